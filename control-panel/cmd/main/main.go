@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 
@@ -26,8 +28,16 @@ func main() {
 	}
 
 	handlers := map[string]cmdHandler{
-		"run-migrations-up": handleRunMigrationsUp,
-		"reset-db-dev":      handleResetDBDev,
+		"run-migrations-up":   handleRunMigrationsUp,
+		"run-migrations-down": handleRunMigrationsDown,
+		"migrate-goto":        handleMigrateGoto,
+		"reset-db-dev":        handleResetDBDev,
+		"sync-xero":           handleSyncXero,
+		"seed-dev":            handleSeedDev,
+		"create-invite":       handleCreateInvite,
+		"import-bom":          handleImportBOM,
+		"import-suppliers":    handleImportSuppliers,
+		"validate-bom":        handleValidateBOM,
 	}
 
 	cmd := os.Args[1]
@@ -60,6 +70,196 @@ func handleRunMigrationsUp(args []string) error {
 	return commands.RunMigrationsUp(isProd)
 }
 
+// handleRunMigrationsDown: run-migrations-down --dev|--prod [n] (default 1)
+func handleRunMigrationsDown(args []string) error {
+	isProd, rest, err := parseEnvArg(args)
+	if err != nil {
+		return err
+	}
+	n := 1
+	if len(rest) > 0 {
+		if n, err = strconv.Atoi(rest[0]); err != nil {
+			return fmt.Errorf("invalid number of migrations %q", rest[0])
+		}
+	}
+	return commands.RunMigrationsDown(isProd, n)
+}
+
+// handleMigrateGoto: migrate-goto --dev|--prod <version>
+func handleMigrateGoto(args []string) error {
+	isProd, rest, err := parseEnvArg(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: migrate-goto --dev|--prod <version>")
+	}
+	v, err := strconv.ParseUint(rest[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid version %q", rest[0])
+	}
+	return commands.MigrateGoto(isProd, uint(v))
+}
+
+// parseEnvArg takes the required --dev/--prod flag (in any position) and returns the
+// remaining arguments.
+func parseEnvArg(args []string) (bool, []string, error) {
+	var isProd, seen bool
+	var rest []string
+	for _, a := range args {
+		switch a {
+		case "--dev", "-d":
+			isProd, seen = false, true
+		case "--prod", "-p":
+			isProd, seen = true, true
+		default:
+			rest = append(rest, a)
+		}
+	}
+	if !seen {
+		return false, nil, fmt.Errorf("Must provide argument --dev or --prod")
+	}
+	return isProd, rest, nil
+}
+
+// handleDBDump: db-dump --dev|--prod [file]
+func handleDBDump(args []string) error {
+	isProd, rest, err := parseEnvArg(args)
+	if err != nil {
+		return err
+	}
+	var path string
+	if len(rest) > 0 {
+		path = rest[0]
+	}
+	return commands.DumpDB(isProd, path)
+}
+
+// handleDBRestore: db-restore --dev|--prod [--replace] <file>
+func handleDBRestore(args []string) error {
+	isProd, rest, err := parseEnvArg(args)
+	if err != nil {
+		return err
+	}
+	var replace bool
+	var path string
+	for _, a := range rest {
+		if a == "--replace" {
+			replace = true
+			continue
+		}
+		path = a
+	}
+	if path == "" {
+		return fmt.Errorf("usage: db-restore --dev|--prod [--replace] <file>")
+	}
+	return commands.RestoreDB(isProd, path, replace)
+}
+
 func handleResetDBDev(args []string) error {
 	return commands.ResetDBDev()
 }
+
+// handleSeedDev: seed-dev [--owner=<user id>]
+func handleSeedDev(args []string) error {
+	fs := flag.NewFlagSet("seed-dev", flag.ContinueOnError)
+	owner := fs.String("owner", "", "user whose personal workspace gets the BOM, mappings and shopping list (default: first Xero connection owner)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return commands.SeedDev(*owner)
+}
+
+// handleSyncXero: sync-xero --dev|--prod [--dry-run] [--batch-size=N]
+func handleSyncXero(args []string) error {
+	fs := flag.NewFlagSet("sync-xero", flag.ContinueOnError)
+	dev := fs.Bool("dev", false, "use DEV_SUPABASE_URL")
+	prod := fs.Bool("prod", false, "use PROD_SUPABASE_URL")
+	dryRun := fs.Bool("dry-run", false, "print what would be synced without calling Xero")
+	batchSize := fs.Int("batch-size", 50, "items/contacts per Xero request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dev == *prod {
+		return fmt.Errorf("Must provide argument --dev or --prod")
+	}
+	return commands.SyncXero(commands.SyncXeroOptions{IsProd: *prod, DryRun: *dryRun, BatchSize: *batchSize})
+}
+
+// handleCreateInvite: create-invite --dev|--prod [--code=X] [--uses=N] [--days=N] [--note=...]
+func handleCreateInvite(args []string) error {
+	fs := flag.NewFlagSet("create-invite", flag.ContinueOnError)
+	dev := fs.Bool("dev", false, "use DEV_SUPABASE_URL")
+	prod := fs.Bool("prod", false, "use PROD_SUPABASE_URL")
+	code := fs.String("code", "", "invite code (default: random)")
+	uses := fs.Int("uses", 1, "number of sign-ups the code allows")
+	days := fs.Int("days", 0, "days until the code expires (0 = never)")
+	note := fs.String("note", "", "who the invite is for")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dev == *prod {
+		return fmt.Errorf("Must provide argument --dev or --prod")
+	}
+	return commands.CreateInvite(commands.CreateInviteOptions{IsProd: *prod, Code: *code, Uses: *uses, Days: *days, Note: *note})
+}
+
+// handleImportBOM: import-bom --dev|--prod [--dry-run] [--skip-xero] [--workspace=ID] <file.csv>
+func handleImportBOM(args []string) error {
+	fs := flag.NewFlagSet("import-bom", flag.ContinueOnError)
+	dev := fs.Bool("dev", false, "use DEV_SUPABASE_URL")
+	prod := fs.Bool("prod", false, "use PROD_SUPABASE_URL")
+	dryRun := fs.Bool("dry-run", false, "check the file without writing")
+	skipXero := fs.Bool("skip-xero", false, "don't check item codes against Xero")
+	workspace := fs.String("workspace", "", "workspace to import into (default: first Xero connection's)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dev == *prod {
+		return fmt.Errorf("Must provide argument --dev or --prod")
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import-bom --dev|--prod [--dry-run] [--skip-xero] [--workspace=ID] <file.csv>")
+	}
+	return commands.ImportBOM(commands.ImportBOMOptions{
+		IsProd: *prod, Path: fs.Arg(0), DryRun: *dryRun, SkipXero: *skipXero, Workspace: *workspace,
+	})
+}
+
+// handleImportSuppliers: import-suppliers --dev|--prod [--dry-run] [--skip-xero] [--workspace=ID] <file.csv>
+func handleImportSuppliers(args []string) error {
+	fs := flag.NewFlagSet("import-suppliers", flag.ContinueOnError)
+	dev := fs.Bool("dev", false, "use DEV_SUPABASE_URL")
+	prod := fs.Bool("prod", false, "use PROD_SUPABASE_URL")
+	dryRun := fs.Bool("dry-run", false, "check the file and list what becomes orderable without writing")
+	skipXero := fs.Bool("skip-xero", false, "don't check items and account numbers against Xero")
+	workspace := fs.String("workspace", "", "workspace to import into (default: first Xero connection's)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dev == *prod {
+		return fmt.Errorf("Must provide argument --dev or --prod")
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: import-suppliers --dev|--prod [--dry-run] [--skip-xero] [--workspace=ID] <file.csv>")
+	}
+	return commands.ImportSuppliers(commands.ImportSuppliersOptions{
+		IsProd: *prod, Path: fs.Arg(0), DryRun: *dryRun, SkipXero: *skipXero, Workspace: *workspace,
+	})
+}
+
+// handleValidateBOM: validate-bom --dev|--prod [--codes=xero|parts|none] [--workspace=ID]
+func handleValidateBOM(args []string) error {
+	fs := flag.NewFlagSet("validate-bom", flag.ContinueOnError)
+	dev := fs.Bool("dev", false, "use DEV_SUPABASE_URL")
+	prod := fs.Bool("prod", false, "use PROD_SUPABASE_URL")
+	codes := fs.String("codes", commands.CodesXero, "check item codes against xero, the local parts table, or none")
+	workspace := fs.String("workspace", "", "workspace to check (default: first Xero connection's)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dev == *prod {
+		return fmt.Errorf("Must provide argument --dev or --prod")
+	}
+	return commands.ValidateBOM(commands.ValidateBOMOptions{IsProd: *prod, Codes: *codes, Workspace: *workspace})
+}
@@ -4,6 +4,8 @@ go 1.24.3
 
 require (
 	github.com/hwalton/psqltoolbox v1.0.1
+	github.com/hwalton/xero-invoice-orderer v0.0.0-00010101000000-000000000000
+	github.com/hwalton/xero-invoice-orderer/pkg/xero v0.0.0-00010101000000-000000000000
 	github.com/jackc/pgx/v5 v5.7.6
 	github.com/joho/godotenv v1.5.1
 )
@@ -11,6 +13,13 @@ require (
 require (
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
-	golang.org/x/crypto v0.37.0 // indirect
-	golang.org/x/text v0.24.0 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	golang.org/x/crypto v0.38.0 // indirect
+	golang.org/x/sync v0.14.0 // indirect
+	golang.org/x/text v0.25.0 // indirect
 )
+
+// shared Xero client from the web app module
+replace github.com/hwalton/xero-invoice-orderer => ../src
+
+replace github.com/hwalton/xero-invoice-orderer/pkg/xero => ../src/pkg/xero
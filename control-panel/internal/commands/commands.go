@@ -6,43 +6,84 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strconv"
 	"time"
 
 	"github.com/hwalton/psqltoolbox"
 )
 
+// migrationsPath is relative to control-panel/cmd/main, where commands are run from.
+const migrationsPath = "../../../src/migrations"
+
 func RunMigrationsUp(isProd bool) error {
-	var dbURL string
-	var ok bool
+	dbURL, err := dbURLFor(isProd)
+	if err != nil {
+		return err
+	}
 
+	fmt.Printf("[%s] Running DB migrations from %s...\n", time.Now().Format(time.RFC3339), migrationsPath)
+	if err := runMigrate(dbURL, "up"); err != nil {
+		return fmt.Errorf("migrate up failed: %w", err)
+	}
+
+	fmt.Printf("[%s] Migrations applied.\n", time.Now().Format(time.RFC3339))
+	return nil
+}
+
+// RunMigrationsDown rolls back the last n migrations. Prod asks for confirmation.
+func RunMigrationsDown(isProd bool, n int) error {
+	if n < 1 {
+		return fmt.Errorf("number of migrations to roll back must be at least 1")
+	}
+	dbURL, err := dbURLFor(isProd)
+	if err != nil {
+		return err
+	}
 	if isProd {
-		dbURL, ok = os.LookupEnv("PROD_SUPABASE_URL")
-		if !ok || dbURL == "" {
-			return fmt.Errorf("PROD_SUPABASE_URL not set")
+		if err := confirm(os.Stdin, fmt.Sprintf("Roll back %d migration(s) on PROD?", n)); err != nil {
+			return err
 		}
-	} else {
-		dbURL, ok = os.LookupEnv("DEV_SUPABASE_URL")
-		if !ok || dbURL == "" {
-			return fmt.Errorf("DEV_SUPABASE_URL not set")
+	}
+
+	fmt.Printf("[%s] Rolling back %d migration(s) from %s...\n", time.Now().Format(time.RFC3339), n, migrationsPath)
+	if err := runMigrate(dbURL, "down", strconv.Itoa(n)); err != nil {
+		return fmt.Errorf("migrate down failed: %w", err)
+	}
+
+	fmt.Printf("[%s] Rollback complete.\n", time.Now().Format(time.RFC3339))
+	return nil
+}
+
+// MigrateGoto migrates up or down to the given version. Prod asks for confirmation.
+func MigrateGoto(isProd bool, version uint) error {
+	dbURL, err := dbURLFor(isProd)
+	if err != nil {
+		return err
+	}
+	if isProd {
+		if err := confirm(os.Stdin, fmt.Sprintf("Migrate PROD to version %d?", version)); err != nil {
+			return err
 		}
 	}
 
-	migrationsPath := "../../../migrations"
+	fmt.Printf("[%s] Migrating to version %d from %s...\n", time.Now().Format(time.RFC3339), version, migrationsPath)
+	if err := runMigrate(dbURL, "goto", strconv.FormatUint(uint64(version), 10)); err != nil {
+		return fmt.Errorf("migrate goto failed: %w", err)
+	}
 
-	fmt.Printf("[%s] Running DB migrations from %s...\n", time.Now().Format(time.RFC3339), migrationsPath)
+	fmt.Printf("[%s] Database at version %d.\n", time.Now().Format(time.RFC3339), version)
+	return nil
+}
+
+// runMigrate runs the migrate CLI against the migrations directory.
+func runMigrate(dbURL string, args ...string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "migrate", "-database", dbURL, "-path", migrationsPath, "up")
+	cmd := exec.CommandContext(ctx, "migrate", append([]string{"-database", dbURL, "-path", migrationsPath}, args...)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("migrate up failed: %w", err)
-	}
-
-	fmt.Printf("[%s] Migrations applied.\n", time.Now().Format(time.RFC3339))
-	return nil
+	return cmd.Run()
 }
 
 func ResetDBDev() error {
@@ -67,8 +108,6 @@ func ResetDBDev() error {
 		}
 	}()
 
-	migrationsPath := "../../../migrations"
-
 	// drop all tables and optionally run migrations
 	if err := psqltoolbox.DropTablesAndMigrate(ctx, conn, dbURL, migrationsPath); err != nil {
 		return err
@@ -1,8 +1,12 @@
 package commands
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -13,3 +17,52 @@ func connectDB(ctx context.Context, dbURL string) (*pgx.Conn, error) {
 	}
 	return pgx.Connect(ctx, dbURL)
 }
+
+// dbURLFor returns PROD_SUPABASE_URL or DEV_SUPABASE_URL.
+func dbURLFor(isProd bool) (string, error) {
+	key := "DEV_SUPABASE_URL"
+	if isProd {
+		key = "PROD_SUPABASE_URL"
+	}
+	dbURL, ok := os.LookupEnv(key)
+	if !ok || dbURL == "" {
+		return "", fmt.Errorf("%s not set", key)
+	}
+	return dbURL, nil
+}
+
+// workspaceFor returns the workspace a command works in: id when given (it must
+// exist), else that of the first Xero connection, as the web app's first user gets.
+func workspaceFor(ctx context.Context, conn *pgx.Conn, id string) (string, error) {
+	if id != "" {
+		var found bool
+		if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM workspaces WHERE id = $1)`, id).Scan(&found); err != nil {
+			return "", fmt.Errorf("load workspace: %w", err)
+		}
+		if !found {
+			return "", fmt.Errorf("workspace %q not found", id)
+		}
+		return id, nil
+	}
+	err := conn.QueryRow(ctx, `SELECT owner_id FROM xero_connections ORDER BY created_at LIMIT 1`).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return "", fmt.Errorf("no xero connection found; pass --workspace=ID")
+	}
+	if err != nil {
+		return "", fmt.Errorf("load xero connection owner: %w", err)
+	}
+	return id, nil
+}
+
+// confirm asks the user to type "yes" before a destructive action.
+func confirm(in io.Reader, question string) error {
+	fmt.Printf("%s Type 'yes' to continue: ", question)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read confirmation: %w", err)
+	}
+	if strings.TrimSpace(line) != "yes" {
+		return fmt.Errorf("aborted")
+	}
+	return nil
+}
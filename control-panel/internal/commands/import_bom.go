@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// ImportBOMOptions configures ImportBOM.
+type ImportBOMOptions struct {
+	IsProd   bool
+	Path     string // CSV with parent_code, child_code, qty columns
+	DryRun   bool   // check only; nothing is written
+	SkipXero bool   // don't check codes against Xero (e.g. before items are synced)
+	// Workspace whose parts lists are imported into; "" for the first Xero
+	// connection's
+	Workspace string
+}
+
+// ImportBOM upserts a workspace's parent_child relationships from a CSV in one
+// transaction after checking every line, and every code against Xero. Problems are
+// printed per line and nothing is written if there are any.
+func ImportBOM(opts ImportBOMOptions) error {
+	f, err := os.Open(opts.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, rowErrs, err := csvimport.ParseBOM(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", opts.Path, err)
+	}
+
+	dbURL, err := dbURLFor(opts.IsProd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+	workspace, err := workspaceFor(ctx, conn, opts.Workspace)
+	if err != nil {
+		return err
+	}
+
+	if !opts.SkipXero {
+		xc := xero.NewClient(&http.Client{Timeout: 60 * time.Second}, os.Getenv("XERO_BASE_URL"))
+		accessToken, tenantID, err := xeroAccess(ctx, conn, xc)
+		if err != nil {
+			return err
+		}
+		items, err := xc.GetItemsByCodes(ctx, accessToken, tenantID, csvimport.BOMCodes(rows))
+		if err != nil {
+			return fmt.Errorf("look up items in Xero: %w", err)
+		}
+		known := make(map[string]bool, len(items))
+		for code := range items {
+			known[code] = true
+		}
+		rowErrs = append(rowErrs, csvimport.CheckBOMCodes(rows, known)...)
+		csvimport.SortErrors(rowErrs)
+	}
+	if len(rowErrs) > 0 {
+		for _, e := range rowErrs {
+			fmt.Printf("  %s\n", e)
+		}
+		return fmt.Errorf("%d line(s) have problems; nothing imported", len(rowErrs))
+	}
+	if opts.DryRun {
+		fmt.Printf("Dry run: %d line(s) are valid; nothing changed.\n", len(rows))
+		return nil
+	}
+	if opts.IsProd {
+		if err := confirm(os.Stdin, fmt.Sprintf("Import %d parent_child line(s) into PROD?", len(rows))); err != nil {
+			return err
+		}
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	res, err := csvimport.UpsertBOM(ctx, tx, workspace, rows)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	fmt.Printf("[%s] Imported %d line(s) into %s parent_child of workspace %s: %d added, %d updated, %d unchanged.\n",
+		time.Now().Format(time.RFC3339), len(rows), envName(opts.IsProd), workspace, res.Inserted, res.Updated, res.Unchanged)
+	return nil
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+)
+
+// ImportSuppliersOptions configures ImportSuppliers.
+type ImportSuppliersOptions struct {
+	IsProd   bool
+	Path     string // CSV with item_code, account_number columns
+	DryRun   bool   // check only and list what becomes orderable; nothing is written
+	SkipXero bool   // don't check items and account numbers against Xero
+	// Workspace whose supplier mappings are imported into and whose shopping list
+	// is checked; "" for the first Xero connection's
+	Workspace string
+}
+
+// ImportSuppliers adds item-to-supplier mappings (items_contacts) from a CSV in one
+// transaction after checking every line, and every item and AccountNumber against
+// Xero. Problems are printed per line and nothing is written if there are any. The
+// unordered shopping list items that had no supplier and get one are listed.
+func ImportSuppliers(opts ImportSuppliersOptions) error {
+	f, err := os.Open(opts.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, rowErrs, err := csvimport.ParseSupplierMappings(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", opts.Path, err)
+	}
+
+	dbURL, err := dbURLFor(opts.IsProd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+	workspace, err := workspaceFor(ctx, conn, opts.Workspace)
+	if err != nil {
+		return err
+	}
+
+	if !opts.SkipXero {
+		xc := xero.NewClient(&http.Client{Timeout: 60 * time.Second}, os.Getenv("XERO_BASE_URL"))
+		accessToken, tenantID, err := xeroAccess(ctx, conn, xc)
+		if err != nil {
+			return err
+		}
+		items, err := xc.GetItemsByCodes(ctx, accessToken, tenantID, csvimport.SupplierItemCodes(rows))
+		if err != nil {
+			return fmt.Errorf("look up items in Xero: %w", err)
+		}
+		knownItems := make(map[string]bool, len(items))
+		for code := range items {
+			knownItems[code] = true
+		}
+		contacts, err := xc.GetContactIDsByAccountNumbers(ctx, accessToken, tenantID, csvimport.SupplierAccounts(rows))
+		if err != nil {
+			return fmt.Errorf("look up contacts in Xero: %w", err)
+		}
+		knownAccounts := make(map[string]bool, len(contacts))
+		for acc := range contacts {
+			knownAccounts[acc] = true
+		}
+		rowErrs = append(rowErrs, csvimport.CheckSupplierMappings(rows, knownItems, knownAccounts)...)
+		csvimport.SortErrors(rowErrs)
+	}
+	if len(rowErrs) > 0 {
+		for _, e := range rowErrs {
+			fmt.Printf("  %s\n", e)
+		}
+		return fmt.Errorf("%d line(s) have problems; nothing imported", len(rowErrs))
+	}
+
+	orderable, err := newlyOrderable(ctx, conn, workspace, rows)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		fmt.Printf("Dry run: %d line(s) are valid; nothing changed.\n", len(rows))
+		printOrderable("would become orderable", orderable)
+		return nil
+	}
+	if opts.IsProd {
+		if err := confirm(os.Stdin, fmt.Sprintf("Import %d supplier mapping(s) into PROD?", len(rows))); err != nil {
+			return err
+		}
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	res, err := csvimport.UpsertSupplierMappings(ctx, tx, workspace, rows)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	fmt.Printf("[%s] Imported %d line(s) into %s items_contacts of workspace %s: %d added, %d restored, %d already mapped.\n",
+		time.Now().Format(time.RFC3339), len(rows), envName(opts.IsProd), workspace, res.Inserted, res.Updated, res.Unchanged)
+	printOrderable("can now be ordered", orderable)
+	return nil
+}
+
+// newlyOrderable reads the workspace's unordered shopping list and mapped items, and
+// returns the rows that the import gives a supplier.
+func newlyOrderable(ctx context.Context, conn *pgx.Conn, workspace string, rows []csvimport.SupplierRow) ([]service.ShoppingRow, error) {
+	rs, err := conn.Query(ctx, `
+SELECT list_id, item_id, quantity, COALESCE(source_invoice, '')
+FROM shopping_list
+WHERE ordered = FALSE AND archived_at IS NULL AND owner_id = $1
+`, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+	shopping, err := pgx.CollectRows(rs, func(row pgx.CollectableRow) (service.ShoppingRow, error) {
+		var s service.ShoppingRow
+		err := row.Scan(&s.ListID, &s.ItemID, &s.Quantity, &s.SourceInvoice)
+		return s, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan shopping row: %w", err)
+	}
+
+	rs, err = conn.Query(ctx, `SELECT DISTINCT item_id FROM items_contacts WHERE workspace_id = $1 AND archived_at IS NULL`, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	ids, err := pgx.CollectRows(rs, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("scan item id: %w", err)
+	}
+	tracked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		tracked[id] = true
+	}
+	return service.NewlyOrderable(shopping, tracked, rows), nil
+}
+
+func printOrderable(what string, rows []service.ShoppingRow) {
+	if len(rows) == 0 {
+		fmt.Println("No unordered shopping list items are waiting for these suppliers.")
+		return
+	}
+	fmt.Printf("%d shopping list item(s) %s:\n", len(rows), what)
+	for _, r := range rows {
+		from := ""
+		if r.SourceInvoice != "" {
+			from = "  (" + r.SourceInvoice + ")"
+		}
+		fmt.Printf("  #%d %s x%g%s\n", r.ListID, r.ItemID, r.Quantity, from)
+	}
+}
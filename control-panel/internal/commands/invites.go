@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// CreateInviteOptions configures CreateInvite.
+type CreateInviteOptions struct {
+	IsProd bool
+	Code   string // generated when empty
+	Uses   int    // sign-ups allowed
+	Days   int    // 0 = never expires
+	Note   string
+}
+
+// inviteAlphabet avoids look-alike characters (0/O, 1/I).
+const inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func randomInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = inviteAlphabet[int(b[i])%len(inviteAlphabet)]
+	}
+	return string(b), nil
+}
+
+// CreateInvite adds an invite code that lets users register on /register.
+func CreateInvite(opts CreateInviteOptions) error {
+	if opts.Uses < 1 {
+		return fmt.Errorf("uses must be at least 1")
+	}
+	code := strings.ToUpper(strings.TrimSpace(opts.Code))
+	if code == "" {
+		var err error
+		if code, err = randomInviteCode(); err != nil {
+			return fmt.Errorf("generate code: %w", err)
+		}
+	}
+	var expiresAt *int64
+	if opts.Days > 0 {
+		at := time.Now().AddDate(0, 0, opts.Days).Unix()
+		expiresAt = &at
+	}
+
+	dbURL, err := dbURLFor(opts.IsProd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, `INSERT INTO invite_codes (code, max_uses, expires_at, note) VALUES ($1, $2, $3, $4)`,
+		code, opts.Uses, expiresAt, opts.Note); err != nil {
+		return fmt.Errorf("insert invite_code: %w", err)
+	}
+
+	expiry := "never expires"
+	if expiresAt != nil {
+		expiry = "expires " + time.Unix(*expiresAt, 0).Format(time.RFC3339)
+	}
+	fmt.Printf("[%s] Created %s invite %s (%d use(s), %s).\n",
+		time.Now().Format(time.RFC3339), envName(opts.IsProd), code, opts.Uses, expiry)
+	return nil
+}
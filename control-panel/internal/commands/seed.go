@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// seedSourceInvoice tags the shopping_list rows written by SeedDev so a re-run can
+// replace them.
+const seedSourceInvoice = "SEED-0001"
+
+// seedSQL is the dev catalogue shared by every workspace: suppliers and parts. Safe
+// to re-run: rows are upserted.
+const seedSQL = `
+INSERT INTO suppliers (supplier_id, supplier_name, contact_email, phone) VALUES
+  ('S-001', 'Northern Fasteners Ltd', 'orders@northernfasteners.example', '0161 496 0001'),
+  ('S-002', 'Brightline Electrical', 'sales@brightline.example', '0113 496 0002'),
+  ('S-003', 'Coastal Timber Supplies', 'trade@coastaltimber.example', '0117 496 0003'),
+  ('S-004', 'Midlands Metalwork', 'hello@midlandsmetal.example', '0121 496 0004')
+ON CONFLICT (supplier_id) DO UPDATE
+  SET supplier_name = EXCLUDED.supplier_name, contact_email = EXCLUDED.contact_email, phone = EXCLUDED.phone;
+
+INSERT INTO parts (part_id, name, description, cost_price, sales_price) VALUES
+  ('P-0001', 'M6 x 20 hex bolt (pack of 50)', 'A2 stainless', 6.40, 11.50),
+  ('P-0002', '12V LED strip 1m', 'Warm white, IP65', 4.95, 9.99),
+  ('P-0003', '12V rocker switch', 'Illuminated, 20A', 2.10, 4.50),
+  ('P-0004', '40x40 aluminium extrusion 1m', '8mm slot', 9.80, 17.00),
+  ('P-0005', 'Hinge, stainless 75mm', NULL, 3.25, 6.00),
+  ('P-0006', 'Wago 221 connector', '3-way', 0.38, 0.90),
+  ('P-0007', '18mm birch ply sheet', '2440x1220', 62.00, 95.00),
+  ('P-0008', 'Self-tapping screw 4x30 (pack of 200)', NULL, 5.60, 9.50),
+  ('P-0009', 'M6 nyloc nut (pack of 100)', 'A2 stainless', 3.90, 7.20),
+  ('P-0010', 'Corner bracket 40 series', 'Die cast', 1.15, 2.40),
+  ('KIT-001', 'Cabinet door kit', 'Hinges and fixings for one door', NULL, 45.00),
+  ('KIT-002', 'Lighting kit', 'LED strip, switch and connectors', NULL, 39.00),
+  ('FRAME-001', 'Bed frame', 'Extrusion frame with lighting', NULL, 320.00),
+  ('VAN-001', 'Camper conversion (SWB)', 'Top-level assembly', NULL, 2450.00)
+ON CONFLICT (part_id) DO UPDATE
+  SET name = EXCLUDED.name, description = EXCLUDED.description,
+      cost_price = EXCLUDED.cost_price, sales_price = EXCLUDED.sales_price;
+`
+
+// seedBOMSQL gives workspace $1 a three-level BOM (VAN-001 > FRAME-001 > KIT-002,
+// plus KIT-001).
+const seedBOMSQL = `
+INSERT INTO parent_child (workspace_id, parent_id, child_id, quantity)
+SELECT $1, parent_id, child_id, quantity FROM (VALUES
+  ('KIT-001', 'P-0001', 3),
+  ('KIT-001', 'P-0005', 1),
+  ('KIT-001', 'P-0009', 4),
+  ('KIT-002', 'P-0002', 2),
+  ('KIT-002', 'P-0003', 1),
+  ('KIT-002', 'P-0006', 6),
+  ('FRAME-001', 'P-0004', 4),
+  ('FRAME-001', 'P-0010', 8),
+  ('FRAME-001', 'KIT-002', 1),
+  ('VAN-001', 'FRAME-001', 1),
+  ('VAN-001', 'KIT-001', 2),
+  ('VAN-001', 'P-0007', 3),
+  ('VAN-001', 'P-0008', 1)
+) AS v (parent_id, child_id, quantity)
+ON CONFLICT (workspace_id, parent_id, child_id) DO UPDATE SET quantity = EXCLUDED.quantity
+`
+
+// seedMappingsSQL gives workspace $1 supplier mappings, with lead times, MOQs and
+// pack sizes, for every purchasable part.
+const seedMappingsSQL = `
+INSERT INTO items_contacts (workspace_id, item_id, contact_id, lead_time_days, minimum_order_qty, pack_size, unit_price)
+SELECT $1, item_id, contact_id, lead_time_days, minimum_order_qty, pack_size, unit_price FROM (VALUES
+  ('P-0001', 'S-001', 3, NULL::int, NULL::numeric, NULL::numeric, NULL::numeric),
+  ('P-0002', 'S-002', 5, 10, 5, 1.2500),
+  ('P-0003', 'S-002', 5, NULL, 10, 0.4000),
+  ('P-0004', 'S-004', 10, 6, NULL, NULL),
+  ('P-0005', 'S-004', 10, NULL, 2, 12.5000),
+  ('P-0006', 'S-002', 5, 50, 50, 0.0800),
+  ('P-0007', 'S-003', 14, NULL, NULL, NULL),
+  ('P-0008', 'S-001', 3, NULL, NULL, NULL),
+  ('P-0009', 'S-001', 3, NULL, NULL, 2.1000),
+  ('P-0010', 'S-004', NULL, NULL, 4, NULL)
+) AS v (item_id, contact_id, lead_time_days, minimum_order_qty, pack_size, unit_price)
+ON CONFLICT (workspace_id, item_id, contact_id) DO UPDATE
+  SET lead_time_days = EXCLUDED.lead_time_days, minimum_order_qty = EXCLUDED.minimum_order_qty, pack_size = EXCLUDED.pack_size,
+      unit_price = EXCLUDED.unit_price
+`
+
+// SeedDev loads sample catalogue data, and a BOM, supplier mappings and a few
+// shopping list rows for one workspace, into the dev database so BOM resolution can
+// be exercised without a live Xero org. The workspace is ownerID's personal one, or
+// the first Xero connection's when ownerID is empty.
+func SeedDev(ownerID string) error {
+	dbURL, err := dbURLFor(false)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+
+	if ownerID == "" {
+		err := conn.QueryRow(ctx, `SELECT owner_id FROM xero_connections ORDER BY created_at LIMIT 1`).Scan(&ownerID)
+		if err != nil && err != pgx.ErrNoRows {
+			return fmt.Errorf("load xero connection owner: %w", err)
+		}
+		if ownerID == "" {
+			ownerID = "dev-owner"
+		}
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	if _, err := tx.Exec(ctx, seedSQL); err != nil {
+		return fmt.Errorf("seed catalogue: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO workspaces (id, name) VALUES ($1, 'Personal') ON CONFLICT (id) DO NOTHING`, ownerID); err != nil {
+		return fmt.Errorf("seed workspace: %w", err)
+	}
+	// a new workspace is the owner's personal one; an existing one keeps its members
+	if _, err := tx.Exec(ctx, `
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT $1, $1, 'owner'
+WHERE NOT EXISTS (SELECT 1 FROM workspace_members WHERE workspace_id = $1)
+`, ownerID); err != nil {
+		return fmt.Errorf("seed workspace member: %w", err)
+	}
+	if _, err := tx.Exec(ctx, seedBOMSQL, ownerID); err != nil {
+		return fmt.Errorf("seed parent_child: %w", err)
+	}
+	if _, err := tx.Exec(ctx, seedMappingsSQL, ownerID); err != nil {
+		return fmt.Errorf("seed items_contacts: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM shopping_list WHERE source_invoice = $1`, seedSourceInvoice); err != nil {
+		return fmt.Errorf("clear seeded shopping list: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO shopping_list (item_id, quantity, ordered, owner_id, source_invoice) VALUES
+  ('P-0002', 4, FALSE, $1, $2),
+  ('P-0006', 12, FALSE, $1, $2),
+  ('P-0007', 3, FALSE, $1, $2),
+  ('P-0001', 6, TRUE, $1, $2)
+`, ownerID, seedSourceInvoice); err != nil {
+		return fmt.Errorf("seed shopping list: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	fmt.Printf("[%s] Seeded dev catalogue (14 parts, 4 suppliers) and the BOM for VAN-001, supplier mappings and shopping list rows for workspace %s.\n",
+		time.Now().Format(time.RFC3339), ownerID)
+	return nil
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// snapshotVersion is bumped when the bundle layout changes.
+const snapshotVersion = 1
+
+// snapshotTable is one table in a snapshot. Dump selects rows as JSON with
+// dumpExpr (applied to alias t); identity names an identity column whose sequence
+// must be moved past restored ids.
+type snapshotTable struct {
+	Name     string
+	DumpExpr string
+	Identity string
+}
+
+// snapshotTables are dumped in this order and restored in it (parents first).
+// Xero tokens are blanked: a restored connection must be re-authorised in the app.
+// build_id is dropped because builds are not part of the snapshot.
+var snapshotTables = []snapshotTable{
+	{Name: "suppliers", DumpExpr: "to_jsonb(t)"},
+	{Name: "parts", DumpExpr: "to_jsonb(t)"},
+	{Name: "parent_child", DumpExpr: "to_jsonb(t)"},
+	{Name: "items_contacts", DumpExpr: "to_jsonb(t)"},
+	{Name: "xero_connections", DumpExpr: `to_jsonb(t) || '{"access_token": "", "refresh_token": "", "expires_at": 0}'::jsonb`},
+	{Name: "shopping_list", DumpExpr: "to_jsonb(t) - 'build_id'", Identity: "list_id"},
+}
+
+// snapshot is the db-dump bundle.
+type snapshot struct {
+	Version   int                          `json:"version"`
+	CreatedAt string                       `json:"created_at"`
+	Source    string                       `json:"source"` // dev or prod
+	Tables    map[string][]json.RawMessage `json:"tables"`
+}
+
+func envName(isProd bool) string {
+	if isProd {
+		return "prod"
+	}
+	return "dev"
+}
+
+// DumpDB writes the app tables to a JSON bundle at path (default
+// snapshot-<env>-<timestamp>.json). Tables missing from the database are skipped.
+func DumpDB(isProd bool, path string) error {
+	dbURL, err := dbURLFor(isProd)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		path = fmt.Sprintf("snapshot-%s-%s.json", envName(isProd), time.Now().UTC().Format("20060102-150405"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+
+	snap := snapshot{
+		Version:   snapshotVersion,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Source:    envName(isProd),
+		Tables:    map[string][]json.RawMessage{},
+	}
+	for _, tbl := range snapshotTables {
+		ok, err := tableExists(ctx, conn, tbl.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Printf("  %-16s missing, skipped\n", tbl.Name)
+			continue
+		}
+		rows, err := conn.Query(ctx, fmt.Sprintf("SELECT %s FROM %s t", tbl.DumpExpr, pgx.Identifier{tbl.Name}.Sanitize()))
+		if err != nil {
+			return fmt.Errorf("dump %s: %w", tbl.Name, err)
+		}
+		out := []json.RawMessage{}
+		for rows.Next() {
+			var b []byte
+			if err := rows.Scan(&b); err != nil {
+				rows.Close()
+				return fmt.Errorf("dump %s: %w", tbl.Name, err)
+			}
+			out = append(out, json.RawMessage(b))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("dump %s: %w", tbl.Name, err)
+		}
+		snap.Tables[tbl.Name] = out
+		fmt.Printf("  %-16s %d rows\n", tbl.Name, len(out))
+	}
+
+	b, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	// tokens are stripped but the bundle still holds business data
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	fmt.Printf("[%s] Snapshot written to %s.\n", time.Now().Format(time.RFC3339), path)
+	return nil
+}
+
+// RestoreDB loads a db-dump bundle. By default rows are merged (existing keys are
+// kept); with replace the tables in the bundle are emptied first. Prod asks for
+// confirmation. Everything runs in one transaction.
+func RestoreDB(isProd bool, path string, replace bool) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d (want %d)", snap.Version, snapshotVersion)
+	}
+
+	dbURL, err := dbURLFor(isProd)
+	if err != nil {
+		return err
+	}
+	if isProd {
+		mode := "merge into"
+		if replace {
+			mode = "REPLACE"
+		}
+		if err := confirm(os.Stdin, fmt.Sprintf("Restore %s snapshot from %s and %s PROD tables?", snap.Source, snap.CreatedAt, mode)); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	if replace {
+		// children first
+		for i := len(snapshotTables) - 1; i >= 0; i-- {
+			tbl := snapshotTables[i]
+			if _, ok := snap.Tables[tbl.Name]; !ok {
+				continue
+			}
+			if _, err := tx.Exec(ctx, "DELETE FROM "+pgx.Identifier{tbl.Name}.Sanitize()); err != nil {
+				return fmt.Errorf("clear %s: %w", tbl.Name, err)
+			}
+		}
+	}
+
+	for _, tbl := range snapshotTables {
+		rows, ok := snap.Tables[tbl.Name]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(rows)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", tbl.Name, err)
+		}
+		name := pgx.Identifier{tbl.Name}.Sanitize()
+		tag, err := tx.Exec(ctx, fmt.Sprintf(`
+INSERT INTO %[1]s OVERRIDING SYSTEM VALUE
+SELECT * FROM json_populate_recordset(NULL::%[1]s, $1::json)
+ON CONFLICT DO NOTHING
+`, name), string(data))
+		if err != nil {
+			return fmt.Errorf("restore %s: %w", tbl.Name, err)
+		}
+		if tbl.Identity != "" {
+			if _, err := tx.Exec(ctx, fmt.Sprintf(
+				`SELECT setval(pg_get_serial_sequence('%[1]s', '%[2]s'), COALESCE((SELECT max(%[2]s) FROM %[1]s), 0) + 1, false)`,
+				tbl.Name, tbl.Identity)); err != nil {
+				return fmt.Errorf("reset %s identity: %w", tbl.Name, err)
+			}
+		}
+		fmt.Printf("  %-16s %d of %d rows inserted\n", tbl.Name, tag.RowsAffected(), len(rows))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	fmt.Printf("[%s] Snapshot %s restored.\n", time.Now().Format(time.RFC3339), path)
+	return nil
+}
+
+func tableExists(ctx context.Context, conn *pgx.Conn, name string) (bool, error) {
+	var ok bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&ok); err != nil {
+		return false, fmt.Errorf("check %s table: %w", name, err)
+	}
+	return ok, nil
+}
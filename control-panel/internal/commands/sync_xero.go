@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+)
+
+// SyncXeroOptions controls SyncXero.
+type SyncXeroOptions struct {
+	IsProd    bool
+	DryRun    bool // print what would be sent without calling Xero
+	BatchSize int  // items/contacts per Xero request (1..xero.MaxSyncBatch)
+}
+
+// SyncXero loads suppliers and parts from the database and upserts them to Xero as
+// Contacts and Items, in batches, printing progress as it goes.
+func SyncXero(opts SyncXeroOptions) error {
+	if opts.BatchSize <= 0 || opts.BatchSize > xero.MaxSyncBatch {
+		return fmt.Errorf("batch size must be between 1 and %d", xero.MaxSyncBatch)
+	}
+	dbURL, err := dbURLFor(opts.IsProd)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+
+	suppliers, err := loadSuppliers(ctx, conn)
+	if err != nil {
+		return err
+	}
+	parts, err := loadParts(ctx, conn)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[%s] Loaded %d suppliers and %d parts.\n", time.Now().Format(time.RFC3339), len(suppliers), len(parts))
+
+	if opts.DryRun {
+		for _, s := range suppliers {
+			fmt.Printf("  contact %s  %s\n", s.SupplierID, s.SupplierName)
+		}
+		for _, p := range parts {
+			fmt.Printf("  item    %s  %s  cost=%.2f sales=%.2f\n", p.PartID, p.Name, p.CostPrice, p.SalesPrice)
+		}
+		fmt.Printf("Dry run: %d contact batch(es) and %d item batch(es) of up to %d would be sent; nothing changed.\n",
+			batchCount(len(suppliers), opts.BatchSize), batchCount(len(parts), opts.BatchSize), opts.BatchSize)
+		return nil
+	}
+
+	// XERO_BASE_URL: same override as the web app, e.g. to sync into a fake Xero
+	xc := xero.NewClient(&http.Client{Timeout: 60 * time.Second}, os.Getenv("XERO_BASE_URL"))
+	accessToken, tenantID, err := xeroAccess(ctx, conn, xc)
+	if err != nil {
+		return err
+	}
+
+	// contacts first so items can reference suppliers that already exist
+	total := batchCount(len(suppliers), opts.BatchSize)
+	for i := 0; i < len(suppliers); i += opts.BatchSize {
+		batch := suppliers[i:min(i+opts.BatchSize, len(suppliers))]
+		fmt.Printf("contacts: batch %d/%d (%d)... ", i/opts.BatchSize+1, total, len(batch))
+		res, err := xc.SyncSuppliersToXero(ctx, accessToken, tenantID, batch, time.Time{})
+		if err != nil {
+			fmt.Println("failed")
+			return fmt.Errorf("sync contacts %d-%d: %w", i+1, i+len(batch), err)
+		}
+		fmt.Printf("ok (%d created, %d updated, %d unchanged)\n", res.Created, res.Updated, res.Unchanged)
+	}
+
+	total = batchCount(len(parts), opts.BatchSize)
+	for i := 0; i < len(parts); i += opts.BatchSize {
+		batch := parts[i:min(i+opts.BatchSize, len(parts))]
+		fmt.Printf("items: batch %d/%d (%d)... ", i/opts.BatchSize+1, total, len(batch))
+		if err := xc.UpsertItemsBatch(ctx, accessToken, tenantID, batch); err != nil {
+			fmt.Println("failed")
+			return fmt.Errorf("sync items %d-%d: %w", i+1, i+len(batch), err)
+		}
+		fmt.Println("ok")
+	}
+
+	fmt.Printf("[%s] Synced %d contacts and %d items to Xero.\n", time.Now().Format(time.RFC3339), len(suppliers), len(parts))
+	return nil
+}
+
+func batchCount(n, size int) int {
+	return (n + size - 1) / size
+}
+
+func loadSuppliers(ctx context.Context, conn *pgx.Conn) ([]xero.Supplier, error) {
+	rows, err := conn.Query(ctx, `
+SELECT supplier_id, COALESCE(supplier_name, ''), COALESCE(contact_email, ''), COALESCE(phone, ''), COALESCE(updated_at, 0)
+FROM suppliers
+ORDER BY supplier_id
+`)
+	if err != nil {
+		return nil, fmt.Errorf("query suppliers: %w", err)
+	}
+	defer rows.Close()
+
+	var out []xero.Supplier
+	for rows.Next() {
+		var s xero.Supplier
+		if err := rows.Scan(&s.SupplierID, &s.SupplierName, &s.ContactEmail, &s.Phone, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan supplier: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func loadParts(ctx context.Context, conn *pgx.Conn) ([]xero.Part, error) {
+	rows, err := conn.Query(ctx, `
+SELECT
+  part_id,
+  COALESCE(name, ''),
+  COALESCE(description, ''),
+  COALESCE(cost_price, 0)::float8,
+  COALESCE(sales_price, 0)::float8
+FROM parts
+ORDER BY part_id
+`)
+	if err != nil {
+		return nil, fmt.Errorf("query parts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []xero.Part
+	for rows.Next() {
+		var p xero.Part
+		if err := rows.Scan(&p.PartID, &p.Name, &p.Description, &p.CostPrice, &p.SalesPrice); err != nil {
+			return nil, fmt.Errorf("scan part: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// xeroAccess returns a usable access token and tenant from the first stored Xero
+// connection, refreshing (and saving) the token when it is about to expire.
+func xeroAccess(ctx context.Context, conn *pgx.Conn, xc *xero.Client) (string, string, error) {
+	var id, tenantID, accessToken, refreshToken string
+	var expiresAt int64
+	err := conn.QueryRow(ctx, `
+SELECT id, tenant_id, access_token, refresh_token, COALESCE(expires_at, 0)
+FROM xero_connections
+ORDER BY created_at
+LIMIT 1
+`).Scan(&id, &tenantID, &accessToken, &refreshToken, &expiresAt)
+	if err == pgx.ErrNoRows {
+		return "", "", fmt.Errorf("no xero connection found; connect Xero in the web app first")
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("load xero connection: %w", err)
+	}
+	if expiresAt > time.Now().Unix()+60 {
+		return accessToken, tenantID, nil
+	}
+
+	clientID, clientSecret := os.Getenv("XERO_CLIENT_ID"), os.Getenv("XERO_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" {
+		return "", "", fmt.Errorf("xero token expired and XERO_CLIENT_ID/XERO_CLIENT_SECRET not set")
+	}
+	tr, err := xc.RefreshToken(ctx, clientID, clientSecret, refreshToken)
+	if err != nil {
+		return "", "", fmt.Errorf("refresh xero token: %w", err)
+	}
+	secs := tr.ExpiresIn
+	if secs == 0 {
+		secs = 3600
+	}
+	if _, err := conn.Exec(ctx, `
+UPDATE xero_connections
+SET access_token = $2, refresh_token = $3, expires_at = $4, updated_at = (extract(epoch from now()))::bigint
+WHERE id = $1
+`, id, tr.AccessToken, tr.RefreshToken, time.Now().Unix()+secs); err != nil {
+		return "", "", fmt.Errorf("save refreshed xero token: %w", err)
+	}
+	return tr.AccessToken, tenantID, nil
+}
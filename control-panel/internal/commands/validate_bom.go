@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+)
+
+// Where ValidateBOM looks up item codes.
+const (
+	CodesXero  = "xero"  // Xero items
+	CodesParts = "parts" // the local parts table
+	CodesNone  = "none"  // don't check codes
+)
+
+// ValidateBOMOptions configures ValidateBOM.
+type ValidateBOMOptions struct {
+	IsProd bool
+	Codes  string // CodesXero, CodesParts or CodesNone
+	// Workspace whose parts lists are checked; "" for the first Xero connection's
+	Workspace string
+}
+
+// ValidateBOM scans a workspace's parent_child for cycles, codes that are not items and assemblies
+// that also have a supplier mapping, and prints what to fix. It fails when anything
+// is found, so it can gate a deploy or an import.
+func ValidateBOM(opts ValidateBOMOptions) error {
+	dbURL, err := dbURLFor(opts.IsProd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	conn, err := connectDB(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer func() {
+		if cerr := conn.Close(ctx); cerr != nil {
+			log.Printf("warning: failed to close db connection: %v", cerr)
+		}
+	}()
+	workspace, err := workspaceFor(ctx, conn, opts.Workspace)
+	if err != nil {
+		return err
+	}
+
+	rs, err := conn.Query(ctx, `SELECT parent_id, child_id, quantity FROM parent_child WHERE workspace_id = $1 ORDER BY parent_id, child_id`, workspace)
+	if err != nil {
+		return fmt.Errorf("query parent_child: %w", err)
+	}
+	rows, err := pgx.CollectRows(rs, func(row pgx.CollectableRow) (service.ParentChildRow, error) {
+		var r service.ParentChildRow
+		err := row.Scan(&r.Parent, &r.Child, &r.Quantity)
+		return r, err
+	})
+	if err != nil {
+		return fmt.Errorf("scan parent_child: %w", err)
+	}
+
+	rs, err = conn.Query(ctx, `SELECT item_id, contact_id FROM items_contacts WHERE workspace_id = $1 AND archived_at IS NULL`, workspace)
+	if err != nil {
+		return fmt.Errorf("query items_contacts: %w", err)
+	}
+	mapped := map[string][]string{}
+	var item, contact string
+	if _, err := pgx.ForEachRow(rs, []any{&item, &contact}, func() error {
+		mapped[item] = append(mapped[item], contact)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("scan items_contacts: %w", err)
+	}
+
+	codes := service.BOMCodes(rows)
+	var known map[string]bool
+	switch opts.Codes {
+	case CodesXero:
+		xc := xero.NewClient(&http.Client{Timeout: 60 * time.Second}, os.Getenv("XERO_BASE_URL"))
+		accessToken, tenantID, err := xeroAccess(ctx, conn, xc)
+		if err != nil {
+			return err
+		}
+		items, err := xc.GetItemsByCodes(ctx, accessToken, tenantID, codes)
+		if err != nil {
+			return fmt.Errorf("look up items in Xero: %w", err)
+		}
+		known = make(map[string]bool, len(items))
+		for code := range items {
+			known[code] = true
+		}
+	case CodesParts:
+		rs, err := conn.Query(ctx, `SELECT part_id FROM parts WHERE part_id = ANY($1)`, codes)
+		if err != nil {
+			return fmt.Errorf("query parts: %w", err)
+		}
+		ids, err := pgx.CollectRows(rs, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("scan part id: %w", err)
+		}
+		known = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			known[id] = true
+		}
+	case CodesNone:
+	default:
+		return fmt.Errorf("codes must be %s, %s or %s", CodesXero, CodesParts, CodesNone)
+	}
+
+	issues := service.ValidateBOM(rows, known, mapped)
+	fmt.Printf("[%s] Checked %d parent_child row(s) and %d item code(s) in %s.\n",
+		time.Now().Format(time.RFC3339), len(rows), len(codes), envName(opts.IsProd))
+	printBOMIssues(issues, opts.Codes)
+	if n := issues.Count(); n > 0 {
+		return fmt.Errorf("%d problem(s) found", n)
+	}
+	fmt.Println("No problems found.")
+	return nil
+}
+
+func printBOMIssues(issues service.BOMIssues, codes string) {
+	if len(issues.Cycles) > 0 {
+		fmt.Printf("\nCycles (%d): these assemblies contain themselves and cannot be resolved.\n", len(issues.Cycles))
+		fmt.Println("Delete one parent_child row in each loop:")
+		for _, c := range issues.Cycles {
+			fmt.Printf("  %s\n", strings.Join(c, " > "))
+		}
+	}
+	if len(issues.Unknown) > 0 {
+		where := "Xero"
+		if codes == CodesParts {
+			where = "the parts table"
+		}
+		fmt.Printf("\nUnknown codes (%d): not found in %s. Create the item or fix the code in these rows:\n", len(issues.Unknown), where)
+		for _, u := range issues.Unknown {
+			fmt.Printf("  %-20s used in %s\n", u.Code, strings.Join(u.UsedIn, ", "))
+		}
+	}
+	if len(issues.MappedAssemblies) > 0 {
+		fmt.Printf("\nAssemblies with suppliers (%d): these are ordered whole unless \"Order parts\" is chosen on the invoice page.\n", len(issues.MappedAssemblies))
+		fmt.Println("Remove the items_contacts rows if they are built in house, or the parent_child rows if they are bought:")
+		for _, m := range issues.MappedAssemblies {
+			fmt.Printf("  %-20s %d part(s), supplier %s\n", m.Code, m.Children, strings.Join(m.Suppliers, ", "))
+		}
+	}
+}
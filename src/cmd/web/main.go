@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	"github.com/hwalton/xero-invoice-orderer/internal/dbmigrate"
 	"github.com/hwalton/xero-invoice-orderer/internal/frontend"
 	"github.com/hwalton/xero-invoice-orderer/internal/handler"
+	"github.com/hwalton/xero-invoice-orderer/internal/jobs"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/notify"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/storage"
 	"github.com/hwalton/xero-invoice-orderer/pkg/auth"
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/supabasetoolbox"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xerotest"
 	"github.com/joho/godotenv"
 )
 
@@ -20,34 +33,145 @@ func main() {
 		log.Printf("no .env file found — relying on environment: %v", err)
 	}
 
-	addr := ":" + getEnv("PORT", "8080")
-	httpClient := &http.Client{Timeout: 10 * time.Second}
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	// several instances log to one place behind a load balancer; say which one this is
+	log.SetPrefix("[" + cfg.InstanceID + "] ")
 
-	// Construct an authenticator (replace with your pkg/auth constructor)
-	// e.g. auth.NewJWT(secret) or auth.NewSupabaseClient(supabaseURL, httpClient)
-	authProvider := auth.NewJWT(
-		os.Getenv("SUPABASE_JWT_SECRET"),
-		os.Getenv("SUPABASE_JWT_ISSUER"),
-		os.Getenv("SUPABASE_JWT_AUDIENCE"),
-	)
+	if cfg.RunMigrations {
+		res, err := dbmigrate.Up(cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("migrations: %v", err)
+		}
+		if res.Applied {
+			log.Printf("migrations: schema migrated from version %d to %d", res.From, res.To)
+		} else {
+			log.Printf("migrations: schema up to date at version %d", res.To)
+		}
+	}
 
-	// Read DB url from env and pass to handler.NewRouter
-	dbURL := getEnv("SUPABASE_URL", "")
-	if dbURL == "" {
-		log.Fatal("SUPABASE_URL environment variable is required")
+	addr := ":" + cfg.Port
+	// one breaker for all outbound HTTP, so a host that is down fails fast for every caller
+	br := breaker.New(cfg.Breaker.Threshold, cfg.Breaker.Cooldown)
+	httpClient := &http.Client{Timeout: cfg.HTTPTimeout, Transport: br.Transport(nil)}
+
+	// Xero calls get their own client so XERO_DEBUG only logs those
+	xeroHTTP := httpClient
+	if cfg.Xero.Debug != config.XeroDebugOff {
+		xeroHTTP = &http.Client{Timeout: cfg.HTTPTimeout, Transport: xeroDebugTransport(cfg, br.Transport(nil))}
+		log.Printf("XERO_DEBUG=%s: logging every Xero request", cfg.Xero.Debug)
+	}
+
+	// Xero endpoints: an in-process fake with demo data, another host, or the real API
+	xeroClient := xero.NewClient(xeroHTTP, cfg.Xero.BaseURL)
+	xeroClient.Concurrency = cfg.Xero.Concurrency
+	switch {
+	case cfg.Xero.DevFake:
+		fake := xerotest.New(xerotest.DevFixtures())
+		defer fake.Close()
+		xeroClient = xero.NewClient(xeroHTTP, fake.URL)
+		xeroClient.Concurrency = cfg.Xero.Concurrency
+		log.Printf("DEV_FAKE_XERO: using fake Xero at %s (demo data, nothing leaves this process)", fake.URL)
+	case cfg.Xero.BaseURL != "":
+		log.Printf("XERO_BASE_URL: using Xero at %s", cfg.Xero.BaseURL)
+	}
+
+	authProvider, sbAuth := buildAuth(cfg.Auth, httpClient)
+	var sbAdmin *supabasetoolbox.Client
+	if u := cfg.Auth.AdminURL(); u != "" {
+		sbAdmin = supabasetoolbox.New(u, cfg.Auth.ServiceRoleKey, httpClient)
 	}
 
 	tpls, err := frontend.BuildTemplates()
 	if err != nil {
 		log.Fatalf("build templates: %v", err)
 	}
-	appRouter := handler.NewRouter(authProvider, httpClient, dbURL, tpls)
+	// part attachments (optional): Supabase Storage with the service-role key
+	var store storage.Store
+	if cfg.Storage.Enabled() {
+		store = storage.NewSupabase(supabasetoolbox.New(cfg.Storage.URL, cfg.Storage.ServiceRoleKey, &http.Client{Timeout: 60 * time.Second, Transport: br.Transport(nil)}), cfg.Storage.Bucket)
+	} else {
+		log.Printf("SUPABASE_STORAGE_URL/SUPABASE_SERVICE_ROLE_KEY not set — part attachments disabled")
+	}
+
+	// order events go to the chat webhooks only; email is for the daily reminders
+	var events *notify.Events
+	if cfg.Notify.Webhooks() && len(cfg.Notify.Events) > 0 {
+		events = notify.NewEvents(buildNotifier(config.NotifyConfig{
+			SlackWebhookURL: cfg.Notify.SlackWebhookURL,
+			TeamsWebhookURL: cfg.Notify.TeamsWebhookURL,
+		}, httpClient), cfg.Notify.Events, cfg.Notify.EventsQuiet)
+	}
+
+	// cached Xero lookups: shared through Redis when configured, else per process
+	if cfg.RedisURL != "" {
+		if c, err := cache.NewRedis(cfg.RedisURL, "xio:"); err != nil {
+			log.Printf("REDIS_URL: %v — lookups cached in memory", err)
+		} else {
+			service.UseCache(c)
+		}
+	}
+
+	// flash cookies must verify on every instance, so without FLASH_SECRET they share
+	// a key kept in the database
+	if cfg.FlashSecret == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if secret, err := service.SharedSecret(ctx, cfg.DatabaseURL, "flash"); err != nil {
+			log.Printf("FLASH_SECRET: shared key unavailable, using a per-process key: %v", err)
+		} else {
+			cfg.FlashSecret = secret
+		}
+		cancel()
+	}
+
+	appRouter := handler.NewRouter(cfg, authProvider, xeroClient, tpls, sbAuth, sbAdmin, store, events)
+
+	// background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	// every instance schedules the jobs; the job_runs claim lets only one of them run
+	claim := jobs.ClaimInDB(cfg.DatabaseURL, cfg.InstanceID)
+	go jobs.Every(jobsCtx, "janitor", time.Hour, jobs.Exclusive("janitor", 30*time.Minute, claim,
+		jobs.Janitor(cfg.DatabaseURL, service.Retention{
+			Archived:     cfg.ArchiveRetention,
+			AuditLog:     cfg.AuditLogRetention,
+			BOMSnapshots: cfg.BOMSnapshotRetention,
+		})))
+	if cfg.Reconcile.Enabled {
+		go jobs.Daily(jobsCtx, "reconcile-purchase-orders", cfg.Reconcile.HourUTC, 0, jobs.Exclusive("reconcile-purchase-orders", 12*time.Hour, claim,
+			jobs.ReconcilePurchaseOrders(cfg.DatabaseURL, xeroClient, cfg.Xero.ClientID, cfg.Xero.ClientSecret, cfg.Reconcile.Lookback)))
+	}
+	if cfg.Reminders.Enabled {
+		rm := cfg.Reminders
+		go jobs.Daily(jobsCtx, "remind-outstanding-purchase-orders", rm.HourUTC, 0, jobs.Exclusive("remind-outstanding-purchase-orders", 12*time.Hour, claim,
+			jobs.RemindOutstandingPurchaseOrders(
+				cfg.DatabaseURL, xeroClient, cfg.Xero.ClientID, cfg.Xero.ClientSecret,
+				service.ReminderPolicy{DefaultDays: rm.DefaultDays, GraceDays: rm.GraceDays, RepeatDays: rm.RepeatDays},
+				buildNotifier(cfg.Notify, httpClient),
+			)))
+	}
+	// owners who hold orders for a weekly release; nothing runs for the others
+	go jobs.Every(jobsCtx, "release-held-orders", 10*time.Minute, jobs.Exclusive("release-held-orders", 5*time.Minute, claim,
+		handler.ReleaseHeldOrders(cfg, xeroClient, events)))
+	// supplier prices dated ahead take effect, and price pushes to Xero are retried
+	go jobs.Every(jobsCtx, "apply-supplier-prices", time.Hour, jobs.Exclusive("apply-supplier-prices", 30*time.Minute, claim,
+		jobs.ApplySupplierPrices(cfg.DatabaseURL, xeroClient, cfg.Xero.ClientID, cfg.Xero.ClientSecret)))
+	if ip := cfg.InvoicePoll; ip.Enabled {
+		go jobs.Every(jobsCtx, "poll-invoices", ip.Interval, jobs.Exclusive("poll-invoices", ip.Interval/2, claim,
+			jobs.PollInvoices(cfg.DatabaseURL, xeroClient, cfg.Xero.ClientID, cfg.Xero.ClientSecret, ip.Lookback)))
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	// forwarded client addresses are only believed from TRUSTED_PROXIES
+	r.Use(mid.RealIP(cfg.TrustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	if cfg.CompressMinSize >= 0 {
+		r.Use(mid.Compress(cfg.CompressMinSize))
+	}
 	r.Use(middleware.Timeout(30 * time.Second))
 
 	// Serve embedded static files at /static/*
@@ -76,9 +200,78 @@ func main() {
 	}
 }
 
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// xeroDebugTransport logs each Xero call with the request id and workspace, and with
+// XERO_DEBUG=db also stores it in api_call_log.
+func xeroDebugTransport(cfg *config.Config, base http.RoundTripper) http.RoundTripper {
+	dt := &xero.DebugTransport{
+		Base: base,
+		Attrs: func(ctx context.Context) []slog.Attr {
+			owner, _ := ctx.Value(mid.CtxWorkspaceID).(string)
+			return []slog.Attr{slog.String("request_id", middleware.GetReqID(ctx)), slog.String("owner_id", owner)}
+		},
+	}
+	if cfg.Xero.Debug == config.XeroDebugDB {
+		dt.Sink = func(ctx context.Context, call xero.APICall) {
+			owner, _ := ctx.Value(mid.CtxWorkspaceID).(string)
+			// record even when the caller's context is already done
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			if err := service.RecordAPICall(ctx, cfg.DatabaseURL, owner, middleware.GetReqID(ctx), call); err != nil {
+				log.Printf("XERO_DEBUG: %v", err)
+			}
+		}
+	}
+	return dt
+}
+
+// buildAuth selects the Supabase auth client from the auth mode.
+// Tokens are verified locally with the HS256 JWT secret, or with keys from the
+// JWKS URL (RS256/ES256) when that is set.
+//   - public (default): login uses NEXT_PUBLIC_SUPABASE_URL + NEXT_PUBLIC_SUPABASE_ANON_KEY.
+//   - server: login uses SUPABASE_AUTH_URL + SUPABASE_SERVICE_ROLE_KEY, so no anon key
+//     or NEXT_PUBLIC_* names are needed. With VerifyRemote every token is additionally
+//     checked against the GoTrue user endpoint.
+func buildAuth(ac config.AuthConfig, httpClient *http.Client) (auth.Authenticator, *supabasetoolbox.Client) {
+	local := auth.NewJWT(ac.JWTSecret, ac.JWTIssuer, ac.JWTAudience)
+	hasLocal := ac.JWTSecret != ""
+	if ac.JWKSURL != "" {
+		local = auth.NewJWKS(ac.JWKSURL, ac.JWTIssuer, ac.JWTAudience, httpClient, 0)
+		hasLocal = true
+	}
+
+	if ac.Mode != config.AuthModeServer {
+		return local, supabasetoolbox.New(ac.PublicURL, ac.AnonKey, httpClient)
+	}
+
+	sb := supabasetoolbox.New(ac.ServerURL, ac.ServiceRoleKey, httpClient)
+	if ac.VerifyRemote {
+		// local signature check first (when configured), then confirm with GoTrue
+		var first auth.Authenticator
+		if hasLocal {
+			first = local
+		}
+		return auth.NewGoTrue(first, sb.URL, sb.APIKey, httpClient, ac.RemoteCacheTTL), sb
+	}
+	return local, sb
+}
+
+// buildNotifier sends to every configured notification channel.
+func buildNotifier(cfg config.NotifyConfig, httpClient *http.Client) notify.Notifier {
+	var n notify.Multi
+	if cfg.SlackWebhookURL != "" {
+		n = append(n, notify.NewSlack(cfg.SlackWebhookURL, httpClient))
+	}
+	if cfg.TeamsWebhookURL != "" {
+		n = append(n, notify.NewTeams(cfg.TeamsWebhookURL, httpClient))
+	}
+	if cfg.SMTPAddr != "" {
+		n = append(n, notify.NewEmail(notify.EmailConfig{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.EmailFrom,
+			To:       cfg.EmailTo,
+		}))
 	}
-	return fallback
+	return n
 }
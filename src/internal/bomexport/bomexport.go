@@ -0,0 +1,138 @@
+// Package bomexport writes a flattened, resolved BOM as CSV or XLSX for planners
+// who work in spreadsheets.
+package bomexport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats supported by Write.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Header is the column order shared by both formats.
+var Header = []string{"Level", "Item code", "Name", "Qty per assembly", "Effective qty", "Supplier", "Unit cost"}
+
+const sheetName = "BOM"
+
+// ContentType returns the MIME type for format.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Write encodes rows in format ("csv" or "xlsx") to w.
+func Write(w io.Writer, format string, rows []service.BOMExportRow) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, rows)
+	case FormatXLSX:
+		return WriteXLSX(w, rows)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// WriteCSV writes a header line then one record per row. Unit cost is blank when unknown.
+func WriteCSV(w io.Writer, rows []service.BOMExportRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Header); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		cost := ""
+		if r.UnitCost > 0 {
+			cost = strconv.FormatFloat(r.UnitCost, 'f', 2, 64)
+		}
+		if err := cw.Write([]string{
+			strconv.Itoa(r.Level),
+			r.PartID,
+			r.Name,
+			strconv.FormatFloat(r.PerAssemblyQty, 'f', -1, 64),
+			strconv.FormatFloat(r.EffectiveQty, 'f', -1, 64),
+			r.Supplier,
+			cost,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteXLSX writes a single "BOM" sheet with a bold, frozen, filterable header row and
+// numeric quantity/cost cells.
+func WriteXLSX(w io.Writer, rows []service.BOMExportRow) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), sheetName); err != nil {
+		return err
+	}
+	header := make([]any, len(Header))
+	for i, h := range Header {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(sheetName, "A1", &header); err != nil {
+		return err
+	}
+	for i, r := range rows {
+		var cost any
+		if r.UnitCost > 0 {
+			cost = r.UnitCost
+		}
+		cellRef, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := f.SetSheetRow(sheetName, cellRef, &[]any{
+			r.Level, r.PartID, r.Name, r.PerAssemblyQty, r.EffectiveQty, r.Supplier, cost,
+		}); err != nil {
+			return err
+		}
+	}
+
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	lastCol, err := excelize.ColumnNumberToName(len(Header))
+	if err != nil {
+		return err
+	}
+	if err := f.SetCellStyle(sheetName, "A1", lastCol+"1", bold); err != nil {
+		return err
+	}
+	money, err := f.NewStyle(&excelize.Style{NumFmt: 2}) // 0.00
+	if err != nil {
+		return err
+	}
+	lastRow := len(rows) + 1
+	if lastRow > 1 {
+		if err := f.SetCellStyle(sheetName, "G2", fmt.Sprintf("G%d", lastRow), money); err != nil {
+			return err
+		}
+	}
+	if err := f.SetPanes(sheetName, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	if err := f.AutoFilter(sheetName, fmt.Sprintf("A1:%s%d", lastCol, lastRow), nil); err != nil {
+		return err
+	}
+	for col, width := range map[string]float64{"B": 18, "C": 40, "D": 16, "E": 14, "F": 28} {
+		if err := f.SetColWidth(sheetName, col, col, width); err != nil {
+			return err
+		}
+	}
+	return f.Write(w)
+}
@@ -0,0 +1,66 @@
+package bomexport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/xuri/excelize/v2"
+)
+
+var sampleRows = []service.BOMExportRow{
+	{Level: 0, PartID: "VAN-001", Name: "Van", PerAssemblyQty: 2, EffectiveQty: 2, UnitCost: 1500},
+	{Level: 1, PartID: "BOLT", Name: "Bolt, M8", PerAssemblyQty: 0.5, EffectiveQty: 1, Supplier: "Fasteners Ltd"},
+}
+
+func TestWriteCSV(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatCSV, sampleRows); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	want := [][]string{
+		Header,
+		{"0", "VAN-001", "Van", "2", "2", "", "1500.00"},
+		{"1", "BOLT", "Bolt, M8", "0.5", "1", "Fasteners Ltd", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatXLSX, sampleRows); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatalf("open xlsx: %v", err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		t.Fatalf("GetRows: %v", err)
+	}
+	if len(rows) != 3 || !reflect.DeepEqual(rows[0], Header) {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+	if rows[2][1] != "BOLT" || rows[2][3] != "0.5" || rows[2][5] != "Fasteners Ltd" {
+		t.Fatalf("unexpected data row: %v", rows[2])
+	}
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	t.Parallel()
+	if err := Write(&bytes.Buffer{}, "ods", sampleRows); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
@@ -0,0 +1,561 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/notify"
+)
+
+// Xero request logging (XERO_DEBUG).
+const (
+	XeroDebugOff = ""
+	XeroDebugLog = "log" // log every Xero call
+	XeroDebugDB  = "db"  // log and store every Xero call in api_call_log
+)
+
+// Auth modes (SUPABASE_AUTH_MODE).
+const (
+	AuthModePublic = "public"
+	AuthModeServer = "server"
+)
+
+// Config is the web app configuration, loaded and validated once at startup.
+type Config struct {
+	Port        string
+	DatabaseURL string        // SUPABASE_URL (Postgres connection string)
+	ReplicaURL  string        // SUPABASE_REPLICA_URL: read-only replica for reports; "" reads from DatabaseURL
+	HTTPTimeout time.Duration // outbound HTTP client timeout
+
+	// CompressMinSize is the smallest text response (HTML, JSON, CSV) sent gzipped,
+	// in bytes (COMPRESS_MIN_SIZE); -1 turns compression off
+	CompressMinSize int
+
+	// RunMigrations applies pending embedded migrations at startup (RUN_MIGRATIONS)
+	RunMigrations bool
+
+	// InstanceID names this process in logs and scheduled job claims (INSTANCE_ID);
+	// defaults to hostname-pid
+	InstanceID string
+
+	// FlashSecret signs flash message cookies (FLASH_SECRET); empty uses a key
+	// generated once and stored in the database, shared by every instance
+	FlashSecret string
+
+	// RedisURL shares cached Xero lookups and BOM snapshot lists, and by default the
+	// sign-in counts, between app instances (REDIS_URL); empty keeps them in memory
+	RedisURL string
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and X-Real-IP
+	// headers name the client (TRUSTED_PROXIES, comma separated CIDRs or addresses).
+	// Requests from any other peer are keyed on the peer's own address, so clients
+	// cannot pick the address the sign-in and public rate limits count them under.
+	TrustedProxies []netip.Prefix
+
+	// ArchiveRetention is how long archived shopping list rows and supplier mappings
+	// are kept before the janitor deletes them (ARCHIVE_RETENTION; 0 keeps them)
+	ArchiveRetention time.Duration
+	// AuditLogRetention is how long audit_log rows are kept (AUDIT_LOG_RETENTION; 0
+	// keeps them)
+	AuditLogRetention time.Duration
+	// BOMSnapshotRetention is how long BOM snapshots are kept (BOM_SNAPSHOT_RETENTION;
+	// 0 keeps them)
+	BOMSnapshotRetention time.Duration
+
+	Auth      AuthConfig
+	Xero      XeroConfig
+	Storage   StorageConfig
+	Reconcile ReconcileConfig
+	Breaker   BreakerConfig
+	Notify    NotifyConfig
+	Reminders ReminderConfig
+	// InvoicePoll configures the background job that pre-resolves new invoices
+	InvoicePoll InvoicePollConfig
+	// XeroWebhooks configures the receiver Xero posts invoice changes to
+	XeroWebhooks XeroWebhookConfig
+
+	LoginLimit  LoginLimitConfig
+	PublicLimit PublicLimitConfig
+}
+
+// AuthConfig selects how Supabase tokens are issued and verified.
+type AuthConfig struct {
+	Mode        string // AuthModePublic or AuthModeServer
+	JWTSecret   string
+	JWTIssuer   string
+	JWTAudience string
+	JWKSURL     string
+
+	// public mode
+	PublicURL string
+	AnonKey   string
+
+	// server mode
+	ServerURL      string
+	ServiceRoleKey string
+	VerifyRemote   bool
+	RemoteCacheTTL time.Duration
+}
+
+// AdminURL is the Supabase project the user admin API is called on: the auth URL of
+// the configured mode. The admin API needs ServiceRoleKey; "" when it is not set.
+// /register creates users through it too, so public sign-ups must be off in the
+// project: with them on, the anon key alone makes accounts without an invite code.
+func (a AuthConfig) AdminURL() string {
+	if a.ServiceRoleKey == "" {
+		return ""
+	}
+	if a.Mode == AuthModeServer {
+		return a.ServerURL
+	}
+	return a.PublicURL
+}
+
+// XeroConfig holds the Xero OAuth app credentials.
+type XeroConfig struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	StateTTL     time.Duration // lifetime of an OAuth state value
+
+	// BaseURL sends all Xero calls to another host (XERO_BASE_URL), e.g. a fake server
+	BaseURL string
+	// DevFake runs an in-process fake Xero seeded with demo data (DEV_FAKE_XERO); the
+	// app credentials are then optional
+	DevFake bool
+	// Debug logs each Xero request and response (XERO_DEBUG): XeroDebugOff, XeroDebugLog
+	// or XeroDebugDB
+	Debug string
+	// Concurrency is how many requests a batched item or contact lookup sends at once
+	// (XERO_CONCURRENCY, 1-5; Xero allows 5 concurrent calls per organisation)
+	Concurrency int
+}
+
+// StorageConfig configures part attachment storage. Disabled when URL is empty.
+type StorageConfig struct {
+	URL            string
+	Bucket         string
+	ServiceRoleKey string
+}
+
+// Enabled reports whether attachment storage is configured.
+func (s StorageConfig) Enabled() bool { return s.URL != "" && s.ServiceRoleKey != "" }
+
+// ReconcileConfig configures the nightly purchase order reconciliation job.
+type ReconcileConfig struct {
+	Enabled  bool
+	HourUTC  int
+	Lookback time.Duration
+}
+
+// InvoicePollConfig configures the job that polls Xero for newly authorised sales
+// invoices and resolves their BOMs ahead of time.
+type InvoicePollConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	// Lookback is how far back the first poll of a connection looks.
+	Lookback time.Duration
+}
+
+// XeroWebhookConfig configures the Xero webhook receiver (POST /xero/webhooks), which
+// polls a tenant's invoices as soon as Xero reports one changed. Disabled when Key is
+// empty.
+type XeroWebhookConfig struct {
+	Key string // XERO_WEBHOOK_KEY, the webhooks key of the Xero app
+	// PreviousKey is accepted as well while the key is rotated
+	// (XERO_WEBHOOK_KEY_PREVIOUS)
+	PreviousKey string
+	// MaxAge is how old an event may be and still be acted on (XERO_WEBHOOK_MAX_AGE);
+	// handled events are remembered for as long, so repeats are ignored
+	MaxAge time.Duration
+}
+
+// Enabled reports whether webhook deliveries are accepted.
+func (w XeroWebhookConfig) Enabled() bool { return w.Key != "" }
+
+// Keys are the keys a delivery's signature is checked against.
+func (w XeroWebhookConfig) Keys() []string { return []string{w.Key, w.PreviousKey} }
+
+// NotifyConfig configures where operational notifications go: Slack or Teams
+// incoming webhooks, email over SMTP, or any mix. Disabled when none is set.
+type NotifyConfig struct {
+	SlackWebhookURL string // NOTIFY_SLACK_WEBHOOK_URL
+	TeamsWebhookURL string // NOTIFY_TEAMS_WEBHOOK_URL
+
+	SMTPAddr     string   // NOTIFY_SMTP_ADDR, host:port
+	SMTPUsername string   // NOTIFY_SMTP_USERNAME; empty sends without auth
+	SMTPPassword string   // NOTIFY_SMTP_PASSWORD
+	EmailFrom    string   // NOTIFY_EMAIL_FROM
+	EmailTo      []string // NOTIFY_EMAIL_TO, comma separated
+
+	// Events are the order events posted to the webhooks (NOTIFY_EVENTS, comma
+	// separated notify.Event* kinds; all by default, "none" for none)
+	Events []string
+	// EventsQuiet drops repeats of the same event within this period (NOTIFY_EVENTS_QUIET)
+	EventsQuiet time.Duration
+}
+
+// Enabled reports whether any notification channel is configured.
+func (n NotifyConfig) Enabled() bool { return n.Webhooks() || n.SMTPAddr != "" }
+
+// Webhooks reports whether a Slack or Teams webhook is configured.
+func (n NotifyConfig) Webhooks() bool { return n.SlackWebhookURL != "" || n.TeamsWebhookURL != "" }
+
+// ReminderConfig configures the daily outstanding purchase order reminder. A PO is
+// outstanding once it has gone unbilled in Xero for its supplier's lead time plus
+// GraceDays, or DefaultDays when the lead time is not recorded.
+type ReminderConfig struct {
+	Enabled     bool
+	HourUTC     int
+	DefaultDays int
+	GraceDays   int
+	RepeatDays  int // days between reminders for the same PO; 0 reminds once
+}
+
+// BreakerConfig configures the per-host circuit breaker on outbound HTTP (Xero, Supabase).
+type BreakerConfig struct {
+	Threshold int           // consecutive failures that open a host's circuit; 0 disables
+	Cooldown  time.Duration // how long an open circuit fails fast before a trial request
+}
+
+// LoginLimitConfig throttles sign-in attempts against password guessing.
+type LoginLimitConfig struct {
+	PerIP    int           // attempts per client IP per Window; 0 disables
+	PerEmail int           // failed attempts per email per Window before it is locked; 0 disables
+	Window   time.Duration // also how long a locked email stays locked
+	// RedisURL shares the counts between app instances (redis:// or rediss://);
+	// defaults to REDIS_URL, and empty keeps them in process memory
+	RedisURL string
+}
+
+// PublicLimitConfig throttles requests to the public sign-in routes per client IP,
+// and to the Xero callback per signed-in user.
+type PublicLimitConfig struct {
+	PerIP  int // requests per client IP (or user) per Window to each route; 0 disables
+	Window time.Duration
+	// RedisURL shares the counts between app instances; the same as the sign-in
+	// limits'
+	RedisURL string
+}
+
+// Error lists every missing or invalid variable so they can be fixed in one go.
+type Error struct {
+	Missing []string
+	Invalid []string
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing required environment variables: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Invalid) > 0 {
+		parts = append(parts, "invalid environment variables: "+strings.Join(e.Invalid, "; "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Load reads the configuration from the process environment.
+func Load() (*Config, error) {
+	return FromEnv(os.Getenv)
+}
+
+// defaultInstanceID is hostname-pid, unique per container or process on a host.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// FromEnv reads the configuration using getenv and validates it.
+func FromEnv(getenv func(string) string) (*Config, error) {
+	r := &reader{getenv: getenv, err: &Error{}}
+
+	cfg := &Config{
+		Port:        r.str("PORT", "8080"),
+		DatabaseURL: r.required("SUPABASE_URL"),
+		ReplicaURL:  r.str("SUPABASE_REPLICA_URL", ""),
+		HTTPTimeout: r.duration("HTTP_TIMEOUT", 10*time.Second),
+
+		CompressMinSize: r.integer("COMPRESS_MIN_SIZE", 1024, -1, 10<<20),
+
+		RunMigrations: r.boolean("RUN_MIGRATIONS", false),
+		InstanceID:    r.str("INSTANCE_ID", defaultInstanceID()),
+		FlashSecret:   r.str("FLASH_SECRET", ""),
+		RedisURL:      r.str("REDIS_URL", ""),
+	}
+	if u := cfg.RedisURL; u != "" && !strings.HasPrefix(u, "redis://") && !strings.HasPrefix(u, "rediss://") {
+		r.invalid("REDIS_URL", "<redacted>", "want a redis:// or rediss:// URL")
+	}
+	for _, v := range r.list("TRUSTED_PROXIES") {
+		p, err := parseProxy(v)
+		if err != nil {
+			r.invalid("TRUSTED_PROXIES", v, "want a CIDR such as 10.0.0.0/8 or an IP address")
+			continue
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, p)
+	}
+	if r.str("ARCHIVE_RETENTION", "") != "0" {
+		cfg.ArchiveRetention = r.duration("ARCHIVE_RETENTION", 90*24*time.Hour)
+	}
+	if r.str("AUDIT_LOG_RETENTION", "") != "0" {
+		cfg.AuditLogRetention = r.duration("AUDIT_LOG_RETENTION", 365*24*time.Hour)
+	}
+	if r.str("BOM_SNAPSHOT_RETENTION", "") != "0" {
+		cfg.BOMSnapshotRetention = r.duration("BOM_SNAPSHOT_RETENTION", 180*24*time.Hour)
+	}
+
+	a := &cfg.Auth
+	a.Mode = strings.ToLower(r.str("SUPABASE_AUTH_MODE", AuthModePublic))
+	a.JWTSecret = r.str("SUPABASE_JWT_SECRET", "")
+	a.JWTIssuer = r.str("SUPABASE_JWT_ISSUER", "")
+	a.JWTAudience = r.str("SUPABASE_JWT_AUDIENCE", "")
+	a.JWKSURL = r.str("SUPABASE_JWKS_URL", "")
+	a.ServiceRoleKey = r.str("SUPABASE_SERVICE_ROLE_KEY", "")
+	a.VerifyRemote = r.boolean("SUPABASE_AUTH_VERIFY_REMOTE", false)
+	a.RemoteCacheTTL = r.duration("SUPABASE_AUTH_CACHE_TTL", 30*time.Second)
+	switch a.Mode {
+	case AuthModePublic:
+		a.PublicURL = r.required("NEXT_PUBLIC_SUPABASE_URL")
+		a.AnonKey = r.required("NEXT_PUBLIC_SUPABASE_ANON_KEY")
+	case AuthModeServer:
+		a.ServerURL = r.required("SUPABASE_AUTH_URL")
+		a.ServiceRoleKey = r.required("SUPABASE_SERVICE_ROLE_KEY")
+	default:
+		r.invalid("SUPABASE_AUTH_MODE", a.Mode, "want public or server")
+	}
+	// tokens must be verifiable somehow: locally, or remotely in server mode
+	if a.JWTSecret == "" && a.JWKSURL == "" && !(a.Mode == AuthModeServer && a.VerifyRemote) {
+		r.err.Missing = append(r.err.Missing, "SUPABASE_JWT_SECRET or SUPABASE_JWKS_URL")
+	}
+
+	cfg.Xero = XeroConfig{
+		RedirectURL: r.str("REDIRECT", "http://localhost:8080/xero/callback"),
+		StateTTL:    r.duration("XERO_OAUTH_STATE_TTL", 5*time.Minute),
+		BaseURL:     r.str("XERO_BASE_URL", ""),
+		DevFake:     r.boolean("DEV_FAKE_XERO", false),
+		Concurrency: r.integer("XERO_CONCURRENCY", 4, 1, 5),
+	}
+	switch v := strings.ToLower(r.str("XERO_DEBUG", "")); v {
+	case "", "0", "false", "no":
+	case "1", "true", "yes", XeroDebugLog:
+		cfg.Xero.Debug = XeroDebugLog
+	case XeroDebugDB:
+		cfg.Xero.Debug = XeroDebugDB
+	default:
+		r.invalid("XERO_DEBUG", v, "want 1, log or db")
+	}
+	if cfg.Xero.DevFake {
+		cfg.Xero.ClientID = r.str("XERO_CLIENT_ID", "dev-client")
+		cfg.Xero.ClientSecret = r.str("XERO_CLIENT_SECRET", "dev-secret")
+		if cfg.Xero.BaseURL != "" {
+			r.invalid("XERO_BASE_URL", cfg.Xero.BaseURL, "not used with DEV_FAKE_XERO")
+		}
+	} else {
+		cfg.Xero.ClientID = r.required("XERO_CLIENT_ID")
+		cfg.Xero.ClientSecret = r.required("XERO_CLIENT_SECRET")
+	}
+	if u := cfg.Xero.BaseURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		r.invalid("XERO_BASE_URL", u, "want an http(s) URL")
+	}
+
+	cfg.Storage = StorageConfig{
+		URL:            r.str("SUPABASE_STORAGE_URL", ""),
+		Bucket:         r.str("SUPABASE_STORAGE_BUCKET", "part-attachments"),
+		ServiceRoleKey: a.ServiceRoleKey,
+	}
+
+	cfg.Reconcile = ReconcileConfig{
+		Enabled:  r.boolean("RECONCILE_PURCHASE_ORDERS", true),
+		HourUTC:  r.integer("RECONCILE_HOUR_UTC", 2, 0, 23),
+		Lookback: r.duration("RECONCILE_LOOKBACK", 30*24*time.Hour),
+	}
+
+	cfg.InvoicePoll = InvoicePollConfig{
+		Enabled:  r.boolean("POLL_INVOICES", true),
+		Interval: r.duration("POLL_INVOICES_INTERVAL", 15*time.Minute),
+		Lookback: r.duration("POLL_INVOICES_LOOKBACK", 7*24*time.Hour),
+	}
+
+	cfg.XeroWebhooks = XeroWebhookConfig{
+		Key:         r.str("XERO_WEBHOOK_KEY", ""),
+		PreviousKey: r.str("XERO_WEBHOOK_KEY_PREVIOUS", ""),
+		MaxAge:      r.duration("XERO_WEBHOOK_MAX_AGE", time.Hour),
+	}
+	if wh := cfg.XeroWebhooks; wh.PreviousKey != "" && wh.Key == "" {
+		r.invalid("XERO_WEBHOOK_KEY_PREVIOUS", "<redacted>", "needs XERO_WEBHOOK_KEY")
+	}
+
+	cfg.Breaker = BreakerConfig{
+		Threshold: r.integer("CIRCUIT_BREAKER_THRESHOLD", 5, 0, 1000),
+		Cooldown:  r.duration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second),
+	}
+
+	cfg.Notify = NotifyConfig{
+		SlackWebhookURL: r.str("NOTIFY_SLACK_WEBHOOK_URL", ""),
+		TeamsWebhookURL: r.str("NOTIFY_TEAMS_WEBHOOK_URL", ""),
+		SMTPAddr:        r.str("NOTIFY_SMTP_ADDR", ""),
+		SMTPUsername:    r.str("NOTIFY_SMTP_USERNAME", ""),
+		SMTPPassword:    r.str("NOTIFY_SMTP_PASSWORD", ""),
+		EmailFrom:       r.str("NOTIFY_EMAIL_FROM", ""),
+		EmailTo:         r.list("NOTIFY_EMAIL_TO"),
+		Events:          r.list("NOTIFY_EVENTS"),
+		EventsQuiet:     r.duration("NOTIFY_EVENTS_QUIET", time.Hour),
+	}
+	for _, key := range []string{"NOTIFY_SLACK_WEBHOOK_URL", "NOTIFY_TEAMS_WEBHOOK_URL"} {
+		if u := r.str(key, ""); u != "" && !strings.HasPrefix(u, "https://") {
+			r.invalid(key, "<redacted>", "want an https URL")
+		}
+	}
+	switch ev := cfg.Notify.Events; {
+	case len(ev) == 0:
+		cfg.Notify.Events = notify.EventKinds
+	case len(ev) == 1 && strings.EqualFold(ev[0], "none"):
+		cfg.Notify.Events = nil
+	default:
+		for _, k := range ev {
+			if !slices.Contains(notify.EventKinds, k) {
+				r.invalid("NOTIFY_EVENTS", k, "want "+strings.Join(notify.EventKinds, ", ")+" or none")
+			}
+		}
+	}
+	if n := cfg.Notify; n.SMTPAddr != "" {
+		if !strings.Contains(n.SMTPAddr, ":") {
+			r.invalid("NOTIFY_SMTP_ADDR", n.SMTPAddr, "want host:port")
+		}
+		if n.EmailFrom == "" {
+			r.err.Missing = append(r.err.Missing, "NOTIFY_EMAIL_FROM")
+		}
+		if len(n.EmailTo) == 0 {
+			r.err.Missing = append(r.err.Missing, "NOTIFY_EMAIL_TO")
+		}
+	}
+
+	cfg.Reminders = ReminderConfig{
+		Enabled:     r.boolean("PO_REMINDERS", cfg.Notify.Enabled()),
+		HourUTC:     r.integer("PO_REMINDER_HOUR_UTC", 7, 0, 23),
+		DefaultDays: r.integer("PO_REMINDER_DAYS", 14, 1, 365),
+		GraceDays:   r.integer("PO_REMINDER_GRACE_DAYS", 3, 0, 365),
+		RepeatDays:  r.integer("PO_REMINDER_REPEAT_DAYS", 7, 0, 365),
+	}
+	if cfg.Reminders.Enabled && !cfg.Notify.Enabled() {
+		r.invalid("PO_REMINDERS", "true", "needs a NOTIFY_*_WEBHOOK_URL or NOTIFY_SMTP_ADDR")
+	}
+
+	cfg.LoginLimit = LoginLimitConfig{
+		PerIP:    r.integer("LOGIN_MAX_ATTEMPTS_PER_IP", 20, 0, 100000),
+		PerEmail: r.integer("LOGIN_MAX_FAILURES_PER_EMAIL", 5, 0, 1000),
+		Window:   r.duration("LOGIN_LOCKOUT_WINDOW", 15*time.Minute),
+		RedisURL: r.str("RATE_LIMIT_REDIS_URL", cfg.RedisURL),
+	}
+	if u := cfg.LoginLimit.RedisURL; u != "" && u != cfg.RedisURL && !strings.HasPrefix(u, "redis://") && !strings.HasPrefix(u, "rediss://") {
+		r.invalid("RATE_LIMIT_REDIS_URL", "<redacted>", "want a redis:// or rediss:// URL")
+	}
+	cfg.PublicLimit = PublicLimitConfig{
+		PerIP:    r.integer("PUBLIC_RATE_LIMIT_PER_IP", 60, 0, 100000),
+		Window:   r.duration("PUBLIC_RATE_LIMIT_WINDOW", time.Minute),
+		RedisURL: cfg.LoginLimit.RedisURL,
+	}
+
+	if len(r.err.Missing) > 0 || len(r.err.Invalid) > 0 {
+		return nil, r.err
+	}
+	return cfg, nil
+}
+
+// parseProxy reads one TRUSTED_PROXIES entry: a CIDR, or an address standing for
+// itself alone.
+func parseProxy(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		p, err := netip.ParsePrefix(v)
+		return p.Masked(), err
+	}
+	a, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()), nil
+}
+
+// reader accumulates problems instead of failing on the first one.
+type reader struct {
+	getenv func(string) string
+	err    *Error
+}
+
+func (r *reader) str(key, def string) string {
+	if v := strings.TrimSpace(r.getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func (r *reader) required(key string) string {
+	v := r.str(key, "")
+	if v == "" {
+		r.err.Missing = append(r.err.Missing, key)
+	}
+	return v
+}
+
+// list splits a comma separated value, dropping empty entries.
+func (r *reader) list(key string) []string {
+	var out []string
+	for _, v := range strings.Split(r.str(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (r *reader) invalid(key, val, why string) {
+	r.err.Invalid = append(r.err.Invalid, fmt.Sprintf("%s=%q (%s)", key, val, why))
+}
+
+func (r *reader) boolean(key string, def bool) bool {
+	v := r.str(key, "")
+	switch strings.ToLower(v) {
+	case "":
+		return def
+	case "1", "true", "yes":
+		return true
+	case "0", "false", "no":
+		return false
+	}
+	r.invalid(key, v, "want true or false")
+	return def
+}
+
+func (r *reader) integer(key string, def, min, max int) int {
+	v := r.str(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		r.invalid(key, v, fmt.Sprintf("want an integer %d-%d", min, max))
+		return def
+	}
+	return n
+}
+
+func (r *reader) duration(key string, def time.Duration) time.Duration {
+	v := r.str(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		r.invalid(key, v, "want a positive duration like 30s or 720h")
+		return def
+	}
+	return d
+}
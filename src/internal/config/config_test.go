@@ -0,0 +1,361 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func baseEnv() map[string]string {
+	return map[string]string{
+		"SUPABASE_URL":                  "postgres://x",
+		"SUPABASE_JWT_SECRET":           "secret",
+		"NEXT_PUBLIC_SUPABASE_URL":      "https://ref.supabase.co",
+		"NEXT_PUBLIC_SUPABASE_ANON_KEY": "anon",
+		"XERO_CLIENT_ID":                "id",
+		"XERO_CLIENT_SECRET":            "sec",
+	}
+}
+
+func TestFromEnv_Defaults(t *testing.T) {
+	t.Parallel()
+	cfg, err := FromEnv(envFrom(baseEnv()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Port != "8080" || cfg.HTTPTimeout != 10*time.Second || cfg.RunMigrations {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+	if cfg.Auth.Mode != AuthModePublic || cfg.Auth.AnonKey != "anon" {
+		t.Fatalf("unexpected auth: %+v", cfg.Auth)
+	}
+	if !cfg.Reconcile.Enabled || cfg.Reconcile.HourUTC != 2 || cfg.Reconcile.Lookback != 30*24*time.Hour {
+		t.Fatalf("unexpected reconcile: %+v", cfg.Reconcile)
+	}
+	if cfg.InvoicePoll != (InvoicePollConfig{Enabled: true, Interval: 15 * time.Minute, Lookback: 7 * 24 * time.Hour}) {
+		t.Fatalf("unexpected invoice poll: %+v", cfg.InvoicePoll)
+	}
+	if cfg.Breaker.Threshold != 5 || cfg.Breaker.Cooldown != 30*time.Second {
+		t.Fatalf("unexpected breaker: %+v", cfg.Breaker)
+	}
+	if cfg.LoginLimit != (LoginLimitConfig{PerIP: 20, PerEmail: 5, Window: 15 * time.Minute}) {
+		t.Fatalf("unexpected login limit: %+v", cfg.LoginLimit)
+	}
+	if cfg.PublicLimit != (PublicLimitConfig{PerIP: 60, Window: time.Minute}) {
+		t.Fatalf("unexpected public limit: %+v", cfg.PublicLimit)
+	}
+	if len(cfg.Notify.Events) != 3 || cfg.Notify.EventsQuiet != time.Hour {
+		t.Fatalf("unexpected notify events: %+v", cfg.Notify)
+	}
+	if cfg.Notify.Enabled() || cfg.Reminders != (ReminderConfig{HourUTC: 7, DefaultDays: 14, GraceDays: 3, RepeatDays: 7}) {
+		t.Fatalf("unexpected notify/reminders: %+v %+v", cfg.Notify, cfg.Reminders)
+	}
+	if cfg.Xero.RedirectURL != "http://localhost:8080/xero/callback" || cfg.Storage.Enabled() {
+		t.Fatalf("unexpected xero/storage: %+v %+v", cfg.Xero, cfg.Storage)
+	}
+	if cfg.ArchiveRetention != 90*24*time.Hour || cfg.AuditLogRetention != 365*24*time.Hour || cfg.BOMSnapshotRetention != 180*24*time.Hour {
+		t.Fatalf("unexpected retention: %v %v %v", cfg.ArchiveRetention, cfg.AuditLogRetention, cfg.BOMSnapshotRetention)
+	}
+	if cfg.ReplicaURL != "" {
+		t.Fatalf("unexpected replica url: %q", cfg.ReplicaURL)
+	}
+}
+
+func TestFromEnv_Retention(t *testing.T) {
+	t.Parallel()
+	for name, got := range map[string]func(*Config) time.Duration{
+		"ARCHIVE_RETENTION":      func(c *Config) time.Duration { return c.ArchiveRetention },
+		"AUDIT_LOG_RETENTION":    func(c *Config) time.Duration { return c.AuditLogRetention },
+		"BOM_SNAPSHOT_RETENTION": func(c *Config) time.Duration { return c.BOMSnapshotRetention },
+	} {
+		for v, want := range map[string]time.Duration{"0": 0, "720h": 720 * time.Hour} {
+			env := baseEnv()
+			env[name] = v
+			cfg, err := FromEnv(envFrom(env))
+			if err != nil {
+				t.Fatalf("%s=%s: unexpected error: %v", name, v, err)
+			}
+			if got(cfg) != want {
+				t.Fatalf("%s=%s: retention = %v, want %v", name, v, got(cfg), want)
+			}
+		}
+		env := baseEnv()
+		env[name] = "-1h"
+		if _, err := FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Fatalf("expected %s error, got %v", name, err)
+		}
+	}
+}
+
+func TestFromEnv_RedisURL(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	env["REDIS_URL"] = "redis://cache:6379/1"
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RedisURL != "redis://cache:6379/1" || cfg.LoginLimit.RedisURL != cfg.RedisURL {
+		t.Fatalf("redis urls = %q, %q; want the login limits to share REDIS_URL", cfg.RedisURL, cfg.LoginLimit.RedisURL)
+	}
+
+	env["RATE_LIMIT_REDIS_URL"] = "rediss://limits:6380"
+	if cfg, err = FromEnv(envFrom(env)); err != nil || cfg.LoginLimit.RedisURL != "rediss://limits:6380" || cfg.PublicLimit.RedisURL != "rediss://limits:6380" {
+		t.Fatalf("RATE_LIMIT_REDIS_URL override: %v, %v", cfg, err)
+	}
+
+	env = baseEnv()
+	env["REDIS_URL"] = "http://cache"
+	_, err = FromEnv(envFrom(env))
+	if err == nil || !strings.Contains(err.Error(), "REDIS_URL") || strings.Contains(err.Error(), "RATE_LIMIT_REDIS_URL") {
+		t.Fatalf("expected one REDIS_URL error, got %v", err)
+	}
+}
+
+func TestFromEnv_TrustedProxies(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil || len(cfg.TrustedProxies) != 0 {
+		t.Fatalf("default TrustedProxies = %v, %v; want none", cfg, err)
+	}
+	env["TRUSTED_PROXIES"] = "10.1.2.3/8, 192.0.2.7,fd00::/8"
+	cfg, err = FromEnv(envFrom(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(cfg.TrustedProxies); got != "[10.0.0.0/8 192.0.2.7/32 fd00::/8]" {
+		t.Fatalf("TrustedProxies = %s", got)
+	}
+	env["TRUSTED_PROXIES"] = "10.0.0.0/8,proxy.internal"
+	if _, err = FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), `TRUSTED_PROXIES="proxy.internal"`) {
+		t.Fatalf("expected a TRUSTED_PROXIES error, got %v", err)
+	}
+}
+
+func TestFromEnv_CompressMinSize(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil || cfg.CompressMinSize != 1024 {
+		t.Fatalf("default CompressMinSize = %v, %v; want 1024", cfg, err)
+	}
+	env["COMPRESS_MIN_SIZE"] = "-1"
+	if cfg, err = FromEnv(envFrom(env)); err != nil || cfg.CompressMinSize != -1 {
+		t.Fatalf("COMPRESS_MIN_SIZE=-1: %v, %v", cfg, err)
+	}
+	env["COMPRESS_MIN_SIZE"] = "-5"
+	if _, err = FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), "COMPRESS_MIN_SIZE") {
+		t.Fatalf("expected a COMPRESS_MIN_SIZE error, got %v", err)
+	}
+}
+
+func TestFromEnv_InstanceID(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := fmt.Sprintf("-%d", os.Getpid()); !strings.HasSuffix(cfg.InstanceID, want) {
+		t.Fatalf("default InstanceID = %q, want hostname%s", cfg.InstanceID, want)
+	}
+
+	env["INSTANCE_ID"] = "web-2"
+	if cfg, err = FromEnv(envFrom(env)); err != nil || cfg.InstanceID != "web-2" {
+		t.Fatalf("INSTANCE_ID override: %v, %v", cfg, err)
+	}
+}
+
+func TestFromEnv_ListsAllProblems(t *testing.T) {
+	t.Parallel()
+	_, err := FromEnv(envFrom(map[string]string{
+		"RECONCILE_HOUR_UTC": "25",
+		"HTTP_TIMEOUT":       "soon",
+	}))
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	for _, k := range []string{"SUPABASE_URL", "NEXT_PUBLIC_SUPABASE_URL", "NEXT_PUBLIC_SUPABASE_ANON_KEY", "XERO_CLIENT_ID", "XERO_CLIENT_SECRET", "SUPABASE_JWT_SECRET or SUPABASE_JWKS_URL"} {
+		if !strings.Contains(strings.Join(cerr.Missing, ","), k) {
+			t.Fatalf("expected %s in missing list: %v", k, cerr.Missing)
+		}
+	}
+	if len(cerr.Invalid) != 2 {
+		t.Fatalf("expected 2 invalid vars, got %v", cerr.Invalid)
+	}
+}
+
+func TestFromEnv_ServerMode(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	delete(env, "NEXT_PUBLIC_SUPABASE_URL")
+	delete(env, "NEXT_PUBLIC_SUPABASE_ANON_KEY")
+	delete(env, "SUPABASE_JWT_SECRET")
+	env["SUPABASE_AUTH_MODE"] = "server"
+	env["SUPABASE_AUTH_URL"] = "https://ref.supabase.co"
+	env["SUPABASE_SERVICE_ROLE_KEY"] = "svc"
+	env["SUPABASE_AUTH_VERIFY_REMOTE"] = "true"
+	env["SUPABASE_STORAGE_URL"] = "https://ref.supabase.co"
+
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Auth.VerifyRemote || cfg.Auth.ServerURL == "" || !cfg.Storage.Enabled() || cfg.Storage.ServiceRoleKey != "svc" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestFromEnv_XeroOverrides(t *testing.T) {
+	t.Parallel()
+
+	env := baseEnv()
+	delete(env, "XERO_CLIENT_ID")
+	delete(env, "XERO_CLIENT_SECRET")
+	env["DEV_FAKE_XERO"] = "1"
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil {
+		t.Fatalf("dev fake without credentials: %v", err)
+	}
+	if !cfg.Xero.DevFake || cfg.Xero.ClientID == "" || cfg.Xero.ClientSecret == "" {
+		t.Fatalf("unexpected xero: %+v", cfg.Xero)
+	}
+
+	env["XERO_BASE_URL"] = "http://localhost:9000"
+	if _, err := FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), "XERO_BASE_URL") {
+		t.Fatalf("expected XERO_BASE_URL conflict, got %v", err)
+	}
+
+	env = baseEnv()
+	env["XERO_BASE_URL"] = "localhost:9000"
+	if _, err := FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), "XERO_BASE_URL") {
+		t.Fatalf("expected invalid XERO_BASE_URL, got %v", err)
+	}
+	env["XERO_BASE_URL"] = "http://localhost:9000"
+	cfg, err = FromEnv(envFrom(env))
+	if err != nil || cfg.Xero.BaseURL != "http://localhost:9000" || cfg.Xero.DevFake || cfg.Xero.Debug != XeroDebugOff {
+		t.Fatalf("unexpected xero: %+v, %v", cfg, err)
+	}
+
+	for v, want := range map[string]string{"1": XeroDebugLog, "log": XeroDebugLog, "DB": XeroDebugDB, "false": XeroDebugOff} {
+		env["XERO_DEBUG"] = v
+		if cfg, err := FromEnv(envFrom(env)); err != nil || cfg.Xero.Debug != want {
+			t.Fatalf("XERO_DEBUG=%s: got %+v, %v", v, cfg, err)
+		}
+	}
+	env["XERO_DEBUG"] = "verbose"
+	if _, err := FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), "XERO_DEBUG") {
+		t.Fatalf("expected invalid XERO_DEBUG, got %v", err)
+	}
+	delete(env, "XERO_DEBUG")
+
+	if cfg.Xero.Concurrency != 4 {
+		t.Fatalf("XERO_CONCURRENCY default = %d, want 4", cfg.Xero.Concurrency)
+	}
+	env["XERO_CONCURRENCY"] = "2"
+	if cfg, err := FromEnv(envFrom(env)); err != nil || cfg.Xero.Concurrency != 2 {
+		t.Fatalf("XERO_CONCURRENCY=2: got %+v, %v", cfg, err)
+	}
+	env["XERO_CONCURRENCY"] = "6"
+	if _, err := FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), "XERO_CONCURRENCY") {
+		t.Fatalf("expected invalid XERO_CONCURRENCY, got %v", err)
+	}
+}
+
+func TestFromEnv_XeroWebhooks(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil || cfg.XeroWebhooks.Enabled() || cfg.XeroWebhooks.MaxAge != time.Hour {
+		t.Fatalf("unexpected webhook defaults: %+v, %v", cfg, err)
+	}
+
+	env["XERO_WEBHOOK_KEY_PREVIOUS"] = "old"
+	if _, err := FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), "XERO_WEBHOOK_KEY_PREVIOUS") {
+		t.Fatalf("expected previous key without a key to fail, got %v", err)
+	}
+	env["XERO_WEBHOOK_KEY"] = "new"
+	env["XERO_WEBHOOK_MAX_AGE"] = "10m"
+	cfg, err = FromEnv(envFrom(env))
+	if err != nil || !cfg.XeroWebhooks.Enabled() || cfg.XeroWebhooks.MaxAge != 10*time.Minute {
+		t.Fatalf("unexpected webhooks: %+v, %v", cfg.XeroWebhooks, err)
+	}
+	if keys := cfg.XeroWebhooks.Keys(); len(keys) != 2 || keys[0] != "new" || keys[1] != "old" {
+		t.Fatalf("keys = %q", keys)
+	}
+}
+
+func TestFromEnv_Notify(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	env["NOTIFY_SMTP_ADDR"] = "smtp.example.com:587"
+	env["NOTIFY_EMAIL_FROM"] = "orders@example.com"
+	env["NOTIFY_EMAIL_TO"] = "buyer@example.com, , stores@example.com"
+	env["PO_REMINDER_DAYS"] = "10"
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Notify.Enabled() || !cfg.Reminders.Enabled || cfg.Reminders.DefaultDays != 10 {
+		t.Fatalf("unexpected notify/reminders: %+v %+v", cfg.Notify, cfg.Reminders)
+	}
+	if got := strings.Join(cfg.Notify.EmailTo, "|"); got != "buyer@example.com|stores@example.com" {
+		t.Fatalf("EmailTo = %q", got)
+	}
+
+	env = baseEnv()
+	env["NOTIFY_SMTP_ADDR"] = "smtp.example.com"
+	env["PO_REMINDERS"] = "true"
+	_, err = FromEnv(envFrom(env))
+	var cerr *Error
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	msg := cerr.Error()
+	for _, want := range []string{"NOTIFY_SMTP_ADDR", "NOTIFY_EMAIL_FROM", "NOTIFY_EMAIL_TO"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q missing %s", msg, want)
+		}
+	}
+
+	env = baseEnv()
+	env["PO_REMINDERS"] = "true"
+	if _, err := FromEnv(envFrom(env)); err == nil || !strings.Contains(err.Error(), "PO_REMINDERS") {
+		t.Fatalf("reminders without a channel: %v", err)
+	}
+}
+
+func TestFromEnv_NotifyEvents(t *testing.T) {
+	t.Parallel()
+	env := baseEnv()
+	env["NOTIFY_TEAMS_WEBHOOK_URL"] = "https://example.webhook.office.com/x"
+	env["NOTIFY_EVENTS"] = "po_created,connection_broken"
+	cfg, err := FromEnv(envFrom(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Notify.Webhooks() || strings.Join(cfg.Notify.Events, ",") != "po_created,connection_broken" {
+		t.Fatalf("unexpected notify: %+v", cfg.Notify)
+	}
+
+	env["NOTIFY_EVENTS"] = "none"
+	if cfg, err = FromEnv(envFrom(env)); err != nil || cfg.Notify.Events != nil {
+		t.Fatalf("none: %+v, %v", cfg, err)
+	}
+
+	env["NOTIFY_EVENTS"] = "po_created,po_deleted"
+	env["NOTIFY_TEAMS_WEBHOOK_URL"] = "http://example.com/x"
+	_, err = FromEnv(envFrom(env))
+	if err == nil || !strings.Contains(err.Error(), "po_deleted") || !strings.Contains(err.Error(), "NOTIFY_TEAMS_WEBHOOK_URL") {
+		t.Fatalf("expected NOTIFY_EVENTS and NOTIFY_TEAMS_WEBHOOK_URL errors, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package csvimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BOMHeader is the parent_child CSV header; column order may vary.
+var BOMHeader = []string{"parent_code", "child_code", "qty"}
+
+// BOMRow is one parent_child relationship read from the CSV.
+type BOMRow struct {
+	Line     int     `json:"line"` // 1-based, header included
+	Parent   string  `json:"parent_code"`
+	Child    string  `json:"child_code"`
+	Quantity float64 `json:"qty"` // in the child's unit of measure, e.g. 1.5 (metres)
+}
+
+// ParseBOM reads rows from a CSV with a parent_code, child_code, qty header
+// ("quantity" is accepted for qty). Lines with a problem are reported as RowErrors
+// rather than returned as rows.
+func ParseBOM(r io.Reader) ([]BOMRow, []RowError, error) {
+	var rows []BOMRow
+	var bad []RowError
+	seen := map[[2]string]int{} // parent, child -> line
+	rowErrs, err := readRecords(r, BOMHeader, map[string]string{"quantity": "qty"}, func(rec record) {
+		row := BOMRow{Line: rec.line, Parent: rec.field("parent_code"), Child: rec.field("child_code")}
+		var msgs []string
+		if row.Parent == "" {
+			msgs = append(msgs, "parent_code is empty")
+		}
+		if row.Child == "" {
+			msgs = append(msgs, "child_code is empty")
+		}
+		if row.Parent != "" && row.Parent == row.Child {
+			msgs = append(msgs, "an item cannot contain itself")
+		}
+		q, err := strconv.ParseFloat(rec.field("qty"), 64)
+		if err != nil || !(q > 0) || math.IsInf(q, 0) || math.Abs(q*1e4-math.Round(q*1e4)) > 1e-6 {
+			msgs = append(msgs, fmt.Sprintf("qty %q must be a positive number with at most 4 decimal places", rec.field("qty")))
+		}
+		row.Quantity = q
+		if first, dup := seen[[2]string{row.Parent, row.Child}]; dup && len(msgs) == 0 {
+			msgs = append(msgs, fmt.Sprintf("%s > %s is already on line %d", row.Parent, row.Child, first))
+		}
+		if len(msgs) > 0 {
+			bad = append(bad, RowError{Line: rec.line, Message: strings.Join(msgs, "; ")})
+			return
+		}
+		seen[[2]string{row.Parent, row.Child}] = rec.line
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	rowErrs = append(rowErrs, bad...)
+	SortErrors(rowErrs)
+	return rows, rowErrs, nil
+}
+
+// BOMCodes returns every item code the rows use, sorted.
+func BOMCodes(rows []BOMRow) []string {
+	set := map[string]bool{}
+	for _, r := range rows {
+		set[r.Parent] = true
+		set[r.Child] = true
+	}
+	return sortedKeys(set)
+}
+
+// CheckBOMCodes reports rows that use a code not in known (e.g. the codes found in Xero).
+func CheckBOMCodes(rows []BOMRow, known map[string]bool) []RowError {
+	var out []RowError
+	for _, r := range rows {
+		var unknown []string
+		for _, c := range []string{r.Parent, r.Child} {
+			if !known[c] {
+				unknown = append(unknown, c)
+			}
+		}
+		if len(unknown) > 0 {
+			out = append(out, RowError{Line: r.Line, Message: "unknown item code " + strings.Join(unknown, ", ")})
+		}
+	}
+	return out
+}
+
+// UpsertBOM inserts the rows into the workspace's parent_child, updating the quantity
+// of existing relationships. Run it in a transaction so a failure leaves parent_child
+// untouched.
+func UpsertBOM(ctx context.Context, q Querier, workspaceID string, rows []BOMRow) (Result, error) {
+	var res Result
+	for _, r := range rows {
+		var inserted bool
+		err := q.QueryRow(ctx, `
+INSERT INTO parent_child (workspace_id, parent_id, child_id, quantity) VALUES ($1, $2, $3, $4)
+ON CONFLICT (workspace_id, parent_id, child_id) DO UPDATE SET quantity = EXCLUDED.quantity
+WHERE parent_child.quantity IS DISTINCT FROM EXCLUDED.quantity
+RETURNING (xmax = 0)
+`, workspaceID, r.Parent, r.Child, r.Quantity).Scan(&inserted)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			res.Unchanged++
+		case err != nil:
+			return Result{}, fmt.Errorf("upsert %s > %s (line %d): %w", r.Parent, r.Child, r.Line, err)
+		case inserted:
+			res.Inserted++
+		default:
+			res.Updated++
+		}
+	}
+	return res, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
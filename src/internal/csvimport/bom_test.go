@@ -0,0 +1,81 @@
+package csvimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBOM(t *testing.T) {
+	t.Parallel()
+	in := "\ufeffChild_Code, Parent_Code ,Quantity\n" +
+		"BOLT,FRAME,4\n" +
+		"\n" +
+		"NUT,FRAME,x\n" +
+		",FRAME,1\n" +
+		"FRAME,FRAME,1\n" +
+		"BOLT,FRAME,2\n" +
+		"FRAME,CHASSIS,1\n" +
+		"CABLE,FRAME,1.0001\n" +
+		"WIRE,FRAME,0.00001\n"
+	rows, errs, err := ParseBOM(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRows := []BOMRow{
+		{Line: 2, Parent: "FRAME", Child: "BOLT", Quantity: 4},
+		{Line: 8, Parent: "CHASSIS", Child: "FRAME", Quantity: 1},
+		{Line: 9, Parent: "FRAME", Child: "CABLE", Quantity: 1.0001},
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Fatalf("rows = %+v, want %+v", rows, wantRows)
+	}
+	wantErrs := []RowError{
+		{Line: 4, Message: `qty "x" must be a positive number with at most 4 decimal places`},
+		{Line: 5, Message: "child_code is empty"},
+		{Line: 6, Message: "an item cannot contain itself"},
+		{Line: 7, Message: "FRAME > BOLT is already on line 2"},
+		{Line: 10, Message: `qty "0.00001" must be a positive number with at most 4 decimal places`},
+	}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Fatalf("errors = %+v, want %+v", errs, wantErrs)
+	}
+	if got := BOMCodes(rows); !reflect.DeepEqual(got, []string{"BOLT", "CABLE", "CHASSIS", "FRAME"}) {
+		t.Fatalf("codes = %v", got)
+	}
+}
+
+func TestParseBOM_BadFile(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{
+		"":                             "empty",
+		"parent,child,qty\nA,B,1\n":    "header must have columns",
+		"parent_code,child_code,qty\n": "no rows",
+	} {
+		if _, _, err := ParseBOM(strings.NewReader(in)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: err = %v, want %q", in, err, want)
+		}
+	}
+}
+
+func TestCheckBOMCodes(t *testing.T) {
+	t.Parallel()
+	rows := []BOMRow{
+		{Line: 2, Parent: "FRAME", Child: "BOLT", Quantity: 4},
+		{Line: 3, Parent: "FRAME", Child: "NUT", Quantity: 4},
+		{Line: 4, Parent: "GADGET", Child: "WIDGET", Quantity: 1},
+	}
+	errs := CheckBOMCodes(rows, map[string]bool{"FRAME": true, "BOLT": true})
+	want := []RowError{
+		{Line: 3, Message: "unknown item code NUT"},
+		{Line: 4, Message: "unknown item code GADGET, WIDGET"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("errors = %+v, want %+v", errs, want)
+	}
+	all := append([]RowError{{Line: 9, Message: "late"}}, errs...)
+	SortErrors(all)
+	if all[0].Line != 3 || all[2].Line != 9 {
+		t.Fatalf("not sorted: %+v", all)
+	}
+}
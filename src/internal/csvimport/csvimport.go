@@ -0,0 +1,120 @@
+// Package csvimport reads bulk data from CSV, reports problems per line, and upserts
+// it in one transaction: parent_child relationships (ParseBOM, UpsertBOM),
+// item-to-supplier mappings in items_contacts (ParseSupplierMappings,
+// UpsertSupplierMappings) and supplier price lists (ParsePriceList,
+// UpsertSupplierPrices). The web app's upload pages and the control-panel import
+// commands share it.
+package csvimport
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RowError is a problem with one line of the CSV.
+type RowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string { return fmt.Sprintf("line %d: %s", e.Line, e.Message) }
+
+// Result counts what an upsert changed.
+type Result struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// SortErrors orders errors by line.
+func SortErrors(errs []RowError) {
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+}
+
+// Querier runs a query returning at most one row; pgx.Tx satisfies it.
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// record is one non-empty CSV line; field returns a column by header name, trimmed.
+type record struct {
+	line  int
+	field func(name string) string
+}
+
+// readRecords reads a CSV whose header has every column in header (case, surrounding
+// spaces and a UTF-8 BOM ignored; aliases maps other accepted names to header names),
+// calling fn for each line that has a value. Malformed lines are returned as
+// RowErrors; the error is for an unreadable file or header, or no data lines.
+func readRecords(r io.Reader, header []string, aliases map[string]string, fn func(record)) ([]RowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	head, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range head {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if a, ok := aliases[h]; ok {
+			h = a
+		}
+		col[h] = i
+	}
+	for _, h := range header {
+		if _, ok := col[h]; !ok {
+			return nil, fmt.Errorf("header must have columns %s", strings.Join(header, ", "))
+		}
+	}
+
+	var rowErrs []RowError
+	lines := 0
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				rowErrs = append(rowErrs, RowError{Line: perr.Line, Message: perr.Err.Error()})
+				lines++
+				continue
+			}
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		empty := true
+		for _, h := range header {
+			if field(h) != "" {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		lines++
+		fn(record{line: line, field: field})
+	}
+	if lines == 0 {
+		return nil, fmt.Errorf("no rows after the header")
+	}
+	return rowErrs, nil
+}
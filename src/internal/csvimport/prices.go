@@ -0,0 +1,134 @@
+package csvimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PriceHeader is the supplier price list CSV header; column order may vary. An
+// effective_from column (YYYY-MM-DD) is optional: lines without one take effect on
+// the day they are imported.
+var PriceHeader = []string{"item_code", "account_number", "unit_price"}
+
+// PriceRow is an item's price from a supplier, the Xero contact with that
+// AccountNumber, from EffectiveFrom on ("" = the import date).
+type PriceRow struct {
+	Line          int     `json:"line"` // 1-based, header included
+	ItemCode      string  `json:"item_code"`
+	AccountNumber string  `json:"account_number"`
+	UnitPrice     float64 `json:"unit_price"`
+	EffectiveFrom string  `json:"effective_from,omitempty"`
+}
+
+// ParsePriceList reads rows from a CSV with an item_code, account_number, unit_price
+// header ("code", "supplier", "price" and "effective_date" are accepted too). Lines
+// with a problem are reported as RowErrors rather than returned as rows.
+func ParsePriceList(r io.Reader) ([]PriceRow, []RowError, error) {
+	var rows []PriceRow
+	var bad []RowError
+	seen := map[[3]string]int{} // item, account, date -> line
+	aliases := map[string]string{"code": "item_code", "supplier": "account_number", "price": "unit_price", "effective_date": "effective_from"}
+	rowErrs, err := readRecords(r, PriceHeader, aliases, func(rec record) {
+		row := PriceRow{Line: rec.line, ItemCode: rec.field("item_code"), AccountNumber: rec.field("account_number"), EffectiveFrom: rec.field("effective_from")}
+		var msgs []string
+		if row.ItemCode == "" {
+			msgs = append(msgs, "item_code is empty")
+		}
+		if row.AccountNumber == "" {
+			msgs = append(msgs, "account_number is empty")
+		}
+		p, err := strconv.ParseFloat(rec.field("unit_price"), 64)
+		if err != nil || !(p >= 0) || math.IsInf(p, 0) || p >= 1e8 || math.Abs(p*1e4-math.Round(p*1e4)) > 1e-6 {
+			msgs = append(msgs, fmt.Sprintf("unit_price %q must be a number of at least 0 with at most 4 decimal places", rec.field("unit_price")))
+		}
+		row.UnitPrice = p
+		if row.EffectiveFrom != "" {
+			if _, err := time.Parse(time.DateOnly, row.EffectiveFrom); err != nil {
+				msgs = append(msgs, fmt.Sprintf("effective_from %q must be a date like 2025-07-01", row.EffectiveFrom))
+			}
+		}
+		key := [3]string{row.ItemCode, row.AccountNumber, row.EffectiveFrom}
+		if first, dup := seen[key]; dup && len(msgs) == 0 {
+			msgs = append(msgs, fmt.Sprintf("%s from %s is already on line %d", row.ItemCode, row.AccountNumber, first))
+		}
+		if len(msgs) > 0 {
+			bad = append(bad, RowError{Line: rec.line, Message: strings.Join(msgs, "; ")})
+			return
+		}
+		seen[key] = rec.line
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	rowErrs = append(rowErrs, bad...)
+	SortErrors(rowErrs)
+	return rows, rowErrs, nil
+}
+
+// PriceItemCodes returns every item code the rows use, sorted.
+func PriceItemCodes(rows []PriceRow) []string {
+	set := map[string]bool{}
+	for _, r := range rows {
+		set[r.ItemCode] = true
+	}
+	return sortedKeys(set)
+}
+
+// CheckPriceList reports rows for an item and supplier not in mapped (item code,
+// AccountNumber pairs, e.g. the workspace's live items_contacts).
+func CheckPriceList(rows []PriceRow, mapped map[[2]string]bool) []RowError {
+	var out []RowError
+	for _, r := range rows {
+		if !mapped[[2]string{r.ItemCode, r.AccountNumber}] {
+			out = append(out, RowError{Line: r.Line, Message: fmt.Sprintf("%s is not bought from %s; import the supplier mapping first", r.ItemCode, r.AccountNumber)})
+		}
+	}
+	return out
+}
+
+// PriceSource describes one price list upload.
+type PriceSource struct {
+	Filename   string
+	ImportedBy string
+	PushToXero bool // also update the items' Xero purchase prices
+}
+
+// UpsertSupplierPrices adds the rows to the workspace's supplier_prices. A price
+// already listed for the same item, supplier and date is replaced, and applied again
+// when it changed. Run it in a transaction so a failure leaves supplier_prices
+// untouched.
+func UpsertSupplierPrices(ctx context.Context, q Querier, workspaceID string, src PriceSource, rows []PriceRow) (Result, error) {
+	var res Result
+	for _, r := range rows {
+		var inserted bool
+		err := q.QueryRow(ctx, `
+INSERT INTO supplier_prices (workspace_id, item_id, contact_id, effective_from, unit_price, source, imported_by, push_to_xero)
+VALUES ($1, $2, $3, COALESCE(NULLIF($4, '')::date, current_date), $5, $6, $7, $8)
+ON CONFLICT (workspace_id, item_id, contact_id, effective_from) DO UPDATE
+SET unit_price = EXCLUDED.unit_price, source = EXCLUDED.source, imported_by = EXCLUDED.imported_by,
+    imported_at = EXCLUDED.imported_at, push_to_xero = EXCLUDED.push_to_xero, applied_at = NULL, pushed_at = NULL
+WHERE supplier_prices.unit_price IS DISTINCT FROM EXCLUDED.unit_price OR supplier_prices.push_to_xero <> EXCLUDED.push_to_xero
+RETURNING (xmax = 0)
+`, workspaceID, r.ItemCode, r.AccountNumber, r.EffectiveFrom, r.UnitPrice, src.Filename, src.ImportedBy, src.PushToXero).Scan(&inserted)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			res.Unchanged++
+		case err != nil:
+			return Result{}, fmt.Errorf("upsert price of %s from %s (line %d): %w", r.ItemCode, r.AccountNumber, r.Line, err)
+		case inserted:
+			res.Inserted++
+		default:
+			res.Updated++
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package csvimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePriceList(t *testing.T) {
+	t.Parallel()
+	in := "Code,Supplier,Price,Effective_From\n" +
+		"BOLT,SUP-1,0.25,\n" +
+		"BOLT,SUP-1,0.3,2025-07-01\n" +
+		"BOLT,SUP-1,0.26,\n" +
+		"NUT,SUP-1,free,\n" +
+		"NUT,SUP-1,-1,\n" +
+		"NUT,SUP-1,0.12345,\n" +
+		"NUT,SUP-2,0.05,1 July\n" +
+		",SUP-2,0.05,\n" +
+		"NUT,SUP-2,0,2025-07-01\n"
+	rows, errs, err := ParsePriceList(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRows := []PriceRow{
+		{Line: 2, ItemCode: "BOLT", AccountNumber: "SUP-1", UnitPrice: 0.25},
+		{Line: 3, ItemCode: "BOLT", AccountNumber: "SUP-1", UnitPrice: 0.3, EffectiveFrom: "2025-07-01"},
+		{Line: 10, ItemCode: "NUT", AccountNumber: "SUP-2", EffectiveFrom: "2025-07-01"},
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Fatalf("rows = %+v, want %+v", rows, wantRows)
+	}
+	wantErrs := []RowError{
+		{Line: 4, Message: "BOLT from SUP-1 is already on line 2"},
+		{Line: 5, Message: `unit_price "free" must be a number of at least 0 with at most 4 decimal places`},
+		{Line: 6, Message: `unit_price "-1" must be a number of at least 0 with at most 4 decimal places`},
+		{Line: 7, Message: `unit_price "0.12345" must be a number of at least 0 with at most 4 decimal places`},
+		{Line: 8, Message: `effective_from "1 July" must be a date like 2025-07-01`},
+		{Line: 9, Message: "item_code is empty"},
+	}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Fatalf("errors = %+v, want %+v", errs, wantErrs)
+	}
+
+	mapped := map[[2]string]bool{{"BOLT", "SUP-1"}: true}
+	want := []RowError{{Line: 10, Message: "NUT is not bought from SUP-2; import the supplier mapping first"}}
+	if got := CheckPriceList(rows, mapped); !reflect.DeepEqual(got, want) {
+		t.Fatalf("check = %+v, want %+v", got, want)
+	}
+	if got := PriceItemCodes(rows); !reflect.DeepEqual(got, []string{"BOLT", "NUT"}) {
+		t.Fatalf("item codes = %v", got)
+	}
+}
+
+func TestParsePriceList_Header(t *testing.T) {
+	t.Parallel()
+	if _, _, err := ParsePriceList(strings.NewReader("item_code,account_number\nBOLT,SUP-1\n")); err == nil || !strings.Contains(err.Error(), "unit_price") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package csvimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SupplierHeader is the items_contacts CSV header; column order may vary.
+var SupplierHeader = []string{"item_code", "account_number"}
+
+// SupplierRow maps an item to a supplier, the Xero contact with that AccountNumber.
+type SupplierRow struct {
+	Line          int    `json:"line"` // 1-based, header included
+	ItemCode      string `json:"item_code"`
+	AccountNumber string `json:"account_number"`
+}
+
+// ParseSupplierMappings reads rows from a CSV with an item_code, account_number
+// header ("code" and "supplier" are accepted too). Lines with a problem are reported
+// as RowErrors rather than returned as rows.
+func ParseSupplierMappings(r io.Reader) ([]SupplierRow, []RowError, error) {
+	var rows []SupplierRow
+	var bad []RowError
+	seen := map[[2]string]int{} // item, account -> line
+	aliases := map[string]string{"code": "item_code", "supplier": "account_number"}
+	rowErrs, err := readRecords(r, SupplierHeader, aliases, func(rec record) {
+		row := SupplierRow{Line: rec.line, ItemCode: rec.field("item_code"), AccountNumber: rec.field("account_number")}
+		var msgs []string
+		if row.ItemCode == "" {
+			msgs = append(msgs, "item_code is empty")
+		}
+		if row.AccountNumber == "" {
+			msgs = append(msgs, "account_number is empty")
+		}
+		if first, dup := seen[[2]string{row.ItemCode, row.AccountNumber}]; dup && len(msgs) == 0 {
+			msgs = append(msgs, fmt.Sprintf("%s from %s is already on line %d", row.ItemCode, row.AccountNumber, first))
+		}
+		if len(msgs) > 0 {
+			bad = append(bad, RowError{Line: rec.line, Message: strings.Join(msgs, "; ")})
+			return
+		}
+		seen[[2]string{row.ItemCode, row.AccountNumber}] = rec.line
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	rowErrs = append(rowErrs, bad...)
+	SortErrors(rowErrs)
+	return rows, rowErrs, nil
+}
+
+// SupplierItemCodes returns every item code the rows use, sorted.
+func SupplierItemCodes(rows []SupplierRow) []string {
+	set := map[string]bool{}
+	for _, r := range rows {
+		set[r.ItemCode] = true
+	}
+	return sortedKeys(set)
+}
+
+// SupplierAccounts returns every AccountNumber the rows use, sorted.
+func SupplierAccounts(rows []SupplierRow) []string {
+	set := map[string]bool{}
+	for _, r := range rows {
+		set[r.AccountNumber] = true
+	}
+	return sortedKeys(set)
+}
+
+// CheckSupplierMappings reports rows whose item code is not in knownItems or whose
+// AccountNumber is not in knownAccounts (e.g. what was found in Xero).
+func CheckSupplierMappings(rows []SupplierRow, knownItems, knownAccounts map[string]bool) []RowError {
+	var out []RowError
+	for _, r := range rows {
+		var msgs []string
+		if !knownItems[r.ItemCode] {
+			msgs = append(msgs, "unknown item code "+r.ItemCode)
+		}
+		if !knownAccounts[r.AccountNumber] {
+			msgs = append(msgs, "no Xero contact with account number "+r.AccountNumber)
+		}
+		if len(msgs) > 0 {
+			out = append(out, RowError{Line: r.Line, Message: strings.Join(msgs, "; ")})
+		}
+	}
+	return out
+}
+
+// UpsertSupplierMappings adds the rows to the workspace's items_contacts. Archived
+// mappings are restored and count as updated; live ones are unchanged. Existing
+// mappings keep their ordering terms. Run it in a transaction so a failure leaves
+// items_contacts untouched.
+func UpsertSupplierMappings(ctx context.Context, q Querier, workspaceID string, rows []SupplierRow) (Result, error) {
+	var res Result
+	for _, r := range rows {
+		var inserted bool
+		err := q.QueryRow(ctx, `
+INSERT INTO items_contacts (workspace_id, item_id, contact_id) VALUES ($1, $2, $3)
+ON CONFLICT (workspace_id, item_id, contact_id) DO UPDATE SET archived_at = NULL
+WHERE items_contacts.archived_at IS NOT NULL
+RETURNING (xmax = 0)
+`, workspaceID, r.ItemCode, r.AccountNumber).Scan(&inserted)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			res.Unchanged++
+		case err != nil:
+			return Result{}, fmt.Errorf("insert %s from %s (line %d): %w", r.ItemCode, r.AccountNumber, r.Line, err)
+		case inserted:
+			res.Inserted++
+		default:
+			res.Updated++
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,61 @@
+package csvimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSupplierMappings(t *testing.T) {
+	t.Parallel()
+	in := "Supplier,Code\n" +
+		"SUP-1,BOLT\n" +
+		"SUP-2,BOLT\n" +
+		",NUT\n" +
+		"SUP-1,BOLT\n" +
+		"SUP-1,\n" +
+		"SUP-1,NUT\n"
+	rows, errs, err := ParseSupplierMappings(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRows := []SupplierRow{
+		{Line: 2, ItemCode: "BOLT", AccountNumber: "SUP-1"},
+		{Line: 3, ItemCode: "BOLT", AccountNumber: "SUP-2"},
+		{Line: 7, ItemCode: "NUT", AccountNumber: "SUP-1"},
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Fatalf("rows = %+v, want %+v", rows, wantRows)
+	}
+	wantErrs := []RowError{
+		{Line: 4, Message: "account_number is empty"},
+		{Line: 5, Message: "BOLT from SUP-1 is already on line 2"},
+		{Line: 6, Message: "item_code is empty"},
+	}
+	if !reflect.DeepEqual(errs, wantErrs) {
+		t.Fatalf("errors = %+v, want %+v", errs, wantErrs)
+	}
+	if got := SupplierItemCodes(rows); !reflect.DeepEqual(got, []string{"BOLT", "NUT"}) {
+		t.Fatalf("item codes = %v", got)
+	}
+	if got := SupplierAccounts(rows); !reflect.DeepEqual(got, []string{"SUP-1", "SUP-2"}) {
+		t.Fatalf("accounts = %v", got)
+	}
+}
+
+func TestCheckSupplierMappings(t *testing.T) {
+	t.Parallel()
+	rows := []SupplierRow{
+		{Line: 2, ItemCode: "BOLT", AccountNumber: "SUP-1"},
+		{Line: 3, ItemCode: "WIDGET", AccountNumber: "SUP-1"},
+		{Line: 4, ItemCode: "GADGET", AccountNumber: "SUP-9"},
+	}
+	errs := CheckSupplierMappings(rows, map[string]bool{"BOLT": true}, map[string]bool{"SUP-1": true})
+	want := []RowError{
+		{Line: 3, Message: "unknown item code WIDGET"},
+		{Line: 4, Message: "unknown item code GADGET; no Xero contact with account number SUP-9"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("errors = %+v, want %+v", errs, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Package dbmigrate applies the embedded SQL migrations with golang-migrate.
+package dbmigrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5" // registers pgx5://
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/hwalton/xero-invoice-orderer/migrations"
+)
+
+// Result reports the schema version before and after Up.
+type Result struct {
+	From    uint
+	To      uint
+	Applied bool // false when already up to date
+}
+
+// Up applies all pending migrations from the embedded FS to dbURL.
+func Up(dbURL string) (Result, error) {
+	return up(migrations.FS, dbURL)
+}
+
+func up(fsys fs.FS, dbURL string) (Result, error) {
+	if dbURL == "" {
+		return Result{}, fmt.Errorf("db url missing")
+	}
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return Result{}, fmt.Errorf("open migrations: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, driverURL(dbURL))
+	if err != nil {
+		return Result{}, fmt.Errorf("init migrate: %w", err)
+	}
+	defer m.Close()
+
+	var res Result
+	from, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return res, fmt.Errorf("read schema version: %w", err)
+	}
+	if dirty {
+		return res, fmt.Errorf("schema version %d is dirty; fix it manually before migrating", from)
+	}
+	res.From, res.To = from, from
+
+	if err := m.Up(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			return res, nil
+		}
+		return res, fmt.Errorf("migrate up: %w", err)
+	}
+	to, _, err := m.Version()
+	if err != nil {
+		return res, fmt.Errorf("read schema version: %w", err)
+	}
+	res.To, res.Applied = to, true
+	return res, nil
+}
+
+// driverURL rewrites a postgres:// connection string to the pgx5:// scheme the
+// golang-migrate pgx driver registers.
+func driverURL(dbURL string) string {
+	for _, prefix := range []string{"postgres://", "postgresql://"} {
+		if strings.HasPrefix(dbURL, prefix) {
+			return "pgx5://" + strings.TrimPrefix(dbURL, prefix)
+		}
+	}
+	return dbURL
+}
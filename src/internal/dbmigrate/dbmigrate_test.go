@@ -0,0 +1,57 @@
+package dbmigrate
+
+import (
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/hwalton/xero-invoice-orderer/migrations"
+)
+
+func TestDriverURL(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"postgres://u:p@h:5432/db?sslmode=require": "pgx5://u:p@h:5432/db?sslmode=require",
+		"postgresql://u@h/db":                      "pgx5://u@h/db",
+		"pgx5://u@h/db":                            "pgx5://u@h/db",
+	}
+	for in, want := range cases {
+		if got := driverURL(in); got != want {
+			t.Fatalf("driverURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEmbeddedMigrationsParse(t *testing.T) {
+	t.Parallel()
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		t.Fatalf("open embedded migrations: %v", err)
+	}
+	defer src.Close()
+	v, err := src.First()
+	if err != nil || v != 1 {
+		t.Fatalf("expected first version 1, got %d (%v)", v, err)
+	}
+	n := 1
+	for {
+		next, err := src.Next(v)
+		if err != nil {
+			break
+		}
+		if next != v+1 {
+			t.Fatalf("gap in migration versions after %d: next is %d", v, next)
+		}
+		v = next
+		n++
+	}
+	if n < 9 {
+		t.Fatalf("expected at least 9 migrations, found %d", n)
+	}
+}
+
+func TestUp_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := Up(""); err == nil {
+		t.Fatal("expected error for empty db url")
+	}
+}
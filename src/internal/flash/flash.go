@@ -0,0 +1,170 @@
+// Package flash carries one-shot user messages across a redirect in a signed cookie.
+// Handlers Add messages before redirecting; the page that renders them Pops them,
+// which also clears the cookie.
+package flash
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/utils"
+)
+
+// Level is the severity of a message.
+type Level string
+
+const (
+	Info  Level = "info"
+	Warn  Level = "warn"
+	Error Level = "error"
+)
+
+const (
+	cookieName = "flash"
+	ttl        = 5 * time.Minute
+
+	// keep the cookie well under the ~4KB browser limit
+	maxMessages = 5
+	maxTextLen  = 400
+	maxLinks    = 8
+)
+
+// Message is one flash message, optionally followed by links (e.g. to the documents
+// it mentions).
+type Message struct {
+	Level Level  `json:"l"`
+	Text  string `json:"t"`
+	Links []Link `json:"k,omitempty"`
+}
+
+// Link is a link shown with a message.
+type Link struct {
+	Text string `json:"t"`
+	URL  string `json:"u"`
+}
+
+// Class returns the text colour class used to render the message.
+func (m Message) Class() string {
+	switch m.Level {
+	case Error:
+		return "text-red-700"
+	case Warn:
+		return "text-amber-700"
+	default:
+		return "text-gray-700"
+	}
+}
+
+// Store signs and verifies flash cookies with an HMAC key.
+type Store struct {
+	key []byte
+}
+
+// New returns a Store keyed by secret. An empty secret uses a random per-process key,
+// so messages pending across a restart are dropped.
+func New(secret string) *Store {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic("flash: read random key: " + err.Error())
+		}
+	}
+	return &Store{key: key}
+}
+
+// Add queues a message, with any links, for the next page render. Messages already
+// pending for the request, or added earlier in this response, are kept; the oldest
+// are dropped past the limit. Links past the first few are dropped too.
+func (s *Store) Add(w http.ResponseWriter, r *http.Request, level Level, text string, links ...Link) {
+	if rs := []rune(text); len(rs) > maxTextLen {
+		text = string(rs[:maxTextLen]) + "…"
+	}
+	if len(links) > maxLinks {
+		links = links[:maxLinks]
+	}
+	msgs := append(s.pending(w, r), Message{Level: level, Text: text, Links: links})
+	if len(msgs) > maxMessages {
+		msgs = msgs[len(msgs)-maxMessages:]
+	}
+	dropSetCookie(w, cookieName)
+	utils.SetCookie(w, r, cookieName, s.encode(msgs), time.Now().Add(ttl))
+}
+
+// Pop returns the pending messages and clears the cookie. Missing, tampered or
+// malformed cookies yield no messages.
+func (s *Store) Pop(w http.ResponseWriter, r *http.Request) []Message {
+	c, err := r.Cookie(cookieName)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	utils.ClearCookie(w, r, cookieName)
+	return s.decode(c.Value)
+}
+
+// pending returns messages set earlier in this response, else those on the request.
+func (s *Store) pending(w http.ResponseWriter, r *http.Request) []Message {
+	for _, sc := range w.Header().Values("Set-Cookie") {
+		if name, value, ok := strings.Cut(sc, "="); ok && name == cookieName {
+			value, _, _ = strings.Cut(value, ";")
+			return s.decode(value)
+		}
+	}
+	if c, err := r.Cookie(cookieName); err == nil {
+		return s.decode(c.Value)
+	}
+	return nil
+}
+
+func (s *Store) encode(msgs []Message) string {
+	b, _ := json.Marshal(msgs)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+func (s *Store) decode(v string) []Message {
+	payload, sig, ok := strings.Cut(v, ".")
+	if !ok {
+		return nil
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(payload)) {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil
+	}
+	var msgs []Message
+	if err := json.Unmarshal(b, &msgs); err != nil {
+		return nil
+	}
+	return msgs
+}
+
+func (s *Store) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(cookieName + ":" + payload))
+	return mac.Sum(nil)
+}
+
+// dropSetCookie removes a Set-Cookie header for name queued earlier in this response.
+func dropSetCookie(w http.ResponseWriter, name string) {
+	h := w.Header()
+	kept := h.Values("Set-Cookie")[:0:0]
+	for _, sc := range h.Values("Set-Cookie") {
+		if n, _, _ := strings.Cut(sc, "="); n != name {
+			kept = append(kept, sc)
+		}
+	}
+	h.Del("Set-Cookie")
+	for _, sc := range kept {
+		h.Add("Set-Cookie", sc)
+	}
+}
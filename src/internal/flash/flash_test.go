@@ -0,0 +1,93 @@
+package flash
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// carry copies the cookies set on rec into a new request, like a browser following a redirect.
+func carry(rec *httptest.ResponseRecorder) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		if c.MaxAge >= 0 && c.Value != "" {
+			req.AddCookie(c)
+		}
+	}
+	return req
+}
+
+func TestAddPop_RoundTripAndClear(t *testing.T) {
+	t.Parallel()
+	s := New("secret")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	s.Add(rec, req, Info, "3 items added")
+	s.Add(rec, req, Warn, "stock unavailable")
+	if n := len(rec.Header().Values("Set-Cookie")); n != 1 {
+		t.Fatalf("expected a single Set-Cookie, got %d", n)
+	}
+
+	rec2 := httptest.NewRecorder()
+	got := s.Pop(rec2, carry(rec))
+	want := []Message{{Level: Info, Text: "3 items added"}, {Level: Warn, Text: "stock unavailable"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	if sc := rec2.Header().Get("Set-Cookie"); !strings.Contains(sc, "Max-Age=0") {
+		t.Fatalf("expected Pop to clear the cookie, got %q", sc)
+	}
+}
+
+func TestAdd_KeepsPendingAndCaps(t *testing.T) {
+	t.Parallel()
+	s := New("secret")
+
+	rec := httptest.NewRecorder()
+	s.Add(rec, httptest.NewRequest(http.MethodPost, "/", nil), Info, "first")
+	// a second request before the page rendered keeps the first message
+	req := carry(rec)
+	rec = httptest.NewRecorder()
+	for i := 0; i < maxMessages; i++ {
+		s.Add(rec, req, Error, "again")
+	}
+	got := s.Pop(httptest.NewRecorder(), carry(rec))
+	if len(got) != maxMessages || got[0].Text != "again" {
+		t.Fatalf("expected the newest %d messages, got %+v", maxMessages, got)
+	}
+}
+
+func TestAdd_Links(t *testing.T) {
+	t.Parallel()
+	s := New("secret")
+	rec := httptest.NewRecorder()
+	links := make([]Link, maxLinks+2)
+	for i := range links {
+		links[i] = Link{Text: "PO", URL: "https://go.xero.com/po"}
+	}
+	s.Add(rec, httptest.NewRequest(http.MethodPost, "/", nil), Info, "Created POs", links...)
+	got := s.Pop(httptest.NewRecorder(), carry(rec))
+	if len(got) != 1 || len(got[0].Links) != maxLinks || got[0].Links[0].URL != "https://go.xero.com/po" {
+		t.Fatalf("unexpected messages: %+v", got)
+	}
+}
+
+func TestPop_RejectsTamperedOrForeignCookies(t *testing.T) {
+	t.Parallel()
+	rec := httptest.NewRecorder()
+	New("secret").Add(rec, httptest.NewRequest(http.MethodPost, "/", nil), Info, "hello")
+	c := rec.Result().Cookies()[0]
+
+	if got := New("other").Pop(httptest.NewRecorder(), carry(rec)); got != nil {
+		t.Fatalf("expected messages signed with another key to be rejected, got %+v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: c.Name, Value: "x" + c.Value})
+	if got := New("secret").Pop(httptest.NewRecorder(), req); got != nil {
+		t.Fatalf("expected tampered cookie to be rejected, got %+v", got)
+	}
+}
@@ -0,0 +1,129 @@
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/locale"
+	"github.com/hwalton/xero-invoice-orderer/internal/uom"
+)
+
+// indentStep is how far each BOM level is indented.
+const indentStep = 1.5 // rem
+
+// Funcs returns the helpers available to every template, so handlers pass raw
+// numbers and times rather than pre-formatted strings. Those marked [loc] take the
+// page's locale.Locale as an optional last argument ({{ money .Total $.Currency
+// $.Locale }}) and otherwise format as locale.Default does:
+//
+//	indent depth            style attribute value indenting a BOM row by its depth
+//	qty v [loc]             a quantity: whole numbers without decimals, else up to 3
+//	units v uom [loc]       a quantity in its unit of measure, e.g. "2.5 m" ("3" for each)
+//	step uom                the step attribute of a quantity input in that unit ("1", "0.01")
+//	money v currency [loc]  two decimals with thousands separators and the currency
+//	                        ("£1,234.50", "1,234.50 SEK"; no currency when "")
+//	percent v               a whole percentage, e.g. "42%"
+//	filesize n              a byte count as "812 B", "14.2 KB" or "3.1 MB"
+//	date v [loc],           epoch seconds (int, int64 or *int64) or a (*)time.Time as
+//	datetime v [loc],       "2 Jan 2006", "2006-01-02 15:04 UTC", "January 2006";
+//	month v [loc]           "" for zero or nil
+//	dict k v ...            a map for passing several values to a nested template
+//	add a b                 a + b
+func Funcs() template.FuncMap {
+	return template.FuncMap{
+		"indent": indent,
+		"qty":    func(v float64, loc ...any) string { return localeOf(loc).Qty(v) },
+		"units":  func(v float64, u string, loc ...any) string { return localeOf(loc).Units(v, u) },
+		"step":   uom.Step,
+		// currency is any so a page without one (a missing map key) still renders
+		"money": func(v float64, currency any, loc ...any) string {
+			code, _ := currency.(string)
+			return localeOf(loc).Money(v, code)
+		},
+		"percent":  formatPercent,
+		"filesize": formatFileSize,
+		"date":     func(v any, loc ...any) string { return localeOf(loc).Date(timeOf(v)) },
+		"datetime": func(v any, loc ...any) string { return localeOf(loc).DateTime(timeOf(v)) },
+		"month":    func(v any, loc ...any) string { return localeOf(loc).Month(timeOf(v)) },
+		"dict":     dict,
+		"add":      func(a, b int) int { return a + b },
+	}
+}
+
+// localeOf is the locale passed as a template function's optional last argument;
+// Default when there is none (or the page's data has no Locale).
+func localeOf(args []any) locale.Locale {
+	if len(args) > 0 {
+		if l, ok := args[len(args)-1].(locale.Locale); ok {
+			return l
+		}
+	}
+	return locale.Default
+}
+
+func indent(depth int) template.CSS {
+	if depth <= 0 {
+		return ""
+	}
+	return template.CSS("padding-left: " + strconv.FormatFloat(float64(depth)*indentStep, 'f', -1, 64) + "rem")
+}
+
+func formatPercent(v float64) string {
+	return strconv.FormatFloat(math.Round(v), 'f', 0, 64) + "%"
+}
+
+func formatFileSize(n int64) string {
+	switch {
+	case n < 1024:
+		return strconv.FormatInt(n, 10) + " B"
+	case n < 1024*1024:
+		return strconv.FormatFloat(float64(n)/1024, 'f', 1, 64) + " KB"
+	default:
+		return strconv.FormatFloat(float64(n)/(1024*1024), 'f', 1, 64) + " MB"
+	}
+}
+
+// timeOf converts the times templates are given; the zero time for anything else.
+func timeOf(v any) time.Time {
+	var t time.Time
+	switch v := v.(type) {
+	case time.Time:
+		t = v
+	case *time.Time:
+		if v != nil {
+			t = *v
+		}
+	case int64:
+		if v != 0 {
+			t = time.Unix(v, 0)
+		}
+	case int:
+		if v != 0 {
+			t = time.Unix(int64(v), 0)
+		}
+	case *int64:
+		if v != nil && *v != 0 {
+			t = time.Unix(*v, 0)
+		}
+	}
+	return t
+}
+
+func dict(kv ...any) (map[string]any, error) {
+	if len(kv)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments")
+	}
+	m := make(map[string]any, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		k, ok := kv[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", kv[i])
+		}
+		m[k] = kv[i+1]
+	}
+	return m, nil
+}
@@ -0,0 +1,82 @@
+package frontend
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/locale"
+)
+
+func TestFormatQtyAndPercent(t *testing.T) {
+	t.Parallel()
+	units := Funcs()["units"].(func(float64, string, ...any) string)
+	if got := units(2.5, "Metres"); got != "2.5 m" {
+		t.Errorf("units = %q", got)
+	}
+	if got := units(2.5, "Metres", locale.For("DE", "EUR")); got != "2,5 m" {
+		t.Errorf("units in Germany = %q", got)
+	}
+	if got := Funcs()["step"].(func(string) string)("kg"); got != "0.001" {
+		t.Errorf("step = %q", got)
+	}
+	if got := formatPercent(41.6); got != "42%" {
+		t.Errorf("formatPercent = %q", got)
+	}
+	for n, want := range map[int64]string{812: "812 B", 14541: "14.2 KB", 3250586: "3.1 MB"} {
+		if got := formatFileSize(n); got != want {
+			t.Errorf("formatFileSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2025, 3, 9, 14, 5, 0, 0, time.UTC)
+	epoch := at.Unix()
+	var zero *int64
+	var never *time.Time
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{epoch, "9 Mar 2025"},
+		{int(epoch), "9 Mar 2025"},
+		{&epoch, "9 Mar 2025"},
+		{time.Unix(epoch, 0), "9 Mar 2025"},
+		{&at, "9 Mar 2025"},
+		{never, ""},
+		{int64(0), ""},
+		{zero, ""},
+		{"2025-03-09", ""},
+	}
+	date := Funcs()["date"].(func(any, ...any) string)
+	for _, tt := range tests {
+		if got := date(tt.v); got != tt.want {
+			t.Errorf("date(%v) = %q, want %q", tt.v, got, tt.want)
+		}
+	}
+	datetime := Funcs()["datetime"].(func(any, ...any) string)
+	if got := datetime(epoch); got != "2025-03-09 14:05 UTC" {
+		t.Errorf("datetime = %q", got)
+	}
+	if got := datetime(epoch, locale.For("NZ", "NZD")); got != "10 Mar 2025 03:05 NZDT" {
+		t.Errorf("datetime in New Zealand = %q", got)
+	}
+}
+
+func TestIndentAndDict(t *testing.T) {
+	t.Parallel()
+	if got := indent(0); got != "" {
+		t.Errorf("indent(0) = %q", got)
+	}
+	if got := indent(2); got != "padding-left: 3rem" {
+		t.Errorf("indent(2) = %q", got)
+	}
+	if _, err := dict("a", 1, "b"); err == nil {
+		t.Error("dict with an odd number of arguments should fail")
+	}
+	if _, err := dict(1, 2); err == nil || !strings.Contains(err.Error(), "not a string") {
+		t.Errorf("dict with a non-string key: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Progress bars for long form posts. A form with data-progress="<element id>" sends a
+// fresh progress_id with each submit, and the element shows the server-sent events
+// from /progress/<progress_id> until the response arrives (htmx) or the next page
+// loads. The element holds a [data-progress-bar] whose width follows done/total and
+// a [data-progress-message] for the step being worked on.
+(function () {
+  function newID() {
+    if (window.crypto && crypto.randomUUID) {
+      return crypto.randomUUID();
+    }
+    return Date.now().toString(36) + Math.random().toString(36).slice(2);
+  }
+
+  function show(el, p) {
+    var bar = el.querySelector('[data-progress-bar]');
+    var message = el.querySelector('[data-progress-message]');
+    if (bar) {
+      bar.style.width = p.total > 0 ? Math.round((100 * p.done) / p.total) + '%' : '0%';
+    }
+    if (message) {
+      message.textContent = p.message || '';
+    }
+  }
+
+  document.addEventListener('submit', function (e) {
+    var form = e.target;
+    var el = form.dataset && form.dataset.progress && document.getElementById(form.dataset.progress);
+    // a button posting somewhere else (formaction) is not the long request
+    if (!el || !window.EventSource || (e.submitter && e.submitter.hasAttribute('formaction'))) {
+      return;
+    }
+    var id = newID();
+    var input = form.querySelector('input[name="progress_id"]');
+    if (!input) {
+      input = document.createElement('input');
+      input.type = 'hidden';
+      input.name = 'progress_id';
+      form.appendChild(input);
+    }
+    input.value = id;
+
+    if (form._progress) {
+      form._progress.close();
+    }
+    var source = new EventSource('/progress/' + encodeURIComponent(id));
+    form._progress = source;
+    show(el, { done: 0, total: 0, message: '' });
+    el.classList.remove('hidden');
+    var stop = function () {
+      source.close();
+      el.classList.add('hidden');
+      form.removeEventListener('htmx:afterRequest', stop);
+    };
+    source.addEventListener('progress', function (ev) {
+      var p = JSON.parse(ev.data);
+      show(el, p);
+      if (p.finished) {
+        source.close();
+      }
+    });
+    form.addEventListener('htmx:afterRequest', stop);
+  }, true);
+})();
@@ -12,9 +12,7 @@ var TemplatesFS embed.FS
 // BuildTemplates parses partials and pages and returns a *template.Template.
 // Call this once at startup and pass the result into your Handler.
 func BuildTemplates() (*template.Template, error) {
-	t := template.New("app").Funcs(template.FuncMap{
-		// add helpers here if needed, e.g. URL building, formatters
-	})
+	t := template.New("app").Funcs(Funcs())
 	// parse partials first so pages can use them
 	if _, err := t.ParseFS(TemplatesFS, "templates/partials/*.html"); err != nil {
 		return nil, err
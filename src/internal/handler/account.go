@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+)
+
+// minPasswordLen is Supabase's default minimum password length.
+const minPasswordLen = 6
+
+// passwordPageHandler shows the change password form; a password reset link lands
+// here after /confirm has signed the user in.
+func (h *Handler) passwordPageHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderPassword(w, r, http.StatusOK, map[string]interface{}{})
+}
+
+// changePasswordHandler sets a new password for the signed-in user.
+func (h *Handler) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	password := r.PostFormValue("password")
+	data := map[string]interface{}{}
+	switch {
+	case len(password) < minPasswordLen:
+		data["Error"] = "Password must be at least 6 characters"
+	case password != r.PostFormValue("password_confirm"):
+		data["Error"] = "Passwords do not match"
+	}
+	if data["Error"] != nil {
+		h.renderPassword(w, r, http.StatusBadRequest, data)
+		return
+	}
+	c, err := r.Cookie("access_token")
+	if err != nil || c.Value == "" {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	if err := h.supabase.UpdatePassword(ctx, c.Value, password); err != nil {
+		log.Printf("change password: %v", err)
+		data["Error"] = "Could not change the password: " + supabaseErrorText(err)
+		h.renderPassword(w, r, http.StatusBadGateway, data)
+		return
+	}
+	h.flash.Add(w, r, flash.Info, "Password changed")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *Handler) renderPassword(w http.ResponseWriter, r *http.Request, status int, data map[string]interface{}) {
+	data["Title"] = "Change password"
+	data["CSRFToken"] = mid.CSRFToken(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = h.templates.ExecuteTemplate(w, "account_password.html", data)
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+	"github.com/hwalton/xero-invoice-orderer/pkg/supabasetoolbox"
+)
+
+// adminRole is the app_metadata.role that opens the /admin pages.
+const adminRole = "admin"
+
+// approverRole is the app_metadata.role that may approve purchase order batches held
+// for approval; admins may too.
+const approverRole = "approver"
+
+// userRoles are the roles an admin can give; "" is an ordinary user.
+var userRoles = []string{"", approverRole, adminRole}
+
+// adminUsersPerPage is how many users the user list shows per page.
+const adminUsersPerPage = 50
+
+// isAdmin reports whether the signed-in user has the admin role.
+func isAdmin(r *http.Request) bool {
+	claims, _ := r.Context().Value(mid.CtxClaims).(map[string]interface{})
+	return mid.AppRole(claims) == adminRole
+}
+
+// canApprove reports whether the signed-in user may approve purchase order batches.
+func canApprove(r *http.Request) bool {
+	claims, _ := r.Context().Value(mid.CtxClaims).(map[string]interface{})
+	role := mid.AppRole(claims)
+	return role == approverRole || role == adminRole
+}
+
+// adminConfigured answers 503 when there is no service-role key to call the admin
+// API with, and reports whether there is.
+func (h *Handler) adminConfigured(w http.ResponseWriter) bool {
+	if h.admin == nil {
+		http.Error(w, "user admin not configured (needs SUPABASE_SERVICE_ROLE_KEY)", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// supabaseErrorText is the user-facing text for a failed Supabase call.
+func supabaseErrorText(err error) string {
+	if errors.Is(err, breaker.ErrOpen) {
+		return unavailableMessage("Supabase")
+	}
+	var ae *supabasetoolbox.AuthError
+	if errors.As(err, &ae) && ae.Message() != "" {
+		return ae.Message()
+	}
+	return err.Error()
+}
+
+// adminUsersHandler lists the Supabase project's users with their role and whether
+// they are disabled, a page (?page=N) at a time.
+func (h *Handler) adminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.adminConfigured(w) {
+		return
+	}
+	page := 1
+	if v, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && v > 1 {
+		page = v
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	users, err := h.admin.ListUsers(ctx, page, adminUsersPerPage)
+	if err != nil {
+		log.Printf("admin: %v", err)
+		if !h.renderUnavailable(w, r, "Supabase", err) {
+			h.renderError(w, r, http.StatusBadGateway, "failed to list users: "+supabaseErrorText(err), err)
+		}
+		return
+	}
+	self, _ := r.Context().Value(mid.CtxUserID).(string)
+	data := map[string]interface{}{
+		"Title":     "Users",
+		"Users":     users,
+		"Roles":     userRoles,
+		"Self":      self,
+		"Now":       time.Now(),
+		"Page":      page,
+		"NextPage":  0,
+		"Flash":     h.flash.Pop(w, r),
+		"CSRFToken": mid.CSRFToken(r),
+	}
+	if len(users) == adminUsersPerPage {
+		data["NextPage"] = page + 1
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "admin_users.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// adminUserRedirect flashes msg and goes back to the user list.
+func (h *Handler) adminUserRedirect(w http.ResponseWriter, r *http.Request, level flash.Level, msg string) {
+	h.flash.Add(w, r, level, msg)
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// adminUserRoleHandler sets a user's role (form field role, one of userRoles). Admins
+// cannot take the role away from themselves, so there is always one left.
+func (h *Handler) adminUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.adminConfigured(w) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	role := r.PostFormValue("role")
+	if !slices.Contains(userRoles, role) {
+		http.Error(w, "unknown role", http.StatusBadRequest)
+		return
+	}
+	if self, _ := r.Context().Value(mid.CtxUserID).(string); id == self && role != adminRole {
+		h.adminUserRedirect(w, r, flash.Error, "You cannot remove your own admin role")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	if err := h.admin.SetUserRole(ctx, id, role); err != nil {
+		log.Printf("admin: %v", err)
+		h.adminUserRedirect(w, r, flash.Error, "Update failed: "+supabaseErrorText(err))
+		return
+	}
+	if role == "" {
+		role = "user"
+	}
+	h.adminUserRedirect(w, r, flash.Info, "Role set to "+role+"; it applies from the user's next sign-in")
+}
+
+// adminUserAccessHandler disables or re-enables a user's sign-in ({action}).
+func (h *Handler) adminUserAccessHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.adminConfigured(w) {
+		return
+	}
+	id := chi.URLParam(r, "id")
+	disable := chi.URLParam(r, "action") == "disable"
+	if self, _ := r.Context().Value(mid.CtxUserID).(string); id == self && disable {
+		h.adminUserRedirect(w, r, flash.Error, "You cannot disable your own account")
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	if err := h.admin.SetUserDisabled(ctx, id, disable); err != nil {
+		log.Printf("admin: %v", err)
+		h.adminUserRedirect(w, r, flash.Error, "Update failed: "+supabaseErrorText(err))
+		return
+	}
+	if disable {
+		h.adminUserRedirect(w, r, flash.Info, "Account disabled; the user is signed out when their session expires")
+		return
+	}
+	h.adminUserRedirect(w, r, flash.Info, "Account enabled")
+}
+
+// adminUserResetHandler emails a user a password reset link that comes back to
+// /confirm and on to the change password page.
+func (h *Handler) adminUserResetHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.adminConfigured(w) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	u, err := h.admin.GetUser(ctx, chi.URLParam(r, "id"))
+	if err == nil && u.Email == "" {
+		err = errors.New("the user has no email address")
+	}
+	if err == nil {
+		err = h.admin.SendPasswordReset(ctx, u.Email, confirmURL(r))
+	}
+	if err != nil {
+		log.Printf("admin: password reset: %v", err)
+		h.adminUserRedirect(w, r, flash.Error, "Password reset failed: "+supabaseErrorText(err))
+		return
+	}
+	h.adminUserRedirect(w, r, flash.Info, "Password reset email sent to "+u.Email)
+}
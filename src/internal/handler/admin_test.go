@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSupabaseAdmin serves the admin user endpoints and password recovery on the
+// harness server, and records what each update and reset sent.
+type fakeSupabaseAdmin struct {
+	mu      sync.Mutex
+	updates map[string]map[string]interface{}
+	resets  []string
+}
+
+func newFakeSupabaseAdmin(hs *harness) *fakeSupabaseAdmin {
+	f := &fakeSupabaseAdmin{updates: map[string]map[string]interface{}{}}
+	authorised := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer service-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	hs.xero.HandleFunc("GET /auth/v1/admin/users", func(w http.ResponseWriter, r *http.Request) {
+		if authorised(w, r) {
+			_, _ = w.Write([]byte(`{"users":[
+				{"id":"admin-1","email":"admin@example.com","created_at":"2025-01-02T00:00:00Z","app_metadata":{"role":"admin"}},
+				{"id":"user-2","email":"ann@example.com","created_at":"2025-03-09T00:00:00Z","banned_until":"2125-01-01T00:00:00Z"}
+			]}`))
+		}
+	})
+	hs.xero.HandleFunc("GET /auth/v1/admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if authorised(w, r) {
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": r.PathValue("id"), "email": r.PathValue("id") + "@example.com"})
+		}
+	})
+	hs.xero.HandleFunc("PUT /auth/v1/admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorised(w, r) {
+			return
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.updates[r.PathValue("id")] = body
+		f.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	hs.xero.HandleFunc("POST /auth/v1/recover", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.resets = append(f.resets, body["email"])
+		f.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	return f
+}
+
+func TestAdminUsers(t *testing.T) {
+	t.Parallel()
+
+	t.Run("admins only", func(t *testing.T) {
+		hs := newHarness(t)
+		newFakeSupabaseAdmin(hs)
+		expectStatus(t, hs.do(http.MethodGet, "/admin/users", nil), http.StatusForbidden)
+		expectStatus(t, hs.do(http.MethodPost, "/admin/users/user-2/role", url.Values{"role": {"admin"}}), http.StatusForbidden)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		hs := newHarness(t)
+		newFakeSupabaseAdmin(hs)
+		rec := hs.do(http.MethodGet, "/admin/users", nil, asAdmin)
+		expectStatus(t, rec, http.StatusOK)
+		body := rec.Body.String()
+		for _, want := range []string{"admin@example.com", "(you)", "ann@example.com", "disabled", `action="/admin/users/user-2/enable"`} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("user list missing %q:\n%s", want, body)
+			}
+		}
+		if strings.Contains(body, `action="/admin/users/admin-1/disable"`) {
+			t.Fatal("admins are offered to disable themselves")
+		}
+	})
+
+	t.Run("set role", func(t *testing.T) {
+		hs := newHarness(t)
+		f := newFakeSupabaseAdmin(hs)
+		expectRedirect(t, hs.do(http.MethodPost, "/admin/users/user-2/role", url.Values{"role": {"admin"}}, asAdmin), "/admin/users")
+		meta, _ := f.updates["user-2"]["app_metadata"].(map[string]interface{})
+		if meta["role"] != "admin" {
+			t.Fatalf("update sent %v", f.updates["user-2"])
+		}
+		expectStatus(t, hs.do(http.MethodPost, "/admin/users/user-2/role", url.Values{"role": {"owner"}}, asAdmin), http.StatusBadRequest)
+
+		rec := hs.do(http.MethodPost, "/admin/users/"+testAdminID+"/role", url.Values{"role": {""}}, asAdmin)
+		expectRedirect(t, rec, "/admin/users")
+		if _, ok := f.updates[testAdminID]; ok {
+			t.Fatal("admin removed their own role")
+		}
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "own admin role") {
+			t.Fatalf("flash = %v", msgs)
+		}
+	})
+
+	t.Run("disable and enable", func(t *testing.T) {
+		hs := newHarness(t)
+		f := newFakeSupabaseAdmin(hs)
+		expectRedirect(t, hs.do(http.MethodPost, "/admin/users/user-2/disable", url.Values{}, asAdmin), "/admin/users")
+		if got := f.updates["user-2"]["ban_duration"]; got != "876000h" {
+			t.Fatalf("disable sent ban_duration %v", got)
+		}
+		expectRedirect(t, hs.do(http.MethodPost, "/admin/users/user-2/enable", url.Values{}, asAdmin), "/admin/users")
+		if got := f.updates["user-2"]["ban_duration"]; got != "none" {
+			t.Fatalf("enable sent ban_duration %v", got)
+		}
+		hs.do(http.MethodPost, "/admin/users/"+testAdminID+"/disable", url.Values{}, asAdmin)
+		if _, ok := f.updates[testAdminID]; ok {
+			t.Fatal("admin disabled their own account")
+		}
+	})
+
+	t.Run("password reset", func(t *testing.T) {
+		hs := newHarness(t)
+		f := newFakeSupabaseAdmin(hs)
+		expectRedirect(t, hs.do(http.MethodPost, "/admin/users/user-2/reset-password", url.Values{}, asAdmin), "/admin/users")
+		if len(f.resets) != 1 || f.resets[0] != "user-2@example.com" {
+			t.Fatalf("resets = %v", f.resets)
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.handler.admin = nil
+		expectStatus(t, hs.do(http.MethodGet, "/admin/users", nil, asAdmin), http.StatusServiceUnavailable)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	var sent struct{ token, password string }
+	hs.xero.HandleFunc("PUT /auth/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sent.token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sent.password = body["password"]
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	expectStatus(t, hs.do(http.MethodGet, "/account/password", nil), http.StatusOK)
+	expectStatus(t, hs.do(http.MethodPost, "/account/password", url.Values{"password": {"short"}, "password_confirm": {"short"}}), http.StatusBadRequest)
+	expectStatus(t, hs.do(http.MethodPost, "/account/password", url.Values{"password": {"new-secret"}, "password_confirm": {"other"}}), http.StatusBadRequest)
+
+	rec := hs.do(http.MethodPost, "/account/password", url.Values{"password": {"new-secret"}, "password_confirm": {"new-secret"}})
+	expectRedirect(t, rec, "/")
+	if sent.token != testAccessToken || sent.password != "new-secret" {
+		t.Fatalf("update sent %+v", sent)
+	}
+}
+
+func TestDebugVars(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	expectStatus(t, hs.do(http.MethodGet, "/debug/vars", nil, anonymous), http.StatusFound)
+	expectStatus(t, hs.do(http.MethodGet, "/debug/vars", nil), http.StatusForbidden)
+
+	rec := hs.do(http.MethodGet, "/debug/vars", nil, asAdmin)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), `"cmdline"`) {
+		t.Fatalf("expvars missing:\n%s", rec.Body.String())
+	}
+}
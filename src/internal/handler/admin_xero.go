@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// diagnosticsStore is what the Xero diagnostics page reads: every stored connection,
+// fresh credentials for one of them and the failed calls in api_call_log.
+type diagnosticsStore interface {
+	ListAllConnections(ctx context.Context) ([]service.ConnectionInfo, error)
+	ConnectionCredentials(ctx context.Context, conn service.ConnectionInfo) (service.XeroCredentials, error)
+	ListAPICallErrors(ctx context.Context, tenantID string, limit int) ([]service.APICallError, error)
+}
+
+// diagnosticsDB is the diagnosticsStore outside tests.
+type diagnosticsDB struct {
+	dbStore
+	tokens *service.TokenManager
+}
+
+func (s diagnosticsDB) ListAllConnections(ctx context.Context) ([]service.ConnectionInfo, error) {
+	return service.ListAllConnections(ctx, s.dbURL)
+}
+
+func (s diagnosticsDB) ConnectionCredentials(ctx context.Context, conn service.ConnectionInfo) (service.XeroCredentials, error) {
+	return s.tokens.Credentials(ctx, conn)
+}
+
+func (s diagnosticsDB) ListAPICallErrors(ctx context.Context, tenantID string, limit int) ([]service.APICallError, error) {
+	return service.ListAPICallErrors(ctx, s.dbURL, tenantID, limit)
+}
+
+// adminXeroErrorsShown is how many failed Xero calls the diagnostics page lists.
+const adminXeroErrorsShown = 20
+
+// xeroCheck is the outcome of one live check on the Xero diagnostics page.
+type xeroCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// adminXeroHandler is the Xero diagnostics page. It lists every stored connection
+// and, for the one picked with ?connection=ID, runs live checks of Xero's
+// reachability, the connection's tokens, the organisation fetch and its rate limits,
+// and reports how fresh its cached items and contacts are. The newest failed Xero
+// calls are listed too (only the picked connection's tenant's, when one is picked).
+func (h *Handler) adminXeroHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	conns, err := h.diagnostics.ListAllConnections(ctx)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to list Xero connections", err)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":        "Xero diagnostics",
+		"Connections":  conns,
+		"Flash":        h.flash.Pop(w, r),
+		"RecordsCalls": h.cfg.Xero.Debug == config.XeroDebugDB,
+	}
+	tenantID := ""
+	if id := r.URL.Query().Get("connection"); id != "" {
+		i := slices.IndexFunc(conns, func(c service.ConnectionInfo) bool { return c.ID == id })
+		if i < 0 {
+			h.renderError(w, r, http.StatusNotFound, "No such Xero connection", nil)
+			return
+		}
+		data["Selected"] = conns[i]
+		data["Checks"] = h.checkXeroConnection(ctx, conns[i])
+		tenantID = conns[i].TenantID
+	}
+	calls, err := h.diagnostics.ListAPICallErrors(ctx, tenantID, adminXeroErrorsShown)
+	if err != nil {
+		log.Printf("admin xero: %v", err)
+		data["ErrorsUnavailable"] = err.Error()
+	}
+	data["Errors"] = calls
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "admin_xero.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// checkXeroConnection runs the live checks of conn, in the order the page shows
+// them. Each Xero call is made once, without retries and bypassing the caches, so
+// the outcome is what Xero answers now.
+func (h *Handler) checkXeroConnection(ctx context.Context, conn service.ConnectionInfo) []xeroCheck {
+	ctx = xero.WithRetryPolicy(ctx, xero.NoRetry)
+	var checks []xeroCheck
+
+	reach := xeroCheck{Name: "Xero reachable", OK: true, Detail: "the identity service answered"}
+	if err := h.xc.Ping(ctx); err != nil {
+		reach.OK, reach.Detail = false, err.Error()
+	}
+	checks = append(checks, reach)
+
+	token := xeroCheck{Name: "Access token"}
+	creds, err := h.diagnostics.ConnectionCredentials(ctx, conn)
+	switch {
+	case errors.Is(err, service.ErrConsentRevoked):
+		token.Detail = "Xero refused the refresh token, so the connection was removed; the workspace has to connect to Xero again"
+	case err != nil:
+		token.Detail = err.Error()
+	default:
+		token.OK = true
+		if left := time.Until(time.Unix(conn.ExpiresAt, 0)); left > 0 {
+			token.Detail = "the stored token is valid for another " + left.Round(time.Second).String()
+		} else {
+			token.Detail = "the stored token had expired " + (-left).Round(time.Second).String() + " ago and was refreshed"
+		}
+	}
+	checks = append(checks, token)
+
+	org := xeroCheck{Name: "Organisation"}
+	limits := xeroCheck{Name: "Rate limits"}
+	if !token.OK {
+		org.Detail = "not checked without an access token"
+		limits.Detail = org.Detail
+	} else {
+		o, rl, err := h.xc.GetOrganisationLimits(ctx, creds.AccessToken, creds.TenantID)
+		if err != nil {
+			_, msg := errorStatus("Xero", err)
+			org.Detail = msg
+		} else {
+			org.OK, org.Detail = true, fmt.Sprintf("%s (%s, base currency %s)", o.Name, o.CountryCode, o.BaseCurrency)
+		}
+		limits.OK, limits.Detail = rateLimitsDetail(rl)
+	}
+	checks = append(checks, org, limits)
+
+	cached, err := service.XeroCacheFreshness(ctx, conn.TenantID)
+	if err != nil {
+		checks = append(checks, xeroCheck{Name: "Cached lookups", Detail: "the cache could not be read: " + err.Error()})
+	}
+	for _, c := range cached {
+		check := xeroCheck{Name: "Cached " + c.Name, OK: true}
+		if c.FilledAt.IsZero() {
+			check.Detail = "nothing cached; entries are kept " + c.TTL.String()
+		} else {
+			check.Detail = "last fetched from Xero " + time.Since(c.FilledAt).Round(time.Second).String() + " ago; entries are kept " + c.TTL.String()
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// rateLimitsDetail describes the calls Xero says are left, and whether any limit is
+// used up.
+func rateLimitsDetail(l xero.RateLimits) (bool, string) {
+	if !l.Known() {
+		return false, "Xero sent no rate limit headers"
+	}
+	var parts []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{
+		{l.DayRemaining, "today"},
+		{l.MinuteRemaining, "this minute"},
+		{l.AppMinuteRemaining, "this minute for the whole app"},
+	} {
+		if c.n >= 0 {
+			parts = append(parts, fmt.Sprintf("%d calls left %s", c.n, c.what))
+		}
+	}
+	detail := strings.Join(parts, ", ")
+	if l.Problem != "" {
+		return false, detail + "; Xero refused the call over the " + l.Problem + " limit"
+	}
+	return l.DayRemaining != 0 && l.MinuteRemaining != 0 && l.AppMinuteRemaining != 0, detail
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestAdminXero(t *testing.T) {
+	t.Parallel()
+
+	newDiagnosticsHarness := func(t *testing.T) *harness {
+		hs := newHarness(t)
+		hs.store.connections["conn-1"] = &storedConnection{OwnerID: testOwnerID, TenantID: "tenant-1", TenantName: "Acme Ltd", AccessToken: "xero-access", RefreshToken: "refresh"}
+		hs.store.connections["conn-2"] = &storedConnection{OwnerID: "owner-2", TenantID: "tenant-2", TenantName: "Revoked Ltd"}
+		hs.store.apiErrors = []service.APICallError{
+			{At: 1760000000, TenantID: "tenant-1", Method: "GET", URL: "/api.xro/2.0/Items", Status: 429, Error: "xero items: status 429"},
+			{At: 1759990000, TenantID: "tenant-2", Method: "GET", URL: "/api.xro/2.0/Contacts", Status: 0, Error: "context deadline exceeded"},
+		}
+		hs.xero.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{}`)
+		})
+		hs.xero.HandleFunc("GET /api.xro/2.0/Organisation", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer xero-access" || r.Header.Get("Xero-Tenant-Id") != "tenant-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("X-DayLimit-Remaining", "4321")
+			w.Header().Set("X-MinLimit-Remaining", "58")
+			w.Header().Set("X-AppMinLimit-Remaining", "9990")
+			fmt.Fprint(w, `{"Organisations":[{"Name":"Acme Ltd","BaseCurrency":"GBP","CountryCode":"GB"}]}`)
+		})
+		return hs
+	}
+
+	t.Run("admins only", func(t *testing.T) {
+		hs := newDiagnosticsHarness(t)
+		expectStatus(t, hs.do(http.MethodGet, "/admin/xero", nil), http.StatusForbidden)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		hs := newDiagnosticsHarness(t)
+		rec := hs.do(http.MethodGet, "/admin/xero", nil, asAdmin)
+		expectStatus(t, rec, http.StatusOK)
+		body := rec.Body.String()
+		for _, want := range []string{"Acme Ltd", "Revoked Ltd", `href="/admin/xero?connection=conn-2"`, "xero items: status 429", "context deadline exceeded", "XERO_DEBUG=db"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("page missing %q:\n%s", want, body)
+			}
+		}
+		if strings.Contains(body, "Checks for") {
+			t.Fatal("checks ran without a connection picked")
+		}
+	})
+
+	t.Run("checks", func(t *testing.T) {
+		hs := newDiagnosticsHarness(t)
+		rec := hs.do(http.MethodGet, "/admin/xero?connection=conn-1", nil, asAdmin)
+		expectStatus(t, rec, http.StatusOK)
+		body := rec.Body.String()
+		for _, want := range []string{"Checks for Acme Ltd", "the identity service answered", "valid for another", "Acme Ltd (GB, base currency GBP)",
+			"4321 calls left today, 58 calls left this minute, 9990 calls left this minute for the whole app", "Cached items", "nothing cached", "xero items: status 429"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("page missing %q:\n%s", want, body)
+			}
+		}
+		if strings.Contains(body, "context deadline exceeded") {
+			t.Fatal("errors of another tenant listed")
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		hs := newDiagnosticsHarness(t)
+		rec := hs.do(http.MethodGet, "/admin/xero?connection=conn-2", nil, asAdmin)
+		expectStatus(t, rec, http.StatusOK)
+		body := rec.Body.String()
+		for _, want := range []string{"Xero refused the refresh token", "not checked without an access token"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("page missing %q:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("unknown connection", func(t *testing.T) {
+		hs := newDiagnosticsHarness(t)
+		expectStatus(t, hs.do(http.MethodGet, "/admin/xero?connection=nope", nil, asAdmin), http.StatusNotFound)
+	})
+}
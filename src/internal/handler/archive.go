@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// includeArchived reads ?include_archived=1 (or true) from a list request.
+func includeArchived(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("include_archived"))
+	return v
+}
+
+// shoppingListHandler lists the owner's shopping list, newest first, as a page or
+// JSON (see renderList). Query: page, per_page, sort (list_id, item_id, quantity,
+// needed_by; "-" for descending), q, and include_archived=1 to add archived rows
+// (those have archived_at set).
+func (h *Handler) shoppingListHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	req, ok := h.listRequest(w, r, service.ShoppingListSpec)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	page, err := h.lists.ListShoppingList(ctx, ownerID, includeArchived(r), req)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load shopping list", err)
+		return
+	}
+	renderList(h, w, r, page, "shopping_list.html", map[string]any{
+		"Title":           "Shopping list",
+		"IncludeArchived": includeArchived(r),
+		"Locale":          h.localeForOwner(ctx, ownerID),
+	})
+}
+
+// supplierMappingsHandler returns the item-to-supplier mappings as JSON;
+// ?include_archived=1 adds archived ones.
+func (h *Handler) supplierMappingsHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	mappings, err := service.ListSupplierMappings(ctx, h.dbURL, ownerID, includeArchived(r))
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load supplier mappings", err)
+		return
+	}
+	if mappings == nil {
+		mappings = []service.SupplierMapping{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"mappings": mappings})
+}
+
+// archiveSupplierMappingHandler archives or restores one item's supplier mapping from
+// the item page. An archived supplier is not ordered from and the item is resolved
+// like an assembly again, until restored or purged.
+func (h *Handler) archiveSupplierMappingHandler(w http.ResponseWriter, r *http.Request) {
+	code, contact := chi.URLParam(r, "code"), chi.URLParam(r, "contact")
+	archive := chi.URLParam(r, "action") == "archive"
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	err := service.SetSupplierMappingArchived(ctx, h.dbURL, ownerID, code, contact, archive)
+	if errors.Is(err, service.ErrMappingNotFound) {
+		h.renderError(w, r, http.StatusNotFound, err.Error(), err)
+		return
+	}
+	back := "/items/" + url.PathEscape(code)
+	if err != nil {
+		h.flash.Add(w, r, flash.Error, "Update failed: "+err.Error())
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if archive {
+		h.flash.Add(w, r, flash.Info, "Supplier "+contact+" archived for "+code)
+	} else {
+		h.flash.Add(w, r, flash.Info, "Supplier "+contact+" restored for "+code)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// preferSupplierHandler makes one of an item's suppliers the one it is ordered from by
+// default, in place of the cheapest.
+func (h *Handler) preferSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	code, contact := chi.URLParam(r, "code"), chi.URLParam(r, "contact")
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	err := service.SetPreferredSupplier(ctx, h.dbURL, ownerID, code, contact)
+	if errors.Is(err, service.ErrMappingNotFound) {
+		h.renderError(w, r, http.StatusNotFound, err.Error(), err)
+		return
+	}
+	back := "/items/" + url.PathEscape(code)
+	if err != nil {
+		h.flash.Add(w, r, flash.Error, "Update failed: "+err.Error())
+	} else {
+		h.flash.Add(w, r, flash.Info, "Supplier "+contact+" is now preferred for "+code)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// maxAttachmentBytes caps a single uploaded photo/datasheet.
+const maxAttachmentBytes = 10 << 20
+
+// attachmentURLTTL is how long a redirect to stored content stays valid.
+const attachmentURLTTL = 5 * time.Minute
+
+// itemDetailHandler renders the local catalog page for one item code.
+func (h *Handler) itemDetailHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		http.Error(w, "item code missing", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	item, err := service.GetItemDetail(ctx, h.dbURL, ownerID, code)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load item", err)
+		return
+	}
+
+	// item name from Xero is best-effort; the page is still useful without it
+	var itemName string
+	if ownerID != "" {
+		if creds, err := h.tokens.CredentialsForOwner(ctx, ownerID); err == nil {
+			if name, ok, err := h.xc.GetItemNameByCode(ctx, creds.AccessToken, creds.TenantID, code); err == nil && ok {
+				itemName = name
+			}
+		}
+	}
+
+	data := map[string]interface{}{
+		"Title":          "Item " + code,
+		"Item":           item,
+		"ItemName":       itemName,
+		"Flash":          h.flash.Pop(w, r),
+		"StorageEnabled": h.store != nil,
+		"CSRFToken":      mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "item.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// uploadAttachmentHandler stores an image or PDF datasheet for an item code.
+// Expects multipart form field "file".
+func (h *Handler) uploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	code := chi.URLParam(r, "code")
+	back := "/items/" + url.PathEscape(code)
+
+	redirectWithMsg := func(level flash.Level, msg string) {
+		h.flash.Add(w, r, level, msg)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+	}
+
+	if h.store == nil {
+		http.Error(w, "attachment storage not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentBytes+1<<20)
+	if err := r.ParseMultipartForm(maxAttachmentBytes); err != nil {
+		redirectWithMsg(flash.Error, "upload too large or malformed (max 10 MB)")
+		return
+	}
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		redirectWithMsg(flash.Error, "no file selected")
+		return
+	}
+	defer f.Close()
+	if fh.Size > maxAttachmentBytes {
+		redirectWithMsg(flash.Error, "file too large (max 10 MB)")
+		return
+	}
+
+	// trust the content, not the client-supplied header
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	kind := service.AttachmentKindFor(contentType)
+	if kind == "" {
+		redirectWithMsg(flash.Error, "only images and PDF datasheets can be attached")
+		return
+	}
+
+	var rnd [8]byte
+	if _, err := rand.Read(rnd[:]); err != nil {
+		http.Error(w, "failed to generate key", http.StatusInternalServerError)
+		return
+	}
+	key := service.AttachmentStorageKey(code, hex.EncodeToString(rnd[:]), fh.Filename)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	if err := h.store.Put(ctx, key, contentType, io.MultiReader(bytes.NewReader(head), f)); err != nil {
+		redirectWithMsg(flash.Error, "upload failed: "+err.Error())
+		return
+	}
+	_, err = service.CreatePartAttachment(ctx, h.dbURL, service.PartAttachment{
+		WorkspaceID: ownerID,
+		ItemID:      code,
+		Kind:        kind,
+		StorageKey:  key,
+		Filename:    fh.Filename,
+		ContentType: contentType,
+		SizeBytes:   fh.Size,
+		UploadedBy:  userID,
+	})
+	if err != nil {
+		// don't leave an orphaned object behind
+		if derr := h.store.Delete(ctx, key); derr != nil {
+			log.Printf("delete orphaned attachment %s: %v", key, derr)
+		}
+		redirectWithMsg(flash.Error, "failed to save attachment: "+err.Error())
+		return
+	}
+	redirectWithMsg(flash.Info, "attached "+fh.Filename)
+}
+
+// attachmentHandler redirects to a short-lived signed URL for the stored file. Only
+// the workspace's own attachments are found.
+func (h *Handler) attachmentHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if h.store == nil {
+		http.Error(w, "attachment storage not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid attachment id", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	a, err := service.GetPartAttachment(ctx, h.dbURL, ownerID, id)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load attachment", err)
+		return
+	}
+	if a == nil {
+		http.NotFound(w, r)
+		return
+	}
+	u, err := h.store.SignedURL(ctx, a.StorageKey, attachmentURLTTL)
+	if err != nil {
+		h.renderError(w, r, http.StatusBadGateway, "failed to sign url", err)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// deleteAttachmentHandler removes one of the workspace's attachments and its stored
+// object.
+func (h *Handler) deleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if h.store == nil {
+		http.Error(w, "attachment storage not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid attachment id", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	a, err := service.GetPartAttachment(ctx, h.dbURL, ownerID, id)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load attachment", err)
+		return
+	}
+	if a == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if err := service.DeletePartAttachment(ctx, h.dbURL, ownerID, id); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to delete attachment", err)
+		return
+	}
+	// the row is gone, so a failed object delete only leaves an unreachable file
+	if err := h.store.Delete(ctx, a.StorageKey); err != nil {
+		log.Printf("delete attachment object %s: %v", a.StorageKey, err)
+	}
+	h.flash.Add(w, r, flash.Info, "removed "+a.Filename)
+	http.Redirect(w, r, "/items/"+url.PathEscape(a.ItemID), http.StatusSeeOther)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/frontend"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/utils"
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+	"github.com/hwalton/xero-invoice-orderer/pkg/supabasetoolbox"
+)
+
+// Session handling: the login page, password login against Supabase, logout and the
+// session cookies shared with registration (register.go).
+
+// loginHandler serves the login page.
+func (h *Handler) loginHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderLogin(w, r, http.StatusOK, map[string]interface{}{})
+}
+
+// supabaseConnect handles POST from the login form, authenticates with Supabase,
+// sets session cookies on success and redirects to "/". Attempts are throttled per
+// client IP and per email (h.limits).
+func (h *Handler) supabaseConnectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	email := r.FormValue("email")
+	password := r.FormValue("password")
+
+	log.Printf("supabaseConnect: attempt for email=%s", email)
+
+	if msg, wait := h.limits.attempt(r.Context(), r, email); wait > 0 {
+		log.Printf("supabaseConnect: throttled email=%s ip=%s", email, clientIP(r))
+		setRetryAfter(w, wait)
+		h.renderLogin(w, r, http.StatusTooManyRequests, map[string]interface{}{"Email": email, "Locked": msg})
+		return
+	}
+
+	access, refresh, userID, err := h.supabase.SignIn(r.Context(), email, password)
+	if err != nil {
+		log.Printf("supabaseConnect: auth failed: %v", err)
+		data := map[string]interface{}{"Email": email, "Error": "Invalid credentials"}
+		var ae *supabasetoolbox.AuthError
+		switch {
+		case errors.Is(err, breaker.ErrOpen):
+			data["Error"] = unavailableMessage("Supabase")
+		case errors.As(err, &ae) && ae.Status >= 400 && ae.Status < 500:
+			// only a rejected password counts towards locking the account
+			if locked, msg := h.limits.failed(r.Context(), email); locked {
+				delete(data, "Error")
+				data["Locked"] = msg
+			} else if msg != "" {
+				data["Error"] = "Invalid credentials. " + msg
+			}
+		}
+		h.renderLogin(w, r, http.StatusOK, data)
+		return
+	}
+	h.limits.succeeded(r.Context(), email)
+
+	setSessionCookies(w, r, access, refresh)
+
+	// keep user id in request context instead of a cookie (for this request)
+	r = r.WithContext(context.WithValue(r.Context(), mid.CtxUserID, userID))
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// renderLogin shows the login page with data (Error, Locked, Email, ...).
+func (h *Handler) renderLogin(w http.ResponseWriter, r *http.Request, status int, data map[string]interface{}) {
+	data["Title"] = "Login — Business"
+	data["CSRFToken"] = mid.CSRFToken(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if h.templates != nil {
+		_ = h.templates.ExecuteTemplate(w, "login.html", data)
+		return
+	}
+	if b, e := frontend.TemplatesFS.ReadFile("templates/login.html"); e == nil {
+		_, _ = w.Write(b)
+		return
+	}
+	http.Error(w, "template error", http.StatusInternalServerError)
+}
+
+// logoutHandler clears the session cookies and redirects to /login.
+func (h *Handler) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	for _, n := range []string{"access_token", "refresh_token"} {
+		utils.ClearCookie(w, r, n)
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// setSessionCookies stores a Supabase session: the access token for an hour (its
+// lifetime) and the refresh token for 30 days.
+func setSessionCookies(w http.ResponseWriter, r *http.Request, access, refresh string) {
+	utils.SetCookie(w, r, "access_token", access, time.Now().Add(time.Hour))
+	utils.SetCookie(w, r, "refresh_token", refresh, time.Now().Add(30*24*time.Hour))
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// snapshotView is a row of the snapshot list on the changes page.
+type snapshotView struct {
+	ID       int
+	Taken    int64 // epoch seconds
+	Parts    int
+	From, To bool // the snapshots being compared
+}
+
+// bomChangesHandler shows how an invoice's parts list changed between two of its
+// snapshots (?from=&to= snapshot ids; by default the latest against the one before),
+// e.g. before re-ordering after an engineering change. ?format=json returns the
+// snapshots compared and the changes.
+func (h *Handler) bomChangesHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	invoiceNumber := chi.URLParam(r, "number")
+	if invoiceNumber == "" {
+		http.Error(w, "invoice number missing", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	snapshots, err := h.invoices.ListBOMSnapshots(ctx, ownerID, invoiceNumber)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load snapshots", err)
+		return
+	}
+
+	// snapshots are newest first
+	var from, to *service.BOMSnapshot
+	if len(snapshots) > 0 {
+		to = &snapshots[0]
+	}
+	if len(snapshots) > 1 {
+		from = &snapshots[1]
+	}
+	for param, dst := range map[string]**service.BOMSnapshot{"from": &from, "to": &to} {
+		s := r.URL.Query().Get(param)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "invalid "+param, http.StatusBadRequest)
+			return
+		}
+		*dst = nil
+		for i := range snapshots {
+			if snapshots[i].ID == id {
+				*dst = &snapshots[i]
+			}
+		}
+		if *dst == nil {
+			http.Error(w, "snapshot not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	var changes []service.PartChange
+	if from != nil && to != nil {
+		changes = service.DiffBOMSnapshots(from.Parts, to.Parts)
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"invoice_number": invoiceNumber,
+			"from":           from,
+			"to":             to,
+			"changes":        changes,
+		})
+		return
+	}
+
+	views := make([]snapshotView, 0, len(snapshots))
+	for _, s := range snapshots {
+		views = append(views, snapshotView{
+			ID:    s.ID,
+			Taken: s.CreatedAt,
+			Parts: len(s.Parts),
+			From:  from != nil && from.ID == s.ID,
+			To:    to != nil && to.ID == s.ID,
+		})
+	}
+	data := map[string]interface{}{
+		"Title":         "Parts list changes: " + invoiceNumber,
+		"InvoiceNumber": invoiceNumber,
+		"Snapshots":     views,
+		"Compared":      from != nil && to != nil,
+		"Changes":       changes,
+		"Locale":        h.localeForOwner(ctx, ownerID),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "bom_changes.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
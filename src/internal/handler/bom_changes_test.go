@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestBOMChanges(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	resolve := func(totals ...service.LeafTotal) {
+		t.Helper()
+		hs.store.invoices["INV-1"] = resolvedInvoice{
+			perAssy:    []service.BOMNode{{PartID: "ASSY", Name: "Frame", Quantity: 1, IsAssembly: true}},
+			leafTotals: totals,
+		}
+		rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "ignore_stock": {"1"}}, htmx)
+		expectStatus(t, rec, http.StatusOK)
+	}
+
+	rec := hs.do(http.MethodGet, "/invoice/INV-1/changes", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "No snapshots yet") {
+		t.Fatal("expected the no snapshots message")
+	}
+
+	resolve(service.LeafTotal{PartID: "BOLT", Name: "Bolt", Quantity: 4}, service.LeafTotal{PartID: "WASHER", Name: "Washer", Quantity: 8})
+	// an engineering change: more bolts, washers replaced by clips
+	resolve(service.LeafTotal{PartID: "BOLT", Name: "Bolt", Quantity: 6}, service.LeafTotal{PartID: "CLIP", Name: "Clip", Quantity: 2})
+	// resolving again without changes keeps no new snapshot
+	resolve(service.LeafTotal{PartID: "BOLT", Name: "Bolt", Quantity: 6}, service.LeafTotal{PartID: "CLIP", Name: "Clip", Quantity: 2})
+	if n := len(hs.store.snapshots["INV-1"]); n != 2 {
+		t.Fatalf("%d snapshots, want 2", n)
+	}
+
+	rec = hs.do(http.MethodGet, "/invoice/INV-1/changes", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := html.UnescapeString(rec.Body.String())
+	for _, want := range []string{"Added", "Removed", "+2", "-8", "/items/CLIP"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+
+	rec = hs.do(http.MethodGet, "/invoice/INV-1/changes?from=2&to=2&format=json", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var res struct {
+		Changes []service.PartChange `json:"changes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changes) != 0 {
+		t.Fatalf("a snapshot against itself has changes: %+v", res.Changes)
+	}
+
+	expectStatus(t, hs.do(http.MethodGet, "/invoice/INV-1/changes?from=99", nil), http.StatusNotFound)
+	expectStatus(t, hs.do(http.MethodGet, "/invoice/INV-1/changes?to=x", nil), http.StatusBadRequest)
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// maxBOMImportBytes caps an uploaded parent_child CSV.
+const maxBOMImportBytes = 5 << 20
+
+// bomImportResult is what one upload did, for the page and ?format=json.
+type bomImportResult struct {
+	Filename string               `json:"filename"`
+	Rows     int                  `json:"rows"`
+	Errors   []csvimport.RowError `json:"errors"`
+	DryRun   bool                 `json:"dry_run"`
+	Applied  bool                 `json:"applied"`
+	Result   csvimport.Result     `json:"result"`
+	// ProposalID is the parts list change held for review instead of applied, with
+	// the workspace's bom_review setting on
+	ProposalID int `json:"proposal_id,omitempty"`
+}
+
+// bomImportPageHandler shows the parent_child CSV upload form.
+func (h *Handler) bomImportPageHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderBOMImport(w, r, http.StatusOK, nil, "")
+}
+
+// bomImportHandler ingests a parent_child CSV (multipart field "file"; columns
+// parent_code, child_code, qty). Every code must exist in Xero; any problem is
+// reported per line and nothing is written, otherwise all rows are upserted in one
+// transaction. dry_run=1 checks without writing. With the owner's BOMReview setting
+// the rows are proposed for review (bomProposalHandler) instead. ?format=json returns
+// the result (422 when lines have errors).
+func (h *Handler) bomImportHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	asJSON := r.URL.Query().Get("format") == "json"
+	fail := func(msg string, code int) {
+		if asJSON {
+			http.Error(w, msg, code)
+			return
+		}
+		h.renderBOMImport(w, r, code, nil, msg)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBOMImportBytes+1<<20)
+	if err := r.ParseMultipartForm(maxBOMImportBytes); err != nil {
+		fail("upload too large or malformed (max 5 MB)", http.StatusBadRequest)
+		return
+	}
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		fail("no file selected", http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+	rows, rowErrs, err := csvimport.ParseBOM(f)
+	if err != nil {
+		fail(fh.Filename+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if errors.Is(err, service.ErrNoConnection) {
+		fail(err.Error(), http.StatusNotFound)
+		return
+	}
+	if h.redirectToReconnect(w, r, err) {
+		return
+	}
+	if err != nil {
+		if h.renderUnavailable(w, r, "Xero", err) {
+			return
+		}
+		fail(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	known, err := service.KnownXeroItemCodes(ctx, h.xc, creds.AccessToken, creds.TenantID, csvimport.BOMCodes(rows))
+	if err != nil {
+		if h.renderUnavailable(w, r, "Xero", err) {
+			return
+		}
+		fail("Item lookup failed: "+errorText("Xero", err), http.StatusBadGateway)
+		return
+	}
+	rowErrs = append(rowErrs, csvimport.CheckBOMCodes(rows, known)...)
+	csvimport.SortErrors(rowErrs)
+
+	res := &bomImportResult{Filename: fh.Filename, Rows: len(rows), Errors: rowErrs, DryRun: r.PostFormValue("dry_run") == "1"}
+	status := http.StatusOK
+	switch {
+	case len(rowErrs) > 0:
+		status = http.StatusUnprocessableEntity
+	case !res.DryRun:
+		settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+		if err != nil {
+			h.renderError(w, r, http.StatusInternalServerError, "failed to load settings", err)
+			return
+		}
+		if settings.BOMReview {
+			userID, _ := r.Context().Value(mid.CtxUserID).(string)
+			if res.ProposalID, err = h.proposals.ProposeParentChild(ctx, ownerID, userID, fh.Filename, rows); err != nil {
+				h.renderError(w, r, http.StatusInternalServerError, "failed to propose the parts list change", err)
+				return
+			}
+			break
+		}
+		if res.Result, err = service.ImportParentChild(ctx, h.dbURL, ownerID, rows); err != nil {
+			fail("import failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Applied = true
+	}
+
+	if asJSON {
+		if res.Errors == nil {
+			res.Errors = []csvimport.RowError{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+	h.renderBOMImport(w, r, status, res, "")
+}
+
+// renderBOMImport renders the upload page with the last upload's result or error.
+func (h *Handler) renderBOMImport(w http.ResponseWriter, r *http.Request, status int, res *bomImportResult, errMsg string) {
+	data := map[string]interface{}{
+		"Title":     "Import parts lists",
+		"Header":    csvimport.BOMHeader,
+		"Import":    res,
+		"Error":     errMsg,
+		"CSRFToken": mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	if err := h.templates.ExecuteTemplate(w, "bom_import.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// withCSVUpload sends csv as the multipart "file" field, with form fields.
+func withCSVUpload(t *testing.T, csv string, fields map[string]string) reqOption {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", "bom.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = io.WriteString(fw, csv)
+	for k, v := range fields {
+		_ = mw.WriteField(k, v)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return func(r *http.Request) {
+		r.Body = io.NopCloser(bytes.NewReader(buf.Bytes()))
+		r.ContentLength = int64(buf.Len())
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+	}
+}
+
+func TestBOMImport(t *testing.T) {
+	t.Parallel()
+	const target = "/bom/import?csrf_token=" + testCSRFToken
+
+	t.Run("unknown codes are reported per line", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.handler.xc = fakeXeroSuppliers(t).Client()
+		rec := hs.do(http.MethodPost, target+"&format=json", nil, withCSVUpload(t, "parent_code,child_code,qty\nBOLT,NUT,2\nFRAME,BOLT,4\nFRAME,NUT,0\n", nil))
+		expectStatus(t, rec, http.StatusUnprocessableEntity)
+		var res bomImportResult
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Applied || res.Rows != 2 || len(res.Errors) != 2 ||
+			res.Errors[0].Line != 3 || res.Errors[0].Message != "unknown item code FRAME" || res.Errors[1].Line != 4 {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	})
+	t.Run("dry run checks without writing", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.handler.xc = fakeXeroSuppliers(t).Client()
+		rec := hs.do(http.MethodPost, target, nil, withCSVUpload(t, "parent_code,child_code,qty\nBOLT,NUT,2\n", map[string]string{"dry_run": "1"}))
+		expectStatus(t, rec, http.StatusOK)
+		if !strings.Contains(rec.Body.String(), "All 1 line(s) are valid") {
+			t.Fatalf("page missing dry run result: %s", rec.Body.String())
+		}
+	})
+	t.Run("bad header", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodPost, target, nil, withCSVUpload(t, "parent,child\nA,B\n", nil))
+		expectStatus(t, rec, http.StatusBadRequest)
+		if !strings.Contains(rec.Body.String(), "bom.csv: header must have columns") {
+			t.Fatalf("page missing error: %s", rec.Body.String())
+		}
+	})
+	t.Run("form page", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodGet, "/bom/import", nil)
+		expectStatus(t, rec, http.StatusOK)
+		if !strings.Contains(rec.Body.String(), `enctype="multipart/form-data"`) {
+			t.Fatalf("form missing: %s", rec.Body.String())
+		}
+	})
+}
+
+func TestBOMImport_Review(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.handler.xc = fakeXeroSuppliers(t).Client()
+	settings := service.DefaultOwnerSettings()
+	settings.BOMReview = true
+	hs.store.settings[testOwnerID] = settings
+	hs.store.workspaces[testOwnerID] = &fakeWorkspace{name: "Personal", members: map[string]string{
+		testOwnerID: service.WorkspaceOwner,
+		testAdminID: service.WorkspaceMember,
+	}}
+	hs.store.parentChild[[2]string{"BOLT", "NUT"}] = 1
+	asApprover := func(r *http.Request) { asAdmin(r); inWorkspace(testOwnerID)(r) }
+
+	rec := hs.do(http.MethodPost, "/bom/import?csrf_token="+testCSRFToken+"&format=json", nil, withCSVUpload(t, "parent_code,child_code,qty\nBOLT,NUT,2\nNUT,BOLT,1\n", nil))
+	expectStatus(t, rec, http.StatusOK)
+	var res bomImportResult
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Applied || res.ProposalID != 1 || hs.store.parentChild[[2]string{"BOLT", "NUT"}] != 1 {
+		t.Fatalf("import with review on: %+v, parent_child %v", res, hs.store.parentChild)
+	}
+
+	rec = hs.do(http.MethodGet, "/bom/proposals/1", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	if !strings.Contains(body, "1 added, 1 quantity changed, 0 unchanged") || !strings.Contains(body, "Waiting for another user") ||
+		strings.Contains(body, "/bom/proposals/1/approve") {
+		t.Fatalf("proposer's change page:\n%s", body)
+	}
+	if body := hs.do(http.MethodGet, "/bom/proposals/1", nil, asApprover).Body.String(); !strings.Contains(body, "/bom/proposals/1/approve") {
+		t.Fatalf("approver's change page:\n%s", body)
+	}
+	if body := hs.do(http.MethodGet, "/bom/proposals", nil).Body.String(); !strings.Contains(body, `href="/bom/proposals/1"`) {
+		t.Fatalf("changes page:\n%s", body)
+	}
+
+	expectStatus(t, hs.do(http.MethodPost, "/bom/proposals/1/approve", url.Values{}), http.StatusForbidden)
+
+	rec = hs.do(http.MethodPost, "/bom/proposals/1/approve", url.Values{}, asApprover)
+	expectRedirect(t, rec, "/bom/proposals/1")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "Applied 2 line(s): 1 added, 1 quantity changed, 0 unchanged." {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if hs.store.parentChild[[2]string{"BOLT", "NUT"}] != 2 || hs.store.parentChild[[2]string{"NUT", "BOLT"}] != 1 {
+		t.Fatalf("parent_child after approval: %v", hs.store.parentChild)
+	}
+
+	rec = hs.do(http.MethodPost, "/bom/proposals/1/reject", url.Values{}, asApprover)
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "already decided") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// proposalStore keeps parts list changes held for review (bom_proposals).
+type proposalStore interface {
+	ProposeParentChild(ctx context.Context, ownerID, proposedBy, filename string, rows []csvimport.BOMRow) (int, error)
+	ListBOMProposals(ctx context.Context, ownerID string, limit int) ([]service.BOMProposal, error)
+	GetBOMProposal(ctx context.Context, ownerID string, id int) (service.BOMProposal, []service.BOMRowChange, error)
+	DecideBOMProposal(ctx context.Context, ownerID string, id int, decidedBy string, approve bool) (service.BOMProposal, csvimport.Result, error)
+}
+
+func (s dbStore) ProposeParentChild(ctx context.Context, ownerID, proposedBy, filename string, rows []csvimport.BOMRow) (int, error) {
+	return service.ProposeParentChild(ctx, s.dbURL, ownerID, proposedBy, filename, rows)
+}
+
+func (s dbStore) ListBOMProposals(ctx context.Context, ownerID string, limit int) ([]service.BOMProposal, error) {
+	return service.ListBOMProposals(ctx, s.dbURL, ownerID, limit)
+}
+
+func (s dbStore) GetBOMProposal(ctx context.Context, ownerID string, id int) (service.BOMProposal, []service.BOMRowChange, error) {
+	return service.GetBOMProposal(ctx, s.dbURL, ownerID, id)
+}
+
+func (s dbStore) DecideBOMProposal(ctx context.Context, ownerID string, id int, decidedBy string, approve bool) (service.BOMProposal, csvimport.Result, error) {
+	return service.DecideBOMProposal(ctx, s.dbURL, ownerID, id, decidedBy, approve)
+}
+
+// decidedProposalsShown is how many approved or rejected parts list changes the
+// changes page lists below the pending ones.
+const decidedProposalsShown = 20
+
+// bomProposalsHandler lists the parts list changes awaiting review and the ones most
+// recently decided.
+func (h *Handler) bomProposalsHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	proposals, err := h.proposals.ListBOMProposals(ctx, ownerID, decidedProposalsShown)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load parts list changes", err)
+		return
+	}
+	h.renderBOMProposals(w, r, "bom_proposals.html", map[string]interface{}{
+		"Title":     "Parts list changes",
+		"Proposals": proposals,
+		"Locale":    h.localeForOwner(ctx, ownerID),
+	})
+}
+
+// bomProposalHandler shows what approving a parts list change would do to the
+// current parent_child, with approve and reject buttons for an approver other than
+// the user who proposed it. ?format=json returns the proposal and the changes.
+func (h *Handler) bomProposalHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, "invalid change id", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	p, changes, err := h.proposals.GetBOMProposal(ctx, ownerID, id)
+	if err != nil {
+		status, msg := errorStatus("Supabase", err)
+		if status == http.StatusInternalServerError {
+			msg = "failed to load parts list change"
+		}
+		h.renderError(w, r, status, msg, err)
+		return
+	}
+	if r.URL.Query().Get("format") == "json" {
+		if changes == nil {
+			changes = []service.BOMRowChange{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"proposal": p, "changes": changes})
+		return
+	}
+	counts := map[string]int{}
+	for _, c := range changes {
+		counts[c.Kind()]++
+	}
+	self, _ := r.Context().Value(mid.CtxUserID).(string)
+	h.renderBOMProposals(w, r, "bom_proposal.html", map[string]interface{}{
+		"Title":      fmt.Sprintf("Parts list change #%d", p.ID),
+		"Proposal":   p,
+		"Changes":    changes,
+		"Counts":     counts,
+		"CanApprove": canApprove(r) && p.ProposedBy != self,
+		"Self":       self,
+		"Locale":     h.localeForOwner(ctx, ownerID),
+	})
+}
+
+// renderBOMProposals renders a parts list changes page with the flash and CSRF token.
+func (h *Handler) renderBOMProposals(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	data["Flash"] = h.flash.Pop(w, r)
+	data["CSRFToken"] = mid.CSRFToken(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// decideBOMProposalHandler approves or rejects a parts list change ({action}
+// approve|reject). Only an approver (or admin) other than the user who proposed it
+// may; approving applies its rows to parent_child.
+func (h *Handler) decideBOMProposalHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, "invalid change id", err)
+		return
+	}
+	if !canApprove(r) {
+		h.renderError(w, r, http.StatusForbidden, "only an approver can approve or reject parts list changes", nil)
+		return
+	}
+	approve := chi.URLParam(r, "action") == "approve"
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	back := fmt.Sprintf("/bom/proposals/%d", id)
+	p, res, err := h.proposals.DecideBOMProposal(ctx, ownerID, id, userID, approve)
+	switch {
+	case errors.Is(err, service.ErrProposalDecided), errors.Is(err, service.ErrSelfReview):
+		h.flash.Add(w, r, flash.Error, err.Error()+".")
+	case err != nil:
+		status, msg := errorStatus("Supabase", err)
+		if status == http.StatusInternalServerError {
+			msg = "failed to decide parts list change"
+		}
+		h.renderError(w, r, status, msg, err)
+		return
+	case approve:
+		h.flash.Add(w, r, flash.Info, fmt.Sprintf("Applied %d line(s): %d added, %d quantity changed, %d unchanged.",
+			len(p.Rows), res.Inserted, res.Updated, res.Unchanged))
+	default:
+		h.flash.Add(w, r, flash.Info, "Rejected; the parts lists are unchanged.")
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/utils"
+)
+
+// listBuildsHandler returns the owner's builds as JSON.
+func (h *Handler) listBuildsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	builds, err := service.ListBuilds(ctx, h.dbURL, ownerID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load builds", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(builds)
+}
+
+// buildProgressHandler returns purchasing progress for one of the owner's builds.
+func (h *Handler) buildProgressHandler(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.ownedBuild(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	progress, err := service.GetBuildProgress(ctx, h.dbURL, *b)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load progress", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(progress)
+}
+
+// shareBuildHandler issues a new public embed token for a build (replacing any previous
+// token) and returns the embed URL and an iframe snippet. The token is only shown once.
+func (h *Handler) shareBuildHandler(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.ownedBuild(w, r)
+	if !ok {
+		return
+	}
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if _, err := service.SetBuildShareToken(ctx, h.dbURL, b.OwnerID, b.ID, token); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to share build", err)
+		return
+	}
+
+	scheme := "https"
+	if !utils.IsSecureRequest(r) {
+		scheme = "http"
+	}
+	embedURL := fmt.Sprintf("%s://%s/embed/builds/%s", scheme, r.Host, token)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"embed_url": embedURL,
+		"iframe":    fmt.Sprintf(`<iframe src="%s" width="100%%" height="360" style="border:0" loading="lazy"></iframe>`, template.HTMLEscapeString(embedURL)),
+	})
+}
+
+// revokeBuildShareHandler disables the public embed for a build.
+func (h *Handler) revokeBuildShareHandler(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.ownedBuild(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if _, err := service.SetBuildShareToken(ctx, h.dbURL, b.OwnerID, b.ID, ""); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to revoke share", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// embedBuildHandler is the public, read-only progress page for a shared build. It only
+// exposes assembly names and percentages; ?format=json returns the same data as JSON.
+func (h *Handler) embedBuildHandler(w http.ResponseWriter, r *http.Request) {
+	// may be framed by any site, but never cached or indexed
+	w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	b, err := service.GetBuildByShareToken(ctx, h.dbURL, chi.URLParam(r, "token"))
+	if err != nil {
+		log.Printf("embed build: %v", err)
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+		return
+	}
+	if b == nil {
+		http.NotFound(w, r)
+		return
+	}
+	progress, err := service.GetBuildProgress(ctx, h.dbURL, *b)
+	if err != nil {
+		log.Printf("embed build %d: %v", b.ID, err)
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	view := map[string]interface{}{
+		"Title":           b.Name,
+		"Name":            b.Name,
+		"Assemblies":      progress.Assemblies,
+		"OrderedPercent":  progress.OrderedPercent,
+		"ReceivedPercent": progress.ReceivedPercent,
+		"UpdatedAt":       time.Now().UTC(),
+	}
+	if r.URL.Query().Get("format") == "json" {
+		delete(view, "Title")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(view)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "embed_build.html", view); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// ownedBuild loads the {id} build for the current owner, writing an error response
+// and returning ok=false when it is missing.
+func (h *Handler) ownedBuild(w http.ResponseWriter, r *http.Request) (*service.Build, bool) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return nil, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid build id", http.StatusBadRequest)
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	b, err := service.GetBuild(ctx, h.dbURL, ownerID, id)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load build", err)
+		return nil, false
+	}
+	if b == nil {
+		http.Error(w, "build not found", http.StatusNotFound)
+		return nil, false
+	}
+	return b, true
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// xeroExportTimeout bounds a whole contacts or items export; Xero serves 100 records
+// per page, so large organisations take a while.
+const xeroExportTimeout = 2 * time.Minute
+
+// contactsCSVHeader is the column header of the CSV contacts export.
+var contactsCSVHeader = []string{"ContactID", "Name", "AccountNumber", "EmailAddress", "Phone", "ContactStatus", "IsSupplier", "IsCustomer"}
+
+// exportContactsHandler downloads every Xero contact of the owner's organisation as
+// JSON (the full Xero objects, ?format=json, the default) or CSV (?format=csv);
+// ?modifiedSince=YYYY-MM-DD limits it to contacts changed since then.
+// Pages are written as Xero returns them, so nothing is buffered or written to disk.
+// With ?persist=1 the export is also saved to storage under exports/<owner>/, listed
+// on the downloads page and its key returned in X-Export-Key; that needs the whole
+// file, so it is built in memory.
+func (h *Handler) exportContactsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "unsupported export format (want json or csv)", http.StatusBadRequest)
+		return
+	}
+	var q xero.ContactsQuery
+	if v := strings.TrimSpace(r.URL.Query().Get("modifiedSince")); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "invalid modifiedSince date (want YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		q.ModifiedSince = t
+	}
+	persist, ok := h.persistRequested(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), xeroExportTimeout)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	name := "xero-contacts-" + now.Format("20060102-150405")
+	contentType := "application/json"
+	if format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+
+	if persist {
+		var buf bytes.Buffer
+		cw := &contactsWriter{w: &buf, format: format, fetchedAt: now}
+		err := h.xc.EachContactsPage(ctx, creds.AccessToken, creds.TenantID, q, cw.page)
+		if err == nil {
+			err = cw.end()
+		}
+		if err != nil {
+			if !h.renderUnavailable(w, r, "Xero", err) {
+				h.renderError(w, r, http.StatusBadGateway, "contacts fetch failed", err)
+			}
+			return
+		}
+		key, err := h.saveExport(ctx, ownerID, service.ExportContacts, name+"."+format, contentType, buf.Bytes())
+		if err != nil {
+			h.renderError(w, r, http.StatusBadGateway, "failed to store export", err)
+			return
+		}
+		w.Header().Set("X-Export-Key", key)
+		writeDownload(w, contentType, "attachment", name, "."+format, buf.Bytes())
+		return
+	}
+
+	// headers go out with the first page, so a failure before then still gets an
+	// error status
+	rc := http.NewResponseController(w)
+	started := false
+	start := func() {
+		started = true
+		_ = rc.SetWriteDeadline(time.Now().Add(xeroExportTimeout))
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	cw := &contactsWriter{w: w, format: format, fetchedAt: now}
+	err = h.xc.EachContactsPage(ctx, creds.AccessToken, creds.TenantID, q, func(cs []xero.Contact) error {
+		if !started {
+			start()
+		}
+		if err := cw.page(cs); err != nil {
+			return err
+		}
+		_ = rc.Flush()
+		return nil
+	})
+	if err != nil && !started {
+		if !h.renderUnavailable(w, r, "Xero", err) {
+			h.renderError(w, r, http.StatusBadGateway, "contacts fetch failed", err)
+		}
+		return
+	}
+	if err == nil {
+		if !started {
+			start()
+		}
+		err = cw.end()
+	}
+	if err != nil {
+		// the status is already sent; drop the connection so the client sees a
+		// truncated download rather than a file that looks complete
+		log.Printf("contacts export for %s failed after %d contacts: %v", ownerID, cw.n, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// contactsWriter encodes contacts page by page. JSON output is
+// {"FetchedAt": ..., "Contacts": [...]} with each contact as Xero returned it;
+// CSV output has one row per contact under contactsCSVHeader.
+type contactsWriter struct {
+	w         io.Writer
+	format    string
+	fetchedAt time.Time
+	csv       *csv.Writer
+	begun     bool
+	n         int // contacts written
+}
+
+// begin writes the CSV header or opening JSON, once.
+func (cw *contactsWriter) begin() error {
+	if cw.begun {
+		return nil
+	}
+	cw.begun = true
+	if cw.format == "csv" {
+		cw.csv = csv.NewWriter(cw.w)
+		return cw.csv.Write(contactsCSVHeader)
+	}
+	_, err := fmt.Fprintf(cw.w, `{"FetchedAt":%q,"Contacts":[`, cw.fetchedAt.Format(time.RFC3339))
+	return err
+}
+
+// page writes one page of contacts.
+func (cw *contactsWriter) page(cs []xero.Contact) error {
+	if err := cw.begin(); err != nil {
+		return err
+	}
+	for _, c := range cs {
+		if cw.format == "csv" {
+			if err := cw.csv.Write([]string{
+				c.ContactID, c.Name, c.AccountNumber, c.EmailAddress, c.DefaultPhone(), c.ContactStatus,
+				strconv.FormatBool(c.IsSupplier), strconv.FormatBool(c.IsCustomer),
+			}); err != nil {
+				return err
+			}
+			cw.n++
+			continue
+		}
+		raw := c.Raw
+		if len(raw) == 0 {
+			b, err := json.Marshal(c)
+			if err != nil {
+				return err
+			}
+			raw = b
+		}
+		if cw.n > 0 {
+			if _, err := io.WriteString(cw.w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := cw.w.Write(raw); err != nil {
+			return err
+		}
+		cw.n++
+	}
+	if cw.csv != nil {
+		cw.csv.Flush()
+		return cw.csv.Error()
+	}
+	return nil
+}
+
+// end finishes the document; it also writes the header of an empty export.
+func (cw *contactsWriter) end() error {
+	if err := cw.begin(); err != nil {
+		return err
+	}
+	if cw.csv != nil {
+		cw.csv.Flush()
+		return cw.csv.Error()
+	}
+	_, err := io.WriteString(cw.w, "]}\n")
+	return err
+}
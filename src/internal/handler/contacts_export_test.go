@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// memStorage is an in-memory storage.Store.
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	putErr  error
+}
+
+func (m *memStorage) Put(ctx context.Context, key, contentType string, body io.Reader) error {
+	if m.putErr != nil {
+		return m.putErr
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = b
+	return nil
+}
+
+func (m *memStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://storage.test/" + key, nil
+}
+
+func (m *memStorage) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+// serveContacts makes the fake Xero list n contacts, 100 per page.
+func serveContacts(hs *harness, n int) {
+	hs.xero.HandleFunc("GET /api.xro/2.0/Contacts", func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		var cs []string
+		for i := (page - 1) * 100; i < min(page*100, n); i++ {
+			cs = append(cs, fmt.Sprintf(`{"ContactID":"c-%d","Name":"Contact, %d","IsSupplier":true,"Phones":[{"PhoneType":"DEFAULT","PhoneNumber":"0%d"}],"Addresses":[]}`, i, i, i))
+		}
+		fmt.Fprintf(w, `{"Contacts":[%s]}`, strings.Join(cs, ","))
+	})
+}
+
+func TestExportContacts_JSON(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	serveContacts(hs, 150)
+
+	rec := hs.do(http.MethodGet, "/xero/contacts/export", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="xero-contacts-`) || !strings.HasSuffix(cd, `.json"`) {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+	var out struct {
+		FetchedAt string
+		Contacts  []map[string]any
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v; body: %s", err, rec.Body.String())
+	}
+	if out.FetchedAt == "" || len(out.Contacts) != 150 {
+		t.Fatalf("got %d contacts, FetchedAt %q", len(out.Contacts), out.FetchedAt)
+	}
+	// contacts are passed through as Xero returned them
+	if _, ok := out.Contacts[149]["Addresses"]; !ok || out.Contacts[149]["ContactID"] != "c-149" {
+		t.Fatalf("unexpected contact: %+v", out.Contacts[149])
+	}
+}
+
+func TestExportContacts_CSV(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	serveContacts(hs, 2)
+
+	rec := hs.do(http.MethodGet, "/xero/contacts/export?format=csv", nil)
+	expectStatus(t, rec, http.StatusOK)
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "ContactID" || rows[2][1] != "Contact, 1" || rows[2][4] != "01" || rows[2][6] != "true" {
+		t.Fatalf("unexpected rows: %q", rows)
+	}
+}
+
+func TestExportContacts_Empty(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	serveContacts(hs, 0)
+
+	rec := hs.do(http.MethodGet, "/xero/contacts/export", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var out struct{ Contacts []any }
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || out.Contacts == nil || len(out.Contacts) != 0 {
+		t.Fatalf("body %s: %v", rec.Body.String(), err)
+	}
+}
+
+func TestExportContacts_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown format", func(t *testing.T) {
+		hs := newHarness(t)
+		expectStatus(t, hs.do(http.MethodGet, "/xero/contacts/export?format=xml", nil), http.StatusBadRequest)
+	})
+	t.Run("xero failure before the first page", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.xero.HandleFunc("GET /api.xro/2.0/Contacts", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", http.StatusBadRequest)
+		})
+		rec := hs.do(http.MethodGet, "/xero/contacts/export", nil)
+		expectStatus(t, rec, http.StatusBadGateway)
+		if rec.Header().Get("Content-Disposition") != "" {
+			t.Fatal("error response should not be a download")
+		}
+	})
+	t.Run("persist without storage", func(t *testing.T) {
+		hs := newHarness(t)
+		expectStatus(t, hs.do(http.MethodGet, "/xero/contacts/export?persist=1", nil), http.StatusServiceUnavailable)
+	})
+	t.Run("persist upload fails", func(t *testing.T) {
+		hs := newHarness(t)
+		serveContacts(hs, 1)
+		hs.handler.store = &memStorage{objects: map[string][]byte{}, putErr: errors.New("bucket missing")}
+		expectStatus(t, hs.do(http.MethodGet, "/xero/contacts/export?persist=1", nil), http.StatusBadGateway)
+	})
+}
+
+func TestExportContacts_Persist(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	serveContacts(hs, 3)
+	store := &memStorage{objects: map[string][]byte{}}
+	hs.handler.store = store
+
+	rec := hs.do(http.MethodGet, "/xero/contacts/export?format=csv&persist=1", nil)
+	expectStatus(t, rec, http.StatusOK)
+	key := rec.Header().Get("X-Export-Key")
+	if !strings.HasPrefix(key, "exports/"+testOwnerID+"/xero-contacts-") || !strings.HasSuffix(key, ".csv") {
+		t.Fatalf("X-Export-Key = %q", key)
+	}
+	if got := string(store.objects[key]); got == "" || got != rec.Body.String() {
+		t.Fatalf("stored %q, downloaded %q", got, rec.Body.String())
+	}
+	if len(hs.store.exports) != 1 || hs.store.exports[0].StorageKey != key || hs.store.exports[0].Kind != service.ExportContacts {
+		t.Fatalf("recorded exports %+v", hs.store.exports)
+	}
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// creditNoteStore matches Xero credit notes with the shopping list and takes the
+// credited parts off it (applied_credit_notes).
+type creditNoteStore interface {
+	PlanCreditNote(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials, number, invoice string) (service.CreditNotePlan, string, error)
+	ApplyCreditNote(ctx context.Context, ownerID, appliedBy string, plan service.CreditNotePlan) error
+}
+
+func (s dbStore) PlanCreditNote(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials, number, invoice string) (service.CreditNotePlan, string, error) {
+	return service.PlanCreditNote(ctx, s.dbURL, ownerID, xc, creds, number, invoice)
+}
+
+func (s dbStore) ApplyCreditNote(ctx context.Context, ownerID, appliedBy string, plan service.CreditNotePlan) error {
+	return service.ApplyCreditNote(ctx, s.dbURL, ownerID, appliedBy, plan)
+}
+
+// creditRowKeys identifies the rows a plan changes and the versions it read them at
+// ("list_id:version", sorted), so applying can tell whether the plan still holds.
+func creditRowKeys(plan service.CreditNotePlan) []string {
+	var keys []string
+	for _, c := range plan.Changes() {
+		keys = append(keys, fmt.Sprintf("%d:%d", c.ListID, c.Version))
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// creditNoteURL is the credit notes page showing number's plan.
+func creditNoteURL(number, invoice string) string {
+	v := url.Values{"number": {number}}
+	if invoice != "" {
+		v.Set("invoice", invoice)
+	}
+	return "/credit-notes?" + v.Encode()
+}
+
+// creditNotesHandler looks up the Xero credit note ?number= and shows which unordered
+// shopping list rows of the invoices it credits it would reduce or cancel. ?invoice=
+// names the credited invoice for a credit note not allocated to one.
+func (h *Handler) creditNotesHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	number := strings.TrimSpace(r.URL.Query().Get("number"))
+	invoice := strings.TrimSpace(r.URL.Query().Get("invoice"))
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	data := map[string]interface{}{
+		"Title":   "Credit notes",
+		"Number":  number,
+		"Invoice": invoice,
+		"Locale":  h.localeForOwner(ctx, ownerID),
+	}
+	if number != "" {
+		creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+		if err != nil {
+			h.xeroError(w, r, "", err)
+			return
+		}
+		plan, msg, err := h.creditNotes.PlanCreditNote(ctx, ownerID, h.xc, creds, number, invoice)
+		if err != nil {
+			h.xeroError(w, r, "failed to load credit note", err)
+			return
+		}
+		if msg != "" {
+			data["Message"] = msg
+		} else {
+			data["Plan"] = plan
+			data["RowKeys"] = creditRowKeys(plan)
+		}
+	}
+	data["Flash"] = h.flash.Pop(w, r)
+	data["CSRFToken"] = mid.CSRFToken(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "credit_notes.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// applyCreditNoteHandler takes a credit note's parts off the shopping list. The form
+// carries the rows the user reviewed ("row", list_id:version); when the credit note
+// or those rows have changed since, nothing is applied and the page shows the new plan.
+func (h *Handler) applyCreditNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	if err := r.ParseForm(); err != nil {
+		h.renderError(w, r, http.StatusBadRequest, "invalid form", err)
+		return
+	}
+	number := strings.TrimSpace(r.PostFormValue("number"))
+	invoice := strings.TrimSpace(r.PostFormValue("invoice"))
+	if number == "" {
+		h.renderError(w, r, http.StatusBadRequest, "credit note number missing", nil)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	back := func(level flash.Level, msg string, links ...flash.Link) {
+		h.flash.Add(w, r, level, msg, links...)
+		http.Redirect(w, r, creditNoteURL(number, invoice), http.StatusSeeOther)
+	}
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+	plan, msg, err := h.creditNotes.PlanCreditNote(ctx, ownerID, h.xc, creds, number, invoice)
+	switch {
+	case err != nil:
+		h.xeroError(w, r, "failed to load credit note", err)
+		return
+	case msg != "":
+		back(flash.Error, msg+".")
+		return
+	case plan.AppliedAt != 0:
+		back(flash.Error, "Credit note "+plan.Number+" was already applied to the shopping list.")
+		return
+	}
+	reviewed := slices.Clone(r.PostForm["row"])
+	slices.Sort(reviewed)
+	if !slices.Equal(reviewed, creditRowKeys(plan)) {
+		back(flash.Error, "The shopping list or the credit note changed since you reviewed it; check the changes below and apply again.")
+		return
+	}
+	if len(plan.Changes()) == 0 {
+		back(flash.Error, "Nothing to take off the shopping list: none of the credited parts are waiting to be ordered.")
+		return
+	}
+
+	var conflict *service.ShoppingConflictError
+	err = h.creditNotes.ApplyCreditNote(ctx, ownerID, userID, plan)
+	switch {
+	case errors.Is(err, service.ErrCreditNoteApplied):
+		back(flash.Error, "Credit note "+plan.Number+" was already applied to the shopping list.")
+		return
+	case errors.As(err, &conflict):
+		back(flash.Error, "Nothing was changed: "+conflict.Error()+".")
+		return
+	case err != nil:
+		h.renderError(w, r, http.StatusInternalServerError, "failed to apply credit note", err)
+		return
+	}
+	reduced, cancelled := 0, 0
+	for _, c := range plan.Changes() {
+		if c.To > 0 {
+			reduced++
+		} else {
+			cancelled++
+		}
+	}
+	back(flash.Info, fmt.Sprintf("Credit note %s applied: %d shopping list row(s) reduced, %d cancelled (archived).", plan.Number, reduced, cancelled),
+		flash.Link{Text: "Shopping list", URL: "/shopping-list"})
+}
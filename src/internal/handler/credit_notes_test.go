@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// creditNotePlan takes 3 of the 4 unordered BOLT and all of the HINGE off INV-0001's
+// rows; one PANEL was already ordered.
+func creditNotePlan() service.CreditNotePlan {
+	return service.CreditNotePlan{Number: "CN-0001", Invoices: []string{"INV-0001"}, Matches: []service.CreditMatch{
+		{PartID: "BOLT", Credited: 3, Unordered: 4, Rows: []service.CreditRowChange{{ListID: 4, Version: 2, From: 4, To: 1}}},
+		{PartID: "HINGE", Credited: 2, Unordered: 2, Rows: []service.CreditRowChange{{ListID: 5, Version: 1, From: 2, To: 0}}},
+		{PartID: "PANEL", Credited: 1, Ordered: 1},
+	}}
+}
+
+func TestCreditNote(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.creditPlans["CN-0001"] = creditNotePlan()
+
+	body := hs.do(http.MethodGet, "/credit-notes?number=CN-0001", nil).Body.String()
+	for _, want := range []string{"INV-0001", "BOLT", `name="row" value="4:2"`, `name="row" value="5:1"`, "Take off the shopping list"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("credit note page lacks %q:\n%s", want, body)
+		}
+	}
+
+	apply := url.Values{"number": {"CN-0001"}, "row": {"5:1", "4:2"}}
+	rec := hs.do(http.MethodPost, "/credit-notes/apply", apply)
+	expectRedirect(t, rec, "/credit-notes?number=CN-0001")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "1 shopping list row(s) reduced, 1 cancelled") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if len(hs.store.creditApplied) != 1 || hs.store.creditApplied[0].Number != "CN-0001" {
+		t.Fatalf("applied = %+v", hs.store.creditApplied)
+	}
+
+	t.Run("only once", func(t *testing.T) {
+		rec := hs.do(http.MethodPost, "/credit-notes/apply", apply)
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "already applied") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+		if len(hs.store.creditApplied) != 1 {
+			t.Fatalf("applied %d times", len(hs.store.creditApplied))
+		}
+		if body := hs.do(http.MethodGet, "/credit-notes?number=CN-0001", nil).Body.String(); strings.Contains(body, "Take off the shopping list") {
+			t.Fatalf("applied credit note offered again:\n%s", body)
+		}
+	})
+}
+
+func TestCreditNote_Changed(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	// row 4 was edited after the page listed it at version 2
+	plan := creditNotePlan()
+	plan.Matches[0].Rows[0].Version = 3
+	hs.store.creditPlans["CN-0001"] = plan
+	rec := hs.do(http.MethodPost, "/credit-notes/apply", url.Values{"number": {"CN-0001"}, "row": {"4:2", "5:1"}})
+	expectRedirect(t, rec, "/credit-notes?number=CN-0001")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "changed since you reviewed it") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if len(hs.store.creditApplied) != 0 {
+		t.Fatalf("applied = %+v", hs.store.creditApplied)
+	}
+}
+
+func TestCreditNote_Unknown(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	body := hs.do(http.MethodGet, "/credit-notes?number=CN-0404", nil).Body.String()
+	if !strings.Contains(body, "Credit note CN-0404 was not found in Xero") || strings.Contains(body, "Take off the shopping list") {
+		t.Fatalf("unknown credit note page:\n%s", body)
+	}
+}
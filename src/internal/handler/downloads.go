@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// downloadsPageSize is how many recent exports the downloads page lists.
+const downloadsPageSize = 50
+
+// exportStore records the generated files kept in storage for each owner (exports).
+type exportStore interface {
+	RecordExport(ctx context.Context, e service.Export) (int, error)
+	ListExports(ctx context.Context, ownerID string, limit int) ([]service.Export, error)
+	GetExport(ctx context.Context, ownerID string, id int) (*service.Export, error)
+	DeleteExport(ctx context.Context, ownerID string, id int) error
+}
+
+func (s dbStore) RecordExport(ctx context.Context, e service.Export) (int, error) {
+	return service.RecordExport(ctx, s.dbURL, e)
+}
+
+func (s dbStore) ListExports(ctx context.Context, ownerID string, limit int) ([]service.Export, error) {
+	return service.ListExports(ctx, s.dbURL, ownerID, limit)
+}
+
+func (s dbStore) GetExport(ctx context.Context, ownerID string, id int) (*service.Export, error) {
+	return service.GetExport(ctx, s.dbURL, ownerID, id)
+}
+
+func (s dbStore) DeleteExport(ctx context.Context, ownerID string, id int) error {
+	return service.DeleteExport(ctx, s.dbURL, ownerID, id)
+}
+
+// exportFilename names a stored export: base (made filename-safe) and the time it
+// was generated, so repeated exports of one invoice do not replace each other.
+func exportFilename(base, suffix string, generated time.Time) string {
+	return unsafeFilenameChars.ReplaceAllString(base, "_") + "-" + generated.UTC().Format("20060102-150405") + suffix
+}
+
+// saveExport stores body under exports/<owner>/<filename> and lists it on the owner's
+// downloads page. It returns the storage key.
+func (h *Handler) saveExport(ctx context.Context, ownerID, kind, filename, contentType string, body []byte) (string, error) {
+	key := path.Join("exports", ownerID, filename)
+	if err := h.store.Put(ctx, key, contentType, bytes.NewReader(body)); err != nil {
+		return "", err
+	}
+	_, err := h.exports.RecordExport(ctx, service.Export{
+		OwnerID:     ownerID,
+		Kind:        kind,
+		Filename:    filename,
+		StorageKey:  key,
+		ContentType: contentType,
+		SizeBytes:   int64(len(body)),
+	})
+	if err != nil {
+		// don't leave an object nobody can find
+		if derr := h.store.Delete(ctx, key); derr != nil {
+			log.Printf("delete unrecorded export %s: %v", key, derr)
+		}
+		return "", err
+	}
+	return key, nil
+}
+
+// persistRequested reports whether an export request asked to be kept (?persist=1).
+// Without storage it answers 503 and returns ok=false.
+func (h *Handler) persistRequested(w http.ResponseWriter, r *http.Request) (persist, ok bool) {
+	persist, _ = strconv.ParseBool(r.URL.Query().Get("persist"))
+	if persist && h.store == nil {
+		http.Error(w, "export storage not configured", http.StatusServiceUnavailable)
+		return false, false
+	}
+	return persist, true
+}
+
+// storeAndRedirect keeps a generated invoice export for the owner and redirects to
+// a signed URL for it instead of sending it inline.
+func (h *Handler) storeAndRedirect(ctx context.Context, w http.ResponseWriter, r *http.Request, kind, filename, contentType string, body []byte) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	key, err := h.saveExport(ctx, ownerID, kind, filename, contentType, body)
+	if err != nil {
+		h.renderError(w, r, http.StatusBadGateway, "failed to store export", err)
+		return
+	}
+	u, err := h.store.SignedURL(ctx, key, attachmentURLTTL)
+	if err != nil {
+		h.renderError(w, r, http.StatusBadGateway, "failed to sign url", err)
+		return
+	}
+	w.Header().Set("X-Export-Key", key)
+	http.Redirect(w, r, u, http.StatusSeeOther)
+}
+
+// downloadsHandler lists the owner's recent stored exports.
+func (h *Handler) downloadsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	exports, err := h.exports.ListExports(ctx, ownerID, downloadsPageSize)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load exports", err)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":          "Downloads",
+		"Exports":        exports,
+		"StorageEnabled": h.store != nil,
+		"Locale":         h.localeForOwner(ctx, ownerID),
+		"Flash":          h.flash.Pop(w, r),
+		"CSRFToken":      mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "downloads.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// ownedExport loads the {id} export of the current owner. On failure it has already
+// written the error response and returns nil.
+func (h *Handler) ownedExport(ctx context.Context, w http.ResponseWriter, r *http.Request) *service.Export {
+	if h.store == nil {
+		http.Error(w, "export storage not configured", http.StatusServiceUnavailable)
+		return nil
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return nil
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid export id", http.StatusBadRequest)
+		return nil
+	}
+	e, err := h.exports.GetExport(ctx, ownerID, id)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load export", err)
+		return nil
+	}
+	if e == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	return e
+}
+
+// downloadHandler redirects to a short-lived signed URL for one of the owner's
+// stored exports.
+func (h *Handler) downloadHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	e := h.ownedExport(ctx, w, r)
+	if e == nil {
+		return
+	}
+	u, err := h.store.SignedURL(ctx, e.StorageKey, attachmentURLTTL)
+	if err != nil {
+		h.renderError(w, r, http.StatusBadGateway, "failed to sign url", err)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// deleteDownloadHandler removes one of the owner's stored exports and its object.
+func (h *Handler) deleteDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	e := h.ownedExport(ctx, w, r)
+	if e == nil {
+		return
+	}
+	if err := h.exports.DeleteExport(ctx, e.OwnerID, e.ID); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to delete export", err)
+		return
+	}
+	// the row is gone, so a failed object delete only leaves an unreachable file
+	if err := h.store.Delete(ctx, e.StorageKey); err != nil {
+		log.Printf("delete export object %s: %v", e.StorageKey, err)
+	}
+	h.flash.Add(w, r, flash.Info, "removed "+e.Filename)
+	http.Redirect(w, r, "/downloads", http.StatusSeeOther)
+}
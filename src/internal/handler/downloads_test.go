@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestInvoiceExport_Persist(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy:    []service.BOMNode{{PartID: "BOLT", Name: "Bolt", Quantity: 8}},
+		leafTotals: []service.LeafTotal{{PartID: "BOLT", Name: "Bolt", Quantity: 8}},
+	}
+	store := &memStorage{objects: map[string][]byte{}}
+	hs.handler.store = store
+
+	for _, tc := range []struct{ target, kind, prefix, suffix string }{
+		{"/invoice/INV-1/bom.pdf?persist=1", service.ExportPicklist, "picklist-INV-1-", ".pdf"},
+		{"/invoice/INV-1/kit.pdf?persist=1", service.ExportKit, "kit-INV-1-", ".pdf"},
+	} {
+		rec := hs.do(http.MethodGet, tc.target, nil)
+		expectStatus(t, rec, http.StatusSeeOther)
+		key := rec.Header().Get("X-Export-Key")
+		if !strings.HasPrefix(key, "exports/"+testOwnerID+"/"+tc.prefix) || !strings.HasSuffix(key, tc.suffix) {
+			t.Fatalf("%s: X-Export-Key = %q", tc.target, key)
+		}
+		if got := rec.Header().Get("Location"); got != "https://storage.test/"+key {
+			t.Fatalf("%s: Location = %q", tc.target, got)
+		}
+		if len(store.objects[key]) == 0 {
+			t.Fatalf("%s: nothing stored under %q", tc.target, key)
+		}
+		e := hs.store.exports[len(hs.store.exports)-1]
+		if e.OwnerID != testOwnerID || e.Kind != tc.kind || e.StorageKey != key || e.SizeBytes != int64(len(store.objects[key])) {
+			t.Fatalf("%s: recorded %+v", tc.target, e)
+		}
+	}
+}
+
+func TestInvoiceExport_PersistFailures(t *testing.T) {
+	t.Parallel()
+	t.Run("without storage", func(t *testing.T) {
+		hs := newHarness(t)
+		expectStatus(t, hs.do(http.MethodGet, "/invoice/INV-1/kit.pdf?persist=1", nil), http.StatusServiceUnavailable)
+	})
+	t.Run("record fails", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.store.invoices["INV-1"] = resolvedInvoice{perAssy: []service.BOMNode{{PartID: "BOLT", Quantity: 8}}}
+		store := &memStorage{objects: map[string][]byte{}}
+		hs.handler.store = store
+		hs.store.exportErr = errors.New("db down")
+		expectStatus(t, hs.do(http.MethodGet, "/invoice/INV-1/bom.pdf?persist=1", nil), http.StatusBadGateway)
+		if len(store.objects) != 0 {
+			t.Fatalf("unrecorded export left in storage: %v", store.objects)
+		}
+	})
+}
+
+func TestDownloads(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	store := &memStorage{objects: map[string][]byte{"exports/owner-1/kit-INV-1.pdf": []byte("%PDF-")}}
+	hs.handler.store = store
+	hs.store.exports = []service.Export{
+		{ID: 1, OwnerID: testOwnerID, Kind: service.ExportKit, Filename: "kit-INV-1.pdf", StorageKey: "exports/owner-1/kit-INV-1.pdf", SizeBytes: 14541},
+		{ID: 2, OwnerID: "owner-2", Kind: service.ExportBOM, Filename: "bom-INV-9.csv", StorageKey: "exports/owner-2/bom-INV-9.csv"},
+	}
+
+	rec := hs.do(http.MethodGet, "/downloads", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	if !strings.Contains(body, "kit-INV-1.pdf") || !strings.Contains(body, "14.2 KB") || !strings.Contains(body, `href="/downloads/1"`) {
+		t.Fatalf("downloads page missing the owner's export:\n%s", body)
+	}
+	if strings.Contains(body, "bom-INV-9.csv") {
+		t.Fatal("downloads page lists another owner's export")
+	}
+
+	expectRedirect(t, hs.do(http.MethodGet, "/downloads/1", nil), "https://storage.test/exports/owner-1/kit-INV-1.pdf")
+	expectStatus(t, hs.do(http.MethodGet, "/downloads/2", nil), http.StatusNotFound)
+	expectStatus(t, hs.do(http.MethodPost, "/downloads/2/delete", url.Values{}), http.StatusNotFound)
+
+	rec = hs.do(http.MethodPost, "/downloads/1/delete", url.Values{})
+	expectRedirect(t, rec, "/downloads")
+	if len(hs.store.exports) != 1 || len(store.objects) != 0 {
+		t.Fatalf("after delete: exports %v, objects %v", hs.store.exports, store.objects)
+	}
+}
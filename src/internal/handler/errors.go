@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/validate"
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// errorStatus maps err to an HTTP status and the text to show the user. dep names
+// the dependency the error came from ("Xero", "Supabase") in messages about it.
+// Errors without a known type keep their own text: 502 when the dependency failed or
+// answered with an error, 500 otherwise.
+func errorStatus(dep string, err error) (int, string) {
+	var (
+		xeroInvalid *xero.ValidationError
+		invalid     *service.ValidationError
+		fields      validate.Errors
+		apiErr      *xero.APIError
+		netErr      *url.Error
+	)
+	switch {
+	case errors.Is(err, breaker.ErrOpen):
+		return http.StatusServiceUnavailable, unavailableMessage(dep)
+	case errors.Is(err, service.ErrConsentRevoked):
+		return http.StatusForbidden, reconnectMessage
+	case errors.Is(err, service.ErrNoConnection):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, xero.ErrAuthExpired):
+		return http.StatusForbidden, "Xero refused this connection's access. Try again, or use Connect to Xero if it keeps happening."
+	case errors.Is(err, xero.ErrRateLimited):
+		return http.StatusTooManyRequests, "Xero is limiting how often this organisation can be called. Please try again in a minute."
+	case errors.As(err, &xeroInvalid):
+		return http.StatusUnprocessableEntity, "Xero rejected the request: " + strings.Join(xeroInvalid.Messages, "; ")
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest, invalid.Message
+	case errors.As(err, &fields):
+		return http.StatusBadRequest, fields.Error()
+	case errors.Is(err, xero.ErrNotFound):
+		return http.StatusNotFound, "Not found in " + dep
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, service.ErrShoppingConflict):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, dep + " did not answer in time. Please try again."
+	case errors.As(err, &apiErr), errors.As(err, &netErr):
+		return http.StatusBadGateway, err.Error()
+	}
+	return http.StatusInternalServerError, err.Error()
+}
+
+// xeroError answers err from a Xero call, or from loading the credentials for one:
+// the reconnect banner when consent was revoked, the unavailable page when Xero's
+// circuit is open, else the error page with the status errorStatus gives. what (e.g.
+// "invoice search failed") goes before the message; for a 500 or 502 it replaces it,
+// as the raw error is only logged.
+func (h *Handler) xeroError(w http.ResponseWriter, r *http.Request, what string, err error) {
+	if h.redirectToReconnect(w, r, err) || h.renderUnavailable(w, r, "Xero", err) {
+		return
+	}
+	status, msg := errorStatus("Xero", err)
+	switch {
+	case status == http.StatusInternalServerError || status == http.StatusBadGateway:
+		msg = what
+	case what != "":
+		msg = what + ": " + msg
+	}
+	h.renderError(w, r, status, msg, err)
+}
+
+// genericErrorMessage is shown for a server error whose caller gave no message.
+const genericErrorMessage = "Something went wrong on our side."
+
+// problem is an RFC 7807 problem details body, with the error reference as an
+// extension member.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Reference string `json:"reference"`
+}
+
+// errorRef returns a short random reference tying an error response to its log line.
+func errorRef() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// renderError answers status with msg: application/problem+json when the client asked
+// for JSON, else the error page (plain text without templates). msg is shown to the
+// user, so it must not carry err's text for a server error; err is logged with a
+// reference the response also shows, so a report can be matched to the log line.
+func (h *Handler) renderError(w http.ResponseWriter, r *http.Request, status int, msg string, err error) {
+	ref := errorRef()
+	if msg == "" {
+		msg = genericErrorMessage
+	}
+	log.Printf("error ref=%s request_id=%s %s %s: status=%d %s: %v",
+		ref, middleware.GetReqID(r.Context()), r.Method, r.URL.Path, status, msg, err)
+
+	switch {
+	case strings.Contains(r.Header.Get("Accept"), "application/json"):
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(status),
+			Status:    status,
+			Detail:    msg,
+			Instance:  r.URL.Path,
+			Reference: ref,
+		})
+	case h.templates == nil:
+		http.Error(w, msg+" (reference "+ref+")", status)
+	default:
+		back := ""
+		if r.Method == http.MethodGet {
+			back = r.URL.RequestURI()
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		_ = h.templates.ExecuteTemplate(w, "error.html", map[string]any{
+			"Title":     http.StatusText(status),
+			"Message":   msg,
+			"Reference": ref,
+			"Back":      back,
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/validate"
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestErrorStatus(t *testing.T) {
+	t.Parallel()
+	wrap := func(err error) error { return fmt.Errorf("resolve invoice INV-1: %w", err) }
+	tests := []struct {
+		name string
+		err  error
+		want int
+		msg  string // "" = not checked
+	}{
+		{"circuit open", wrap(&breaker.OpenError{Host: "api.xero.com"}), http.StatusServiceUnavailable, unavailableMessage("Xero")},
+		{"consent revoked", wrap(service.ErrConsentRevoked), http.StatusForbidden, reconnectMessage},
+		{"no connection", service.ErrNoConnection, http.StatusNotFound, service.ErrNoConnection.Error()},
+		{"xero unauthorized", wrap(&xero.APIError{Op: "list items", Status: http.StatusUnauthorized}), http.StatusForbidden, ""},
+		{"xero rate limited", wrap(&xero.APIError{Op: "list items", Status: http.StatusTooManyRequests}), http.StatusTooManyRequests, ""},
+		{"xero not found", wrap(&xero.APIError{Op: "get item", Status: http.StatusNotFound}), http.StatusNotFound, "Not found in Xero"},
+		{"xero validation", wrap(&xero.APIError{Op: "create purchase order", Status: http.StatusBadRequest,
+			Validation: &xero.ValidationError{Messages: []string{"Account code '999' is not a valid code"}}}),
+			http.StatusUnprocessableEntity, "Xero rejected the request: Account code '999' is not a valid code"},
+		{"xero failure", wrap(&xero.APIError{Op: "list items", Status: http.StatusInternalServerError, Body: "oops"}), http.StatusBadGateway, ""},
+		{"service validation", wrap(&service.ValidationError{Field: "name", Message: "workspace name is required"}), http.StatusBadRequest, "workspace name is required"},
+		{"form validation", validate.Errors{{Field: "qty", Message: "Quantity is required"}}, http.StatusBadRequest, "Quantity is required"},
+		{"service not found", service.ErrMappingNotFound, http.StatusNotFound, "supplier mapping not found"},
+		{"shopping conflict", &service.ShoppingConflictError{ListIDs: []int{2}}, http.StatusConflict, ""},
+		{"timeout", wrap(context.DeadlineExceeded), http.StatusGatewayTimeout, ""},
+		{"other", errors.New("db down"), http.StatusInternalServerError, "db down"},
+	}
+	for _, tt := range tests {
+		status, msg := errorStatus("Xero", tt.err)
+		if status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.want)
+		}
+		if tt.msg != "" && msg != tt.msg {
+			t.Errorf("%s: message = %q, want %q", tt.name, msg, tt.msg)
+		}
+	}
+}
+
+func TestRenderError_HidesServerErrors(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.creds.err = errors.New("dial tcp 10.1.2.3:5432: connection refused")
+
+	t.Run("page", func(t *testing.T) {
+		rec := hs.do(http.MethodPost, "/purchase-orders/reconcile", url.Values{})
+		expectStatus(t, rec, http.StatusInternalServerError)
+		body := rec.Body.String()
+		if strings.Contains(body, "10.1.2.3") || !strings.Contains(body, genericErrorMessage) || !strings.Contains(body, "quote reference") {
+			t.Fatalf("unexpected page: %s", body)
+		}
+	})
+	t.Run("problem+json", func(t *testing.T) {
+		rec := hs.do(http.MethodPost, "/purchase-orders/reconcile", url.Values{}, func(r *http.Request) {
+			r.Header.Set("Accept", "application/json")
+		})
+		expectStatus(t, rec, http.StatusInternalServerError)
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Fatalf("Content-Type = %q", ct)
+		}
+		var p problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if p.Status != http.StatusInternalServerError || p.Title != "Internal Server Error" || p.Detail != genericErrorMessage ||
+			p.Instance != "/purchase-orders/reconcile" || len(p.Reference) != 8 {
+			t.Fatalf("problem = %+v", p)
+		}
+	})
+}
+
+func TestRenderError_ShowsClientErrors(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.creds.err = fmt.Errorf("refresh: %w", &xero.APIError{Op: "refresh token", Status: http.StatusTooManyRequests})
+
+	rec := hs.do(http.MethodPost, "/purchase-orders/reconcile", url.Values{}, func(r *http.Request) {
+		r.Header.Set("Accept", "application/json")
+	})
+	expectStatus(t, rec, http.StatusTooManyRequests)
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.Detail, "limiting how often") {
+		t.Fatalf("detail = %q", p.Detail)
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/notify"
+)
+
+// createdPO is one purchase order raised by createPurchaseOrdersHandler, for the
+// po_created event and the links to it.
+type createdPO struct {
+	AccountNumber string
+	Lines         int
+	XeroPOID      string // "" when Xero did not return one
+}
+
+// postPOsCreated posts the po_created event for the purchase orders one "Create
+// Purchase Orders" raised in Xero.
+func (h *Handler) postPOsCreated(ownerID string, pos []createdPO) {
+	if len(pos) == 0 {
+		return
+	}
+	var b strings.Builder
+	for _, po := range pos {
+		fmt.Fprintf(&b, "- %s: %d line(s)\n", po.AccountNumber, po.Lines)
+	}
+	h.events.Post(notify.EventPOCreated, "", notify.Message{
+		Subject: fmt.Sprintf("%d purchase order(s) created in Xero", len(pos)),
+		Text:    b.String(),
+	})
+}
+
+// postBOMUnresolved posts the bom_unresolved event; the same problem is posted once
+// per quiet period.
+func (h *Handler) postBOMUnresolved(ownerID, problem string) {
+	h.events.Post(notify.EventBOMUnresolved, ownerID+"/"+problem, notify.Message{
+		Subject: "Parts list could not be resolved",
+		Text:    problem,
+	})
+}
+
+// postConnectionBroken posts the connection_broken event, once per owner per quiet
+// period.
+func (h *Handler) postConnectionBroken(ownerID string) {
+	h.events.Post(notify.EventConnectionBroken, ownerID, notify.Message{
+		Subject: "Xero connection needs reconnecting",
+		Text:    reconnectMessage,
+	})
+}
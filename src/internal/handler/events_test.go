@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/notify"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// recordedEvents captures what Events posts.
+type recordedEvents struct {
+	mu   sync.Mutex
+	msgs []notify.Message
+}
+
+func (r *recordedEvents) Notify(ctx context.Context, m notify.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, m)
+	return nil
+}
+
+// withEvents makes hs post every event kind to the returned recorder.
+func withEvents(hs *harness) (*recordedEvents, *notify.Events) {
+	rec := &recordedEvents{}
+	hs.handler.events = notify.NewEvents(rec, notify.EventKinds, time.Hour)
+	return rec, hs.handler.events
+}
+
+func TestEvents_POsCreated(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	got, events := withEvents(hs)
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+
+	expectRedirect(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}), "/")
+	events.Wait()
+	if len(got.msgs) != 1 || got.msgs[0].Subject != "1 purchase order(s) created in Xero" || got.msgs[0].Text != "- SUP-1: 1 line(s)\n" {
+		t.Fatalf("events = %+v", got.msgs)
+	}
+}
+
+func TestEvents_BOMUnresolved(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	got, events := withEvents(hs)
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.groupErr = fmt.Errorf("no contact mapping found for item BOLT")
+
+	// the preview and the create both hit the mapping failure; it is posted once
+	expectStatus(t, hs.do(http.MethodGet, "/purchase-orders/preview", nil), http.StatusOK)
+	expectRedirect(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}), "/")
+	events.Wait()
+	if len(got.msgs) != 1 || !strings.Contains(got.msgs[0].Text, "no contact mapping found for item BOLT") {
+		t.Fatalf("events = %+v", got.msgs)
+	}
+}
+
+func TestEvents_ConnectionBroken(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	got, events := withEvents(hs)
+	hs.creds.err = service.ErrConsentRevoked
+
+	expectRedirect(t, hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}}), "/")
+	expectRedirect(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}), "/")
+	events.Wait()
+	if len(got.msgs) != 1 || got.msgs[0].Subject != "Xero connection needs reconnecting" {
+		t.Fatalf("events = %+v", got.msgs)
+	}
+}
@@ -0,0 +1,23 @@
+package handler
+
+import "net/http"
+
+// isHTMXRequest reports whether r was sent by htmx and expects an HTML fragment
+// rather than a full page or redirect.
+func isHTMXRequest(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// renderFragment executes a single (partial) template as the whole response.
+func (h *Handler) renderFragment(w http.ResponseWriter, name string, data any) {
+	if h.templates == nil {
+		http.Error(w, "templates not loaded", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// the response depends on HX-Request, so caches must key on it
+	w.Header().Add("Vary", "HX-Request")
+	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
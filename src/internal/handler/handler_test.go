@@ -0,0 +1,973 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	"github.com/hwalton/xero-invoice-orderer/internal/frontend"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/supabasetoolbox"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// Test harness: the router is built with fakes for auth and every database-backed
+// store, and an httptest server standing in for Xero. Requests made with
+// harness.do carry a valid session and CSRF token unless asked otherwise.
+
+const (
+	testOwnerID     = "owner-1"
+	testAdminToken  = "admin-access"
+	testAdminID     = "admin-1"
+	testAccessToken = "session-token"
+	testCSRFToken   = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA" // 32 zero bytes, base64url
+	testNonce       = "nonce-1"
+)
+
+// testRetry keeps Xero retries in handler tests short.
+var testRetry = xero.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+type harness struct {
+	t       *testing.T
+	router  http.Handler
+	handler *Handler
+	store   *fakeStore
+	creds   *fakeCredentials
+	// xero serves the Xero API, identity and login calls made by handlers, and the
+	// Supabase auth API
+	xero *http.ServeMux
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	xeroMux := http.NewServeMux()
+	ts := httptest.NewServer(xeroMux)
+	t.Cleanup(ts.Close)
+
+	templates, err := frontend.BuildTemplates()
+	if err != nil {
+		t.Fatalf("build templates: %v", err)
+	}
+
+	store := newFakeStore()
+	creds := &fakeCredentials{creds: service.XeroCredentials{TenantID: "tenant-1", AccessToken: "xero-access"}}
+	h := &Handler{
+		cfg: &config.Config{Xero: config.XeroConfig{
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			RedirectURL:  "http://localhost/xero/callback",
+			StateTTL:     10 * time.Minute,
+		}},
+		auth:        fakeAuth{},
+		xc:          &xero.Client{BaseURL: ts.URL, IdentityURL: ts.URL, LoginURL: ts.URL, HTTPClient: ts.Client(), Retry: &testRetry},
+		templates:   templates,
+		flash:       flash.New("test-secret"),
+		tokens:      creds,
+		states:      store,
+		conns:       store,
+		invoices:    store,
+		orders:      store,
+		settings:    store,
+		invites:     store,
+		views:       store,
+		exports:     store,
+		lists:       store,
+		approvals:   store,
+		proposals:   store,
+		prepared:    store,
+		creditNotes: store,
+		releases:    store,
+		prices:      store,
+		webhooks:    store,
+		diagnostics: store,
+
+		workspaces: store,
+		progress:   cache.NewMemory(),
+
+		supabase: supabasetoolbox.New(ts.URL, "anon-key", ts.Client()),
+		admin:    supabasetoolbox.New(ts.URL, "service-key", ts.Client()),
+	}
+	return &harness{t: t, router: h.routes(), handler: h, store: store, creds: creds, xero: xeroMux}
+}
+
+// reqOption adjusts a request built by harness.do.
+type reqOption func(*http.Request)
+
+// withNonce sends the OAuth nonce cookie testNonce, as after /xero/connect.
+func withNonce(r *http.Request) {
+	r.AddCookie(&http.Cookie{Name: oauthNonceCookie, Value: testNonce})
+}
+
+// anonymous drops the session cookie.
+func anonymous(r *http.Request) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if c.Name != "access_token" {
+			r.AddCookie(c)
+		}
+	}
+}
+
+// asAdmin signs the request in as testAdminID instead.
+func asAdmin(r *http.Request) {
+	anonymous(r)
+	r.AddCookie(&http.Cookie{Name: "access_token", Value: testAdminToken})
+}
+
+// htmx marks the request as coming from htmx.
+func htmx(r *http.Request) { r.Header.Set("HX-Request", "true") }
+
+// do sends method/target through the router. form, when non-nil, is sent as an
+// urlencoded body with the CSRF field added.
+func (hs *harness) do(method, target string, form url.Values, opts ...reqOption) *httptest.ResponseRecorder {
+	hs.t.Helper()
+	var r *http.Request
+	if form != nil {
+		form.Set(mid.CSRFFormField, testCSRFToken)
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	r.AddCookie(&http.Cookie{Name: "access_token", Value: testAccessToken})
+	r.AddCookie(&http.Cookie{Name: mid.CSRFCookieName, Value: testCSRFToken})
+	for _, o := range opts {
+		o(r)
+	}
+	rec := httptest.NewRecorder()
+	hs.router.ServeHTTP(rec, r)
+	return rec
+}
+
+// flashMessages decodes the flash cookie set on the response.
+func (hs *harness) flashMessages(rec *httptest.ResponseRecorder) []flash.Message {
+	hs.t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	return hs.handler.flash.Pop(httptest.NewRecorder(), r)
+}
+
+// invoiceView returns the invoice results the response saved for the home page.
+func (hs *harness) invoiceView(rec *httptest.ResponseRecorder) invoiceView {
+	hs.t.Helper()
+	var id string
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == invoiceViewCookie {
+			id = c.Value
+		}
+	}
+	if id == "" {
+		hs.t.Fatalf("%s cookie not set", invoiceViewCookie)
+	}
+	var v invoiceView
+	found, err := hs.store.TakeViewState(context.Background(), testOwnerID, id, &v)
+	if err != nil || !found {
+		hs.t.Fatalf("take view state %q: found=%v err=%v", id, found, err)
+	}
+	return v
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func expectRedirect(t *testing.T, rec *httptest.ResponseRecorder, want string) {
+	t.Helper()
+	if rec.Code != http.StatusSeeOther && rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want redirect; body: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Location"); got != want {
+		t.Fatalf("Location = %q, want %q", got, want)
+	}
+}
+
+// fakeAuth accepts testAccessToken as testOwnerID and testAdminToken as testAdminID,
+// who has the admin role.
+type fakeAuth struct{}
+
+func (fakeAuth) Authenticate(r *http.Request) (map[string]interface{}, bool) {
+	switch r.Header.Get("Authorization") {
+	case "Bearer " + testAccessToken:
+		return map[string]interface{}{"sub": testOwnerID}, true
+	case "Bearer " + testAdminToken:
+		return map[string]interface{}{"sub": testAdminID, "app_metadata": map[string]interface{}{"role": "admin"}}, true
+	}
+	return nil, false
+}
+
+// fakeCredentials returns fixed credentials, or err when set.
+type fakeCredentials struct {
+	creds service.XeroCredentials
+	err   error
+}
+
+func (f *fakeCredentials) CredentialsForOwner(ctx context.Context, ownerID string) (service.XeroCredentials, error) {
+	if f.err != nil {
+		return service.XeroCredentials{}, f.err
+	}
+	return f.creds, nil
+}
+
+// storedConnection is a connection persisted through fakeStore.
+type storedConnection struct {
+	OwnerID, TenantID, TenantName string
+	AccessToken, RefreshToken     string
+}
+
+// resolvedInvoice is what fakeStore.ResolveInvoice returns for one invoice number.
+type resolvedInvoice struct {
+	perAssy    []service.BOMNode
+	leafTotals []service.LeafTotal
+	msg        string
+}
+
+// fakeStore is an in-memory implementation of every store interface.
+type fakeStore struct {
+	mu sync.Mutex
+
+	states      map[string]oauthState
+	connections map[string]*storedConnection
+
+	invoices  map[string]resolvedInvoice
+	parts     map[string]xero.Part             // local parts table
+	builds    []string                         // invoice numbers recorded as builds
+	snapshots map[string][]service.BOMSnapshot // invoice number -> snapshots, newest first
+	resolve   service.ResolveOptions           // opts of the last ResolveInvoice
+
+	shopping       []service.ShoppingRow
+	tracked        map[string]bool // items with a supplier mapping
+	grouped        map[string][]service.ContactItem
+	groupErr       error
+	choices        map[string]string // supplier choices of the last GroupShoppingItemsByContact
+	purchaseOrders []service.PurchaseOrderRecord
+	ordered        []int
+	changed        []int             // list ids RecordPurchaseOrders finds changed since read
+	recordErr      error             // fails RecordPurchaseOrders
+	accountCodes   map[string]string // item ID -> default account code
+	settings       map[string]service.OwnerSettings
+	invites        map[string]int    // code -> uses left
+	views          map[string][]byte // view state id -> JSON
+	exports        []service.Export
+	exportErr      error                       // fails RecordExport
+	shoppingList   []service.ShoppingListEntry // served by ListShoppingList, in page order
+	audit          []service.AuditRecord
+	listReq        service.PageRequest               // request of the last list call
+	approvals      []service.POApproval              // po_approvals, by id - 1
+	proposals      []service.BOMProposal             // bom_proposals, by id - 1
+	parentChild    map[[2]string]float64             // parent, child -> quantity, as approved proposals leave it
+	prepared       []service.PreparedInvoice         // served by ListPreparedInvoices
+	creditPlans    map[string]service.CreditNotePlan // credit note number -> plan
+	creditApplied  []service.CreditNotePlan          // plans ApplyCreditNote applied
+	releasedAt     map[string]int64                  // owner -> last order release
+	releasedBy     []string                          // who RecordOrderRelease recorded, in order
+	mapped         map[[2]string]bool                // item, supplier pairs MappedSuppliers reports
+	priceImports   []csvimport.PriceSource           // uploads ImportSupplierPrices stored
+	agreedPrices   map[[2]string]float64             // item, supplier -> price ImportSupplierPrices applied
+	pushErr        error                             // fails PushXeroPurchasePrices
+	pushes         int                               // PushXeroPurchasePrices calls
+	apiErrors      []service.APICallError            // served by ListAPICallErrors, newest first
+	workspaces     map[string]*fakeWorkspace
+	seenEvents     map[string]bool // ids of events RecordWebhookEvents has seen
+	tenantPolls    chan string     // tenants PollTenantInvoices polled
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		states:       map[string]oauthState{},
+		connections:  map[string]*storedConnection{},
+		invoices:     map[string]resolvedInvoice{},
+		parts:        map[string]xero.Part{},
+		snapshots:    map[string][]service.BOMSnapshot{},
+		settings:     map[string]service.OwnerSettings{},
+		accountCodes: map[string]string{},
+		invites:      map[string]int{},
+		views:        map[string][]byte{},
+		workspaces:   map[string]*fakeWorkspace{},
+		parentChild:  map[[2]string]float64{},
+		creditPlans:  map[string]service.CreditNotePlan{},
+		releasedAt:   map[string]int64{},
+		mapped:       map[[2]string]bool{},
+		agreedPrices: map[[2]string]float64{},
+		seenEvents:   map[string]bool{},
+		tenantPolls:  make(chan string, 10),
+	}
+}
+
+// oauthState is a stored connect flow: the owner and the nonce it is bound to.
+type oauthState struct {
+	owner string
+	nonce string
+}
+
+func (s *fakeStore) CreateOAuthState(ctx context.Context, state, ownerID, nonce string, ttlSeconds int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state] = oauthState{owner: ownerID, nonce: nonce}
+	return nil
+}
+
+func (s *fakeStore) ConsumeOAuthState(ctx context.Context, state, nonce string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[state]
+	delete(s.states, state)
+	if !ok || st.nonce != nonce {
+		return "", false, nil
+	}
+	return st.owner, true, nil
+}
+
+func (s *fakeStore) UpsertConnection(ctx context.Context, ownerID, tenantID, accessToken, refreshToken string, expiresInSeconds int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.connections[ownerID+"/"+tenantID]
+	if c == nil {
+		c = &storedConnection{OwnerID: ownerID, TenantID: tenantID}
+		s.connections[ownerID+"/"+tenantID] = c
+	}
+	c.AccessToken, c.RefreshToken = accessToken, refreshToken
+	return nil
+}
+
+func (s *fakeStore) SetConnectionTenantName(ctx context.Context, ownerID, tenantID, tenantName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c := s.connections[ownerID+"/"+tenantID]; c != nil {
+		c.TenantName = tenantName
+	}
+	return nil
+}
+
+func (s *fakeStore) ResolveInvoice(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials, invoiceNumber string, opts service.ResolveOptions) ([]service.BOMNode, []service.LeafTotal, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolve = opts
+	inv, ok := s.invoices[invoiceNumber]
+	if !ok {
+		return nil, nil, "No items found on invoice " + invoiceNumber, nil
+	}
+	return inv.perAssy, inv.leafTotals, inv.msg, nil
+}
+
+func (s *fakeStore) UpsertBuildFromBOM(ctx context.Context, ownerID, invoiceNumber string, perAssy []service.BOMNode) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.builds = append(s.builds, invoiceNumber)
+	return len(s.builds), nil
+}
+
+func (s *fakeStore) SaveBOMSnapshot(ctx context.Context, ownerID, invoiceNumber string, totals []service.LeafTotal) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parts := service.SnapshotParts(totals)
+	prev := s.snapshots[invoiceNumber]
+	if len(prev) > 0 && len(service.DiffBOMSnapshots(prev[0].Parts, parts)) == 0 {
+		return false, nil
+	}
+	snap := service.BOMSnapshot{ID: len(prev) + 1, InvoiceNumber: invoiceNumber, Parts: parts, CreatedAt: int64(1_700_000_000 + len(prev))}
+	s.snapshots[invoiceNumber] = append([]service.BOMSnapshot{snap}, prev...)
+	return true, nil
+}
+
+func (s *fakeStore) ListBOMSnapshots(ctx context.Context, ownerID, invoiceNumber string) ([]service.BOMSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshots[invoiceNumber], nil
+}
+
+func (s *fakeStore) GetPart(ctx context.Context, partID string) (xero.Part, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.parts[partID]
+	return p, ok, nil
+}
+
+func (s *fakeStore) GetUnorderedShoppingRows(ctx context.Context, ownerID string) ([]service.ShoppingRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shopping, nil
+}
+
+func (s *fakeStore) GetTrackedItemCodes(ctx context.Context, ownerID string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tracked, nil
+}
+
+func (s *fakeStore) GroupShoppingItemsByContact(ctx context.Context, ownerID string, rows []service.ShoppingRow, choices map[string]string) (map[string][]service.ContactItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.choices = choices
+	return s.grouped, s.groupErr
+}
+
+func (s *fakeStore) RecordPurchaseOrders(ctx context.Context, ownerID string, pos []service.PurchaseOrderRecord, versions map[int]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recordErr != nil {
+		return s.recordErr // nothing stored, as the transaction rolled back
+	}
+	s.purchaseOrders = append(s.purchaseOrders, pos...)
+	var stale []int
+	for _, id := range slices.Sorted(maps.Keys(versions)) {
+		if slices.Contains(s.changed, id) {
+			stale = append(stale, id)
+			continue
+		}
+		s.ordered = append(s.ordered, id)
+	}
+	if len(stale) > 0 {
+		return &service.ShoppingConflictError{ListIDs: stale}
+	}
+	return nil
+}
+
+func (s *fakeStore) GetItemAccountCodes(ctx context.Context, itemIDs []string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string]string{}
+	for _, id := range itemIDs {
+		if code, ok := s.accountCodes[id]; ok {
+			out[id] = code
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) SetItemAccountCodes(ctx context.Context, codes map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, code := range codes {
+		if code == "" {
+			delete(s.accountCodes, id)
+		} else {
+			s.accountCodes[id] = code
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) GetOwnerSettings(ctx context.Context, ownerID string) (service.OwnerSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if settings, ok := s.settings[ownerID]; ok {
+		return settings, nil
+	}
+	return service.DefaultOwnerSettings(), nil
+}
+
+func (s *fakeStore) SaveOwnerSettings(ctx context.Context, ownerID string, settings service.OwnerSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[ownerID] = settings
+	return nil
+}
+
+func (s *fakeStore) EnsureOwnerSettings(ctx context.Context, ownerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.settings[ownerID]; !ok {
+		s.settings[ownerID] = service.DefaultOwnerSettings()
+	}
+	return nil
+}
+
+func (s *fakeStore) ConsumeInviteCode(ctx context.Context, code string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code = service.NormalizeInviteCode(code)
+	if s.invites[code] <= 0 {
+		return false, nil
+	}
+	s.invites[code]--
+	return true, nil
+}
+
+func (s *fakeStore) ReleaseInviteCode(ctx context.Context, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invites[service.NormalizeInviteCode(code)]++
+	return nil
+}
+
+func (s *fakeStore) SaveViewState(ctx context.Context, ownerID string, v any, ttl time.Duration) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("view-%d", len(s.views)+1)
+	s.views[ownerID+"/"+id] = b
+	return id, nil
+}
+
+func (s *fakeStore) TakeViewState(ctx context.Context, ownerID, id string, dst any) (bool, error) {
+	s.mu.Lock()
+	b, ok := s.views[ownerID+"/"+id]
+	delete(s.views, ownerID+"/"+id)
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(b, dst)
+}
+
+// fakePage returns req's page of items, which are already in the requested order.
+func fakePage[T any](items []T, spec service.ListSpec, req service.PageRequest) service.Page[T] {
+	req = spec.Normalize(req)
+	page := service.Page[T]{Items: []T{}, Page: req.Page, PerPage: req.PerPage, Total: len(items), Sort: req.SortParam(), Query: req.Query}
+	if off := req.Offset(); off < len(items) {
+		page.Items = append(page.Items, items[off:min(off+req.PerPage, len(items))]...)
+	}
+	return page
+}
+
+func (s *fakeStore) ListShoppingList(ctx context.Context, ownerID string, includeArchived bool, req service.PageRequest) (service.Page[service.ShoppingListEntry], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listReq = req
+	var rows []service.ShoppingListEntry
+	for _, e := range s.shoppingList {
+		if includeArchived || e.ArchivedAt == nil {
+			rows = append(rows, e)
+		}
+	}
+	return fakePage(rows, service.ShoppingListSpec, req), nil
+}
+
+func (s *fakeStore) ListPurchaseOrders(ctx context.Context, ownerID string, req service.PageRequest) (service.Page[service.PurchaseOrderRecord], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listReq = req
+	return fakePage(s.purchaseOrders, service.PurchaseOrderListSpec, req), nil
+}
+
+func (s *fakeStore) ListAuditLog(ctx context.Context, ownerID string, req service.PageRequest) (service.Page[service.AuditRecord], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listReq = req
+	return fakePage(s.audit, service.AuditLogSpec, req), nil
+}
+
+func (s *fakeStore) RequestPOApproval(ctx context.Context, ownerID, requestedBy string, batch service.POBatch) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.approvals {
+		if a.Status == service.ApprovalPending {
+			return 0, service.ErrApprovalPending
+		}
+	}
+	id := len(s.approvals) + 1
+	s.approvals = append(s.approvals, service.POApproval{ID: id, RequestedBy: requestedBy, Status: service.ApprovalPending, Total: batch.Total(), Batch: batch})
+	return id, nil
+}
+
+func (s *fakeStore) GetPOApproval(ctx context.Context, ownerID string, id int) (service.POApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.approvals) {
+		return service.POApproval{}, fmt.Errorf("purchase order batch %d: %w", id, service.ErrNotFound)
+	}
+	return s.approvals[id-1], nil
+}
+
+func (s *fakeStore) ListPOApprovals(ctx context.Context, ownerID string, limit int) ([]service.POApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.approvals), nil
+}
+
+func (s *fakeStore) DecidePOApproval(ctx context.Context, ownerID string, id int, decidedBy string, approve bool) (service.POApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.approvals) {
+		return service.POApproval{}, fmt.Errorf("purchase order batch %d: %w", id, service.ErrNotFound)
+	}
+	a := &s.approvals[id-1]
+	switch {
+	case a.Status != service.ApprovalPending:
+		return service.POApproval{}, service.ErrApprovalDecided
+	case a.RequestedBy == decidedBy:
+		return service.POApproval{}, service.ErrSelfApproval
+	}
+	a.Status, a.DecidedBy = service.ApprovalRejected, decidedBy
+	if approve {
+		a.Status = service.ApprovalApproved
+	}
+	return *a, nil
+}
+
+func (s *fakeStore) ProposeParentChild(ctx context.Context, ownerID, proposedBy, filename string, rows []csvimport.BOMRow) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := len(s.proposals) + 1
+	s.proposals = append(s.proposals, service.BOMProposal{ID: id, ProposedBy: proposedBy, Filename: filename, Status: service.ApprovalPending, Rows: rows})
+	return id, nil
+}
+
+func (s *fakeStore) ListBOMProposals(ctx context.Context, ownerID string, limit int) ([]service.BOMProposal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.proposals), nil
+}
+
+func (s *fakeStore) GetBOMProposal(ctx context.Context, ownerID string, id int) (service.BOMProposal, []service.BOMRowChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.proposals) {
+		return service.BOMProposal{}, nil, fmt.Errorf("parts list change %d: %w", id, service.ErrNotFound)
+	}
+	p := s.proposals[id-1]
+	return p, service.DiffBOMRows(s.parentChild, p.Rows), nil
+}
+
+func (s *fakeStore) DecideBOMProposal(ctx context.Context, ownerID string, id int, decidedBy string, approve bool) (service.BOMProposal, csvimport.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 1 || id > len(s.proposals) {
+		return service.BOMProposal{}, csvimport.Result{}, fmt.Errorf("parts list change %d: %w", id, service.ErrNotFound)
+	}
+	p := &s.proposals[id-1]
+	switch {
+	case p.Status != service.ApprovalPending:
+		return service.BOMProposal{}, csvimport.Result{}, service.ErrProposalDecided
+	case p.ProposedBy == decidedBy:
+		return service.BOMProposal{}, csvimport.Result{}, service.ErrSelfReview
+	}
+	var res csvimport.Result
+	p.Status, p.DecidedBy = service.ApprovalRejected, decidedBy
+	if approve {
+		p.Status = service.ApprovalApproved
+		for _, c := range service.DiffBOMRows(s.parentChild, p.Rows) {
+			switch c.Kind() {
+			case "added":
+				res.Inserted++
+			case "changed":
+				res.Updated++
+			default:
+				res.Unchanged++
+			}
+			s.parentChild[[2]string{c.Parent, c.Child}] = c.Proposed
+		}
+	}
+	return *p, res, nil
+}
+
+func (s *fakeStore) ListPreparedInvoices(ctx context.Context, ownerID string, limit int) ([]service.PreparedInvoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.prepared[:min(limit, len(s.prepared))]), nil
+}
+
+func (s *fakeStore) RecordWebhookEvents(ctx context.Context, events []xero.WebhookEvent, maxAge time.Duration) (service.WebhookReceipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rec service.WebhookReceipt
+	for _, e := range events {
+		switch {
+		case time.Since(e.EventDateUTC.Time) > maxAge:
+			rec.Stale++
+		case s.seenEvents[e.ID()]:
+			rec.Seen++
+		default:
+			s.seenEvents[e.ID()] = true
+			rec.Fresh = append(rec.Fresh, e)
+		}
+	}
+	return rec, nil
+}
+
+func (s *fakeStore) PollTenantInvoices(ctx context.Context, xc *xero.Client, tenantID string) (service.InvoicePollResult, error) {
+	s.tenantPolls <- tenantID
+	return service.InvoicePollResult{Seen: 1, Ready: 1}, nil
+}
+
+func (s *fakeStore) ListAllConnections(ctx context.Context) ([]service.ConnectionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []service.ConnectionInfo
+	for key, c := range s.connections {
+		out = append(out, service.ConnectionInfo{
+			ID: key, OwnerID: c.OwnerID, TenantID: c.TenantID, TenantName: c.TenantName,
+			ExpiresAt: time.Now().Add(30 * time.Minute).Unix(), HasRefreshToken: c.RefreshToken != "",
+		})
+	}
+	slices.SortFunc(out, func(a, b service.ConnectionInfo) int { return strings.Compare(a.ID, b.ID) })
+	return out, nil
+}
+
+func (s *fakeStore) ConnectionCredentials(ctx context.Context, conn service.ConnectionInfo) (service.XeroCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.connections[conn.ID]
+	if c == nil || c.RefreshToken == "" {
+		return service.XeroCredentials{}, service.ErrConsentRevoked
+	}
+	return service.XeroCredentials{TenantID: c.TenantID, AccessToken: c.AccessToken}, nil
+}
+
+func (s *fakeStore) ListAPICallErrors(ctx context.Context, tenantID string, limit int) ([]service.APICallError, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []service.APICallError
+	for _, e := range s.apiErrors {
+		if (tenantID == "" || e.TenantID == tenantID) && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) PlanCreditNote(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials, number, invoice string) (service.CreditNotePlan, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan, ok := s.creditPlans[number]
+	if !ok {
+		return service.CreditNotePlan{Number: number}, "Credit note " + number + " was not found in Xero", nil
+	}
+	for _, p := range s.creditApplied {
+		if p.Number == number {
+			plan.AppliedAt = 1
+		}
+	}
+	return plan, "", nil
+}
+
+func (s *fakeStore) ApplyCreditNote(ctx context.Context, ownerID, appliedBy string, plan service.CreditNotePlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.creditApplied {
+		if p.Number == plan.Number {
+			return service.ErrCreditNoteApplied
+		}
+	}
+	s.creditApplied = append(s.creditApplied, plan)
+	return nil
+}
+
+func (s *fakeStore) GetOrderRelease(ctx context.Context, ownerID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.releasedAt[ownerID], nil
+}
+
+func (s *fakeStore) RecordOrderRelease(ctx context.Context, ownerID, releasedBy string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releasedAt[ownerID] = time.Now().Unix()
+	s.releasedBy = append(s.releasedBy, releasedBy)
+	return nil
+}
+
+func (s *fakeStore) MappedSuppliers(ctx context.Context, ownerID string, items []string) (map[[2]string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.mapped), nil
+}
+
+// ImportSupplierPrices applies the rows without an effective date, as dated ones are
+// taken to be in the future.
+func (s *fakeStore) ImportSupplierPrices(ctx context.Context, ownerID string, src csvimport.PriceSource, rows []csvimport.PriceRow) (csvimport.Result, []service.PriceChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.priceImports = append(s.priceImports, src)
+	var changes []service.PriceChange
+	for _, r := range rows {
+		key := [2]string{r.ItemCode, r.AccountNumber}
+		if r.EffectiveFrom == "" && s.agreedPrices[key] != r.UnitPrice {
+			changes = append(changes, service.PriceChange{OwnerID: ownerID, ItemID: r.ItemCode, ContactID: r.AccountNumber,
+				From: s.agreedPrices[key], To: r.UnitPrice, EffectiveFrom: time.Now().Format(time.DateOnly)})
+			s.agreedPrices[key] = r.UnitPrice
+		}
+	}
+	return csvimport.Result{Inserted: len(rows)}, changes, nil
+}
+
+func (s *fakeStore) PushXeroPurchasePrices(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushes++
+	if s.pushErr != nil {
+		return 0, s.pushErr
+	}
+	return len(s.agreedPrices), nil
+}
+
+func (s *fakeStore) ListHoldingOwners(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for id, settings := range s.settings {
+		if settings.HoldOrders {
+			out = append(out, id)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+func (s *fakeStore) RecordExport(ctx context.Context, e service.Export) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exportErr != nil {
+		return 0, s.exportErr
+	}
+	e.ID = len(s.exports) + 1
+	s.exports = append(s.exports, e)
+	return e.ID, nil
+}
+
+func (s *fakeStore) ListExports(ctx context.Context, ownerID string, limit int) ([]service.Export, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []service.Export
+	for i := len(s.exports) - 1; i >= 0 && len(out) < limit; i-- {
+		if s.exports[i].OwnerID == ownerID {
+			out = append(out, s.exports[i])
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) GetExport(ctx context.Context, ownerID string, id int) (*service.Export, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.exports {
+		if e.ID == id && e.OwnerID == ownerID {
+			return &e, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) DeleteExport(ctx context.Context, ownerID string, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.exports {
+		if e.ID == id && e.OwnerID == ownerID {
+			s.exports = append(s.exports[:i], s.exports[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// fakeWorkspace is a stored workspace with its members' roles by user id.
+type fakeWorkspace struct {
+	name     string
+	joinCode string
+	members  map[string]string
+}
+
+// workspace returns id as userID sees it; ok is false if they are not a member.
+func (s *fakeStore) workspace(id, userID string) (service.Workspace, bool) {
+	ws := s.workspaces[id]
+	if ws == nil || ws.members[userID] == "" {
+		return service.Workspace{}, false
+	}
+	return service.Workspace{ID: id, Name: ws.name, Role: ws.members[userID], JoinCode: ws.joinCode}, true
+}
+
+func (s *fakeStore) ResolveWorkspace(ctx context.Context, userID, email, requested string) (service.Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.workspace(requested, userID); ok {
+		return w, nil
+	}
+	if s.workspaces[userID] == nil {
+		s.workspaces[userID] = &fakeWorkspace{name: "Personal", members: map[string]string{userID: service.WorkspaceOwner}}
+	}
+	w, _ := s.workspace(userID, userID)
+	return w, nil
+}
+
+func (s *fakeStore) ListWorkspaces(ctx context.Context, userID string) ([]service.Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []service.Workspace
+	for _, id := range slices.Sorted(maps.Keys(s.workspaces)) {
+		if w, ok := s.workspace(id, userID); ok {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) CreateWorkspace(ctx context.Context, userID, email, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("ws-%d", len(s.workspaces)+1)
+	s.workspaces[id] = &fakeWorkspace{name: name, members: map[string]string{userID: service.WorkspaceOwner}}
+	return id, nil
+}
+
+func (s *fakeStore) SetWorkspaceJoinCode(ctx context.Context, workspaceID, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ws := s.workspaces[workspaceID]; ws != nil {
+		ws.joinCode = code
+	}
+	return nil
+}
+
+func (s *fakeStore) JoinWorkspace(ctx context.Context, code, userID, email string) (service.Workspace, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, ws := range s.workspaces {
+		if code != "" && ws.joinCode == code {
+			if ws.members[userID] == "" {
+				ws.members[userID] = service.WorkspaceMember
+			}
+			w, _ := s.workspace(id, userID)
+			return w, true, nil
+		}
+	}
+	return service.Workspace{}, false, nil
+}
+
+func (s *fakeStore) ListWorkspaceMembers(ctx context.Context, workspaceID string) ([]service.WorkspaceMemberInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []service.WorkspaceMemberInfo
+	if ws := s.workspaces[workspaceID]; ws != nil {
+		for _, id := range slices.Sorted(maps.Keys(ws.members)) {
+			out = append(out, service.WorkspaceMemberInfo{UserID: id, Email: id + "@example.com", Role: ws.members[id]})
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws := s.workspaces[workspaceID]
+	if ws == nil || ws.members[userID] != service.WorkspaceMember {
+		return false, nil
+	}
+	delete(ws.members, userID)
+	return true, nil
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// xeroPingTTL limits how often readiness probes call out to Xero.
+const xeroPingTTL = time.Minute
+
+// componentStatus is one entry in the /health/ready report.
+type componentStatus struct {
+	Status   string `json:"status"` // "ok" or "error"
+	Error    string `json:"error,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Critical bool   `json:"critical"`
+}
+
+// xeroPingCache remembers the last Xero reachability result.
+type xeroPingCache struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ready reports readiness for load balancer probes. The database and at least one
+// usable Xero connection are required; Xero API and read replica reachability are
+// reported but do not take the instance out of rotation (every instance would fail
+// them together).
+func (h *Handler) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	components := map[string]componentStatus{}
+
+	db := componentStatus{Status: "ok", Critical: true}
+	if err := service.PingDB(ctx, h.dbURL); err != nil {
+		db.Status, db.Error = "error", err.Error()
+	}
+	components["database"] = db
+
+	conn := componentStatus{Status: "ok", Critical: true}
+	if db.Status != "ok" {
+		conn.Status, conn.Error = "error", "database unavailable"
+	} else if n, err := service.CountUsableConnections(ctx, h.dbURL); err != nil {
+		conn.Status, conn.Error = "error", err.Error()
+	} else if n == 0 {
+		conn.Status, conn.Error = "error", "no usable xero connection"
+	} else {
+		conn.Detail = plural(n, "usable connection")
+	}
+	components["xero_connection"] = conn
+
+	// reports fall back to the primary, so a missing replica only slows them down
+	if h.reports != nil && h.reports.replica != "" {
+		replica := componentStatus{Status: "ok"}
+		if err := h.reports.check(ctx); err != nil {
+			replica.Status, replica.Error = "error", err.Error()
+		}
+		components["database_replica"] = replica
+	}
+
+	api := componentStatus{Status: "ok"}
+	if err := h.pingXero(ctx); err != nil {
+		api.Status, api.Error = "error", err.Error()
+	}
+	components["xero_api"] = api
+
+	status, code := "ready", http.StatusOK
+	for _, c := range components {
+		if c.Critical && c.Status != "ok" {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     status,
+		"components": components,
+	})
+}
+
+// pingXero returns the cached Xero reachability result, refreshing it after xeroPingTTL.
+func (h *Handler) pingXero(ctx context.Context) error {
+	h.xeroPing.mu.Lock()
+	defer h.xeroPing.mu.Unlock()
+	if !h.xeroPing.checked.IsZero() && time.Since(h.xeroPing.checked) < xeroPingTTL {
+		return h.xeroPing.err
+	}
+	// a probe should answer quickly; the next one tries again anyway
+	h.xeroPing.err = h.xc.Ping(xero.WithRetryPolicy(ctx, xero.NoRetry))
+	h.xeroPing.checked = time.Now()
+	return h.xeroPing.err
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/bomexport"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/picklist"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// unsafeFilenameChars is everything not kept when an invoice number goes into a filename.
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// resolveInvoiceFromRequest resolves the {number} invoice's BOM for the current owner.
+// On failure it has already written the error response and returns ok=false.
+func (h *Handler) resolveInvoiceFromRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (invoiceNumber string, perAssy []service.BOMNode, leafTotals []service.LeafTotal, ok bool) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return "", nil, nil, false
+	}
+	invoiceNumber = strings.TrimSpace(chi.URLParam(r, "number"))
+	if invoiceNumber == "" {
+		http.Error(w, "invoice number missing", http.StatusBadRequest)
+		return "", nil, nil, false
+	}
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return "", nil, nil, false
+	}
+
+	settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load settings", err)
+		return "", nil, nil, false
+	}
+	// the same supplier assemblies expanded, and substitutes chosen, as on the page
+	// the export was linked from
+	opts := settings.ResolveOptions()
+	opts.Expand = toggleExpand(r.URL.Query()["expand"], "")
+	opts.Substitute = substituteMap(chooseSubstitute(r.URL.Query()["substitute"], ""))
+	perAssy, leafTotals, msg, err := h.invoices.ResolveInvoice(ctx, ownerID, h.xc, creds, invoiceNumber, opts)
+	if err != nil {
+		h.xeroError(w, r, "invoice resolution failed", err)
+		return "", nil, nil, false
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusUnprocessableEntity)
+		return "", nil, nil, false
+	}
+	return invoiceNumber, perAssy, leafTotals, true
+}
+
+// writeDownload sends body with a filename derived from the invoice number.
+func writeDownload(w http.ResponseWriter, contentType, disposition, invoiceNumber, suffix string, body []byte) {
+	filename := unsafeFilenameChars.ReplaceAllString(invoiceNumber, "_") + suffix
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filename))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(body)
+}
+
+// invoiceBOMPDFHandler resolves an invoice's BOM from Xero and returns it as a
+// printable PDF pick list (per-assembly tree plus aggregated leaf totals).
+func (h *Handler) invoiceBOMPDFHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	persist, ok := h.persistRequested(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	invoiceNumber, perAssy, leafTotals, ok := h.resolveInvoiceFromRequest(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// render fully before writing so a failure can still return an error status
+	var buf bytes.Buffer
+	now := time.Now().UTC()
+	if err := picklist.Render(&buf, invoiceNumber, perAssy, leafTotals, now); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "render pdf failed", err)
+		return
+	}
+	if persist {
+		h.storeAndRedirect(ctx, w, r, service.ExportPicklist, exportFilename("picklist-"+invoiceNumber, ".pdf", now), "application/pdf", buf.Bytes())
+		return
+	}
+	writeDownload(w, "application/pdf", "inline", "picklist-"+invoiceNumber, ".pdf", buf.Bytes())
+}
+
+// invoiceKitHandler resolves an invoice's BOM and shows it as a printable kit list:
+// one card per assembly with the parts for a single unit (picklist.KitSections).
+func (h *Handler) invoiceKitHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	invoiceNumber, perAssy, _, ok := h.resolveInvoiceFromRequest(ctx, w, r)
+	if !ok {
+		return
+	}
+	exportQuery := ""
+	if r.URL.RawQuery != "" {
+		exportQuery = "?" + r.URL.RawQuery
+	}
+	data := map[string]interface{}{
+		"Title":         "Kit list " + invoiceNumber,
+		"InvoiceNumber": invoiceNumber,
+		"Sections":      picklist.KitSections(perAssy),
+		"Generated":     time.Now().UTC(),
+		"ExportQuery":   exportQuery,
+	}
+	if h.store != nil {
+		q := r.URL.Query()
+		q.Set("persist", "1")
+		data["KeepQuery"] = "?" + q.Encode()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "kit.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// invoiceKitPDFHandler returns the kit list of invoiceKitHandler as a PDF.
+func (h *Handler) invoiceKitPDFHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	persist, ok := h.persistRequested(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	invoiceNumber, perAssy, _, ok := h.resolveInvoiceFromRequest(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	now := time.Now().UTC()
+	if err := picklist.RenderKit(&buf, invoiceNumber, picklist.KitSections(perAssy), now); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "render pdf failed", err)
+		return
+	}
+	if persist {
+		h.storeAndRedirect(ctx, w, r, service.ExportKit, exportFilename("kit-"+invoiceNumber, ".pdf", now), "application/pdf", buf.Bytes())
+		return
+	}
+	writeDownload(w, "application/pdf", "inline", "kit-"+invoiceNumber, ".pdf", buf.Bytes())
+}
+
+// invoiceBOMExportHandler returns the invoice's flattened BOM (level, code, name,
+// per-assembly and effective qty, supplier, unit cost) as CSV or XLSX per {format}.
+func (h *Handler) invoiceBOMExportHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	format := chi.URLParam(r, "format")
+	if format != bomexport.FormatCSV && format != bomexport.FormatXLSX {
+		http.Error(w, "unsupported export format", http.StatusNotFound)
+		return
+	}
+	persist, ok := h.persistRequested(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	invoiceNumber, perAssy, _, ok := h.resolveInvoiceFromRequest(ctx, w, r)
+	if !ok {
+		return
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	suppliers, err := service.LoadItemSuppliers(ctx, h.dbURL, ownerID, service.BOMPartIDs(perAssy))
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load suppliers", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := bomexport.Write(&buf, format, service.FlattenBOMForExport(perAssy, suppliers)); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "export failed", err)
+		return
+	}
+	if persist {
+		h.storeAndRedirect(ctx, w, r, service.ExportBOM, exportFilename("bom-"+invoiceNumber, "."+format, time.Now()), bomexport.ContentType(format), buf.Bytes())
+		return
+	}
+	writeDownload(w, bomexport.ContentType(format), "attachment", "bom-"+invoiceNumber, "."+format, buf.Bytes())
+}
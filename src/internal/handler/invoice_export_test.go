@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestInvoiceKit(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy: []service.BOMNode{{PartID: "VAN", Name: "Van", Quantity: 2, IsAssembly: true, Children: []service.BOMNode{
+			{PartID: "FRAME", Quantity: 1, IsAssembly: true, Children: []service.BOMNode{{PartID: "BOLT", Name: "Bolt", Quantity: 8}}},
+		}}},
+	}
+
+	rec := hs.do(http.MethodGet, "/invoice/INV-1/kit?expand=SINK", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := hs.store.resolve.Expand; !reflect.DeepEqual(got, []string{"SINK"}) {
+		t.Fatalf("Expand = %v, want [SINK]", got)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"Kit list: invoice INV-1", "&times; 2", `<span class="font-mono">VAN</span>`, "16", "/invoice/INV-1/kit.pdf?expand=SINK"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+
+	rec = hs.do(http.MethodGet, "/invoice/INV-1/kit.pdf", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/pdf" || !strings.HasPrefix(rec.Body.String(), "%PDF-") {
+		t.Fatalf("Content-Type %q, body %.16q", ct, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "kit-INV-1.pdf") {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// invoiceDateLayout is the form of the from/to filters (an <input type="date"> value).
+const invoiceDateLayout = "2006-01-02"
+
+// invoiceSearchForm is the /invoices query: the filters as typed and the page.
+type invoiceSearchForm struct {
+	Contact string
+	From    string
+	To      string
+	Page    int
+}
+
+// parseInvoiceSearch reads contact, from, to and page from v.
+func parseInvoiceSearch(v url.Values) (invoiceSearchForm, xero.InvoiceSearch, error) {
+	f := invoiceSearchForm{
+		Contact: strings.TrimSpace(v.Get("contact")),
+		From:    strings.TrimSpace(v.Get("from")),
+		To:      strings.TrimSpace(v.Get("to")),
+		Page:    1,
+	}
+	q := xero.InvoiceSearch{Contact: f.Contact}
+	if s := strings.TrimSpace(v.Get("page")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return f, q, errors.New("invalid page")
+		}
+		f.Page = n
+	}
+	q.Page = f.Page
+	var err error
+	if f.From != "" {
+		if q.From, err = time.Parse(invoiceDateLayout, f.From); err != nil {
+			return f, q, errors.New("from must be a date (YYYY-MM-DD)")
+		}
+	}
+	if f.To != "" {
+		if q.To, err = time.Parse(invoiceDateLayout, f.To); err != nil {
+			return f, q, errors.New("to must be a date (YYYY-MM-DD)")
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return f, q, errors.New("to is before from")
+	}
+	return f, q, nil
+}
+
+// pageURL is /invoices with f's filters on page n.
+func (f invoiceSearchForm) pageURL(n int) string {
+	v := url.Values{}
+	for k, s := range map[string]string{"contact": f.Contact, "from": f.From, "to": f.To} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	if n > 1 {
+		v.Set("page", strconv.Itoa(n))
+	}
+	if len(v) == 0 {
+		return "/invoices"
+	}
+	return "/invoices?" + v.Encode()
+}
+
+// invoicesHandler lists the owner's recent sales invoices from Xero, newest first,
+// filtered by contact name and date range (?contact=&from=&to=&page=). Each row can
+// be sent to the BOM resolver.
+func (h *Handler) invoicesHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	form, q, err := parseInvoiceSearch(r.URL.Query())
+	data := map[string]interface{}{
+		"Title":     "Invoices",
+		"Search":    form,
+		"CSRFToken": mid.CSRFToken(r),
+	}
+	if err != nil {
+		data["Error"] = err.Error()
+		h.renderInvoices(w, http.StatusBadRequest, data)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+
+	invoices, err := h.xc.SearchInvoices(ctx, creds.AccessToken, creds.TenantID, q)
+	if err != nil {
+		h.xeroError(w, r, "invoice search failed", err)
+		return
+	}
+
+	data["Invoices"] = invoices
+	org := h.organisation(ctx, creds)
+	data["Org"] = org // for "Open in Xero" links
+	data["Locale"] = orgLocale(org)
+	data["Flash"] = h.flash.Pop(w, r)
+	if form.Page > 1 {
+		data["PrevURL"] = form.pageURL(form.Page - 1)
+	}
+	if len(invoices) >= xero.DefaultInvoiceSearchPageSize {
+		data["NextURL"] = form.pageURL(form.Page + 1)
+	}
+	h.renderInvoices(w, http.StatusOK, data)
+}
+
+func (h *Handler) renderInvoices(w http.ResponseWriter, status int, data map[string]interface{}) {
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = h.templates.ExecuteTemplate(w, "invoices.html", data)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestInvoices(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	var wheres []string
+	hs.xero.HandleFunc("GET /api.xro/2.0/Invoices", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		wheres = append(wheres, q.Get("where")+" page="+q.Get("page"))
+		if q.Get("order") != "Date DESC" || r.Header.Get("Xero-tenant-id") != "tenant-1" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"Invoices":[{"InvoiceID":"i1","InvoiceNumber":"INV-0042","Type":"ACCREC","Status":"AUTHORISED",`+
+			`"Contact":{"Name":"Harbour Kitchens"},"DateString":"2025-03-03T00:00:00","Total":540.5,"CurrencyCode":"GBP"}]}`)
+	})
+
+	hs.xero.HandleFunc("GET /api.xro/2.0/Organisation", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Organisations":[{"Name":"Demo","ShortCode":"!k3Pq"}]}`)
+	})
+
+	rec := hs.do(http.MethodGet, "/invoices?contact=harbour&from=2025-03-01&to=2025-03-31&page=2", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{"INV-0042", "Harbour Kitchens", "3 Mar 2025", "540.50", `name="invoice_id" value="INV-0042"`, "Resolve BOM",
+		`href="https://go.xero.com/organisationlogin/default.aspx?shortcode=%21k3Pq&amp;redirecturl=%2FAccountsReceivable%2FView.aspx%3FInvoiceID%3Di1"`,
+		`href="/invoices?contact=harbour&amp;from=2025-03-01&amp;to=2025-03-31"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+	if strings.Contains(body, "Older") {
+		t.Error("a short page has no next link")
+	}
+	want := `Type=="ACCREC" AND Contact.Name.Contains("harbour") AND Date>=DateTime(2025,03,01) AND Date<=DateTime(2025,03,31) page=2`
+	if len(wheres) != 1 || wheres[0] != want {
+		t.Fatalf("xero queries = %q", wheres)
+	}
+
+	for _, q := range []string{"from=yesterday", "page=0", "from=2025-03-02&to=2025-03-01"} {
+		expectStatus(t, hs.do(http.MethodGet, "/invoices?"+q, nil), http.StatusBadRequest)
+	}
+	if len(wheres) != 1 {
+		t.Fatal("invalid searches must not call Xero")
+	}
+}
+
+func TestInvoices_Errors(t *testing.T) {
+	t.Parallel()
+	t.Run("requires login", func(t *testing.T) {
+		hs := newHarness(t)
+		expectRedirect(t, hs.do(http.MethodGet, "/invoices", nil, anonymous), "/login")
+	})
+	t.Run("no connection", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.creds.err = service.ErrNoConnection
+		expectStatus(t, hs.do(http.MethodGet, "/invoices", nil), http.StatusNotFound)
+	})
+	// Xero's status is mapped: its own failures are 502, the typed ones keep meaning
+	for _, tc := range []struct {
+		name       string
+		xeroStatus int
+		want       int
+	}{
+		{"xero error", http.StatusInternalServerError, http.StatusBadGateway},
+		{"xero refused access", http.StatusForbidden, http.StatusForbidden},
+		{"xero rate limited", http.StatusTooManyRequests, http.StatusTooManyRequests},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hs := newHarness(t)
+			hs.xero.HandleFunc("GET /api.xro/2.0/Invoices", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", tc.xeroStatus)
+			})
+			expectStatus(t, hs.do(http.MethodGet, "/invoices", nil), tc.want)
+		})
+	}
+}
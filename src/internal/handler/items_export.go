@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// exportItemsHandler downloads every Xero item of the owner's organisation as JSON,
+// {"FetchedAt": ..., "Items": [...]} with each item as Xero returned it. Optional query
+// values: pageSize (1-1000 items per Xero request) and modifiedSince (YYYY-MM-DD) to
+// only export items changed since then.
+func (h *Handler) exportItemsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+
+	var q xero.ItemsQuery
+	if v := strings.TrimSpace(r.URL.Query().Get("pageSize")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "invalid pageSize (want 1-1000)", http.StatusBadRequest)
+			return
+		}
+		q.PageSize = n
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("modifiedSince")); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "invalid modifiedSince date (want YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		q.ModifiedSince = t
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), xeroExportTimeout)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+
+	items, err := h.xc.GetAllItems(ctx, creds.AccessToken, creds.TenantID, q)
+	if err != nil {
+		h.xeroError(w, r, "items fetch failed", err)
+		return
+	}
+
+	raw := make([]json.RawMessage, len(items))
+	for i, it := range items {
+		raw[i] = it.Raw
+	}
+	now := time.Now().UTC()
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(map[string]any{
+		"FetchedAt": now.Format(time.RFC3339),
+		"Items":     raw,
+	}); err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, fmt.Sprintf("failed to encode %d items", len(items)), err)
+		return
+	}
+	writeDownload(w, "application/json", "attachment", "xero-items-"+now.Format("20060102-150405"), ".json", buf.Bytes())
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportItems(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	var queries []string
+	hs.xero.HandleFunc("GET /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery+" "+r.Header.Get("If-Modified-Since"))
+		if r.URL.Query().Get("page") == "1" {
+			fmt.Fprint(w, `{"Items":[{"ItemID":"i1","Code":"BOLT","SalesDetails":{"UnitPrice":1}},{"ItemID":"i2","Code":"NUT"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"Items":[{"ItemID":"i3","Code":"WASHER"}]}`)
+	})
+
+	rec := hs.do(http.MethodGet, "/xero/items/export?pageSize=2&modifiedSince=2025-03-01", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="xero-items-`) {
+		t.Fatalf("Content-Disposition = %q", cd)
+	}
+	var out struct {
+		FetchedAt string
+		Items     []map[string]any
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(out.Items) != 3 || out.Items[2]["Code"] != "WASHER" || out.Items[0]["SalesDetails"] == nil {
+		t.Fatalf("unexpected items: %+v", out.Items)
+	}
+	want := []string{
+		"page=1&pageSize=2 Sat, 01 Mar 2025 00:00:00 GMT",
+		"page=2&pageSize=2 Sat, 01 Mar 2025 00:00:00 GMT",
+	}
+	if strings.Join(queries, "|") != strings.Join(want, "|") {
+		t.Fatalf("xero queries = %q", queries)
+	}
+
+	for _, q := range []string{"pageSize=0", "pageSize=abc", "modifiedSince=yesterday"} {
+		expectStatus(t, hs.do(http.MethodGet, "/xero/items/export?"+q, nil), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// listStore reads the paged lists: the shopping list, purchase order history and
+// audit log.
+type listStore interface {
+	ListShoppingList(ctx context.Context, ownerID string, includeArchived bool, req service.PageRequest) (service.Page[service.ShoppingListEntry], error)
+	ListPurchaseOrders(ctx context.Context, ownerID string, req service.PageRequest) (service.Page[service.PurchaseOrderRecord], error)
+	ListAuditLog(ctx context.Context, ownerID string, req service.PageRequest) (service.Page[service.AuditRecord], error)
+}
+
+func (s dbStore) ListShoppingList(ctx context.Context, ownerID string, includeArchived bool, req service.PageRequest) (service.Page[service.ShoppingListEntry], error) {
+	return service.ListShoppingList(ctx, s.dbURL, ownerID, includeArchived, req)
+}
+
+func (s dbStore) ListPurchaseOrders(ctx context.Context, ownerID string, req service.PageRequest) (service.Page[service.PurchaseOrderRecord], error) {
+	return service.ListPurchaseOrders(ctx, s.dbURL, ownerID, req)
+}
+
+func (s dbStore) ListAuditLog(ctx context.Context, ownerID string, req service.PageRequest) (service.Page[service.AuditRecord], error) {
+	return service.ListAuditLog(ctx, s.dbURL, ownerID, req)
+}
+
+// pager is the paging, sorting and search controls of a list page (pager.html).
+type pager struct {
+	Page, Pages, Total int
+	Query              string
+	Sort               string // as in ?sort=
+	PrevURL, NextURL   string // "" on the first and last page
+	// Hidden carries the request's other query values (e.g. include_archived)
+	// through the search form.
+	Hidden map[string]string
+
+	u url.URL
+}
+
+func newPager[T any](r *http.Request, p service.Page[T]) pager {
+	pg := pager{Page: p.Page, Pages: p.Pages(), Total: p.Total, Query: p.Query, Sort: p.Sort, Hidden: map[string]string{}, u: *r.URL}
+	for k, v := range r.URL.Query() {
+		if k != "page" && k != "q" && len(v) > 0 {
+			pg.Hidden[k] = v[0]
+		}
+	}
+	if pg.Page > 1 {
+		pg.PrevURL = pg.link(map[string]string{"page": strconv.Itoa(min(pg.Page-1, pg.Pages))})
+	}
+	if pg.Page < pg.Pages {
+		pg.NextURL = pg.link(map[string]string{"page": strconv.Itoa(pg.Page + 1)})
+	}
+	return pg
+}
+
+// link is the list's URL with set applied to its query.
+func (p pager) link(set map[string]string) string {
+	q := p.u.Query()
+	for k, v := range set {
+		q.Set(k, v)
+	}
+	u := p.u
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// SortURL links to the first page sorted by key, reversing the order when the list
+// is already sorted by it.
+func (p pager) SortURL(key string) string {
+	sort := key
+	if p.Sort == key {
+		sort = "-" + key
+	}
+	return p.link(map[string]string{"sort": sort, "page": "1"})
+}
+
+// SortMark marks the column the list is sorted by with its direction.
+func (p pager) SortMark(key string) string {
+	switch p.Sort {
+	case key:
+		return " ▲"
+	case "-" + key:
+		return " ▼"
+	}
+	return ""
+}
+
+// wantsHTML reports whether a list request came from a browser. Scripts, which do not
+// ask for text/html, get JSON.
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// listRequest parses the list query of r with spec, answering 400 when it is invalid.
+func (h *Handler) listRequest(w http.ResponseWriter, r *http.Request, spec service.ListSpec) (service.PageRequest, bool) {
+	req, err := spec.Parse(r.URL.Query())
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, err.Error(), err)
+		return req, false
+	}
+	return req, true
+}
+
+// renderList answers one page of a list: JSON unless the browser asked for HTML, then
+// tmpl with data plus "Items" and "Pager".
+func renderList[T any](h *Handler, w http.ResponseWriter, r *http.Request, page service.Page[T], tmpl string, data map[string]any) {
+	if !wantsHTML(r) || h.templates == nil {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(page)
+		return
+	}
+	data["Items"] = page.Items
+	data["Pager"] = newPager(r, page)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates.ExecuteTemplate(w, tmpl, data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// purchaseOrderHistoryHandler lists the purchase orders this app created, newest
+// first, as a page or JSON (see renderList). Query: page, per_page, sort
+// (created_at, contact_account, status; "-" for descending), q.
+func (h *Handler) purchaseOrderHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	req, ok := h.listRequest(w, r, service.PurchaseOrderListSpec)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	page, err := h.lists.ListPurchaseOrders(ctx, ownerID, req)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load purchase orders", err)
+		return
+	}
+	renderList(h, w, r, page, "purchase_orders.html", map[string]any{
+		"Title":  "Purchase orders",
+		"Locale": h.localeForOwner(ctx, ownerID),
+	})
+}
+
+// auditLogHandler lists the workspace's audit log, newest first, as a page or JSON
+// (see renderList). Query: page, per_page, sort (id, action), q.
+func (h *Handler) auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	req, ok := h.listRequest(w, r, service.AuditLogSpec)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	page, err := h.lists.ListAuditLog(ctx, ownerID, req)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load audit log", err)
+		return
+	}
+	renderList(h, w, r, page, "audit_log.html", map[string]any{
+		"Title":  "Audit log",
+		"Locale": h.localeForOwner(ctx, ownerID),
+	})
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// browser asks for HTML, as a browser does.
+func browser(r *http.Request) { r.Header.Set("Accept", "text/html,application/xhtml+xml") }
+
+func TestShoppingList_PagesAsJSON(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	archived := int64(1_700_000_000)
+	for i := 5; i >= 1; i-- {
+		hs.store.shoppingList = append(hs.store.shoppingList, service.ShoppingListEntry{ListID: i, ItemID: fmt.Sprintf("P-%04d", i), Quantity: 1})
+	}
+	hs.store.shoppingList[0].ArchivedAt = &archived
+
+	rec := hs.do(http.MethodGet, "/shopping-list?per_page=2&page=2&sort=item_id&q=P-", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var page service.Page[service.ShoppingListEntry]
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || page.Page != 2 || page.PerPage != 2 || page.Sort != "item_id" || page.Query != "P-" ||
+		len(page.Items) != 2 || page.Items[0].ListID != 2 {
+		t.Fatalf("page = %+v", page)
+	}
+	if got := hs.store.listReq; got.Sort != "item_id" || got.Desc || got.Page != 2 {
+		t.Fatalf("request = %+v", got)
+	}
+
+	rec = hs.do(http.MethodGet, "/shopping-list?include_archived=1", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 5 || page.Sort != "-list_id" {
+		t.Fatalf("page = %+v", page)
+	}
+}
+
+func TestShoppingList_InvalidQuery(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	for _, q := range []string{"page=0", "per_page=x", "sort=owner_id", "sort=-version"} {
+		expectStatus(t, hs.do(http.MethodGet, "/shopping-list?"+q, nil), http.StatusBadRequest)
+	}
+}
+
+func TestPurchaseOrderHistory_PageLinks(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	for i := 1; i <= 30; i++ {
+		hs.store.purchaseOrders = append(hs.store.purchaseOrders, service.PurchaseOrderRecord{
+			ID: i, XeroPOID: fmt.Sprintf("po-%d", i), ContactAccount: "S-001", Status: "AUTHORISED",
+			Lines: []service.PurchaseOrderLine{{ItemID: "BOLT", Quantity: 4}},
+		})
+	}
+
+	rec := hs.do(http.MethodGet, "/purchase-orders?sort=-contact_account&q=S-0", nil, browser)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{
+		"Page 1 of 2 (30 total)",
+		`href="/purchase-orders?page=2&amp;q=S-0&amp;sort=-contact_account"`,
+		`href="/purchase-orders?page=1&amp;q=S-0&amp;sort=contact_account"`, // reverses the current sort
+		`name="sort" value="-contact_account"`,
+		`value="S-0"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page missing %s", want)
+		}
+	}
+	if strings.Contains(body, "Previous") {
+		t.Error("first page links to a previous one")
+	}
+
+	rec = hs.do(http.MethodGet, "/purchase-orders?page=2", nil, browser)
+	if body := rec.Body.String(); !strings.Contains(body, "Page 2 of 2") || !strings.Contains(body, "Previous") || strings.Contains(body, "Next") {
+		t.Fatalf("unexpected last page: %s", body)
+	}
+}
+
+func TestAuditLog_JSONAndHTMLAgree(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.audit = []service.AuditRecord{
+		{ID: 2, Action: service.AuditPurchaseOrdersCreated, Detail: json.RawMessage(`{"marked":3}`), CreatedAt: 1_700_000_100},
+		{ID: 1, Action: service.AuditPurchaseOrdersCreated, Detail: json.RawMessage(`{"marked":1}`), CreatedAt: 1_700_000_000},
+	}
+
+	rec := hs.do(http.MethodGet, "/audit-log", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var page service.Page[service.AuditRecord]
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Sort != "-id" || string(page.Items[0].Detail) != `{"marked":3}` {
+		t.Fatalf("page = %+v", page)
+	}
+
+	rec = hs.do(http.MethodGet, "/audit-log", nil, browser)
+	if body := rec.Body.String(); !strings.Contains(body, "Page 1 of 1 (2 total)") || !strings.Contains(body, "{&#34;marked&#34;:3}") {
+		t.Fatalf("unexpected page: %s", body)
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	"github.com/hwalton/xero-invoice-orderer/pkg/ratelimit"
+)
+
+// loginLimits throttles sign-in: every attempt counts against the client IP, and
+// failed ones against the email address, which is locked for the rest of the window
+// once it reaches its limit. A nil *loginLimits allows everything.
+type loginLimits struct {
+	ip    ratelimit.Limiter
+	email ratelimit.Limiter
+}
+
+// newLoginLimits builds the limits from cfg, keeping counts in Redis when
+// configured and in memory otherwise.
+func newLoginLimits(cfg config.LoginLimitConfig) *loginLimits {
+	store := newLimitStore(cfg.RedisURL, "sign-in limits")
+	return &loginLimits{
+		ip:    ratelimit.Limiter{Store: store, Prefix: "login:ip:", Limit: cfg.PerIP, Window: cfg.Window},
+		email: ratelimit.Limiter{Store: store, Prefix: "login:email:", Limit: cfg.PerEmail, Window: cfg.Window},
+	}
+}
+
+// newLimitStore keeps rate limit counts in Redis at redisURL, or in memory when it is
+// empty or cannot be used (what names the limits in the log).
+func newLimitStore(redisURL, what string) ratelimit.Store {
+	if redisURL == "" {
+		return ratelimit.NewMemory()
+	}
+	rs, err := ratelimit.NewRedis(redisURL)
+	if err != nil {
+		log.Printf("RATE_LIMIT_REDIS_URL: %v — %s kept in memory", err, what)
+		return ratelimit.NewMemory()
+	}
+	return rs
+}
+
+// attempt records a sign-in attempt from r for email. It returns a message for the
+// user and how long to wait when the attempt must be refused. Store errors are
+// logged and let the attempt through.
+func (l *loginLimits) attempt(ctx context.Context, r *http.Request, email string) (string, time.Duration) {
+	if l == nil {
+		return "", 0
+	}
+	wait, err := l.ip.Hit(ctx, clientIP(r))
+	if err != nil {
+		log.Printf("login limits: ip: %v", err)
+	}
+	if wait > 0 {
+		return "Too many sign-in attempts from your network. Try again in " + waitText(wait) + ".", wait
+	}
+	if email == "" {
+		return "", 0
+	}
+	wait, err = l.email.Check(ctx, emailKey(email))
+	if err != nil {
+		log.Printf("login limits: email: %v", err)
+	}
+	if wait > 0 {
+		return "Too many failed sign-in attempts for this account. Try again in " + waitText(wait) + ".", wait
+	}
+	return "", 0
+}
+
+// failed records a rejected password for email. It returns a lockout message when
+// this failure locked the account, else a warning once few attempts remain ("" when
+// there is nothing to say).
+func (l *loginLimits) failed(ctx context.Context, email string) (locked bool, msg string) {
+	if l == nil || email == "" || l.email.Limit < 1 {
+		return false, ""
+	}
+	wait, err := l.email.Hit(ctx, emailKey(email))
+	if err != nil {
+		log.Printf("login limits: email: %v", err)
+		return false, ""
+	}
+	if wait > 0 {
+		return true, "Too many failed sign-in attempts for this account. Try again in " + waitText(wait) + "."
+	}
+	left, err := l.email.Remaining(ctx, emailKey(email))
+	if err != nil {
+		log.Printf("login limits: email: %v", err)
+		return false, ""
+	}
+	if left == 0 {
+		return true, "Too many failed sign-in attempts for this account. Try again in " + waitText(l.email.Window) + "."
+	}
+	if left <= 2 {
+		return false, fmt.Sprintf("%d attempt(s) left before sign-in is locked for %s.", left, waitText(l.email.Window))
+	}
+	return false, ""
+}
+
+// succeeded clears email's failed attempts.
+func (l *loginLimits) succeeded(ctx context.Context, email string) {
+	if l == nil || email == "" {
+		return
+	}
+	if err := l.email.Reset(ctx, emailKey(email)); err != nil {
+		log.Printf("login limits: email: %v", err)
+	}
+}
+
+func emailKey(email string) string { return strings.ToLower(strings.TrimSpace(email)) }
+
+// clientIP is the request's client address without the port (RemoteAddr, which
+// middleware.RealIP sets from the forwarded headers of trusted proxies only).
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// waitText is d for a person, rounded up: "45 seconds", "1 minute", "15 minutes".
+func waitText(d time.Duration) string {
+	if d < time.Minute {
+		n := int((d + time.Second - 1) / time.Second)
+		if n == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", n)
+	}
+	n := int((d + time.Minute - 1) / time.Minute)
+	if n == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", n)
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprint(int((d+time.Second-1)/time.Second)))
+}
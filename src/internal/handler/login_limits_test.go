@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+)
+
+// fakeSupabaseLogin accepts password "right" for any email.
+func fakeSupabaseLogin(hs *harness) {
+	hs.xero.HandleFunc("POST /auth/v1/token", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["password"] != "right" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"Invalid login credentials"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"at","refresh_token":"rt","user":{"id":"u1"}}`))
+	})
+}
+
+func fromIP(ip string) reqOption {
+	return func(r *http.Request) { r.RemoteAddr = ip + ":5555" }
+}
+
+func TestLoginLimits(t *testing.T) {
+	t.Parallel()
+
+	login := func(hs *harness, email, password, ip string) *http.Response {
+		return hs.do(http.MethodPost, "/perform-login", url.Values{"email": {email}, "password": {password}}, anonymous, fromIP(ip)).Result()
+	}
+	body := func(resp *http.Response) string {
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	t.Run("email locked after failures", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		fakeSupabaseLogin(hs)
+		hs.handler.limits = newLoginLimits(config.LoginLimitConfig{PerIP: 100, PerEmail: 4, Window: 15 * time.Minute})
+
+		if resp := login(hs, "a@example.com", "wrong", "10.0.0.1"); resp.StatusCode != http.StatusOK || strings.Contains(body(resp), "attempt(s) left") {
+			t.Fatal("first failure should not warn")
+		}
+		if b := body(login(hs, "A@example.com ", "wrong", "10.0.0.2")); !strings.Contains(b, "2 attempt(s) left before sign-in is locked for 15 minutes.") {
+			t.Fatalf("expected warning:\n%s", b)
+		}
+		login(hs, "a@example.com", "wrong", "10.0.0.2")
+		if b := body(login(hs, "a@example.com", "wrong", "10.0.0.3")); !strings.Contains(b, "Too many failed sign-in attempts for this account. Try again in 15 minutes.") {
+			t.Fatalf("expected lockout:\n%s", b)
+		}
+		// locked even with the right password, from anywhere
+		resp := login(hs, "a@example.com", "right", "10.0.0.4")
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" || sessionCookie(resp) != "" {
+			t.Fatalf("status %d Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+		// other accounts are unaffected, and success clears the count
+		if resp := login(hs, "b@example.com", "wrong", "10.0.0.1"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("b: status %d", resp.StatusCode)
+		}
+		if resp := login(hs, "b@example.com", "right", "10.0.0.1"); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("b: status %d", resp.StatusCode)
+		}
+		if n, _ := hs.handler.limits.email.Remaining(t.Context(), "b@example.com"); n != 4 {
+			t.Fatalf("b: remaining %d after success", n)
+		}
+	})
+
+	t.Run("per ip", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		fakeSupabaseLogin(hs)
+		hs.handler.limits = newLoginLimits(config.LoginLimitConfig{PerIP: 2, PerEmail: 100, Window: time.Minute})
+
+		login(hs, "a@example.com", "wrong", "10.0.0.1")
+		login(hs, "b@example.com", "right", "10.0.0.1")
+		resp := login(hs, "c@example.com", "right", "10.0.0.1")
+		if resp.StatusCode != http.StatusTooManyRequests || !strings.Contains(body(resp), "Too many sign-in attempts from your network.") {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		if resp := login(hs, "c@example.com", "right", "10.0.0.2"); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("other ip: status %d", resp.StatusCode)
+		}
+		// registration shares the per-IP limit
+		rec := hs.do(http.MethodPost, "/register", url.Values{
+			"email": {"d@example.com"}, "password": {"pw"}, "password_confirm": {"pw"}, "invite_code": {"X"},
+		}, anonymous, fromIP("10.0.0.1"))
+		expectStatus(t, rec, http.StatusTooManyRequests)
+	})
+
+	t.Run("spoofed forwarded header", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		fakeSupabaseLogin(hs)
+		hs.handler.limits = newLoginLimits(config.LoginLimitConfig{PerIP: 2, PerEmail: 100, Window: time.Minute})
+		// as cmd/web mounts it, behind one proxy at 10.0.0.1
+		hs.router = mid.RealIP([]netip.Prefix{netip.MustParsePrefix("10.0.0.1/32")})(hs.router)
+		spoof := func(xff string) reqOption {
+			return func(r *http.Request) { r.Header.Set("X-Forwarded-For", xff) }
+		}
+
+		// straight from the client: its own header is ignored
+		for i, xff := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+			resp := hs.do(http.MethodPost, "/perform-login", url.Values{"email": {"a@example.com"}, "password": {"wrong"}}, anonymous, fromIP("203.0.113.9"), spoof(xff)).Result()
+			if want := i == 2; (resp.StatusCode == http.StatusTooManyRequests) != want {
+				t.Fatalf("direct attempt %d with X-Forwarded-For %s: status %d", i+1, xff, resp.StatusCode)
+			}
+		}
+		// through the proxy: only the address the proxy saw counts
+		for i, xff := range []string{"1.1.1.1, 203.0.113.7", "2.2.2.2, 203.0.113.7", "3.3.3.3, 203.0.113.7"} {
+			resp := hs.do(http.MethodPost, "/perform-login", url.Values{"email": {"a@example.com"}, "password": {"wrong"}}, anonymous, fromIP("10.0.0.1"), spoof(xff)).Result()
+			if want := i == 2; (resp.StatusCode == http.StatusTooManyRequests) != want {
+				t.Fatalf("proxied attempt %d with X-Forwarded-For %s: status %d", i+1, xff, resp.StatusCode)
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/validate"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// Xero's limits on item fields.
+const (
+	maxItemCodeLen        = 30
+	maxItemNameLen        = 50
+	maxItemDescriptionLen = 4000
+)
+
+// missingItem fills the "create item in Xero" form offered when an invoice's BOM
+// uses an item code Xero doesn't have.
+type missingItem struct {
+	Code          string
+	Name          string
+	Description   string
+	PurchasePrice string
+}
+
+// missingItemForm returns the form for msg when it reports an item missing from
+// Xero (nil otherwise), prefilled from the local parts table when it has the item.
+func (h *Handler) missingItemForm(ctx context.Context, msg string) *missingItem {
+	code, ok := service.MissingXeroItem(msg)
+	if !ok {
+		return nil
+	}
+	item := &missingItem{Code: code, Name: code}
+	p, found, err := h.invoices.GetPart(ctx, code)
+	if err != nil {
+		log.Printf("missing item %s: load part: %v", code, err)
+	}
+	if found {
+		if p.Name != "" {
+			item.Name = p.Name
+		}
+		item.Description = p.Description
+		if p.CostPrice > 0 {
+			item.PurchasePrice = strconv.FormatFloat(p.CostPrice, 'f', -1, 64)
+		}
+	}
+	return item
+}
+
+// parseMissingItem reads and checks the create item form; errs is nil when it is
+// valid.
+func parseMissingItem(r *http.Request) (*missingItem, xero.Part, validate.Errors) {
+	f := validate.New(r.PostForm)
+	item := &missingItem{
+		Code:          f.Code("code", "Item code", maxItemCodeLen),
+		Name:          f.Required("name", "Name"),
+		Description:   f.MaxLen("description", "Description", maxItemDescriptionLen),
+		PurchasePrice: f.Value("purchase_price"),
+	}
+	f.MaxLen("name", "Name", maxItemNameLen)
+	p := xero.Part{PartID: item.Code, Name: item.Name, Description: item.Description}
+	p.CostPrice = f.Float("purchase_price", "Purchase price", 0, 0, math.MaxFloat64)
+	return item, p, f.Errors()
+}
+
+// createXeroItemHandler handles the form offered for an item missing from Xero: it
+// creates the item and resolves the form's invoice_id again, so the BOM (or the
+// next problem) replaces the form.
+func (h *Handler) createXeroItemHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	fragment := isHTMXRequest(r)
+
+	// fail shows msg with the form again (and errs next to their inputs) so the user
+	// can correct it
+	fail := func(item *missingItem, msg string, errs validate.Errors) {
+		if fragment {
+			h.renderFragment(w, "invoice-bom.html", map[string]interface{}{
+				"InvoiceMessages": []flash.Message{{Level: flash.Error, Text: msg}},
+				"MissingItem":     item,
+				"FieldErrors":     errs,
+				"InvoiceID":       r.PostFormValue("invoice_id"),
+				"IgnoreStock":     r.PostFormValue("ignore_stock") != "",
+				"CSRFToken":       mid.CSRFToken(r),
+			})
+			return
+		}
+		h.flash.Add(w, r, flash.Error, msg)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+
+	item, part, errs := parseMissingItem(r)
+	if errs != nil {
+		fail(item, errs.Error(), errs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if errors.Is(err, service.ErrNoConnection) {
+		fail(item, err.Error(), nil)
+		return
+	}
+	if h.redirectToReconnect(w, r, err) {
+		return
+	}
+	if err == nil {
+		err = h.xc.UpsertItemsBatch(ctx, creds.AccessToken, creds.TenantID, []xero.Part{part})
+	}
+	if err != nil {
+		if !fragment && h.renderUnavailable(w, r, "Xero", err) {
+			return
+		}
+		fail(item, "Could not create item "+item.Code+": "+errorText("Xero", err), nil)
+		return
+	}
+	log.Printf("createXeroItem: owner %s created item %s", ownerID, item.Code)
+
+	h.getInvoiceHandler(w, r)
+}
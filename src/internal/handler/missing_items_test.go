@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestCreateMissingXeroItem(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{msg: "item BRACKET not found in Xero"}
+	hs.store.parts["BRACKET"] = xero.Part{PartID: "BRACKET", Name: "Corner bracket", Description: "40 series", CostPrice: 1.15}
+	var posted string
+	hs.xero.HandleFunc("GET /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Items":[]}`)
+	})
+	hs.xero.HandleFunc("POST /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		posted = string(b)
+		// the item now exists, so the invoice resolves
+		hs.store.mu.Lock()
+		hs.store.invoices["INV-1"] = resolvedInvoice{
+			perAssy:    []service.BOMNode{{PartID: "BRACKET", Name: "Corner bracket", Quantity: 2}},
+			leafTotals: []service.LeafTotal{{PartID: "BRACKET", Name: "Corner bracket", Quantity: 2}},
+		}
+		hs.store.mu.Unlock()
+		_, _ = io.WriteString(w, `{"Items":[]}`)
+	})
+
+	// the fragment offers the form, prefilled from the parts table
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "ignore_stock": {"1"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{`action="/xero/items/create"`, `name="code" value="BRACKET"`, `name="name" value="Corner bracket"`, `value="1.15"`, `name="invoice_id" value="INV-1"`, `name="ignore_stock"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("fragment missing %q:\n%s", want, body)
+		}
+	}
+
+	// without htmx it stays a warning
+	rec = hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}})
+	expectRedirect(t, rec, "/")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "item BRACKET not found in Xero" {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+
+	rec = hs.do(http.MethodPost, "/xero/items/create", url.Values{
+		"invoice_id":     {"INV-1"},
+		"ignore_stock":   {"1"},
+		"code":           {"BRACKET"},
+		"name":           {"Corner bracket"},
+		"description":    {"40 series"},
+		"purchase_price": {"1.15"},
+	}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	if want := `{"Items":[{"Code":"BRACKET","Name":"Corner bracket","Description":"40 series","PurchaseDetails":{"UnitPrice":1.15}}]}`; posted != want {
+		t.Fatalf("posted %s\nwant %s", posted, want)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "/invoice/INV-1/bom.pdf") || strings.Contains(body, "/xero/items/create") {
+		t.Fatalf("invoice not resolved again:\n%s", body)
+	}
+}
+
+func TestCreateMissingXeroItem_Invalid(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.xero.HandleFunc("POST /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("nothing should be posted to Xero")
+	})
+
+	rec := hs.do(http.MethodPost, "/xero/items/create", url.Values{"invoice_id": {"INV-1"}, "code": {"BRACKET"}, "name": {"Bracket"}, "purchase_price": {"-1"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	if !strings.Contains(body, `<span class="block text-xs text-red-600">Purchase price must be a number of at least 0</span>`) || !strings.Contains(body, `name="name" value="Bracket"`) {
+		t.Fatalf("form not shown again with the error:\n%s", body)
+	}
+
+	rec = hs.do(http.MethodPost, "/xero/items/create", url.Values{"invoice_id": {"INV-1"}, "code": {"BRACKET"}})
+	expectRedirect(t, rec, "/")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "Name is required" {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+}
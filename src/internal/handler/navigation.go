@@ -2,61 +2,32 @@ package handler
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"html/template"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/hwalton/xero-invoice-orderer/internal/frontend"
 	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
 	"github.com/hwalton/xero-invoice-orderer/internal/service"
-	"github.com/hwalton/xero-invoice-orderer/internal/utils"
 )
 
-// login serves the login page via templates
-func (h *Handler) loginHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	// render using parsed templates; pass any dynamic data here
-	data := map[string]interface{}{
-		"Title": "Login — Business",
-	}
-	if h.templates != nil {
-		_ = h.templates.ExecuteTemplate(w, "login.html", data)
-		return
-	}
-	// fallback: embedded raw file (if templates not provided)
-	if b, err := frontend.TemplatesFS.ReadFile("templates/login.html"); err == nil {
-		_, _ = w.Write(b)
-		return
-	}
-	http.Error(w, "template error", http.StatusInternalServerError)
-}
-
-// logoutHandler clears auth cookies and redirects to /login
-func (h *Handler) logoutHandler(w http.ResponseWriter, r *http.Request) {
-	names := []string{"access_token", "refresh_token", "current_card_id", "review_ahead_days", "max_new_cards_per_day"}
-	for _, n := range names {
-		utils.ClearCookie(w, r, n)
-	}
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
-}
-
 func (h *Handler) homeHandler(w http.ResponseWriter, r *http.Request) {
 	// ensure middleware helper populates ctxUserID when possible
 	if h.auth != nil {
 		r = mid.EnsureUserIDInContext(r, h.auth)
 	}
 	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	// load connections for the user so template can render them server-side
-	var conns []service.XeroConnection
-	if userID != "" && h.dbURL != "" {
+	// load the workspace's connections so template can render them server-side
+	var conns []service.ConnectionInfo
+	if ownerID != "" && h.dbURL != "" {
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
-		if c, err := service.GetConnectionsForOwner(ctx, h.dbURL, userID); err == nil {
+		if c, err := service.GetConnectionsForOwner(ctx, h.dbURL, ownerID); err == nil {
 			conns = c
 		}
 	}
@@ -71,54 +42,23 @@ func (h *Handler) homeHandler(w http.ResponseWriter, r *http.Request) {
 		createdAt = conns[0].CreatedAt
 	}
 
-	// Build sync message from short-lived cookie (set after successful sync)
-	var xeroSyncMsg string
-	if c, err := r.Cookie("xero_sync_msg"); err == nil && c.Value != "" {
-		xeroSyncMsg = c.Value
-		// clear the cookie so the message is shown only once
-		utils.ClearCookie(w, r, "xero_sync_msg")
-	}
-
-	// read invoice views from cookies
-	var invoiceNumber string
-	if c, err := r.Cookie("xero_invoice_number"); err == nil && c.Value != "" {
-		invoiceNumber = c.Value
-		utils.ClearCookie(w, r, "xero_invoice_number")
-	}
-
-	decode := func(name string, dst any) {
-		if c, err := r.Cookie(name); err == nil && c.Value != "" {
-			if decoded, derr := base64.StdEncoding.DecodeString(c.Value); derr == nil {
-				_ = json.Unmarshal(decoded, dst)
-			}
-			utils.ClearCookie(w, r, name)
-		}
-	}
-
-	var perAssyBOM []service.BOMNode
-	type leafTotal struct {
-		PartID   string  `json:"part_id"`
-		Name     string  `json:"name"`
-		Quantity float64 `json:"quantity"`
-	}
-	var leafTotals []leafTotal
-
-	decode("xero_perassy_bom", &perAssyBOM)
-	decode("xero_leaf_totals", &leafTotals)
+	// invoice results saved by the invoice form before redirecting here
+	view, _ := h.takeInvoiceView(w, r, ownerID)
 
 	data := map[string]interface{}{
 		"Title":             "Home",
 		"UserID":            userID,
+		"Workspace":         currentWorkspace(r).Name,
 		"HasXeroConnection": hasXeroConn,
 		"XeroTenantID":      tenantID,
 		"XeroCreatedAt":     createdAt,
-		"XeroSyncMessage":   xeroSyncMsg,
-
-		// new view data
-		"PerAssemblyBOM": perAssyBOM,
-		"LeafTotals":     leafTotals,
-		"InvoiceNumber":  invoiceNumber,
+		"Flash":             h.flash.Pop(w, r),
+		"CSRFToken":         mid.CSRFToken(r),
+		"IsAdmin":           isAdmin(r) && h.admin != nil,
 	}
+	h.addInvoiceView(r.Context(), data, ownerID, view)
+	h.addReadyInvoices(r.Context(), data, ownerID)
+	h.addOrderHold(r.Context(), data, ownerID)
 
 	if h.templates != nil {
 		if err := h.templates.ExecuteTemplate(w, "home.html", data); err != nil {
@@ -129,7 +69,7 @@ func (h *Handler) homeHandler(w http.ResponseWriter, r *http.Request) {
 
 	// fallback: render embedded template file if parsed templates not provided
 	if b, err := frontend.TemplatesFS.ReadFile("templates/home.html"); err == nil {
-		t, err := template.New("home").Parse(string(b))
+		t, err := template.New("home").Funcs(frontend.Funcs()).Parse(string(b))
 		if err != nil {
 			http.Error(w, "template error", http.StatusInternalServerError)
 			return
@@ -143,3 +83,45 @@ func (h *Handler) homeHandler(w http.ResponseWriter, r *http.Request) {
 
 	http.Error(w, "template error", http.StatusInternalServerError)
 }
+
+// addInvoiceView adds the invoice lookup results rendered by the "invoice-bom.html"
+// partial, with part attachments, material cost and the owner's currency filled in.
+func (h *Handler) addInvoiceView(ctx context.Context, data map[string]interface{}, ownerID string, v invoiceView) {
+	perAssyBOM, leafTotals := v.PerAssy, v.LeafTotals
+	// show part photos/datasheets next to the BOM and pick totals
+	if len(perAssyBOM) > 0 && h.dbURL != "" {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if byItem, err := service.ListPartAttachments(ctx, h.dbURL, ownerID, service.BOMPartIDs(perAssyBOM)); err == nil {
+			service.AnnotateBOMAttachments(perAssyBOM, byItem)
+			for i := range leafTotals {
+				leafTotals[i].Attachments = byItem[leafTotals[i].PartID]
+			}
+		}
+	}
+
+	materialCost, costIncomplete := service.BOMTotalCost(perAssyBOM)
+
+	data["PerAssemblyBOM"] = perAssyBOM
+	data["LeafTotals"] = leafTotals
+	data["InvoiceNumber"] = v.InvoiceNumber
+	data["InvoiceNumbers"] = service.ParseInvoiceNumbers(v.InvoiceNumber)
+	data["IgnoreStock"] = v.IgnoreStock
+	data["Expand"] = v.Expand
+	data["Substitute"] = v.Substitute
+	data["ExportQuery"] = ""
+	if q := (url.Values{"expand": v.Expand, "substitute": v.Substitute}).Encode(); q != "" {
+		data["ExportQuery"] = "?" + q
+	}
+	if h.store != nil {
+		// the same export, kept on the downloads page
+		data["KeepQuery"] = "?" + (url.Values{"expand": v.Expand, "substitute": v.Substitute, "persist": {"1"}}).Encode()
+	}
+	data["MaterialCost"] = materialCost
+	data["MaterialCostIncomplete"] = costIncomplete
+	if len(perAssyBOM) > 0 || len(leafTotals) > 0 {
+		loc := h.localeForOwner(ctx, ownerID)
+		data["Currency"] = loc.Currency
+		data["Locale"] = loc
+	}
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	"github.com/hwalton/xero-invoice-orderer/internal/notify"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// orderWindowStore tracks when held orders were last released (order_releases).
+type orderWindowStore interface {
+	GetOrderRelease(ctx context.Context, ownerID string) (int64, error)
+	RecordOrderRelease(ctx context.Context, ownerID, releasedBy string) error
+	ListHoldingOwners(ctx context.Context) ([]string, error)
+}
+
+func (s dbStore) GetOrderRelease(ctx context.Context, ownerID string) (int64, error) {
+	return service.GetOrderRelease(ctx, s.dbURL, ownerID)
+}
+
+func (s dbStore) RecordOrderRelease(ctx context.Context, ownerID, releasedBy string) error {
+	return service.RecordOrderRelease(ctx, s.dbURL, ownerID, releasedBy)
+}
+
+func (s dbStore) ListHoldingOwners(ctx context.Context) ([]string, error) {
+	return service.ListHoldingOwners(ctx, s.dbURL)
+}
+
+// orderHold returns the owner's settings and whether purchase orders may be raised
+// now: always when orders are not held, else once a scheduled release has passed
+// since the last one.
+func (h *Handler) orderHold(ctx context.Context, ownerID string) (service.OwnerSettings, bool, error) {
+	settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+	if err != nil || !settings.HoldOrders {
+		return settings, err == nil, err
+	}
+	releasedAt, err := h.releases.GetOrderRelease(ctx, ownerID)
+	if err != nil {
+		return settings, false, err
+	}
+	return settings, settings.ReleaseDue(releasedAt, time.Now()), nil
+}
+
+// formatRelease writes the owner's next scheduled release for a message.
+func (h *Handler) formatRelease(ctx context.Context, ownerID string, settings service.OwnerSettings) string {
+	return h.localeForOwner(ctx, ownerID).DateTime(settings.NextRelease(time.Now()))
+}
+
+// recordRelease starts a new window after held orders were raised. A failure is only
+// logged: the next release then raises whatever was ordered since as well.
+func (h *Handler) recordRelease(ctx context.Context, ownerID, releasedBy string) {
+	if err := h.releases.RecordOrderRelease(ctx, ownerID, releasedBy); err != nil {
+		log.Printf("record order release: owner=%s: %v", ownerID, err)
+	}
+}
+
+// releaseOrdersHandler raises the owner's held orders now ("Release now") instead of
+// at the next scheduled release, one purchase order per supplier as usual.
+func (h *Handler) releaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	h.createPurchaseOrders(w, r, true)
+}
+
+// addOrderHold adds when held orders will be released (HeldUntil) for the home page,
+// while the owner holds orders and the release is not due yet. A failure only hides it.
+func (h *Handler) addOrderHold(ctx context.Context, data map[string]interface{}, ownerID string) {
+	if ownerID == "" || h.settings == nil || h.releases == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	settings, due, err := h.orderHold(ctx, ownerID)
+	if err != nil {
+		log.Printf("order hold: owner=%s: %v", ownerID, err)
+		return
+	}
+	if due {
+		return
+	}
+	data["HeldUntil"] = settings.NextRelease(time.Now())
+	if _, ok := data["Locale"]; !ok {
+		data["Locale"] = h.localeForOwner(ctx, ownerID)
+	}
+}
+
+// logReply reports purchase orders raised by a scheduled release to the log.
+type logReply struct {
+	ownerID string
+	failed  bool
+}
+
+func (rep *logReply) Flash(level flash.Level, msg string, _ ...flash.Link) {
+	rep.failed = rep.failed || level == flash.Error
+	log.Printf("release-held-orders: owner=%s: %s", rep.ownerID, msg)
+}
+
+func (rep *logReply) Done(string) {}
+
+func (rep *logReply) Fail(_ int, msg string, err error) {
+	rep.failed = true
+	log.Printf("release-held-orders: owner=%s: %s: %v", rep.ownerID, msg, err)
+}
+
+func (rep *logReply) Failed() bool { return rep.failed }
+
+// ReleaseHeldOrders returns the job that raises the held orders of every owner whose
+// release is due, as "Create Purchase Orders" would: one purchase order per supplier,
+// held for an approver when over the threshold. An owner whose run fails is retried
+// on the next run; the others are not held up.
+func ReleaseHeldOrders(cfg *config.Config, xc *xero.Client, events *notify.Events) func(context.Context) error {
+	db := dbStore{dbURL: cfg.DatabaseURL}
+	h := &Handler{
+		cfg:       cfg,
+		xc:        xc,
+		dbURL:     cfg.DatabaseURL,
+		events:    events,
+		tokens:    service.NewTokenManager(cfg.DatabaseURL, xc, cfg.Xero.ClientID, cfg.Xero.ClientSecret),
+		orders:    db,
+		settings:  db,
+		approvals: db,
+		releases:  db,
+		lookups:   service.SharedCache(),
+	}
+	return h.releaseHeldOrders
+}
+
+func (h *Handler) releaseHeldOrders(ctx context.Context) error {
+	owners, err := h.releases.ListHoldingOwners(ctx)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, ownerID := range owners {
+		if err := h.releaseIfDue(ctx, ownerID); err != nil {
+			log.Printf("release-held-orders: owner=%s: %v", ownerID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d owners failed", failed, len(owners))
+	}
+	return nil
+}
+
+// releaseIfDue raises the owner's held orders when their release is due and starts
+// the next window.
+func (h *Handler) releaseIfDue(ctx context.Context, ownerID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	settings, due, err := h.orderHold(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if !settings.HoldOrders || !due {
+		return nil
+	}
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	rep := &logReply{ownerID: ownerID}
+	h.raisePurchaseOrders(ctx, rep, nil, ownerID, service.ReleasedBySchedule, creds)
+	if rep.Failed() {
+		return fmt.Errorf("purchase orders not raised")
+	}
+	return h.releases.RecordOrderRelease(ctx, ownerID, service.ReleasedBySchedule)
+}
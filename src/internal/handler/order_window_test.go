@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xerotest"
+)
+
+// holdHarness is a harness whose shopping list makes one PO, for an owner who holds
+// orders for a weekly release and last released them at releasedAt.
+func holdHarness(t *testing.T, releasedAt int64) (*harness, *xerotest.Server) {
+	t.Helper()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}, {ListID: 2, ItemID: "NUT", Quantity: 8}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}, {ItemID: "NUT", Quantity: 8, ListIDs: []int{2}}},
+	}
+	settings := service.DefaultOwnerSettings()
+	settings.HoldOrders = true
+	hs.store.settings[testOwnerID] = settings
+	hs.store.releasedAt[testOwnerID] = releasedAt
+	return hs, fx
+}
+
+func TestOrderWindow_Held(t *testing.T) {
+	t.Parallel()
+	hs, fx := holdHarness(t, time.Now().Unix())
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "Orders are held until") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if n := len(fx.PurchaseOrders()); n != 0 || len(hs.store.ordered) != 0 {
+		t.Fatalf("%d PO(s) sent, ordered %v while held", n, hs.store.ordered)
+	}
+	data := map[string]interface{}{"HasXeroConnection": true, "CSRFToken": testCSRFToken}
+	hs.handler.addOrderHold(context.Background(), data, testOwnerID)
+	var body strings.Builder
+	if err := hs.handler.templates.ExecuteTemplate(&body, "home.html", data); err != nil {
+		t.Fatalf("render home: %v", err)
+	}
+	if !strings.Contains(body.String(), `action="/purchase-orders/release"`) || !strings.Contains(body.String(), "Held until") {
+		t.Fatalf("home page has no Release now:\n%s", body.String())
+	}
+
+	// the scheduled release is not due either
+	if err := hs.handler.releaseHeldOrders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fx.PurchaseOrders()); n != 0 {
+		t.Fatalf("%d PO(s) sent before the release", n)
+	}
+
+	rec = hs.do(http.MethodPost, "/purchase-orders/release", url.Values{})
+	expectRedirect(t, rec, "/")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "Created 1 purchase order(s), 2 shopping list rows marked ordered") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if n := len(fx.PurchaseOrders()); n != 1 {
+		t.Fatalf("%d PO(s) sent on release", n)
+	}
+	if !slices.Equal(hs.store.releasedBy, []string{testOwnerID}) {
+		t.Fatalf("releases = %v", hs.store.releasedBy)
+	}
+}
+
+func TestOrderWindow_ScheduledRelease(t *testing.T) {
+	t.Parallel()
+	hs, fx := holdHarness(t, time.Now().AddDate(0, 0, -8).Unix())
+
+	if err := hs.handler.releaseHeldOrders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(fx.PurchaseOrders()); n != 1 || len(hs.store.ordered) != 2 {
+		t.Fatalf("%d PO(s) sent, ordered %v", n, hs.store.ordered)
+	}
+	if !slices.Equal(hs.store.releasedBy, []string{service.ReleasedBySchedule}) {
+		t.Fatalf("releases = %v", hs.store.releasedBy)
+	}
+
+	// the next window has started: nothing more until its release
+	if err := hs.handler.releaseHeldOrders(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(hs.store.releasedBy) != 1 {
+		t.Fatalf("released again: %v", hs.store.releasedBy)
+	}
+}
+
+func TestOrderWindow_ScheduledReleaseFails(t *testing.T) {
+	t.Parallel()
+	hs, fx := holdHarness(t, 0)
+	hs.store.groupErr = errors.New("no contact mapping found for item BOLT")
+
+	if err := hs.handler.releaseHeldOrders(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := len(fx.PurchaseOrders()); n != 0 || len(hs.store.releasedBy) != 0 {
+		t.Fatalf("%d PO(s) sent, releases %v", n, hs.store.releasedBy)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	"github.com/hwalton/xero-invoice-orderer/internal/locale"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// organisation returns the Xero organisation behind creds, for its base currency and
+// deep links. Pages only decorate with it, so failures give the zero Organisation:
+// amounts without a currency and links that open in the user's current organisation.
+func (h *Handler) organisation(ctx context.Context, creds service.XeroCredentials) xero.Organisation {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	org, err := service.XeroOrganisation(xero.WithRetryPolicy(ctx, xero.NoRetry), h.lookups, h.xc, creds.AccessToken, creds.TenantID)
+	if err != nil {
+		log.Printf("xero organisation for tenant %s: %v", creds.TenantID, err)
+	}
+	return org
+}
+
+// poLinks links each created purchase order to Xero, labelled by supplier.
+func (h *Handler) poLinks(ctx context.Context, creds service.XeroCredentials, pos []createdPO) []flash.Link {
+	if len(pos) == 0 {
+		return nil
+	}
+	org := h.organisation(ctx, creds)
+	links := make([]flash.Link, 0, len(pos))
+	for _, po := range pos {
+		if po.XeroPOID != "" {
+			links = append(links, flash.Link{Text: "Open " + po.AccountNumber + " PO in Xero", URL: org.PurchaseOrderURL(po.XeroPOID)})
+		}
+	}
+	return links
+}
+
+// localeForOwner returns how the owner's Xero organisation writes numbers and times,
+// with its base currency. When that cannot be found out it is locale.Default, and
+// amounts are shown without a currency.
+func (h *Handler) localeForOwner(ctx context.Context, ownerID string) locale.Locale {
+	if ownerID == "" || h.tokens == nil {
+		return locale.Default
+	}
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		return locale.Default
+	}
+	return orgLocale(h.organisation(ctx, creds))
+}
+
+// orgLocale is org's locale; the zero Organisation gives locale.Default.
+func orgLocale(org xero.Organisation) locale.Locale {
+	return locale.For(org.CountryCode, org.BaseCurrency)
+}
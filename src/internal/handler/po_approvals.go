@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// approvalStore keeps purchase order batches held for approval (po_approvals).
+type approvalStore interface {
+	RequestPOApproval(ctx context.Context, ownerID, requestedBy string, batch service.POBatch) (int, error)
+	GetPOApproval(ctx context.Context, ownerID string, id int) (service.POApproval, error)
+	ListPOApprovals(ctx context.Context, ownerID string, limit int) ([]service.POApproval, error)
+	DecidePOApproval(ctx context.Context, ownerID string, id int, decidedBy string, approve bool) (service.POApproval, error)
+}
+
+func (s dbStore) RequestPOApproval(ctx context.Context, ownerID, requestedBy string, batch service.POBatch) (int, error) {
+	return service.RequestPOApproval(ctx, s.dbURL, ownerID, requestedBy, batch)
+}
+
+func (s dbStore) GetPOApproval(ctx context.Context, ownerID string, id int) (service.POApproval, error) {
+	return service.GetPOApproval(ctx, s.dbURL, ownerID, id)
+}
+
+func (s dbStore) ListPOApprovals(ctx context.Context, ownerID string, limit int) ([]service.POApproval, error) {
+	return service.ListPOApprovals(ctx, s.dbURL, ownerID, limit)
+}
+
+func (s dbStore) DecidePOApproval(ctx context.Context, ownerID string, id int, decidedBy string, approve bool) (service.POApproval, error) {
+	return service.DecidePOApproval(ctx, s.dbURL, ownerID, id, decidedBy, approve)
+}
+
+// decidedApprovalsShown is how many approved or rejected batches the approvals page
+// lists below the pending one.
+const decidedApprovalsShown = 20
+
+// requestPOApproval holds batch for an approver instead of sending it, and tells the
+// user so.
+func (h *Handler) requestPOApproval(ctx context.Context, rep poReply, ownerID, userID string, batch service.POBatch, settings service.OwnerSettings) {
+	_, err := h.approvals.RequestPOApproval(ctx, ownerID, userID, batch)
+	switch {
+	case errors.Is(err, service.ErrApprovalPending):
+		rep.Flash(flash.Error, "Purchase orders not created: "+err.Error()+". Approve or reject it first.",
+			flash.Link{Text: "Approvals", URL: "/purchase-orders/approvals"})
+	case err != nil:
+		rep.Fail(http.StatusInternalServerError, "failed to hold purchase orders for approval", err)
+		return
+	default:
+		scope := "in total"
+		if settings.ApprovalScope == service.ApprovalScopePO {
+			scope = "for one supplier"
+		}
+		rep.Flash(flash.Info, fmt.Sprintf("%d purchase order(s) worth %.2f are over the approval threshold of %.2f %s and wait for an approver; nothing was sent to Xero.",
+			len(batch.POs), batch.Total(), settings.ApprovalThreshold, scope),
+			flash.Link{Text: "Approvals", URL: "/purchase-orders/approvals"})
+	}
+	rep.Done("/")
+}
+
+// poApprovalsHandler lists the batch awaiting approval, if any, and the ones most
+// recently decided.
+func (h *Handler) poApprovalsHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	approvals, err := h.approvals.ListPOApprovals(ctx, ownerID, decidedApprovalsShown)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load approvals", err)
+		return
+	}
+	self, _ := r.Context().Value(mid.CtxUserID).(string)
+	loc := h.localeForOwner(ctx, ownerID)
+	data := map[string]interface{}{
+		"Title":      "Purchase order approvals",
+		"Approvals":  approvals,
+		"CanApprove": canApprove(r),
+		"Self":       self,
+		"Currency":   loc.Currency,
+		"Locale":     loc,
+		"Flash":      h.flash.Pop(w, r),
+		"CSRFToken":  mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "po_approvals.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// decidePOApprovalHandler approves or rejects a held batch ({action} approve|reject).
+// Only an approver (or admin) other than the user who created the batch may. An
+// approved batch is sent to Xero at once, in the organisation it was planned for.
+func (h *Handler) decidePOApprovalHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, "invalid approval id", err)
+		return
+	}
+	if !canApprove(r) {
+		h.renderError(w, r, http.StatusForbidden, "only an approver can approve or reject purchase orders", nil)
+		return
+	}
+	approve := chi.URLParam(r, "action") == "approve"
+	// as createPurchaseOrders: inside the server's 30s write timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
+	defer cancel()
+	ctx, finish := h.trackProgress(ctx, r, ownerID)
+	defer finish()
+
+	back := func(level flash.Level, msg string) {
+		h.flash.Add(w, r, level, msg)
+		http.Redirect(w, r, "/purchase-orders/approvals", http.StatusSeeOther)
+	}
+
+	var creds service.XeroCredentials
+	if approve {
+		// the batch is only claimed once it can be sent to the organisation it was
+		// planned for
+		pending, err := h.approvals.GetPOApproval(ctx, ownerID, id)
+		if err != nil {
+			status, msg := errorStatus("Supabase", err)
+			if status == http.StatusInternalServerError {
+				msg = "failed to load approval"
+			}
+			h.renderError(w, r, status, msg, err)
+			return
+		}
+		if creds, err = h.tokens.CredentialsForOwner(ctx, ownerID); err != nil {
+			h.xeroError(w, r, "", err)
+			return
+		}
+		if pending.Batch.TenantID != creds.TenantID {
+			back(flash.Error, "This batch was planned for a different Xero organisation than the one connected now. Reject it and create the purchase orders again.")
+			return
+		}
+	}
+
+	a, err := h.approvals.DecidePOApproval(ctx, ownerID, id, userID, approve)
+	switch {
+	case errors.Is(err, service.ErrApprovalDecided), errors.Is(err, service.ErrSelfApproval):
+		back(flash.Error, err.Error()+".")
+		return
+	case err != nil:
+		status, msg := errorStatus("Supabase", err)
+		if status == http.StatusInternalServerError {
+			msg = "failed to decide approval"
+		}
+		h.renderError(w, r, status, msg, err)
+		return
+	}
+	if !approve {
+		back(flash.Info, fmt.Sprintf("Rejected %d purchase order(s); their shopping list rows stay unordered.", len(a.Batch.POs)))
+		return
+	}
+	h.sendPurchaseOrders(ctx, h.reply(w, r), ownerID, creds, a.Batch)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xerotest"
+)
+
+// approvalHarness is a harness whose shopping list makes one PO worth 1.30 (4 BOLT
+// at 0.20, 10 NUT at 0.05) against an approval threshold of 1, in a workspace the
+// admin (an approver) also belongs to.
+func approvalHarness(t *testing.T) (*harness, *xerotest.Server) {
+	t.Helper()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}, {ListID: 2, ItemID: "NUT", Quantity: 8}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {
+			{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}},
+			{ItemID: "NUT", Quantity: 8, ListIDs: []int{2}, Terms: service.SupplierTerms{PackSize: 10, UnitPrice: 0.05}},
+		},
+	}
+	settings := service.DefaultOwnerSettings()
+	settings.ApprovalThreshold = 1
+	hs.store.settings[testOwnerID] = settings
+	hs.store.workspaces[testOwnerID] = &fakeWorkspace{name: "Personal", members: map[string]string{
+		testOwnerID: service.WorkspaceOwner,
+		testAdminID: service.WorkspaceMember,
+	}}
+	return hs, fx
+}
+
+func TestPOApproval(t *testing.T) {
+	t.Parallel()
+	hs, fx := approvalHarness(t)
+	asApprover := func(r *http.Request) { asAdmin(r); inWorkspace(testOwnerID)(r) }
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+	if n := len(fx.PurchaseOrders()); n != 0 {
+		t.Fatalf("%d PO(s) sent to Xero before approval", n)
+	}
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "worth 1.30 are over the approval threshold of 1.00") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if len(hs.store.approvals) != 1 || hs.store.approvals[0].RequestedBy != testOwnerID || len(hs.store.ordered) != 0 {
+		t.Fatalf("approvals = %+v, ordered = %v", hs.store.approvals, hs.store.ordered)
+	}
+	if lines := hs.store.approvals[0].Batch.POs[0].Lines; len(lines) != 2 || lines[1].Quantity != 10 {
+		t.Fatalf("held lines = %+v", lines)
+	}
+
+	t.Run("one batch waits at a time", func(t *testing.T) {
+		rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "already awaiting approval") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+		if len(hs.store.approvals) != 1 {
+			t.Fatalf("%d approvals", len(hs.store.approvals))
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		body := hs.do(http.MethodGet, "/purchase-orders/approvals", nil).Body.String()
+		if !strings.Contains(body, "Waiting for another user") || strings.Contains(body, "/purchase-orders/approvals/1/approve") {
+			t.Fatalf("requester's approvals page:\n%s", body)
+		}
+		body = hs.do(http.MethodGet, "/purchase-orders/approvals", nil, asApprover).Body.String()
+		if !strings.Contains(body, "/purchase-orders/approvals/1/approve") || !strings.Contains(body, "SUP-1") {
+			t.Fatalf("approver's approvals page:\n%s", body)
+		}
+	})
+
+	t.Run("requester cannot approve", func(t *testing.T) {
+		expectStatus(t, hs.do(http.MethodPost, "/purchase-orders/approvals/1/approve", url.Values{}), http.StatusForbidden)
+	})
+
+	t.Run("approver sends it", func(t *testing.T) {
+		rec := hs.do(http.MethodPost, "/purchase-orders/approvals/1/approve", url.Values{}, asApprover)
+		expectRedirect(t, rec, "/")
+		if n := len(fx.PurchaseOrders()); n != 1 {
+			t.Fatalf("%d PO(s) in Xero, want 1", n)
+		}
+		if fmt.Sprint(hs.store.ordered) != "[1 2]" || hs.store.approvals[0].Status != service.ApprovalApproved {
+			t.Fatalf("ordered = %v, approval = %+v", hs.store.ordered, hs.store.approvals[0])
+		}
+	})
+
+	t.Run("only once", func(t *testing.T) {
+		rec := hs.do(http.MethodPost, "/purchase-orders/approvals/1/approve", url.Values{}, asApprover)
+		expectRedirect(t, rec, "/purchase-orders/approvals")
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "already decided") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+		if n := len(fx.PurchaseOrders()); n != 1 {
+			t.Fatalf("%d PO(s) in Xero, want 1", n)
+		}
+	})
+}
+
+func TestPOApproval_Reject(t *testing.T) {
+	t.Parallel()
+	hs, fx := approvalHarness(t)
+	expectRedirect(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}), "/")
+
+	rec := hs.do(http.MethodPost, "/purchase-orders/approvals/1/reject", url.Values{}, asAdmin, inWorkspace(testOwnerID))
+	expectRedirect(t, rec, "/purchase-orders/approvals")
+	if n := len(fx.PurchaseOrders()); n != 0 || len(hs.store.ordered) != 0 || hs.store.approvals[0].Status != service.ApprovalRejected {
+		t.Fatalf("%d PO(s) in Xero, ordered = %v, approval = %+v", n, hs.store.ordered, hs.store.approvals[0])
+	}
+}
+
+func TestPOApproval_NotSelf(t *testing.T) {
+	t.Parallel()
+	hs, fx := approvalHarness(t)
+	expectRedirect(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}, asAdmin, inWorkspace(testOwnerID)), "/")
+
+	rec := hs.do(http.MethodPost, "/purchase-orders/approvals/1/approve", url.Values{}, asAdmin, inWorkspace(testOwnerID))
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "someone other than who requested it") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if n := len(fx.PurchaseOrders()); n != 0 {
+		t.Fatalf("%d PO(s) in Xero after self-approval", n)
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"log"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// poTracking assigns purchase order lines to the option of the owner's tracking
+// category named after their source invoice, creating options Xero doesn't have
+// yet. A nil *poTracking leaves every line untracked.
+type poTracking struct {
+	xc       *xero.Client
+	creds    service.XeroCredentials
+	category xero.TrackingCategory
+	failed   int // lines left untracked because their option could not be created
+}
+
+// newPOTracking loads the tracking category called name. It returns nil when name
+// is "" or Xero has no such category.
+func newPOTracking(ctx context.Context, xc *xero.Client, creds service.XeroCredentials, name string) (*poTracking, error) {
+	if name == "" {
+		return nil, nil
+	}
+	cats, err := xc.GetTrackingCategories(ctx, creds.AccessToken, creds.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	tc, ok := xero.FindTrackingCategory(cats, name)
+	if !ok {
+		return nil, nil
+	}
+	return &poTracking{xc: xc, creds: creds, category: tc}, nil
+}
+
+// line is the Tracking of a PO line raised for sourceInvoice ("" = untracked).
+func (t *poTracking) line(ctx context.Context, sourceInvoice string) []xero.LineTracking {
+	if t == nil || sourceInvoice == "" {
+		return nil
+	}
+	o, ok := t.category.Option(sourceInvoice)
+	if !ok {
+		var err error
+		o, err = t.xc.CreateTrackingOption(ctx, t.creds.AccessToken, t.creds.TenantID, t.category.TrackingCategoryID, sourceInvoice)
+		if err != nil {
+			log.Printf("createPurchaseOrders: create tracking option %q: %v", sourceInvoice, err)
+			t.failed++
+			return nil
+		}
+		t.category.Options = append(t.category.Options, o)
+	}
+	return []xero.LineTracking{{Name: t.category.Name, Option: o.Name}}
+}
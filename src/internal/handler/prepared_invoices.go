@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// preparedStore lists the invoices the invoice poll resolved ahead of time
+// (prepared_invoices).
+type preparedStore interface {
+	ListPreparedInvoices(ctx context.Context, ownerID string, limit int) ([]service.PreparedInvoice, error)
+}
+
+func (s dbStore) ListPreparedInvoices(ctx context.Context, ownerID string, limit int) ([]service.PreparedInvoice, error) {
+	return service.ListPreparedInvoices(ctx, s.dbURL, ownerID, limit)
+}
+
+// readyInvoicesShown is how many prepared invoices the home page lists.
+const readyInvoicesShown = 10
+
+// addReadyInvoices adds the invoices authorised in Xero that are not on the shopping
+// list yet, for the home page's "Ready to order" list. A failure only hides the list.
+func (h *Handler) addReadyInvoices(ctx context.Context, data map[string]interface{}, ownerID string) {
+	if ownerID == "" || h.prepared == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	invs, err := h.prepared.ListPreparedInvoices(ctx, ownerID, readyInvoicesShown)
+	if err != nil {
+		log.Printf("list prepared invoices: owner=%s: %v", ownerID, err)
+		return
+	}
+	data["ReadyInvoices"] = invs
+	if _, ok := data["Locale"]; !ok && len(invs) > 0 {
+		data["Locale"] = h.localeForOwner(ctx, ownerID)
+	}
+}
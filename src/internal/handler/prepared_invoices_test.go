@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestReadyInvoices(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.prepared = []service.PreparedInvoice{
+		{InvoiceNumber: "INV-0042", Ready: true, Parts: 3, UpdatedAt: 1767225600},
+		{InvoiceNumber: "INV-0043", Message: "no parts list for WIDGET", UpdatedAt: 1767225000},
+	}
+
+	data := map[string]interface{}{"HasXeroConnection": true, "CSRFToken": testCSRFToken}
+	hs.handler.addReadyInvoices(context.Background(), data, testOwnerID)
+	var body strings.Builder
+	if err := hs.handler.templates.ExecuteTemplate(&body, "home.html", data); err != nil {
+		t.Fatalf("render home: %v", err)
+	}
+	for _, want := range []string{"Ready to order", `name="invoice_id" value="INV-0042"`, "3 part(s)", "no parts list for WIDGET"} {
+		if !strings.Contains(body.String(), want) {
+			t.Fatalf("home page lacks %q:\n%s", want, body.String())
+		}
+	}
+
+	t.Run("none ready", func(t *testing.T) {
+		hs.store.prepared = nil
+		data := map[string]interface{}{"HasXeroConnection": true}
+		hs.handler.addReadyInvoices(context.Background(), data, testOwnerID)
+		var body strings.Builder
+		if err := hs.handler.templates.ExecuteTemplate(&body, "home.html", data); err != nil {
+			t.Fatalf("render home: %v", err)
+		}
+		if strings.Contains(body.String(), "Ready to order") {
+			t.Fatalf("empty list shown:\n%s", body.String())
+		}
+	})
+}
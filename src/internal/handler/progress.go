@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+)
+
+// progressTTL is how long a request's progress is kept for its page to read.
+const progressTTL = 10 * time.Minute
+
+var (
+	// progressPoll is how often a progress stream looks for news.
+	progressPoll = 250 * time.Millisecond
+	// progressWait is how long one progress stream stays open, inside the server's
+	// 30s timeouts; the browser reconnects to carry on reading.
+	progressWait = 25 * time.Second
+)
+
+// progressIDPattern is the shape of the ids pages make up for their requests.
+var progressIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// progressKey is where the owner's request id keeps its progress in h.progress.
+func progressKey(ownerID, id string) string {
+	return "progress:" + ownerID + ":" + id
+}
+
+// trackProgress keeps what ctx reports (see service.ReportProgress) under the
+// progress_id the page sent with r, for progressHandler to stream. Call finish once
+// the response is written. Without an id (or a cache) nothing is kept.
+func (h *Handler) trackProgress(ctx context.Context, r *http.Request, ownerID string) (_ context.Context, finish func()) {
+	id := r.FormValue("progress_id")
+	if h.progress == nil || !progressIDPattern.MatchString(id) {
+		return ctx, func() {}
+	}
+	key := progressKey(ownerID, id)
+	var mu sync.Mutex
+	var last service.Progress
+	save := func(p service.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		last = p
+		// saved even when the request's own context has timed out
+		sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+		defer cancel()
+		if err := cache.SetJSON(sctx, h.progress, map[string]service.Progress{key: p}, progressTTL); err != nil {
+			log.Printf("progress %s: %v", id, err)
+		}
+	}
+	finish = func() {
+		mu.Lock()
+		p := last
+		mu.Unlock()
+		p.Finished = true
+		p.Done = p.Total
+		save(p)
+	}
+	return service.WithProgress(ctx, save), finish
+}
+
+// progressHandler streams the progress of one of the owner's requests as server-sent
+// "progress" events (JSON service.Progress), one per change, until the request
+// finishes, the browser goes away or progressWait passes. The page opens the stream
+// before it posts the request, so nothing is sent until the first report.
+func (h *Handler) progressHandler(w http.ResponseWriter, r *http.Request) {
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if h.progress == nil || !progressIDPattern.MatchString(id) {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would hold the events back
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	key := progressKey(ownerID, id)
+	ticker := time.NewTicker(progressPoll)
+	defer ticker.Stop()
+	deadline := time.After(progressWait)
+	var sent []byte
+	for {
+		got, err := h.progress.Get(r.Context(), key)
+		if err != nil {
+			log.Printf("progress %s: %v", id, err)
+		}
+		if b, ok := got[key]; ok && !bytes.Equal(b, sent) {
+			if _, err := fmt.Fprintf(w, "event: progress\ndata: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+			sent = b
+			var p service.Progress
+			if json.Unmarshal(b, &p) == nil && p.Finished {
+				return
+			}
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
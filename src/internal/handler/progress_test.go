@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// withContext sends the request with ctx.
+func withContext(ctx context.Context) reqOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(ctx)
+	}
+}
+
+func TestProgress_InvoiceResolution(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy:    []service.BOMNode{{PartID: "BOLT", Name: "Bolt", Quantity: 2}},
+		leafTotals: []service.LeafTotal{{PartID: "BOLT", Name: "Bolt", Quantity: 2}},
+	}
+	const id = "3f6c1b2a-progress"
+
+	expectStatus(t, hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "progress_id": {id}}, htmx), http.StatusOK)
+
+	rec := hs.do(http.MethodGet, "/progress/"+id, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	want := "event: progress\ndata: " + `{"message":"Resolving invoice INV-1 (1 of 1)","done":1,"total":1,"finished":true}` + "\n\n"
+	if body := rec.Body.String(); body != want {
+		t.Fatalf("stream:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestProgress_Stream(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	expectStatus(t, hs.do(http.MethodGet, "/progress/short", nil), http.StatusNotFound)
+	expectRedirect(t, hs.do(http.MethodGet, "/progress/abcdefgh", nil, anonymous), "/login")
+
+	// nothing reported yet: the stream waits, and ends when the browser goes away
+	ctx, cancel := context.WithTimeout(context.Background(), 3*progressPoll)
+	defer cancel()
+	start := time.Now()
+	rec := hs.do(http.MethodGet, "/progress/abcdefgh", nil, withContext(ctx))
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Body.Len() != 0 || time.Since(start) > progressWait/2 {
+		t.Fatalf("stream without progress sent %q after %s", rec.Body.String(), time.Since(start))
+	}
+
+	// a request that reports progress but has not finished is streamed as it stands
+	r := httptest.NewRequest(http.MethodPost, "/xero/create-pos", strings.NewReader("progress_id=abcdefgh"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx2, finish := hs.handler.trackProgress(context.Background(), r, testOwnerID)
+	service.ReportProgress(ctx2, 14, 37, "Resolved 14/37 items")
+	ctx, cancel = context.WithTimeout(context.Background(), 3*progressPoll)
+	defer cancel()
+	rec = hs.do(http.MethodGet, "/progress/abcdefgh", nil, withContext(ctx))
+	if body := rec.Body.String(); !strings.Contains(body, `"message":"Resolved 14/37 items","done":14,"total":37,"finished":false`) {
+		t.Fatalf("stream:\n%s", body)
+	}
+	finish()
+	rec = hs.do(http.MethodGet, "/progress/abcdefgh", nil)
+	if body := rec.Body.String(); !strings.Contains(body, `"done":37,"total":37,"finished":true`) {
+		t.Fatalf("finished stream:\n%s", body)
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/pkg/ratelimit"
+)
+
+// Public route rate limit metrics, served with the other expvars on /debug/vars.
+var (
+	// rateLimitChecked counts requests checked against the public limits, by route.
+	rateLimitChecked = expvar.NewMap("rate_limit_checked")
+	// rateLimitRejected counts requests refused with 429, by route.
+	rateLimitRejected = expvar.NewMap("rate_limit_rejected")
+)
+
+// publicLimits throttles the sign-in routes per client IP and the Xero callback per
+// signed-in user, each route counted on its own. A nil *publicLimits allows
+// everything.
+type publicLimits struct {
+	ip ratelimit.Limiter
+}
+
+// newPublicLimits builds the limits from cfg, keeping counts in Redis when
+// configured and in memory otherwise.
+func newPublicLimits(cfg config.PublicLimitConfig) *publicLimits {
+	return &publicLimits{
+		ip: ratelimit.Limiter{Store: newLimitStore(cfg.RedisURL, "public rate limits"), Prefix: "public:", Limit: cfg.PerIP, Window: cfg.Window},
+	}
+}
+
+// rateLimited counts each request to route and answers 429 Too Many Requests once it
+// is over the limit. Behind RequireAuth requests count against the signed-in user,
+// whatever address they come from; elsewhere against the client IP (see clientIP,
+// which only believes trusted proxies' forwarded headers). Store errors are logged
+// and let the request through.
+func (h *Handler) rateLimited(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.public == nil || h.public.ip.Limit < 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key, who := "ip="+clientIP(r), "your network"
+			if uid, _ := r.Context().Value(mid.CtxUserID).(string); uid != "" {
+				key, who = "user="+uid, "your account"
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+			wait, err := h.public.ip.Hit(ctx, route+":"+key)
+			cancel()
+			if err != nil {
+				log.Printf("public limits: %s: %v", route, err)
+			}
+			rateLimitChecked.Add(route, 1)
+			if wait == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rateLimitRejected.Add(route, 1)
+			log.Printf("public limits: %s throttled %s", route, key)
+			h.renderTooManyRequests(w, r, wait, who)
+		})
+	}
+}
+
+// renderTooManyRequests answers 429 with Retry-After, as a page unless the client
+// asked for JSON, which gets problem+json. who is what the requests were counted
+// against, for the message ("your network").
+func (h *Handler) renderTooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration, who string) {
+	setRetryAfter(w, wait)
+	msg := "Too many requests from " + who + ". Try again in " + waitText(wait) + "."
+	if h.templates == nil || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		h.renderError(w, r, http.StatusTooManyRequests, msg, nil)
+		return
+	}
+	back := ""
+	if r.Method == http.MethodGet {
+		back = r.URL.RequestURI()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = h.templates.ExecuteTemplate(w, "too_many_requests.html", map[string]any{
+		"Message": msg,
+		"Back":    back,
+	})
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"expvar"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+)
+
+// expvarCount is m[key] (0 when unset).
+func expvarCount(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestPublicLimits(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.handler.public = newPublicLimits(config.PublicLimitConfig{PerIP: 2, Window: time.Minute})
+	checked, rejected := expvarCount(rateLimitChecked, "login"), expvarCount(rateLimitRejected, "login")
+
+	for range 2 {
+		expectStatus(t, hs.do(http.MethodGet, "/login", nil, anonymous, fromIP("10.0.0.1")), http.StatusOK)
+	}
+	rec := hs.do(http.MethodGet, "/login", nil, anonymous, fromIP("10.0.0.1"))
+	expectStatus(t, rec, http.StatusTooManyRequests)
+	if rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), "Too many requests from your network.") {
+		t.Fatalf("Retry-After %q, body:\n%s", rec.Header().Get("Retry-After"), rec.Body.String())
+	}
+	if got := expvarCount(rateLimitChecked, "login") - checked; got != 3 {
+		t.Fatalf("checked %d requests, want 3", got)
+	}
+	if got := expvarCount(rateLimitRejected, "login") - rejected; got != 1 {
+		t.Fatalf("rejected %d requests, want 1", got)
+	}
+
+	// other addresses and routes have their own counts
+	expectStatus(t, hs.do(http.MethodGet, "/login", nil, anonymous, fromIP("10.0.0.2")), http.StatusOK)
+	if rec := hs.do(http.MethodGet, "/xero/callback?state=x&code=y", nil, fromIP("10.0.0.1")); rec.Code == http.StatusTooManyRequests {
+		t.Fatal("callback limited by the login page count")
+	}
+
+	// the callback counts per signed-in user, wherever they connect from: with the
+	// one above, the second of these is over the limit
+	for i, ip := range []string{"10.0.0.3", "10.0.0.4"} {
+		rec := hs.do(http.MethodGet, "/xero/callback?state=x&code=y", nil, fromIP(ip))
+		if limited := rec.Code == http.StatusTooManyRequests; limited != (i == 1) {
+			t.Fatalf("callback %d from %s: status %d", i+2, ip, rec.Code)
+		}
+		if i == 1 && !strings.Contains(rec.Body.String(), "Too many requests from your account.") {
+			t.Fatalf("body:\n%s", rec.Body.String())
+		}
+	}
+	if rec := hs.do(http.MethodGet, "/xero/callback?state=x&code=y", nil, asAdmin, fromIP("10.0.0.5")); rec.Code == http.StatusTooManyRequests {
+		t.Fatal("callback limited by another user's count")
+	}
+
+	hs.handler.public = newPublicLimits(config.PublicLimitConfig{PerIP: 0, Window: time.Minute})
+	expectStatus(t, hs.do(http.MethodGet, "/login", nil, anonymous, fromIP("10.0.0.1")), http.StatusOK)
+}
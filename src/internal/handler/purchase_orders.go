@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// reconcilePurchaseOrdersHandler runs PO reconciliation now for the current owner and
+// returns the discrepancy report as JSON.
+func (h *Handler) reconcilePurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+
+	report, err := service.RunPurchaseOrderReconciliation(ctx, h.dbURL, h.xc, ownerID, creds, time.Now().Add(-h.cfg.Reconcile.Lookback))
+	if err != nil {
+		h.xeroError(w, r, "reconciliation failed", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// reconciliationReportHandler returns the latest stored reconciliation report for the owner.
+func (h *Handler) reconciliationReportHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	report, err := service.GetLatestReconciliationReport(ctx, h.dbURL, ownerID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load report", err)
+		return
+	}
+	if report == nil {
+		http.Error(w, "no reconciliation report yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// purchaseOrderPreviewHandler shows the purchase orders "Create Purchase Orders" would
+// raise from the unordered shopping list: quantities rounded up to supplier pack
+// sizes, lines under the supplier's minimum order flagged, expected arrival dates
+// from lead times, and line and order values, plus the owner's PO header defaults
+// for editing. ?format=json returns the previews and settings.
+func (h *Handler) purchaseOrderPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := h.orders.GetUnorderedShoppingRows(ctx, ownerID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to read shopping list", err)
+		return
+	}
+	settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load settings", err)
+		return
+	}
+	var previews []service.POPreview
+	var groupErr, priceWarning string
+	var supplierChoice bool // a line can be ordered from another supplier
+	var noRate int          // lines that cannot be priced in their supplier's currency yet
+	choices := supplierChoicesFromForm(r.URL.Query())
+	if len(rows) > 0 {
+		grouped, err := h.orders.GroupShoppingItemsByContact(ctx, ownerID, rows, choices)
+		switch {
+		case errors.Is(err, service.ErrSupplierChoice):
+			// a stale ?supplier. choice, not a BOM problem
+			groupErr = err.Error()
+		case err != nil:
+			// same failure "Create Purchase Orders" would hit; show it instead of a preview
+			groupErr = err.Error()
+			h.postBOMUnresolved(ownerID, groupErr)
+		default:
+			// Xero prices are a nice-to-have here: without them lines show as unpriced
+			prices, cur, err := h.previewPrices(ctx, ownerID, grouped)
+			if err != nil {
+				priceWarning = "Xero purchase prices or supplier currencies unavailable: " + errorText("Xero", err)
+			}
+			previews = service.BuildPOPreview(grouped, time.Now().UTC(), prices, cur)
+			codes, err := h.orders.GetItemAccountCodes(ctx, purchaseItemCodes(grouped))
+			if err != nil {
+				h.renderError(w, r, http.StatusInternalServerError, "failed to load account codes", err)
+				return
+			}
+			for i := range previews {
+				noRate += previews[i].NoRate
+				for j := range previews[i].Lines {
+					previews[i].Lines[j].AccountCode = codes[previews[i].Lines[j].ItemID]
+					supplierChoice = supplierChoice || len(previews[i].Lines[j].Suppliers) > 0
+				}
+			}
+		}
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		if groupErr != "" {
+			http.Error(w, groupErr, http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"purchase_orders": previews, "price_warning": priceWarning, "settings": settings.POSettings})
+		return
+	}
+
+	loc := h.localeForOwner(ctx, ownerID)
+	data := map[string]interface{}{
+		"Title":          "Purchase order preview",
+		"Previews":       previews,
+		"Error":          groupErr,
+		"PriceWarning":   priceWarning,
+		"Settings":       settings.POSettings,
+		"Choices":        choices,
+		"SupplierChoice": supplierChoice,
+		"NoRate":         noRate,
+		"Currency":       loc.Currency,
+		"Locale":         loc,
+		"Flash":          h.flash.Pop(w, r),
+		"CSRFToken":      mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "po_preview.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// savePOSettingsHandler stores the delivery address, attention-to and reference
+// edited on the preview screen as the owner's purchase order defaults, keeping the
+// rest of their settings, and the line account codes as the items' defaults.
+func (h *Handler) savePOSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	codes, err := accountCodesFromForm(r)
+	if err != nil {
+		h.flash.Add(w, r, flash.Error, "Purchase order defaults not saved: "+err.Error())
+		http.Redirect(w, r, "/purchase-orders/preview", http.StatusSeeOther)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+	if err == nil {
+		settings.POSettings = poSettingsFromForm(r)
+		err = h.settings.SaveOwnerSettings(ctx, ownerID, settings)
+	}
+	if err == nil {
+		err = h.orders.SetItemAccountCodes(ctx, codes)
+	}
+	if err != nil {
+		h.flash.Add(w, r, flash.Error, "Failed to save purchase order defaults: "+err.Error())
+	} else {
+		h.flash.Add(w, r, flash.Info, "Purchase order defaults saved.")
+	}
+	http.Redirect(w, r, "/purchase-orders/preview", http.StatusSeeOther)
+}
+
+// poSettingsFromForm reads the PO header fields of the preview form.
+func poSettingsFromForm(r *http.Request) service.POSettings {
+	return service.POSettings{
+		DeliveryAddress: r.PostFormValue("delivery_address"),
+		AttentionTo:     r.PostFormValue("attention_to"),
+		Reference:       r.PostFormValue("reference"),
+	}
+}
+
+// supplierField prefixes the preview's per-item supplier choices
+// (supplier.<item code> = contact AccountNumber).
+const supplierField = "supplier."
+
+// supplierChoicesFromForm reads the preview's supplier choices: item code -> the
+// supplier to order it from. Blank choices are left out.
+func supplierChoicesFromForm(v url.Values) map[string]string {
+	choices := map[string]string{}
+	for key, vals := range v {
+		item, ok := strings.CutPrefix(key, supplierField)
+		if !ok || item == "" || len(vals) == 0 {
+			continue
+		}
+		if c := strings.TrimSpace(vals[0]); c != "" {
+			choices[item] = c
+		}
+	}
+	return choices
+}
+
+// accountCodeField prefixes the preview form's per-item account code inputs
+// (account_code.<item code>).
+const accountCodeField = "account_code."
+
+// accountCodesFromForm reads the preview form's account codes: item code -> code,
+// "" where the field was cleared.
+func accountCodesFromForm(r *http.Request) (map[string]string, error) {
+	codes := map[string]string{}
+	for key, vals := range r.PostForm {
+		item, ok := strings.CutPrefix(key, accountCodeField)
+		if !ok || item == "" || len(vals) == 0 {
+			continue
+		}
+		code, err := service.NormalizeAccountCode(vals[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", item, err)
+		}
+		codes[item] = code
+	}
+	return codes, nil
+}
+
+// previewPrices returns the Xero purchase prices of the grouped items and the
+// currencies their suppliers invoice in.
+func (h *Handler) previewPrices(ctx context.Context, ownerID string, grouped map[string][]service.ContactItem) (map[string]float64, service.SupplierCurrencies, error) {
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		return nil, service.SupplierCurrencies{}, err
+	}
+	items, err := h.xc.GetItemsByCodes(ctx, creds.AccessToken, creds.TenantID, purchaseItemCodes(grouped))
+	if err != nil {
+		return nil, service.SupplierCurrencies{}, err
+	}
+	cur, err := h.supplierCurrencies(ctx, creds, grouped)
+	return xeroPurchasePrices(items), cur, err
+}
+
+// supplierCurrencies looks up the currencies the suppliers of grouped's items invoice
+// in, from their Xero contacts, and Xero's rates for them.
+func (h *Handler) supplierCurrencies(ctx context.Context, creds service.XeroCredentials, grouped map[string][]service.ContactItem) (service.SupplierCurrencies, error) {
+	seen := map[string]bool{}
+	var accounts []string
+	add := func(account string) {
+		if !seen[account] {
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	for account, items := range grouped {
+		add(account)
+		for _, it := range items {
+			for _, s := range it.Suppliers {
+				add(s.ContactID)
+			}
+		}
+	}
+	sort.Strings(accounts)
+	base := h.organisation(ctx, creds).BaseCurrency
+	return service.LoadSupplierCurrencies(ctx, h.lookups, h.xc, creds.AccessToken, creds.TenantID, base, accounts)
+}
+
+// purchaseItemCodes returns the distinct item codes across all suppliers, sorted.
+func purchaseItemCodes(grouped map[string][]service.ContactItem) []string {
+	seen := map[string]bool{}
+	var codes []string
+	for _, items := range grouped {
+		for _, it := range items {
+			if !seen[it.ItemID] {
+				seen[it.ItemID] = true
+				codes = append(codes, it.ItemID)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// xeroPurchasePrices maps item code -> PurchaseDetails.UnitPrice.
+func xeroPurchasePrices(items map[string]xero.ItemSummary) map[string]float64 {
+	prices := make(map[string]float64, len(items))
+	for code, it := range items {
+		prices[code] = it.PurchaseDetails.UnitPrice
+	}
+	return prices
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestPurchaseOrderPreview(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 45}, {ListID: 2, ItemID: "NUT", Quantity: 30}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {
+			{ItemID: "NUT", Quantity: 30, ListIDs: []int{2}, Terms: service.SupplierTerms{PackSize: 100, MinimumOrderQty: 500}},
+			{ItemID: "BOLT", Quantity: 45, ListIDs: []int{1}, Terms: service.SupplierTerms{PackSize: 50, LeadTimeDays: 7, HasLeadTime: true, UnitPrice: 0.25}},
+		},
+	}
+	hs.xero.HandleFunc("GET /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Items":[{"ItemID":"i-1","Code":"BOLT","PurchaseDetails":{"UnitPrice":0.5}},{"ItemID":"i-2","Code":"NUT","PurchaseDetails":{"UnitPrice":0.05}}]}`)
+	})
+	hs.xero.HandleFunc("GET /api.xro/2.0/Contacts", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Contacts":[{"ContactID":"c-1","AccountNumber":"SUP-1"}]}`)
+	})
+
+	rec := hs.do(http.MethodGet, "/purchase-orders/preview", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{"SUP-1", "BOLT", ">50<", "1 line(s) below the supplier's minimum order quantity", "All in by", ">17.50<", `action="/xero/create-pos"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("preview missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = hs.do(http.MethodGet, "/purchase-orders/preview?format=json", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var out struct {
+		PurchaseOrders []service.POPreview `json:"purchase_orders"`
+		PriceWarning   string              `json:"price_warning"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.PurchaseOrders) != 1 || out.PurchaseOrders[0].Total != 17.5 || out.PriceWarning != "" {
+		t.Fatalf("previews: %+v", out.PurchaseOrders)
+	}
+	lines := out.PurchaseOrders[0].Lines
+	if len(lines) != 2 || lines[0].ItemID != "BOLT" || lines[0].Quantity != 50 || lines[1].Quantity != 100 || !lines[1].BelowMOQ {
+		t.Fatalf("lines: %+v", lines)
+	}
+	if lines[0].ExpectedArrival.IsZero() || !lines[1].ExpectedArrival.IsZero() {
+		t.Fatalf("arrival dates: %+v", lines)
+	}
+	if lines[0].PriceSource != service.PriceSourceSupplier || lines[1].PriceSource != service.PriceSourceXero || lines[1].LineTotal != 5 {
+		t.Fatalf("prices: %+v", lines)
+	}
+}
+
+func TestPurchaseOrderPreview_Currency(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 10}, {ListID: 2, ItemID: "NUT", Quantity: 10}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-EU": {
+			{ItemID: "BOLT", Quantity: 10, ListIDs: []int{1}, Terms: service.SupplierTerms{UnitPrice: 0.3}},
+			{ItemID: "NUT", Quantity: 10, ListIDs: []int{2}},
+		},
+	}
+	hs.xero.HandleFunc("GET /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Items":[{"ItemID":"i-2","Code":"NUT","PurchaseDetails":{"UnitPrice":0.08}}]}`)
+	})
+	hs.xero.HandleFunc("GET /api.xro/2.0/Contacts", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Contacts":[{"ContactID":"c-2","AccountNumber":"SUP-EU","DefaultCurrency":"EUR"}]}`)
+	})
+	hs.xero.HandleFunc("GET /api.xro/2.0/Invoices", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Query().Get("where"), `CurrencyCode=="EUR"`) {
+			t.Errorf("unexpected bill lookup: %s", r.URL.RawQuery)
+		}
+		_, _ = io.WriteString(w, `{"Invoices":[{"InvoiceNumber":"BILL-1","CurrencyCode":"EUR","CurrencyRate":1.25}]}`)
+	})
+
+	rec := hs.do(http.MethodGet, "/purchase-orders/preview?format=json", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var out struct {
+		PurchaseOrders []service.POPreview `json:"purchase_orders"`
+		PriceWarning   string              `json:"price_warning"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.PurchaseOrders) != 1 || out.PriceWarning != "" {
+		t.Fatalf("previews: %+v, warning %q", out.PurchaseOrders, out.PriceWarning)
+	}
+	// NUT's Xero price of 0.08 is 0.10 in EUR
+	if p := out.PurchaseOrders[0]; p.Currency != "EUR" || p.Total != 4 || p.Rate != 1.25 || p.BaseTotal != 3.2 {
+		t.Fatalf("EUR preview: %+v", p)
+	}
+
+	rec = hs.do(http.MethodGet, "/purchase-orders/preview", nil)
+	expectStatus(t, rec, http.StatusOK)
+	for _, want := range []string{"invoices in EUR", "Converted at 1.25 EUR", "latest EUR bill"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("preview missing %q:\n%s", want, rec.Body.String())
+		}
+	}
+}
+
+func TestPurchaseOrderPreview_EmptyAndUnmapped(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	rec := hs.do(http.MethodGet, "/purchase-orders/preview", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "No unordered shopping list items.") {
+		t.Fatalf("unexpected page: %s", rec.Body.String())
+	}
+
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "ORPHAN", Quantity: 1}}
+	hs.store.groupErr = errors.New("no contact mapping found for item ORPHAN")
+	rec = hs.do(http.MethodGet, "/purchase-orders/preview", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "no contact mapping found for item ORPHAN") {
+		t.Fatalf("unexpected page: %s", rec.Body.String())
+	}
+	expectStatus(t, hs.do(http.MethodGet, "/purchase-orders/preview?format=json", nil), http.StatusUnprocessableEntity)
+}
+
+func TestPOSettings_SaveAndPreview(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	rec := hs.do(http.MethodPost, "/purchase-orders/settings", url.Values{
+		"delivery_address": {"Unit 4\nMill Lane"},
+		"attention_to":     {" Stores "},
+		"reference":        {""},
+	})
+	expectRedirect(t, rec, "/purchase-orders/preview")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "Purchase order defaults saved." {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+	rec = hs.do(http.MethodGet, "/purchase-orders/preview", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{">Unit 4\nMill Lane</textarea>", `name="attention_to" value=" Stores "`, `formaction="/purchase-orders/settings"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("preview missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestPOPreview_AccountCodes(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}, {ListID: 2, ItemID: "NUT", Quantity: 8}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}, {ItemID: "NUT", Quantity: 8, ListIDs: []int{2}}},
+	}
+	hs.store.accountCodes["NUT"] = "300"
+
+	// saving defaults stores edited codes and removes cleared ones
+	rec := hs.do(http.MethodPost, "/purchase-orders/settings", url.Values{
+		"account_code.BOLT": {" 429 "},
+		"account_code.NUT":  {""},
+	})
+	expectRedirect(t, rec, "/purchase-orders/preview")
+	if fmt.Sprint(hs.store.accountCodes) != "map[BOLT:429]" {
+		t.Fatalf("account codes = %v", hs.store.accountCodes)
+	}
+
+	rec = hs.do(http.MethodGet, "/purchase-orders/preview", nil)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{`name="account_code.BOLT" value="429"`, `name="account_code.NUT" value=""`, `form="create-pos"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("preview missing %q:\n%s", want, body)
+		}
+	}
+
+	rec = hs.do(http.MethodPost, "/purchase-orders/settings", url.Values{"account_code.BOLT": {"not a code"}})
+	expectRedirect(t, rec, "/purchase-orders/preview")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.HasPrefix(msgs[0].Text, "Purchase order defaults not saved: BOLT: invalid account code") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if hs.store.accountCodes["BOLT"] != "429" {
+		t.Fatal("an invalid code should not be saved")
+	}
+}
+
+func TestPurchaseOrderPreview_SupplierChoice(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	suppliers := []service.ItemSupplier{
+		{ContactID: "SUP-1", Preferred: true, Terms: service.SupplierTerms{UnitPrice: 0.5}},
+		{ContactID: "SUP-2", Terms: service.SupplierTerms{UnitPrice: 0.25}},
+	}
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-2": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}, Terms: suppliers[1].Terms, Suppliers: suppliers}},
+	}
+
+	rec := hs.do(http.MethodGet, "/purchase-orders/preview?supplier.BOLT=SUP-2", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if hs.store.choices["BOLT"] != "SUP-2" {
+		t.Fatalf("choices: %+v", hs.store.choices)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`name="supplier.BOLT"`, `<option value="SUP-2" selected>`, "(preferred)", "(cheapest)",
+		`action="/purchase-orders/preview"`, `<input type="hidden" name="supplier.BOLT" value="SUP-2" />`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("preview missing %q:\n%s", want, body)
+		}
+	}
+
+	hs.store.groupErr = fmt.Errorf("%w: BOLT is not bought from SUP-9", service.ErrSupplierChoice)
+	rec = hs.do(http.MethodGet, "/purchase-orders/preview?supplier.BOLT=SUP-9", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "BOLT is not bought from SUP-9") {
+		t.Fatalf("unexpected page: %s", rec.Body.String())
+	}
+}
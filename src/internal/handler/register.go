@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/utils"
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+	"github.com/hwalton/xero-invoice-orderer/pkg/supabasetoolbox"
+)
+
+// registerPageHandler shows the sign-up form.
+func (h *Handler) registerPageHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderRegister(w, r, http.StatusOK, map[string]interface{}{
+		"InviteCode": r.URL.Query().Get("invite"),
+	})
+}
+
+// registerHandler handles POST from the sign-up form: it takes one use of the
+// invite code, creates the user with the Supabase admin API and their default
+// settings, and has Supabase email them a link to /confirm. Users are never made
+// through the public sign-up endpoint, which would skip the invite code; the project
+// should have public sign-ups turned off.
+func (h *Handler) registerHandler(w http.ResponseWriter, r *http.Request) {
+	email := strings.TrimSpace(r.FormValue("email"))
+	password := r.FormValue("password")
+	code := r.FormValue("invite_code")
+	data := map[string]interface{}{"Email": email, "InviteCode": code}
+
+	if email == "" || password == "" || strings.TrimSpace(code) == "" {
+		data["Error"] = "Email, password and invite code are required"
+		h.renderRegister(w, r, http.StatusBadRequest, data)
+		return
+	}
+	if password != r.FormValue("password_confirm") {
+		data["Error"] = "Passwords do not match"
+		h.renderRegister(w, r, http.StatusBadRequest, data)
+		return
+	}
+
+	if h.admin == nil {
+		log.Printf("register: user admin not configured (needs SUPABASE_SERVICE_ROLE_KEY)")
+		data["Error"] = "Registration is not available, please ask for an account"
+		h.renderRegister(w, r, http.StatusServiceUnavailable, data)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	// sign-ups share the per-IP sign-in limit, which also slows invite code guessing
+	if msg, wait := h.limits.attempt(ctx, r, ""); wait > 0 {
+		setRetryAfter(w, wait)
+		data["Error"] = msg
+		h.renderRegister(w, r, http.StatusTooManyRequests, data)
+		return
+	}
+
+	ok, err := h.invites.ConsumeInviteCode(ctx, code)
+	if err != nil {
+		log.Printf("register: consume invite: %v", err)
+		data["Error"] = "Could not check the invite code, please try again"
+		h.renderRegister(w, r, http.StatusInternalServerError, data)
+		return
+	}
+	if !ok {
+		data["Error"] = "Invite code is invalid, expired or already used"
+		h.renderRegister(w, r, http.StatusForbidden, data)
+		return
+	}
+
+	user, err := h.admin.CreateUser(ctx, email, password)
+	if err != nil {
+		log.Printf("register: create user failed: %v", err)
+		h.failRegistration(ctx, w, r, code, "", signUpErrorMessage(err), data)
+		return
+	}
+	if err := h.supabase.SendConfirmation(ctx, email, confirmURL(r)); err != nil {
+		// without the email the account could never be used
+		log.Printf("register: send confirmation: %v", err)
+		h.failRegistration(ctx, w, r, code, user.ID, signUpErrorMessage(err), data)
+		return
+	}
+	if err := h.settings.EnsureOwnerSettings(ctx, user.ID); err != nil {
+		// defaults apply without a row; /settings creates it on first save
+		log.Printf("register: ensure owner settings: %v", err)
+	}
+
+	h.renderRegister(w, r, http.StatusOK, map[string]interface{}{
+		"Message": "Check your email for a link to confirm your account.",
+		"Sent":    true,
+	})
+}
+
+// failRegistration undoes a registration that could not be completed, so the email
+// and invite can be used again: it deletes the user made for it, when there is one,
+// and gives the invite code back. The form is shown again with msg.
+func (h *Handler) failRegistration(ctx context.Context, w http.ResponseWriter, r *http.Request, code, userID, msg string, data map[string]interface{}) {
+	if userID != "" {
+		if err := h.admin.DeleteUser(ctx, userID); err != nil {
+			log.Printf("register: delete user %s: %v", userID, err)
+		}
+	}
+	if err := h.invites.ReleaseInviteCode(ctx, code); err != nil {
+		log.Printf("register: release invite: %v", err)
+	}
+	data["Error"] = msg
+	h.renderRegister(w, r, http.StatusBadRequest, data)
+}
+
+// confirmEmailHandler is the target of the confirmation email link
+// (/confirm?token_hash=...&type=email): it verifies the token, logs the user in and
+// makes sure their settings row exists. Password reset links (type=recovery) go on
+// to the change password page.
+func (h *Handler) confirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	tokenHash := r.URL.Query().Get("token_hash")
+	typ := r.URL.Query().Get("type")
+	if typ == "" {
+		typ = "email"
+	}
+	if tokenHash == "" {
+		h.renderLogin(w, r, http.StatusBadRequest, map[string]interface{}{"Error": "Confirmation link is incomplete"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	access, refresh, userID, err := h.supabase.VerifyEmailToken(ctx, tokenHash, typ)
+	if err != nil {
+		log.Printf("confirm: verify failed: %v", err)
+		msg := "Confirmation link is invalid or has expired"
+		if errors.Is(err, breaker.ErrOpen) {
+			msg = unavailableMessage("Supabase")
+		}
+		h.renderLogin(w, r, http.StatusBadRequest, map[string]interface{}{"Error": msg})
+		return
+	}
+	if err := h.settings.EnsureOwnerSettings(ctx, userID); err != nil {
+		log.Printf("confirm: ensure owner settings: %v", err)
+	}
+
+	setSessionCookies(w, r, access, refresh)
+	if typ == "recovery" {
+		// a password reset link: choose the new password next
+		http.Redirect(w, r, "/account/password", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// confirmURL is this app's /confirm on the host the request came in on.
+func confirmURL(r *http.Request) string {
+	scheme := "https"
+	if !utils.IsSecureRequest(r) {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + "/confirm"
+}
+
+// signUpErrorMessage is what the form shows for a failed Supabase sign-up.
+func signUpErrorMessage(err error) string {
+	if errors.Is(err, breaker.ErrOpen) {
+		return unavailableMessage("Supabase")
+	}
+	var ae *supabasetoolbox.AuthError
+	if errors.As(err, &ae) {
+		if msg := ae.Message(); msg != "" {
+			return msg
+		}
+	}
+	return "Sign up failed, please try again"
+}
+
+func (h *Handler) renderRegister(w http.ResponseWriter, r *http.Request, status int, data map[string]interface{}) {
+	data["Title"] = "Register — Business"
+	data["CSRFToken"] = mid.CSRFToken(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = h.templates.ExecuteTemplate(w, "register.html", data)
+}
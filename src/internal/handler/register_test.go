@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// fakeSupabaseAuth serves the admin user creation, confirmation emails and verify on
+// the harness server, and records the users made, emailed and deleted. Creating
+// taken@example.com fails, as does emailing nomail@example.com; the confirmation
+// link of new@example.com has token "tok-new". Nothing may use the public sign-up
+// endpoint, which would skip the invite code.
+type fakeSupabaseAuth struct {
+	mu      sync.Mutex
+	created []string
+	emailed []string
+	deleted []string
+}
+
+func newFakeSupabaseAuth(hs *harness) *fakeSupabaseAuth {
+	f := &fakeSupabaseAuth{}
+	hs.xero.HandleFunc("POST /auth/v1/signup", func(w http.ResponseWriter, r *http.Request) {
+		hs.t.Error("registration used the public sign-up endpoint")
+		w.WriteHeader(http.StatusForbidden)
+	})
+	hs.xero.HandleFunc("POST /auth/v1/admin/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer service-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct{ Email string }
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Email == "taken@example.com" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"msg":"A user with this email address has already been registered"}`))
+			return
+		}
+		f.mu.Lock()
+		f.created = append(f.created, body.Email)
+		f.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]string{"id": "user-" + strings.TrimSuffix(body.Email, "@example.com"), "email": body.Email})
+	})
+	hs.xero.HandleFunc("DELETE /auth/v1/admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.deleted = append(f.deleted, r.PathValue("id"))
+		f.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	hs.xero.HandleFunc("POST /auth/v1/resend", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["email"] == "nomail@example.com" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"msg":"Email rate limit exceeded"}`))
+			return
+		}
+		f.mu.Lock()
+		f.emailed = append(f.emailed, body["email"])
+		f.mu.Unlock()
+		_, _ = w.Write([]byte(`{}`))
+	})
+	hs.xero.HandleFunc("POST /auth/v1/verify", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["token_hash"] != "tok-new" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"at-new","refresh_token":"rt-new","user":{"id":"user-new"}}`))
+	})
+	return f
+}
+
+func sessionCookie(resp *http.Response) string {
+	for _, c := range resp.Cookies() {
+		if c.Name == "access_token" {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	register := func(hs *harness, email, code string) *http.Response {
+		rec := hs.do(http.MethodPost, "/register", url.Values{
+			"email": {email}, "password": {"secret-pw"}, "password_confirm": {"secret-pw"}, "invite_code": {code},
+		}, anonymous)
+		return rec.Result()
+	}
+
+	t.Run("invite required", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		f := newFakeSupabaseAuth(hs)
+		hs.store.invites["WELCOME"] = 1
+
+		rec := hs.do(http.MethodGet, "/register?invite=WELCOME", nil, anonymous)
+		expectStatus(t, rec, http.StatusOK)
+		if !strings.Contains(rec.Body.String(), `name="invite_code" value="WELCOME"`) {
+			t.Fatalf("invite not prefilled:\n%s", rec.Body.String())
+		}
+		for _, code := range []string{"", "NOPE"} {
+			if resp := register(hs, "new@example.com", code); resp.StatusCode == http.StatusOK {
+				t.Fatalf("code %q: registered without a valid invite", code)
+			}
+		}
+		if hs.store.invites["WELCOME"] != 1 || len(f.created) != 0 {
+			t.Fatalf("invite uses %d, users created %v", hs.store.invites["WELCOME"], f.created)
+		}
+	})
+
+	t.Run("email confirmation", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		f := newFakeSupabaseAuth(hs)
+		hs.store.invites["WELCOME"] = 1
+
+		resp := register(hs, "new@example.com", " welcome ")
+		if resp.StatusCode != http.StatusOK || sessionCookie(resp) != "" {
+			t.Fatalf("status %d, cookie %q", resp.StatusCode, sessionCookie(resp))
+		}
+		if !reflect.DeepEqual(f.created, []string{"new@example.com"}) || !reflect.DeepEqual(f.emailed, []string{"new@example.com"}) {
+			t.Fatalf("created %v, emailed %v", f.created, f.emailed)
+		}
+		if got, ok := hs.store.settings["user-new"]; !ok || !reflect.DeepEqual(got, service.DefaultOwnerSettings()) {
+			t.Fatalf("owner settings not created: %+v", hs.store.settings)
+		}
+		// single use
+		if resp := register(hs, "other@example.com", "WELCOME"); resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("reused invite: status %d", resp.StatusCode)
+		}
+
+		rec := hs.do(http.MethodGet, "/confirm?token_hash=expired&type=email", nil, anonymous)
+		expectStatus(t, rec, http.StatusBadRequest)
+
+		rec = hs.do(http.MethodGet, "/confirm?token_hash=tok-new&type=email", nil, anonymous)
+		expectRedirect(t, rec, "/")
+		if got := sessionCookie(rec.Result()); got != "at-new" {
+			t.Fatalf("access_token cookie = %q", got)
+		}
+
+		// password reset links carry on to choosing a new password
+		expectRedirect(t, hs.do(http.MethodGet, "/confirm?token_hash=tok-new&type=recovery", nil, anonymous), "/account/password")
+	})
+
+	t.Run("create error gives the invite back", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		newFakeSupabaseAuth(hs)
+		hs.store.invites["WELCOME"] = 1
+
+		rec := hs.do(http.MethodPost, "/register", url.Values{
+			"email": {"taken@example.com"}, "password": {"pw"}, "password_confirm": {"pw"}, "invite_code": {"WELCOME"},
+		}, anonymous)
+		expectStatus(t, rec, http.StatusBadRequest)
+		if !strings.Contains(rec.Body.String(), "already been registered") {
+			t.Fatalf("error not shown:\n%s", rec.Body.String())
+		}
+		if hs.store.invites["WELCOME"] != 1 {
+			t.Fatalf("invite not released: %d uses left", hs.store.invites["WELCOME"])
+		}
+	})
+
+	t.Run("email error removes the user", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		f := newFakeSupabaseAuth(hs)
+		hs.store.invites["WELCOME"] = 1
+
+		resp := register(hs, "nomail@example.com", "WELCOME")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		if !reflect.DeepEqual(f.deleted, []string{"user-nomail"}) || hs.store.invites["WELCOME"] != 1 {
+			t.Fatalf("deleted %v, %d invite uses left", f.deleted, hs.store.invites["WELCOME"])
+		}
+	})
+
+	t.Run("needs the service-role key", func(t *testing.T) {
+		t.Parallel()
+		hs := newHarness(t)
+		newFakeSupabaseAuth(hs)
+		hs.handler.admin = nil
+		hs.store.invites["WELCOME"] = 1
+
+		if resp := register(hs, "new@example.com", "WELCOME"); resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+		if hs.store.invites["WELCOME"] != 1 {
+			t.Fatalf("invite used: %d uses left", hs.store.invites["WELCOME"])
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// replicaCheckTTL limits how often the read replica's reachability is checked.
+const replicaCheckTTL = 30 * time.Second
+
+// reportDB chooses where report queries read from: the read replica when one is
+// configured and answered its last check, otherwise the primary.
+type reportDB struct {
+	primary string
+	replica string // "" when none is configured
+
+	// ping checks the replica (service.PingDB outside tests)
+	ping func(ctx context.Context, dbURL string) error
+
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+func newReportDB(primary, replica string) *reportDB {
+	return &reportDB{primary: primary, replica: replica, ping: service.PingDB}
+}
+
+// url returns the database URL for a report query. A replica that stops answering
+// is used again once a later check succeeds.
+func (d *reportDB) url(ctx context.Context) string {
+	if d.replica == "" {
+		return d.primary
+	}
+	if d.check(ctx) != nil {
+		return d.primary
+	}
+	return d.replica
+}
+
+// check returns the cached replica reachability result, refreshing it after
+// replicaCheckTTL. It logs when the replica goes away or comes back.
+func (d *reportDB) check(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.checked.IsZero() && time.Since(d.checked) < replicaCheckTTL {
+		return d.err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	err := d.ping(ctx, d.replica)
+	switch {
+	case err != nil && d.err == nil:
+		log.Printf("read replica unavailable, reports read from the primary: %v", err)
+	case err == nil && d.err != nil:
+		log.Printf("read replica available again")
+	}
+	d.err, d.checked = err, time.Now()
+	return err
+}
+
+// reportDBURL is the database URL heavy read-only reports query.
+func (h *Handler) reportDBURL(ctx context.Context) string {
+	if h.reports == nil {
+		return h.dbURL
+	}
+	return h.reports.url(ctx)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReportDB(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	if got := newReportDB("primary", "").url(ctx); got != "primary" {
+		t.Fatalf("no replica: url = %q, want primary", got)
+	}
+
+	down := errors.New("connection refused")
+	var pings int
+	var pingErr error
+	d := newReportDB("primary", "replica")
+	d.ping = func(ctx context.Context, dbURL string) error {
+		if dbURL != "replica" {
+			t.Fatalf("pinged %q", dbURL)
+		}
+		pings++
+		return pingErr
+	}
+
+	if got := d.url(ctx); got != "replica" {
+		t.Fatalf("replica up: url = %q", got)
+	}
+	pingErr = down
+	if got := d.url(ctx); got != "replica" || pings != 1 {
+		t.Fatalf("within ttl: url = %q after %d pings, want cached replica after 1", got, pings)
+	}
+
+	d.checked = time.Now().Add(-replicaCheckTTL)
+	if got := d.url(ctx); got != "primary" || pings != 2 {
+		t.Fatalf("replica down: url = %q after %d pings, want primary after 2", got, pings)
+	}
+
+	pingErr = nil
+	d.checked = time.Now().Add(-replicaCheckTTL)
+	if got := d.url(ctx); got != "replica" {
+		t.Fatalf("replica back: url = %q", got)
+	}
+}
+
+func TestReportDBURL_NoReportDB(t *testing.T) {
+	t.Parallel()
+	h := &Handler{dbURL: "primary"}
+	if got := h.reportDBURL(context.Background()); got != "primary" {
+		t.Fatalf("url = %q, want primary", got)
+	}
+}
@@ -1,65 +1,280 @@
 package handler
 
 import (
-	"encoding/json"
+	"expvar"
 	"html/template"
 	"net/http"
 	"sync"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
 	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/notify"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/storage"
 	authpkg "github.com/hwalton/xero-invoice-orderer/pkg/auth"
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/supabasetoolbox"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
 )
 
 // Handler groups dependencies for route handlers.
 type Handler struct {
+	cfg       *config.Config
 	auth      authpkg.Authenticator
-	client    *http.Client
+	xc        *xero.Client
 	dbURL     string
 	templates *template.Template // added: parsed templates
 
+	// supabase makes the GoTrue login, sign-up and confirmation calls (public or
+	// server mode)
+	supabase *supabasetoolbox.Client
+
+	// admin calls the Supabase user admin API with the service-role key; nil
+	// disables the /admin pages
+	admin *supabasetoolbox.Client
+
+	// flash carries one-shot messages across redirects
+	flash *flash.Store
+
+	// tokens is the only way handlers obtain Xero credentials
+	tokens credentialSource
+
+	// database-backed operations used by the Xero routes (dbStore outside tests)
+	states   oauthStateStore
+	conns    connectionStore
+	invoices invoiceStore
+	orders   orderStore
+	settings settingsStore
+	invites  inviteStore
+	views    viewStateStore
+	exports  exportStore
+	lists    listStore
+
+	// approvals holds purchase order batches over the approval threshold
+	approvals approvalStore
+
+	// proposals holds parts list changes for review when the workspace requires it
+	proposals proposalStore
+
+	// prepared lists invoices the invoice poll resolved ahead of time
+	prepared preparedStore
+
+	// creditNotes takes credited parts off the shopping list
+	creditNotes creditNoteStore
+
+	// releases tracks the windows orders are held in for one PO per supplier
+	releases orderWindowStore
+
+	// prices imports supplier price lists into the agreed prices
+	prices priceListStore
+
+	// webhooks remembers handled Xero webhook events and polls invoices they report
+	webhooks webhookStore
+
+	// diagnostics backs the admin Xero diagnostics page
+	diagnostics diagnosticsStore
+
+	// workspaces resolves the workspace each request works in and manages members
+	workspaces workspaceStore
+
+	// limits throttles sign-in and sign-up attempts; nil disables
+	limits *loginLimits
+
+	// public throttles requests to the public routes per client IP; nil disables
+	public *publicLimits
+
+	// store holds part attachments and kept exports; nil disables uploads
+	store storage.Store
+
+	// events posts order events (POs created, BOM failures, broken connections) to
+	// the configured webhooks; nil posts nothing
+	events *notify.Events
+
+	// xeroPing caches the Xero reachability check used by /health/ready
+	xeroPing xeroPingCache
+
+	// lookups caches Xero contact ids between requests; nil caches nothing
+	lookups cache.Cache
+
+	// progress keeps long requests' progress for their pages' progress bars, shared
+	// by every instance like lookups; nil streams nothing
+	progress cache.Cache
+
+	// reports picks the read replica or the primary for report queries; nil reads
+	// from dbURL
+	reports *reportDB
+
 	// removed in-memory stateStore -> using DB-backed state with TTL
 	_ sync.Mutex
 }
 
-// NewRouter now accepts dbURL so handlers can persist connections.
-func NewRouter(a authpkg.Authenticator, c *http.Client, dbURL string, templates *template.Template) http.Handler {
+// NewRouter builds the app routes. cfg must already be validated (config.Load).
+func NewRouter(cfg *config.Config, a authpkg.Authenticator, xc *xero.Client, templates *template.Template, sb, admin *supabasetoolbox.Client, store storage.Store, events *notify.Events) http.Handler {
+	db := dbStore{dbURL: cfg.DatabaseURL}
+	tokens := service.NewTokenManager(cfg.DatabaseURL, xc, cfg.Xero.ClientID, cfg.Xero.ClientSecret)
 	h := &Handler{
-		auth:      a,
-		client:    c,
-		dbURL:     dbURL,
-		templates: templates,
+		cfg:         cfg,
+		auth:        a,
+		xc:          xc,
+		dbURL:       cfg.DatabaseURL,
+		templates:   templates,
+		supabase:    sb,
+		store:       store,
+		events:      events,
+		flash:       flash.New(cfg.FlashSecret),
+		tokens:      tokens,
+		states:      db,
+		conns:       db,
+		invoices:    db,
+		orders:      db,
+		settings:    db,
+		invites:     db,
+		views:       db,
+		exports:     db,
+		lists:       db,
+		approvals:   db,
+		proposals:   db,
+		prepared:    db,
+		creditNotes: db,
+		releases:    db,
+		prices:      db,
+		webhooks:    webhookDB{dbStore: db, tokens: tokens, lookback: cfg.InvoicePoll.Lookback},
+		diagnostics: diagnosticsDB{dbStore: db, tokens: tokens},
+		workspaces:  db,
+		limits:      newLoginLimits(cfg.LoginLimit),
+		public:      newPublicLimits(cfg.PublicLimit),
+		reports:     newReportDB(cfg.DatabaseURL, cfg.ReplicaURL),
+		lookups:     service.SharedCache(),
+		progress:    service.SharedCache(),
 	}
+	return h.routes()
+}
+
+// maxFormBytes caps form and JSON bodies; file uploads set their own limits.
+const maxFormBytes = 1 << 20
+
+// routes registers every route on a new router.
+func (h *Handler) routes() http.Handler {
 	r := chi.NewRouter()
+	r.Use(mid.LimitBody(maxFormBytes))
+	r.Use(mid.CSRF)
 
 	r.Get("/health", h.health)
+	r.Get("/health/ready", h.ready)
 
-	// public login route
-	r.Get("/login", h.loginHandler)
-	r.Post("/perform-login", h.supabaseConnectHandler)
+	// public login and registration routes
+	r.With(h.rateLimited("login")).Get("/login", h.loginHandler)
+	r.With(h.rateLimited("perform-login")).Post("/perform-login", h.supabaseConnectHandler)
 	r.Post("/logout", h.logoutHandler)
+	r.Get("/register", h.registerPageHandler)
+	r.Post("/register", h.registerHandler)
+	r.Get("/confirm", h.confirmEmailHandler)
+
+	// public, token-protected build progress embed
+	r.Get("/embed/builds/{token}", h.embedBuildHandler)
+
+	// Xero webhook deliveries, signed with the app's webhooks key
+	r.Post("/xero/webhooks", h.xeroWebhookHandler)
 
 	// Protect routes with RequireAuth
 	r.Group(func(r chi.Router) {
 		r.Use(mid.RequireAuth(h.auth))
+		// everything below reads and writes the workspace requireWorkspace resolves
+		r.Use(h.requireWorkspace)
 		r.Get("/", h.homeHandler) // <-- protected now
 		r.Get("/xero/connect", h.xeroConnectHandler)
-		r.Get("/xero/callback", h.xeroCallbackHandler)
+		r.With(h.rateLimited("xero-callback")).Get("/xero/callback", h.xeroCallbackHandler)
 		r.Get("/xero/connections", h.xeroConnectionsHandler)
 
+		r.Get("/invoices", h.invoicesHandler)
 		r.Post("/xero/invoice", h.getInvoiceHandler)
+		r.Post("/xero/items/create", h.createXeroItemHandler)
+		r.Get("/invoice/{number}/bom.pdf", h.invoiceBOMPDFHandler)
+		r.Get("/invoice/{number}/kit", h.invoiceKitHandler)
+		r.Get("/invoice/{number}/kit.pdf", h.invoiceKitPDFHandler)
+		r.Get("/invoice/{number}/bom.{format:csv|xlsx}", h.invoiceBOMExportHandler)
+		r.Get("/invoice/{number}/changes", h.bomChangesHandler)
 		r.Post("/xero/create-pos", h.createPurchaseOrdersHandler)
+		r.Post("/purchase-orders/release", h.releaseOrdersHandler)
+		r.Get("/progress/{id}", h.progressHandler) // server-sent progress of the two above
+		r.Post("/xero/sync-suppliers", h.syncSuppliersHandler)
+		r.Get("/xero/contacts/export", h.exportContactsHandler)
+		r.Get("/xero/items/export", h.exportItemsHandler)
 		r.Post("/shopping-list/add", h.addShoppingListHandler) // add invoice lines to shopping_list
+		r.Post("/shopping-list/bulk", h.bulkShoppingListHandler)
+		r.Get("/shopping-list", h.shoppingListHandler)
+		r.Get("/credit-notes", h.creditNotesHandler)
+		r.Post("/credit-notes/apply", h.applyCreditNoteHandler)
 
-		// // Development helpers
-		// r.Get("/contacts", h.dumpContactsHandler)
-		// r.Get("/items", h.dumpItemsHandler)
+		r.Get("/purchase-orders", h.purchaseOrderHistoryHandler)
+		r.Get("/purchase-orders/preview", h.purchaseOrderPreviewHandler)
+		r.Post("/purchase-orders/settings", h.savePOSettingsHandler)
+		r.Post("/purchase-orders/reconcile", h.reconcilePurchaseOrdersHandler)
+		r.Get("/purchase-orders/reconciliation", h.reconciliationReportHandler)
+		r.Get("/purchase-orders/approvals", h.poApprovalsHandler)
+		r.Post("/purchase-orders/approvals/{id}/{action:approve|reject}", h.decidePOApprovalHandler)
+
+		r.Get("/settings", h.settingsHandler)
+		r.Post("/settings", h.saveSettingsHandler)
+
+		r.Get("/builds", h.listBuildsHandler)
+		r.Get("/shortages", h.shortageReportHandler)
+		r.Get("/reports/usage", h.usageReportHandler)
+		r.Get("/reports/supplier-billing", h.supplierBillingHandler)
+		r.Get("/builds/{id}", h.buildProgressHandler)
+		r.Post("/builds/{id}/share", h.shareBuildHandler)
+		r.Post("/builds/{id}/share/revoke", h.revokeBuildShareHandler)
+
+		r.Get("/bom/import", h.bomImportPageHandler)
+		r.Post("/bom/import", h.bomImportHandler)
+		r.Get("/bom/proposals", h.bomProposalsHandler)
+		r.Get("/bom/proposals/{id}", h.bomProposalHandler)
+		r.Post("/bom/proposals/{id}/{action:approve|reject}", h.decideBOMProposalHandler)
+		r.Get("/suppliers/import", h.supplierImportPageHandler)
+		r.Post("/suppliers/import", h.supplierImportHandler)
+		r.Get("/suppliers/prices", h.supplierPricesPageHandler)
+		r.Post("/suppliers/prices", h.supplierPricesHandler)
+		r.Get("/suppliers/mappings", h.supplierMappingsHandler)
+
+		r.Get("/items/{code}", h.itemDetailHandler)
+		r.Get("/items/{code}/where-used", h.whereUsedHandler)
+		r.Post("/items/{code}/suppliers/{contact}/{action:archive|restore}", h.archiveSupplierMappingHandler)
+		r.Post("/items/{code}/suppliers/{contact}/prefer", h.preferSupplierHandler)
+		r.Post("/items/{code}/substitutes", h.saveSubstituteHandler)
+		r.Post("/items/{code}/substitutes/{substitute}/delete", h.deleteSubstituteHandler)
+		r.Post("/items/{code}/{action:unavailable|available}", h.partAvailabilityHandler)
+		r.Post("/items/{code}/attachments", h.uploadAttachmentHandler)
+		r.Get("/attachments/{id}", h.attachmentHandler)
+		r.Post("/attachments/{id}/delete", h.deleteAttachmentHandler)
+		r.Get("/audit-log", h.auditLogHandler)
+		r.Get("/downloads", h.downloadsHandler)
+		r.Get("/downloads/{id}", h.downloadHandler)
+		r.Post("/downloads/{id}/delete", h.deleteDownloadHandler)
+
+		r.Get("/workspaces", h.workspacesHandler)
+		r.Post("/workspaces", h.createWorkspaceHandler)
+		r.Post("/workspaces/switch", h.switchWorkspaceHandler)
+		r.Post("/workspaces/join", h.joinWorkspaceHandler)
+		r.Post("/workspaces/current/join-code/{action:rotate|close}", h.workspaceJoinCodeHandler)
+		r.Post("/workspaces/current/members/{user}/remove", h.removeWorkspaceMemberHandler)
+
+		r.Get("/account/password", h.passwordPageHandler)
+		r.Post("/account/password", h.changePasswordHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(mid.RequireAppRole(adminRole))
+			r.Get("/admin/users", h.adminUsersHandler)
+			r.Post("/admin/users/{id}/role", h.adminUserRoleHandler)
+			r.Post("/admin/users/{id}/{action:disable|enable}", h.adminUserAccessHandler)
+			r.Post("/admin/users/{id}/reset-password", h.adminUserResetHandler)
+			r.Get("/admin/xero", h.adminXeroHandler)
+			// runtime and job metrics (e.g. janitor_rows_purged) as JSON; they include
+			// the command line, so admins only
+			r.Handle("/debug/vars", expvar.Handler())
+		})
 	})
 
 	return r
 }
-
-func (h *Handler) health(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-}
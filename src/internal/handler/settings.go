@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// settingsHandler shows the owner's settings form; ?format=json returns them.
+func (h *Handler) settingsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load settings", err)
+		return
+	}
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(settings)
+		return
+	}
+
+	type statusOption struct {
+		Status  string
+		Checked bool
+	}
+	var invoiceStatuses []statusOption
+	for _, st := range xero.InvoiceStatuses {
+		invoiceStatuses = append(invoiceStatuses, statusOption{st, slices.Contains(settings.InvoiceStatuses, st)})
+	}
+	data := map[string]interface{}{
+		"Title":           "Settings",
+		"Settings":        settings,
+		"POStatuses":      []string{service.POStatusDraft, service.POStatusSubmitted, service.POStatusAuthorised},
+		"InvoiceStatuses": invoiceStatuses,
+		"MaxDepth":        service.MaxBOMMaxDepth,
+		"ReleaseWeekdays": []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday},
+		"Flash":           h.flash.Pop(w, r),
+		"CSRFToken":       mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "settings.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// saveSettingsHandler validates and stores the settings form.
+func (h *Handler) saveSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	settings, err := service.ParseOwnerSettings(r.PostForm)
+	if err != nil {
+		h.flash.Add(w, r, flash.Error, "Settings not saved: "+err.Error())
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.settings.SaveOwnerSettings(ctx, ownerID, settings); err != nil {
+		h.flash.Add(w, r, flash.Error, "Failed to save settings: "+err.Error())
+	} else {
+		h.flash.Add(w, r, flash.Info, "Settings saved.")
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestSettings(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+
+	rec := hs.do(http.MethodGet, "/settings", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); !strings.Contains(body, `<option value="AUTHORISED" selected>`) || !strings.Contains(body, `name="bom_max_depth" min="1" max="50" step="1" value="12"`) ||
+		!strings.Contains(body, `name="invoice_status" value="PAID" checked`) || strings.Contains(body, `name="invoice_status" value="DRAFT" checked`) {
+		t.Fatalf("defaults not shown:\n%s", body)
+	}
+
+	rec = hs.do(http.MethodPost, "/settings", url.Values{
+		"po_status":            {"SUBMITTED"},
+		"delivery_address":     {"Unit 4"},
+		"auto_email_suppliers": {"1"},
+		"bom_max_depth":        {"6"},
+		"invoice_statuses_set": {"1"},
+		"invoice_status":       {"AUTHORISED", "PAID"},
+	})
+	expectRedirect(t, rec, "/settings")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "Settings saved." {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+
+	rec = hs.do(http.MethodGet, "/settings?format=json", nil)
+	expectStatus(t, rec, http.StatusOK)
+	var got service.OwnerSettings
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := service.OwnerSettings{POStatus: "SUBMITTED", POSettings: service.POSettings{DeliveryAddress: "Unit 4"}, AutoEmailSuppliers: true, BOMMaxDepth: 6, InvoiceStatuses: []string{"AUTHORISED", "PAID"}, ApprovalScope: service.ApprovalScopeBatch,
+		ReleaseWeekday: time.Friday, ReleaseHour: 12}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+
+	// the invoice resolver gets the owner's depth and statuses
+	hs.store.invoices["INV-1"] = resolvedInvoice{perAssy: []service.BOMNode{{PartID: "BOLT", Quantity: 1}}, leafTotals: []service.LeafTotal{{PartID: "BOLT", Quantity: 1}}}
+	expectStatus(t, hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "ignore_stock": {"1"}}, htmx), http.StatusOK)
+	if want := (service.ResolveOptions{MaxDepth: 6, Statuses: []string{"AUTHORISED", "PAID"}}); !reflect.DeepEqual(hs.store.resolve, want) {
+		t.Fatalf("ResolveInvoice opts = %+v, want %+v", hs.store.resolve, want)
+	}
+}
+
+func TestSettings_Invalid(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	rec := hs.do(http.MethodPost, "/settings", url.Values{"bom_max_depth": {"0"}})
+	expectRedirect(t, rec, "/settings")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.HasPrefix(msgs[0].Text, "Settings not saved: BOM max depth") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if _, ok := hs.store.settings[testOwnerID]; ok {
+		t.Fatal("invalid settings should not be stored")
+	}
+}
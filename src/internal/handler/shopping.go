@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/validate"
+)
+
+// bulkShoppingRequest is the JSON body accepted by POST /shopping-list/bulk.
+// needed_by is a YYYY-MM-DD date (empty clears it). versions maps list ids to the
+// version read from GET /shopping-list; the batch is refused if any has changed.
+type bulkShoppingRequest struct {
+	Operations []struct {
+		Action   string      `json:"action"`
+		ListIDs  []int       `json:"list_ids"`
+		Quantity float64     `json:"quantity"`
+		NeededBy string      `json:"needed_by"`
+		Versions map[int]int `json:"versions"`
+	} `json:"operations"`
+}
+
+// bulkShoppingListHandler applies bulk actions (set quantity, set needed-by, delete,
+// mark unordered, mark received, archive, restore) to selected shopping_list rows in
+// one transaction.
+// JSON requests (scripts) get a JSON response; form posts (UI multi-select) redirect home.
+// Rows changed by someone else since the given versions were read fail the batch
+// with 409 Conflict and the stale list ids.
+func (h *Handler) bulkShoppingListHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+
+	var ops []service.ShoppingBulkOp
+	var err error
+	if isJSON {
+		ops, err = decodeBulkShoppingJSON(r)
+	} else {
+		ops, err = decodeBulkShoppingForm(r)
+	}
+	var fieldErrs validate.Errors
+	if isJSON && errors.As(err, &fieldErrs) {
+		writeFieldErrors(w, fieldErrs)
+		return
+	}
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, "invalid input: "+err.Error(), err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	res, err := service.BulkUpdateShoppingList(ctx, h.dbURL, ownerID, ops)
+	var conflict *service.ShoppingConflictError
+	if errors.As(err, &conflict) {
+		if isJSON {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "conflicts": conflict.ListIDs})
+			return
+		}
+		h.flash.Add(w, r, flash.Error, "Nothing updated: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		if isJSON {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		h.flash.Add(w, r, flash.Error, "Bulk update failed: "+err.Error())
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": res})
+		return
+	}
+	var total int64
+	for _, rr := range res {
+		total += rr.Affected
+	}
+	h.flash.Add(w, r, flash.Info, fmt.Sprintf("%d shopping list rows updated", total))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// decodeBulkShoppingJSON reads a bulkShoppingRequest. Operations with a missing
+// action or a bad needed_by fail as validate.Errors named after their place in the
+// body (operations[1].needed_by), all of them at once.
+func decodeBulkShoppingJSON(r *http.Request) ([]service.ShoppingBulkOp, error) {
+	var body bulkShoppingRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode body: %w", err)
+	}
+	var errs validate.Errors
+	ops := make([]service.ShoppingBulkOp, 0, len(body.Operations))
+	for i, o := range body.Operations {
+		field, op := fmt.Sprintf("operations[%d].", i), fmt.Sprintf("operation %d: ", i+1)
+		if strings.TrimSpace(o.Action) == "" {
+			errs = append(errs, validate.FieldError{Field: field + "action", Message: op + "action is required"})
+		}
+		neededBy, err := parseNeededBy(o.NeededBy)
+		if err != nil {
+			errs = append(errs, validate.FieldError{Field: field + "needed_by", Message: op + err.Error()})
+		}
+		ops = append(ops, service.ShoppingBulkOp{
+			Action:   o.Action,
+			ListIDs:  o.ListIDs,
+			Quantity: o.Quantity,
+			NeededBy: neededBy,
+			Versions: o.Versions,
+		})
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return ops, nil
+}
+
+// decodeBulkShoppingForm reads a single action from a multi-select form:
+// action, list_id (repeated), quantity, needed_by, and version_<list id> for each
+// row's version as rendered.
+func decodeBulkShoppingForm(r *http.Request) ([]service.ShoppingBulkOp, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("invalid form")
+	}
+	op := service.ShoppingBulkOp{Action: strings.TrimSpace(r.FormValue("action"))}
+	for _, s := range r.Form["list_id"] {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid list id %q", s)
+		}
+		op.ListIDs = append(op.ListIDs, id)
+		if v := strings.TrimSpace(r.FormValue("version_" + strconv.Itoa(id))); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version %q for list id %d", v, id)
+			}
+			if op.Versions == nil {
+				op.Versions = map[int]int{}
+			}
+			op.Versions[id] = n
+		}
+	}
+	if q := strings.TrimSpace(r.FormValue("quantity")); q != "" {
+		n, err := strconv.ParseFloat(q, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("invalid quantity %q", q)
+		}
+		op.Quantity = n
+	}
+	neededBy, err := parseNeededBy(r.FormValue("needed_by"))
+	if err != nil {
+		return nil, err
+	}
+	op.NeededBy = neededBy
+	return []service.ShoppingBulkOp{op}, nil
+}
+
+// parseNeededBy converts a YYYY-MM-DD date into epoch seconds (nil when empty).
+func parseNeededBy(s string) (*int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid needed_by %q (want YYYY-MM-DD)", s)
+	}
+	epoch := d.Unix()
+	return &epoch, nil
+}
+
+func (h *Handler) addShoppingListHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	itemIDs := r.Form["item_code"] // now carries ItemID from BOM
+	qtys := r.Form["qty"]
+	sources := r.Form["sources"] // per item, set when several invoices were resolved together
+	if len(itemIDs) == 0 || len(qtys) == 0 {
+		http.Error(w, "invalid input", http.StatusBadRequest)
+		return
+	}
+	invoices := service.ParseInvoiceNumbers(r.FormValue("invoice_number"))
+
+	// quantity per item, split by source invoice ("" = not attributed to an invoice)
+	sum := make(map[string]map[string]float64)
+	for i := range itemIDs {
+		id := strings.TrimSpace(itemIDs[i])
+		if id == "" {
+			continue
+		}
+		qStr := "1"
+		if i < len(qtys) && qtys[i] != "" {
+			qStr = qtys[i]
+		}
+		// fractional for parts bought by the metre or weight
+		q, err := strconv.ParseFloat(qStr, 64)
+		if err != nil || !(q > 0) || math.IsInf(q, 0) {
+			continue
+		}
+		if sum[id] == nil {
+			sum[id] = make(map[string]float64)
+		}
+		var split map[string]float64
+		if i < len(sources) && sources[i] != "" {
+			split = service.AllocateToSources(q, service.ParseLeafSources(sources[i]))
+		}
+		if len(split) == 0 {
+			inv := ""
+			if len(invoices) == 1 {
+				inv = invoices[0]
+			}
+			split = map[string]float64{inv: q}
+		}
+		for inv, n := range split {
+			sum[id][inv] += n
+		}
+	}
+	if len(sum) == 0 {
+		http.Error(w, "no valid items", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	// attribute rows to each invoice's build when there is one
+	buildIDs := make(map[string]int, len(invoices))
+	for _, inv := range invoices {
+		b, err := service.GetBuildForInvoice(ctx, h.dbURL, ownerID, inv)
+		if err != nil {
+			h.renderError(w, r, http.StatusInternalServerError, "failed to load build", err)
+			return
+		}
+		if b != nil {
+			buildIDs[inv] = b.ID
+		}
+	}
+
+	added := 0
+	for id, byInvoice := range sum {
+		for inv, q := range byInvoice {
+			var err error
+			if buildID := buildIDs[inv]; buildID != 0 {
+				err = service.AddBuildShoppingListEntry(ctx, h.dbURL, buildID, id, q)
+			} else {
+				err = service.AddShoppingListEntry(ctx, h.dbURL, ownerID, id, inv, q, false)
+			}
+			if err != nil {
+				h.renderError(w, r, http.StatusInternalServerError, "failed to add to shopping list", err)
+				return
+			}
+		}
+		added++
+	}
+
+	msg := fmt.Sprintf("%d items added to shopping list", added)
+	h.flash.Add(w, r, flash.Info, msg)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
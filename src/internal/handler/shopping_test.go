@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBulkShopping_Versions(t *testing.T) {
+	t.Parallel()
+	r := httptest.NewRequest(http.MethodPost, "/shopping-list/bulk",
+		strings.NewReader("action=set_quantity&quantity=3&list_id=4&list_id=7&version_4=2"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ops, err := decodeBulkShoppingForm(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || !reflect.DeepEqual(ops[0].Versions, map[int]int{4: 2}) {
+		t.Fatalf("unexpected ops: %+v", ops)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/shopping-list/bulk",
+		strings.NewReader(`{"operations":[{"action":"archive","list_ids":[4],"versions":{"4":5}}]}`))
+	ops, err = decodeBulkShoppingJSON(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) != 1 || !reflect.DeepEqual(ops[0].Versions, map[int]int{4: 5}) {
+		t.Fatalf("unexpected ops: %+v", ops)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/shopping-list/bulk", strings.NewReader("action=delete&list_id=4&version_4=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := decodeBulkShoppingForm(r); err == nil || !strings.Contains(err.Error(), "invalid version") {
+		t.Fatalf("expected invalid version error, got %v", err)
+	}
+}
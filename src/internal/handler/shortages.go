@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// shortageReportHandler lists, per build, the parts still to be received: how many
+// are required, on the shopping list, ordered and received, and which supplier they
+// come from. ?invoice= limits it to one build; ?format=json returns the report.
+func (h *Handler) shortageReportHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	invoice := strings.TrimSpace(r.URL.Query().Get("invoice"))
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	report, err := service.GetShortageReport(ctx, h.reportDBURL(ctx), ownerID, invoice)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load shortages", err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		if report == nil {
+			report = []service.BuildShortage{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"invoice": invoice, "builds": report})
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":   "Shortages",
+		"Invoice": invoice,
+		"Builds":  report,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "shortages.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
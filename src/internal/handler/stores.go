@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// The interfaces below are the database-backed operations the Xero routes depend on.
+// NewRouter wires them to dbStore; handler tests substitute in-memory fakes.
+
+// oauthStateStore persists one-time OAuth state values between connect and callback,
+// each bound to the nonce in the starting browser's cookie.
+type oauthStateStore interface {
+	CreateOAuthState(ctx context.Context, state, ownerID, nonce string, ttlSeconds int) error
+	ConsumeOAuthState(ctx context.Context, state, nonce string) (ownerID string, found bool, err error)
+}
+
+// connectionStore persists Xero connections after the OAuth callback.
+type connectionStore interface {
+	UpsertConnection(ctx context.Context, ownerID, tenantID, accessToken, refreshToken string, expiresInSeconds int64) error
+	SetConnectionTenantName(ctx context.Context, ownerID, tenantID, tenantName string) error
+}
+
+// credentialSource hands out Xero credentials for an owner (service.TokenManager).
+type credentialSource interface {
+	CredentialsForOwner(ctx context.Context, ownerID string) (service.XeroCredentials, error)
+}
+
+// invoiceStore resolves invoices into BOMs and records them as builds and parts list
+// snapshots; GetPart prefills items to create in Xero from the local parts table.
+type invoiceStore interface {
+	ResolveInvoice(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials, invoiceNumber string, opts service.ResolveOptions) ([]service.BOMNode, []service.LeafTotal, string, error)
+	UpsertBuildFromBOM(ctx context.Context, ownerID, invoiceNumber string, perAssy []service.BOMNode) (int, error)
+	SaveBOMSnapshot(ctx context.Context, ownerID, invoiceNumber string, totals []service.LeafTotal) (bool, error)
+	ListBOMSnapshots(ctx context.Context, ownerID, invoiceNumber string) ([]service.BOMSnapshot, error)
+	GetPart(ctx context.Context, partID string) (xero.Part, bool, error)
+}
+
+// orderStore reads the shopping list and records the purchase orders raised from it,
+// with the per-item default account codes of their lines. GetTrackedItemCodes
+// returns the items that have a supplier mapping. RecordPurchaseOrders stores the
+// orders, marks their rows ordered and writes the audit row in one transaction.
+type orderStore interface {
+	GetUnorderedShoppingRows(ctx context.Context, ownerID string) ([]service.ShoppingRow, error)
+	GetTrackedItemCodes(ctx context.Context, ownerID string) (map[string]bool, error)
+	GroupShoppingItemsByContact(ctx context.Context, ownerID string, rows []service.ShoppingRow, choices map[string]string) (map[string][]service.ContactItem, error)
+	RecordPurchaseOrders(ctx context.Context, ownerID string, pos []service.PurchaseOrderRecord, versions map[int]int) error
+	GetItemAccountCodes(ctx context.Context, itemIDs []string) (map[string]string, error)
+	SetItemAccountCodes(ctx context.Context, codes map[string]string) error
+}
+
+// settingsStore loads and saves per-owner settings (owner_settings).
+type settingsStore interface {
+	GetOwnerSettings(ctx context.Context, ownerID string) (service.OwnerSettings, error)
+	SaveOwnerSettings(ctx context.Context, ownerID string, s service.OwnerSettings) error
+	EnsureOwnerSettings(ctx context.Context, ownerID string) error
+}
+
+// inviteStore gates registration on invite codes (invite_codes).
+type inviteStore interface {
+	ConsumeInviteCode(ctx context.Context, code string) (bool, error)
+	ReleaseInviteCode(ctx context.Context, code string) error
+}
+
+// dbStore implements the store interfaces with the service package against dbURL.
+type dbStore struct {
+	dbURL string
+}
+
+func (s dbStore) CreateOAuthState(ctx context.Context, state, ownerID, nonce string, ttlSeconds int) error {
+	return service.CreateOAuthState(ctx, s.dbURL, state, ownerID, nonce, ttlSeconds)
+}
+
+func (s dbStore) ConsumeOAuthState(ctx context.Context, state, nonce string) (string, bool, error) {
+	return service.ConsumeOAuthState(ctx, s.dbURL, state, nonce)
+}
+
+func (s dbStore) UpsertConnection(ctx context.Context, ownerID, tenantID, accessToken, refreshToken string, expiresInSeconds int64) error {
+	return service.UpsertConnection(ctx, s.dbURL, ownerID, tenantID, accessToken, refreshToken, expiresInSeconds)
+}
+
+func (s dbStore) SetConnectionTenantName(ctx context.Context, ownerID, tenantID, tenantName string) error {
+	return service.SetConnectionTenantName(ctx, s.dbURL, ownerID, tenantID, tenantName)
+}
+
+func (s dbStore) ResolveInvoice(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials, invoiceNumber string, opts service.ResolveOptions) ([]service.BOMNode, []service.LeafTotal, string, error) {
+	return service.ResolveInvoice(ctx, s.dbURL, ownerID, xc, creds, invoiceNumber, opts)
+}
+
+func (s dbStore) UpsertBuildFromBOM(ctx context.Context, ownerID, invoiceNumber string, perAssy []service.BOMNode) (int, error) {
+	return service.UpsertBuildFromBOM(ctx, s.dbURL, ownerID, invoiceNumber, perAssy)
+}
+
+func (s dbStore) SaveBOMSnapshot(ctx context.Context, ownerID, invoiceNumber string, totals []service.LeafTotal) (bool, error) {
+	return service.SaveBOMSnapshot(ctx, s.dbURL, ownerID, invoiceNumber, totals)
+}
+
+func (s dbStore) ListBOMSnapshots(ctx context.Context, ownerID, invoiceNumber string) ([]service.BOMSnapshot, error) {
+	return service.ListBOMSnapshots(ctx, s.dbURL, ownerID, invoiceNumber)
+}
+
+func (s dbStore) GetPart(ctx context.Context, partID string) (xero.Part, bool, error) {
+	return service.GetPart(ctx, s.dbURL, partID)
+}
+
+func (s dbStore) GetUnorderedShoppingRows(ctx context.Context, ownerID string) ([]service.ShoppingRow, error) {
+	return service.GetUnorderedShoppingRows(ctx, s.dbURL, ownerID)
+}
+
+func (s dbStore) GetTrackedItemCodes(ctx context.Context, ownerID string) (map[string]bool, error) {
+	return service.GetTrackedItemCodes(ctx, s.dbURL, ownerID)
+}
+
+func (s dbStore) GroupShoppingItemsByContact(ctx context.Context, ownerID string, rows []service.ShoppingRow, choices map[string]string) (map[string][]service.ContactItem, error) {
+	return service.GroupShoppingItemsByContact(ctx, s.dbURL, ownerID, rows, choices)
+}
+
+func (s dbStore) RecordPurchaseOrders(ctx context.Context, ownerID string, pos []service.PurchaseOrderRecord, versions map[int]int) error {
+	return service.RecordPurchaseOrders(ctx, s.dbURL, ownerID, pos, versions)
+}
+
+func (s dbStore) GetItemAccountCodes(ctx context.Context, itemIDs []string) (map[string]string, error) {
+	return service.GetItemAccountCodes(ctx, s.dbURL, itemIDs)
+}
+
+func (s dbStore) SetItemAccountCodes(ctx context.Context, codes map[string]string) error {
+	return service.SetItemAccountCodes(ctx, s.dbURL, codes)
+}
+
+func (s dbStore) GetOwnerSettings(ctx context.Context, ownerID string) (service.OwnerSettings, error) {
+	return service.GetOwnerSettings(ctx, s.dbURL, ownerID)
+}
+
+func (s dbStore) SaveOwnerSettings(ctx context.Context, ownerID string, settings service.OwnerSettings) error {
+	return service.SaveOwnerSettings(ctx, s.dbURL, ownerID, settings)
+}
+
+func (s dbStore) EnsureOwnerSettings(ctx context.Context, ownerID string) error {
+	return service.EnsureOwnerSettings(ctx, s.dbURL, ownerID)
+}
+
+func (s dbStore) ConsumeInviteCode(ctx context.Context, code string) (bool, error) {
+	return service.ConsumeInviteCode(ctx, s.dbURL, code)
+}
+
+func (s dbStore) ReleaseInviteCode(ctx context.Context, code string) error {
+	return service.ReleaseInviteCode(ctx, s.dbURL, code)
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/validate"
+)
+
+// maxSubstituteNoteLen bounds the note kept with a substitute.
+const maxSubstituteNoteLen = 200
+
+// substituteChoice fills the "use a substitute" buttons offered when an invoice's BOM
+// has an item that can be neither ordered nor built.
+type substituteChoice struct {
+	Item    string
+	Options []string
+}
+
+// chooseSubstitute returns the substitutes chosen in the invoice form ("ITEM=SUB",
+// sorted by item) after applying choice: "ITEM=SUB" picks SUB for ITEM, replacing any
+// earlier pick, and "ITEM=" goes back to the item itself. Malformed pairs are dropped.
+func chooseSubstitute(pairs []string, choice string) []string {
+	chosen := map[string]string{}
+	for _, p := range append(pairs, choice) {
+		item, sub, ok := strings.Cut(p, "=")
+		item, sub = strings.TrimSpace(item), strings.TrimSpace(sub)
+		if !ok || item == "" || item == sub {
+			continue
+		}
+		if sub == "" {
+			delete(chosen, item)
+			continue
+		}
+		chosen[item] = sub
+	}
+	out := make([]string, 0, len(chosen))
+	for item, sub := range chosen {
+		out = append(out, item+"="+sub)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// substituteMap turns chooseSubstitute's pairs into service.ResolveOptions.Substitute.
+func substituteMap(pairs []string) map[string]string {
+	if len(pairs) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		if item, sub, ok := strings.Cut(p, "="); ok {
+			out[item] = sub
+		}
+	}
+	return out
+}
+
+// saveSubstituteHandler adds a substitute to an item from the item page, or updates
+// the priority and note of one it already has.
+func (h *Handler) saveSubstituteHandler(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	back := "/items/" + url.PathEscape(code)
+	redirectWithMsg := func(level flash.Level, msg string) {
+		h.flash.Add(w, r, level, msg)
+		http.Redirect(w, r, back, http.StatusSeeOther)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	f := validate.New(r.PostForm)
+	s := service.ItemSubstitute{
+		ItemID:       code,
+		SubstituteID: f.Code("substitute_id", "Substitute item code", maxItemCodeLen),
+		Priority:     f.Int("priority", "Priority", 0, 0, math.MaxInt),
+		Note:         f.MaxLen("note", "Note", maxSubstituteNoteLen),
+	}
+	if err := f.Err(); err != nil {
+		redirectWithMsg(flash.Error, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := service.SaveItemSubstitute(ctx, h.dbURL, s); err != nil {
+		redirectWithMsg(flash.Error, "Update failed: "+err.Error())
+		return
+	}
+	redirectWithMsg(flash.Info, s.SubstituteID+" can now be ordered instead of "+code)
+}
+
+// deleteSubstituteHandler removes one of an item's substitutes from the item page.
+func (h *Handler) deleteSubstituteHandler(w http.ResponseWriter, r *http.Request) {
+	code, sub := chi.URLParam(r, "code"), chi.URLParam(r, "substitute")
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	err := service.DeleteItemSubstitute(ctx, h.dbURL, code, sub)
+	if errors.Is(err, service.ErrSubstituteNotFound) {
+		h.renderError(w, r, http.StatusNotFound, err.Error(), err)
+		return
+	}
+	back := "/items/" + url.PathEscape(code)
+	if err != nil {
+		h.flash.Add(w, r, flash.Error, "Update failed: "+err.Error())
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	h.flash.Add(w, r, flash.Info, sub+" is no longer a substitute for "+code)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// partAvailabilityHandler marks an item unavailable, so invoices using it offer its
+// substitutes, or available again.
+func (h *Handler) partAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	unavailable := chi.URLParam(r, "action") == "unavailable"
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	back := "/items/" + url.PathEscape(code)
+	if err := service.SetPartUnavailable(ctx, h.dbURL, code, unavailable); err != nil {
+		h.flash.Add(w, r, flash.Error, "Update failed: "+err.Error())
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+	if unavailable {
+		h.flash.Add(w, r, flash.Info, code+" marked unavailable")
+	} else {
+		h.flash.Add(w, r, flash.Info, code+" marked available")
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
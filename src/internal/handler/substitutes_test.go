@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestChooseSubstitute(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		pairs  []string
+		choice string
+		want   []string
+	}{
+		{nil, "", []string{}},
+		{nil, "TAP=TAP-B", []string{"TAP=TAP-B"}},
+		{[]string{"TAP=TAP-B", "HOSE=HOSE-2"}, "TAP=TAP-C", []string{"HOSE=HOSE-2", "TAP=TAP-C"}},
+		{[]string{"TAP=TAP-B", "HOSE=HOSE-2"}, "TAP=", []string{"HOSE=HOSE-2"}},
+		{[]string{"TAP", "=X", "A=A", " B = C "}, "", []string{"B=C"}},
+	} {
+		if got := chooseSubstitute(tc.pairs, tc.choice); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("chooseSubstitute(%q, %q) = %q, want %q", tc.pairs, tc.choice, got, tc.want)
+		}
+	}
+	if got := substituteMap([]string{"TAP=TAP-B"}); !reflect.DeepEqual(got, map[string]string{"TAP": "TAP-B"}) {
+		t.Fatalf("substituteMap = %v", got)
+	}
+}
+
+func TestGetInvoice_OffersSubstitutes(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{msg: "item HOSE has no supplier contact; it can be replaced by HOSE-2, HOSE-3"}
+
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "expand": {"SINK"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{`name="use_substitute" value="HOSE=HOSE-2"`, `name="use_substitute" value="HOSE=HOSE-3"`, `name="expand" value="SINK"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fragment missing %q", want)
+		}
+	}
+}
+
+func TestGetInvoice_UseSubstitute(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy: []service.BOMNode{{PartID: "KIT", Quantity: 1, IsAssembly: true, Children: []service.BOMNode{
+			{PartID: "HOSE-2", Quantity: 2, SubstituteFor: "HOSE", Substitutes: []string{"HOSE-2", "HOSE-3"}},
+			{PartID: "TAP", Quantity: 1, Unavailable: true, Substitutes: []string{"TAP-B"}},
+		}}},
+		leafTotals: []service.LeafTotal{{PartID: "HOSE-2", Quantity: 2, SubstituteFor: "HOSE"}, {PartID: "TAP", Quantity: 1}},
+	}
+
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "use_substitute": {"HOSE=HOSE-2"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	if got := hs.store.resolve.Substitute; !reflect.DeepEqual(got, map[string]string{"HOSE": "HOSE-2"}) {
+		t.Fatalf("Substitute = %v", got)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`name="substitute" value="HOSE=HOSE-2"`,
+		`name="use_substitute" value="HOSE="`,       // back to the original
+		`name="use_substitute" value="HOSE=HOSE-3"`, // or another substitute
+		`name="use_substitute" value="TAP=TAP-B"`,
+		"unavailable",
+		"/invoice/INV-1/bom.pdf?substitute=HOSE%3DHOSE-2",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fragment missing %q", want)
+		}
+	}
+
+	// going back to the original survives the redirect to the home page
+	rec = hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "substitute": {"HOSE=HOSE-2", "TAP=TAP-B"}, "use_substitute": {"HOSE="}})
+	expectRedirect(t, rec, "/")
+	if got := hs.invoiceView(rec).Substitute; !reflect.DeepEqual(got, []string{"TAP=TAP-B"}) {
+		t.Fatalf("saved Substitute = %v", got)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// supplierBillingCSV is the supplier billing report as CSV records, header first.
+func supplierBillingCSV(report *service.SupplierBillingReport) [][]string {
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	records := [][]string{{"ContactID", "AccountNumber", "Name", "POs", "Committed", "Bills", "Billed", "Difference", "MissingPOs"}}
+	for _, s := range report.Suppliers {
+		records = append(records, []string{s.ContactID, s.AccountNumber, s.Name, strconv.Itoa(s.POs), money(s.Committed), strconv.Itoa(s.Bills), money(s.Billed), money(s.Difference), strconv.Itoa(s.Missing)})
+	}
+	return records
+}
+
+// supplierBillingHandler compares, per supplier, the value of the purchase orders the
+// owner raised over the last ?months= months (default 12) with the bills Xero holds
+// from those suppliers. ?format=json returns the report; ?format=csv downloads it.
+func (h *Handler) supplierBillingHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	months, ok := reportMonths(r.URL.Query().Get("months"))
+	if !ok {
+		http.Error(w, "months must be 1-"+strconv.Itoa(maxUsageMonths), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+
+	since := usageSince(time.Now(), months)
+	report, err := service.RunSupplierBillingReport(ctx, h.reportDBURL(ctx), h.xc, ownerID, creds, since)
+	if err != nil {
+		h.xeroError(w, r, "failed to load supplier billing", err)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+		return
+	case "csv":
+		var buf bytes.Buffer
+		if err := csv.NewWriter(&buf).WriteAll(supplierBillingCSV(report)); err != nil {
+			h.renderError(w, r, http.StatusInternalServerError, "export failed", err)
+			return
+		}
+		writeDownload(w, "text/csv; charset=utf-8", "attachment", "supplier-billing-"+since.Format("2006-01"), ".csv", buf.Bytes())
+		return
+	}
+
+	loc := orgLocale(h.organisation(ctx, creds))
+	data := map[string]interface{}{
+		"Title":    "Supplier billing",
+		"Months":   months,
+		"Since":    since,
+		"Report":   report,
+		"Currency": loc.Currency,
+		"Locale":   loc,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "supplier_billing.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
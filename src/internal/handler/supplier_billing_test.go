@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestSupplierBillingCSV(t *testing.T) {
+	t.Parallel()
+	records := supplierBillingCSV(&service.SupplierBillingReport{Suppliers: []service.SupplierBilling{
+		{ContactID: "c-1", AccountNumber: "ACME", Name: "Acme, Inc", POs: 2, Committed: 150.5, Bills: 1, Billed: 100, Difference: -50.5, Missing: 1},
+	}})
+	want := []string{"c-1", "ACME", "Acme, Inc", "2", "150.50", "1", "100.00", "-50.50", "1"}
+	if len(records) != 2 || !reflect.DeepEqual(records[1], want) {
+		t.Fatalf("got %q, want header and %q", records, want)
+	}
+}
+
+func TestSupplierBilling_Errors(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	expectStatus(t, hs.do(http.MethodGet, "/reports/supplier-billing?months=61", nil), http.StatusBadRequest)
+
+	hs.creds.err = service.ErrNoConnection
+	expectStatus(t, hs.do(http.MethodGet, "/reports/supplier-billing", nil), http.StatusNotFound)
+}
+
+func TestSupplierBillingTemplate(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	var buf bytes.Buffer
+	err := hs.handler.templates.ExecuteTemplate(&buf, "supplier_billing.html", map[string]interface{}{
+		"Months":   6,
+		"Since":    time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC),
+		"Currency": "GBP",
+		"Report": &service.SupplierBillingReport{
+			Suppliers: []service.SupplierBilling{{AccountNumber: "FAST", Name: "Fasteners", POs: 1, Committed: 20, Bills: 1, Billed: 32, Difference: 12, Missing: 1}},
+			Committed: 20,
+			Billed:    32,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Fasteners", "£32.00", "£12.00", "text-red-600", "1 not in Xero", "since October 2025", "months=6"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+}
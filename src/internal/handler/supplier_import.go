@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// supplierImportResult is what one upload did, for the page and ?format=json.
+// Orderable lists the owner's unordered shopping rows that had no supplier and get
+// one from the import.
+type supplierImportResult struct {
+	Filename  string                `json:"filename"`
+	Rows      int                   `json:"rows"`
+	Errors    []csvimport.RowError  `json:"errors"`
+	DryRun    bool                  `json:"dry_run"`
+	Applied   bool                  `json:"applied"`
+	Result    csvimport.Result      `json:"result"`
+	Orderable []service.ShoppingRow `json:"orderable"`
+}
+
+// supplierImportPageHandler shows the items_contacts CSV upload form.
+func (h *Handler) supplierImportPageHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderSupplierImport(w, r, http.StatusOK, nil, "")
+}
+
+// supplierImportHandler ingests an item-to-supplier CSV (multipart field "file";
+// columns item_code, account_number). Every item code and AccountNumber must exist
+// in Xero; any problem is reported per line and nothing is written, otherwise all
+// rows are added to items_contacts in one transaction. dry_run=1 checks without
+// writing. Either way the result lists the shopping list items the import makes
+// orderable. ?format=json returns the result (422 when lines have errors).
+func (h *Handler) supplierImportHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	asJSON := r.URL.Query().Get("format") == "json"
+	fail := func(msg string, code int) {
+		if asJSON {
+			http.Error(w, msg, code)
+			return
+		}
+		h.renderSupplierImport(w, r, code, nil, msg)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBOMImportBytes+1<<20)
+	if err := r.ParseMultipartForm(maxBOMImportBytes); err != nil {
+		fail("upload too large or malformed (max 5 MB)", http.StatusBadRequest)
+		return
+	}
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		fail("no file selected", http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+	rows, rowErrs, err := csvimport.ParseSupplierMappings(f)
+	if err != nil {
+		fail(fh.Filename+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if errors.Is(err, service.ErrNoConnection) {
+		fail(err.Error(), http.StatusNotFound)
+		return
+	}
+	if h.redirectToReconnect(w, r, err) {
+		return
+	}
+	if err != nil {
+		if h.renderUnavailable(w, r, "Xero", err) {
+			return
+		}
+		fail(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	knownItems, err := service.KnownXeroItemCodes(ctx, h.xc, creds.AccessToken, creds.TenantID, csvimport.SupplierItemCodes(rows))
+	if err != nil {
+		if h.renderUnavailable(w, r, "Xero", err) {
+			return
+		}
+		fail("Item lookup failed: "+errorText("Xero", err), http.StatusBadGateway)
+		return
+	}
+	knownAccounts, err := service.KnownXeroAccountNumbers(ctx, h.xc, creds.AccessToken, creds.TenantID, csvimport.SupplierAccounts(rows))
+	if err != nil {
+		if h.renderUnavailable(w, r, "Xero", err) {
+			return
+		}
+		fail("Contact lookup failed: "+errorText("Xero", err), http.StatusBadGateway)
+		return
+	}
+	rowErrs = append(rowErrs, csvimport.CheckSupplierMappings(rows, knownItems, knownAccounts)...)
+	csvimport.SortErrors(rowErrs)
+
+	res := &supplierImportResult{Filename: fh.Filename, Rows: len(rows), Errors: rowErrs, DryRun: r.PostFormValue("dry_run") == "1"}
+	status := http.StatusOK
+	if len(rowErrs) > 0 {
+		status = http.StatusUnprocessableEntity
+	} else {
+		// read before importing: afterwards every imported item is tracked
+		shopping, err := h.orders.GetUnorderedShoppingRows(ctx, ownerID)
+		if err != nil {
+			fail(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		tracked, err := h.orders.GetTrackedItemCodes(ctx, ownerID)
+		if err != nil {
+			fail(err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Orderable = service.NewlyOrderable(shopping, tracked, rows)
+		if !res.DryRun {
+			if res.Result, err = service.ImportSupplierMappings(ctx, h.dbURL, ownerID, rows); err != nil {
+				fail("import failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Applied = true
+		}
+	}
+
+	if asJSON {
+		if res.Errors == nil {
+			res.Errors = []csvimport.RowError{}
+		}
+		if res.Orderable == nil {
+			res.Orderable = []service.ShoppingRow{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+	h.renderSupplierImport(w, r, status, res, "")
+}
+
+// renderSupplierImport renders the upload page with the last upload's result or error.
+func (h *Handler) renderSupplierImport(w http.ResponseWriter, r *http.Request, status int, res *supplierImportResult, errMsg string) {
+	data := map[string]interface{}{
+		"Title":     "Import suppliers",
+		"Header":    csvimport.SupplierHeader,
+		"Import":    res,
+		"Error":     errMsg,
+		"CSRFToken": mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	if err := h.templates.ExecuteTemplate(w, "supplier_import.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestSupplierImport(t *testing.T) {
+	t.Parallel()
+	const target = "/suppliers/import?csrf_token=" + testCSRFToken
+
+	t.Run("unknown items and accounts are reported per line", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.handler.xc = fakeXeroSuppliers(t).Client()
+		rec := hs.do(http.MethodPost, target+"&format=json", nil, withCSVUpload(t, "item_code,account_number\nBOLT,SUP-1\nFRAME,SUP-1\nNUT,SUP-9\n", nil))
+		expectStatus(t, rec, http.StatusUnprocessableEntity)
+		var res supplierImportResult
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Applied || res.Rows != 3 || len(res.Errors) != 2 ||
+			res.Errors[0].Message != "unknown item code FRAME" ||
+			res.Errors[1].Line != 4 || res.Errors[1].Message != "no Xero contact with account number SUP-9" {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	})
+	t.Run("dry run lists items that become orderable", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.handler.xc = fakeXeroSuppliers(t).Client()
+		hs.store.shopping = []service.ShoppingRow{
+			{ListID: 1, ItemID: "BOLT", Quantity: 8, SourceInvoice: "INV-0001"},
+			{ListID: 2, ItemID: "NUT", Quantity: 8},
+			{ListID: 3, ItemID: "WASHER", Quantity: 2},
+		}
+		hs.store.tracked = map[string]bool{"NUT": true}
+		rec := hs.do(http.MethodPost, target+"&format=json", nil, withCSVUpload(t, "item_code,account_number\nBOLT,SUP-1\nNUT,SUP-1\n", map[string]string{"dry_run": "1"}))
+		expectStatus(t, rec, http.StatusOK)
+		var res supplierImportResult
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Applied || !res.DryRun || len(res.Errors) != 0 || len(res.Orderable) != 1 || res.Orderable[0].ItemID != "BOLT" {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+
+		rec = hs.do(http.MethodPost, target, nil, withCSVUpload(t, "item_code,account_number\nBOLT,SUP-1\n", map[string]string{"dry_run": "1"}))
+		expectStatus(t, rec, http.StatusOK)
+		body := rec.Body.String()
+		if !strings.Contains(body, "1 shopping list item(s) would become orderable") || !strings.Contains(body, "INV-0001") {
+			t.Fatalf("page missing orderable items: %s", body)
+		}
+	})
+	t.Run("bad header", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodPost, target, nil, withCSVUpload(t, "item,contact\nA,B\n", nil))
+		expectStatus(t, rec, http.StatusBadRequest)
+		if !strings.Contains(rec.Body.String(), "header must have columns item_code, account_number") {
+			t.Fatalf("page missing error: %s", rec.Body.String())
+		}
+	})
+	t.Run("form page", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodGet, "/suppliers/import", nil)
+		expectStatus(t, rec, http.StatusOK)
+		if !strings.Contains(rec.Body.String(), `action="/suppliers/import?csrf_token=`) {
+			t.Fatalf("form missing: %s", rec.Body.String())
+		}
+	})
+}
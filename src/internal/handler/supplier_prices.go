@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// priceListStore imports supplier price lists (supplier_prices) into the agreed prices
+// of items_contacts, and pushes them to the Xero items' purchase prices.
+type priceListStore interface {
+	MappedSuppliers(ctx context.Context, ownerID string, items []string) (map[[2]string]bool, error)
+	ImportSupplierPrices(ctx context.Context, ownerID string, src csvimport.PriceSource, rows []csvimport.PriceRow) (csvimport.Result, []service.PriceChange, error)
+	PushXeroPurchasePrices(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials) (int, error)
+}
+
+func (s dbStore) MappedSuppliers(ctx context.Context, ownerID string, items []string) (map[[2]string]bool, error) {
+	return service.MappedSuppliers(ctx, s.dbURL, ownerID, items)
+}
+
+func (s dbStore) ImportSupplierPrices(ctx context.Context, ownerID string, src csvimport.PriceSource, rows []csvimport.PriceRow) (csvimport.Result, []service.PriceChange, error) {
+	return service.ImportSupplierPrices(ctx, s.dbURL, ownerID, src, rows)
+}
+
+func (s dbStore) PushXeroPurchasePrices(ctx context.Context, ownerID string, xc *xero.Client, creds service.XeroCredentials) (int, error) {
+	return service.PushXeroPurchasePrices(ctx, s.dbURL, xc, ownerID, creds)
+}
+
+// priceImportResult is what one price list upload did, for the page and ?format=json.
+// Changes are the agreed prices it changed now; Upcoming the lines dated later.
+type priceImportResult struct {
+	Filename   string                `json:"filename"`
+	Rows       int                   `json:"rows"`
+	Errors     []csvimport.RowError  `json:"errors"`
+	DryRun     bool                  `json:"dry_run"`
+	Applied    bool                  `json:"applied"`
+	Result     csvimport.Result      `json:"result"`
+	Changes    []service.PriceChange `json:"changes"`
+	Upcoming   []csvimport.PriceRow  `json:"upcoming"`
+	PushToXero bool                  `json:"push_to_xero"`
+	Pushed     int                   `json:"pushed"`               // Xero items whose purchase price was updated
+	PushError  string                `json:"push_error,omitempty"` // why they were not; retried by the apply-supplier-prices job
+}
+
+// supplierPricesPageHandler shows the price list upload form.
+func (h *Handler) supplierPricesPageHandler(w http.ResponseWriter, r *http.Request) {
+	h.renderSupplierPrices(w, r, http.StatusOK, nil, "")
+}
+
+// supplierPricesHandler ingests a supplier price list CSV (multipart field "file";
+// columns item_code, account_number, unit_price and optionally effective_from). Every
+// line must be for an item and supplier already mapped; any problem is reported per
+// line and nothing is written. Otherwise the prices are stored and those in effect
+// become the agreed prices at once, later ones on their date. push_to_xero=1 also
+// sets the Xero items' purchase prices; dry_run=1 checks without writing.
+// ?format=json returns the result (422 when lines have errors).
+func (h *Handler) supplierPricesHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	asJSON := r.URL.Query().Get("format") == "json"
+	fail := func(msg string, code int) {
+		if asJSON {
+			http.Error(w, msg, code)
+			return
+		}
+		h.renderSupplierPrices(w, r, code, nil, msg)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBOMImportBytes+1<<20)
+	if err := r.ParseMultipartForm(maxBOMImportBytes); err != nil {
+		fail("upload too large or malformed (max 5 MB)", http.StatusBadRequest)
+		return
+	}
+	f, fh, err := r.FormFile("file")
+	if err != nil {
+		fail("no file selected", http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+	rows, rowErrs, err := csvimport.ParsePriceList(f)
+	if err != nil {
+		fail(fh.Filename+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	mapped, err := h.prices.MappedSuppliers(ctx, ownerID, csvimport.PriceItemCodes(rows))
+	if err != nil {
+		fail(err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rowErrs = append(rowErrs, csvimport.CheckPriceList(rows, mapped)...)
+	csvimport.SortErrors(rowErrs)
+
+	res := &priceImportResult{
+		Filename:   fh.Filename,
+		Rows:       len(rows),
+		Errors:     rowErrs,
+		DryRun:     r.PostFormValue("dry_run") == "1",
+		PushToXero: r.PostFormValue("push_to_xero") == "1",
+	}
+	today := time.Now().Format(time.DateOnly)
+	for _, row := range rows {
+		if row.EffectiveFrom > today {
+			res.Upcoming = append(res.Upcoming, row)
+		}
+	}
+	status := http.StatusOK
+	switch {
+	case len(rowErrs) > 0:
+		status = http.StatusUnprocessableEntity
+		res.Upcoming = nil
+	case !res.DryRun:
+		src := csvimport.PriceSource{Filename: fh.Filename, ImportedBy: userID, PushToXero: res.PushToXero}
+		if res.Result, res.Changes, err = h.prices.ImportSupplierPrices(ctx, ownerID, src, rows); err != nil {
+			fail("import failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Applied = true
+		if res.PushToXero && len(res.Changes) > 0 {
+			// the prices are in; a Xero failure leaves the push to the hourly job
+			creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+			if err == nil {
+				res.Pushed, err = h.prices.PushXeroPurchasePrices(ctx, ownerID, h.xc, creds)
+			}
+			if err != nil {
+				res.PushError = errorText("Xero", err)
+			}
+		}
+	}
+
+	if asJSON {
+		if res.Errors == nil {
+			res.Errors = []csvimport.RowError{}
+		}
+		if res.Changes == nil {
+			res.Changes = []service.PriceChange{}
+		}
+		if res.Upcoming == nil {
+			res.Upcoming = []csvimport.PriceRow{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+	h.renderSupplierPrices(w, r, status, res, "")
+}
+
+// renderSupplierPrices renders the upload page with the last upload's result or error.
+func (h *Handler) renderSupplierPrices(w http.ResponseWriter, r *http.Request, status int, res *priceImportResult, errMsg string) {
+	data := map[string]interface{}{
+		"Title":     "Import price lists",
+		"Header":    csvimport.PriceHeader,
+		"Import":    res,
+		"Error":     errMsg,
+		"CSRFToken": mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	if err := h.templates.ExecuteTemplate(w, "supplier_prices.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
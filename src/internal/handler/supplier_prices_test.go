@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSupplierPrices(t *testing.T) {
+	t.Parallel()
+	const target = "/suppliers/prices?csrf_token=" + testCSRFToken
+
+	t.Run("lines for unmapped suppliers are reported", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.store.mapped[[2]string{"BOLT", "SUP-1"}] = true
+		rec := hs.do(http.MethodPost, target+"&format=json", nil, withCSVUpload(t, "item_code,account_number,unit_price\nBOLT,SUP-1,0.25\nBOLT,SUP-2,0.2\nNUT,SUP-1,x\n", nil))
+		expectStatus(t, rec, http.StatusUnprocessableEntity)
+		var res priceImportResult
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Applied || res.Rows != 2 || len(res.Errors) != 2 ||
+			res.Errors[0].Line != 3 || res.Errors[0].Message != "BOLT is not bought from SUP-2; import the supplier mapping first" || res.Errors[1].Line != 4 {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+		if len(hs.store.priceImports) != 0 {
+			t.Fatal("nothing should be imported")
+		}
+	})
+	t.Run("prices in effect are applied and pushed to Xero", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.store.mapped[[2]string{"BOLT", "SUP-1"}] = true
+		hs.store.mapped[[2]string{"NUT", "SUP-1"}] = true
+		csv := "item_code,account_number,unit_price,effective_from\nBOLT,SUP-1,0.25,\nNUT,SUP-1,0.05,2999-01-01\n"
+
+		rec := hs.do(http.MethodPost, target+"&format=json", nil, withCSVUpload(t, csv, map[string]string{"dry_run": "1", "push_to_xero": "1"}))
+		expectStatus(t, rec, http.StatusOK)
+		var res priceImportResult
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Applied || len(res.Upcoming) != 1 || len(hs.store.priceImports) != 0 || hs.store.pushes != 0 {
+			t.Fatalf("dry run: %+v", res)
+		}
+
+		rec = hs.do(http.MethodPost, target, nil, withCSVUpload(t, csv, map[string]string{"push_to_xero": "1"}))
+		expectStatus(t, rec, http.StatusOK)
+		body := rec.Body.String()
+		for _, want := range []string{"Imported 2 line(s)", `href="/items/BOLT"`, "1 Xero item purchase price(s) updated.", "1 price(s) take effect later", "from 2999-01-01"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("page missing %q:\n%s", want, body)
+			}
+		}
+		if len(hs.store.priceImports) != 1 || hs.store.priceImports[0].Filename != "bom.csv" || !hs.store.priceImports[0].PushToXero ||
+			hs.store.priceImports[0].ImportedBy != testOwnerID || hs.store.pushes != 1 {
+			t.Fatalf("imports %+v, pushes %d", hs.store.priceImports, hs.store.pushes)
+		}
+	})
+	t.Run("a failed push keeps the import", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.store.mapped[[2]string{"BOLT", "SUP-1"}] = true
+		hs.store.pushErr = errors.New("xero items post: status 500")
+		rec := hs.do(http.MethodPost, target, nil, withCSVUpload(t, "item_code,account_number,unit_price\nBOLT,SUP-1,0.25\n", map[string]string{"push_to_xero": "1"}))
+		expectStatus(t, rec, http.StatusOK)
+		if body := rec.Body.String(); !strings.Contains(body, "Xero purchase prices not updated yet") || !strings.Contains(body, "Imported 1 line(s)") {
+			t.Fatalf("unexpected page: %s", body)
+		}
+	})
+	t.Run("bad header", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodPost, target, nil, withCSVUpload(t, "item_code,account_number\nBOLT,SUP-1\n", nil))
+		expectStatus(t, rec, http.StatusBadRequest)
+		if !strings.Contains(rec.Body.String(), "header must have columns item_code, account_number, unit_price") {
+			t.Fatalf("unexpected page: %s", rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+)
+
+// unavailableMessage is the user-facing text for a dependency whose circuit is open.
+func unavailableMessage(service string) string {
+	return service + " is temporarily unavailable. Please try again in a minute."
+}
+
+// errorText is the message errorStatus gives for err from service: e.g.
+// unavailableMessage for its open circuit breaker, else err's own text.
+func errorText(service string, err error) string {
+	_, msg := errorStatus(service, err)
+	return msg
+}
+
+// renderUnavailable answers 503 with the "temporarily unavailable" page (problem+json
+// for JSON clients) when err comes from an open circuit breaker, and reports whether it did.
+func (h *Handler) renderUnavailable(w http.ResponseWriter, r *http.Request, service string, err error) bool {
+	var open *breaker.OpenError
+	if !errors.As(err, &open) {
+		return false
+	}
+	wait := time.Until(open.Until).Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())))
+
+	if h.templates == nil || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		h.renderError(w, r, http.StatusServiceUnavailable, unavailableMessage(service), err)
+		return true
+	}
+	back := ""
+	if r.Method == http.MethodGet {
+		back = r.URL.RequestURI()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = h.templates.ExecuteTemplate(w, "unavailable.html", map[string]any{
+		"Service":    service,
+		"RetryAfter": wait.String(),
+		"Back":       back,
+	})
+	return true
+}
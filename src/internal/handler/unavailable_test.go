@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/breaker"
+)
+
+// openCircuit routes the harness's Xero client through a breaker whose circuit for
+// the fake Xero host is already open.
+func openCircuit(t *testing.T, hs *harness) {
+	t.Helper()
+	u, err := url.Parse(hs.handler.xc.BaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := breaker.New(1, time.Minute)
+	if err := br.Allow(u.Host); err != nil {
+		t.Fatal(err)
+	}
+	br.Record(u.Host, false)
+	hc := *hs.handler.xc.HTTPClient
+	hc.Transport = br.Transport(hc.Transport)
+	hs.handler.xc.HTTPClient = &hc
+}
+
+func TestXeroCallback_CircuitOpen(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.states["st-1"] = oauthState{owner: testOwnerID, nonce: testNonce}
+	openCircuit(t, hs)
+
+	rec := hs.do(http.MethodGet, "/xero/callback?code=code-1&state=st-1", nil, withNonce)
+	expectStatus(t, rec, http.StatusServiceUnavailable)
+	if ra := rec.Header().Get("Retry-After"); ra == "" || ra == "0" {
+		t.Fatalf("Retry-After = %q", ra)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "Xero is temporarily unavailable") || !strings.Contains(body, "Try again") {
+		t.Fatalf("unexpected page: %s", body)
+	}
+}
+
+func TestCreatePurchaseOrders_CircuitOpen(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	openCircuit(t, hs)
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, unavailableMessage("Xero")) {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if len(hs.store.ordered) != 0 {
+		t.Fatal("nothing should be ordered")
+	}
+}
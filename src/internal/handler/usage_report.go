@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// Usage report period in months (?months=).
+const (
+	defaultUsageMonths = 12
+	maxUsageMonths     = 60
+)
+
+// usageSince is the start of the UTC month months-1 months before now, so the period
+// covers whole months including the current one.
+func usageSince(now time.Time, months int) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// reportMonths parses a report's ?months= value; "" is the default and ok is false
+// outside 1-maxUsageMonths.
+func reportMonths(s string) (months int, ok bool) {
+	if s == "" {
+		return defaultUsageMonths, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxUsageMonths {
+		return 0, false
+	}
+	return n, true
+}
+
+// usageCSV is one table of the usage report as CSV records, header first; ok is false
+// for an unknown table.
+func usageCSV(report *service.UsageReport, table string) (records [][]string, ok bool) {
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	switch table {
+	case "parts":
+		records = append(records, []string{"PartID", "Name", "Quantity", "Orders", "Spend", "UnpricedLines"})
+		for _, p := range report.TopParts {
+			records = append(records, []string{p.PartID, p.Name, strconv.FormatFloat(p.Quantity, 'f', -1, 64), strconv.Itoa(p.Orders), money(p.Spend), strconv.Itoa(p.Unpriced)})
+		}
+	case "spend":
+		records = append(records, []string{"Month", "SupplierID", "SupplierName", "Orders", "Spend", "UnpricedLines"})
+		for _, s := range report.SupplierSpend {
+			records = append(records, []string{s.Month, s.SupplierID, s.SupplierName, strconv.Itoa(s.Orders), money(s.Spend), strconv.Itoa(s.Unpriced)})
+		}
+	case "receipts":
+		records = append(records, []string{"Month", "Rows", "AverageDays"})
+		for _, rt := range report.Receipts {
+			records = append(records, []string{rt.Month, strconv.Itoa(rt.Rows), strconv.FormatFloat(rt.AverageDays, 'f', 1, 64)})
+		}
+	default:
+		return nil, false
+	}
+	return records, true
+}
+
+// usageReportHandler shows the owner's purchasing over the last ?months= months
+// (default 12): the most ordered parts, spend per supplier per month and how long
+// ordered parts took to arrive. ?format=json returns the report;
+// ?format=csv&table=parts|spend|receipts downloads one table.
+func (h *Handler) usageReportHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	months, ok := reportMonths(q.Get("months"))
+	if !ok {
+		http.Error(w, "months must be 1-"+strconv.Itoa(maxUsageMonths), http.StatusBadRequest)
+		return
+	}
+	format, table := q.Get("format"), q.Get("table")
+	if format == "csv" {
+		if _, ok := usageCSV(&service.UsageReport{}, table); !ok {
+			http.Error(w, "unknown table (want parts, spend or receipts)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	since := usageSince(time.Now(), months)
+	report, err := service.GetUsageReport(ctx, h.reportDBURL(ctx), ownerID, since)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load usage report", err)
+		return
+	}
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(report)
+		return
+	case "csv":
+		records, _ := usageCSV(report, table)
+		var buf bytes.Buffer
+		if err := csv.NewWriter(&buf).WriteAll(records); err != nil {
+			h.renderError(w, r, http.StatusInternalServerError, "export failed", err)
+			return
+		}
+		writeDownload(w, "text/csv; charset=utf-8", "attachment", "usage-"+table+"-"+since.Format("2006-01"), ".csv", buf.Bytes())
+		return
+	}
+
+	loc := h.localeForOwner(ctx, ownerID)
+	data := map[string]interface{}{
+		"Title":    "Usage",
+		"Months":   months,
+		"Since":    since,
+		"Report":   report,
+		"Currency": loc.Currency,
+		"Locale":   loc,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "usage.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
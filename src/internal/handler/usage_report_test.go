@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestUsageSince(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 17, 8, 0, 0, 0, time.UTC)
+	for months, want := range map[int]string{1: "2026-03-01", 3: "2026-01-01", 12: "2025-04-01"} {
+		if got := usageSince(now, months).Format("2006-01-02"); got != want {
+			t.Errorf("%d months: since %s, want %s", months, got, want)
+		}
+	}
+}
+
+func TestUsageCSV(t *testing.T) {
+	t.Parallel()
+	report := &service.UsageReport{
+		TopParts:      []service.PartUsage{{PartID: "BOLT", Name: "Bolt", Quantity: 120, Orders: 2, Spend: 12.4, Unpriced: 1}},
+		SupplierSpend: []service.SupplierSpend{{Month: "2026-03", SupplierID: "ACME", SupplierName: "Acme, Inc", Orders: 1, Spend: 10}},
+		Receipts:      []service.ReceiptTime{{Month: "2026-03", Rows: 2, AverageDays: 5}},
+	}
+	for table, want := range map[string][]string{
+		"parts":    {"PartID", "BOLT", "Bolt", "120", "2", "12.40", "1"},
+		"spend":    {"Month", "2026-03", "ACME", "Acme, Inc", "1", "10.00", "0"},
+		"receipts": {"Month", "2026-03", "2", "5.0"},
+	} {
+		records, ok := usageCSV(report, table)
+		if !ok || len(records) != 2 {
+			t.Fatalf("%s: got %v, %v", table, records, ok)
+		}
+		if got := append([]string{records[0][0]}, records[1]...); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", table, got, want)
+		}
+	}
+	if _, ok := usageCSV(report, "builds"); ok {
+		t.Fatal("unknown table accepted")
+	}
+}
+
+func TestUsageReport_BadParams(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	for _, target := range []string{"/reports/usage?months=0", "/reports/usage?months=x", "/reports/usage?format=csv&table=builds"} {
+		expectStatus(t, hs.do(http.MethodGet, target, nil), http.StatusBadRequest)
+	}
+}
+
+func TestUsageTemplate(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	var buf bytes.Buffer
+	err := hs.handler.templates.ExecuteTemplate(&buf, "usage.html", map[string]interface{}{
+		"Months":   6,
+		"Since":    time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC),
+		"Currency": "GBP",
+		"Report": &service.UsageReport{
+			TopParts:      []service.PartUsage{{PartID: "BOLT", Quantity: 120, Orders: 2, Spend: 12.4}},
+			SupplierSpend: []service.SupplierSpend{{Month: "2026-03", SupplierID: "ACME", Spend: 10, Unpriced: 2}},
+			Receipts:      []service.ReceiptTime{{Month: "2026-03", Rows: 2, AverageDays: 5}},
+			Receipt:       service.ReceiptTime{Rows: 2, AverageDays: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"/items/BOLT", "£12.40", "2 unpriced", "on average 5 day(s)", "months=6", "table=spend"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/validate"
+)
+
+// writeFieldErrors answers a JSON request whose fields failed validation with 422
+// and one entry per field, so a script can tell which value to fix.
+func writeFieldErrors(w http.ResponseWriter, errs validate.Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": errs.Error(), "errors": errs})
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/validate"
+)
+
+func TestOversizedFormRejected(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	rec := hs.do(http.MethodPost, "/settings", url.Values{"note": {strings.Repeat("x", maxFormBytes)}})
+	expectStatus(t, rec, http.StatusRequestEntityTooLarge)
+}
+
+func TestBulkShoppingJSON_FieldErrors(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	body := `{"operations":[{"action":"delete","list_ids":[1]},{"list_ids":[2],"needed_by":"next week"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/shopping-list/bulk", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(mid.CSRFHeader, testCSRFToken)
+	r.AddCookie(&http.Cookie{Name: "access_token", Value: testAccessToken})
+	r.AddCookie(&http.Cookie{Name: mid.CSRFCookieName, Value: testCSRFToken})
+	rec := httptest.NewRecorder()
+	hs.router.ServeHTTP(rec, r)
+	expectStatus(t, rec, http.StatusUnprocessableEntity)
+
+	var got struct {
+		Error  string          `json:"error"`
+		Errors validate.Errors `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Errors) != 2 ||
+		got.Errors.Get("operations[1].action") != "operation 2: action is required" ||
+		!strings.Contains(got.Errors.Get("operations[1].needed_by"), `invalid needed_by "next week"`) {
+		t.Fatalf("unexpected errors: %+v", got)
+	}
+}
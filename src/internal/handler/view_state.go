@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/utils"
+)
+
+// invoiceViewCookie holds the id of the invoice results waiting for the home page;
+// the results themselves are stored in view_states, so any instance can show them.
+const invoiceViewCookie = "xero_invoice_view"
+
+// invoiceViewTTL is how long resolved invoice results wait to be shown.
+const invoiceViewTTL = 5 * time.Minute
+
+// invoiceView is what the invoice form hands to the home page after its redirect.
+type invoiceView struct {
+	InvoiceNumber string              `json:"invoice_number"`
+	IgnoreStock   bool                `json:"ignore_stock,omitempty"`
+	Expand        []string            `json:"expand,omitempty"`     // supplier assemblies ordered as their parts
+	Substitute    []string            `json:"substitute,omitempty"` // chosen substitutes, "ITEM=SUB"
+	PerAssy       []service.BOMNode   `json:"per_assy"`
+	LeafTotals    []service.LeafTotal `json:"leaf_totals"`
+}
+
+// viewStateStore keeps short-lived per-owner page state between a POST and the
+// page it redirects to (view_states).
+type viewStateStore interface {
+	SaveViewState(ctx context.Context, ownerID string, v any, ttl time.Duration) (string, error)
+	TakeViewState(ctx context.Context, ownerID, id string, dst any) (bool, error)
+}
+
+func (s dbStore) SaveViewState(ctx context.Context, ownerID string, v any, ttl time.Duration) (string, error) {
+	return service.SaveViewState(ctx, s.dbURL, ownerID, v, ttl)
+}
+
+func (s dbStore) TakeViewState(ctx context.Context, ownerID, id string, dst any) (bool, error) {
+	return service.TakeViewState(ctx, s.dbURL, ownerID, id, dst)
+}
+
+// saveInvoiceView stores v for the owner's next home page and points the cookie at it.
+func (h *Handler) saveInvoiceView(w http.ResponseWriter, r *http.Request, ownerID string, v invoiceView) error {
+	id, err := h.views.SaveViewState(r.Context(), ownerID, v, invoiceViewTTL)
+	if err != nil {
+		return err
+	}
+	utils.SetCookie(w, r, invoiceViewCookie, id, time.Now().Add(invoiceViewTTL))
+	return nil
+}
+
+// takeInvoiceView returns the invoice results saved for this browser, once. It
+// reports false when there are none or they expired.
+func (h *Handler) takeInvoiceView(w http.ResponseWriter, r *http.Request, ownerID string) (invoiceView, bool) {
+	var v invoiceView
+	c, err := r.Cookie(invoiceViewCookie)
+	if err != nil || c.Value == "" {
+		return v, false
+	}
+	utils.ClearCookie(w, r, invoiceViewCookie)
+	if ownerID == "" || h.views == nil {
+		return v, false
+	}
+	found, err := h.views.TakeViewState(r.Context(), ownerID, c.Value, &v)
+	if err != nil {
+		log.Printf("home: load invoice view: %v", err)
+		return v, false
+	}
+	return v, found
+}
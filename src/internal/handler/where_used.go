@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// whereUsedHandler lists every top-level assembly that uses the item, e.g. before
+// replacing a discontinued part. ?format=json returns the raw result.
+func (h *Handler) whereUsedHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		http.Error(w, "item code missing", http.StatusBadRequest)
+		return
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	assemblies, err := service.WhereUsed(ctx, h.reportDBURL(ctx), ownerID, code)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load where used", err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"item_id":    code,
+			"assemblies": assemblies,
+		})
+		return
+	}
+
+	data := map[string]interface{}{
+		"Title":      "Where used: " + code,
+		"ItemID":     code,
+		"Assemblies": assemblies,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "where_used.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
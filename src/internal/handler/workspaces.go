@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/utils"
+)
+
+// workspaceCookie remembers the workspace the browser last switched to. It is only a
+// preference: every request checks the user is still a member.
+const workspaceCookie = "workspace"
+
+// workspaceCookieTTL keeps the choice for a year.
+const workspaceCookieTTL = 365 * 24 * time.Hour
+
+// workspaceStore resolves and manages workspaces and their members (workspaces,
+// workspace_members).
+type workspaceStore interface {
+	ResolveWorkspace(ctx context.Context, userID, email, requested string) (service.Workspace, error)
+	ListWorkspaces(ctx context.Context, userID string) ([]service.Workspace, error)
+	CreateWorkspace(ctx context.Context, userID, email, name string) (string, error)
+	SetWorkspaceJoinCode(ctx context.Context, workspaceID, code string) error
+	JoinWorkspace(ctx context.Context, code, userID, email string) (service.Workspace, bool, error)
+	ListWorkspaceMembers(ctx context.Context, workspaceID string) ([]service.WorkspaceMemberInfo, error)
+	RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) (bool, error)
+}
+
+func (s dbStore) ResolveWorkspace(ctx context.Context, userID, email, requested string) (service.Workspace, error) {
+	return service.ResolveWorkspace(ctx, s.dbURL, userID, email, requested)
+}
+
+func (s dbStore) ListWorkspaces(ctx context.Context, userID string) ([]service.Workspace, error) {
+	return service.ListWorkspaces(ctx, s.dbURL, userID)
+}
+
+func (s dbStore) CreateWorkspace(ctx context.Context, userID, email, name string) (string, error) {
+	return service.CreateWorkspace(ctx, s.dbURL, userID, email, name)
+}
+
+func (s dbStore) SetWorkspaceJoinCode(ctx context.Context, workspaceID, code string) error {
+	return service.SetWorkspaceJoinCode(ctx, s.dbURL, workspaceID, code)
+}
+
+func (s dbStore) JoinWorkspace(ctx context.Context, code, userID, email string) (service.Workspace, bool, error) {
+	return service.JoinWorkspace(ctx, s.dbURL, code, userID, email)
+}
+
+func (s dbStore) ListWorkspaceMembers(ctx context.Context, workspaceID string) ([]service.WorkspaceMemberInfo, error) {
+	return service.ListWorkspaceMembers(ctx, s.dbURL, workspaceID)
+}
+
+func (s dbStore) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID string) (bool, error) {
+	return service.RemoveWorkspaceMember(ctx, s.dbURL, workspaceID, userID)
+}
+
+// workspaceCtxKey holds the request's service.Workspace.
+type workspaceCtxKey struct{}
+
+// requireWorkspace runs after RequireAuth. It resolves the workspace the user works
+// in (the one in workspaceCookie if they are a member of it, else their personal
+// one) and stores its id as mid.CtxWorkspaceID, which handlers pass to the service
+// layer as the owner of everything they read and write.
+func (h *Handler) requireWorkspace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(mid.CtxUserID).(string)
+		var requested string
+		if c, err := r.Cookie(workspaceCookie); err == nil {
+			requested = c.Value
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		ws, err := h.workspaces.ResolveWorkspace(ctx, userID, userEmail(r), requested)
+		cancel()
+		if err != nil {
+			log.Printf("workspace for %s: %v", userID, err)
+			http.Error(w, "failed to load workspace", http.StatusInternalServerError)
+			return
+		}
+		rctx := context.WithValue(r.Context(), mid.CtxWorkspaceID, ws.ID)
+		rctx = context.WithValue(rctx, workspaceCtxKey{}, ws)
+		next.ServeHTTP(w, r.WithContext(rctx))
+	})
+}
+
+// currentWorkspace is the workspace requireWorkspace resolved for r.
+func currentWorkspace(r *http.Request) service.Workspace {
+	ws, _ := r.Context().Value(workspaceCtxKey{}).(service.Workspace)
+	return ws
+}
+
+// userEmail is the signed-in user's email claim ("" if the token has none).
+func userEmail(r *http.Request) string {
+	claims, _ := r.Context().Value(mid.CtxClaims).(map[string]interface{})
+	email, _ := claims["email"].(string)
+	return email
+}
+
+// switchWorkspace points the browser at workspace id from the next request on.
+func switchWorkspace(w http.ResponseWriter, r *http.Request, id string) {
+	utils.SetCookie(w, r, workspaceCookie, id, time.Now().Add(workspaceCookieTTL))
+}
+
+// workspacesHandler lists the user's workspaces and the members of the current one,
+// with forms to switch, create and join workspaces.
+func (h *Handler) workspacesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	current := currentWorkspace(r)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	workspaces, err := h.workspaces.ListWorkspaces(ctx, userID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to list workspaces", err)
+		return
+	}
+	members, err := h.workspaces.ListWorkspaceMembers(ctx, current.ID)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to list members", err)
+		return
+	}
+	data := map[string]interface{}{
+		"Title":      "Workspaces",
+		"Workspaces": workspaces,
+		"Current":    current,
+		"IsOwner":    current.Role == service.WorkspaceOwner,
+		"Personal":   current.Personal(userID),
+		"Members":    members,
+		"Self":       userID,
+		"Flash":      h.flash.Pop(w, r),
+		"CSRFToken":  mid.CSRFToken(r),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if h.templates == nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.templates.ExecuteTemplate(w, "workspaces.html", data); err != nil {
+		http.Error(w, "template error", http.StatusInternalServerError)
+	}
+}
+
+// workspacesRedirect flashes msg and goes back to the workspaces page.
+func (h *Handler) workspacesRedirect(w http.ResponseWriter, r *http.Request, level flash.Level, msg string) {
+	h.flash.Add(w, r, level, msg)
+	http.Redirect(w, r, "/workspaces", http.StatusSeeOther)
+}
+
+// createWorkspaceHandler creates a workspace (form field name) owned by the user and
+// switches to it.
+func (h *Handler) createWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	name, err := service.NormalizeWorkspaceName(r.PostFormValue("name"))
+	if err != nil {
+		h.workspacesRedirect(w, r, flash.Error, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	id, err := h.workspaces.CreateWorkspace(ctx, userID, userEmail(r), name)
+	if err != nil {
+		log.Printf("create workspace: %v", err)
+		h.workspacesRedirect(w, r, flash.Error, "Could not create the workspace")
+		return
+	}
+	switchWorkspace(w, r, id)
+	h.workspacesRedirect(w, r, flash.Info, "Created "+name+"; connect it to Xero from the home page")
+}
+
+// switchWorkspaceHandler makes workspace id (form field) the current one. Membership
+// is checked on every request, so switching to a workspace the user is not in
+// leaves them in their personal one.
+func (h *Handler) switchWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	switchWorkspace(w, r, r.PostFormValue("id"))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// joinWorkspaceHandler adds the user to the workspace with the join code (form field
+// code) and switches to it.
+func (h *Handler) joinWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	ws, ok, err := h.workspaces.JoinWorkspace(ctx, r.PostFormValue("code"), userID, userEmail(r))
+	if err != nil {
+		log.Printf("join workspace: %v", err)
+		h.workspacesRedirect(w, r, flash.Error, "Could not join the workspace")
+		return
+	}
+	if !ok {
+		h.workspacesRedirect(w, r, flash.Error, "Unknown join code")
+		return
+	}
+	switchWorkspace(w, r, ws.ID)
+	h.workspacesRedirect(w, r, flash.Info, "Joined "+ws.Name)
+}
+
+// workspaceJoinCodeHandler issues a new join code for the current workspace
+// ({action} rotate, which invalidates the old one) or stops joining (close). Owners
+// only; personal workspaces cannot be joined.
+func (h *Handler) workspaceJoinCodeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ws := currentWorkspace(r)
+	if ws.Role != service.WorkspaceOwner {
+		http.Error(w, "only workspace owners can change the join code", http.StatusForbidden)
+		return
+	}
+	if ws.Personal(userID) {
+		h.workspacesRedirect(w, r, flash.Error, "Personal workspaces cannot be shared; create a workspace to share instead")
+		return
+	}
+	var code string
+	if chi.URLParam(r, "action") == "rotate" {
+		var err error
+		if code, err = service.NewWorkspaceJoinCode(); err != nil {
+			http.Error(w, "failed to generate code", http.StatusInternalServerError)
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := h.workspaces.SetWorkspaceJoinCode(ctx, ws.ID, code); err != nil {
+		log.Printf("workspace join code: %v", err)
+		h.workspacesRedirect(w, r, flash.Error, "Could not change the join code")
+		return
+	}
+	if code == "" {
+		h.workspacesRedirect(w, r, flash.Info, "Joining closed")
+		return
+	}
+	h.workspacesRedirect(w, r, flash.Info, "New join code issued; the old one no longer works")
+}
+
+// removeWorkspaceMemberHandler takes a member ({user}) out of the current workspace.
+// Owners can remove any member; members can only remove themselves (leave). Owners
+// cannot be removed.
+func (h *Handler) removeWorkspaceMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ws := currentWorkspace(r)
+	member := chi.URLParam(r, "user")
+	if member != userID && ws.Role != service.WorkspaceOwner {
+		http.Error(w, "only workspace owners can remove members", http.StatusForbidden)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	removed, err := h.workspaces.RemoveWorkspaceMember(ctx, ws.ID, member)
+	if err != nil {
+		log.Printf("remove workspace member: %v", err)
+		h.workspacesRedirect(w, r, flash.Error, "Could not remove the member")
+		return
+	}
+	if !removed {
+		h.workspacesRedirect(w, r, flash.Error, "Owners cannot be removed")
+		return
+	}
+	if member == userID {
+		utils.ClearCookie(w, r, workspaceCookie)
+		h.workspacesRedirect(w, r, flash.Info, "You left "+ws.Name)
+		return
+	}
+	h.workspacesRedirect(w, r, flash.Info, "Member removed")
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// inWorkspace sends the workspace cookie for id, as after switching to it.
+func inWorkspace(id string) reqOption {
+	return func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: workspaceCookie, Value: id})
+	}
+}
+
+// workspaceCookieValue is the workspace cookie set on the response ("" if none).
+func workspaceCookieValue(rec *httptest.ResponseRecorder) string {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == workspaceCookie {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// sharedWorkspace has testOwnerID create a workspace and open joining, and returns
+// its id and join code.
+func sharedWorkspace(t *testing.T, hs *harness) (id, code string) {
+	t.Helper()
+	rec := hs.do(http.MethodPost, "/workspaces", url.Values{"name": {"Workshop"}})
+	expectRedirect(t, rec, "/workspaces")
+	id = workspaceCookieValue(rec)
+	if id == "" {
+		t.Fatal("creating a workspace did not switch to it")
+	}
+	expectRedirect(t, hs.do(http.MethodPost, "/workspaces/current/join-code/rotate", url.Values{}, inWorkspace(id)), "/workspaces")
+	code = hs.store.workspaces[id].joinCode
+	if code == "" {
+		t.Fatal("no join code issued")
+	}
+	return id, code
+}
+
+func TestWorkspaces(t *testing.T) {
+	t.Parallel()
+
+	t.Run("personal by default", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodGet, "/workspaces", nil)
+		expectStatus(t, rec, http.StatusOK)
+		if body := rec.Body.String(); !strings.Contains(body, "Members of Personal") || strings.Contains(body, "join-code/rotate") {
+			t.Fatalf("personal workspace page:\n%s", body)
+		}
+	})
+
+	t.Run("join and work in a shared workspace", func(t *testing.T) {
+		hs := newHarness(t)
+		id, code := sharedWorkspace(t, hs)
+
+		rec := hs.do(http.MethodPost, "/workspaces/join", url.Values{"code": {code}}, asAdmin)
+		expectRedirect(t, rec, "/workspaces")
+		if got := workspaceCookieValue(rec); got != id {
+			t.Fatalf("joined workspace %q, want %q", got, id)
+		}
+		if role := hs.store.workspaces[id].members[testAdminID]; role != "member" {
+			t.Fatalf("joined as %q", role)
+		}
+
+		hs.do(http.MethodGet, "/xero/connect", nil, asAdmin, inWorkspace(id))
+		if len(hs.store.states) != 1 {
+			t.Fatalf("%d OAuth states stored", len(hs.store.states))
+		}
+		for state, st := range hs.store.states {
+			if st.owner != id {
+				t.Fatalf("state %q stored for %q, want the workspace %q", state, st.owner, id)
+			}
+		}
+
+		rec = hs.do(http.MethodGet, "/workspaces", nil, asAdmin, inWorkspace(id))
+		expectStatus(t, rec, http.StatusOK)
+		body := rec.Body.String()
+		for _, want := range []string{"Members of Workshop", testOwnerID + "@example.com", "Leave"} {
+			if !strings.Contains(body, want) {
+				t.Fatalf("members page missing %q:\n%s", want, body)
+			}
+		}
+		if strings.Contains(body, code) {
+			t.Fatal("members are shown the join code")
+		}
+	})
+
+	t.Run("unknown join code", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodPost, "/workspaces/join", url.Values{"code": {"nope"}}, asAdmin)
+		expectRedirect(t, rec, "/workspaces")
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "Unknown join code" {
+			t.Fatalf("flash = %v", msgs)
+		}
+	})
+
+	t.Run("non-members stay in their personal workspace", func(t *testing.T) {
+		hs := newHarness(t)
+		id, _ := sharedWorkspace(t, hs)
+		hs.do(http.MethodGet, "/xero/connect", nil, asAdmin, inWorkspace(id))
+		if len(hs.store.states) != 1 {
+			t.Fatalf("%d OAuth states stored", len(hs.store.states))
+		}
+		for state, st := range hs.store.states {
+			if st.owner != testAdminID {
+				t.Fatalf("state %q stored for %q, want the personal workspace", state, st.owner)
+			}
+		}
+	})
+
+	t.Run("join code is for owners of shared workspaces", func(t *testing.T) {
+		hs := newHarness(t)
+		id, code := sharedWorkspace(t, hs)
+		hs.do(http.MethodPost, "/workspaces/join", url.Values{"code": {code}}, asAdmin)
+		expectStatus(t, hs.do(http.MethodPost, "/workspaces/current/join-code/close", url.Values{}, asAdmin, inWorkspace(id)), http.StatusForbidden)
+
+		expectRedirect(t, hs.do(http.MethodPost, "/workspaces/current/join-code/close", url.Values{}, inWorkspace(id)), "/workspaces")
+		if got := hs.store.workspaces[id].joinCode; got != "" {
+			t.Fatalf("join code %q after closing", got)
+		}
+
+		rec := hs.do(http.MethodPost, "/workspaces/current/join-code/rotate", url.Values{})
+		expectRedirect(t, rec, "/workspaces")
+		if got := hs.store.workspaces[testOwnerID].joinCode; got != "" {
+			t.Fatalf("personal workspace given join code %q", got)
+		}
+	})
+
+	t.Run("remove and leave", func(t *testing.T) {
+		hs := newHarness(t)
+		id, code := sharedWorkspace(t, hs)
+		hs.do(http.MethodPost, "/workspaces/join", url.Values{"code": {code}}, asAdmin)
+
+		expectStatus(t, hs.do(http.MethodPost, "/workspaces/current/members/"+testOwnerID+"/remove", url.Values{}, asAdmin, inWorkspace(id)), http.StatusForbidden)
+		rec := hs.do(http.MethodPost, "/workspaces/current/members/"+testOwnerID+"/remove", url.Values{}, inWorkspace(id))
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "Owners cannot be removed" {
+			t.Fatalf("flash = %v", msgs)
+		}
+
+		expectRedirect(t, hs.do(http.MethodPost, "/workspaces/current/members/"+testAdminID+"/remove", url.Values{}, asAdmin, inWorkspace(id)), "/workspaces")
+		if _, ok := hs.store.workspaces[id].members[testAdminID]; ok {
+			t.Fatal("member could not leave")
+		}
+
+		hs.do(http.MethodPost, "/workspaces/join", url.Values{"code": {code}}, asAdmin)
+		expectRedirect(t, hs.do(http.MethodPost, "/workspaces/current/members/"+testAdminID+"/remove", url.Values{}, inWorkspace(id)), "/workspaces")
+		if _, ok := hs.store.workspaces[id].members[testAdminID]; ok {
+			t.Fatal("owner could not remove a member")
+		}
+	})
+}
@@ -3,16 +3,18 @@ package handler
 import (
 	"context"
 	"crypto/rand"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
-	"os"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
 	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
 	"github.com/hwalton/xero-invoice-orderer/internal/service"
 	"github.com/hwalton/xero-invoice-orderer/internal/utils"
@@ -28,19 +30,23 @@ func generateState(n int) (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// oauthNonceCookie holds the per-browser nonce a connect flow's state is bound to, so
+// a callback only completes in the browser that started it.
+const oauthNonceCookie = "xero_oauth_nonce"
+
 // xeroConnect redirects to Xero auth URL
 func (h *Handler) xeroConnectHandler(w http.ResponseWriter, r *http.Request) {
 	if h.auth != nil {
 		r = mid.EnsureUserIDInContext(r, h.auth)
 	}
-	ownerID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
 	if ownerID == "" {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	clientID := utils.GetEnv("XERO_CLIENT_ID", "")
-	redirect := utils.GetEnv("REDIRECT", "http://localhost:8080/xero/callback")
+	clientID := h.cfg.Xero.ClientID
+	redirect := h.cfg.Xero.RedirectURL
 
 	// generate secure state and persist mapping -> ownerID (use DB-backed store with TTL)
 	state, err := generateState(16)
@@ -48,8 +54,13 @@ func (h *Handler) xeroConnectHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to generate state", http.StatusInternalServerError)
 		return
 	}
-	ttl := 300 // seconds (5 minutes) — adjust as needed
-	if err := service.CreateOAuthState(r.Context(), h.dbURL, state, ownerID, ttl); err != nil {
+	nonce, err := generateState(16)
+	if err != nil {
+		http.Error(w, "failed to generate state", http.StatusInternalServerError)
+		return
+	}
+	ttl := int(h.cfg.Xero.StateTTL / time.Second)
+	if err := h.states.CreateOAuthState(r.Context(), state, ownerID, nonce, ttl); err != nil {
 		// Log the underlying error for debugging (do not expose internal details to clients).
 		// Use server logs to inspect permission/constraint/connection issues.
 		log.Printf("xeroConnect: CreateOAuthState failed: %v", err)
@@ -57,11 +68,14 @@ func (h *Handler) xeroConnectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authURL := xero.BuildAuthURL(clientID, redirect, state)
+	utils.SetCookie(w, r, oauthNonceCookie, nonce, time.Now().Add(h.cfg.Xero.StateTTL))
+
+	authURL := h.xc.BuildAuthURL(clientID, redirect, state)
 	http.Redirect(w, r, authURL, http.StatusFound)
 }
 
-// xeroCallback exchanges code for tokens and persists connection(s)
+// xeroCallback exchanges code for tokens and persists connection(s) for the
+// workspace the flow was started in, which must still be the current one
 func (h *Handler) xeroCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	code := r.URL.Query().Get("code")
@@ -76,8 +90,16 @@ func (h *Handler) xeroCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the state must come back to the browser that started the flow
+	nc, err := r.Cookie(oauthNonceCookie)
+	if err != nil || nc.Value == "" {
+		http.Error(w, "invalid or expired state", http.StatusBadRequest)
+		return
+	}
+	utils.ClearCookie(w, r, oauthNonceCookie)
+
 	// lookup ownerID by state (one-time use) via DB
-	ownerID, found, err := service.ConsumeOAuthState(ctx, h.dbURL, state)
+	ownerID, found, err := h.states.ConsumeOAuthState(ctx, state, nc.Value)
 	if err != nil {
 		http.Error(w, "state lookup failed", http.StatusInternalServerError)
 		return
@@ -86,20 +108,27 @@ func (h *Handler) xeroCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid or expired state", http.StatusBadRequest)
 		return
 	}
+	// the flow must finish in the workspace it started in. requireWorkspace only
+	// resolves a workspace the user is still a member of, so this also turns away a
+	// user removed from it in the meantime.
+	if current, _ := ctx.Value(mid.CtxWorkspaceID).(string); current != ownerID {
+		http.Error(w, "Xero was connected for a workspace you are not working in; switch to it and connect again", http.StatusForbidden)
+		return
+	}
 
-	clientID := os.Getenv("XERO_CLIENT_ID")
-	clientSecret := os.Getenv("XERO_CLIENT_SECRET")
-	redirect := utils.GetEnv("REDIRECT", "http://localhost:8080/xero/callback")
+	clientID := h.cfg.Xero.ClientID
+	clientSecret := h.cfg.Xero.ClientSecret
+	redirect := h.cfg.Xero.RedirectURL
 
-	tr, err := xero.ExchangeCodeForToken(ctx, h.client, clientID, clientSecret, code, redirect)
+	tr, err := h.xc.ExchangeCodeForToken(ctx, clientID, clientSecret, code, redirect)
 	if err != nil {
-		http.Error(w, "token exchange failed: "+err.Error(), http.StatusInternalServerError)
+		h.xeroError(w, r, "token exchange failed", err)
 		return
 	}
 
-	conns, err := xero.GetConnections(ctx, h.client, tr.AccessToken)
+	conns, err := h.xc.GetConnections(ctx, tr.AccessToken)
 	if err != nil {
-		http.Error(w, "failed to get connections: "+err.Error(), http.StatusInternalServerError)
+		h.xeroError(w, r, "failed to get connections", err)
 		return
 	}
 
@@ -109,8 +138,12 @@ func (h *Handler) xeroCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		if expires == 0 {
 			expires = 3600
 		}
-		if err := service.UpsertConnection(ctx, h.dbURL, ownerID, c.TenantID, tr.AccessToken, tr.RefreshToken, expires); err != nil {
-			http.Error(w, "persist connection failed: "+err.Error(), http.StatusInternalServerError)
+		if err := h.conns.UpsertConnection(ctx, ownerID, c.TenantID, tr.AccessToken, tr.RefreshToken, expires); err != nil {
+			h.renderError(w, r, http.StatusInternalServerError, "persist connection failed", err)
+			return
+		}
+		if err := h.conns.SetConnectionTenantName(ctx, ownerID, c.TenantID, c.TenantName); err != nil {
+			h.renderError(w, r, http.StatusInternalServerError, "persist connection failed", err)
 			return
 		}
 	}
@@ -118,27 +151,34 @@ func (h *Handler) xeroCallbackHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-// xeroConnections lists stored Xero connections for the current user.
+// xeroConnections lists the current user's stored Xero connections as sanitised
+// summaries (no tokens). Query: page, per_page, status (active|expiring|expired), q.
 func (h *Handler) xeroConnectionsHandler(w http.ResponseWriter, r *http.Request) {
 	if h.auth != nil {
 		r = mid.EnsureUserIDInContext(r, h.auth)
 	}
-	ownerID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
 	if ownerID == "" {
 		http.Error(w, "owner id missing", http.StatusUnauthorized)
 		return
 	}
+	opts, err := service.ParseConnectionListOptions(r.URL.Query())
+	if err != nil {
+		h.renderError(w, r, http.StatusBadRequest, err.Error(), err)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	conns, err := service.GetConnectionsForOwner(ctx, h.dbURL, ownerID)
+	page, err := service.ListConnectionSummaries(ctx, h.dbURL, ownerID, opts)
 	if err != nil {
-		http.Error(w, "failed to load connections: "+err.Error(), http.StatusInternalServerError)
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load connections", err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(conns)
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(page)
 }
 
 // getInvoiceHandler POSTs a form with invoice_id, queries Xero for that invoice's line item codes,
@@ -146,12 +186,14 @@ func (h *Handler) xeroConnectionsHandler(w http.ResponseWriter, r *http.Request)
 //   - PerAssemblyBOM: tree showing "Qty required (for each Assy)"
 //   - LeafTotals: flat list aggregating total required for purchasable leaves
 //
-// These are stored in cookies for home page rendering.
+// These are stored in cookies for home page rendering. htmx requests instead get the
+// "invoice-bom.html" fragment back so the form updates in place; messages and errors
+// are rendered into the fragment rather than redirected.
 func (h *Handler) getInvoiceHandler(w http.ResponseWriter, r *http.Request) {
 	if h.auth != nil {
 		r = mid.EnsureUserIDInContext(r, h.auth)
 	}
-	ownerID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
 	if ownerID == "" {
 		http.Error(w, "owner id missing", http.StatusUnauthorized)
 		return
@@ -160,550 +202,567 @@ func (h *Handler) getInvoiceHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid form", http.StatusBadRequest)
 		return
 	}
-	invoiceNumber := strings.TrimSpace(r.FormValue("invoice_id"))
-	if invoiceNumber == "" {
-		http.Error(w, "invoice number required", http.StatusBadRequest)
+	fragment := isHTMXRequest(r)
+
+	// fail reports an error; htmx only swaps 2xx responses, so fragments carry it as a message
+	fail := func(msg string, code int) {
+		if fragment {
+			h.renderFragment(w, "invoice-bom.html", map[string]interface{}{
+				"InvoiceMessages": []flash.Message{{Level: flash.Error, Text: msg}},
+			})
+			return
+		}
+		http.Error(w, msg, code)
+	}
+	// failXero is fail for errors from calls to Xero, with the status and message
+	// errorStatus gives; an open circuit gets the unavailable page outside fragments
+	failXero := func(err error) {
+		if !fragment && h.renderUnavailable(w, r, "Xero", err) {
+			return
+		}
+		code, msg := errorStatus("Xero", err)
+		fail(msg, code)
+	}
+
+	// one or more invoice numbers, comma separated or one per line
+	invoiceNumbers := service.ParseInvoiceNumbers(r.FormValue("invoice_id"))
+	if len(invoiceNumbers) == 0 {
+		fail("invoice number required", http.StatusBadRequest)
+		return
+	}
+	if len(invoiceNumbers) > service.MaxInvoicesPerRequest {
+		fail(fmt.Sprintf("too many invoices (max %d)", service.MaxInvoicesPerRequest), http.StatusBadRequest)
 		return
 	}
+	ignoreStock := r.FormValue("ignore_stock") != ""
+	// supplier assemblies to order as their parts; a toggle button flips one of them
+	expand := toggleExpand(r.Form["expand"], r.FormValue("toggle"))
+	// substitutes the user chose ("ITEM=SUB"); a use_substitute button changes one
+	substitutes := chooseSubstitute(r.Form["substitute"], r.FormValue("use_substitute"))
 
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second*time.Duration(len(invoiceNumbers)))
 	defer cancel()
+	// invoices often share parts, and the stock lookup wants the same items again
+	ctx = service.WithItemLookups(ctx)
+	ctx, finish := h.trackProgress(ctx, r, ownerID)
+	defer finish()
+
+	// credentials for the owner's Xero connection (refreshed if near expiry)
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if errors.Is(err, service.ErrNoConnection) {
+		fail(err.Error(), http.StatusNotFound)
+		return
+	}
+	if h.redirectToReconnect(w, r, err) {
+		return
+	}
+	if err != nil {
+		failXero(err)
+		return
+	}
+
+	redirectWithMsg := func(msg string) {
+		if fragment {
+			h.renderFragment(w, "invoice-bom.html", map[string]interface{}{
+				"InvoiceMessages": []flash.Message{{Level: flash.Warn, Text: msg}},
+			})
+			return
+		}
+		h.flash.Add(w, r, flash.Warn, msg)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
 
-	// load Xero connection for the owner (first one)
-	conns, err := service.GetConnectionsForOwner(ctx, h.dbURL, ownerID)
-	if err != nil || len(conns) == 0 {
-		http.Error(w, "no xero connection found for owner", http.StatusNotFound)
+	settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+	if err != nil {
+		fail("failed to load settings: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	found := &conns[0]
 
-	// refresh token if near expiry (<= 60s)
-	now := time.Now().UTC()
-	if found.ExpiresAt <= now.Unix()+60 {
-		clientID := os.Getenv("XERO_CLIENT_ID")
-		clientSecret := os.Getenv("XERO_CLIENT_SECRET")
-		tr, rerr := xero.RefreshToken(ctx, h.client, clientID, clientSecret, found.RefreshToken)
-		if rerr != nil {
-			http.Error(w, "refresh token failed: "+rerr.Error(), http.StatusInternalServerError)
+	opts := settings.ResolveOptions()
+	opts.Expand = expand
+	opts.Substitute = substituteMap(substitutes)
+
+	var perAssy []service.BOMNode
+	perInvoiceTotals := make([][]service.LeafTotal, 0, len(invoiceNumbers))
+	for i, invoiceNumber := range invoiceNumbers {
+		service.ReportProgress(ctx, i, len(invoiceNumbers), fmt.Sprintf("Resolving invoice %s (%d of %d)", invoiceNumber, i+1, len(invoiceNumbers)))
+		invPerAssy, invTotals, msg, err := h.invoices.ResolveInvoice(ctx, ownerID, h.xc, creds, invoiceNumber, opts)
+		if err != nil {
+			failXero(err)
 			return
 		}
-		if err := service.UpsertConnection(ctx, h.dbURL, ownerID, found.TenantID, tr.AccessToken, tr.RefreshToken, tr.ExpiresIn); err != nil {
-			http.Error(w, "persist refreshed token failed: "+err.Error(), http.StatusInternalServerError)
+		if msg != "" {
+			if len(invoiceNumbers) > 1 && !strings.Contains(msg, invoiceNumber) {
+				msg = "Invoice " + invoiceNumber + ": " + msg
+			}
+			h.postBOMUnresolved(ownerID, msg)
+			// an item missing from Xero can be created from the fragment, and one
+			// without a supplier swapped for a substitute
+			if fragment {
+				if item, options, ok := service.SubstitutesOffered(msg); ok {
+					h.renderFragment(w, "invoice-bom.html", map[string]interface{}{
+						"InvoiceMessages":  []flash.Message{{Level: flash.Warn, Text: msg}},
+						"SubstituteChoice": &substituteChoice{Item: item, Options: options},
+						"InvoiceID":        r.FormValue("invoice_id"),
+						"IgnoreStock":      ignoreStock,
+						"Expand":           expand,
+						"Substitute":       substitutes,
+						"CSRFToken":        mid.CSRFToken(r),
+					})
+					return
+				}
+				if item := h.missingItemForm(ctx, msg); item != nil {
+					h.renderFragment(w, "invoice-bom.html", map[string]interface{}{
+						"InvoiceMessages": []flash.Message{{Level: flash.Warn, Text: msg}},
+						"MissingItem":     item,
+						"InvoiceID":       r.FormValue("invoice_id"),
+						"IgnoreStock":     ignoreStock,
+						"CSRFToken":       mid.CSRFToken(r),
+					})
+					return
+				}
+			}
+			redirectWithMsg(msg)
 			return
 		}
-		found.AccessToken = tr.AccessToken
-		secs := tr.ExpiresIn
-		if secs == 0 {
-			secs = 3600
+		perAssy = append(perAssy, invPerAssy...)
+		perInvoiceTotals = append(perInvoiceTotals, invTotals)
+
+		// record the invoice as a build so purchasing progress can be tracked and shared
+		if _, err := h.invoices.UpsertBuildFromBOM(ctx, ownerID, invoiceNumber, invPerAssy); err != nil {
+			log.Printf("getInvoice: record build for invoice %s: %v", invoiceNumber, err)
+		}
+		// keep the parts list when it changed so /invoice/{number}/changes can show how
+		if _, err := h.invoices.SaveBOMSnapshot(ctx, ownerID, invoiceNumber, invTotals); err != nil {
+			log.Printf("getInvoice: snapshot bom for invoice %s: %v", invoiceNumber, err)
 		}
-		found.ExpiresAt = time.Now().Unix() + secs
 	}
 
-	client := h.client
-	if client == nil {
-		client = http.DefaultClient
+	// merge across invoices; Sources keeps each invoice's share for the shopping list
+	var leafTotals []service.LeafTotal
+	if len(invoiceNumbers) == 1 {
+		leafTotals = perInvoiceTotals[0]
+	} else {
+		leafTotals = service.MergeLeafTotals(invoiceNumbers, perInvoiceTotals)
 	}
 
-	// 1) Fetch invoice lines (roots)
-	lines, err := xero.GetInvoiceItemCodes(ctx, client, found.AccessToken, found.TenantID, invoiceNumber)
-	if err != nil {
-		http.Error(w, "fetch invoice items failed: "+err.Error(), http.StatusInternalServerError)
+	// 4b) Deduct stock on hand for tracked inventory items unless asked not to
+	stockMsg := ""
+	if !ignoreStock {
+		stock, err := service.FetchStockOnHand(ctx, h.xc, creds.AccessToken, creds.TenantID, service.LeafPartIDs(leafTotals))
+		if err != nil {
+			log.Printf("getInvoice: fetch stock on hand for invoices %s: %v", strings.Join(invoiceNumbers, ","), err)
+			stockMsg = "Stock levels unavailable from Xero; quantities do not account for stock on hand"
+		} else {
+			service.ApplyStockOnHand(leafTotals, stock)
+		}
+	}
+
+	view := invoiceView{
+		InvoiceNumber: strings.Join(invoiceNumbers, ", "),
+		IgnoreStock:   ignoreStock,
+		Expand:        expand,
+		Substitute:    substitutes,
+		PerAssy:       perAssy,
+		LeafTotals:    leafTotals,
+	}
+	if fragment {
+		data := map[string]interface{}{"CSRFToken": mid.CSRFToken(r)}
+		if stockMsg != "" {
+			data["InvoiceMessages"] = []flash.Message{{Level: flash.Warn, Text: stockMsg}}
+		}
+		h.addInvoiceView(ctx, data, ownerID, view)
+		h.renderFragment(w, "invoice-bom.html", data)
 		return
 	}
-	if len(lines) == 0 {
-		utils.SetCookie(w, r, "xero_sync_msg", "No items found on invoice "+invoiceNumber, time.Now().Add(3*time.Minute))
+
+	// 5) Save the results for the home page, on whichever instance serves it
+	if err := h.saveInvoiceView(w, r, ownerID, view); err != nil {
+		log.Printf("getInvoice: save invoice view: %v", err)
+		h.flash.Add(w, r, flash.Error, "Could not keep the invoice results; please try again")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-
-	roots := make([]service.RootItem, 0, len(lines))
-	for _, li := range lines {
-		roots = append(roots, service.RootItem{
-			PartID:   li.ItemCode,
-			Name:     li.Name,
-			Quantity: li.Quantity,
-		})
+	if stockMsg != "" {
+		h.flash.Add(w, r, flash.Warn, stockMsg)
 	}
 
-	// 2) Resolve BOM (effective totals for all nodes)
-	bom, errMsg, err := service.ResolveInvoiceBOM(ctx, h.dbURL, roots, 12, client, found.AccessToken, found.TenantID)
-	if err != nil {
-		http.Error(w, "resolve bom failed: "+err.Error(), http.StatusInternalServerError)
-		return
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// toggleExpand returns the expanded item codes with toggle added, or removed when it
+// is already there. Blanks and repeats are dropped.
+func toggleExpand(expand []string, toggle string) []string {
+	toggle = strings.TrimSpace(toggle)
+	var out []string
+	seen := map[string]bool{}
+	for _, id := range expand {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id != toggle {
+			out = append(out, id)
+		}
 	}
-	if errMsg != "" {
-		utils.SetCookie(w, r, "xero_sync_msg", errMsg, time.Now().Add(3*time.Minute))
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
+	if toggle != "" && !seen[toggle] {
+		out = append(out, toggle)
 	}
+	return out
+}
+
+// poReply is where planning and raising purchase orders report back: the browser
+// (httpReply) or, for a scheduled release, the log (logReply).
+type poReply interface {
+	// Flash reports a message; an Error-level one means the run stopped.
+	Flash(level flash.Level, msg string, links ...flash.Link)
+	// Done ends the reply, sending the browser to to.
+	Done(to string)
+	// Fail reports an unexpected failure, as an error page with status.
+	Fail(status int, msg string, err error)
+	// Failed reports whether Fail or an Error-level Flash was called.
+	Failed() bool
+}
 
-	// 3) Build PerAssemblyBOM (children quantities divided by root qty; root keeps its invoice qty)
-	perAssy := service.BuildPerAssemblyBOM(bom, roots)
+// httpReply answers a request with flash messages and a redirect, or an error page.
+type httpReply struct {
+	h      *Handler
+	w      http.ResponseWriter
+	r      *http.Request
+	failed bool
+}
 
-	// 4) Aggregate leaf totals across all roots (sum effective totals only for leaves)
-	leafTotals := service.AggregateLeafTotals(perAssy)
+func (h *Handler) reply(w http.ResponseWriter, r *http.Request) *httpReply {
+	return &httpReply{h: h, w: w, r: r}
+}
 
-	// 5) Store cookies for home page rendering
-	setJSONCookie := func(name string, v any) {
-		b, _ := json.Marshal(v)
-		utils.SetCookie(w, r, name, base64.StdEncoding.EncodeToString(b), time.Now().Add(5*time.Minute))
-	}
-	setJSONCookie("xero_perassy_bom", perAssy)
-	setJSONCookie("xero_leaf_totals", leafTotals)
-	utils.SetCookie(w, r, "xero_invoice_number", invoiceNumber, time.Now().Add(5*time.Minute))
+func (rep *httpReply) Flash(level flash.Level, msg string, links ...flash.Link) {
+	rep.failed = rep.failed || level == flash.Error
+	rep.h.flash.Add(rep.w, rep.r, level, msg, links...)
+}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+func (rep *httpReply) Done(to string) { http.Redirect(rep.w, rep.r, to, http.StatusSeeOther) }
+
+func (rep *httpReply) Fail(status int, msg string, err error) {
+	rep.failed = true
+	rep.h.renderError(rep.w, rep.r, status, msg, err)
 }
 
+func (rep *httpReply) Failed() bool { return rep.failed }
+
 // createPurchaseOrdersHandler reads unordered shopping_list rows, groups by contact (AccountNumber),
 // creates a purchase order per contact via pkg/xero, marks rows ordered, and sets a message.
+// A run worth more than the owner's approval threshold is stored for an approver
+// instead (see approvePOsHandler), with nothing sent to Xero. While the owner holds
+// orders for a release nothing is raised until the release is due; "Release now"
+// (releaseOrdersHandler) raises them early.
 func (h *Handler) createPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	h.createPurchaseOrders(w, r, false)
+}
+
+// createPurchaseOrders raises the purchase orders for createPurchaseOrdersHandler, or
+// for releaseOrdersHandler when release is set, ignoring the hold.
+func (h *Handler) createPurchaseOrders(w http.ResponseWriter, r *http.Request, release bool) {
 	if h.auth != nil {
 		r = mid.EnsureUserIDInContext(r, h.auth)
 	}
-	ownerID, _ := r.Context().Value(mid.CtxUserID).(string)
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
 	if ownerID == "" {
 		http.Error(w, "owner id missing", http.StatusUnauthorized)
 		return
 	}
-	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	userID, _ := r.Context().Value(mid.CtxUserID).(string)
+	// inside the server's 30s write timeout, so a slow Xero still gets its flash
+	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
 	defer cancel()
+	ctx, finish := h.trackProgress(ctx, r, ownerID)
+	defer finish()
 
-	// load Xero connection for owner
-	conns, err := service.GetConnectionsForOwner(ctx, h.dbURL, ownerID)
+	held, due, err := h.orderHold(ctx, ownerID)
 	if err != nil {
-		http.Error(w, "failed to load connections: "+err.Error(), http.StatusInternalServerError)
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load settings", err)
 		return
 	}
-	if len(conns) == 0 {
-		http.Error(w, "no xero connection found for owner", http.StatusNotFound)
+	if !due && !release {
+		h.flash.Add(w, r, flash.Info, "Orders are held until "+h.formatRelease(ctx, ownerID, held)+
+			" so each supplier gets one purchase order; nothing was sent to Xero. Use Release now to order early.")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-	found := &conns[0]
 
-	// refresh token if near expiry
-	now := time.Now().UTC()
-	if found.ExpiresAt <= now.Unix()+60 {
-		clientID := os.Getenv("XERO_CLIENT_ID")
-		clientSecret := os.Getenv("XERO_CLIENT_SECRET")
-		tr, err := xero.RefreshToken(ctx, h.client, clientID, clientSecret, found.RefreshToken)
-		if err != nil {
-			http.Error(w, "refresh token failed: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if err := service.UpsertConnection(ctx, h.dbURL, ownerID, found.TenantID, tr.AccessToken, tr.RefreshToken, tr.ExpiresIn); err != nil {
-			http.Error(w, "failed to persist refreshed token: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		found.AccessToken = tr.AccessToken
-		secs := tr.ExpiresIn
-		if secs == 0 {
-			secs = 3600
-		}
-		found.ExpiresAt = time.Now().Unix() + secs
+	// credentials for the owner's Xero connection (refreshed if near expiry)
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+	rep := h.reply(w, r)
+	h.raisePurchaseOrders(ctx, rep, r, ownerID, userID, creds)
+	if held.HoldOrders && !rep.Failed() {
+		h.recordRelease(ctx, ownerID, userID)
 	}
+}
+
+// raisePurchaseOrders plans the purchase orders for the owner's unordered shopping
+// list and sends them to Xero, or holds them for an approver when they are over the
+// approval threshold. form is the preview form the run came from, nil for none.
+func (h *Handler) raisePurchaseOrders(ctx context.Context, rep poReply, form *http.Request, ownerID, userID string, creds service.XeroCredentials) {
+	batch, settings, ok := h.planPurchaseOrders(ctx, rep, form, ownerID, creds)
+	if !ok {
+		return
+	}
+	if settings.NeedsApproval(batch) {
+		h.requestPOApproval(ctx, rep, ownerID, userID, batch, settings)
+		return
+	}
+	h.sendPurchaseOrders(ctx, rep, ownerID, creds, batch)
+}
+
+// planPurchaseOrders works out the purchase orders for the owner's unordered shopping
+// list rows without writing anything to Xero: one per contact (AccountNumber), with
+// Xero's names and purchase prices, the suppliers, account codes and PO details (as
+// edited on the preview form when it posts po_details=1), the contact's ContactID
+// and the currency it invoices in. When it cannot, it tells rep why and reports false.
+func (h *Handler) planPurchaseOrders(ctx context.Context, rep poReply, form *http.Request, ownerID string, creds service.XeroCredentials) (service.POBatch, service.OwnerSettings, bool) {
+	fail := func(kind flash.Level, msg, to string) (service.POBatch, service.OwnerSettings, bool) {
+		rep.Flash(kind, msg)
+		rep.Done(to)
+		return service.POBatch{}, service.OwnerSettings{}, false
+	}
+	edited := form != nil && form.PostFormValue("po_details") == "1"
 
 	// 1) load unordered shopping list rows
-	rows, err := service.GetUnorderedShoppingRows(ctx, h.dbURL)
+	rows, err := h.orders.GetUnorderedShoppingRows(ctx, ownerID)
 	if err != nil {
-		http.Error(w, "failed to read shopping list: "+err.Error(), http.StatusInternalServerError)
-		return
+		rep.Fail(http.StatusInternalServerError, "failed to read shopping list", err)
+		return service.POBatch{}, service.OwnerSettings{}, false
 	}
 	if len(rows) == 0 {
-		utils.SetCookie(w, r, "xero_sync_msg", "No unordered shopping list items found.", time.Now().Add(5*time.Minute))
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
+		return fail(flash.Info, "No unordered shopping list items found.", "/")
 	}
 
-	// 2) group rows by contact (and aggregate quantities).
-	grouped, err := service.GroupShoppingItemsByContact(ctx, h.dbURL, rows)
+	// 2) group rows by contact (and aggregate quantities), with the suppliers chosen
+	// on the preview screen
+	var choices map[string]string
+	if edited {
+		choices = supplierChoicesFromForm(form.PostForm)
+	}
+	grouped, err := h.orders.GroupShoppingItemsByContact(ctx, ownerID, rows, choices)
+	if errors.Is(err, service.ErrSupplierChoice) {
+		return fail(flash.Error, "Purchase orders not created: "+err.Error(), "/purchase-orders/preview")
+	}
 	if err != nil {
-		utils.SetCookie(w, r, "xero_sync_msg", "Failed to group items by contact: "+err.Error(), time.Now().Add(5*time.Minute))
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
+		h.postBOMUnresolved(ownerID, err.Error())
+		return fail(flash.Error, "Failed to group items by contact: "+err.Error(), "/")
 	}
 
-	// 3) create POs per contact and collect list IDs to mark ordered
-	var allListIDs []int
-	created := 0
+	// 3) one batched lookup for line names and Xero purchase prices
+	xeroItems, err := h.xc.GetItemsByCodes(ctx, creds.AccessToken, creds.TenantID, purchaseItemCodes(grouped))
+	if err != nil {
+		return fail(flash.Error, "Item lookup failed: "+errorText("Xero", err), "/")
+	}
+	prices := xeroPurchasePrices(xeroItems)
+	// suppliers with a foreign default currency are ordered from in it
+	cur, err := h.supplierCurrencies(ctx, creds, grouped)
+	if err != nil {
+		return fail(flash.Error, "Supplier currency lookup failed: "+errorText("Xero", err), "/")
+	}
 
-	// caches to reduce Xero calls
-	contactIDCache := make(map[string]string) // AccountNumber -> ContactID
-	nameCache := make(map[string]string)      // ItemCode -> Name
+	// status, theme and sent flag from the owner's settings; delivery address,
+	// attention-to and reference as edited on the preview screen, else the saved defaults
+	settings, err := h.settings.GetOwnerSettings(ctx, ownerID)
+	if err != nil {
+		return fail(flash.Error, "Failed to load settings: "+err.Error(), "/")
+	}
+	if edited {
+		settings.POSettings = poSettingsFromForm(form)
+	}
+	// per-item default account codes, overridden by those edited on the preview screen
+	accountCodes, err := h.orders.GetItemAccountCodes(ctx, purchaseItemCodes(grouped))
+	if err != nil {
+		return fail(flash.Error, "Failed to load account codes: "+err.Error(), "/")
+	}
+	if edited {
+		codes, err := accountCodesFromForm(form)
+		if err != nil {
+			return fail(flash.Error, "Purchase orders not created: "+err.Error(), "/purchase-orders/preview")
+		}
+		maps.Copy(accountCodes, codes)
+	}
 
-	for accountNumber, items := range grouped { // accountNumber is Xero Contact.AccountNumber
-		// resolve ContactID once per accountNumber
-		contactID := contactIDCache[accountNumber]
+	// 4) one PO per contact, and the versions of the rows to mark ordered
+	batch := service.POBatch{TenantID: creds.TenantID, TrackingCategory: settings.TrackingCategory, Versions: map[int]int{}}
+	read := service.ShoppingVersions(rows)
+	for _, accountNumber := range slices.Sorted(maps.Keys(grouped)) { // accountNumber is Xero Contact.AccountNumber
+		items := grouped[accountNumber]
+		contactID, err := service.ContactIDByAccountNumber(ctx, h.lookups, h.xc, creds.AccessToken, creds.TenantID, accountNumber)
+		if err != nil {
+			return fail(flash.Error, "Contact lookup failed for "+accountNumber+": "+errorText("Xero", err), "/")
+		}
 		if contactID == "" {
-			var err error
-			contactID, err = xero.GetContactIDByAccountNumber(ctx, h.client, found.AccessToken, found.TenantID, accountNumber)
-			if err != nil {
-				utils.SetCookie(w, r, "xero_sync_msg", "Contact lookup failed for "+accountNumber+": "+err.Error(), time.Now().Add(5*time.Minute))
-				http.Redirect(w, r, "/", http.StatusSeeOther)
-				return
-			}
-			if contactID == "" {
-				utils.SetCookie(w, r, "xero_sync_msg", "No ContactID found for "+accountNumber+" in Xero", time.Now().Add(5*time.Minute))
-				http.Redirect(w, r, "/", http.StatusSeeOther)
-				return
-			}
-			contactIDCache[accountNumber] = contactID
+			return fail(flash.Error, "No ContactID found for "+accountNumber+" in Xero", "/")
 		}
 
-		var poItems []xero.POItem
+		po := service.PlannedPO{
+			ContactAccount: accountNumber,
+			ContactID:      contactID,
+			Details:        settings.PODetails(service.SourceInvoices(rows, items)),
+		}
+		if po.Details.CurrencyCode = cur.Currency(accountNumber); po.Details.CurrencyCode != "" {
+			po.CurrencyRate = cur.Rate(accountNumber)
+		}
 		for _, it := range items {
 			code := it.ItemID // ItemID in DB = Xero Item Code
 			desc := code
-			if nm, ok := nameCache[code]; ok && nm != "" {
+			if nm := xeroItems[code].Name; nm != "" {
 				desc = nm
-			} else {
-				if nm, ok, err := xero.GetItemNameByCode(ctx, h.client, found.AccessToken, found.TenantID, code); err == nil && ok && nm != "" {
-					nameCache[code] = nm
-					desc = nm
-				}
 			}
-
-			poItems = append(poItems, xero.POItem{
-				ItemCode:    code,
-				Quantity:    it.Quantity,
-				Description: desc, // use Name where possible
+			price, _, err := cur.UnitPrice(it, accountNumber, prices)
+			if err != nil {
+				return fail(flash.Error, "Purchase orders not created: "+code+" from "+accountNumber+" only has a Xero purchase price, and there is "+
+					err.Error()+". Give it a supplier price, or enter a bill in that currency in Xero first.", "/purchase-orders/preview")
+			}
+			po.Lines = append(po.Lines, service.PlannedLine{
+				POItem: xero.POItem{
+					ItemCode:    code,
+					Quantity:    it.Terms.OrderQuantity(it.Quantity), // whole packs, as in the preview
+					Description: desc,                                // use Name where possible
+					UnitAmount:  price,
+					AccountCode: accountCodes[code],
+				},
+				SourceInvoice: service.ItemSourceInvoice(rows, it),
 			})
-			allListIDs = append(allListIDs, it.ListIDs...)
+			for _, id := range it.ListIDs {
+				batch.Versions[id] = read[id]
+				po.ListIDs = append(po.ListIDs, id)
+			}
 		}
+		batch.POs = append(batch.POs, po)
+	}
+	return batch, settings, true
+}
 
-		if _, err := xero.CreatePurchaseOrder(ctx, h.client, found.AccessToken, found.TenantID, contactID, poItems); err != nil {
-			utils.SetCookie(w, r, "xero_sync_msg", "Failed to create PO for contact "+accountNumber+": "+err.Error(), time.Now().Add(5*time.Minute))
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-			return
-		}
-		created++
+// sendPurchaseOrders creates batch's purchase orders in Xero, records them and marks
+// the rows they cover ordered, then tells rep what happened. When Xero refuses one,
+// the purchase orders already created are still recorded and their rows marked, so
+// ordering again does not raise them twice.
+func (h *Handler) sendPurchaseOrders(ctx context.Context, rep poReply, ownerID string, creds service.XeroCredentials, batch service.POBatch) {
+	tracking, err := newPOTracking(ctx, h.xc, creds, batch.TrackingCategory)
+	if err != nil {
+		rep.Flash(flash.Error, "Tracking category lookup failed: "+errorText("Xero", err))
+		rep.Done("/")
+		return
+	}
+	if batch.TrackingCategory != "" && tracking == nil {
+		rep.Flash(flash.Warn, "Tracking category "+batch.TrackingCategory+" not found in Xero; purchase order lines are untracked.")
 	}
 
-	// 4) mark rows ordered
-	if len(allListIDs) > 0 {
-		if err := service.MarkShoppingListOrdered(ctx, h.dbURL, allListIDs); err != nil {
-			http.Error(w, "failed to mark shopping list items ordered: "+err.Error(), http.StatusInternalServerError)
+	// create POs per contact
+	var created []createdPO
+	var records []service.PurchaseOrderRecord
+	sent := map[int]int{} // versions of the rows covered by the POs created so far
+	// post what was raised even when a later supplier fails
+	defer func() { h.postPOsCreated(ownerID, created) }()
+
+	for i, po := range batch.POs {
+		service.ReportProgress(ctx, len(created), len(batch.POs), fmt.Sprintf("Creating PO %d of %d (%s)", len(created)+1, len(batch.POs), po.ContactAccount))
+		var poItems []xero.POItem
+		var poLines []service.PurchaseOrderLine
+		for _, l := range po.Lines {
+			item := l.POItem
+			item.Tracking = tracking.line(ctx, l.SourceInvoice)
+			poItems = append(poItems, item)
+			poLines = append(poLines, service.PurchaseOrderLine{ItemID: item.ItemCode, Quantity: item.Quantity, UnitAmount: item.UnitAmount})
+		}
+
+		poID, err := h.xc.CreatePurchaseOrder(ctx, creds.AccessToken, creds.TenantID, po.ContactID, poItems, po.Details)
+		if err != nil {
+			msg := "Failed to create PO for contact " + po.ContactAccount + ": " + errorText("Xero", err)
+			if len(created) > 0 {
+				service.ReportProgress(ctx, len(created), len(batch.POs), "Recording purchase orders")
+				marked, ok := h.recordPurchaseOrders(ctx, rep, ownerID, len(created), records, sent)
+				if !ok {
+					return
+				}
+				msg = fmt.Sprintf("Created purchase order(s) for %s, %d shopping list rows marked ordered. %s",
+					createdAccounts(created), marked, msg)
+			}
+			if rest := batch.POs[i+1:]; len(rest) > 0 {
+				var accounts []string
+				for _, p := range rest {
+					accounts = append(accounts, p.ContactAccount)
+				}
+				msg = strings.TrimSuffix(msg, ".") + ". Not sent: " + strings.Join(accounts, ", ")
+			}
+			rep.Flash(flash.Error, msg, h.poLinks(ctx, creds, created)...)
+			rep.Done("/")
 			return
 		}
+		created = append(created, createdPO{AccountNumber: po.ContactAccount, Lines: len(poItems), XeroPOID: poID})
+		for _, id := range po.ListIDs {
+			sent[id] = batch.Versions[id]
+		}
+
+		// recorded locally for reconciliation below, with the rows it covers
+		if poID != "" {
+			records = append(records, service.PurchaseOrderRecord{
+				OwnerID:        ownerID,
+				TenantID:       creds.TenantID,
+				XeroPOID:       poID,
+				ContactAccount: po.ContactAccount,
+				ContactID:      po.ContactID,
+				Status:         po.Details.Status,
+				CurrencyCode:   po.Details.CurrencyCode,
+				CurrencyRate:   po.CurrencyRate,
+				Lines:          poLines,
+			})
+		} else {
+			log.Printf("createPurchaseOrders: no PurchaseOrderID returned for contact %s; not recorded", po.ContactAccount)
+		}
 	}
-	msg := fmt.Sprintf("Created %d purchase order(s), %d shopping list rows marked ordered", created, len(allListIDs))
-	utils.SetCookie(w, r, "xero_sync_msg", msg, time.Now().Add(5*time.Minute))
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+
+	service.ReportProgress(ctx, len(created), len(batch.POs), "Recording purchase orders")
+	marked, ok := h.recordPurchaseOrders(ctx, rep, ownerID, len(created), records, batch.Versions)
+	if !ok {
+		return
+	}
+	if tracking != nil && tracking.failed > 0 {
+		rep.Flash(flash.Warn, fmt.Sprintf("%d purchase order line(s) could not be assigned to tracking category %s.", tracking.failed, tracking.category.Name))
+	}
+	msg := fmt.Sprintf("Created %d purchase order(s), %d shopping list rows marked ordered", len(created), marked)
+	rep.Flash(flash.Info, msg, h.poLinks(ctx, creds, created)...)
+	rep.Done("/")
+}
+
+// recordPurchaseOrders records the POs and marks the rows in versions ordered in one
+// transaction, leaving rows someone changed while the POs were raised unmarked, and
+// reports how many rows were marked. When it cannot, it tells rep and reports false.
+func (h *Handler) recordPurchaseOrders(ctx context.Context, rep poReply, ownerID string, created int, records []service.PurchaseOrderRecord, versions map[int]int) (int, bool) {
+	marked := len(versions)
+	if len(versions) == 0 && len(records) == 0 {
+		return 0, true
+	}
+	err := h.orders.RecordPurchaseOrders(ctx, ownerID, records, versions)
+	var conflict *service.ShoppingConflictError
+	switch {
+	case errors.As(err, &conflict):
+		marked -= len(conflict.ListIDs)
+		rep.Flash(flash.Warn, "Not marked ordered: "+conflict.Error()+
+			". Check those rows against the purchase orders just created before ordering them again.")
+	case err != nil:
+		log.Printf("createPurchaseOrders: record %d PO(s) failed: %v", len(records), err)
+		rep.Fail(http.StatusInternalServerError, fmt.Sprintf("created %d purchase order(s) in Xero but failed to record them; no shopping list rows were marked ordered, so check Xero before ordering again", created), err)
+		return 0, false
+	}
+	return marked, true
 }
 
-// // dumpContactsHandler fetches all Contacts from Xero (pages) and writes the combined
-// // JSON to contact.json in the repo root (development helper).
-// func (h *Handler) dumpContactsHandler(w http.ResponseWriter, r *http.Request) {
-// 	if h.auth != nil {
-// 		r = mid.EnsureUserIDInContext(r, h.auth)
-// 	}
-// 	ownerID, _ := r.Context().Value(mid.CtxUserID).(string)
-// 	if ownerID == "" {
-// 		http.Error(w, "owner id missing", http.StatusUnauthorized)
-// 		return
-// 	}
-
-// 	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
-// 	defer cancel()
-
-// 	// load Xero connection for the owner
-// 	conns, err := service.GetConnectionsForOwner(ctx, h.dbURL, ownerID)
-// 	if err != nil {
-// 		http.Error(w, "failed to load connections: "+err.Error(), http.StatusInternalServerError)
-// 		return
-// 	}
-// 	if len(conns) == 0 {
-// 		http.Error(w, "no xero connection found for owner", http.StatusNotFound)
-// 		return
-// 	}
-// 	found := &conns[0]
-
-// 	// refresh token if near expiry
-// 	now := time.Now().UTC()
-// 	if found.ExpiresAt <= now.Unix()+60 {
-// 		clientID := os.Getenv("XERO_CLIENT_ID")
-// 		clientSecret := os.Getenv("XERO_CLIENT_SECRET")
-// 		tr, err := xero.RefreshToken(ctx, h.client, clientID, clientSecret, found.RefreshToken)
-// 		if err != nil {
-// 			http.Error(w, "refresh token failed: "+err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-// 		if err := service.UpsertConnection(ctx, h.dbURL, ownerID, found.TenantID, tr.AccessToken, tr.RefreshToken, tr.ExpiresIn); err != nil {
-// 			http.Error(w, "failed to persist refreshed token: "+err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-// 		found.AccessToken = tr.AccessToken
-// 		secs := tr.ExpiresIn
-// 		if secs == 0 {
-// 			secs = 3600
-// 		}
-// 		found.ExpiresAt = time.Now().Unix() + secs
-// 	}
-
-// 	// fetch contacts page-by-page and aggregate
-// 	allContacts := make([]interface{}, 0)
-// 	client := h.client
-// 	if client == nil {
-// 		client = http.DefaultClient
-// 	}
-
-// 	for page := 1; page <= 50; page++ { // safety cap at 50 pages
-// 		u := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Contacts?page=%d", page)
-// 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
-// 		if err != nil {
-// 			http.Error(w, "failed to build request: "+err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-// 		req.Header.Set("Authorization", "Bearer "+found.AccessToken)
-// 		req.Header.Set("Xero-tenant-id", found.TenantID)
-// 		req.Header.Set("Accept", "application/json")
-
-// 		resp, err := client.Do(req)
-// 		if err != nil {
-// 			http.Error(w, "contacts fetch failed: "+err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-// 		if resp.Body != nil {
-// 			defer resp.Body.Close()
-// 		}
-// 		if resp.StatusCode >= 300 {
-// 			// read body for debugging
-// 			var b []byte
-// 			_ = json.NewDecoder(resp.Body).Decode(&b) // best-effort
-// 			http.Error(w, fmt.Sprintf("contacts fetch failed: status=%d", resp.StatusCode), http.StatusInternalServerError)
-// 			return
-// 		}
-
-// 		var pageShape struct {
-// 			Contacts []interface{} `json:"Contacts"`
-// 		}
-// 		if err := json.NewDecoder(resp.Body).Decode(&pageShape); err != nil {
-// 			http.Error(w, "failed to decode contacts response: "+err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-// 		if len(pageShape.Contacts) == 0 {
-// 			break
-// 		}
-// 		allContacts = append(allContacts, pageShape.Contacts...)
-// 		// continue to next page
-// 	}
-
-// 	out := map[string]interface{}{
-// 		"Contacts":  allContacts,
-// 		"FetchedAt": time.Now().UTC().Format(time.RFC3339),
-// 	}
-
-// 	b, err := json.MarshalIndent(out, "", "  ")
-// 	if err != nil {
-// 		http.Error(w, "failed to marshal contacts: "+err.Error(), http.StatusInternalServerError)
-// 		return
-// 	}
-
-// 	if err := os.WriteFile("contact.json", b, 0644); err != nil {
-// 		http.Error(w, "failed to write contact.json: "+err.Error(), http.StatusInternalServerError)
-// 		return
-// 	}
-
-// 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-// 	_, _ = w.Write([]byte(fmt.Sprintf("wrote %d contacts to contact.json\n", len(allContacts))))
-// }
-
-// // dumpItemsHandler fetches all Items from Xero (pages) and writes the combined
-// // JSON to parts.json (development helper).
-// func (h *Handler) dumpItemsHandler(w http.ResponseWriter, r *http.Request) {
-// 	if h.auth != nil {
-// 		r = mid.EnsureUserIDInContext(r, h.auth)
-// 	}
-// 	ownerID, _ := r.Context().Value(mid.CtxUserID).(string)
-// 	if ownerID == "" {
-// 		http.Error(w, "owner id missing", http.StatusUnauthorized)
-// 		return
-// 	}
-
-// 	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
-// 	defer cancel()
-
-// 	// load Xero connection for the owner
-// 	conns, err := service.GetConnectionsForOwner(ctx, h.dbURL, ownerID)
-// 	if err != nil {
-// 		http.Error(w, "failed to load connections: "+err.Error(), http.StatusInternalServerError)
-// 		return
-// 	}
-// 	if len(conns) == 0 {
-// 		http.Error(w, "no xero connection found for owner", http.StatusNotFound)
-// 		return
-// 	}
-// 	found := &conns[0]
-
-// 	// refresh token if near expiry
-// 	now := time.Now().UTC()
-// 	if found.ExpiresAt <= now.Unix()+60 {
-// 		clientID := os.Getenv("XERO_CLIENT_ID")
-// 		clientSecret := os.Getenv("XERO_CLIENT_SECRET")
-// 		tr, err := xero.RefreshToken(ctx, h.client, clientID, clientSecret, found.RefreshToken)
-// 		if err != nil {
-// 			http.Error(w, "refresh token failed: "+err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-// 		if err := service.UpsertConnection(ctx, h.dbURL, ownerID, found.TenantID, tr.AccessToken, tr.RefreshToken, tr.ExpiresIn); err != nil {
-// 			http.Error(w, "failed to persist refreshed token: "+err.Error(), http.StatusInternalServerError)
-// 			return
-// 		}
-// 		found.AccessToken = tr.AccessToken
-// 		secs := tr.ExpiresIn
-// 		if secs == 0 {
-// 			secs = 3600
-// 		}
-// 		found.ExpiresAt = time.Now().Unix() + secs
-// 	}
-
-// 	// fetch items page-by-page and aggregate with rate-limit retry
-// 	allItems := make([]interface{}, 0)
-// 	seen := make(map[string]struct{}) // track unique ItemID/Code
-
-// 	// ensure http client is set (fixes "undeclared client")
-// 	client := h.client
-// 	if client == nil {
-// 		client = http.DefaultClient
-// 	}
-
-// 	const maxPages = 1
-// 	const maxAttempts = 5
-
-// 	for page := 1; page <= maxPages; page++ {
-// 		var pageShape struct {
-// 			Items []interface{} `json:"Items"`
-// 		}
-
-// 		// attempt with retries on 429
-// 		attempt := 0
-// 		for {
-// 			attempt++
-// 			u := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Items?page=%d", page)
-// 			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
-// 			if err != nil {
-// 				http.Error(w, "failed to build request: "+err.Error(), http.StatusInternalServerError)
-// 				return
-// 			}
-// 			req.Header.Set("Authorization", "Bearer "+found.AccessToken)
-// 			req.Header.Set("Xero-tenant-id", found.TenantID)
-// 			req.Header.Set("Accept", "application/json")
-
-// 			resp, err := client.Do(req)
-// 			if err != nil {
-// 				// network error, retry a few times
-// 				if attempt >= maxAttempts {
-// 					http.Error(w, "items fetch failed: "+err.Error(), http.StatusInternalServerError)
-// 					return
-// 				}
-// 				// small backoff then retry
-// 				backoff := time.Duration(1<<attempt) * time.Second
-// 				select {
-// 				case <-time.After(backoff):
-// 					continue
-// 				case <-ctx.Done():
-// 					http.Error(w, "request cancelled", http.StatusRequestTimeout)
-// 					return
-// 				}
-// 			}
-
-// 			bodyBytes, _ := io.ReadAll(resp.Body)
-// 			resp.Body.Close()
-
-// 			if resp.StatusCode == http.StatusTooManyRequests {
-// 				// Xero rate limited. Respect Retry-After if provided.
-// 				retryAfter := 0
-// 				if ra := resp.Header.Get("Retry-After"); ra != "" {
-// 					if secs, perr := strconv.Atoi(ra); perr == nil {
-// 						retryAfter = secs
-// 					}
-// 				}
-// 				if retryAfter <= 0 {
-// 					// exponential backoff with small jitter
-// 					retryAfter = int((1 << attempt))
-// 				}
-// 				if attempt >= maxAttempts {
-// 					http.Error(w, fmt.Sprintf("items fetch failed: status=429 body=%s", string(bodyBytes)), http.StatusTooManyRequests)
-// 					return
-// 				}
-// 				// wait respecting context
-// 				wait := time.Duration(retryAfter) * time.Second
-// 				select {
-// 				case <-time.After(wait):
-// 					continue
-// 				case <-ctx.Done():
-// 					http.Error(w, "request cancelled", http.StatusRequestTimeout)
-// 					return
-// 				}
-// 			}
-
-// 			if resp.StatusCode >= 300 {
-// 				// surface body for debugging
-// 				http.Error(w, fmt.Sprintf("items fetch failed: status=%d body=%s", resp.StatusCode, string(bodyBytes)), http.StatusInternalServerError)
-// 				return
-// 			}
-
-// 			// decode page
-// 			if err := json.Unmarshal(bodyBytes, &pageShape); err != nil {
-// 				http.Error(w, "failed to decode items response: "+err.Error(), http.StatusInternalServerError)
-// 				return
-// 			}
-// 			break
-// 		} // retry loop
-
-// 		if len(pageShape.Items) == 0 {
-// 			break
-// 		}
-
-// 		// dedupe items by ItemID or Code before appending
-// 		for _, it := range pageShape.Items {
-// 			m, ok := it.(map[string]interface{})
-// 			if !ok {
-// 				// unknown shape, append to result
-// 				allItems = append(allItems, it)
-// 				continue
-// 			}
-
-// 			var key string
-// 			if v, ok := m["ItemID"].(string); ok && v != "" {
-// 				key = "id:" + v
-// 			} else if v, ok := m["Code"].(string); ok && v != "" {
-// 				key = "code:" + v
-// 			} else {
-// 				// fallback: try Name (less reliable)
-// 				if v, ok := m["Name"].(string); ok && v != "" {
-// 					key = "name:" + v
-// 				}
-// 			}
-
-// 			if key == "" {
-// 				allItems = append(allItems, it)
-// 				continue
-// 			}
-// 			if _, found := seen[key]; found {
-// 				continue
-// 			}
-// 			seen[key] = struct{}{}
-// 			allItems = append(allItems, it)
-// 		}
-// 	}
-
-// 	out := map[string]interface{}{
-// 		"Items":     allItems,
-// 		"FetchedAt": time.Now().UTC().Format(time.RFC3339),
-// 	}
-
-// 	b, err := json.MarshalIndent(out, "", "  ")
-// 	if err != nil {
-// 		http.Error(w, "failed to marshal items: "+err.Error(), http.StatusInternalServerError)
-// 		return
-// 	}
-
-// 	if err := os.WriteFile("items.json", b, 0644); err != nil {
-// 		http.Error(w, "failed to write items.json: "+err.Error(), http.StatusInternalServerError)
-// 		return
-// 	}
-
-// 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-// 	_, _ = w.Write([]byte(fmt.Sprintf("wrote %d items to items.json\n", len(allItems))))
-// }
+// createdAccounts lists the contacts of created, for messages.
+func createdAccounts(created []createdPO) string {
+	accounts := make([]string, len(created))
+	for i, c := range created {
+		accounts[i] = c.AccountNumber
+	}
+	return strings.Join(accounts, ", ")
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// reconnectMessage explains why the Xero connection disappeared.
+const reconnectMessage = "Xero access was revoked or has expired, so the connection was removed. Use Connect to Xero to grant access again."
+
+// redirectToReconnect sends the user home, where Connect to Xero starts the consent
+// flow again, with a banner explaining why, when err says Xero consent was revoked.
+// JSON clients get a 403 instead. It reports whether it handled err.
+func (h *Handler) redirectToReconnect(w http.ResponseWriter, r *http.Request, err error) bool {
+	if !errors.Is(err, service.ErrConsentRevoked) {
+		return false
+	}
+	if ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string); ownerID != "" {
+		h.postConnectionBroken(ownerID)
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		h.renderError(w, r, http.StatusForbidden, reconnectMessage, err)
+		return true
+	}
+	h.flash.Add(w, r, flash.Warn, reconnectMessage)
+	if isHTMXRequest(r) {
+		// htmx follows HX-Redirect with a full page load instead of swapping the body
+		w.Header().Set("HX-Redirect", "/")
+		w.WriteHeader(http.StatusOK)
+		return true
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return true
+}
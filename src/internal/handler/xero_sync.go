@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	mid "github.com/hwalton/xero-invoice-orderer/internal/middleware"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// syncSuppliersHandler pushes the suppliers table to Xero Contacts. The optional
+// form/query value "since" (YYYY-MM-DD) only syncs suppliers changed on or after it.
+// JSON clients get the counts; form posts redirect home with a message.
+func (h *Handler) syncSuppliersHandler(w http.ResponseWriter, r *http.Request) {
+	if h.auth != nil {
+		r = mid.EnsureUserIDInContext(r, h.auth)
+	}
+	ownerID, _ := r.Context().Value(mid.CtxWorkspaceID).(string)
+	if ownerID == "" {
+		http.Error(w, "owner id missing", http.StatusUnauthorized)
+		return
+	}
+	wantsJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+
+	var since time.Time
+	if v := strings.TrimSpace(r.FormValue("since")); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "invalid since date (want YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	creds, err := h.tokens.CredentialsForOwner(ctx, ownerID)
+	if err != nil {
+		h.xeroError(w, r, "", err)
+		return
+	}
+
+	suppliers, err := service.LoadSuppliers(ctx, h.dbURL)
+	if err != nil {
+		h.renderError(w, r, http.StatusInternalServerError, "failed to load suppliers", err)
+		return
+	}
+	res, err := h.xc.SyncSuppliersToXero(ctx, creds.AccessToken, creds.TenantID, suppliers, since)
+	if err != nil {
+		if wantsJSON {
+			if !h.renderUnavailable(w, r, "Xero", err) {
+				h.renderError(w, r, http.StatusBadGateway, "supplier sync failed", err)
+			}
+			return
+		}
+		h.flash.Add(w, r, flash.Error, "Supplier sync failed: "+errorText("Xero", err))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if wantsJSON {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := fmt.Sprintf("Suppliers synced to Xero: %d created, %d updated, %d unchanged", res.Created, res.Updated, res.Unchanged)
+	if res.Skipped > 0 {
+		msg += fmt.Sprintf(", %d not modified since %s", res.Skipped, since.Format("2006-01-02"))
+	}
+	h.flash.Add(w, r, flash.Info, msg)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,774 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/flash"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xerotest"
+)
+
+func TestXeroConnect(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+
+	rec := hs.do(http.MethodGet, "/xero/connect", nil)
+	expectStatus(t, rec, http.StatusFound)
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || loc.Scheme+"://"+loc.Host != hs.handler.xc.LoginURL || loc.Path != "/identity/connect/authorize" {
+		t.Fatalf("unexpected redirect %q", rec.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("client_id") != "client-id" || q.Get("redirect_uri") != "http://localhost/xero/callback" {
+		t.Fatalf("unexpected auth params: %v", q)
+	}
+	st := hs.store.states[q.Get("state")]
+	if st.owner != testOwnerID {
+		t.Fatalf("state %q stored for %q, want %q", q.Get("state"), st.owner, testOwnerID)
+	}
+	var nonce *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oauthNonceCookie {
+			nonce = c
+		}
+	}
+	if nonce == nil || nonce.Value == "" || nonce.Value != st.nonce || !nonce.HttpOnly {
+		t.Fatalf("nonce cookie %+v does not match stored nonce %q", nonce, st.nonce)
+	}
+	if nonce.Value == q.Get("state") {
+		t.Fatal("nonce must differ from the state sent to Xero")
+	}
+}
+
+func TestXeroConnect_RequiresLogin(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+
+	rec := hs.do(http.MethodGet, "/xero/connect", nil, anonymous)
+	expectRedirect(t, rec, "/login")
+	if len(hs.store.states) != 0 {
+		t.Fatal("no state should be stored for anonymous requests")
+	}
+}
+
+func TestXeroCallback(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.states["st-1"] = oauthState{owner: testOwnerID, nonce: testNonce}
+
+	hs.xero.HandleFunc("POST /connect/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "client-id" || secret != "client-secret" {
+			http.Error(w, "bad client", http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("code") != "code-1" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, `{"access_token":"at-1","refresh_token":"rt-1","expires_in":1800}`)
+	})
+	hs.xero.HandleFunc("GET /connections", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at-1" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, `[{"tenantId":"tenant-1","tenantName":"Acme Ltd"},{"tenantId":"tenant-2","tenantName":"Acme US"}]`)
+	})
+
+	rec := hs.do(http.MethodGet, "/xero/callback?code=code-1&state=st-1", nil, withNonce)
+	expectRedirect(t, rec, "/")
+
+	if len(hs.store.connections) != 2 {
+		t.Fatalf("stored %d connections, want 2", len(hs.store.connections))
+	}
+	c := hs.store.connections[testOwnerID+"/tenant-1"]
+	if c == nil || c.TenantName != "Acme Ltd" || c.AccessToken != "at-1" || c.RefreshToken != "rt-1" {
+		t.Fatalf("unexpected connection: %+v", c)
+	}
+	if _, ok := hs.store.states["st-1"]; ok {
+		t.Fatal("state should be consumed")
+	}
+}
+
+func TestXeroCallback_Errors(t *testing.T) {
+	t.Parallel()
+
+	otherBrowser := func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: oauthNonceCookie, Value: "someone-else"})
+	}
+	noop := func(*http.Request) {}
+
+	tests := []struct {
+		name   string
+		target string
+		nonce  reqOption
+		want   int
+	}{
+		{"missing code", "/xero/callback?state=st-1", withNonce, http.StatusBadRequest},
+		{"missing state", "/xero/callback?code=code-1", withNonce, http.StatusBadRequest},
+		{"unknown state", "/xero/callback?code=code-1&state=other", withNonce, http.StatusBadRequest},
+		{"missing nonce cookie", "/xero/callback?code=code-1&state=st-1", noop, http.StatusBadRequest},
+		{"nonce from another browser", "/xero/callback?code=code-1&state=st-1", otherBrowser, http.StatusBadRequest},
+		{"token exchange rejected", "/xero/callback?code=code-1&state=st-1", withNonce, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := newHarness(t)
+			hs.store.states["st-1"] = oauthState{owner: testOwnerID, nonce: testNonce}
+			hs.xero.HandleFunc("POST /connect/token", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			})
+
+			rec := hs.do(http.MethodGet, tt.target, nil, tt.nonce)
+			expectStatus(t, rec, tt.want)
+			if len(hs.store.connections) != 0 {
+				t.Fatal("no connection should be stored")
+			}
+		})
+	}
+}
+
+func TestXeroCallback_OtherWorkspace(t *testing.T) {
+	t.Parallel()
+
+	// no Xero calls are expected: the callback is refused before the code is used
+	t.Run("switched workspace since connecting", func(t *testing.T) {
+		hs := newHarness(t)
+		id, _ := sharedWorkspace(t, hs)
+		hs.store.states["st-1"] = oauthState{owner: id, nonce: testNonce}
+
+		rec := hs.do(http.MethodGet, "/xero/callback?code=code-1&state=st-1", nil, withNonce, inWorkspace(testOwnerID))
+		expectStatus(t, rec, http.StatusForbidden)
+		if len(hs.store.connections) != 0 {
+			t.Fatal("no connection should be stored")
+		}
+	})
+	t.Run("removed from the workspace since connecting", func(t *testing.T) {
+		hs := newHarness(t)
+		id, _ := sharedWorkspace(t, hs)
+		hs.store.states["st-1"] = oauthState{owner: id, nonce: testNonce}
+		delete(hs.store.workspaces[id].members, testOwnerID)
+
+		rec := hs.do(http.MethodGet, "/xero/callback?code=code-1&state=st-1", nil, withNonce, inWorkspace(id))
+		expectStatus(t, rec, http.StatusForbidden)
+		if len(hs.store.connections) != 0 {
+			t.Fatal("no connection should be stored")
+		}
+	})
+}
+
+func TestGetInvoice_Fragment(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy:    []service.BOMNode{{PartID: "ASSY", Name: "Frame", Quantity: 1, IsAssembly: true, Children: []service.BOMNode{{PartID: "BOLT", Name: "Bolt", Quantity: 4}}}},
+		leafTotals: []service.LeafTotal{{PartID: "BOLT", Name: "Bolt", Quantity: 4}},
+	}
+
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "ignore_stock": {"1"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{"BOLT", "/invoice/INV-1/bom.pdf", `name="csrf_token"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fragment missing %q", want)
+		}
+	}
+	if len(hs.store.builds) != 1 || hs.store.builds[0] != "INV-1" {
+		t.Fatalf("builds = %v, want [INV-1]", hs.store.builds)
+	}
+}
+
+func TestGetInvoice_FragmentFormatsAmounts(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy: []service.BOMNode{{PartID: "ASSY", Name: "Frame", Quantity: 1, IsAssembly: true, TotalCost: 1250, Children: []service.BOMNode{
+			{PartID: "BOLT", Name: "Bolt", Quantity: 2.5, UnitCost: 500, TotalCost: 1250},
+		}}},
+		leafTotals: []service.LeafTotal{{PartID: "BOLT", Name: "Bolt", Quantity: 3, UnitCost: 500, TotalCost: 1500}},
+	}
+	hs.xero.HandleFunc("GET /api.xro/2.0/Organisation", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Organisations":[{"Name":"Demo","BaseCurrency":"EUR"}]}`)
+	})
+
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "ignore_stock": {"1"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	for _, want := range []string{"€1,250.00", "€1,500.00", ">2.5<", "padding-left: 1.5rem"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fragment missing %q", want)
+		}
+	}
+}
+
+func TestGetInvoice_FragmentUsesOrgLocale(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy: []service.BOMNode{{PartID: "ASSY", Name: "Frame", Quantity: 1, IsAssembly: true, TotalCost: 1250, Children: []service.BOMNode{
+			{PartID: "BOLT", Name: "Bolt", Quantity: 2.5, UnitCost: 500, TotalCost: 1250},
+		}}},
+		leafTotals: []service.LeafTotal{{PartID: "BOLT", Name: "Bolt", Quantity: 3.5, UnitCost: 500, TotalCost: 1750}},
+	}
+	hs.xero.HandleFunc("GET /api.xro/2.0/Organisation", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Organisations":[{"Name":"Demo","BaseCurrency":"EUR","CountryCode":"DE"}]}`)
+	})
+
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "ignore_stock": {"1"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	body := rec.Body.String()
+	// the quantity input keeps the number as the browser reads it
+	for _, want := range []string{"1.250,00\u00a0€", "1.750,00\u00a0€", ">2,5<", `value="3.5"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fragment missing %q", want)
+		}
+	}
+}
+
+func TestGetInvoice_ToggleExpand(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy:    []service.BOMNode{{PartID: "SINK", Name: "Sink unit", Quantity: 2, Collapsible: true}},
+		leafTotals: []service.LeafTotal{{PartID: "SINK", Name: "Sink unit", Quantity: 2}},
+	}
+
+	// the button adds SINK to what was already expanded
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "expand": {"TAP"}, "toggle": {"SINK"}}, htmx)
+	expectStatus(t, rec, http.StatusOK)
+	if got := hs.store.resolve.Expand; !reflect.DeepEqual(got, []string{"TAP", "SINK"}) {
+		t.Fatalf("Expand = %v, want [TAP SINK]", got)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`name="toggle" value="SINK"`, "Order parts", `name="expand" value="TAP"`, `name="expand" value="SINK"`, "/invoice/INV-1/bom.pdf?expand=TAP&amp;expand=SINK"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("fragment missing %q", want)
+		}
+	}
+
+	// and again takes it out; the choice survives the redirect to the home page
+	rec = hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}, "expand": {"TAP", "SINK"}, "toggle": {"SINK"}})
+	expectRedirect(t, rec, "/")
+	if got := hs.invoiceView(rec).Expand; !reflect.DeepEqual(got, []string{"TAP"}) {
+		t.Fatalf("saved Expand = %v, want [TAP]", got)
+	}
+}
+
+func TestGetInvoice_DeductsStock(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.store.invoices["INV-1"] = resolvedInvoice{
+		perAssy:    []service.BOMNode{{PartID: "BOLT", Name: "Bolt", Quantity: 10}},
+		leafTotals: []service.LeafTotal{{PartID: "BOLT", Name: "Bolt", Quantity: 10}},
+	}
+	hs.xero.HandleFunc("GET /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"Items":[{"Code":"BOLT","Name":"Bolt","IsTrackedAsInventory":true,"QuantityOnHand":3}]}`)
+	})
+
+	rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}})
+	expectRedirect(t, rec, "/")
+
+	totals := hs.invoiceView(rec).LeafTotals
+	if len(totals) != 1 || totals[0].Quantity != 7 || totals[0].OnHand != 3 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+}
+
+func TestGetInvoice_Messages(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unresolvable invoice redirects with warning", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"MISSING"}})
+		expectRedirect(t, rec, "/")
+		msgs := hs.flashMessages(rec)
+		if len(msgs) != 1 || !strings.Contains(msgs[0].Text, "No items found on invoice MISSING") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+	})
+	t.Run("missing invoice number", func(t *testing.T) {
+		hs := newHarness(t)
+		expectStatus(t, hs.do(http.MethodPost, "/xero/invoice", url.Values{}), http.StatusBadRequest)
+	})
+	t.Run("no connection", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.creds.err = service.ErrNoConnection
+		expectStatus(t, hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}}), http.StatusNotFound)
+	})
+	t.Run("revoked consent asks to reconnect", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.creds.err = service.ErrConsentRevoked
+		rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}})
+		expectRedirect(t, rec, "/")
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Level != flash.Warn || !strings.Contains(msgs[0].Text, "Connect to Xero") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+
+		rec = hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}}, htmx)
+		expectStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get("HX-Redirect"); got != "/" {
+			t.Fatalf("HX-Redirect = %q", got)
+		}
+	})
+	t.Run("errors render into the fragment", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.creds.err = fmt.Errorf("db down")
+		rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}}, htmx)
+		expectStatus(t, rec, http.StatusOK)
+		if !strings.Contains(rec.Body.String(), "db down") {
+			t.Fatalf("fragment missing error: %s", rec.Body.String())
+		}
+	})
+	t.Run("csrf token required", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodPost, "/xero/invoice", url.Values{"invoice_id": {"INV-1"}}, func(r *http.Request) {
+			r.Header.Del("Cookie")
+			r.AddCookie(&http.Cookie{Name: "access_token", Value: testAccessToken})
+		})
+		expectStatus(t, rec, http.StatusForbidden)
+	})
+}
+
+// fakeXeroSuppliers is a Xero tenant with one supplier and the items it sells.
+func fakeXeroSuppliers(t *testing.T) *xerotest.Server {
+	t.Helper()
+	fx := xerotest.New(xerotest.Fixtures{
+		Tenants: []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		Items: []xerotest.Item{
+			{ItemID: "item-1", Code: "BOLT", Name: "M6 bolt", PurchaseDetails: xerotest.PriceDetails{UnitPrice: 0.2}},
+			{ItemID: "item-2", Code: "NUT", Name: "M6 nut"},
+		},
+		Contacts: []xerotest.Contact{{ContactID: "contact-1", Name: "Fasteners Inc", AccountNumber: "SUP-1"}},
+		TrackingCategories: []xerotest.TrackingCategory{
+			{TrackingCategoryID: "tc-1", Name: "Build", Options: []xerotest.TrackingOption{{TrackingOptionID: "to-1", Name: "INV-0001"}}},
+		},
+	})
+	t.Cleanup(fx.Close)
+	return fx
+}
+
+func TestCreatePurchaseOrders(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}, {ListID: 2, ItemID: "NUT", Quantity: 8}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {
+			{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}},
+			{ItemID: "NUT", Quantity: 8, ListIDs: []int{2}, Terms: service.SupplierTerms{PackSize: 10, UnitPrice: 0.05}},
+		},
+	}
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+
+	pos := fx.PurchaseOrders()
+	if len(pos) != 1 || pos[0].Contact.ContactID != "contact-1" {
+		t.Fatalf("unexpected POs in Xero: %+v", pos)
+	}
+	if lines := pos[0].LineItems; len(lines) != 2 || lines[0].Description != "M6 bolt" || lines[0].UnitAmount != 0.2 ||
+		lines[1].Quantity != 10 || lines[1].UnitAmount != 0.05 {
+		t.Fatalf("unexpected PO lines: %+v", lines)
+	}
+	if len(hs.store.purchaseOrders) != 1 || hs.store.purchaseOrders[0].XeroPOID != pos[0].PurchaseOrderID {
+		t.Fatalf("unexpected recorded POs: %+v", hs.store.purchaseOrders)
+	}
+	if fmt.Sprint(hs.store.ordered) != "[1 2]" {
+		t.Fatalf("ordered = %v, want [1 2]", hs.store.ordered)
+	}
+	msgs := hs.flashMessages(rec)
+	if len(msgs) != 1 || msgs[0].Text != "Created 1 purchase order(s), 2 shopping list rows marked ordered" {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if links := msgs[0].Links; len(links) != 1 || !strings.HasSuffix(links[0].URL, "%2FAccounts%2FPayable%2FPurchaseOrders%2FView%2F"+pos[0].PurchaseOrderID) {
+		t.Fatalf("unexpected PO links: %+v", links)
+	}
+}
+
+func TestCreatePurchaseOrders_Currency(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := xerotest.New(xerotest.Fixtures{
+		Tenants:  []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		Items:    []xerotest.Item{{ItemID: "item-1", Code: "BOLT", Name: "M6 bolt", PurchaseDetails: xerotest.PriceDetails{UnitPrice: 0.2}}},
+		Contacts: []xerotest.Contact{{ContactID: "contact-2", Name: "Schrauben GmbH", AccountNumber: "SUP-EU", DefaultCurrency: "EUR"}},
+		Invoices: []xerotest.Invoice{{InvoiceID: "bill-1", InvoiceNumber: "BILL-1", Type: "ACCPAY", Status: "PAID", Contact: xerotest.PurchaseContact{ContactID: "contact-2"},
+			DateString: "2025-03-03T00:00:00", CurrencyCode: "EUR", CurrencyRate: 1.25}},
+	})
+	t.Cleanup(fx.Close)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-EU": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+
+	// raised in the supplier's currency, the Xero purchase price converted to it
+	pos := fx.PurchaseOrders()
+	if len(pos) != 1 || pos[0].CurrencyCode != "EUR" || pos[0].LineItems[0].UnitAmount != 0.25 {
+		t.Fatalf("unexpected POs in Xero: %+v", pos)
+	}
+	if recs := hs.store.purchaseOrders; len(recs) != 1 || recs[0].CurrencyCode != "EUR" || recs[0].CurrencyRate != 1.25 {
+		t.Fatalf("unexpected recorded POs: %+v", recs)
+	}
+}
+
+func TestCreatePurchaseOrders_NoCurrencyRate(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := xerotest.New(xerotest.Fixtures{
+		Tenants:  []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		Items:    []xerotest.Item{{ItemID: "item-1", Code: "BOLT", Name: "M6 bolt", PurchaseDetails: xerotest.PriceDetails{UnitPrice: 0.2}}},
+		Contacts: []xerotest.Contact{{ContactID: "contact-3", Name: "Bolts LLC", AccountNumber: "SUP-US", DefaultCurrency: "USD"}},
+	})
+	t.Cleanup(fx.Close)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-US": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+
+	// no USD bill to take a rate from: the base currency price must not go out as USD
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/purchase-orders/preview")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "no USD exchange rate in Xero yet") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if len(fx.PurchaseOrders()) != 0 || len(hs.store.ordered) != 0 {
+		t.Fatal("nothing should be ordered")
+	}
+
+	rec = hs.do(http.MethodGet, "/purchase-orders/preview", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); !strings.Contains(body, "No USD rate") || !strings.Contains(body, "1 line(s) only have a Xero purchase price") {
+		t.Fatalf("preview does not flag the line:\n%s", body)
+	}
+}
+
+func TestCreatePurchaseOrders_ChangedRowsNotMarked(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.handler.xc = fakeXeroSuppliers(t).Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4, Version: 3}, {ListID: 2, ItemID: "NUT", Quantity: 8}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}, {ItemID: "NUT", Quantity: 8, ListIDs: []int{2}}},
+	}
+	hs.store.changed = []int{2} // edited by someone else while the PO was raised
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+	if fmt.Sprint(hs.store.ordered) != "[1]" {
+		t.Fatalf("ordered = %v, want [1]", hs.store.ordered)
+	}
+	msgs := hs.flashMessages(rec)
+	if len(msgs) != 2 || !strings.Contains(msgs[0].Text, "row(s) 2 changed since you loaded them") ||
+		msgs[1].Text != "Created 1 purchase order(s), 1 shopping list rows marked ordered" {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+}
+
+func TestCreatePurchaseOrders_RecordFailureMarksNothing(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.handler.xc = fakeXeroSuppliers(t).Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+	hs.store.recordErr = errors.New("connection reset")
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "check Xero before ordering again") {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	if len(hs.store.ordered) != 0 || len(hs.store.purchaseOrders) != 0 {
+		t.Fatalf("ordered = %v, recorded = %+v; want neither", hs.store.ordered, hs.store.purchaseOrders)
+	}
+}
+
+func TestCreatePurchaseOrders_Details(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{
+		{ListID: 1, ItemID: "BOLT", Quantity: 4, SourceInvoice: "INV-0002"},
+		{ListID: 2, ItemID: "NUT", Quantity: 8, SourceInvoice: "INV-0001"},
+		{ListID: 3, ItemID: "NUT", Quantity: 2},
+	}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}, {ItemID: "NUT", Quantity: 10, ListIDs: []int{2, 3}}},
+	}
+	settings := service.DefaultOwnerSettings()
+	settings.POStatus = service.POStatusDraft
+	settings.BrandingThemeID = "theme-1"
+	settings.AutoEmailSuppliers = true
+	settings.POSettings = service.POSettings{DeliveryAddress: "Unit 4", AttentionTo: "Stores"}
+	hs.store.settings[testOwnerID] = settings
+
+	// saved defaults; the reference falls back to the source invoices
+	expectRedirect(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}), "/")
+	pos := fx.PurchaseOrders()
+	if len(pos) != 1 || pos[0].DeliveryAddress != "Unit 4" || pos[0].AttentionTo != "Stores" || pos[0].Reference != "INV-0001, INV-0002" {
+		t.Fatalf("unexpected PO: %+v", pos)
+	}
+	if pos[0].Status != "DRAFT" || pos[0].BrandingThemeID != "theme-1" || !pos[0].SentToContact {
+		t.Fatalf("unexpected PO settings: %+v", pos[0])
+	}
+	if len(hs.store.purchaseOrders) != 1 || hs.store.purchaseOrders[0].Status != "DRAFT" {
+		t.Fatalf("unexpected recorded POs: %+v", hs.store.purchaseOrders)
+	}
+
+	// fields edited on the preview screen win over the defaults
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{
+		"po_details":       {"1"},
+		"delivery_address": {"Goods in, Dock 2"},
+		"reference":        {"Job 42"},
+	})
+	expectRedirect(t, rec, "/")
+	pos = fx.PurchaseOrders()
+	if len(pos) != 2 || pos[1].DeliveryAddress != "Goods in, Dock 2" || pos[1].AttentionTo != "" || pos[1].Reference != "Job 42" {
+		t.Fatalf("unexpected PO: %+v", pos[1:])
+	}
+	if hs.store.settings[testOwnerID].AttentionTo != "Stores" {
+		t.Fatal("creating POs should not change the saved defaults")
+	}
+}
+
+func TestCreatePurchaseOrders_Tracking(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{
+		{ListID: 1, ItemID: "BOLT", Quantity: 4, SourceInvoice: "INV-0001"},
+		{ListID: 2, ItemID: "NUT", Quantity: 8, SourceInvoice: "INV-0002"},
+		{ListID: 3, ItemID: "WASHER", Quantity: 2, SourceInvoice: "INV-0001"},
+		{ListID: 4, ItemID: "WASHER", Quantity: 2, SourceInvoice: "INV-0002"},
+	}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {
+			{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}},
+			{ItemID: "NUT", Quantity: 8, ListIDs: []int{2}},
+			{ItemID: "WASHER", Quantity: 4, ListIDs: []int{3, 4}},
+		},
+	}
+	settings := service.DefaultOwnerSettings()
+	settings.TrackingCategory = "build"
+	hs.store.settings[testOwnerID] = settings
+
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+	pos := fx.PurchaseOrders()
+	if len(pos) != 1 {
+		t.Fatalf("unexpected POs: %+v", pos)
+	}
+	var got []string
+	for _, li := range pos[0].LineItems {
+		got = append(got, fmt.Sprintf("%s:%v", li.ItemCode, li.Tracking))
+	}
+	// a line from two invoices can't be attributed to either
+	if want := "[BOLT:[{Build INV-0001}] NUT:[{Build INV-0002}] WASHER:[]]"; fmt.Sprint(got) != want {
+		t.Fatalf("tracking = %v, want %s", got, want)
+	}
+	if opts := fx.TrackingCategories()[0].Options; len(opts) != 2 || opts[1].Name != "INV-0002" {
+		t.Fatalf("option not created: %+v", opts)
+	}
+
+	// a category Xero doesn't have is reported and the POs are still raised
+	settings.TrackingCategory = "Van"
+	hs.store.settings[testOwnerID] = settings
+	rec = hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+	expectRedirect(t, rec, "/")
+	if msgs := hs.flashMessages(rec); len(msgs) != 2 || !strings.Contains(msgs[0].Text, "Tracking category Van not found") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if pos := fx.PurchaseOrders(); len(pos) != 2 || pos[1].LineItems[0].Tracking != nil {
+		t.Fatalf("unexpected POs: %+v", pos)
+	}
+}
+
+func TestCreatePurchaseOrders_AccountCodes(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}, {ListID: 2, ItemID: "NUT", Quantity: 8}}
+	hs.store.grouped = map[string][]service.ContactItem{
+		"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}, {ItemID: "NUT", Quantity: 8, ListIDs: []int{2}}},
+	}
+	hs.store.accountCodes["BOLT"] = "429"
+	hs.store.accountCodes["NUT"] = "300"
+
+	// item defaults, with the preview's edits winning
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{"po_details": {"1"}, "account_code.NUT": {"310"}})
+	expectRedirect(t, rec, "/")
+	pos := fx.PurchaseOrders()
+	if len(pos) != 1 || pos[0].LineItems[0].AccountCode != "429" || pos[0].LineItems[1].AccountCode != "310" {
+		t.Fatalf("unexpected POs: %+v", pos)
+	}
+	if hs.store.accountCodes["NUT"] != "300" {
+		t.Fatal("creating POs should not change the item defaults")
+	}
+
+	rec = hs.do(http.MethodPost, "/xero/create-pos", url.Values{"po_details": {"1"}, "account_code.NUT": {"3 10"}})
+	expectRedirect(t, rec, "/purchase-orders/preview")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "NUT: invalid account code") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+	if len(fx.PurchaseOrders()) != 1 {
+		t.Fatal("no purchase order should be created")
+	}
+}
+
+func TestCreatePurchaseOrders_Failures(t *testing.T) {
+	t.Parallel()
+
+	t.Run("empty shopping list", func(t *testing.T) {
+		hs := newHarness(t)
+		rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+		expectRedirect(t, rec, "/")
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || msgs[0].Text != "No unordered shopping list items found." {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+	})
+	t.Run("unknown contact leaves rows unordered", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+		hs.store.grouped = map[string][]service.ContactItem{"SUP-X": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+		hs.xero.HandleFunc("GET /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"Items":[]}`)
+		})
+		hs.xero.HandleFunc("GET /api.xro/2.0/Contacts", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"Contacts":[]}`)
+		})
+
+		rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+		expectRedirect(t, rec, "/")
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "No ContactID found for SUP-X") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+		if len(hs.store.ordered) != 0 {
+			t.Fatalf("rows marked ordered: %v", hs.store.ordered)
+		}
+	})
+	t.Run("later supplier failing records the POs already created", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.store.shopping = []service.ShoppingRow{
+			{ListID: 1, ItemID: "BOLT", Quantity: 4}, {ListID: 2, ItemID: "NUT", Quantity: 8}, {ListID: 3, ItemID: "WASHER", Quantity: 2},
+		}
+		hs.store.grouped = map[string][]service.ContactItem{
+			"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}},
+			"SUP-2": {{ItemID: "NUT", Quantity: 8, ListIDs: []int{2}}},
+			"SUP-3": {{ItemID: "WASHER", Quantity: 2, ListIDs: []int{3}}},
+		}
+		hs.xero.HandleFunc("GET /api.xro/2.0/Items", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"Items":[]}`)
+		})
+		hs.xero.HandleFunc("GET /api.xro/2.0/Contacts", func(w http.ResponseWriter, r *http.Request) {
+			for _, account := range []string{"SUP-1", "SUP-2", "SUP-3"} {
+				if strings.Contains(r.URL.Query().Get("where"), `"`+account+`"`) {
+					_, _ = io.WriteString(w, `{"Contacts":[{"ContactID":"contact-`+account+`","AccountNumber":"`+account+`"}]}`)
+					return
+				}
+			}
+			_, _ = io.WriteString(w, `{"Contacts":[]}`)
+		})
+		hs.xero.HandleFunc("POST /api.xro/2.0/PurchaseOrders", func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(b), "contact-SUP-2") {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.WriteString(w, `{"Type":"ValidationException","Elements":[{"ValidationErrors":[{"Message":"Contact is archived"}]}]}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"PurchaseOrders":[{"PurchaseOrderID":"po-1"}]}`)
+		})
+
+		rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+		expectRedirect(t, rec, "/")
+		if recs := hs.store.purchaseOrders; len(recs) != 1 || recs[0].XeroPOID != "po-1" || recs[0].ContactAccount != "SUP-1" {
+			t.Fatalf("recorded POs = %+v, want SUP-1's", recs)
+		}
+		if fmt.Sprint(hs.store.ordered) != "[1]" {
+			t.Fatalf("ordered = %v, want [1]", hs.store.ordered)
+		}
+		msgs := hs.flashMessages(rec)
+		if len(msgs) != 1 || msgs[0].Level != flash.Error ||
+			!strings.HasPrefix(msgs[0].Text, "Created purchase order(s) for SUP-1, 1 shopping list rows marked ordered. Failed to create PO for contact SUP-2") ||
+			!strings.HasSuffix(msgs[0].Text, ". Not sent: SUP-3") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+	})
+	t.Run("transient rate limit is retried", func(t *testing.T) {
+		hs := newHarness(t)
+		fx := fakeXeroSuppliers(t)
+		fx.Throttle(1)
+		hs.handler.xc = fx.Client()
+		hs.handler.xc.Retry = &testRetry
+		hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+		hs.store.grouped = map[string][]service.ContactItem{"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+
+		expectRedirect(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}), "/")
+		if len(fx.PurchaseOrders()) != 1 {
+			t.Fatalf("purchase orders: %+v", fx.PurchaseOrders())
+		}
+	})
+	t.Run("rate limited by xero", func(t *testing.T) {
+		hs := newHarness(t)
+		fx := fakeXeroSuppliers(t)
+		fx.Throttle(testRetry.MaxAttempts)
+		hs.handler.xc = fx.Client()
+		hs.handler.xc.Retry = &testRetry
+		hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+		hs.store.grouped = map[string][]service.ContactItem{"SUP-1": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+
+		rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{})
+		expectRedirect(t, rec, "/")
+		if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "limiting how often") {
+			t.Fatalf("unexpected flash: %+v", msgs)
+		}
+		if len(fx.PurchaseOrders()) != 0 || len(hs.store.ordered) != 0 {
+			t.Fatal("nothing should be ordered")
+		}
+	})
+	t.Run("no connection", func(t *testing.T) {
+		hs := newHarness(t)
+		hs.creds.err = service.ErrNoConnection
+		expectStatus(t, hs.do(http.MethodPost, "/xero/create-pos", url.Values{}), http.StatusNotFound)
+	})
+}
+
+func TestCreatePurchaseOrders_SupplierChoice(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	fx := fakeXeroSuppliers(t)
+	hs.handler.xc = fx.Client()
+	hs.store.shopping = []service.ShoppingRow{{ListID: 1, ItemID: "BOLT", Quantity: 4}}
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-2": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{1}}}}
+
+	// only the preview's choices count; a plain "Create Purchase Orders" uses the defaults
+	rec := hs.do(http.MethodPost, "/xero/create-pos", url.Values{"supplier.BOLT": {"SUP-2"}})
+	expectRedirect(t, rec, "/")
+	if len(hs.store.choices) != 0 {
+		t.Fatalf("choices: %+v", hs.store.choices)
+	}
+
+	hs.store.grouped = map[string][]service.ContactItem{"SUP-2": {{ItemID: "BOLT", Quantity: 4, ListIDs: []int{2}}}}
+	hs.store.shopping = []service.ShoppingRow{{ListID: 2, ItemID: "BOLT", Quantity: 4}}
+	rec = hs.do(http.MethodPost, "/xero/create-pos", url.Values{"po_details": {"1"}, "supplier.BOLT": {"SUP-2"}})
+	expectRedirect(t, rec, "/")
+	if hs.store.choices["BOLT"] != "SUP-2" {
+		t.Fatalf("choices: %+v", hs.store.choices)
+	}
+
+	hs.store.groupErr = fmt.Errorf("%w: BOLT is not bought from SUP-9", service.ErrSupplierChoice)
+	rec = hs.do(http.MethodPost, "/xero/create-pos", url.Values{"po_details": {"1"}, "supplier.BOLT": {"SUP-9"}})
+	expectRedirect(t, rec, "/purchase-orders/preview")
+	if msgs := hs.flashMessages(rec); len(msgs) != 1 || !strings.Contains(msgs[0].Text, "BOLT is not bought from SUP-9") {
+		t.Fatalf("unexpected flash: %+v", msgs)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// webhookStore remembers the Xero webhook events already handled
+// (xero_webhook_events) and polls the invoices of the tenants they are for.
+type webhookStore interface {
+	RecordWebhookEvents(ctx context.Context, events []xero.WebhookEvent, maxAge time.Duration) (service.WebhookReceipt, error)
+	PollTenantInvoices(ctx context.Context, xc *xero.Client, tenantID string) (service.InvoicePollResult, error)
+}
+
+// webhookDB is the webhookStore outside tests. Polling refreshes each connection's
+// tokens with tokens and first looks back lookback, as the invoice poll job does.
+type webhookDB struct {
+	dbStore
+	tokens   *service.TokenManager
+	lookback time.Duration
+}
+
+func (s webhookDB) RecordWebhookEvents(ctx context.Context, events []xero.WebhookEvent, maxAge time.Duration) (service.WebhookReceipt, error) {
+	return service.RecordWebhookEvents(ctx, s.dbURL, events, maxAge)
+}
+
+func (s webhookDB) PollTenantInvoices(ctx context.Context, xc *xero.Client, tenantID string) (service.InvoicePollResult, error) {
+	return service.PollTenantInvoices(ctx, s.dbURL, xc, s.tokens, tenantID, s.lookback)
+}
+
+// webhookEventInvoice is the event category of sales invoice and bill changes.
+const webhookEventInvoice = "INVOICE"
+
+// webhookPollTimeout bounds the invoice polls a delivery starts.
+const webhookPollTimeout = 2 * time.Minute
+
+// xeroWebhookHandler receives Xero webhook deliveries (POST /xero/webhooks). A
+// delivery not signed with the current or the previous webhooks key gets 401, which
+// is also the answer Xero's "intent to receive" check wants for its unsigned
+// delivery. Events older than XERO_WEBHOOK_MAX_AGE, and events handled before, are
+// ignored. Tenants with a new invoice event have their invoices polled in the
+// background, as Xero wants an answer within 5 seconds.
+func (h *Handler) xeroWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	wh := h.cfg.XeroWebhooks
+	if !wh.Enabled() || h.webhooks == nil {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+	if !xero.VerifyWebhook(body, r.Header.Get(xero.WebhookSignatureHeader), wh.Keys()...) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	p, err := xero.ParseWebhook(body)
+	if err != nil {
+		http.Error(w, "invalid webhook payload", http.StatusBadRequest)
+		return
+	}
+	rec, err := h.webhooks.RecordWebhookEvents(r.Context(), p.Events, wh.MaxAge)
+	if err != nil {
+		// Xero delivers the events again later
+		log.Printf("xero webhook: record events: %v", err)
+		http.Error(w, "could not record events", http.StatusInternalServerError)
+		return
+	}
+	if rec.Stale > 0 || rec.Seen > 0 {
+		log.Printf("xero webhook: ignored %d stale and %d repeated event(s)", rec.Stale, rec.Seen)
+	}
+	if tenants := invoiceEventTenants(rec.Fresh); len(tenants) > 0 {
+		go h.pollWebhookTenants(context.WithoutCancel(r.Context()), tenants)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// invoiceEventTenants are the tenants of the invoice events, sorted, each once.
+func invoiceEventTenants(events []xero.WebhookEvent) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range events {
+		if e.EventCategory != webhookEventInvoice || e.TenantID == "" || seen[e.TenantID] {
+			continue
+		}
+		seen[e.TenantID] = true
+		out = append(out, e.TenantID)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// pollWebhookTenants polls the invoices of tenants, logging the outcome; a failure
+// is left for the invoice poll job's next run.
+func (h *Handler) pollWebhookTenants(ctx context.Context, tenants []string) {
+	ctx, cancel := context.WithTimeout(ctx, webhookPollTimeout)
+	defer cancel()
+	for _, tenant := range tenants {
+		res, err := h.webhooks.PollTenantInvoices(ctx, h.xc, tenant)
+		if err != nil {
+			log.Printf("xero webhook: poll tenant=%s: %v", tenant, err)
+			continue
+		}
+		log.Printf("xero webhook: tenant=%s seen=%d ready=%d problems=%d removed=%d",
+			tenant, res.Seen, res.Ready, res.Problems, res.Removed)
+	}
+}
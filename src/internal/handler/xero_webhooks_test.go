@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/config"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// webhookBody is a delivery of one event per tenant, dated at.
+func webhookBody(at time.Time, category string, tenants ...string) string {
+	var events []string
+	for i, tenant := range tenants {
+		events = append(events, fmt.Sprintf(`{"resourceUrl":"https://api.xero.com/api.xro/2.0/Invoices/inv-%d","resourceId":"inv-%d",`+
+			`"eventDateUtc":%q,"eventType":"UPDATE","eventCategory":%q,"tenantId":%q,"tenantType":"ORGANISATION"}`,
+			i, i, at.UTC().Format("2006-01-02T15:04:05.000"), category, tenant))
+	}
+	return `{"events":[` + strings.Join(events, ",") + `],"firstEventSequence":1,"lastEventSequence":1,"entropy":"ABCDEF"}`
+}
+
+// deliver posts body to the webhook receiver as Xero would, signed with key.
+func (hs *harness) deliver(body, key string) *httptest.ResponseRecorder {
+	hs.t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/xero/webhooks", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(xero.WebhookSignatureHeader, xero.SignWebhook([]byte(body), key))
+	rec := httptest.NewRecorder()
+	hs.router.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestXeroWebhook(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	hs.handler.cfg.XeroWebhooks = config.XeroWebhookConfig{Key: "new-key", PreviousKey: "old-key", MaxAge: time.Hour}
+
+	// Xero's intent to receive check: a signed delivery without events is accepted,
+	// a wrongly signed one refused
+	intent := `{"events":[],"firstEventSequence":0,"lastEventSequence":0,"entropy":"S0m3r4Nd0mt3xt"}`
+	expectStatus(t, hs.deliver(intent, "new-key"), http.StatusOK)
+	expectStatus(t, hs.deliver(intent, "some-other-key"), http.StatusUnauthorized)
+
+	// while the key is rotated, deliveries signed with the previous one still count
+	body := webhookBody(time.Now().Add(-time.Minute), "INVOICE", "tenant-2", "tenant-1", "tenant-2")
+	expectStatus(t, hs.deliver(body, "old-key"), http.StatusOK)
+	for _, want := range []string{"tenant-1", "tenant-2"} {
+		select {
+		case got := <-hs.store.tenantPolls:
+			if got != want {
+				t.Fatalf("polled %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("tenant %s not polled", want)
+		}
+	}
+
+	// a replayed delivery, stale events and other categories poll nothing
+	expectStatus(t, hs.deliver(body, "new-key"), http.StatusOK)
+	expectStatus(t, hs.deliver(webhookBody(time.Now().Add(-2*time.Hour), "INVOICE", "tenant-1"), "new-key"), http.StatusOK)
+	expectStatus(t, hs.deliver(webhookBody(time.Now(), "CONTACT", "tenant-1"), "new-key"), http.StatusOK)
+	select {
+	case got := <-hs.store.tenantPolls:
+		t.Fatalf("tenant %s polled again", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	expectStatus(t, hs.deliver(`{"events":`, "new-key"), http.StatusBadRequest)
+
+	t.Run("unsigned", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/xero/webhooks", strings.NewReader(intent))
+		rec := httptest.NewRecorder()
+		hs.router.ServeHTTP(rec, r)
+		// no signature header means no CSRF exemption either
+		expectStatus(t, rec, http.StatusForbidden)
+	})
+}
+
+func TestXeroWebhook_Disabled(t *testing.T) {
+	t.Parallel()
+	hs := newHarness(t)
+	expectStatus(t, hs.deliver(webhookBody(time.Now(), "INVOICE", "tenant-1"), ""), http.StatusNotFound)
+}
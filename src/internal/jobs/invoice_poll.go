@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// PollInvoices returns a job that prepares the BOMs of sales invoices newly
+// authorised in Xero for every stored connection, looking back lookback the first
+// time a connection is polled. A failure for one connection is logged and does not
+// stop the others.
+func PollInvoices(dbURL string, xc *xero.Client, clientID, clientSecret string, lookback time.Duration) Func {
+	return func(ctx context.Context) error {
+		conns, err := service.ListAllConnections(ctx, dbURL)
+		if err != nil {
+			return err
+		}
+		tokens := service.NewTokenManager(dbURL, xc, clientID, clientSecret)
+		failed := 0
+		for _, c := range conns {
+			creds, err := tokens.Credentials(ctx, c)
+			if err != nil {
+				log.Printf("poll-invoices: owner=%s tenant=%s: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			res, err := service.PollInvoices(ctx, dbURL, xc, c.OwnerID, creds, lookback)
+			if err != nil {
+				log.Printf("poll-invoices: owner=%s tenant=%s: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			if res.Seen > 0 {
+				log.Printf("poll-invoices: owner=%s tenant=%s seen=%d ready=%d problems=%d removed=%d",
+					c.OwnerID, c.TenantID, res.Seen, res.Ready, res.Problems, res.Removed)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d connections failed", failed, len(conns))
+		}
+		return nil
+	}
+}
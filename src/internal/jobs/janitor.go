@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// Janitor metrics, served with the other expvars on /debug/vars.
+var (
+	// PurgedRows counts rows deleted by Janitor since start, by table.
+	PurgedRows = expvar.NewMap("janitor_rows_purged")
+	// janitorLastRun is when Janitor last completed (unix seconds).
+	janitorLastRun = expvar.NewInt("janitor_last_run_unix")
+)
+
+// Janitor returns a job that deletes expired and orphaned rows, and rows older than
+// retention allows (see service.PurgeExpiredRows), logging and counting what it
+// purged.
+func Janitor(dbURL string, retention service.Retention) Func {
+	return func(ctx context.Context) error {
+		purged, err := service.PurgeExpiredRows(ctx, dbURL, retention)
+		for table, n := range purged {
+			PurgedRows.Add(table, n)
+		}
+		if len(purged) > 0 {
+			log.Printf("janitor: purged %s", purgedSummary(purged))
+		}
+		if err != nil {
+			return err
+		}
+		janitorLastRun.Set(time.Now().Unix())
+		return nil
+	}
+}
+
+// purgedSummary is "api_call_log=3 oauth_states=0 ..." in table order.
+func purgedSummary(purged map[string]int64) string {
+	tables := make([]string, 0, len(purged))
+	for t := range purged {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	parts := make([]string, len(tables))
+	for i, t := range tables {
+		parts[i] = fmt.Sprintf("%s=%d", t, purged[t])
+	}
+	return strings.Join(parts, " ")
+}
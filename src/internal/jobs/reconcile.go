@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// ReconcilePurchaseOrders returns a job that reconciles purchase orders created in the
+// last lookback period against Xero for every stored connection.
+// A failure for one connection is logged and does not stop the others.
+func ReconcilePurchaseOrders(dbURL string, xc *xero.Client, clientID, clientSecret string, lookback time.Duration) Func {
+	return func(ctx context.Context) error {
+		conns, err := service.ListAllConnections(ctx, dbURL)
+		if err != nil {
+			return err
+		}
+		tokens := service.NewTokenManager(dbURL, xc, clientID, clientSecret)
+		since := time.Now().Add(-lookback)
+		failed := 0
+		for _, c := range conns {
+			creds, err := tokens.Credentials(ctx, c)
+			if err != nil {
+				log.Printf("reconcile: owner=%s tenant=%s: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			report, err := service.RunPurchaseOrderReconciliation(ctx, dbURL, xc, c.OwnerID, creds, since)
+			if err != nil {
+				log.Printf("reconcile: owner=%s tenant=%s: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			log.Printf("reconcile: owner=%s tenant=%s xero=%d local=%d discrepancies=%d",
+				c.OwnerID, c.TenantID, report.XeroCount, report.LocalCount, len(report.Discrepancies))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d connections failed", failed, len(conns))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/notify"
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// RemindOutstandingPurchaseOrders returns a job that, for every stored connection,
+// sends one notification listing the purchase orders raised by the app that Xero has
+// not billed within their supplier's lead time (see service.ReminderPolicy).
+// A failure for one connection is logged and does not stop the others.
+func RemindOutstandingPurchaseOrders(dbURL string, xc *xero.Client, clientID, clientSecret string, policy service.ReminderPolicy, n notify.Notifier) Func {
+	return func(ctx context.Context) error {
+		conns, err := service.ListAllConnections(ctx, dbURL)
+		if err != nil {
+			return err
+		}
+		tokens := service.NewTokenManager(dbURL, xc, clientID, clientSecret)
+		now := time.Now()
+		failed := 0
+		for _, c := range conns {
+			creds, err := tokens.Credentials(ctx, c)
+			if err != nil {
+				log.Printf("po reminders: owner=%s tenant=%s: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			outstanding, err := service.FindOutstandingPurchaseOrders(ctx, dbURL, xc, c.OwnerID, creds, policy, now)
+			if err != nil {
+				log.Printf("po reminders: owner=%s tenant=%s: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			if len(outstanding) == 0 {
+				continue
+			}
+			if err := n.Notify(ctx, reminderMessage(c.TenantName, outstanding)); err != nil {
+				log.Printf("po reminders: owner=%s tenant=%s: notify: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			ids := make([]int, len(outstanding))
+			for i, po := range outstanding {
+				ids[i] = po.ID
+			}
+			if err := service.MarkPurchaseOrdersReminded(ctx, dbURL, ids, now); err != nil {
+				log.Printf("po reminders: owner=%s tenant=%s: %v", c.OwnerID, c.TenantID, err)
+				failed++
+				continue
+			}
+			log.Printf("po reminders: owner=%s tenant=%s outstanding=%d", c.OwnerID, c.TenantID, len(outstanding))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d connections failed", failed, len(conns))
+		}
+		return nil
+	}
+}
+
+// reminderMessage lists outstanding purchase orders, one per line, most overdue first.
+func reminderMessage(tenantName string, outstanding []service.OutstandingPurchaseOrder) notify.Message {
+	subject := fmt.Sprintf("%d purchase order(s) not yet billed", len(outstanding))
+	if tenantName != "" {
+		subject += " for " + tenantName
+	}
+	var b strings.Builder
+	b.WriteString("These purchase orders are past their supplier's lead time and have not been billed in Xero:\n")
+	for _, po := range outstanding {
+		supplier := po.ContactAccount
+		if po.SupplierName != "" {
+			supplier = po.SupplierName + " (" + po.ContactAccount + ")"
+		}
+		number := po.Number
+		if number == "" {
+			number = po.XeroPOID
+		}
+		fmt.Fprintf(&b, "- %s from %s: raised %s, due %s, %d day(s) overdue, %s\n",
+			number, supplier, po.CreatedAt.Format("2 Jan 2006"), po.DueAt.Format("2 Jan 2006"), po.DaysOverdue, strings.ToLower(po.Status))
+	}
+	return notify.Message{Subject: subject, Text: b.String()}
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// Func is a unit of background work. Errors are logged; the schedule continues.
+type Func func(ctx context.Context) error
+
+// Daily runs fn once a day at hour:minute UTC until ctx is cancelled.
+// Call it in its own goroutine.
+func Daily(ctx context.Context, name string, hour, minute int, fn Func) {
+	for {
+		next := nextDaily(time.Now().UTC(), hour, minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		run(ctx, name, fn)
+	}
+}
+
+// Every runs fn every interval until ctx is cancelled (first run after one interval).
+// Call it in its own goroutine.
+func Every(ctx context.Context, name string, interval time.Duration, fn Func) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			run(ctx, name, fn)
+		}
+	}
+}
+
+// Claim reports whether this instance may do the current run of job name: false
+// when another instance claimed it less than minGap ago.
+type Claim func(ctx context.Context, name string, minGap time.Duration) (bool, error)
+
+// ClaimInDB claims runs in the job_runs table as instanceID, so that of several app
+// instances on the same schedule only one runs each job.
+func ClaimInDB(dbURL, instanceID string) Claim {
+	return func(ctx context.Context, name string, minGap time.Duration) (bool, error) {
+		return service.ClaimJobRun(ctx, dbURL, name, instanceID, minGap)
+	}
+}
+
+// Exclusive wraps fn so it only runs when claim grants job name; otherwise the run
+// is skipped. minGap should be shorter than the schedule interval and longer than
+// the spread between instances' clocks. A failed claim skips the run as well:
+// running twice (e.g. sending reminders twice) is worse than waiting for the next one.
+func Exclusive(name string, minGap time.Duration, claim Claim, fn Func) Func {
+	return func(ctx context.Context) error {
+		ok, err := claim(ctx, name, minGap)
+		if err != nil {
+			return fmt.Errorf("claim run: %w", err)
+		}
+		if !ok {
+			log.Printf("job %s: another instance has this run", name)
+			return nil
+		}
+		return fn(ctx)
+	}
+}
+
+func run(ctx context.Context, name string, fn Func) {
+	start := time.Now()
+	log.Printf("job %s: starting", name)
+	if err := fn(ctx); err != nil {
+		log.Printf("job %s: failed after %s: %v", name, time.Since(start).Round(time.Millisecond), err)
+		return
+	}
+	log.Printf("job %s: done in %s", name, time.Since(start).Round(time.Millisecond))
+}
+
+// nextDaily returns the next hour:minute UTC strictly after now.
+func nextDaily(now time.Time, hour, minute int) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
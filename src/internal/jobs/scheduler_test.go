@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestNextDaily(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 10, 1, 30, 0, 0, time.UTC)
+
+	if got := nextDaily(now, 2, 0); !got.Equal(time.Date(2025, 3, 10, 2, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected later today, got %s", got)
+	}
+	if got := nextDaily(now, 1, 0); !got.Equal(time.Date(2025, 3, 11, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected tomorrow, got %s", got)
+	}
+	// exactly at the scheduled time runs tomorrow, not immediately again
+	if got := nextDaily(now, 1, 30); !got.Equal(time.Date(2025, 3, 11, 1, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected tomorrow for equal time, got %s", got)
+	}
+}
+
+func TestPurgedSummary(t *testing.T) {
+	t.Parallel()
+	got := purgedSummary(map[string]int64{"shopping_list": 0, "api_call_log": 12, "oauth_states": 3})
+	if want := "api_call_log=12 oauth_states=3 shopping_list=0"; got != want {
+		t.Fatalf("purgedSummary = %q, want %q", got, want)
+	}
+}
+
+func TestReminderMessage(t *testing.T) {
+	t.Parallel()
+	m := reminderMessage("Acme Ltd", []service.OutstandingPurchaseOrder{
+		{Number: "PO-0007", Status: "AUTHORISED", ContactAccount: "FAST", SupplierName: "Fasteners",
+			CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), DueAt: time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), DaysOverdue: 22},
+		{XeroPOID: "po-guid", Status: "SUBMITTED", ContactAccount: "ACME",
+			CreatedAt: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), DueAt: time.Date(2026, 3, 24, 0, 0, 0, 0, time.UTC), DaysOverdue: 7},
+	})
+	if m.Subject != "2 purchase order(s) not yet billed for Acme Ltd" {
+		t.Fatalf("subject = %q", m.Subject)
+	}
+	for _, want := range []string{
+		"- PO-0007 from Fasteners (FAST): raised 1 Mar 2026, due 9 Mar 2026, 22 day(s) overdue, authorised\n",
+		"- po-guid from ACME: raised 10 Mar 2026, due 24 Mar 2026, 7 day(s) overdue, submitted\n",
+	} {
+		if !strings.Contains(m.Text, want) {
+			t.Errorf("text missing %q:\n%s", want, m.Text)
+		}
+	}
+}
+
+func TestExclusive(t *testing.T) {
+	t.Parallel()
+	ran := 0
+	fn := func(ctx context.Context) error { ran++; return nil }
+	claimed := map[string]bool{}
+	// the first claim of each name wins, like several instances on one schedule
+	claim := func(ctx context.Context, name string, minGap time.Duration) (bool, error) {
+		if claimed[name] {
+			return false, nil
+		}
+		claimed[name] = true
+		return true, nil
+	}
+
+	job := Exclusive("janitor", time.Minute, claim, fn)
+	for i := 0; i < 3; i++ {
+		if err := job(context.Background()); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+	if ran != 1 {
+		t.Fatalf("ran %d times, want once", ran)
+	}
+
+	failing := func(ctx context.Context, name string, minGap time.Duration) (bool, error) {
+		return false, errors.New("db down")
+	}
+	if err := Exclusive("reminders", time.Minute, failing, fn)(context.Background()); err == nil || ran != 1 {
+		t.Fatalf("failed claim: err %v, ran %d; want an error and no run", err, ran)
+	}
+}
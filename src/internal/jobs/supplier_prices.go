@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// ApplySupplierPrices returns a job that makes the supplier price list entries that
+// have taken effect the agreed prices, then pushes the prices of price lists marked
+// for Xero to the items' purchase prices, retrying pushes that failed before. A
+// failure for one workspace is logged and does not stop the others.
+func ApplySupplierPrices(dbURL string, xc *xero.Client, clientID, clientSecret string) Func {
+	return func(ctx context.Context) error {
+		changes, err := service.ApplyDueSupplierPrices(ctx, dbURL)
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			log.Printf("apply-supplier-prices: %d agreed price(s) changed", len(changes))
+		}
+		owners, err := service.ListPendingPriceOwners(ctx, dbURL)
+		if err != nil {
+			return err
+		}
+		tokens := service.NewTokenManager(dbURL, xc, clientID, clientSecret)
+		failed := 0
+		for _, ownerID := range owners {
+			creds, err := tokens.CredentialsForOwner(ctx, ownerID)
+			if err != nil {
+				log.Printf("apply-supplier-prices: owner=%s: %v", ownerID, err)
+				failed++
+				continue
+			}
+			n, err := service.PushXeroPurchasePrices(ctx, dbURL, xc, ownerID, creds)
+			if err != nil {
+				log.Printf("apply-supplier-prices: owner=%s: %v", ownerID, err)
+				failed++
+				continue
+			}
+			log.Printf("apply-supplier-prices: owner=%s xero purchase prices updated=%d", ownerID, n)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d workspaces failed to push prices to Xero", failed, len(owners))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,228 @@
+// Package locale formats numbers, amounts and times the way the people behind a Xero
+// organisation expect them: the organisation's country picks the decimal and
+// thousands separators, the date order and the time zone. Month names stay English.
+//
+//	loc := locale.For(org.CountryCode, org.BaseCurrency)
+//	loc.Money(1234.5, loc.Currency) // "1.234,50 €" in Germany, "£1,234.50" in the UK
+package locale
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the zones below, on hosts without a zoneinfo database
+
+	"github.com/hwalton/xero-invoice-orderer/internal/uom"
+)
+
+// Locale is how one organisation's numbers and times are written.
+type Locale struct {
+	Country  string // ISO 3166 code, "" for Default
+	Currency string // ISO 4217 base currency, "" when not known
+
+	Decimal string // decimal separator
+	Group   string // thousands separator in amounts
+
+	DateLayout     string
+	DateTimeLayout string
+	MonthLayout    string
+	Zone           *time.Location
+
+	// SymbolAfter writes currency symbols after the amount ("1.234,50 €").
+	SymbolAfter bool
+}
+
+// Default is used when the organisation or its country is unknown: UK style
+// numbers and dates, times in UTC.
+var Default = Locale{
+	Decimal:        ".",
+	Group:          ",",
+	DateLayout:     "2 Jan 2006",
+	DateTimeLayout: "2006-01-02 15:04 UTC",
+	MonthLayout:    "January 2006",
+	Zone:           time.UTC,
+}
+
+// nbsp keeps grouped digits and units on one line.
+const nbsp = "\u00a0"
+
+// country is a country's conventions; zone is loaded when the locale is built.
+type country struct {
+	decimal, group        string
+	date, dateTime, month string
+	zone                  string
+	symbolAfter           bool
+}
+
+// Xero's regions and the other countries organisations commonly report. Times use
+// the zone of the country's main business centre.
+var countries = map[string]country{
+	"GB": {".", ",", "2 Jan 2006", "2 Jan 2006 15:04 MST", "January 2006", "Europe/London", false},
+	"IE": {".", ",", "2 Jan 2006", "2 Jan 2006 15:04 MST", "January 2006", "Europe/Dublin", false},
+	"AU": {".", ",", "2 Jan 2006", "2 Jan 2006 15:04 MST", "January 2006", "Australia/Sydney", false},
+	"NZ": {".", ",", "2 Jan 2006", "2 Jan 2006 15:04 MST", "January 2006", "Pacific/Auckland", false},
+	"SG": {".", ",", "2 Jan 2006", "2 Jan 2006 15:04 MST", "January 2006", "Asia/Singapore", false},
+	"US": {".", ",", "Jan 2, 2006", "Jan 2, 2006 3:04 PM MST", "January 2006", "America/New_York", false},
+	"CA": {".", ",", "Jan 2, 2006", "Jan 2, 2006 3:04 PM MST", "January 2006", "America/Toronto", false},
+	"ZA": {",", nbsp, "2006/01/02", "2006/01/02 15:04 MST", "January 2006", "Africa/Johannesburg", false},
+	"DE": {",", ".", "02.01.2006", "02.01.2006 15:04 MST", "January 2006", "Europe/Berlin", true},
+	"AT": {",", ".", "02.01.2006", "02.01.2006 15:04 MST", "January 2006", "Europe/Vienna", true},
+	"NL": {",", ".", "02-01-2006", "02-01-2006 15:04 MST", "January 2006", "Europe/Amsterdam", false},
+	"FR": {",", nbsp, "02/01/2006", "02/01/2006 15:04 MST", "January 2006", "Europe/Paris", true},
+	"ES": {",", ".", "02/01/2006", "02/01/2006 15:04 MST", "January 2006", "Europe/Madrid", true},
+	"IT": {",", ".", "02/01/2006", "02/01/2006 15:04 MST", "January 2006", "Europe/Rome", true},
+}
+
+// For is the locale of an organisation in countryCode with baseCurrency. Unknown
+// countries get Default's conventions, still with baseCurrency.
+func For(countryCode, baseCurrency string) Locale {
+	code := strings.ToUpper(strings.TrimSpace(countryCode))
+	c, ok := countries[code]
+	if !ok {
+		l := Default
+		l.Currency = strings.ToUpper(baseCurrency)
+		return l
+	}
+	zone, err := time.LoadLocation(c.zone)
+	if err != nil {
+		zone = time.UTC
+	}
+	return Locale{
+		Country:        code,
+		Currency:       strings.ToUpper(baseCurrency),
+		Decimal:        c.decimal,
+		Group:          c.group,
+		DateLayout:     c.date,
+		DateTimeLayout: c.dateTime,
+		MonthLayout:    c.month,
+		Zone:           zone,
+		SymbolAfter:    c.symbolAfter,
+	}
+}
+
+// orDefault fills a zero Locale (a page that was not given one) from Default.
+func (l Locale) orDefault() Locale {
+	if l.Decimal == "" {
+		currency := l.Currency
+		l = Default
+		l.Currency = currency
+	}
+	return l
+}
+
+// currencySymbols are the currencies shown with a symbol; others are shown with
+// their code after the amount.
+var currencySymbols = map[string]string{
+	"GBP": "£",
+	"EUR": "€",
+	"USD": "$",
+	"AUD": "$",
+	"NZD": "$",
+	"CAD": "$",
+}
+
+// Money writes v to two decimals with thousands separators and currency ("£1,234.50",
+// "1,234.50 SEK"; no currency when "").
+func (l Locale) Money(v float64, currency string) string {
+	l = l.orDefault()
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	s := l.number(strconv.FormatFloat(v, 'f', 2, 64), true)
+	currency = strings.ToUpper(currency)
+	if sym, ok := currencySymbols[currency]; ok {
+		if l.SymbolAfter {
+			return sign + s + nbsp + sym
+		}
+		return sign + sym + s
+	}
+	if currency != "" {
+		return sign + s + " " + currency
+	}
+	return sign + s
+}
+
+// Qty writes a quantity: whole numbers without decimals, else up to 3.
+func (l Locale) Qty(v float64) string {
+	if v == math.Trunc(v) {
+		return l.orDefault().number(strconv.FormatFloat(v, 'f', 0, 64), false)
+	}
+	return l.orDefault().number(strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64), false)
+}
+
+// Units writes q in unit u as uom.Format does ("2.5 m"), with l's decimal separator.
+func (l Locale) Units(q float64, u string) string {
+	num, unit, ok := strings.Cut(uom.Format(q, u), " ")
+	num = l.orDefault().number(num, false)
+	if ok {
+		return num + " " + unit
+	}
+	return num
+}
+
+// number rewrites s, a number as strconv writes it, with l's decimal separator and,
+// when group is set, thousands separators.
+func (l Locale) number(s string, group bool) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if group {
+		whole = groupThousands(whole, l.Group)
+	}
+	if hasFrac {
+		return sign + whole + l.Decimal + frac
+	}
+	return sign + whole
+}
+
+// groupThousands puts sep between each group of three digits.
+func groupThousands(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	first := len(digits) % 3
+	if first > 0 {
+		b.WriteString(digits[:first])
+	}
+	for i := first; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+// Date writes t's day in l's zone, "" for the zero time.
+func (l Locale) Date(t time.Time) string {
+	l = l.orDefault()
+	return l.format(t, l.DateLayout)
+}
+
+// DateTime writes t to the minute in l's zone, "" for the zero time.
+func (l Locale) DateTime(t time.Time) string {
+	l = l.orDefault()
+	return l.format(t, l.DateTimeLayout)
+}
+
+// Month writes t's month in l's zone, "" for the zero time.
+func (l Locale) Month(t time.Time) string {
+	l = l.orDefault()
+	return l.format(t, l.MonthLayout)
+}
+
+func (l Locale) format(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	zone := l.Zone
+	if zone == nil {
+		zone = time.UTC
+	}
+	return t.In(zone).Format(layout)
+}
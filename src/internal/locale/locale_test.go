@@ -0,0 +1,83 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMoney(t *testing.T) {
+	t.Parallel()
+	de, fr := For("de", "EUR"), For("FR", "EUR")
+	tests := []struct {
+		l        Locale
+		v        float64
+		currency string
+		want     string
+	}{
+		{Default, 0, "", "0.00"},
+		{Default, 12.5, "GBP", "£12.50"},
+		{Default, 1234567.891, "usd", "$1,234,567.89"},
+		{Default, -1000, "EUR", "-€1,000.00"},
+		{Default, 999.999, "SEK", "1,000.00 SEK"},
+		{Default, 123456, "", "123,456.00"},
+		{Locale{}, 1234.5, "GBP", "£1,234.50"},
+		{For("GB", "GBP"), 1234.5, "GBP", "£1,234.50"},
+		{de, 1234.5, "EUR", "1.234,50\u00a0€"},
+		{de, -1234.5, "CHF", "-1.234,50 CHF"},
+		{fr, 1234567, "EUR", "1\u00a0234\u00a0567,00\u00a0€"},
+		{For("XX", "SEK"), 1234.5, "SEK", "1,234.50 SEK"},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Money(tt.v, tt.currency); got != tt.want {
+			t.Errorf("%s Money(%v, %q) = %q, want %q", tt.l.Country, tt.v, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestQtyAndUnits(t *testing.T) {
+	t.Parallel()
+	for v, want := range map[float64]string{0: "0", 12: "12", 2.5: "2.5", 1.0 / 3: "0.333", -4: "-4", 12345: "12345"} {
+		if got := Default.Qty(v); got != want {
+			t.Errorf("Qty(%v) = %q, want %q", v, got, want)
+		}
+	}
+	nl := For("NL", "EUR")
+	if got := nl.Qty(-2.25); got != "-2,25" {
+		t.Errorf("NL Qty = %q", got)
+	}
+	if got := nl.Units(0.125, "kg"); got != "0,125 kg" {
+		t.Errorf("NL Units = %q", got)
+	}
+	if got := nl.Units(3, "each"); got != "3" {
+		t.Errorf("NL Units each = %q", got)
+	}
+}
+
+func TestTimes(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2025, 7, 9, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		l                     Locale
+		date, dateTime, month string
+	}{
+		{Default, "9 Jul 2025", "2025-07-09 23:30 UTC", "July 2025"},
+		{For("GB", "GBP"), "10 Jul 2025", "10 Jul 2025 00:30 BST", "July 2025"},
+		{For("US", "USD"), "Jul 9, 2025", "Jul 9, 2025 7:30 PM EDT", "July 2025"},
+		{For("AU", "AUD"), "10 Jul 2025", "10 Jul 2025 09:30 AEST", "July 2025"},
+		{For("DE", "EUR"), "10.07.2025", "10.07.2025 01:30 CEST", "July 2025"},
+	}
+	for _, tt := range tests {
+		if got := tt.l.Date(at); got != tt.date {
+			t.Errorf("%s Date = %q, want %q", tt.l.Country, got, tt.date)
+		}
+		if got := tt.l.DateTime(at); got != tt.dateTime {
+			t.Errorf("%s DateTime = %q, want %q", tt.l.Country, got, tt.dateTime)
+		}
+		if got := tt.l.Month(at); got != tt.month {
+			t.Errorf("%s Month = %q, want %q", tt.l.Country, got, tt.month)
+		}
+	}
+	if got := For("GB", "GBP").Date(time.Time{}); got != "" {
+		t.Errorf("zero Date = %q", got)
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	t.Parallel()
+	h := LimitBody(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(r.PostFormValue("a") + string(b)))
+	}))
+	send := func(contentType, body string, chunked bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if chunked {
+			r.ContentLength = -1
+		}
+		r.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+	const form = "application/x-www-form-urlencoded"
+
+	if rec := send(form, "a=1", false); rec.Code != http.StatusOK || rec.Body.String() != "1" {
+		t.Fatalf("small form: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := send(form, "a="+strings.Repeat("x", 20), false); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large form: status %d", rec.Code)
+	}
+	if rec := send(form, "a="+strings.Repeat("x", 20), true); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large chunked form: status %d", rec.Code)
+	}
+	if rec := send(form, "a=%zz", false); rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed form: status %d", rec.Code)
+	}
+	if rec := send("application/json", `{"a":"`+strings.Repeat("x", 20)+`"}`, true); rec.Code != http.StatusBadRequest {
+		t.Fatalf("large chunked JSON: status %d", rec.Code)
+	}
+	if rec := send("multipart/form-data; boundary=x", strings.Repeat("x", 20), false); rec.Code != http.StatusOK {
+		t.Fatalf("multipart: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoding is a content-coding Compress can apply, e.g. Gzip. New wraps w so that
+// what is written to it arrives compressed; Close writes the trailer.
+type Encoding struct {
+	Name string // Content-Encoding token, e.g. "gzip" or "br"
+	New  func(w io.Writer) io.WriteCloser
+}
+
+var gzipPool = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+
+// Gzip is the gzip coding at the default level, with writers reused between responses.
+var Gzip = Encoding{Name: "gzip", New: func(w io.Writer) io.WriteCloser {
+	gz := gzipPool.Get().(*gzip.Writer)
+	gz.Reset(w)
+	return &pooledGzip{gz}
+}}
+
+type pooledGzip struct{ *gzip.Writer }
+
+func (p *pooledGzip) Close() error {
+	err := p.Writer.Close()
+	gzipPool.Put(p.Writer)
+	return err
+}
+
+// compressibleTypes are the media types worth compressing; images, archives and
+// uploads are compressed already.
+var compressibleTypes = map[string]bool{
+	"text/html":              true,
+	"text/csv":               true,
+	"text/plain":             true,
+	"text/css":               true,
+	"text/javascript":        true,
+	"application/javascript": true,
+	"application/json":       true,
+	"image/svg+xml":          true,
+}
+
+// Compress returns middleware that compresses HTML, JSON, CSV and other text
+// responses of at least minSize bytes with the first of encodings the client accepts
+// (Gzip when none are given). Smaller bodies go out as they are: compressing them
+// costs more than it saves. Responses that already set a Content-Encoding are left
+// alone.
+func Compress(minSize int, encodings ...Encoding) func(http.Handler) http.Handler {
+	if len(encodings) == 0 {
+		encodings = []Encoding{Gzip}
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			enc, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), encodings)
+			if !ok || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, enc: enc, minSize: minSize}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the first of encodings that accept (an Accept-Encoding
+// header) allows with a non-zero q value, directly or through "*".
+func negotiateEncoding(accept string, encodings []Encoding) (Encoding, bool) {
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[name] = weight
+	}
+	for _, e := range encodings {
+		w, ok := q[e.Name]
+		if !ok {
+			w, ok = q["*"]
+		}
+		if ok && w > 0 {
+			return e, true
+		}
+	}
+	return Encoding{}, false
+}
+
+// compressWriter holds back the first minSize bytes of a body, then decides whether
+// to compress it from its size, status and Content-Type.
+type compressWriter struct {
+	http.ResponseWriter
+	enc     Encoding
+	minSize int
+
+	status  int
+	buf     []byte
+	decided bool
+	out     io.WriteCloser // the encoder; nil when the body goes out as it is
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	// informational responses go out now and do not settle the real status
+	if status >= 100 && status < 200 {
+		cw.status = 0
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.out != nil {
+		return cw.out.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// decide settles the encoding, sends the header and writes what was held back.
+// Without full (a small body or a flush), the body is only compressed when it is at
+// least minSize anyway.
+func (cw *compressWriter) decide(full bool) error {
+	cw.decided = true
+	h := cw.Header()
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	compressible := compressibleTypes[mediaType]
+	if compressible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if compressible && full && h.Get("Content-Encoding") == "" &&
+		cw.status != http.StatusNoContent && cw.status != http.StatusNotModified && cw.status != http.StatusPartialContent {
+		h.Set("Content-Encoding", cw.enc.Name)
+		h.Del("Content-Length")
+		cw.out = cw.enc.New(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.out != nil {
+		_, err := cw.out.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// close finishes the response once the handler returns.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return // nothing written; net/http sends its own 200
+		}
+		_ = cw.decide(len(cw.buf) >= cw.minSize)
+	}
+	if cw.out != nil {
+		_ = cw.out.Close()
+	}
+}
+
+// Flush sends what has been written so far, compressed when the response is.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(len(cw.buf) >= cw.minSize)
+	}
+	if f, ok := cw.out.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection over, e.g. for websockets; nothing is compressed.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
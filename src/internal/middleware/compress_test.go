@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func compressed(t *testing.T, minSize int, accept string, h http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	rec := httptest.NewRecorder()
+	Compress(minSize)(h).ServeHTTP(rec, req)
+	return rec
+}
+
+func gunzip(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	return string(b)
+}
+
+func TestCompress_LargeTextIsGzipped(t *testing.T) {
+	t.Parallel()
+	body := strings.Repeat("part,qty\nBOLT,10\n", 200)
+	rec := compressed(t, 1024, "br;q=1.0, gzip;q=0.8", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Length", "3400")
+		// written in small pieces, as templates do
+		for i := 0; i < 200; i++ {
+			_, _ = io.WriteString(w, "part,qty\nBOLT,10\n")
+		}
+	})
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Fatal("Content-Length of the uncompressed body kept")
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Vary = %q", rec.Header().Get("Vary"))
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Fatalf("compressed %d bytes into %d", len(body), rec.Body.Len())
+	}
+	if got := gunzip(t, rec); got != body {
+		t.Fatal("body changed by the round trip")
+	}
+}
+
+func TestCompress_LeavesAlone(t *testing.T) {
+	t.Parallel()
+	large := strings.Repeat("x", 4096)
+
+	tests := []struct {
+		name   string
+		accept string
+		h      http.HandlerFunc
+	}{
+		{"small body", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"ok":true}`)
+		}},
+		{"client without gzip", "identity", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, large)
+		}},
+		{"gzip refused", "gzip;q=0, *", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, large)
+		}},
+		{"already compressed type", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = io.WriteString(w, large)
+		}},
+		{"own encoding", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = io.WriteString(w, large)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := compressed(t, 1024, tt.accept, tt.h)
+			if enc := rec.Header().Get("Content-Encoding"); enc == "gzip" {
+				t.Fatal("response was gzipped")
+			}
+			if rec.Body.Len() == 0 {
+				t.Fatal("body lost")
+			}
+		})
+	}
+}
+
+func TestCompress_KeepsStatusAndSniffsType(t *testing.T) {
+	t.Parallel()
+	html := "<!DOCTYPE html><html><body>" + strings.Repeat("<p>row</p>", 300) + "</body></html>"
+	rec := compressed(t, 100, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = io.WriteString(w, html)
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	if got := gunzip(t, rec); got != html {
+		t.Fatal("body changed by the round trip")
+	}
+
+	// a status without a body still goes out
+	rec = compressed(t, 100, "gzip", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("status = %d, headers = %v", rec.Code, rec.Header())
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	t.Parallel()
+	br := Encoding{Name: "br"}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"gzip, deflate, br", "br"},
+		{"gzip", "gzip"},
+		{"GZIP;q=0.5", "gzip"},
+		{"br;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"identity", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := negotiateEncoding(tt.accept, []Encoding{br, Gzip})
+		if got.Name != tt.want || ok != (tt.want != "") {
+			t.Errorf("negotiateEncoding(%q) = %q, %v; want %q", tt.accept, got.Name, ok, tt.want)
+		}
+	}
+}
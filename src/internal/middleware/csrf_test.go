@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func csrfHandler() http.Handler {
+	return CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(CSRFToken(r)))
+	}))
+}
+
+func issuedCSRFToken(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	csrfHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == CSRFCookieName {
+			if c.Value != rec.Body.String() {
+				t.Fatalf("cookie token %q != context token %q", c.Value, rec.Body.String())
+			}
+			return c.Value
+		}
+	}
+	t.Fatal("no csrf cookie set")
+	return ""
+}
+
+func TestCSRF_GetIssuesTokenOnce(t *testing.T) {
+	t.Parallel()
+	token := issuedCSRFToken(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+	rec := httptest.NewRecorder()
+	csrfHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != token {
+		t.Fatalf("got %d %q, want existing token", rec.Code, rec.Body.String())
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatal("valid cookie should not be reissued")
+	}
+}
+
+func TestCSRF_Post(t *testing.T) {
+	t.Parallel()
+	token := issuedCSRFToken(t)
+	other := issuedCSRFToken(t)
+
+	tests := []struct {
+		name   string
+		cookie string
+		build  func() *http.Request
+		want   int
+	}{
+		{"form field", token, func() *http.Request {
+			return formRequest("/", url.Values{CSRFFormField: {token}})
+		}, http.StatusOK},
+		{"header", token, func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.Header.Set(CSRFHeader, token)
+			return r
+		}, http.StatusOK},
+		{"multipart query", token, func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/upload?"+CSRFFormField+"="+token, strings.NewReader(""))
+			r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+			return r
+		}, http.StatusOK},
+		{"bearer client exempt", "", func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			r.Header.Set("Authorization", "Bearer abc")
+			return r
+		}, http.StatusOK},
+		{"xero webhook exempt", "", func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/xero/webhooks", strings.NewReader("{}"))
+			r.Header.Set("X-Xero-Signature", "c2lnbmF0dXJl")
+			return r
+		}, http.StatusOK},
+		{"missing token", token, func() *http.Request {
+			return formRequest("/", url.Values{})
+		}, http.StatusForbidden},
+		{"wrong token", token, func() *http.Request {
+			return formRequest("/", url.Values{CSRFFormField: {other}})
+		}, http.StatusForbidden},
+		{"no cookie", "", func() *http.Request {
+			return formRequest("/", url.Values{CSRFFormField: {token}})
+		}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.build()
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			csrfHandler().ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func formRequest(target string, v url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(v.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
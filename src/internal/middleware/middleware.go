@@ -13,6 +13,9 @@ const (
 	// export keys so handlers can access them via mid.CtxUserID / mid.CtxClaims
 	CtxUserID contextKey = "userID"
 	CtxClaims contextKey = "claims"
+	// CtxWorkspaceID is the workspace the signed-in user is working in, which owns
+	// the data (owner_id) the request reads and writes
+	CtxWorkspaceID contextKey = "workspaceID"
 )
 
 // RequireAuth returns middleware that validates JWT (via auth) and sets claims/userID in context.
@@ -63,6 +66,29 @@ func RequireRole(role string) func(http.Handler) http.Handler {
 	}
 }
 
+// AppRole is the role an admin gave the user in Supabase app_metadata.role ("" if
+// none). Unlike the top-level role claim, users cannot set it themselves.
+func AppRole(claims map[string]interface{}) string {
+	meta, _ := claims["app_metadata"].(map[string]interface{})
+	role, _ := meta["role"].(string)
+	return role
+}
+
+// RequireAppRole returns middleware that requires app_metadata.role (exact match).
+// It runs after RequireAuth.
+func RequireAppRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value(CtxClaims).(map[string]interface{})
+			if claims == nil || AppRole(claims) != role {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // EnsureUserIDInContext ensures CtxUserID (and CtxClaims) are set on the request context when possible.
 // Returns a request with an updated context (original request returned if nothing to add).
 func EnsureUserIDInContext(r *http.Request, auth authpkg.Authenticator) *http.Request {
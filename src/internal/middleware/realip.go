@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets r.RemoteAddr to the client's address when the request came through one
+// of the trusted reverse proxies. Only a trusted peer's X-Forwarded-For and X-Real-IP
+// headers are believed, so with no trusted proxies RemoteAddr stays the peer.
+// X-Forwarded-For is read from the right, past the trusted proxies' own hops: the
+// first address that is not a trusted proxy is the one the nearest proxy saw, while
+// anything left of it may have been sent by the client itself.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(a netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(a) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if peer, ok := remoteAddr(r.RemoteAddr); ok && isTrusted(peer) {
+				if ip, ok := forwardedFor(r.Header.Values("X-Forwarded-For"), isTrusted); ok {
+					r.RemoteAddr = ip.String()
+				} else if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+					r.RemoteAddr = ip.Unmap().String()
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteAddr parses a RemoteAddr, with or without its port.
+func remoteAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	a, err := netip.ParseAddr(s)
+	return a.Unmap(), err == nil
+}
+
+// forwardedFor is the client X-Forwarded-For names: the rightmost address that is
+// not trusted, or the leftmost when every hop is. A malformed hop ends the search,
+// as nothing left of it can be vouched for.
+func forwardedFor(values []string, trusted func(netip.Addr) bool) (netip.Addr, bool) {
+	hops := strings.Split(strings.Join(values, ","), ",")
+	var last netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		a, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		a = a.Unmap()
+		if !trusted(a) {
+			return a, true
+		}
+		last = a
+	}
+	return last, last.IsValid()
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	t.Parallel()
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+	for _, tc := range []struct {
+		name, peer, xff, realIP string
+		want                    string
+	}{
+		{"untrusted peer keeps its address", "203.0.113.9:4000", "198.51.100.1", "198.51.100.2", "203.0.113.9:4000"},
+		{"proxy's forwarded client", "10.0.0.2:4000", "198.51.100.1", "", "198.51.100.1"},
+		{"client's own header is skipped", "10.0.0.2:4000", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"chained proxies", "10.0.0.2:4000", "1.2.3.4, 198.51.100.1, 10.0.0.7", "", "198.51.100.1"},
+		{"every hop a proxy", "10.0.0.2:4000", "10.0.0.8, 10.0.0.7", "", "10.0.0.8"},
+		{"malformed hop", "10.0.0.2:4000", "198.51.100.1, junk", "", "10.0.0.2:4000"},
+		{"X-Real-IP", "10.0.0.2:4000", "", "198.51.100.3", "198.51.100.3"},
+		{"IPv6 proxy", "[fd00::1]:4000", "2001:db8::5", "", "2001:db8::5"},
+		{"no headers", "10.0.0.2:4000", "", "", "10.0.0.2:4000"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			h := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.RemoteAddr }))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.peer
+			if tc.xff != "" {
+				r.Header.Set("X-Forwarded-For", tc.xff)
+			}
+			if tc.realIP != "" {
+				r.Header.Set("X-Real-IP", tc.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if got != tc.want {
+				t.Fatalf("RemoteAddr = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("no trusted proxies", func(t *testing.T) {
+		var got string
+		h := RealIP(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.RemoteAddr }))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "10.0.0.2:4000"
+		r.Header.Set("X-Forwarded-For", "198.51.100.1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if got != "10.0.0.2:4000" {
+			t.Fatalf("RemoteAddr = %q", got)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Order event kinds Events can post.
+const (
+	EventPOCreated        = "po_created"        // purchase orders raised from the shopping list
+	EventBOMUnresolved    = "bom_unresolved"    // an invoice's BOM could not be resolved or grouped by supplier
+	EventConnectionBroken = "connection_broken" // a Xero connection needs reconnecting
+)
+
+// EventKinds lists every event kind.
+var EventKinds = []string{EventPOCreated, EventBOMUnresolved, EventConnectionBroken}
+
+// eventTimeout bounds one background post.
+const eventTimeout = 10 * time.Second
+
+// Events posts order events to a Notifier in the background, so a slow or failing
+// channel never holds up a request. Only enabled kinds are posted, and an event
+// with the same kind and key as one posted within the quiet period is dropped. A nil
+// *Events posts nothing.
+type Events struct {
+	n       Notifier
+	enabled map[string]bool
+	quiet   time.Duration
+	now     func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time // kind+key -> last post
+	wg   sync.WaitGroup
+}
+
+// NewEvents returns Events posting the given kinds to n, dropping repeats within quiet.
+func NewEvents(n Notifier, kinds []string, quiet time.Duration) *Events {
+	enabled := make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		enabled[k] = true
+	}
+	return &Events{n: n, enabled: enabled, quiet: quiet, now: time.Now, last: map[string]time.Time{}}
+}
+
+// Post sends m for an event of kind in the background. key identifies repeats of
+// the same event (e.g. an owner for a broken connection); "" is never a repeat.
+func (e *Events) Post(kind, key string, m Message) {
+	if e == nil || !e.enabled[kind] {
+		return
+	}
+	if key != "" {
+		e.mu.Lock()
+		k := kind + "\x00" + key
+		now := e.now()
+		if t, ok := e.last[k]; ok && now.Sub(t) < e.quiet {
+			e.mu.Unlock()
+			return
+		}
+		e.last[k] = now
+		e.mu.Unlock()
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), eventTimeout)
+		defer cancel()
+		if err := e.n.Notify(ctx, m); err != nil {
+			log.Printf("notify %s: %v", kind, err)
+		}
+	}()
+}
+
+// Wait blocks until every posted event has been sent or has failed.
+func (e *Events) Wait() {
+	if e != nil {
+		e.wg.Wait()
+	}
+}
@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Message is one notification: a short subject line and a plain text body.
+type Message struct {
+	Subject string
+	Text    string
+}
+
+// Notifier delivers operational notifications (reminders, alerts) to people.
+type Notifier interface {
+	Notify(ctx context.Context, m Message) error
+}
+
+// NewSlack returns a Notifier that posts to a Slack incoming webhook.
+func NewSlack(webhookURL string, client *http.Client) Notifier {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &slackNotifier{url: webhookURL, client: client}
+}
+
+type slackNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackNotifier) Notify(ctx context.Context, m Message) error {
+	text := m.Text
+	if m.Subject != "" {
+		text = "*" + m.Subject + "*\n" + m.Text
+	}
+	return postJSON(ctx, s.client, s.url, "slack webhook", map[string]string{"text": text})
+}
+
+// postJSON posts v to a webhook, failing on any non-2xx response.
+func postJSON(ctx context.Context, client *http.Client, url, name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: status=%d body=%s", name, resp.StatusCode, body)
+	}
+	return nil
+}
+
+// NewTeams returns a Notifier that posts to a Microsoft Teams incoming webhook.
+func NewTeams(webhookURL string, client *http.Client) Notifier {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &teamsNotifier{url: webhookURL, client: client}
+}
+
+type teamsNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (t *teamsNotifier) Notify(ctx context.Context, m Message) error {
+	// Teams renders the text as markdown, where a single newline is not a line break
+	card := map[string]string{
+		"@type":   "MessageCard",
+		"summary": m.Subject,
+		"title":   m.Subject,
+		"text":    strings.ReplaceAll(m.Text, "\n", "\n\n"),
+	}
+	return postJSON(ctx, t.client, t.url, "teams webhook", card)
+}
+
+// EmailConfig is the SMTP server and addresses an email Notifier uses.
+type EmailConfig struct {
+	Addr     string // host:port
+	Username string // empty sends without auth
+	Password string
+	From     string
+	To       []string
+}
+
+// NewEmail returns a Notifier that sends plain text email over SMTP (STARTTLS when
+// the server offers it).
+func NewEmail(cfg EmailConfig) Notifier {
+	return &emailNotifier{cfg: cfg, send: smtp.SendMail}
+}
+
+type emailNotifier struct {
+	cfg  EmailConfig
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error // smtp.SendMail; replaced in tests
+}
+
+func (e *emailNotifier) Notify(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(e.cfg.Addr)
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, host)
+	}
+	if err := e.send(e.cfg.Addr, auth, e.cfg.From, e.cfg.To, emailMessage(e.cfg.From, e.cfg.To, m)); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
+
+// emailMessage is m as an RFC 5322 message with CRLF line endings.
+func emailMessage(from string, to []string, m Message) []byte {
+	// header values must not carry line breaks
+	oneLine := strings.NewReplacer("\r", " ", "\n", " ")
+	var b strings.Builder
+	b.WriteString("From: " + oneLine.Replace(from) + "\r\n")
+	b.WriteString("To: " + oneLine.Replace(strings.Join(to, ", ")) + "\r\n")
+	b.WriteString("Subject: " + oneLine.Replace(m.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Text, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// Multi sends every message to each of its Notifiers, returning their errors joined.
+type Multi []Notifier
+
+func (ms Multi) Notify(ctx context.Context, m Message) error {
+	var errs []error
+	for _, n := range ms {
+		if err := n.Notify(ctx, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlack(t *testing.T) {
+	t.Parallel()
+	var got map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("content type %q", r.Header.Get("Content-Type"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if got["text"] == "fail" {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	n := NewSlack(ts.URL, ts.Client())
+
+	if err := n.Notify(context.Background(), Message{Subject: "2 POs outstanding", Text: "PO-0001"}); err != nil {
+		t.Fatal(err)
+	}
+	if got["text"] != "*2 POs outstanding*\nPO-0001" {
+		t.Fatalf("text = %q", got["text"])
+	}
+	if err := n.Notify(context.Background(), Message{Text: "fail"}); err == nil || !strings.Contains(err.Error(), "status=400") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	t.Parallel()
+	var got map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	t.Cleanup(ts.Close)
+	if err := NewTeams(ts.URL, ts.Client()).Notify(context.Background(), Message{Subject: "PO created", Text: "ACME\nFAST"}); err != nil {
+		t.Fatal(err)
+	}
+	if got["title"] != "PO created" || got["text"] != "ACME\n\nFAST" || got["@type"] != "MessageCard" {
+		t.Fatalf("card = %v", got)
+	}
+}
+
+func TestEmail(t *testing.T) {
+	t.Parallel()
+	var addr, from string
+	var to []string
+	var msg []byte
+	var auth smtp.Auth
+	n := &emailNotifier{
+		cfg: EmailConfig{Addr: "smtp.example.com:587", Username: "u", Password: "p", From: "orders@example.com", To: []string{"a@example.com", "b@example.com"}},
+		send: func(a string, au smtp.Auth, f string, t []string, m []byte) error {
+			addr, auth, from, to, msg = a, au, f, t, m
+			return nil
+		},
+	}
+	if err := n.Notify(context.Background(), Message{Subject: "POs\r\nBcc: x@example.com", Text: "line 1\nline 2"}); err != nil {
+		t.Fatal(err)
+	}
+	if addr != "smtp.example.com:587" || auth == nil || from != "orders@example.com" || len(to) != 2 {
+		t.Fatalf("sent to %s as %s to %v (auth %v)", addr, from, to, auth)
+	}
+	want := "To: a@example.com, b@example.com\r\nSubject: POs  Bcc: x@example.com\r\n"
+	if !strings.Contains(string(msg), want) || !strings.HasSuffix(string(msg), "\r\n\r\nline 1\r\nline 2\r\n") {
+		t.Fatalf("message:\n%q", msg)
+	}
+}
+
+type notifyFunc func(ctx context.Context, m Message) error
+
+func (f notifyFunc) Notify(ctx context.Context, m Message) error { return f(ctx, m) }
+
+func TestMulti(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	ok := notifyFunc(func(context.Context, Message) error { calls++; return nil })
+	bad := notifyFunc(func(context.Context, Message) error { calls++; return errors.New("down") })
+	if err := (Multi{bad, ok}).Notify(context.Background(), Message{}); err == nil || err.Error() != "down" || calls != 2 {
+		t.Fatalf("err=%v calls=%d; want the failure reported and both called", err, calls)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var sent []string
+	n := notifyFunc(func(_ context.Context, m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, m.Subject)
+		return nil
+	})
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	e := NewEvents(n, []string{EventPOCreated, EventConnectionBroken}, time.Hour)
+	e.now = func() time.Time { return now }
+
+	e.Post(EventPOCreated, "", Message{Subject: "po 1"})
+	e.Post(EventPOCreated, "", Message{Subject: "po 2"})
+	e.Post(EventBOMUnresolved, "INV-1", Message{Subject: "disabled"})
+	e.Post(EventConnectionBroken, "owner-1", Message{Subject: "broken 1"})
+	e.Post(EventConnectionBroken, "owner-1", Message{Subject: "repeat"})
+	e.Post(EventConnectionBroken, "owner-2", Message{Subject: "broken 2"})
+	now = now.Add(time.Hour)
+	e.Post(EventConnectionBroken, "owner-1", Message{Subject: "broken again"})
+	e.Wait()
+
+	sort.Strings(sent)
+	if want := []string{"broken 1", "broken 2", "broken again", "po 1", "po 2"}; !reflect.DeepEqual(sent, want) {
+		t.Fatalf("sent %q, want %q", sent, want)
+	}
+
+	var nilEvents *Events
+	nilEvents.Post(EventPOCreated, "", Message{})
+	nilEvents.Wait()
+}
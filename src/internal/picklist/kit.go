@@ -0,0 +1,137 @@
+package picklist
+
+import (
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+// kitCols are the kit list column widths (mm): code, name, qty per unit, qty for
+// all units, picked.
+var kitCols = []float64{40, 86, 24, 24, 12}
+
+// KitSection is one assembly of the invoice with what goes into a single unit of it,
+// so a kit can be picked per unit. Sub-assemblies get their own section after the
+// assembly they go into.
+type KitSection struct {
+	PartID string
+	Name   string
+	Path   []string // assembly codes from the invoice line down to PartID
+	Units  float64  // how many of the assembly the invoice needs
+	Lines  []KitLine
+}
+
+// Parents are the assemblies the section's assembly goes into, outermost first.
+func (s KitSection) Parents() []string {
+	if len(s.Path) < 2 {
+		return nil
+	}
+	return s.Path[:len(s.Path)-1]
+}
+
+// KitLine is one part or sub-assembly in a KitSection.
+type KitLine struct {
+	PartID     string
+	Name       string
+	UOM        string
+	PerUnit    float64 // for one unit of the section's assembly
+	Total      float64 // for all Units
+	IsAssembly bool    // kitted in its own section
+}
+
+// LooseItemsName names the section of invoice lines that are bought whole rather
+// than built.
+const LooseItemsName = "Loose items"
+
+// KitSections splits the per-assembly BOM (service.BuildPerAssemblyBOM) into one
+// section per assembly, depth-first, each listing its direct children per unit.
+// Invoice lines that are not assemblies are gathered in a last, code-less section
+// named LooseItemsName with a single unit.
+func KitSections(perAssy []service.BOMNode) []KitSection {
+	var out []KitSection
+	var walk func(n service.BOMNode, path []string, units float64)
+	walk = func(n service.BOMNode, path []string, units float64) {
+		path = append(path[:len(path):len(path)], n.PartID)
+		s := KitSection{PartID: n.PartID, Name: n.Name, Path: path, Units: units}
+		for _, ch := range n.Children {
+			s.Lines = append(s.Lines, KitLine{
+				PartID:     ch.PartID,
+				Name:       ch.Name,
+				UOM:        ch.UOM,
+				PerUnit:    ch.Quantity,
+				Total:      roundQty(units * ch.Quantity),
+				IsAssembly: ch.IsAssembly,
+			})
+		}
+		out = append(out, s)
+		for _, ch := range n.Children {
+			if ch.IsAssembly {
+				walk(ch, path, roundQty(units*ch.Quantity))
+			}
+		}
+	}
+
+	loose := KitSection{Name: LooseItemsName, Units: 1}
+	for _, r := range perAssy {
+		if r.IsAssembly {
+			walk(r, nil, r.Quantity)
+			continue
+		}
+		loose.Lines = append(loose.Lines, KitLine{PartID: r.PartID, Name: r.Name, UOM: r.UOM, PerUnit: r.Quantity, Total: r.Quantity})
+	}
+	if len(loose.Lines) > 0 {
+		out = append(out, loose)
+	}
+	return out
+}
+
+// roundQty drops floating point noise from multiplied quantities (4 decimal places,
+// as Xero keeps).
+func roundQty(v float64) float64 { return math.Round(v*10000) / 10000 }
+
+// RenderKit writes the kit list PDF for invoiceNumber: one section per assembly
+// (see KitSections) with a tick box per line. A section starts on a new page when
+// its heading and first rows would not fit.
+func RenderKit(w io.Writer, invoiceNumber string, sections []KitSection, generated time.Time) error {
+	pdf := newDocument("Kit list", invoiceNumber, generated)
+	_, pageHeight := pdf.GetPageSize()
+
+	for _, s := range sections {
+		if pdf.GetY()+8+4*rowHeight > pageHeight-pageMargin-6 {
+			pdf.AddPage()
+		}
+		title := s.Name
+		if s.PartID != "" {
+			title = s.PartID
+			if s.Name != "" {
+				title += " – " + s.Name
+			}
+			title += "  ×" + FormatQty(s.Units)
+		}
+		section(pdf, title)
+		if parents := s.Parents(); len(parents) > 0 {
+			pdf.SetFont(fontFamily, "", 8)
+			pdf.CellFormat(0, 4, "in "+strings.Join(parents, " › "), "", 1, "L", false, 0, "")
+		}
+		header(pdf, kitCols, []string{"Item code", "Name", "Per unit", "Total", ""})
+		for _, l := range s.Lines {
+			style := ""
+			if l.IsAssembly {
+				style = "B"
+			}
+			pdf.SetFont(fontFamily, style, 9)
+			cell(pdf, kitCols[0], fit(pdf, l.PartID, kitCols[0]), "L")
+			cell(pdf, kitCols[1], fit(pdf, l.Name, kitCols[1]), "L")
+			cell(pdf, kitCols[2], formatUnits(l.PerUnit, l.UOM), "R")
+			cell(pdf, kitCols[3], formatUnits(l.Total, l.UOM), "R")
+			tickBox(pdf, kitCols[4], !l.IsAssembly)
+			pdf.Ln(rowHeight)
+		}
+		pdf.Ln(4)
+	}
+
+	return pdf.Output(w)
+}
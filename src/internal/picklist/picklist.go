@@ -0,0 +1,180 @@
+// Package picklist renders a resolved invoice BOM as a printable PDF pick list for
+// the workshop floor. Fonts are embedded in the binary; nothing is fetched at runtime.
+package picklist
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+	"github.com/hwalton/xero-invoice-orderer/internal/uom"
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	fontFamily = "Go"
+
+	pageMargin = 12.0 // mm
+	rowHeight  = 6.0
+	boxSize    = 3.5
+	indentStep = 4.0 // mm per BOM level
+)
+
+// table column widths (mm); A4 portrait leaves 186mm between margins
+var (
+	bomCols  = []float64{14, 40, 96, 24, 12} // level, code, name, qty/assy, picked
+	leafCols = []float64{40, 110, 24, 12}    // code, name, qty, picked
+)
+
+// Line is one row of the flattened BOM tree.
+type Line struct {
+	Level      int
+	PartID     string
+	Name       string
+	Quantity   float64
+	UOM        string
+	IsAssembly bool
+}
+
+// Flatten walks the per-assembly BOM depth-first; roots are level 0.
+func Flatten(perAssy []service.BOMNode) []Line {
+	var out []Line
+	var walk func(n service.BOMNode, level int)
+	walk = func(n service.BOMNode, level int) {
+		out = append(out, Line{Level: level, PartID: n.PartID, Name: n.Name, Quantity: n.Quantity, UOM: n.UOM, IsAssembly: n.IsAssembly})
+		for _, ch := range n.Children {
+			walk(ch, level+1)
+		}
+	}
+	for _, r := range perAssy {
+		walk(r, 0)
+	}
+	return out
+}
+
+// FormatQty prints whole quantities without decimals and others to at most 3 places.
+func FormatQty(q float64) string {
+	s := strconv.FormatFloat(q, 'f', 3, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// formatUnits is FormatQty followed by the unit of measure, unless it is each.
+func formatUnits(q float64, u string) string {
+	if u = uom.Normalize(u); u != uom.Each {
+		return FormatQty(q) + " " + u
+	}
+	return FormatQty(q)
+}
+
+// Render writes the pick list PDF for invoiceNumber: the per-assembly BOM tree
+// followed by the aggregated leaf totals, each row with a tick box.
+func Render(w io.Writer, invoiceNumber string, perAssy []service.BOMNode, leafTotals []service.LeafTotal, generated time.Time) error {
+	pdf := newDocument("Pick list", invoiceNumber, generated)
+
+	// BOM breakdown
+	section(pdf, "BOM breakdown (qty per assembly)")
+	header(pdf, bomCols, []string{"Level", "Item code", "Name", "Qty/assy", ""})
+	for _, l := range Flatten(perAssy) {
+		style := ""
+		if l.IsAssembly {
+			style = "B"
+		}
+		pdf.SetFont(fontFamily, style, 9)
+		indent := float64(l.Level) * indentStep
+		cell(pdf, bomCols[0], strconv.Itoa(l.Level), "C")
+		cell(pdf, bomCols[1], fit(pdf, l.PartID, bomCols[1]), "L")
+		pdf.SetX(pdf.GetX() + indent)
+		cell(pdf, bomCols[2]-indent, fit(pdf, l.Name, bomCols[2]-indent), "L")
+		cell(pdf, bomCols[3], formatUnits(l.Quantity, l.UOM), "R")
+		tickBox(pdf, bomCols[4], !l.IsAssembly)
+		pdf.Ln(rowHeight)
+	}
+	pdf.Ln(4)
+
+	// aggregated totals for purchasable parts
+	section(pdf, "Pick totals")
+	header(pdf, leafCols, []string{"Item code", "Name", "Qty", ""})
+	pdf.SetFont(fontFamily, "", 9)
+	for _, lt := range leafTotals {
+		cell(pdf, leafCols[0], fit(pdf, lt.PartID, leafCols[0]), "L")
+		cell(pdf, leafCols[1], fit(pdf, lt.Name, leafCols[1]), "L")
+		cell(pdf, leafCols[2], formatUnits(lt.Quantity, lt.UOM), "R")
+		tickBox(pdf, leafCols[3], true)
+		pdf.Ln(rowHeight)
+	}
+
+	return pdf.Output(w)
+}
+
+// newDocument starts an A4 document with the embedded fonts, a page footer and a
+// first page headed "<title>: invoice <number>" and the generation time.
+func newDocument(title, invoiceNumber string, generated time.Time) *gofpdf.Fpdf {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin+6)
+	pdf.SetTitle(title+" "+invoiceNumber, true)
+	pdf.SetCreationDate(generated)
+	pdf.SetCatalogSort(true)
+	pdf.AliasNbPages("{nb}")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pageMargin - 2)
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.CellFormat(0, 4, fmt.Sprintf("Invoice %s  ·  page %d/{nb}", invoiceNumber, pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+	pdf.SetFont(fontFamily, "B", 16)
+	pdf.CellFormat(0, 9, title+": invoice "+invoiceNumber, "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 9)
+	pdf.CellFormat(0, 5, "Generated "+generated.Format("2006-01-02 15:04 MST"), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+	return pdf
+}
+
+func section(pdf *gofpdf.Fpdf, title string) {
+	pdf.SetFont(fontFamily, "B", 12)
+	pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+}
+
+func header(pdf *gofpdf.Fpdf, widths []float64, titles []string) {
+	pdf.SetFont(fontFamily, "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for i, t := range titles {
+		pdf.CellFormat(widths[i], rowHeight, t, "B", 0, "L", true, 0, "")
+	}
+	pdf.Ln(rowHeight)
+}
+
+func cell(pdf *gofpdf.Fpdf, w float64, txt, align string) {
+	pdf.CellFormat(w, rowHeight, txt, "", 0, align, false, 0, "")
+}
+
+// tickBox reserves a column of width w and draws an empty box in it when show is set.
+func tickBox(pdf *gofpdf.Fpdf, w float64, show bool) {
+	x, y := pdf.GetXY()
+	if show {
+		pdf.Rect(x+(w-boxSize)/2, y+(rowHeight-boxSize)/2, boxSize, boxSize, "D")
+	}
+	pdf.SetX(x + w)
+}
+
+// fit shortens s with an ellipsis so it fits in a column of width w.
+func fit(pdf *gofpdf.Fpdf, s string, w float64) string {
+	max := w - 2*pdf.GetCellMargin()
+	if pdf.GetStringWidth(s) <= max {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdf.GetStringWidth(string(r)+"…") > max {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
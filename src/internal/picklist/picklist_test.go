@@ -0,0 +1,109 @@
+package picklist
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/service"
+)
+
+func TestFlatten(t *testing.T) {
+	t.Parallel()
+	perAssy := []service.BOMNode{{
+		PartID: "VAN-001", Name: "Van", Quantity: 2, IsAssembly: true,
+		Children: []service.BOMNode{
+			{PartID: "FRAME", Quantity: 1, IsAssembly: true, Children: []service.BOMNode{{PartID: "BOLT", Quantity: 8}}},
+			{PartID: "WHEEL", Quantity: 4},
+			{PartID: "CABLE", Quantity: 2.5, UOM: "m"},
+		},
+	}}
+	got := Flatten(perAssy)
+	want := []Line{
+		{Level: 0, PartID: "VAN-001", Name: "Van", Quantity: 2, IsAssembly: true},
+		{Level: 1, PartID: "FRAME", Quantity: 1, IsAssembly: true},
+		{Level: 2, PartID: "BOLT", Quantity: 8},
+		{Level: 1, PartID: "WHEEL", Quantity: 4},
+		{Level: 1, PartID: "CABLE", Quantity: 2.5, UOM: "m"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFormatQty(t *testing.T) {
+	t.Parallel()
+	cases := map[float64]string{4: "4", 0.5: "0.5", 1.0 / 3: "0.333", 12.25: "12.25", 0: "0"}
+	for in, want := range cases {
+		if got := FormatQty(in); got != want {
+			t.Fatalf("FormatQty(%v) = %q want %q", in, got, want)
+		}
+	}
+	if got := formatUnits(2.5, "m"); got != "2.5 m" {
+		t.Fatalf("formatUnits(2.5, m) = %q", got)
+	}
+	if got := formatUnits(3, ""); got != "3" {
+		t.Fatalf("formatUnits(3, \"\") = %q", got)
+	}
+}
+
+func TestRender_WritesPDF(t *testing.T) {
+	t.Parallel()
+	perAssy := []service.BOMNode{{PartID: "VAN-001", Name: "Camper van – long wheelbase", Quantity: 1, IsAssembly: true}}
+	var leaves []service.LeafTotal
+	for i := 0; i < 80; i++ {
+		id := fmt.Sprintf("P-%03d", i)
+		perAssy[0].Children = append(perAssy[0].Children, service.BOMNode{PartID: id, Name: strings.Repeat("long name ", 20), Quantity: 2})
+		leaves = append(leaves, service.LeafTotal{PartID: id, Name: "part " + id, Quantity: 2})
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, "INV-0001", perAssy, leaves, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-") {
+		t.Fatalf("output is not a PDF: %q", out[:min(len(out), 16)])
+	}
+	// 160 rows do not fit on one A4 page
+	if n := strings.Count(out, "/Type /Page\n"); n < 2 {
+		t.Fatalf("expected several pages, got %d", n)
+	}
+}
+
+func TestKitSections(t *testing.T) {
+	t.Parallel()
+	perAssy := []service.BOMNode{
+		{PartID: "VAN", Name: "Van", Quantity: 2, IsAssembly: true, Children: []service.BOMNode{
+			{PartID: "FRAME", Quantity: 1.5, IsAssembly: true, Children: []service.BOMNode{{PartID: "BOLT", Quantity: 8}}},
+			{PartID: "CABLE", Quantity: 2.5, UOM: "m"},
+		}},
+		{PartID: "MANUAL", Name: "Manual", Quantity: 1},
+	}
+	got := KitSections(perAssy)
+	want := []KitSection{
+		{PartID: "VAN", Name: "Van", Path: []string{"VAN"}, Units: 2, Lines: []KitLine{
+			{PartID: "FRAME", PerUnit: 1.5, Total: 3, IsAssembly: true},
+			{PartID: "CABLE", UOM: "m", PerUnit: 2.5, Total: 5},
+		}},
+		{PartID: "FRAME", Path: []string{"VAN", "FRAME"}, Units: 3, Lines: []KitLine{{PartID: "BOLT", PerUnit: 8, Total: 24}}},
+		{Name: LooseItemsName, Units: 1, Lines: []KitLine{{PartID: "MANUAL", Name: "Manual", PerUnit: 1, Total: 1}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+	if p := got[1].Parents(); !reflect.DeepEqual(p, []string{"VAN"}) {
+		t.Fatalf("Parents = %v", p)
+	}
+
+	var buf bytes.Buffer
+	if err := RenderKit(&buf, "INV-0001", got, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatalf("RenderKit: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "%PDF-") {
+		t.Fatal("output is not a PDF")
+	}
+}
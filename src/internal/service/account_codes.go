@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxAccountCodeLen is the longest account code Xero accepts.
+const maxAccountCodeLen = 10
+
+// NormalizeAccountCode trims code and checks it can be a Xero account code: at most
+// ten letters, digits, '-' or '.'. "" means no code.
+func NormalizeAccountCode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) > maxAccountCodeLen {
+		return "", invalid("account_code", "account code %q is longer than %d characters", code, maxAccountCodeLen)
+	}
+	for _, c := range code {
+		if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '-' || c == '.') {
+			return "", invalid("account_code", "invalid account code %q", code)
+		}
+	}
+	return code, nil
+}
+
+// GetItemAccountCodes returns the default account codes (item_account_codes) of
+// itemIDs that have one.
+func GetItemAccountCodes(ctx context.Context, dbURL string, itemIDs []string) (map[string]string, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	out := map[string]string{}
+	if len(itemIDs) == 0 {
+		return out, nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `SELECT item_id, account_code FROM item_account_codes WHERE item_id = ANY($1)`, itemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query item account codes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, code string
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, fmt.Errorf("scan item account code: %w", err)
+		}
+		out[id] = code
+	}
+	return out, rows.Err()
+}
+
+// SetItemAccountCodes stores item ID -> default account code; "" removes an item's
+// default. Codes must already be normalized.
+func SetItemAccountCodes(ctx context.Context, dbURL string, codes map[string]string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	ids := slices.Sorted(maps.Keys(codes))
+	b := &pgx.Batch{}
+	for _, id := range ids {
+		if code := codes[id]; code == "" {
+			b.Queue(`DELETE FROM item_account_codes WHERE item_id = $1`, id)
+		} else {
+			b.Queue(`
+INSERT INTO item_account_codes (item_id, account_code) VALUES ($1, $2)
+ON CONFLICT (item_id) DO UPDATE SET account_code = EXCLUDED.account_code
+`, id, code)
+		}
+	}
+	return WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		return execBatch(ctx, tx, b, func(i int) string { return "save account code for " + ids[i] })
+	})
+}
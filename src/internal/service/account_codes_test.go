@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeAccountCode(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{"": "", " 429 ": "429", "5000-A.1": "5000-A.1"} {
+		if got, err := NormalizeAccountCode(in); err != nil || got != want {
+			t.Errorf("NormalizeAccountCode(%q) = %q, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"12345678901", "4 29", "429;"} {
+		if _, err := NormalizeAccountCode(in); err == nil {
+			t.Errorf("NormalizeAccountCode(%q): expected error", in)
+		}
+	}
+}
+
+func TestItemAccountCodes_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := GetItemAccountCodes(context.Background(), "", []string{"BOLT"}); err == nil {
+		t.Fatal("expected error for empty db url")
+	}
+	if err := SetItemAccountCodes(context.Background(), "", map[string]string{"BOLT": "429"}); err == nil {
+		t.Fatal("expected error for empty db url")
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APICallRetention is how long api_call_log rows are kept.
+const APICallRetention = 7 * 24 * time.Hour
+
+// apiCallInserts counts RecordAPICall calls so old rows are pruned now and then.
+var apiCallInserts atomic.Int64
+
+// RecordAPICall stores one Xero call observed by xero.DebugTransport in
+// api_call_log. Every 100th call also drops rows older than APICallRetention.
+func RecordAPICall(ctx context.Context, dbURL, ownerID, requestID string, call xero.APICall) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Exec(ctx, `
+INSERT INTO api_call_log (owner_id, request_id, tenant_id, method, url, status, latency_ms, error, response_body, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`, ownerID, requestID, call.TenantID, call.Method, call.URL, call.Status, call.Latency.Milliseconds(), call.Err, call.ResponseBody, call.At.Unix())
+	if err != nil {
+		return fmt.Errorf("insert api_call_log: %w", err)
+	}
+	if apiCallInserts.Add(1)%100 == 0 {
+		if _, err := pool.Exec(ctx, `DELETE FROM api_call_log WHERE created_at < $1`, time.Now().Add(-APICallRetention).Unix()); err != nil {
+			return fmt.Errorf("prune api_call_log: %w", err)
+		}
+	}
+	return nil
+}
+
+// APICallError is an api_call_log row of a Xero call that failed: no response, or
+// an error status.
+type APICallError struct {
+	At           int64
+	OwnerID      string
+	RequestID    string
+	TenantID     string
+	Method       string
+	URL          string
+	Status       int // 0 when no response was received
+	LatencyMS    int
+	Error        string
+	ResponseBody string
+}
+
+// ListAPICallErrors returns up to limit of the newest failed Xero calls in
+// api_call_log, only the tenant's unless tenantID is "". Calls are only recorded
+// with XERO_DEBUG=db.
+func ListAPICallErrors(ctx context.Context, dbURL, tenantID string, limit int) ([]APICallError, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT COALESCE(created_at, 0), owner_id, request_id, tenant_id, method, url, status, latency_ms, error, response_body
+FROM api_call_log
+WHERE (status = 0 OR status >= 400 OR error <> '') AND ($1 = '' OR tenant_id = $1)
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`, tenantID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query api_call_log: %w", err)
+	}
+	defer rows.Close()
+	var out []APICallError
+	for rows.Next() {
+		var e APICallError
+		if err := rows.Scan(&e.At, &e.OwnerID, &e.RequestID, &e.TenantID, &e.Method, &e.URL, &e.Status, &e.LatencyMS, &e.Error, &e.ResponseBody); err != nil {
+			return nil, fmt.Errorf("scan api_call_log: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Attachment kinds.
+const (
+	AttachmentImage     = "image"
+	AttachmentDatasheet = "datasheet"
+)
+
+// PartAttachment is an image or datasheet a workspace attached to a part (Xero Item
+// Code). Other workspaces do not see it.
+type PartAttachment struct {
+	ID          int    `json:"id"`
+	WorkspaceID string `json:"workspace_id"`
+	ItemID      string `json:"item_id"`
+	Kind        string `json:"kind"`
+	StorageKey  string `json:"storage_key"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	UploadedBy  string `json:"uploaded_by"` // user id
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// AttachmentKindFor returns the attachment kind for a sniffed content type, or "" if
+// the type is not accepted (images and PDFs only).
+func AttachmentKindFor(contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return AttachmentImage
+	case contentType == "application/pdf":
+		return AttachmentDatasheet
+	default:
+		return ""
+	}
+}
+
+// AttachmentStorageKey builds the object key for a new attachment. unique should be
+// random per upload so re-uploading a file with the same name never overwrites.
+func AttachmentStorageKey(itemID, unique, filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	if name == "" || name == "." || name == ".." || name == "_" {
+		name = "file"
+	}
+	item := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, itemID)
+	return fmt.Sprintf("parts/%s/%s-%s", item, unique, name)
+}
+
+// CreatePartAttachment inserts an attachment row and returns its id.
+func CreatePartAttachment(ctx context.Context, dbURL string, a PartAttachment) (int, error) {
+	if dbURL == "" {
+		return 0, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return 0, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var id int
+	err = pool.QueryRow(ctx, `
+INSERT INTO part_attachments (workspace_id, item_id, kind, storage_key, filename, content_type, size_bytes, uploaded_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id
+`, a.WorkspaceID, a.ItemID, a.Kind, a.StorageKey, a.Filename, a.ContentType, a.SizeBytes, a.UploadedBy).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("insert part_attachment: %w", err)
+	}
+	return id, nil
+}
+
+// GetPartAttachment returns one of the workspace's attachments (nil if it has no
+// such attachment).
+func GetPartAttachment(ctx context.Context, dbURL, workspaceID string, id int) (*PartAttachment, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var a PartAttachment
+	err = pool.QueryRow(ctx, `
+SELECT id, workspace_id, item_id, kind, storage_key, filename, content_type, size_bytes, uploaded_by, created_at
+FROM part_attachments WHERE workspace_id = $1 AND id = $2
+`, workspaceID, id).Scan(&a.ID, &a.WorkspaceID, &a.ItemID, &a.Kind, &a.StorageKey, &a.Filename, &a.ContentType, &a.SizeBytes, &a.UploadedBy, &a.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query part_attachment: %w", err)
+	}
+	return &a, nil
+}
+
+// ListPartAttachments returns the workspace's attachments for the given item codes
+// keyed by item code, images first then newest first.
+func ListPartAttachments(ctx context.Context, dbURL, workspaceID string, itemIDs []string) (map[string][]PartAttachment, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	out := map[string][]PartAttachment{}
+	if len(itemIDs) == 0 {
+		return out, nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT id, workspace_id, item_id, kind, storage_key, filename, content_type, size_bytes, uploaded_by, created_at
+FROM part_attachments
+WHERE workspace_id = $1 AND item_id = ANY($2)
+ORDER BY item_id, (kind = 'image') DESC, created_at DESC, id DESC
+`, workspaceID, itemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query part_attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a PartAttachment
+		if err := rows.Scan(&a.ID, &a.WorkspaceID, &a.ItemID, &a.Kind, &a.StorageKey, &a.Filename, &a.ContentType, &a.SizeBytes, &a.UploadedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan part_attachment: %w", err)
+		}
+		out[a.ItemID] = append(out[a.ItemID], a)
+	}
+	return out, rows.Err()
+}
+
+// DeletePartAttachment removes one of the workspace's attachment rows. The caller
+// deletes the stored object.
+func DeletePartAttachment(ctx context.Context, dbURL, workspaceID string, id int) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `DELETE FROM part_attachments WHERE workspace_id = $1 AND id = $2`, workspaceID, id); err != nil {
+		return fmt.Errorf("delete part_attachment: %w", err)
+	}
+	return nil
+}
+
+// BOMPartIDs returns the distinct part ids in a BOM tree.
+func BOMPartIDs(nodes []BOMNode) []string {
+	seen := map[string]bool{}
+	var out []string
+	var walk func(ns []BOMNode)
+	walk = func(ns []BOMNode) {
+		for _, n := range ns {
+			if !seen[n.PartID] {
+				seen[n.PartID] = true
+				out = append(out, n.PartID)
+			}
+			walk(n.Children)
+		}
+	}
+	walk(nodes)
+	return out
+}
+
+// AnnotateBOMAttachments sets Attachments on every node of the tree from byItem.
+func AnnotateBOMAttachments(nodes []BOMNode, byItem map[string][]PartAttachment) {
+	for i := range nodes {
+		nodes[i].Attachments = byItem[nodes[i].PartID]
+		AnnotateBOMAttachments(nodes[i].Children, byItem)
+	}
+}
+
+// ItemDetail is the local catalog data for one part.
+type ItemDetail struct {
+	ItemID      string
+	Suppliers   []string        // items_contacts.contact_id (Xero AccountNumber)
+	Archived    []string        // suppliers whose mapping is archived
+	Preferred   string          // the preferred supplier, "" for none
+	Prices      []SupplierPrice // price list entries, latest first (at most itemPriceHistory)
+	Children    []ItemRelation
+	Parents     []ItemRelation
+	Attachments []PartAttachment
+
+	Unavailable   bool             // parts.unavailable: offer Substitutes instead
+	Substitutes   []ItemSubstitute // what can be ordered in its place, best first
+	SubstituteFor []string         // items it can be ordered in place of
+}
+
+// itemPriceHistory is how many price list entries the item page shows.
+const itemPriceHistory = 20
+
+// ItemRelation is a parent_child edge seen from one side.
+type ItemRelation struct {
+	ItemID   string
+	Quantity float64
+}
+
+// GetItemDetail loads the owner's supplier mappings and BOM relations, and the
+// attachments, for an item code.
+func GetItemDetail(ctx context.Context, dbURL, ownerID, itemID string) (*ItemDetail, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	d := &ItemDetail{ItemID: itemID}
+
+	rows, err := pool.Query(ctx, `SELECT contact_id, archived_at IS NOT NULL, preferred FROM items_contacts WHERE workspace_id = $1 AND item_id = $2 ORDER BY contact_id`, ownerID, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	for rows.Next() {
+		var c string
+		var archived, preferred bool
+		if err := rows.Scan(&c, &archived, &preferred); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan contact: %w", err)
+		}
+		if archived {
+			d.Archived = append(d.Archived, c)
+		} else {
+			d.Suppliers = append(d.Suppliers, c)
+		}
+		if preferred {
+			d.Preferred = c
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+
+	relations := func(q string) ([]ItemRelation, error) {
+		rows, err := pool.Query(ctx, q, ownerID, itemID)
+		if err != nil {
+			return nil, fmt.Errorf("query parent_child: %w", err)
+		}
+		defer rows.Close()
+		var out []ItemRelation
+		for rows.Next() {
+			var rel ItemRelation
+			if err := rows.Scan(&rel.ItemID, &rel.Quantity); err != nil {
+				return nil, fmt.Errorf("scan parent_child: %w", err)
+			}
+			out = append(out, rel)
+		}
+		return out, rows.Err()
+	}
+	if d.Children, err = relations(`SELECT child_id, quantity::float8 FROM parent_child WHERE workspace_id = $1 AND parent_id = $2 ORDER BY child_id`); err != nil {
+		return nil, err
+	}
+	if d.Parents, err = relations(`SELECT parent_id, quantity::float8 FROM parent_child WHERE workspace_id = $1 AND child_id = $2 ORDER BY parent_id`); err != nil {
+		return nil, err
+	}
+
+	err = pool.QueryRow(ctx, `SELECT COALESCE(bool_or(unavailable), FALSE) FROM parts WHERE part_id = $1`, itemID).Scan(&d.Unavailable)
+	if err != nil {
+		return nil, fmt.Errorf("query parts: %w", err)
+	}
+	if d.Substitutes, err = ListItemSubstitutes(ctx, dbURL, itemID); err != nil {
+		return nil, err
+	}
+	rows, err = pool.Query(ctx, `SELECT item_id FROM item_substitutes WHERE substitute_id = $1 ORDER BY item_id`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("query item_substitutes: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan item_substitutes: %w", err)
+		}
+		d.SubstituteFor = append(d.SubstituteFor, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query item_substitutes: %w", err)
+	}
+
+	rows, err = pool.Query(ctx, `
+SELECT contact_id, to_char(effective_from, 'YYYY-MM-DD'), unit_price::float8, source, applied_at
+FROM supplier_prices WHERE workspace_id = $1 AND item_id = $2
+ORDER BY effective_from DESC, contact_id
+LIMIT $3
+`, ownerID, itemID, itemPriceHistory)
+	if err != nil {
+		return nil, fmt.Errorf("query supplier_prices: %w", err)
+	}
+	for rows.Next() {
+		var p SupplierPrice
+		if err := rows.Scan(&p.ContactID, &p.EffectiveFrom, &p.UnitPrice, &p.Source, &p.AppliedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan supplier_prices: %w", err)
+		}
+		d.Prices = append(d.Prices, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query supplier_prices: %w", err)
+	}
+
+	byItem, err := ListPartAttachments(ctx, dbURL, ownerID, []string{itemID})
+	if err != nil {
+		return nil, err
+	}
+	d.Attachments = byItem[itemID]
+	return d, nil
+}
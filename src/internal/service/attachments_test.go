@@ -0,0 +1,49 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttachmentKindFor(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"image/png":                AttachmentImage,
+		"image/jpeg":               AttachmentImage,
+		"application/pdf":          AttachmentDatasheet,
+		"text/html; charset=utf-8": "",
+		"application/octet-stream": "",
+	}
+	for ct, want := range cases {
+		if got := AttachmentKindFor(ct); got != want {
+			t.Fatalf("AttachmentKindFor(%q) = %q, want %q", ct, got, want)
+		}
+	}
+}
+
+func TestAttachmentStorageKey(t *testing.T) {
+	t.Parallel()
+	got := AttachmentStorageKey("P/1", "abc", `C:\tmp\relay spec (v2).pdf`)
+	if got != "parts/P_1/abc-relay_spec__v2_.pdf" {
+		t.Fatalf("unexpected key: %s", got)
+	}
+	if k := AttachmentStorageKey("P1", "abc", "../.."); strings.Contains(k, "..") {
+		t.Fatalf("traversal not sanitised: %s", k)
+	}
+}
+
+func TestAnnotateBOMAttachments(t *testing.T) {
+	t.Parallel()
+	bom := []BOMNode{{
+		PartID: "A", IsAssembly: true,
+		Children: []BOMNode{{PartID: "B"}, {PartID: "C"}, {PartID: "B"}},
+	}}
+	ids := BOMPartIDs(bom)
+	if strings.Join(ids, ",") != "A,B,C" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	AnnotateBOMAttachments(bom, map[string][]PartAttachment{"B": {{ID: 7, ItemID: "B"}}})
+	if len(bom[0].Attachments) != 0 || len(bom[0].Children[0].Attachments) != 1 || len(bom[0].Children[2].Attachments) != 1 {
+		t.Fatalf("unexpected annotation: %+v", bom)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Audit actions.
+const (
+	AuditPurchaseOrdersCreated  = "purchase_orders.created"
+	AuditPurchaseOrdersPending  = "purchase_orders.pending_approval"
+	AuditPurchaseOrdersApproved = "purchase_orders.approved"
+	AuditPurchaseOrdersRejected = "purchase_orders.rejected"
+	AuditBOMProposed            = "bom.proposed"
+	AuditBOMApproved            = "bom.approved"
+	AuditBOMRejected            = "bom.rejected"
+	AuditCreditNoteApplied      = "credit_note.applied"
+	AuditOrdersReleased         = "orders.released"
+	AuditSupplierPricesImported = "supplier_prices.imported"
+	AuditSupplierPricesApplied  = "supplier_prices.applied"
+)
+
+// AuditEntry is an audit_log row. Detail is stored as JSON.
+type AuditEntry struct {
+	OwnerID string
+	Action  string
+	Detail  any
+}
+
+// writeAudit adds e to audit_log within tx, so it commits only with the change it
+// describes.
+func writeAudit(ctx context.Context, tx pgx.Tx, e AuditEntry) error {
+	detail, err := json.Marshal(e.Detail)
+	if err != nil {
+		return fmt.Errorf("encode audit detail: %w", err)
+	}
+	if e.Detail == nil {
+		detail = []byte("{}")
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO audit_log (owner_id, action, detail) VALUES ($1, $2, $3::jsonb)
+`, e.OwnerID, e.Action, string(detail)); err != nil {
+		return fmt.Errorf("insert audit_log: %w", err)
+	}
+	return nil
+}
+
+// AuditRecord is a stored audit_log row.
+type AuditRecord struct {
+	ID        int64           `json:"id"`
+	Action    string          `json:"action"`
+	Detail    json.RawMessage `json:"detail"`
+	CreatedAt int64           `json:"created_at"`
+}
+
+// AuditLogSpec pages the audit log, newest first by default. q matches the action.
+var AuditLogSpec = ListSpec{
+	PerPage:     50,
+	MaxPerPage:  500,
+	Sorts:       map[string]string{"id": "id", "action": "action"},
+	DefaultSort: "-id",
+	TieBreak:    "id",
+}
+
+// ListAuditLog returns one page of the owner's audit_log rows.
+func ListAuditLog(ctx context.Context, dbURL, ownerID string, req PageRequest) (Page[AuditRecord], error) {
+	req = AuditLogSpec.Normalize(req)
+	page := newPage[AuditRecord](req)
+	if dbURL == "" {
+		return page, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return page, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	const filter = `WHERE owner_id = $1 AND ($2 = '' OR action ILIKE '%' || $2 || '%')`
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM audit_log `+filter, ownerID, req.Query).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("count audit_log: %w", err)
+	}
+	rows, err := pool.Query(ctx, `
+SELECT id, action, detail, created_at
+FROM audit_log
+`+filter+`
+ORDER BY `+AuditLogSpec.orderBy(req)+`
+LIMIT $3 OFFSET $4
+`, ownerID, req.Query, req.PerPage, req.Offset())
+	if err != nil {
+		return page, fmt.Errorf("query audit_log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a AuditRecord
+		if err := rows.Scan(&a.ID, &a.Action, &a.Detail, &a.CreatedAt); err != nil {
+			return page, fmt.Errorf("scan audit_log: %w", err)
+		}
+		page.Items = append(page.Items, a)
+	}
+	return page, rows.Err()
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// batchSender sends queued statements in one round trip (pgx.Tx, *pgxpool.Pool).
+type batchSender interface {
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// execBatch runs b's statements in one round trip. The first failing statement's
+// error is returned, prefixed with describe(i) for its index in b.
+func execBatch(ctx context.Context, db batchSender, b *pgx.Batch, describe func(i int) string) error {
+	if b.Len() == 0 {
+		return nil
+	}
+	br := db.SendBatch(ctx, b)
+	defer br.Close()
+	for i := range b.Len() {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("%s: %w", describe(i), err)
+		}
+	}
+	return br.Close()
+}
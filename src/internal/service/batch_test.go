@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeBatch answers queued statements in order, failing statement fail (-1 for none).
+type fakeBatch struct {
+	pgx.BatchResults
+	fail, next int
+	sent       int
+	closed     bool
+}
+
+func (f *fakeBatch) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	f.sent++
+	return f
+}
+
+func (f *fakeBatch) Exec() (pgconn.CommandTag, error) {
+	i := f.next
+	f.next++
+	if i == f.fail {
+		return pgconn.CommandTag{}, errors.New("duplicate key")
+	}
+	return pgconn.NewCommandTag("INSERT 0 1"), nil
+}
+
+func (f *fakeBatch) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestExecBatch(t *testing.T) {
+	t.Parallel()
+	names := []string{"BOLT", "NUT", "WASHER"}
+	queue := func() *pgx.Batch {
+		b := &pgx.Batch{}
+		for _, n := range names {
+			b.Queue(`INSERT INTO t (name) VALUES ($1)`, n)
+		}
+		return b
+	}
+	describe := func(i int) string { return "insert " + names[i] }
+
+	ok := &fakeBatch{fail: -1}
+	if err := execBatch(context.Background(), ok, queue(), describe); err != nil || ok.next != 3 || !ok.closed {
+		t.Fatalf("err = %v, executed %d, closed %v", err, ok.next, ok.closed)
+	}
+
+	bad := &fakeBatch{fail: 1}
+	err := execBatch(context.Background(), bad, queue(), describe)
+	if err == nil || err.Error() != "insert NUT: duplicate key" || !bad.closed {
+		t.Fatalf("err = %v, closed %v", err, bad.closed)
+	}
+
+	empty := &fakeBatch{fail: -1}
+	if err := execBatch(context.Background(), empty, &pgx.Batch{}, describe); err != nil || empty.sent != 0 {
+		t.Fatalf("empty batch: err = %v, sent %d", err, empty.sent)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BOMExportRow is one line of the flattened BOM for spreadsheet export.
+type BOMExportRow struct {
+	Level          int
+	PartID         string
+	Name           string
+	PerAssemblyQty float64 // as shown in the per-assembly tree (roots: invoice qty)
+	EffectiveQty   float64 // total needed for the invoice (per-assembly qty multiplied down the path)
+	Supplier       string
+	UnitCost       float64
+}
+
+// FlattenBOMForExport walks the per-assembly tree depth-first (roots are level 0)
+// and fills the supplier for each part from suppliers (part ID -> display name).
+func FlattenBOMForExport(perAssy []BOMNode, suppliers map[string]string) []BOMExportRow {
+	var out []BOMExportRow
+	var walk func(n BOMNode, level int, parentEffective float64)
+	walk = func(n BOMNode, level int, parentEffective float64) {
+		effective := n.Quantity * parentEffective
+		out = append(out, BOMExportRow{
+			Level:          level,
+			PartID:         n.PartID,
+			Name:           n.Name,
+			PerAssemblyQty: n.Quantity,
+			EffectiveQty:   math.Round(effective*10000) / 10000, // drop float noise from the divisions
+			Supplier:       suppliers[n.PartID],
+			UnitCost:       n.UnitCost,
+		})
+		for _, ch := range n.Children {
+			walk(ch, level+1, effective)
+		}
+	}
+	for _, r := range perAssy {
+		walk(r, 0, 1)
+	}
+	return out
+}
+
+// LoadItemSuppliers maps item IDs to their supplier for display: the supplier name
+// when the suppliers table knows the account number, otherwise the account number.
+// Items with several suppliers list them comma-separated. Mappings are the owner's.
+func LoadItemSuppliers(ctx context.Context, dbURL, ownerID string, itemIDs []string) (map[string]string, error) {
+	out := map[string]string{}
+	if len(itemIDs) == 0 {
+		return out, nil
+	}
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT ic.item_id, COALESCE(NULLIF(s.supplier_name, ''), ic.contact_id)
+FROM items_contacts ic
+LEFT JOIN suppliers s ON s.supplier_id = ic.contact_id
+WHERE ic.workspace_id = $1 AND ic.item_id = ANY($2) AND ic.archived_at IS NULL
+ORDER BY ic.item_id, ic.contact_id
+`, ownerID, itemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	defer rows.Close()
+
+	byItem := map[string][]string{}
+	for rows.Next() {
+		var itemID, supplier string
+		if err := rows.Scan(&itemID, &supplier); err != nil {
+			return nil, fmt.Errorf("scan supplier: %w", err)
+		}
+		byItem[itemID] = append(byItem[itemID], supplier)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	for id, ss := range byItem {
+		out[id] = strings.Join(ss, ", ")
+	}
+	return out, nil
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenBOMForExport(t *testing.T) {
+	t.Parallel()
+	perAssy := []BOMNode{{
+		PartID: "VAN-001", Name: "Van", Quantity: 3, IsAssembly: true, UnitCost: 100,
+		Children: []BOMNode{
+			{PartID: "FRAME", Quantity: 1, IsAssembly: true, Children: []BOMNode{{PartID: "BOLT", Quantity: 8, UnitCost: 0.1}}},
+			{PartID: "WHEEL", Quantity: 4, UnitCost: 20},
+		},
+	}}
+	got := FlattenBOMForExport(perAssy, map[string]string{"BOLT": "Fasteners Ltd", "WHEEL": "ACME-01"})
+	want := []BOMExportRow{
+		{Level: 0, PartID: "VAN-001", Name: "Van", PerAssemblyQty: 3, EffectiveQty: 3, UnitCost: 100},
+		{Level: 1, PartID: "FRAME", PerAssemblyQty: 1, EffectiveQty: 3},
+		{Level: 2, PartID: "BOLT", PerAssemblyQty: 8, EffectiveQty: 24, Supplier: "Fasteners Ltd", UnitCost: 0.1},
+		{Level: 1, PartID: "WHEEL", PerAssemblyQty: 4, EffectiveQty: 12, Supplier: "ACME-01", UnitCost: 20},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// KnownXeroItemCodes returns which of codes exist as items in Xero, using the item
+// cache shared with BOM resolution.
+func KnownXeroItemCodes(ctx context.Context, xc *xero.Client, accessToken, tenantID string, codes []string) (map[string]bool, error) {
+	items, err := itemCache.lookup(ctx, xc, accessToken, tenantID, codes)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(items))
+	for code := range items {
+		known[code] = true
+	}
+	return known, nil
+}
+
+// ImportParentChild upserts rows into the owner's parent_child in a single
+// transaction: either every row is applied or none is.
+func ImportParentChild(ctx context.Context, dbURL, ownerID string, rows []csvimport.BOMRow) (csvimport.Result, error) {
+	if dbURL == "" {
+		return csvimport.Result{}, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return csvimport.Result{}, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return csvimport.Result{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := csvimport.UpsertBOM(ctx, tx, ownerID, rows)
+	if err != nil {
+		return csvimport.Result{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return csvimport.Result{}, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
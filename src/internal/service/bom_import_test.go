@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+)
+
+func TestImportParentChild_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	_, err := ImportParentChild(context.Background(), "", "owner-1", []csvimport.BOMRow{{Line: 2, Parent: "A", Child: "B", Quantity: 1}})
+	if err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrProposalDecided is returned by DecideBOMProposal for a proposal that was already
+// approved or rejected.
+var ErrProposalDecided = errors.New("parts list change already decided")
+
+// ErrSelfReview is returned by DecideBOMProposal when the user deciding is the one
+// who proposed the change.
+var ErrSelfReview = errors.New("parts list change must be reviewed by someone other than who proposed it")
+
+// BOMProposal is a bom_proposals row: parent_child rows to upsert once approved.
+// Status is one of the po_approvals statuses (ApprovalPending and so on).
+type BOMProposal struct {
+	ID         int                `json:"id"`
+	ProposedBy string             `json:"proposed_by"`
+	Filename   string             `json:"filename"`
+	Status     string             `json:"status"`
+	Rows       []csvimport.BOMRow `json:"rows"`
+	DecidedBy  string             `json:"decided_by,omitempty"`
+	DecidedAt  int64              `json:"decided_at,omitempty"`
+	CreatedAt  int64              `json:"created_at"`
+}
+
+// BOMRowChange is what approving a proposal row would do to parent_child: Current is
+// the quantity now, 0 when the relationship is new.
+type BOMRowChange struct {
+	Parent   string  `json:"parent_code"`
+	Child    string  `json:"child_code"`
+	Current  float64 `json:"current"`
+	Proposed float64 `json:"proposed"`
+}
+
+// Kind is "added", "changed" or "unchanged".
+func (c BOMRowChange) Kind() string {
+	switch {
+	case c.Current == 0:
+		return "added"
+	case c.Current != c.Proposed:
+		return "changed"
+	}
+	return "unchanged"
+}
+
+// DiffBOMRows compares rows with the current quantities (parent, child -> quantity),
+// added and changed rows first, each in the rows' order.
+func DiffBOMRows(current map[[2]string]float64, rows []csvimport.BOMRow) []BOMRowChange {
+	var changed, unchanged []BOMRowChange
+	for _, r := range rows {
+		c := BOMRowChange{Parent: r.Parent, Child: r.Child, Current: current[[2]string{r.Parent, r.Child}], Proposed: r.Quantity}
+		if c.Kind() == "unchanged" {
+			unchanged = append(unchanged, c)
+		} else {
+			changed = append(changed, c)
+		}
+	}
+	return append(changed, unchanged...)
+}
+
+// ProposeParentChild stores rows as a pending change to the owner's parent_child and
+// returns its id. parent_child is untouched until DecideBOMProposal approves it.
+func ProposeParentChild(ctx context.Context, dbURL, ownerID, proposedBy, filename string, rows []csvimport.BOMRow) (int, error) {
+	if proposedBy == "" {
+		return 0, fmt.Errorf("user id missing")
+	}
+	body, err := json.Marshal(rows)
+	if err != nil {
+		return 0, fmt.Errorf("encode parts list rows: %w", err)
+	}
+	var id int
+	err = WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `
+INSERT INTO bom_proposals (owner_id, proposed_by, filename, rows)
+VALUES ($1, $2, $3, $4::jsonb)
+RETURNING id
+`, ownerID, proposedBy, filename, string(body)).Scan(&id); err != nil {
+			return fmt.Errorf("insert bom_proposal: %w", err)
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  AuditBOMProposed,
+			Detail:  map[string]any{"proposal_id": id, "filename": filename, "rows": len(rows)},
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// bomProposalColumns are the bom_proposals columns scanBOMProposal reads, in order.
+const bomProposalColumns = `id, proposed_by, filename, status, rows, COALESCE(decided_by, ''), COALESCE(decided_at, 0), created_at`
+
+func scanBOMProposal(row pgx.Row) (BOMProposal, error) {
+	var p BOMProposal
+	var rows []byte
+	if err := row.Scan(&p.ID, &p.ProposedBy, &p.Filename, &p.Status, &rows, &p.DecidedBy, &p.DecidedAt, &p.CreatedAt); err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(rows, &p.Rows); err != nil {
+		return p, fmt.Errorf("decode bom_proposal %d rows: %w", p.ID, err)
+	}
+	return p, nil
+}
+
+// ListBOMProposals returns the owner's pending proposals and the limit most recently
+// decided ones, newest first.
+func ListBOMProposals(ctx context.Context, dbURL, ownerID string, limit int) ([]BOMProposal, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+(SELECT `+bomProposalColumns+` FROM bom_proposals WHERE owner_id = $1 AND status = 'pending')
+UNION ALL
+(SELECT `+bomProposalColumns+` FROM bom_proposals WHERE owner_id = $1 AND status <> 'pending' ORDER BY id DESC LIMIT $2)
+ORDER BY id DESC
+`, ownerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query bom_proposals: %w", err)
+	}
+	defer rows.Close()
+	var out []BOMProposal
+	for rows.Next() {
+		p, err := scanBOMProposal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan bom_proposal: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GetBOMProposal returns the owner's proposal id and what approving it would change
+// in parent_child now, or an error wrapping ErrNotFound.
+func GetBOMProposal(ctx context.Context, dbURL, ownerID string, id int) (BOMProposal, []BOMRowChange, error) {
+	if dbURL == "" {
+		return BOMProposal{}, nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return BOMProposal{}, nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	p, err := scanBOMProposal(pool.QueryRow(ctx, `SELECT `+bomProposalColumns+` FROM bom_proposals WHERE owner_id = $1 AND id = $2`, ownerID, id))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return BOMProposal{}, nil, fmt.Errorf("parts list change %d: %w", id, ErrNotFound)
+	case err != nil:
+		return BOMProposal{}, nil, fmt.Errorf("load bom_proposal: %w", err)
+	}
+
+	parents := make([]string, 0, len(p.Rows))
+	for _, r := range p.Rows {
+		parents = append(parents, r.Parent)
+	}
+	rows, err := pool.Query(ctx, `
+SELECT parent_id, child_id, quantity::float8 FROM parent_child WHERE workspace_id = $1 AND parent_id = ANY($2)
+`, ownerID, parents)
+	if err != nil {
+		return BOMProposal{}, nil, fmt.Errorf("query parent_child: %w", err)
+	}
+	defer rows.Close()
+	current := map[[2]string]float64{}
+	for rows.Next() {
+		var parent, child string
+		var qty float64
+		if err := rows.Scan(&parent, &child, &qty); err != nil {
+			return BOMProposal{}, nil, fmt.Errorf("scan parent_child: %w", err)
+		}
+		current[[2]string{parent, child}] = qty
+	}
+	if err := rows.Err(); err != nil {
+		return BOMProposal{}, nil, err
+	}
+	return p, DiffBOMRows(current, p.Rows), nil
+}
+
+// DecideBOMProposal approves or rejects the owner's pending proposal id as decidedBy.
+// Approving upserts its rows into parent_child in the same transaction and returns
+// what that did. It fails with ErrNotFound, ErrProposalDecided, or ErrSelfReview when
+// decidedBy proposed the change.
+func DecideBOMProposal(ctx context.Context, dbURL, ownerID string, id int, decidedBy string, approve bool) (BOMProposal, csvimport.Result, error) {
+	if decidedBy == "" {
+		return BOMProposal{}, csvimport.Result{}, fmt.Errorf("user id missing")
+	}
+	status, action := ApprovalRejected, AuditBOMRejected
+	if approve {
+		status, action = ApprovalApproved, AuditBOMApproved
+	}
+	var p BOMProposal
+	var res csvimport.Result
+	err := WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		var err error
+		p, err = scanBOMProposal(tx.QueryRow(ctx, `SELECT `+bomProposalColumns+` FROM bom_proposals WHERE owner_id = $1 AND id = $2 FOR UPDATE`, ownerID, id))
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("parts list change %d: %w", id, ErrNotFound)
+		case err != nil:
+			return fmt.Errorf("load bom_proposal: %w", err)
+		case p.Status != ApprovalPending:
+			return fmt.Errorf("%w: %s", ErrProposalDecided, p.Status)
+		case p.ProposedBy == decidedBy:
+			return ErrSelfReview
+		}
+		if approve {
+			if res, err = csvimport.UpsertBOM(ctx, tx, ownerID, p.Rows); err != nil {
+				return err
+			}
+		}
+		p.Status, p.DecidedBy, p.DecidedAt = status, decidedBy, time.Now().Unix()
+		if _, err := tx.Exec(ctx, `
+UPDATE bom_proposals SET status = $3, decided_by = $4, decided_at = $5 WHERE owner_id = $1 AND id = $2
+`, ownerID, id, p.Status, p.DecidedBy, p.DecidedAt); err != nil {
+			return fmt.Errorf("update bom_proposal: %w", err)
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  action,
+			Detail:  map[string]any{"proposal_id": id, "proposed_by": p.ProposedBy, "decided_by": decidedBy, "result": res},
+		})
+	})
+	if err != nil {
+		return BOMProposal{}, csvimport.Result{}, err
+	}
+	return p, res, nil
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+)
+
+func TestDiffBOMRows(t *testing.T) {
+	t.Parallel()
+	current := map[[2]string]float64{{"FRAME", "BOLT"}: 4, {"FRAME", "NUT"}: 4, {"FRAME", "CLIP"}: 2}
+	rows := []csvimport.BOMRow{
+		{Parent: "FRAME", Child: "BOLT", Quantity: 4},
+		{Parent: "FRAME", Child: "NUT", Quantity: 6},
+		{Parent: "FRAME", Child: "WASHER", Quantity: 8},
+	}
+	want := []BOMRowChange{
+		{Parent: "FRAME", Child: "NUT", Current: 4, Proposed: 6},
+		{Parent: "FRAME", Child: "WASHER", Proposed: 8},
+		{Parent: "FRAME", Child: "BOLT", Current: 4, Proposed: 4},
+	}
+	got := DiffBOMRows(current, rows)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+	for i, kind := range []string{"changed", "added", "unchanged"} {
+		if got[i].Kind() != kind {
+			t.Errorf("%s > %s: Kind = %q, want %q", got[i].Parent, got[i].Child, got[i].Kind(), kind)
+		}
+	}
+}
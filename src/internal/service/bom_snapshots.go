@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxBOMSnapshotsPerInvoice is how many snapshots are kept per invoice; older ones
+// are deleted when a new one is saved.
+const maxBOMSnapshotsPerInvoice = 20
+
+// bomSnapshotCacheTTL bounds how long an invoice's snapshot list is cached; saving a
+// snapshot drops it sooner.
+const bomSnapshotCacheTTL = 10 * time.Minute
+
+// bomSnapshotsKey is the cache key of an owner's snapshot list for an invoice.
+func bomSnapshotsKey(ownerID, invoiceNumber string) string {
+	return "bom_snapshots:" + ownerID + ":" + invoiceNumber
+}
+
+// SnapshotPart is one line of a stored parts list.
+type SnapshotPart struct {
+	PartID   string  `json:"part_id"`
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	UnitCost float64 `json:"unit_cost,omitempty"`
+}
+
+// BOMSnapshot is an invoice's parts list as resolved at CreatedAt.
+type BOMSnapshot struct {
+	ID            int            `json:"id"`
+	InvoiceNumber string         `json:"invoice_number"`
+	Parts         []SnapshotPart `json:"parts"`
+	CreatedAt     int64          `json:"created_at"`
+}
+
+// SnapshotParts is the stored form of an invoice's leaf totals: the required
+// quantities before stock on hand, sorted by part.
+func SnapshotParts(totals []LeafTotal) []SnapshotPart {
+	out := make([]SnapshotPart, 0, len(totals))
+	for _, lt := range totals {
+		qty := lt.Quantity
+		if lt.Required > 0 {
+			qty = lt.Required
+		}
+		out = append(out, SnapshotPart{PartID: lt.PartID, Name: lt.Name, Quantity: qty, UnitCost: lt.UnitCost})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PartID < out[j].PartID })
+	return out
+}
+
+// SaveBOMSnapshot stores the parts list resolved for an invoice unless it matches the
+// invoice's latest snapshot. It reports whether a snapshot was saved.
+func SaveBOMSnapshot(ctx context.Context, dbURL, ownerID, invoiceNumber string, totals []LeafTotal) (bool, error) {
+	if dbURL == "" {
+		return false, fmt.Errorf("db url missing")
+	}
+	parts := SnapshotParts(totals)
+	b, err := json.Marshal(parts)
+	if err != nil {
+		return false, err
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var latest []byte
+	err = tx.QueryRow(ctx, `
+SELECT parts FROM bom_snapshots
+WHERE owner_id = $1 AND invoice_number = $2
+ORDER BY id DESC LIMIT 1
+`, ownerID, invoiceNumber).Scan(&latest)
+	if err == nil {
+		var prev []SnapshotPart
+		if json.Unmarshal(latest, &prev) == nil && reflect.DeepEqual(prev, parts) {
+			return false, nil
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO bom_snapshots (owner_id, invoice_number, parts) VALUES ($1, $2, $3)
+`, ownerID, invoiceNumber, b); err != nil {
+		return false, fmt.Errorf("insert bom snapshot: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+DELETE FROM bom_snapshots
+WHERE owner_id = $1 AND invoice_number = $2 AND id NOT IN (
+  SELECT id FROM bom_snapshots WHERE owner_id = $1 AND invoice_number = $2 ORDER BY id DESC LIMIT $3
+)
+`, ownerID, invoiceNumber, maxBOMSnapshotsPerInvoice); err != nil {
+		return false, fmt.Errorf("prune bom snapshots: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("commit: %w", err)
+	}
+	if err := sharedCache.Delete(ctx, bomSnapshotsKey(ownerID, invoiceNumber)); err != nil {
+		cacheMiss("drop bom snapshots", err)
+	}
+	return true, nil
+}
+
+// ListBOMSnapshots returns the owner's snapshots of an invoice, newest first.
+func ListBOMSnapshots(ctx context.Context, dbURL, ownerID, invoiceNumber string) ([]BOMSnapshot, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	key := bomSnapshotsKey(ownerID, invoiceNumber)
+	cached, err := cache.GetJSON[[]BOMSnapshot](ctx, sharedCache, key)
+	if err != nil {
+		cacheMiss("get bom snapshots", err)
+	}
+	if list, ok := cached[key]; ok {
+		return list, nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT id, invoice_number, parts, created_at FROM bom_snapshots
+WHERE owner_id = $1 AND invoice_number = $2
+ORDER BY id DESC
+`, ownerID, invoiceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("query bom snapshots: %w", err)
+	}
+	defer rows.Close()
+	var out []BOMSnapshot
+	for rows.Next() {
+		var s BOMSnapshot
+		var parts []byte
+		if err := rows.Scan(&s.ID, &s.InvoiceNumber, &parts, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan bom snapshot: %w", err)
+		}
+		if err := json.Unmarshal(parts, &s.Parts); err != nil {
+			return nil, fmt.Errorf("decode bom snapshot %d: %w", s.ID, err)
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := cache.SetJSON(ctx, sharedCache, map[string][]BOMSnapshot{key: out}, bomSnapshotCacheTTL); err != nil {
+		cacheMiss("set bom snapshots", err)
+	}
+	return out, nil
+}
+
+// Kinds of PartChange.
+const (
+	PartAdded   = "added"
+	PartRemoved = "removed"
+	PartChanged = "changed"
+)
+
+// PartChange is how one part differs between two parts lists. Old is zero for an
+// added part and New for a removed one.
+type PartChange struct {
+	PartID string       `json:"part_id"`
+	Kind   string       `json:"kind"`
+	Old    SnapshotPart `json:"old"`
+	New    SnapshotPart `json:"new"`
+}
+
+// QuantityDelta is the change in required quantity.
+func (c PartChange) QuantityDelta() float64 { return c.New.Quantity - c.Old.Quantity }
+
+// DiffBOMSnapshots lists the parts added, removed or changed (quantity, name or unit
+// cost) going from old to new, sorted by part.
+func DiffBOMSnapshots(old, new []SnapshotPart) []PartChange {
+	before := make(map[string]SnapshotPart, len(old))
+	for _, p := range old {
+		before[p.PartID] = p
+	}
+	var out []PartChange
+	seen := make(map[string]bool, len(new))
+	for _, p := range new {
+		seen[p.PartID] = true
+		o, ok := before[p.PartID]
+		switch {
+		case !ok:
+			out = append(out, PartChange{PartID: p.PartID, Kind: PartAdded, New: p})
+		case o != p:
+			out = append(out, PartChange{PartID: p.PartID, Kind: PartChanged, Old: o, New: p})
+		}
+	}
+	for _, p := range old {
+		if !seen[p.PartID] {
+			out = append(out, PartChange{PartID: p.PartID, Kind: PartRemoved, Old: p})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PartID < out[j].PartID })
+	return out
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotParts(t *testing.T) {
+	t.Parallel()
+	got := SnapshotParts([]LeafTotal{
+		{PartID: "NUT", Name: "Nut", Quantity: 4},
+		// stock deducted: the snapshot keeps what the invoice needs
+		{PartID: "BOLT", Name: "Bolt", Quantity: 1, Required: 6, StockTracked: true, OnHand: 5, UnitCost: 0.2},
+	})
+	want := []SnapshotPart{
+		{PartID: "BOLT", Name: "Bolt", Quantity: 6, UnitCost: 0.2},
+		{PartID: "NUT", Name: "Nut", Quantity: 4},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDiffBOMSnapshots(t *testing.T) {
+	t.Parallel()
+	old := []SnapshotPart{
+		{PartID: "BOLT", Name: "Bolt", Quantity: 6},
+		{PartID: "NUT", Name: "Nut", Quantity: 4},
+		{PartID: "WASHER", Name: "Washer", Quantity: 8},
+	}
+	new := []SnapshotPart{
+		{PartID: "BOLT", Name: "Bolt", Quantity: 8},
+		{PartID: "CLIP", Name: "Clip", Quantity: 2},
+		{PartID: "NUT", Name: "Nut", Quantity: 4},
+	}
+	got := DiffBOMSnapshots(old, new)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", got)
+	}
+	if c := got[0]; c.PartID != "BOLT" || c.Kind != PartChanged || c.QuantityDelta() != 2 {
+		t.Fatalf("unexpected BOLT change: %+v", c)
+	}
+	if c := got[1]; c.PartID != "CLIP" || c.Kind != PartAdded || c.QuantityDelta() != 2 {
+		t.Fatalf("unexpected CLIP change: %+v", c)
+	}
+	if c := got[2]; c.PartID != "WASHER" || c.Kind != PartRemoved || c.QuantityDelta() != -8 {
+		t.Fatalf("unexpected WASHER change: %+v", c)
+	}
+	if d := DiffBOMSnapshots(old, old); len(d) != 0 {
+		t.Fatalf("identical lists differ: %+v", d)
+	}
+}
+
+func TestBOMSnapshots_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := SaveBOMSnapshot(context.Background(), "", "o", "INV-1", nil); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+	if _, err := ListBOMSnapshots(context.Background(), "", "o", "INV-1"); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
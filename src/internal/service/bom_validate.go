@@ -0,0 +1,196 @@
+package service
+
+import (
+	"slices"
+	"sort"
+)
+
+// ParentChildRow is one row of parent_child.
+type ParentChildRow struct {
+	Parent   string
+	Child    string
+	Quantity float64
+}
+
+// UnknownBOMCode is a code used in parent_child that is not a known item, with the
+// rows that use it ("PARENT > CHILD").
+type UnknownBOMCode struct {
+	Code   string
+	UsedIn []string
+}
+
+// MappedAssembly is an item with both a parts list and a supplier mapping. BOM
+// resolution treats it as a purchasable part, so its children are never expanded.
+type MappedAssembly struct {
+	Code      string
+	Suppliers []string // items_contacts.contact_id
+	Children  int
+}
+
+// BOMIssues is what ValidateBOM found; every list is sorted.
+type BOMIssues struct {
+	Cycles           [][]string // each starts and ends with the same code
+	Unknown          []UnknownBOMCode
+	MappedAssemblies []MappedAssembly
+}
+
+// Count returns the number of problems found.
+func (b BOMIssues) Count() int {
+	return len(b.Cycles) + len(b.Unknown) + len(b.MappedAssemblies)
+}
+
+// BOMCodes returns every code used in rows, sorted.
+func BOMCodes(rows []ParentChildRow) []string {
+	set := map[string]bool{}
+	for _, r := range rows {
+		set[r.Parent] = true
+		set[r.Child] = true
+	}
+	return sortedKeys(set)
+}
+
+// ValidateBOM checks parent_child rows for cycles, codes that are not in known (nil
+// skips that check) and assemblies that also have a supplier in mapped
+// (item -> contacts).
+func ValidateBOM(rows []ParentChildRow, known map[string]bool, mapped map[string][]string) BOMIssues {
+	var out BOMIssues
+	out.Cycles = findBOMCycles(rows)
+
+	if known != nil {
+		usedIn := map[string][]string{}
+		for _, r := range rows {
+			edge := r.Parent + " > " + r.Child
+			for _, c := range uniqueStrings([]string{r.Parent, r.Child}) {
+				if !known[c] {
+					usedIn[c] = append(usedIn[c], edge)
+				}
+			}
+		}
+		for _, code := range sortedKeys(usedIn) {
+			edges := usedIn[code]
+			sort.Strings(edges)
+			out.Unknown = append(out.Unknown, UnknownBOMCode{Code: code, UsedIn: edges})
+		}
+	}
+
+	children := map[string]int{}
+	for _, r := range rows {
+		children[r.Parent]++
+	}
+	for _, code := range sortedKeys(children) {
+		if sup := mapped[code]; len(sup) > 0 {
+			sup = append([]string(nil), sup...)
+			sort.Strings(sup)
+			out.MappedAssemblies = append(out.MappedAssemblies, MappedAssembly{Code: code, Suppliers: sup, Children: children[code]})
+		}
+	}
+	return out
+}
+
+// findBOMCycles returns one cycle for each strongly connected group of items in the
+// parent_child graph (Tarjan), starting from its smallest code.
+func findBOMCycles(rows []ParentChildRow) [][]string {
+	adj := map[string][]string{}
+	for _, r := range rows {
+		adj[r.Parent] = append(adj[r.Parent], r.Child)
+	}
+	for _, next := range adj {
+		sort.Strings(next)
+	}
+
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var groups [][]string
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		low[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range adj[v] {
+			if _, seen := index[w]; !seen {
+				visit(w)
+				low[v] = min(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = min(low[v], index[w])
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		var group []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			group = append(group, w)
+			if w == v {
+				break
+			}
+		}
+		groups = append(groups, group)
+	}
+	for _, v := range sortedKeys(adj) {
+		if _, seen := index[v]; !seen {
+			visit(v)
+		}
+	}
+
+	var cycles [][]string
+	for _, group := range groups {
+		sort.Strings(group)
+		start := group[0]
+		in := map[string]bool{}
+		for _, g := range group {
+			in[g] = true
+		}
+		if len(group) == 1 && !slices.Contains(adj[start], start) {
+			continue
+		}
+		cycles = append(cycles, shortestCycle(adj, in, start))
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i][0] < cycles[j][0] })
+	return cycles
+}
+
+// shortestCycle finds the shortest path from start back to itself within in (BFS).
+func shortestCycle(adj map[string][]string, in map[string]bool, start string) []string {
+	prev := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range adj[v] {
+			if !in[w] {
+				continue
+			}
+			if w == start {
+				path := []string{start}
+				for at := v; at != start; at = prev[at] {
+					path = append(path, at)
+				}
+				path = append(path, start)
+				for i, j := 1, len(path)-2; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if _, seen := prev[w]; !seen {
+				prev[w] = v
+				queue = append(queue, w)
+			}
+		}
+	}
+	return []string{start, start}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
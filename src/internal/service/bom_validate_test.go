@@ -0,0 +1,54 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateBOM(t *testing.T) {
+	t.Parallel()
+	rows := []ParentChildRow{
+		{Parent: "FRAME", Child: "BOLT", Quantity: 4},
+		{Parent: "FRAME", Child: "BRACKET", Quantity: 2},
+		{Parent: "BRACKET", Child: "PLATE", Quantity: 1},
+		{Parent: "PLATE", Child: "BRACKET", Quantity: 1}, // BRACKET > PLATE > BRACKET
+		{Parent: "GEAR", Child: "GEAR", Quantity: 1},     // self reference
+		{Parent: "A", Child: "B", Quantity: 1},
+		{Parent: "B", Child: "C", Quantity: 1},
+		{Parent: "C", Child: "A", Quantity: 1},
+		{Parent: "C", Child: "B", Quantity: 1},
+		{Parent: "BOLT", Child: "THREAD", Quantity: 1},
+	}
+	known := map[string]bool{"FRAME": true, "BOLT": true, "BRACKET": true, "PLATE": true, "A": true, "B": true, "C": true}
+	mapped := map[string][]string{"BOLT": {"SUP-2", "SUP-1"}, "PLATE": {"SUP-1"}, "NUT": {"SUP-1"}}
+
+	got := ValidateBOM(rows, known, mapped)
+	want := BOMIssues{
+		Cycles: [][]string{
+			{"A", "B", "C", "A"},
+			{"BRACKET", "PLATE", "BRACKET"},
+			{"GEAR", "GEAR"},
+		},
+		Unknown: []UnknownBOMCode{
+			{Code: "GEAR", UsedIn: []string{"GEAR > GEAR"}},
+			{Code: "THREAD", UsedIn: []string{"BOLT > THREAD"}},
+		},
+		MappedAssemblies: []MappedAssembly{
+			{Code: "BOLT", Suppliers: []string{"SUP-1", "SUP-2"}, Children: 1},
+			{Code: "PLATE", Suppliers: []string{"SUP-1"}, Children: 1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+	if got.Count() != 7 {
+		t.Fatalf("count = %d, want 7", got.Count())
+	}
+
+	if skip := ValidateBOM(rows, nil, nil); skip.Unknown != nil || skip.MappedAssemblies != nil || len(skip.Cycles) != 3 {
+		t.Fatalf("nil known/mapped: %+v", skip)
+	}
+	if got := BOMCodes(rows[:2]); !reflect.DeepEqual(got, []string{"BOLT", "BRACKET", "FRAME"}) {
+		t.Fatalf("codes = %v", got)
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Build is one customer job (an invoice) tracked through purchasing.
+type Build struct {
+	ID            int    `json:"id"`
+	OwnerID       string `json:"owner_id"`
+	InvoiceNumber string `json:"invoice_number"`
+	Name          string `json:"name"`
+	Shared        bool   `json:"shared"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+// BuildPart is a purchasable part required by one top-level assembly of a build.
+type BuildPart struct {
+	AssemblyID   string
+	AssemblyName string
+	PartID       string
+	Quantity     float64
+}
+
+// AssemblyProgress is the purchasing status of one top-level assembly.
+type AssemblyProgress struct {
+	AssemblyID      string  `json:"assembly_id"`
+	AssemblyName    string  `json:"assembly_name"`
+	Parts           int     `json:"parts"`
+	OrderedPercent  float64 `json:"ordered_percent"`
+	ReceivedPercent float64 `json:"received_percent"`
+}
+
+// BuildProgress summarises purchasing status for a build.
+type BuildProgress struct {
+	Build           Build              `json:"build"`
+	Assemblies      []AssemblyProgress `json:"assemblies"`
+	OrderedPercent  float64            `json:"ordered_percent"`
+	ReceivedPercent float64            `json:"received_percent"`
+}
+
+// HashShareToken returns the stored form of a public share token.
+func HashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BuildPartsFromBOM flattens a per-assembly BOM into required parts per root assembly.
+// Roots repeated on the invoice are merged.
+func BuildPartsFromBOM(perAssy []BOMNode) []BuildPart {
+	type key struct{ asm, part string }
+	agg := map[key]*BuildPart{}
+	var order []key
+	for _, root := range perAssy {
+		for _, lt := range AggregateLeafTotals([]BOMNode{root}) {
+			k := key{root.PartID, lt.PartID}
+			if bp, ok := agg[k]; ok {
+				bp.Quantity += lt.Quantity
+				continue
+			}
+			agg[k] = &BuildPart{AssemblyID: root.PartID, AssemblyName: root.Name, PartID: lt.PartID, Quantity: lt.Quantity}
+			order = append(order, k)
+		}
+	}
+	out := make([]BuildPart, 0, len(order))
+	for _, k := range order {
+		out = append(out, *agg[k])
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].AssemblyID != out[j].AssemblyID {
+			return out[i].AssemblyID < out[j].AssemblyID
+		}
+		return out[i].PartID < out[j].PartID
+	})
+	return out
+}
+
+// ComputeBuildProgress works out ordered/received percentages per assembly.
+// ordered/received are quantities per part bought for the whole build; a part shared
+// by several assemblies is credited to each in proportion to what the build needs.
+func ComputeBuildProgress(parts []BuildPart, ordered, received map[string]float64) ([]AssemblyProgress, float64, float64) {
+	need := map[string]float64{}
+	for _, p := range parts {
+		need[p.PartID] += p.Quantity
+	}
+	coverage := func(have map[string]float64, part string) float64 {
+		if need[part] <= 0 {
+			return 1
+		}
+		return math.Min(1, have[part]/need[part])
+	}
+
+	type acc struct {
+		p             AssemblyProgress
+		req, ord, rec float64
+	}
+	byAsm := map[string]*acc{}
+	var order []string
+	var totReq, totOrd, totRec float64
+	for _, p := range parts {
+		a, ok := byAsm[p.AssemblyID]
+		if !ok {
+			a = &acc{p: AssemblyProgress{AssemblyID: p.AssemblyID, AssemblyName: p.AssemblyName}}
+			byAsm[p.AssemblyID] = a
+			order = append(order, p.AssemblyID)
+		}
+		q := p.Quantity
+		a.p.Parts++
+		a.req += q
+		a.ord += q * coverage(ordered, p.PartID)
+		a.rec += q * coverage(received, p.PartID)
+		totReq += q
+		totOrd += q * coverage(ordered, p.PartID)
+		totRec += q * coverage(received, p.PartID)
+	}
+
+	pct := func(n, d float64) float64 {
+		if d <= 0 {
+			return 0
+		}
+		return math.Round(n/d*1000) / 10
+	}
+	out := make([]AssemblyProgress, 0, len(order))
+	for _, id := range order {
+		a := byAsm[id]
+		a.p.OrderedPercent = pct(a.ord, a.req)
+		a.p.ReceivedPercent = pct(a.rec, a.req)
+		out = append(out, a.p)
+	}
+	return out, pct(totOrd, totReq), pct(totRec, totReq)
+}
+
+// UpsertBuildFromBOM creates (or refreshes) the owner's build for an invoice and
+// replaces its required parts with the resolved BOM. Returns the build id.
+func UpsertBuildFromBOM(ctx context.Context, dbURL, ownerID, invoiceNumber string, perAssy []BOMNode) (int, error) {
+	if dbURL == "" {
+		return 0, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return 0, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx, `
+INSERT INTO builds (owner_id, invoice_number, name)
+VALUES ($1, $2, $3)
+ON CONFLICT (owner_id, invoice_number) DO UPDATE SET name = EXCLUDED.name
+RETURNING id
+`, ownerID, invoiceNumber, "Invoice "+invoiceNumber).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("upsert build: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM build_parts WHERE build_id = $1`, id); err != nil {
+		return 0, fmt.Errorf("clear build_parts: %w", err)
+	}
+	parts := BuildPartsFromBOM(perAssy)
+	b := &pgx.Batch{}
+	for _, p := range parts {
+		b.Queue(`
+INSERT INTO build_parts (build_id, assembly_id, assembly_name, part_id, quantity)
+VALUES ($1, $2, $3, $4, $5)
+`, id, p.AssemblyID, p.AssemblyName, p.PartID, p.Quantity)
+	}
+	if err := execBatch(ctx, tx, b, func(i int) string { return "insert build_part " + parts[i].PartID }); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return id, nil
+}
+
+const buildColumns = `id, owner_id, invoice_number, name, share_token_hash IS NOT NULL, created_at`
+
+func scanBuild(row pgx.Row) (*Build, error) {
+	var b Build
+	if err := row.Scan(&b.ID, &b.OwnerID, &b.InvoiceNumber, &b.Name, &b.Shared, &b.CreatedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query build: %w", err)
+	}
+	return &b, nil
+}
+
+// ListBuilds returns the owner's builds, newest first.
+func ListBuilds(ctx context.Context, dbURL, ownerID string) ([]Build, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `SELECT `+buildColumns+` FROM builds WHERE owner_id = $1 ORDER BY created_at DESC, id DESC`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query builds: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Build
+	for rows.Next() {
+		b, err := scanBuild(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *b)
+	}
+	return out, rows.Err()
+}
+
+// GetBuild returns the owner's build by id (nil if not found).
+func GetBuild(ctx context.Context, dbURL, ownerID string, id int) (*Build, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	return scanBuild(pool.QueryRow(ctx, `SELECT `+buildColumns+` FROM builds WHERE owner_id = $1 AND id = $2`, ownerID, id))
+}
+
+// GetBuildForInvoice returns the owner's build for an invoice number (nil if none).
+func GetBuildForInvoice(ctx context.Context, dbURL, ownerID, invoiceNumber string) (*Build, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	return scanBuild(pool.QueryRow(ctx, `SELECT `+buildColumns+` FROM builds WHERE owner_id = $1 AND invoice_number = $2`, ownerID, invoiceNumber))
+}
+
+// GetBuildByShareToken resolves a public embed token to its build (nil if unknown or revoked).
+func GetBuildByShareToken(ctx context.Context, dbURL, token string) (*Build, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	if token == "" {
+		return nil, nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	return scanBuild(pool.QueryRow(ctx, `SELECT `+buildColumns+` FROM builds WHERE share_token_hash = $1`, HashShareToken(token)))
+}
+
+// SetBuildShareToken stores the hash of token for the owner's build; an empty token
+// revokes sharing. Returns false if the build does not exist.
+func SetBuildShareToken(ctx context.Context, dbURL, ownerID string, id int, token string) (bool, error) {
+	if dbURL == "" {
+		return false, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var hash *string
+	if token != "" {
+		h := HashShareToken(token)
+		hash = &h
+	}
+	tag, err := pool.Exec(ctx, `UPDATE builds SET share_token_hash = $3 WHERE owner_id = $1 AND id = $2`, ownerID, id, hash)
+	if err != nil {
+		return false, fmt.Errorf("update build share token: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// GetBuildProgress loads required parts and shopping_list status for a build.
+func GetBuildProgress(ctx context.Context, dbURL string, b Build) (*BuildProgress, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT assembly_id, assembly_name, part_id, quantity
+FROM build_parts WHERE build_id = $1
+ORDER BY assembly_id, part_id
+`, b.ID)
+	if err != nil {
+		return nil, fmt.Errorf("query build_parts: %w", err)
+	}
+	var parts []BuildPart
+	for rows.Next() {
+		var p BuildPart
+		if err := rows.Scan(&p.AssemblyID, &p.AssemblyName, &p.PartID, &p.Quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan build_part: %w", err)
+		}
+		parts = append(parts, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query build_parts: %w", err)
+	}
+
+	rows, err = pool.Query(ctx, `
+SELECT item_id,
+       COALESCE(SUM(quantity) FILTER (WHERE ordered), 0),
+       COALESCE(SUM(quantity) FILTER (WHERE received), 0)
+FROM shopping_list WHERE build_id = $1
+GROUP BY item_id
+`, b.ID)
+	if err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+	defer rows.Close()
+	ordered, received := map[string]float64{}, map[string]float64{}
+	for rows.Next() {
+		var item string
+		var o, r float64
+		if err := rows.Scan(&item, &o, &r); err != nil {
+			return nil, fmt.Errorf("scan shopping totals: %w", err)
+		}
+		ordered[item], received[item] = o, r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+
+	asm, ord, rec := ComputeBuildProgress(parts, ordered, received)
+	return &BuildProgress{Build: b, Assemblies: asm, OrderedPercent: ord, ReceivedPercent: rec}, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import "testing"
+
+func TestBuildPartsFromBOM(t *testing.T) {
+	t.Parallel()
+	perAssy := []BOMNode{
+		{PartID: "VAN-KIT", Name: "Kitchen", Quantity: 1, IsAssembly: true, Children: []BOMNode{
+			{PartID: "SCREW", Quantity: 10},
+			{PartID: "TAP", Quantity: 1},
+		}},
+		{PartID: "VAN-BED", Name: "Bed", Quantity: 2, IsAssembly: true, Children: []BOMNode{
+			{PartID: "SCREW", Quantity: 4},
+		}},
+		{PartID: "VAN-KIT", Name: "Kitchen", Quantity: 1, IsAssembly: true, Children: []BOMNode{
+			{PartID: "TAP", Quantity: 1},
+		}},
+	}
+	got := BuildPartsFromBOM(perAssy)
+	want := []BuildPart{
+		{AssemblyID: "VAN-BED", AssemblyName: "Bed", PartID: "SCREW", Quantity: 8},
+		{AssemblyID: "VAN-KIT", AssemblyName: "Kitchen", PartID: "SCREW", Quantity: 10},
+		{AssemblyID: "VAN-KIT", AssemblyName: "Kitchen", PartID: "TAP", Quantity: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("part %d: got %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestComputeBuildProgress(t *testing.T) {
+	t.Parallel()
+	parts := []BuildPart{
+		{AssemblyID: "KIT", PartID: "SCREW", Quantity: 10},
+		{AssemblyID: "KIT", PartID: "TAP", Quantity: 2},
+		{AssemblyID: "BED", PartID: "SCREW", Quantity: 10},
+	}
+	// 10 of 20 screws ordered (50% coverage), both taps ordered; 1 tap received
+	asm, ord, rec := ComputeBuildProgress(parts,
+		map[string]float64{"SCREW": 10, "TAP": 5},
+		map[string]float64{"TAP": 1},
+	)
+	if len(asm) != 2 || asm[0].AssemblyID != "KIT" || asm[1].AssemblyID != "BED" {
+		t.Fatalf("unexpected assemblies: %+v", asm)
+	}
+	// KIT: (10*0.5 + 2*1) / 12 = 58.3%
+	if asm[0].OrderedPercent != 58.3 || asm[0].Parts != 2 {
+		t.Fatalf("unexpected KIT progress: %+v", asm[0])
+	}
+	// KIT received: (2*0.5)/12 = 8.3%
+	if asm[0].ReceivedPercent != 8.3 {
+		t.Fatalf("unexpected KIT received: %+v", asm[0])
+	}
+	if asm[1].OrderedPercent != 50 || asm[1].ReceivedPercent != 0 {
+		t.Fatalf("unexpected BED progress: %+v", asm[1])
+	}
+	// overall: (20*0.5 + 2) / 22 = 54.5%; received 1/22 = 4.5%
+	if ord != 54.5 || rec != 4.5 {
+		t.Fatalf("unexpected overall: %v %v", ord, rec)
+	}
+}
+
+func TestHashShareToken(t *testing.T) {
+	t.Parallel()
+	if HashShareToken("a") == HashShareToken("b") || len(HashShareToken("a")) != 64 {
+		t.Fatalf("unexpected hash")
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"log"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+)
+
+// sharedCache holds Xero item and contact lookups and BOM snapshot lists. It is in
+// process memory unless UseCache swaps in one shared by every instance (Redis).
+var sharedCache cache.Cache = cache.NewMemory()
+
+// UseCache makes c the cache for lookups. Call it once at startup, before serving.
+func UseCache(c cache.Cache) {
+	sharedCache = c
+}
+
+// SharedCache returns the cache set by UseCache, for callers that pass it on.
+func SharedCache() cache.Cache {
+	return sharedCache
+}
+
+// cacheMiss logs a failed cache call; callers carry on as if nothing was cached.
+func cacheMiss(op string, err error) {
+	log.Printf("cache: %s: %v", op, err)
+}
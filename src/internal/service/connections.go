@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Connection list paging defaults.
+const (
+	DefaultConnectionsPerPage = 20
+	MaxConnectionsPerPage     = 100
+)
+
+// Connection expiry statuses (of the stored access token; an expired one is refreshed on next use).
+const (
+	ConnectionActive   = "active"
+	ConnectionExpiring = "expiring"
+	ConnectionExpired  = "expired"
+)
+
+// connectionExpiringWindow is how close to expiry a token is reported as "expiring".
+const connectionExpiringWindow = 5 * time.Minute
+
+// ConnectionSummary is the browser-safe view of a stored Xero connection. It never
+// carries tokens.
+type ConnectionSummary struct {
+	TenantID   string    `json:"tenant_id"`
+	TenantName string    `json:"tenant_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Status     string    `json:"status"`
+}
+
+// ConnectionPage is one page of connection summaries.
+type ConnectionPage struct {
+	Connections []ConnectionSummary `json:"connections"`
+	Page        int                 `json:"page"`
+	PerPage     int                 `json:"per_page"`
+	Total       int                 `json:"total"`
+}
+
+// ConnectionListOptions filters and pages ListConnectionSummaries.
+type ConnectionListOptions struct {
+	Page    int
+	PerPage int
+	// Status limits results to one of the Connection* statuses when set.
+	Status string
+	// Query matches tenant name or tenant id (case-insensitive substring).
+	Query string
+	// Sort is a ?sort= value: created_at or tenant_name, "-" in front for
+	// descending. Empty sorts newest first.
+	Sort string
+}
+
+// connectionListSpec pages the connection list, newest first unless sorted otherwise.
+var connectionListSpec = ListSpec{
+	PerPage:     DefaultConnectionsPerPage,
+	MaxPerPage:  MaxConnectionsPerPage,
+	Sorts:       map[string]string{"created_at": "COALESCE(created_at, 0)", "tenant_name": "tenant_name"},
+	DefaultSort: "-created_at",
+	TieBreak:    "tenant_id",
+}
+
+// ParseConnectionListOptions reads page, per_page, status and q from query values,
+// clamping paging to sane bounds.
+func ParseConnectionListOptions(v url.Values) (ConnectionListOptions, error) {
+	req, err := connectionListSpec.Parse(v)
+	opts := ConnectionListOptions{Page: req.Page, PerPage: req.PerPage, Query: req.Query}
+	if err != nil {
+		return opts, err
+	}
+	if v.Get("sort") != "" {
+		opts.Sort = req.SortParam()
+	}
+	switch s := strings.ToLower(strings.TrimSpace(v.Get("status"))); s {
+	case "", ConnectionActive, ConnectionExpiring, ConnectionExpired:
+		opts.Status = s
+	default:
+		return opts, invalid("status", "invalid status %q", s)
+	}
+	return opts, nil
+}
+
+// connectionStatus classifies a token expiry relative to now.
+func connectionStatus(expiresAt, now time.Time) string {
+	switch {
+	case !expiresAt.After(now):
+		return ConnectionExpired
+	case expiresAt.Sub(now) <= connectionExpiringWindow:
+		return ConnectionExpiring
+	default:
+		return ConnectionActive
+	}
+}
+
+// connectionFilterSQL is shared by the count and page queries. Params: $1 owner,
+// $2 query, $3 status, $4 now, $5 end of the expiring window (unix seconds).
+const connectionFilterSQL = `
+WHERE owner_id = $1
+  AND ($2 = '' OR tenant_name ILIKE '%' || $2 || '%' OR tenant_id ILIKE '%' || $2 || '%')
+  AND (
+    $3 = ''
+    OR ($3 = 'expired' AND COALESCE(expires_at, 0) <= $4)
+    OR ($3 = 'expiring' AND expires_at > $4 AND expires_at <= $5)
+    OR ($3 = 'active' AND expires_at > $5)
+  )`
+
+// ListConnectionSummaries returns one page of the owner's connections, sorted by
+// opts.Sort.
+// Only non-secret columns are read.
+func ListConnectionSummaries(ctx context.Context, dbURL, ownerID string, opts ConnectionListOptions) (ConnectionPage, error) {
+	sort, desc := strings.CutPrefix(opts.Sort, "-")
+	req := connectionListSpec.Normalize(PageRequest{Page: opts.Page, PerPage: opts.PerPage, Sort: sort, Desc: desc})
+	opts.Page, opts.PerPage = req.Page, req.PerPage
+	page := ConnectionPage{Connections: []ConnectionSummary{}, Page: opts.Page, PerPage: opts.PerPage}
+	if dbURL == "" {
+		return page, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return page, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	now := time.Now()
+	args := []any{ownerID, opts.Query, opts.Status, now.Unix(), now.Add(connectionExpiringWindow).Unix()}
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM xero_connections `+connectionFilterSQL, args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("count connections: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, `
+SELECT tenant_id, tenant_name, COALESCE(created_at, 0), COALESCE(expires_at, 0)
+FROM xero_connections
+`+connectionFilterSQL+`
+ORDER BY `+connectionListSpec.orderBy(req)+`
+LIMIT $6 OFFSET $7
+`, append(args, req.PerPage, req.Offset())...)
+	if err != nil {
+		return page, fmt.Errorf("query connections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cs                   ConnectionSummary
+			createdAt, expiresAt int64
+		)
+		if err := rows.Scan(&cs.TenantID, &cs.TenantName, &createdAt, &expiresAt); err != nil {
+			return page, fmt.Errorf("scan conn: %w", err)
+		}
+		cs.CreatedAt = time.Unix(createdAt, 0).UTC()
+		cs.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+		cs.Status = connectionStatus(cs.ExpiresAt, now)
+		page.Connections = append(page.Connections, cs)
+	}
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("query connections: %w", err)
+	}
+	return page, nil
+}
+
+// SetConnectionTenantName records the organisation name Xero reported for a tenant.
+func SetConnectionTenantName(ctx context.Context, dbURL, ownerID, tenantID, tenantName string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `UPDATE xero_connections SET tenant_name = $3 WHERE owner_id = $1 AND tenant_id = $2`, ownerID, tenantID, tenantName); err != nil {
+		return fmt.Errorf("update tenant name: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseConnectionListOptions(t *testing.T) {
+	t.Parallel()
+	opts, err := ParseConnectionListOptions(url.Values{})
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	if opts.Page != 1 || opts.PerPage != DefaultConnectionsPerPage || opts.Status != "" || opts.Query != "" {
+		t.Fatalf("unexpected defaults: %+v", opts)
+	}
+
+	opts, err = ParseConnectionListOptions(url.Values{"page": {"3"}, "per_page": {"1000"}, "status": {"Expired"}, "q": {" acme "}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := ConnectionListOptions{Page: 3, PerPage: MaxConnectionsPerPage, Status: ConnectionExpired, Query: "acme"}
+	if opts != want {
+		t.Fatalf("got %+v want %+v", opts, want)
+	}
+
+	for _, v := range []url.Values{
+		{"page": {"0"}},
+		{"page": {"x"}},
+		{"per_page": {"-1"}},
+		{"status": {"revoked"}},
+	} {
+		if _, err := ParseConnectionListOptions(v); err == nil {
+			t.Fatalf("expected error for %v", v)
+		}
+	}
+}
+
+func TestConnectionStatus(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1_700_000_000, 0)
+	cases := []struct {
+		expires time.Time
+		want    string
+	}{
+		{now.Add(-time.Second), ConnectionExpired},
+		{now, ConnectionExpired},
+		{now.Add(time.Minute), ConnectionExpiring},
+		{now.Add(time.Hour), ConnectionActive},
+	}
+	for _, c := range cases {
+		if got := connectionStatus(c.expires, now); got != c.want {
+			t.Fatalf("connectionStatus(%v) = %q want %q", c.expires.Sub(now), got, c.want)
+		}
+	}
+}
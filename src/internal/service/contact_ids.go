@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// contactIDCacheTTL bounds how long a supplier's Xero ContactID is reused. Contacts
+// are rarely recreated, and a stale id only fails the purchase order it is used for.
+const contactIDCacheTTL = time.Hour
+
+// contactKey is the cache key of a tenant's contact id for accountNumber.
+func contactKey(tenantID, accountNumber string) string {
+	return "xero:contact:" + tenantID + ":" + accountNumber
+}
+
+// ContactIDByAccountNumber returns the ContactID of the tenant's contact with
+// accountNumber ("" when there is none), caching ids found in c (nil caches nothing).
+func ContactIDByAccountNumber(ctx context.Context, c cache.Cache, xc *xero.Client, accessToken, tenantID, accountNumber string) (string, error) {
+	if c == nil {
+		return xc.GetContactIDByAccountNumber(ctx, accessToken, tenantID, accountNumber)
+	}
+	key := contactKey(tenantID, accountNumber)
+	cached, err := c.Get(ctx, key)
+	if err != nil {
+		cacheMiss("get contact", err)
+	}
+	if id := string(cached[key]); id != "" {
+		return id, nil
+	}
+	id, err := xc.GetContactIDByAccountNumber(ctx, accessToken, tenantID, accountNumber)
+	if err != nil || id == "" {
+		return id, err
+	}
+	if err := c.Set(ctx, map[string][]byte{key: []byte(id)}, contactIDCacheTTL); err != nil {
+		cacheMiss("set contact", err)
+	} else {
+		markCacheFilled(ctx, c, cacheKindContacts, tenantID, contactIDCacheTTL)
+	}
+	return id, nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestContactIDByAccountNumber_Cached(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if strings.Contains(r.URL.Query().Get("where"), `"SUP-1"`) {
+			_, _ = w.Write([]byte(`{"Contacts":[{"ContactID":"contact-1","AccountNumber":"SUP-1"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Contacts":[]}`))
+	}))
+	defer ts.Close()
+	xc := xero.NewClient(ts.Client(), ts.URL)
+	ctx := context.Background()
+	c := cache.NewMemory()
+
+	for range 2 {
+		if id, err := ContactIDByAccountNumber(ctx, c, xc, "at", "tenant-1", "SUP-1"); id != "contact-1" || err != nil {
+			t.Fatalf("SUP-1 = %q, %v", id, err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("%d calls to Xero, want 1", n)
+	}
+
+	// unknown accounts are asked about every time: the contact may be added
+	for range 2 {
+		if id, err := ContactIDByAccountNumber(ctx, c, xc, "at", "tenant-1", "SUP-X"); id != "" || err != nil {
+			t.Fatalf("SUP-X = %q, %v", id, err)
+		}
+	}
+	// other tenants and a nil cache do not share the entry
+	_, _ = ContactIDByAccountNumber(ctx, c, xc, "at", "tenant-2", "SUP-1")
+	_, _ = ContactIDByAccountNumber(ctx, nil, xc, "at", "tenant-1", "SUP-1")
+	if n := calls.Load(); n != 5 {
+		t.Fatalf("%d calls to Xero, want 5", n)
+	}
+}
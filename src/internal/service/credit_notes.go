@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrCreditNoteApplied is returned by ApplyCreditNote for a credit note already taken
+// off the shopping list.
+var ErrCreditNoteApplied = errors.New("credit note already applied to the shopping list")
+
+// creditNoteStatuses are the credit note statuses that reverse shopping list rows;
+// drafts may still change and voided ones credit nothing.
+var creditNoteStatuses = []string{"AUTHORISED", "PAID"}
+
+// CreditRowChange is what applying a credit note does to one unordered shopping_list
+// row: its quantity goes From -> To, and a row brought to 0 is archived. Version is
+// the row version the plan was made from.
+type CreditRowChange struct {
+	ListID  int     `json:"list_id"`
+	Version int     `json:"version"`
+	From    float64 `json:"from"`
+	To      float64 `json:"to"`
+}
+
+// CreditMatch is one part a credit note takes back: Credited is the quantity its lines
+// resolve to, Unordered and Ordered what the credited invoices have on the shopping
+// list. Rows reduce the unordered quantity; ordered rows are never changed.
+type CreditMatch struct {
+	PartID    string            `json:"part_id"`
+	Name      string            `json:"name"`
+	UOM       string            `json:"uom,omitempty"`
+	Credited  float64           `json:"credited"`
+	Unordered float64           `json:"unordered"`
+	Ordered   float64           `json:"ordered"`
+	Rows      []CreditRowChange `json:"rows,omitempty"`
+}
+
+// Removed is the quantity taken off the shopping list.
+func (m CreditMatch) Removed() float64 {
+	var n float64
+	for _, r := range m.Rows {
+		n += r.From - r.To
+	}
+	return roundQty(n)
+}
+
+// Left is the credited quantity with no unordered row to come off: already ordered,
+// or never added to the shopping list.
+func (m CreditMatch) Left() float64 {
+	return roundQty(max(m.Credited-m.Removed(), 0))
+}
+
+// CreditNotePlan is what applying a credit note to the shopping list would do.
+// AppliedAt is set when it has been applied already.
+type CreditNotePlan struct {
+	Number    string        `json:"credit_note_number"`
+	Invoices  []string      `json:"invoices"`
+	Matches   []CreditMatch `json:"matches"`
+	AppliedAt int64         `json:"applied_at,omitempty"`
+}
+
+// Changes returns the row changes of every match.
+func (p CreditNotePlan) Changes() []CreditRowChange {
+	var out []CreditRowChange
+	for _, m := range p.Matches {
+		out = append(out, m.Rows...)
+	}
+	return out
+}
+
+// MatchCreditNote matches the parts a credit note resolves to (credited) with the
+// shopping list rows of the invoices it credits, taking each part's quantity off its
+// unordered rows, newest first, until it is used up or no unordered quantity is left.
+func MatchCreditNote(credited []LeafTotal, rows []ShoppingListEntry) []CreditMatch {
+	byItem := map[string][]ShoppingListEntry{}
+	for _, r := range rows {
+		byItem[r.ItemID] = append(byItem[r.ItemID], r)
+	}
+	out := make([]CreditMatch, 0, len(credited))
+	for _, lt := range credited {
+		m := CreditMatch{PartID: lt.PartID, Name: lt.Name, UOM: lt.UOM, Credited: lt.Quantity}
+		items := byItem[lt.PartID]
+		sort.Slice(items, func(i, j int) bool { return items[i].ListID > items[j].ListID })
+		remaining := lt.Quantity
+		for _, r := range items {
+			if r.Ordered {
+				m.Ordered += r.Quantity
+				continue
+			}
+			m.Unordered += r.Quantity
+			if remaining <= 0 {
+				continue
+			}
+			take := min(remaining, r.Quantity)
+			remaining = roundQty(remaining - take)
+			m.Rows = append(m.Rows, CreditRowChange{ListID: r.ListID, Version: r.Version, From: r.Quantity, To: roundQty(r.Quantity - take)})
+		}
+		m.Ordered, m.Unordered = roundQty(m.Ordered), roundQty(m.Unordered)
+		out = append(out, m)
+	}
+	return out
+}
+
+// PlanCreditNote fetches sales credit note number from Xero, resolves its lines
+// through the owner's parts lists like an invoice, and matches the parts with the
+// shopping list rows of the invoices it is allocated to. invoice names the credited
+// invoice when the credit note is not allocated to one. msg is a user-facing reason
+// there is nothing to plan (unknown, not a sales credit note, a draft or voided one,
+// no invoice, no item lines, BOM problems); err is a failure.
+func PlanCreditNote(ctx context.Context, dbURL, ownerID string, xc *xero.Client, creds XeroCredentials, number, invoice string) (CreditNotePlan, string, error) {
+	plan := CreditNotePlan{Number: number}
+	if dbURL == "" {
+		return plan, "", fmt.Errorf("db url missing")
+	}
+	cn, err := xc.GetCreditNote(ctx, creds.AccessToken, creds.TenantID, number)
+	switch {
+	case errors.Is(err, xero.ErrNotFound):
+		return plan, "Credit note " + number + " was not found in Xero", nil
+	case err != nil:
+		return plan, "", fmt.Errorf("fetch credit note %s: %w", number, err)
+	case cn.Type != xero.CreditNoteTypeSales:
+		return plan, fmt.Sprintf("Credit note %s is not against sales invoices (type %s)", number, cn.Type), nil
+	case !slices.Contains(creditNoteStatuses, cn.Status):
+		return plan, fmt.Sprintf("Credit note %s is %s; only %s credit notes change the shopping list",
+			number, cn.Status, strings.Join(creditNoteStatuses, " or ")), nil
+	}
+	plan.Number = cn.CreditNoteNumber
+	plan.Invoices = cn.Invoices
+	if len(plan.Invoices) == 0 {
+		if invoice == "" {
+			return plan, "Credit note " + number + " is not allocated to an invoice in Xero; enter the invoice it credits", nil
+		}
+		plan.Invoices = []string{invoice}
+	}
+
+	roots := make([]RootItem, 0, len(cn.Lines))
+	for _, li := range cn.Lines {
+		if li.ItemCode != "" && li.Quantity > 0 {
+			roots = append(roots, RootItem{PartID: li.ItemCode, Name: li.Name, Quantity: li.Quantity})
+		}
+	}
+	if len(roots) == 0 {
+		return plan, "No items found on credit note " + number, nil
+	}
+	settings, err := GetOwnerSettings(ctx, dbURL, ownerID)
+	if err != nil {
+		return plan, "", err
+	}
+	bom, msg, err := ResolveInvoiceBOM(ctx, dbURL, ownerID, roots, settings.BOMMaxDepth, nil, nil, xc, creds.AccessToken, creds.TenantID)
+	if err != nil {
+		return plan, "", fmt.Errorf("resolve bom: %w", err)
+	}
+	if msg != "" {
+		return plan, msg, nil
+	}
+	credited := AggregateLeafTotals(BuildPerAssemblyBOM(bom, roots))
+
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return plan, "", fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	err = pool.QueryRow(ctx, `
+SELECT applied_at FROM applied_credit_notes WHERE owner_id = $1 AND credit_note_number = $2
+`, ownerID, plan.Number).Scan(&plan.AppliedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return plan, "", fmt.Errorf("load applied_credit_note: %w", err)
+	}
+	rows, err := pool.Query(ctx, `
+SELECT list_id, item_id, quantity, ordered, COALESCE(source_invoice, ''), version
+FROM shopping_list
+WHERE owner_id = $1 AND source_invoice = ANY($2) AND archived_at IS NULL
+`, ownerID, plan.Invoices)
+	if err != nil {
+		return plan, "", fmt.Errorf("query shopping_list: %w", err)
+	}
+	defer rows.Close()
+	var entries []ShoppingListEntry
+	for rows.Next() {
+		var e ShoppingListEntry
+		if err := rows.Scan(&e.ListID, &e.ItemID, &e.Quantity, &e.Ordered, &e.SourceInvoice, &e.Version); err != nil {
+			return plan, "", fmt.Errorf("scan shopping row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return plan, "", err
+	}
+	plan.Matches = MatchCreditNote(credited, entries)
+	return plan, "", nil
+}
+
+// ApplyCreditNote makes plan's row changes as appliedBy in one transaction: quantities
+// are reduced and rows brought to 0 archived (restorable from the shopping list). It
+// fails with ErrCreditNoteApplied when the credit note was applied before, and with a
+// *ShoppingConflictError when a row changed since the plan was made.
+func ApplyCreditNote(ctx context.Context, dbURL, ownerID, appliedBy string, plan CreditNotePlan) error {
+	if appliedBy == "" {
+		return fmt.Errorf("user id missing")
+	}
+	changes := plan.Changes()
+	return WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+INSERT INTO applied_credit_notes (owner_id, credit_note_number, invoices, applied_by)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (owner_id, credit_note_number) DO NOTHING
+`, ownerID, plan.Number, plan.Invoices, appliedBy)
+		if err != nil {
+			return fmt.Errorf("insert applied_credit_note: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrCreditNoteApplied
+		}
+
+		if len(changes) > 0 {
+			expected := make(map[int]int, len(changes))
+			ids := make([]int, 0, len(changes))
+			for _, c := range changes {
+				expected[c.ListID] = c.Version
+				ids = append(ids, c.ListID)
+			}
+			current, err := lockShoppingVersions(ctx, tx, ownerID, ids)
+			if err != nil {
+				return err
+			}
+			if stale := staleShoppingRows(expected, current); len(stale) > 0 {
+				return &ShoppingConflictError{ListIDs: stale}
+			}
+		}
+		for _, c := range changes {
+			sql := `UPDATE shopping_list SET quantity = $3, updated_at = (extract(epoch from now()))::bigint
+WHERE list_id = $1 AND owner_id = $2 AND ordered = FALSE AND archived_at IS NULL`
+			args := []any{c.ListID, ownerID, c.To}
+			if c.To <= 0 {
+				sql = `UPDATE shopping_list SET archived_at = (extract(epoch from now()))::bigint
+WHERE list_id = $1 AND owner_id = $2 AND ordered = FALSE AND archived_at IS NULL`
+				args = args[:2]
+			}
+			tag, err := tx.Exec(ctx, sql, args...)
+			if err != nil {
+				return fmt.Errorf("update shopping_list: %w", err)
+			}
+			if tag.RowsAffected() != 1 {
+				// no longer an unordered row
+				return &ShoppingConflictError{ListIDs: []int{c.ListID}}
+			}
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  AuditCreditNoteApplied,
+			Detail:  map[string]any{"credit_note": plan.Number, "invoices": plan.Invoices, "applied_by": appliedBy, "rows": changes},
+		})
+	})
+}
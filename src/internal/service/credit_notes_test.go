@@ -0,0 +1,45 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchCreditNote(t *testing.T) {
+	t.Parallel()
+	credited := []LeafTotal{
+		{PartID: "BOLT", Name: "Bolt", Quantity: 12},
+		{PartID: "HINGE", Name: "Hinge", Quantity: 2},
+		{PartID: "PANEL", Name: "Panel", Quantity: 1},
+	}
+	rows := []ShoppingListEntry{
+		{ListID: 1, ItemID: "BOLT", Quantity: 8, Version: 3},
+		{ListID: 2, ItemID: "HINGE", Quantity: 4, Ordered: true},
+		{ListID: 5, ItemID: "BOLT", Quantity: 6, Version: 1},
+		{ListID: 6, ItemID: "HINGE", Quantity: 1, Version: 2},
+		{ListID: 7, ItemID: "NUT", Quantity: 10, Version: 1}, // not credited
+	}
+
+	got := MatchCreditNote(credited, rows)
+	want := []CreditMatch{
+		// newest row first: 6 of 6 off row 5, then 6 of 8 off row 1
+		{PartID: "BOLT", Name: "Bolt", Credited: 12, Unordered: 14, Rows: []CreditRowChange{
+			{ListID: 5, Version: 1, From: 6, To: 0},
+			{ListID: 1, Version: 3, From: 8, To: 2},
+		}},
+		// the ordered row is left alone
+		{PartID: "HINGE", Name: "Hinge", Credited: 2, Unordered: 1, Ordered: 4, Rows: []CreditRowChange{
+			{ListID: 6, Version: 2, From: 1, To: 0},
+		}},
+		{PartID: "PANEL", Name: "Panel", Credited: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MatchCreditNote:\n got %+v\nwant %+v", got, want)
+	}
+	if got[0].Removed() != 12 || got[0].Left() != 0 || got[1].Removed() != 1 || got[1].Left() != 1 || got[2].Left() != 1 {
+		t.Fatalf("removed/left: %v/%v %v/%v %v", got[0].Removed(), got[0].Left(), got[1].Removed(), got[1].Left(), got[2].Left())
+	}
+	if n := len((CreditNotePlan{Matches: got}).Changes()); n != 3 {
+		t.Fatalf("%d changes, want 3", n)
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// currencyRateCacheTTL bounds how long the rate of a tenant's newest bill in a
+// currency is reused; a new bill only moves it by a day's rate.
+const currencyRateCacheTTL = time.Hour
+
+// SupplierCurrencies are the currencies suppliers invoice in, from their Xero
+// contacts' default currency, and Xero's rates for them. The zero value has every
+// supplier in the organisation's base currency.
+type SupplierCurrencies struct {
+	// ByContact maps AccountNumber -> ISO 4217 code for the contacts whose default
+	// currency is not the base currency
+	ByContact map[string]string `json:"by_contact,omitempty"`
+	// Rates are units of a currency per unit of the base currency, as Xero converted
+	// the newest bill in it; currencies without a bill yet are missing
+	Rates map[string]float64 `json:"rates,omitempty"`
+}
+
+// Currency is the code contact invoices in; "" for the base currency.
+func (c SupplierCurrencies) Currency(contact string) string {
+	return c.ByContact[contact]
+}
+
+// Rate is units of contact's currency per unit of the base currency: 1 in the base
+// currency, 0 when Xero has no rate for it yet.
+func (c SupplierCurrencies) Rate(contact string) float64 {
+	code := c.ByContact[contact]
+	if code == "" {
+		return 1
+	}
+	return c.Rates[code]
+}
+
+// ToBase converts amount in contact's currency to the base currency; ok is false
+// when the rate is unknown.
+func (c SupplierCurrencies) ToBase(contact string, amount float64) (float64, bool) {
+	rate := c.Rate(contact)
+	if rate <= 0 {
+		return 0, false
+	}
+	return amount / rate, true
+}
+
+// NoRateError is returned by UnitPrice for a line priced only by a Xero purchase
+// price when Xero has no rate yet for the supplier's currency. Such a line must not
+// be sent unpriced: Xero would fill in the item's base currency price as if it were
+// in the supplier's currency.
+type NoRateError struct {
+	Currency string
+}
+
+func (e *NoRateError) Error() string {
+	return "no " + e.Currency + " exchange rate in Xero yet (it comes from the newest " + e.Currency + " bill)"
+}
+
+// UnitPrice is it.UnitPrice in contact's currency. Agreed supplier prices are in it
+// already; Xero purchase prices are in the base currency and are converted. When the
+// rate is unknown that fails with a *NoRateError.
+func (c SupplierCurrencies) UnitPrice(it ContactItem, contact string, xeroPrices map[string]float64) (price float64, source string, err error) {
+	price, source = it.UnitPrice(xeroPrices)
+	if source != PriceSourceXero {
+		return price, source, nil
+	}
+	rate := c.Rate(contact)
+	if rate <= 0 {
+		return 0, "", &NoRateError{Currency: c.Currency(contact)}
+	}
+	return roundCost(price * rate), source, nil
+}
+
+// LoadSupplierCurrencies looks up the Xero contacts of accounts (AccountNumbers) and
+// the rates of the foreign currencies they invoice in. base is the organisation's
+// base currency; contacts defaulting to it are left out. Rates are cached in c (nil
+// caches nothing).
+func LoadSupplierCurrencies(ctx context.Context, c cache.Cache, xc *xero.Client, accessToken, tenantID, base string, accounts []string) (SupplierCurrencies, error) {
+	codes, err := xc.GetContactCurrencies(ctx, accessToken, tenantID, accounts)
+	if err != nil {
+		return SupplierCurrencies{}, err
+	}
+	out := SupplierCurrencies{ByContact: map[string]string{}, Rates: map[string]float64{}}
+	seen := map[string]bool{}
+	var currencies []string
+	for account, code := range codes {
+		if strings.EqualFold(code, base) {
+			continue
+		}
+		out.ByContact[account] = code
+		if !seen[code] {
+			seen[code] = true
+			currencies = append(currencies, code)
+		}
+	}
+	sort.Strings(currencies)
+	for _, code := range currencies {
+		rate, err := currencyRate(ctx, c, xc, accessToken, tenantID, code)
+		if err != nil {
+			return SupplierCurrencies{}, err
+		}
+		if rate > 0 {
+			out.Rates[code] = rate
+		}
+	}
+	return out, nil
+}
+
+// currencyRate is the rate Xero converted the tenant's newest bill in code at, 0 when
+// there is none, caching rates found in c (nil caches nothing).
+func currencyRate(ctx context.Context, c cache.Cache, xc *xero.Client, accessToken, tenantID, code string) (float64, error) {
+	key := "xero:rate:" + tenantID + ":" + code
+	if c != nil {
+		cached, err := cache.GetJSON[float64](ctx, c, key)
+		if err != nil {
+			cacheMiss("get currency rate", err)
+		}
+		if rate, ok := cached[key]; ok {
+			return rate, nil
+		}
+	}
+	bill, found, err := xc.LatestBill(ctx, accessToken, tenantID, code)
+	if err != nil || !found || bill.CurrencyRate <= 0 {
+		return 0, err
+	}
+	if c != nil {
+		if err := cache.SetJSON(ctx, c, map[string]float64{key: bill.CurrencyRate}, currencyRateCacheTTL); err != nil {
+			cacheMiss("set currency rate", err)
+		}
+	}
+	return bill.CurrencyRate, nil
+}
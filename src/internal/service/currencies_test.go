@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestLoadSupplierCurrencies(t *testing.T) {
+	t.Parallel()
+	var bills atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		where := r.URL.Query().Get("where")
+		switch {
+		case strings.Contains(r.URL.Path, "/Contacts"):
+			_, _ = w.Write([]byte(`{"Contacts":[
+				{"ContactID":"c-1","AccountNumber":"SUP-1","DefaultCurrency":"GBP"},
+				{"ContactID":"c-2","AccountNumber":"SUP-2","DefaultCurrency":"EUR"},
+				{"ContactID":"c-3","AccountNumber":"SUP-3","DefaultCurrency":"USD"},
+				{"ContactID":"c-4","AccountNumber":"SUP-4"}]}`))
+		case strings.Contains(where, `CurrencyCode=="EUR"`):
+			bills.Add(1)
+			_, _ = w.Write([]byte(`{"Invoices":[{"InvoiceNumber":"BILL-1","CurrencyCode":"EUR","CurrencyRate":1.17}]}`))
+		default:
+			bills.Add(1)
+			_, _ = w.Write([]byte(`{"Invoices":[]}`))
+		}
+	}))
+	defer ts.Close()
+	xc := xero.NewClient(ts.Client(), ts.URL)
+	ctx := context.Background()
+	c := cache.NewMemory()
+
+	for range 2 {
+		cur, err := LoadSupplierCurrencies(ctx, c, xc, "at", "tenant-1", "GBP", []string{"SUP-1", "SUP-2", "SUP-3", "SUP-4"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := SupplierCurrencies{ByContact: map[string]string{"SUP-2": "EUR", "SUP-3": "USD"}, Rates: map[string]float64{"EUR": 1.17}}
+		if !reflect.DeepEqual(cur, want) {
+			t.Fatalf("got %+v, want %+v", cur, want)
+		}
+	}
+	// the EUR rate is cached; USD has no bill yet and is asked about again
+	if n := bills.Load(); n != 3 {
+		t.Fatalf("%d bill lookups, want 3", n)
+	}
+}
+
+func TestSupplierCurrencies(t *testing.T) {
+	t.Parallel()
+	cur := SupplierCurrencies{ByContact: map[string]string{"S-EU": "EUR", "S-US": "USD"}, Rates: map[string]float64{"EUR": 1.25}}
+	xeroPrices := map[string]float64{"BOLT": 0.2}
+
+	cases := []struct {
+		contact string
+		terms   SupplierTerms
+		price   float64
+		source  string
+		base    float64
+		known   bool
+		noRate  bool
+	}{
+		{"S-UK", SupplierTerms{}, 0.2, PriceSourceXero, 0.2, true, false},
+		{"S-EU", SupplierTerms{UnitPrice: 0.3}, 0.3, PriceSourceSupplier, 0.24, true, false},
+		{"S-EU", SupplierTerms{}, 0.25, PriceSourceXero, 0.2, true, false},
+		{"S-US", SupplierTerms{UnitPrice: 0.3}, 0.3, PriceSourceSupplier, 0, false, false},
+		// the Xero price cannot be converted to USD, and must not be sent as it is
+		{"S-US", SupplierTerms{}, 0, "", 0, false, true},
+	}
+	for _, tc := range cases {
+		price, source, err := cur.UnitPrice(ContactItem{ItemID: "BOLT", Terms: tc.terms}, tc.contact, xeroPrices)
+		if price != tc.price || source != tc.source {
+			t.Errorf("%s %+v: price %v %q, want %v %q", tc.contact, tc.terms, price, source, tc.price, tc.source)
+		}
+		var noRate *NoRateError
+		if errors.As(err, &noRate) != tc.noRate || (tc.noRate && noRate.Currency != "USD") {
+			t.Errorf("%s %+v: error %v", tc.contact, tc.terms, err)
+		}
+		if base, ok := cur.ToBase(tc.contact, price); base != tc.base || ok != tc.known {
+			t.Errorf("%s %v in base: %v %v, want %v %v", tc.contact, price, base, ok, tc.base, tc.known)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is wrapped by errors for a record that does not exist, or is not the
+// workspace's. Handlers answer it with 404.
+var ErrNotFound = errors.New("not found")
+
+// ValidationError is input a service function refused. Its message is written for
+// the user; handlers answer it with 400.
+type ValidationError struct {
+	Field   string // form field or JSON property; "" when not about one field
+	Message string
+}
+
+func (e *ValidationError) Error() string { return e.Message }
+
+// invalid returns a *ValidationError for field with a formatted message.
+func invalid(field, format string, args ...any) error {
+	return &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Export kinds.
+const (
+	ExportPicklist = "picklist"
+	ExportKit      = "kit"
+	ExportBOM      = "bom"
+	ExportContacts = "contacts"
+)
+
+// Export is a generated file kept in object storage for its owner to download again.
+type Export struct {
+	ID          int    `json:"id"`
+	OwnerID     string `json:"owner_id"`
+	Kind        string `json:"kind"`
+	Filename    string `json:"filename"`
+	StorageKey  string `json:"storage_key"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// RecordExport inserts an export row for an object already stored and returns its id.
+func RecordExport(ctx context.Context, dbURL string, e Export) (int, error) {
+	if dbURL == "" {
+		return 0, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return 0, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var id int
+	err = pool.QueryRow(ctx, `
+INSERT INTO exports (owner_id, kind, filename, storage_key, content_type, size_bytes)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id
+`, e.OwnerID, e.Kind, e.Filename, e.StorageKey, e.ContentType, e.SizeBytes).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("insert export: %w", err)
+	}
+	return id, nil
+}
+
+// ListExports returns the owner's most recent exports, newest first, at most limit.
+func ListExports(ctx context.Context, dbURL, ownerID string, limit int) ([]Export, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT id, owner_id, kind, filename, storage_key, content_type, size_bytes, created_at
+FROM exports
+WHERE owner_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`, ownerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query exports: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Export
+	for rows.Next() {
+		var e Export
+		if err := rows.Scan(&e.ID, &e.OwnerID, &e.Kind, &e.Filename, &e.StorageKey, &e.ContentType, &e.SizeBytes, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan export: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// GetExport returns one of the owner's exports (nil if not found or someone else's).
+func GetExport(ctx context.Context, dbURL, ownerID string, id int) (*Export, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var e Export
+	err = pool.QueryRow(ctx, `
+SELECT id, owner_id, kind, filename, storage_key, content_type, size_bytes, created_at
+FROM exports WHERE id = $1 AND owner_id = $2
+`, id, ownerID).Scan(&e.ID, &e.OwnerID, &e.Kind, &e.Filename, &e.StorageKey, &e.ContentType, &e.SizeBytes, &e.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query export: %w", err)
+	}
+	return &e, nil
+}
+
+// DeleteExport removes one of the owner's export rows. The caller deletes the stored
+// object.
+func DeleteExport(ctx context.Context, dbURL, ownerID string, id int) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `DELETE FROM exports WHERE id = $1 AND owner_id = $2`, id, ownerID); err != nil {
+		return fmt.Errorf("delete export: %w", err)
+	}
+	return nil
+}
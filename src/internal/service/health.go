@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// refreshTokenLifetime is how long Xero keeps an unused refresh token valid.
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+// PingDB opens a pool and pings Postgres.
+func PingDB(ctx context.Context, dbURL string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if err := pool.Ping(ctx); err != nil {
+		return fmt.Errorf("ping db: %w", err)
+	}
+	return nil
+}
+
+// CountUsableConnections counts stored Xero connections whose refresh token is
+// present and was rotated recently enough to still be accepted by Xero.
+func CountUsableConnections(ctx context.Context, dbURL string) (int, error) {
+	conns, err := ListAllConnections(ctx, dbURL)
+	if err != nil {
+		return 0, err
+	}
+	return countUsable(conns, time.Now()), nil
+}
+
+func countUsable(conns []ConnectionInfo, now time.Time) int {
+	n := 0
+	for _, c := range conns {
+		if !c.HasRefreshToken || c.TenantID == "" {
+			continue
+		}
+		if now.Sub(time.Unix(c.UpdatedAt, 0)) > refreshTokenLifetime {
+			continue
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountUsable(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1_700_000_000, 0)
+	conns := []ConnectionInfo{
+		{TenantID: "t1", HasRefreshToken: true, UpdatedAt: now.Add(-time.Hour).Unix()},
+		{TenantID: "t2", HasRefreshToken: false, UpdatedAt: now.Unix()},                          // no refresh token
+		{TenantID: "t3", HasRefreshToken: true, UpdatedAt: now.Add(-61 * 24 * time.Hour).Unix()}, // refresh token expired
+	}
+	if got := countUsable(conns, now); got != 1 {
+		t.Fatalf("expected 1 usable connection, got %d", got)
+	}
+}
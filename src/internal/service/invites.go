@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NormalizeInviteCode trims and upper-cases a code as typed on /register.
+func NormalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ConsumeInviteCode uses up one sign-up of code. It reports false when the code is
+// unknown, expired or used up.
+func ConsumeInviteCode(ctx context.Context, dbURL, code string) (bool, error) {
+	if dbURL == "" {
+		return false, fmt.Errorf("db url missing")
+	}
+	code = NormalizeInviteCode(code)
+	if code == "" {
+		return false, nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	// atomic: concurrent sign-ups can't both take the last use
+	tag, err := pool.Exec(ctx, `
+UPDATE invite_codes SET uses = uses + 1
+WHERE code = $1 AND uses < max_uses AND (expires_at IS NULL OR expires_at > $2)
+`, code, time.Now().Unix())
+	if err != nil {
+		return false, fmt.Errorf("consume invite_code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseInviteCode gives back a use taken by ConsumeInviteCode, for when the
+// sign-up it was taken for failed.
+func ReleaseInviteCode(ctx context.Context, dbURL, code string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `UPDATE invite_codes SET uses = uses - 1 WHERE code = $1 AND uses > 0`, NormalizeInviteCode(code)); err != nil {
+		return fmt.Errorf("release invite_code: %w", err)
+	}
+	return nil
+}
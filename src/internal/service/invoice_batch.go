@@ -0,0 +1,106 @@
+package service
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxInvoicesPerRequest bounds how many invoices are resolved in one form submit.
+const MaxInvoicesPerRequest = 20
+
+// LeafSource is the quantity of a leaf part required by one invoice.
+type LeafSource struct {
+	Invoice  string  `json:"invoice"`
+	Quantity float64 `json:"quantity"`
+}
+
+// ParseInvoiceNumbers splits a pasted list of invoice numbers (comma, semicolon or
+// whitespace separated), dropping blanks and duplicates while keeping order.
+func ParseInvoiceNumbers(raw string) []string {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	return uniqueStrings(fields)
+}
+
+// MergeLeafTotals combines leaf totals from several invoices into one list (in
+// first-seen order), summing quantities and costs and recording each invoice's share
+// in Sources. invoices[i] is the invoice number for perInvoice[i].
+func MergeLeafTotals(invoices []string, perInvoice [][]LeafTotal) []LeafTotal {
+	var out []LeafTotal
+	idx := map[string]int{}
+	for i, totals := range perInvoice {
+		inv := ""
+		if i < len(invoices) {
+			inv = invoices[i]
+		}
+		for _, lt := range totals {
+			j, ok := idx[lt.PartID]
+			if !ok {
+				j = len(out)
+				idx[lt.PartID] = j
+				out = append(out, LeafTotal{PartID: lt.PartID, Name: lt.Name, UOM: lt.UOM, SubstituteFor: lt.SubstituteFor, UnitCost: lt.UnitCost})
+			}
+			m := &out[j]
+			m.Quantity = roundQty(m.Quantity + lt.Quantity)
+			m.CostIncomplete = m.CostIncomplete || lt.CostIncomplete
+			m.Sources = append(m.Sources, LeafSource{Invoice: inv, Quantity: lt.Quantity})
+		}
+	}
+	for i := range out {
+		out[i].TotalCost = roundCost(out[i].UnitCost * out[i].Quantity)
+	}
+	return out
+}
+
+// SourcesValue encodes Sources for a hidden form field ("INV-1:3,INV-2:2").
+func (lt LeafTotal) SourcesValue() string {
+	parts := make([]string, 0, len(lt.Sources))
+	for _, s := range lt.Sources {
+		parts = append(parts, s.Invoice+":"+strconv.FormatFloat(s.Quantity, 'f', -1, 64))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseLeafSources decodes a SourcesValue string. Malformed entries are skipped.
+func ParseLeafSources(v string) []LeafSource {
+	var out []LeafSource
+	for _, part := range strings.Split(v, ",") {
+		i := strings.LastIndex(part, ":")
+		if i <= 0 {
+			continue
+		}
+		q, err := strconv.ParseFloat(part[i+1:], 64)
+		if err != nil || q < 0 {
+			continue
+		}
+		out = append(out, LeafSource{Invoice: strings.TrimSpace(part[:i]), Quantity: q})
+	}
+	return out
+}
+
+// AllocateToSources splits qty across sources in order, filling each invoice's
+// requirement before moving to the next; anything beyond the total goes to the last
+// invoice. Invoices that receive nothing are omitted.
+func AllocateToSources(qty float64, sources []LeafSource) map[string]float64 {
+	out := map[string]float64{}
+	if len(sources) == 0 || qty <= 0 {
+		return out
+	}
+	left := qty
+	for _, s := range sources {
+		if left <= 0 {
+			break
+		}
+		take := math.Min(s.Quantity, left)
+		if take > 0 {
+			out[s.Invoice] = roundQty(out[s.Invoice] + take)
+			left = roundQty(left - take)
+		}
+	}
+	if left > 0 {
+		out[sources[len(sources)-1].Invoice] += left
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInvoiceNumbers(t *testing.T) {
+	t.Parallel()
+	got := ParseInvoiceNumbers(" INV-1, INV-2\nINV-3;;INV-1\r\n\tINV-4 ")
+	want := []string{"INV-1", "INV-2", "INV-3", "INV-4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	if got := ParseInvoiceNumbers(" , \n"); len(got) != 0 {
+		t.Fatalf("expected no invoices, got %v", got)
+	}
+}
+
+func TestMergeLeafTotals(t *testing.T) {
+	t.Parallel()
+	got := MergeLeafTotals([]string{"INV-1", "INV-2"}, [][]LeafTotal{
+		{{PartID: "A", Quantity: 3, UnitCost: 2}, {PartID: "B", Quantity: 1, CostIncomplete: true}},
+		{{PartID: "A", Quantity: 2, UnitCost: 2}, {PartID: "C", Quantity: 4, UnitCost: 1}},
+	})
+	if len(got) != 3 || got[0].PartID != "A" || got[1].PartID != "B" || got[2].PartID != "C" {
+		t.Fatalf("unexpected merge order: %+v", got)
+	}
+	a := got[0]
+	if a.Quantity != 5 || a.TotalCost != 10 || a.SourcesValue() != "INV-1:3,INV-2:2" {
+		t.Fatalf("unexpected A: %+v", a)
+	}
+	if !got[1].CostIncomplete || got[2].SourcesValue() != "INV-2:4" {
+		t.Fatalf("unexpected B/C: %+v %+v", got[1], got[2])
+	}
+}
+
+func TestParseLeafSources(t *testing.T) {
+	t.Parallel()
+	got := ParseLeafSources("INV-1:3,bad,INV:2:2,:5,X:-1")
+	want := []LeafSource{{Invoice: "INV-1", Quantity: 3}, {Invoice: "INV:2", Quantity: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+}
+
+func TestAllocateToSources(t *testing.T) {
+	t.Parallel()
+	src := []LeafSource{{Invoice: "INV-1", Quantity: 3}, {Invoice: "INV-2", Quantity: 2}}
+	cases := []struct {
+		qty  float64
+		want map[string]float64
+	}{
+		{5, map[string]float64{"INV-1": 3, "INV-2": 2}},
+		{2, map[string]float64{"INV-1": 2}},             // reduced (e.g. stock on hand) fills the first invoice
+		{8, map[string]float64{"INV-1": 3, "INV-2": 5}}, // extra goes to the last invoice
+		{3.7, map[string]float64{"INV-1": 3, "INV-2": 0.7}},
+		{0, map[string]float64{}},
+	}
+	for _, c := range cases {
+		if got := AllocateToSources(c.qty, src); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("qty %v: got %v want %v", c.qty, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pollStatus is the invoice status PollInvoices prepares BOMs for.
+const pollStatus = "AUTHORISED"
+
+// PreparedInvoice is a prepared_invoices row: a sales invoice the invoice poll found
+// authorised in Xero and resolved ahead of time. Message is why it could not be
+// resolved when not Ready; Parts is the number of distinct parts it needs.
+type PreparedInvoice struct {
+	InvoiceNumber string `json:"invoice_number"`
+	Ready         bool   `json:"ready"`
+	Message       string `json:"message,omitempty"`
+	Parts         int    `json:"parts"`
+	UpdatedAt     int64  `json:"updated_at"` // the invoice's UpdatedDateUTC
+	ResolvedAt    int64  `json:"resolved_at"`
+}
+
+// InvoicePollResult counts what one PollInvoices run did.
+type InvoicePollResult struct {
+	Seen     int // invoices Xero returned as changed
+	Ready    int // authorised invoices whose BOMs resolved
+	Problems int // authorised invoices that did not resolve
+	Removed  int // prepared invoices no longer authorised
+}
+
+// splitPolledInvoices sorts the invoices ListInvoices returned into the authorised
+// sales invoices to prepare and the numbers of the rest, which are no longer ready
+// to order if they were, and returns the latest UpdatedDateUTC among them (zero when
+// invs is empty).
+func splitPolledInvoices(invs []xero.Invoice) (authorised []xero.Invoice, gone []string, until time.Time) {
+	for _, inv := range invs {
+		if inv.UpdatedDateUTC.After(until) {
+			until = inv.UpdatedDateUTC.Time
+		}
+		if inv.InvoiceNumber == "" || inv.Type != xero.InvoiceTypeSales {
+			continue
+		}
+		if inv.Status == pollStatus {
+			authorised = append(authorised, inv)
+		} else {
+			gone = append(gone, inv.InvoiceNumber)
+		}
+	}
+	return authorised, gone, until
+}
+
+// PollInvoices fetches the sales invoices changed in Xero since the connection was
+// last polled (since lookback ago the first time) and resolves the BOMs of those
+// authorised with the owner's settings, so ListPreparedInvoices can offer them.
+// Invoices that left AUTHORISED are dropped. The poll position only moves once every
+// invoice was handled; a failure leaves it for the next run to retry.
+func PollInvoices(ctx context.Context, dbURL string, xc *xero.Client, ownerID string, creds XeroCredentials, lookback time.Duration) (InvoicePollResult, error) {
+	var res InvoicePollResult
+	if dbURL == "" {
+		return res, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return res, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	settings, err := GetOwnerSettings(ctx, dbURL, ownerID)
+	if err != nil {
+		return res, err
+	}
+	since := time.Now().Add(-lookback)
+	var polledUntil int64
+	err = pool.QueryRow(ctx, `SELECT polled_until FROM invoice_polls WHERE owner_id = $1 AND tenant_id = $2`, ownerID, creds.TenantID).Scan(&polledUntil)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return res, fmt.Errorf("load invoice_poll: %w", err)
+	default:
+		since = time.Unix(polledUntil, 0)
+	}
+
+	invs, err := xc.ListInvoices(ctx, creds.AccessToken, creds.TenantID, xero.InvoicesQuery{ModifiedSince: since})
+	if err != nil {
+		return res, fmt.Errorf("list invoices: %w", err)
+	}
+	res.Seen = len(invs)
+	authorised, gone, until := splitPolledInvoices(invs)
+
+	opts := settings.ResolveOptions()
+	opts.Statuses = []string{pollStatus}
+	for _, inv := range authorised {
+		_, leaves, msg, err := ResolveInvoice(ctx, dbURL, ownerID, xc, creds, inv.InvoiceNumber, opts)
+		if err != nil {
+			return res, fmt.Errorf("resolve invoice %s: %w", inv.InvoiceNumber, err)
+		}
+		if msg == "" {
+			res.Ready++
+		} else {
+			res.Problems++
+		}
+		if _, err := pool.Exec(ctx, `
+INSERT INTO prepared_invoices (owner_id, tenant_id, invoice_number, ready, message, parts, updated_at, resolved_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (owner_id, tenant_id, invoice_number) DO UPDATE
+SET ready = EXCLUDED.ready, message = EXCLUDED.message, parts = EXCLUDED.parts,
+    updated_at = EXCLUDED.updated_at, resolved_at = EXCLUDED.resolved_at
+`, ownerID, creds.TenantID, inv.InvoiceNumber, msg == "", msg, len(leaves), inv.UpdatedDateUTC.Unix(), time.Now().Unix()); err != nil {
+			return res, fmt.Errorf("upsert prepared_invoice: %w", err)
+		}
+	}
+	if len(gone) > 0 {
+		tag, err := pool.Exec(ctx, `
+DELETE FROM prepared_invoices WHERE owner_id = $1 AND tenant_id = $2 AND invoice_number = ANY($3)
+`, ownerID, creds.TenantID, gone)
+		if err != nil {
+			return res, fmt.Errorf("delete prepared_invoices: %w", err)
+		}
+		res.Removed = int(tag.RowsAffected())
+	}
+
+	if until.IsZero() {
+		return res, nil
+	}
+	if _, err := pool.Exec(ctx, `
+INSERT INTO invoice_polls (owner_id, tenant_id, polled_until) VALUES ($1, $2, $3)
+ON CONFLICT (owner_id, tenant_id) DO UPDATE SET polled_until = GREATEST(invoice_polls.polled_until, EXCLUDED.polled_until)
+`, ownerID, creds.TenantID, until.Unix()); err != nil {
+		return res, fmt.Errorf("save invoice_poll: %w", err)
+	}
+	return res, nil
+}
+
+// ListPreparedInvoices returns up to limit of the owner's prepared invoices, most
+// recently updated in Xero first. Invoices already on the shopping list and those of
+// organisations no longer connected are left out.
+func ListPreparedInvoices(ctx context.Context, dbURL, ownerID string, limit int) ([]PreparedInvoice, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT p.invoice_number, p.ready, p.message, p.parts, p.updated_at, p.resolved_at
+FROM prepared_invoices p
+WHERE p.owner_id = $1
+  AND EXISTS (SELECT 1 FROM xero_connections c WHERE c.owner_id = p.owner_id AND c.tenant_id = p.tenant_id)
+  AND NOT EXISTS (SELECT 1 FROM shopping_list s WHERE s.owner_id = p.owner_id AND s.source_invoice = p.invoice_number)
+ORDER BY p.updated_at DESC, p.invoice_number
+LIMIT $2
+`, ownerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query prepared_invoices: %w", err)
+	}
+	defer rows.Close()
+	var out []PreparedInvoice
+	for rows.Next() {
+		var p PreparedInvoice
+		if err := rows.Scan(&p.InvoiceNumber, &p.Ready, &p.Message, &p.Parts, &p.UpdatedAt, &p.ResolvedAt); err != nil {
+			return nil, fmt.Errorf("scan prepared_invoice: %w", err)
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
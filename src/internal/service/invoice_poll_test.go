@@ -0,0 +1,38 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestSplitPolledInvoices(t *testing.T) {
+	t.Parallel()
+	at := func(h int) xero.Timestamp { return xero.Timestamp{Time: time.Date(2026, 3, 1, h, 0, 0, 0, time.UTC)} }
+	invs := []xero.Invoice{
+		{InvoiceNumber: "INV-1", Type: xero.InvoiceTypeSales, Status: "AUTHORISED", UpdatedDateUTC: at(9)},
+		{InvoiceNumber: "INV-2", Type: xero.InvoiceTypeSales, Status: "PAID", UpdatedDateUTC: at(11)},
+		{InvoiceNumber: "BILL-1", Type: "ACCPAY", Status: "AUTHORISED", UpdatedDateUTC: at(12)},
+		{InvoiceNumber: "INV-3", Type: xero.InvoiceTypeSales, Status: "DRAFT", UpdatedDateUTC: at(10)},
+		{InvoiceNumber: "", Type: xero.InvoiceTypeSales, Status: "AUTHORISED", UpdatedDateUTC: at(8)},
+		{InvoiceNumber: "INV-4", Type: xero.InvoiceTypeSales, Status: "AUTHORISED", UpdatedDateUTC: at(7)},
+	}
+	authorised, gone, until := splitPolledInvoices(invs)
+	var numbers []string
+	for _, inv := range authorised {
+		numbers = append(numbers, inv.InvoiceNumber)
+	}
+	if fmt.Sprint(numbers) != "[INV-1 INV-4]" || fmt.Sprint(gone) != "[INV-2 INV-3]" {
+		t.Fatalf("authorised = %v, gone = %v", numbers, gone)
+	}
+	// bills move the poll position too, or they would be fetched again every run
+	if !until.Equal(at(12).Time) {
+		t.Fatalf("until = %v, want %v", until, at(12).Time)
+	}
+
+	if a, g, u := splitPolledInvoices(nil); a != nil || g != nil || !u.IsZero() {
+		t.Fatalf("empty: %v %v %v", a, g, u)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// ResolveOptions tune ResolveInvoice.
+type ResolveOptions struct {
+	// MaxDepth bounds BOM expansion; 0 uses DefaultBOMMaxDepth.
+	MaxDepth int
+	// Statuses are the invoice statuses accepted; nil uses DefaultInvoiceStatuses.
+	Statuses []string
+	// Expand lists items with a supplier that are ordered as their parts list rather
+	// than whole.
+	Expand []string
+	// Substitute maps items to the substitute the user chose to order instead; see
+	// ResolveInvoiceBOM.
+	Substitute map[string]string
+}
+
+// ResolveInvoice fetches an invoice's lines from Xero and resolves them into the
+// per-assembly BOM tree and the aggregated leaf totals. msg is a user-facing reason
+// the invoice could not be resolved (not a sales invoice, a status not in
+// opts.Statuses, no item lines, BOM problems); err is a failure. Parts lists and
+// supplier mappings are the owner's.
+func ResolveInvoice(ctx context.Context, dbURL, ownerID string, xc *xero.Client, creds XeroCredentials, invoiceNumber string, opts ResolveOptions) ([]BOMNode, []LeafTotal, string, error) {
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultBOMMaxDepth
+	}
+	statuses := opts.Statuses
+	if len(statuses) == 0 {
+		statuses = DefaultInvoiceStatuses
+	}
+	filter := xero.InvoiceFilter{Types: []string{xero.InvoiceTypeSales}, Statuses: statuses}
+	lines, err := xc.GetInvoiceItemCodes(ctx, creds.AccessToken, creds.TenantID, invoiceNumber, filter)
+	var rejected *xero.InvoiceRejectedError
+	if errors.As(err, &rejected) {
+		return nil, nil, rejectedInvoiceMessage(rejected, statuses), nil
+	}
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("fetch invoice %s items: %w", invoiceNumber, err)
+	}
+	if len(lines) == 0 {
+		return nil, nil, "No items found on invoice " + invoiceNumber, nil
+	}
+
+	roots := make([]RootItem, 0, len(lines))
+	for _, li := range lines {
+		roots = append(roots, RootItem{
+			PartID:   li.ItemCode,
+			Name:     li.Name,
+			Quantity: li.Quantity,
+		})
+	}
+
+	// effective totals for all nodes
+	bom, msg, err := ResolveInvoiceBOM(ctx, dbURL, ownerID, roots, maxDepth, opts.Expand, opts.Substitute, xc, creds.AccessToken, creds.TenantID)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("resolve bom: %w", err)
+	}
+	if msg != "" {
+		return nil, nil, msg, nil
+	}
+
+	// children quantities divided by root qty; root keeps its invoice qty
+	perAssy := BuildPerAssemblyBOM(bom, roots)
+	return perAssy, AggregateLeafTotals(perAssy), "", nil
+}
+
+// rejectedInvoiceMessage explains why parts are not ordered for an invoice.
+func rejectedInvoiceMessage(e *xero.InvoiceRejectedError, statuses []string) string {
+	if e.WrongType {
+		return fmt.Sprintf("Invoice %s is not a sales invoice (type %s)", e.InvoiceNumber, e.Type)
+	}
+	return fmt.Sprintf("Invoice %s is %s; parts are only ordered for %s invoices (see Settings)",
+		e.InvoiceNumber, e.Status, strings.Join(statuses, ", "))
+}
@@ -1,18 +1,39 @@
 package service
 
-import "math"
+import "github.com/hwalton/xero-invoice-orderer/internal/uom"
 
 // LeafTotal is a flat total per purchasable part.
 type LeafTotal struct {
 	PartID   string  `json:"part_id"`
 	Name     string  `json:"name"`
 	Quantity float64 `json:"quantity"`
+	UOM      string  `json:"uom,omitempty"` // unit Quantity is in; see package uom
+
+	// SubstituteFor is the item this part was chosen to replace
+	SubstituteFor string `json:"substitute_for,omitempty"`
+
+	UnitCost       float64 `json:"unit_cost,omitempty"`
+	TotalCost      float64 `json:"total_cost,omitempty"`
+	CostIncomplete bool    `json:"cost_incomplete,omitempty"`
+
+	// stock on hand (tracked inventory only); Required is the quantity before stock
+	// was deducted from Quantity
+	StockTracked bool    `json:"stock_tracked,omitempty"`
+	OnHand       float64 `json:"on_hand,omitempty"`
+	Required     float64 `json:"required,omitempty"`
+
+	// per-invoice breakdown when several invoices were resolved together
+	Sources []LeafSource `json:"sources,omitempty"`
+
+	Attachments []PartAttachment `json:"-"`
 }
 
 // BuildPerAssemblyBOM converts an "effective totals" BOM into a per-assembly tree:
 //   - Root nodes show the invoice quantity (as-is).
 //   - For every non-root node, Quantity = child.EffectiveQty / parent.EffectiveQty.
 //     This yields "Qty required (for each Assy)" at every level.
+//   - UnitCost is carried over and TotalCost = UnitCost x the displayed Quantity, so a
+//     child shows its cost per parent assembly and a root its cost for the invoice line.
 func BuildPerAssemblyBOM(bom []BOMNode, roots []RootItem) []BOMNode {
 	min := len(bom)
 	if len(roots) < min {
@@ -29,10 +50,18 @@ func BuildPerAssemblyBOM(bom []BOMNode, roots []RootItem) []BOMNode {
 			perAssyQty = node.Quantity / parentEffective
 		}
 		res := BOMNode{
-			PartID:     node.PartID,
-			Name:       node.Name,
-			Quantity:   perAssyQty,
-			IsAssembly: node.IsAssembly,
+			PartID:         node.PartID,
+			Name:           node.Name,
+			Quantity:       perAssyQty,
+			UOM:            node.UOM,
+			IsAssembly:     node.IsAssembly,
+			UnitCost:       node.UnitCost,
+			TotalCost:      roundCost(node.UnitCost * perAssyQty),
+			CostIncomplete: node.CostIncomplete,
+			Collapsible:    node.Collapsible,
+			SubstituteFor:  node.SubstituteFor,
+			Unavailable:    node.Unavailable,
+			Substitutes:    node.Substitutes,
 		}
 		for _, ch := range node.Children {
 			// Pass this node's effective quantity down as the parent effective for children.
@@ -45,10 +74,18 @@ func BuildPerAssemblyBOM(bom []BOMNode, roots []RootItem) []BOMNode {
 		rootEff := bom[i].Quantity
 		// Root shows the invoice quantity exactly (not normalized).
 		root := BOMNode{
-			PartID:     bom[i].PartID,
-			Name:       bom[i].Name,
-			Quantity:   roots[i].Quantity,
-			IsAssembly: bom[i].IsAssembly,
+			PartID:         bom[i].PartID,
+			Name:           bom[i].Name,
+			Quantity:       roots[i].Quantity,
+			UOM:            bom[i].UOM,
+			IsAssembly:     bom[i].IsAssembly,
+			UnitCost:       bom[i].UnitCost,
+			TotalCost:      roundCost(bom[i].UnitCost * roots[i].Quantity),
+			CostIncomplete: bom[i].CostIncomplete,
+			Collapsible:    bom[i].Collapsible,
+			SubstituteFor:  bom[i].SubstituteFor,
+			Unavailable:    bom[i].Unavailable,
+			Substitutes:    bom[i].Substitutes,
 		}
 		for _, ch := range bom[i].Children {
 			root.Children = append(root.Children, norm(ch, rootEff))
@@ -60,7 +97,10 @@ func BuildPerAssemblyBOM(bom []BOMNode, roots []RootItem) []BOMNode {
 
 // AggregateLeafTotals multiplies quantities down the per-assembly tree to produce
 // total quantities for each purchasable leaf across all roots (handles multi-tier).
-// Returned quantities are rounded to the nearest integer for form defaults.
+// An assembly left collapsed (a Collapsible leaf) is ordered whole, so it is totalled
+// itself and its parts are not.
+// Returned quantities are rounded to the nearest amount their unit of measure can
+// express (whole numbers for countable units) for form defaults.
 func AggregateLeafTotals(perAssy []BOMNode) []LeafTotal {
 	agg := map[string]*LeafTotal{}
 
@@ -80,7 +120,7 @@ func AggregateLeafTotals(perAssy []BOMNode) []LeafTotal {
 		if lt, ok := agg[node.PartID]; ok {
 			lt.Quantity += total
 		} else {
-			agg[node.PartID] = &LeafTotal{PartID: node.PartID, Name: node.Name, Quantity: total}
+			agg[node.PartID] = &LeafTotal{PartID: node.PartID, Name: node.Name, Quantity: total, UOM: node.UOM, SubstituteFor: node.SubstituteFor, UnitCost: node.UnitCost, CostIncomplete: node.UnitCost <= 0}
 		}
 	}
 
@@ -90,7 +130,8 @@ func AggregateLeafTotals(perAssy []BOMNode) []LeafTotal {
 
 	out := make([]LeafTotal, 0, len(agg))
 	for _, v := range agg {
-		v.Quantity = math.Round(v.Quantity)
+		v.Quantity = uom.Round(v.Quantity, v.UOM)
+		v.TotalCost = roundCost(v.UnitCost * v.Quantity)
 		out = append(out, *v)
 	}
 	return out
@@ -208,3 +208,47 @@ func TestBuildAndAggregate_RoundTrip(t *testing.T) {
 func equalBOM(a, b []BOMNode) bool {
 	return reflect.DeepEqual(a, b)
 }
+
+func TestRollupBOMCosts(t *testing.T) {
+	t.Parallel()
+	// effective quantities: 2 kits, each with 4 bolts (8) and 1 sink (2) containing 2 taps (4)
+	bom := []BOMNode{{
+		PartID: "KIT", Quantity: 2, IsAssembly: true,
+		Children: []BOMNode{
+			{PartID: "BOLT", Quantity: 8, UnitCost: 0.25},
+			{PartID: "SINK", Quantity: 2, IsAssembly: true, Children: []BOMNode{
+				{PartID: "TAP", Quantity: 4, UnitCost: 12.5},
+			}},
+		},
+	}}
+	RollupBOMCosts(bom)
+	if bom[0].TotalCost != 52 || bom[0].UnitCost != 26 || bom[0].CostIncomplete {
+		t.Fatalf("unexpected kit cost: %+v", bom[0])
+	}
+	if sink := bom[0].Children[1]; sink.TotalCost != 50 || sink.UnitCost != 25 {
+		t.Fatalf("unexpected sink cost: %+v", sink)
+	}
+
+	perAssy := BuildPerAssemblyBOM(bom, []RootItem{{PartID: "KIT", Quantity: 2}})
+	if perAssy[0].TotalCost != 52 || perAssy[0].Children[0].TotalCost != 1 || perAssy[0].Children[1].TotalCost != 25 {
+		t.Fatalf("unexpected per-assembly costs: %+v", perAssy[0])
+	}
+	total, incomplete := BOMTotalCost(perAssy)
+	if total != 52 || incomplete {
+		t.Fatalf("unexpected total: %v %v", total, incomplete)
+	}
+
+	leaves := AggregateLeafTotals(perAssy)
+	for _, lt := range leaves {
+		if lt.PartID == "TAP" && (lt.Quantity != 4 || lt.TotalCost != 50) {
+			t.Fatalf("unexpected tap total: %+v", lt)
+		}
+	}
+
+	// a missing price is flagged up the tree
+	bom[0].Children[0].UnitCost = 0
+	RollupBOMCosts(bom)
+	if !bom[0].CostIncomplete || bom[0].Children[1].CostIncomplete || bom[0].TotalCost != 50 {
+		t.Fatalf("expected incomplete kit cost: %+v", bom[0])
+	}
+}
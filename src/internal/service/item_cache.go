@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// itemCacheTTL bounds how stale cached Xero item data (name, purchase price) can be.
+const itemCacheTTL = 10 * time.Minute
+
+// itemCache caches Xero items per tenant so repeated BOM resolution does not refetch
+// every item. Only found items are cached: a code missing from Xero is looked up
+// again next time (it may just have been created).
+var itemCache = newXeroItemCache(nil, itemCacheTTL)
+
+type xeroItemCache struct {
+	store cache.Cache // nil uses sharedCache
+	ttl   time.Duration
+}
+
+func newXeroItemCache(store cache.Cache, ttl time.Duration) *xeroItemCache {
+	return &xeroItemCache{store: store, ttl: ttl}
+}
+
+func (c *xeroItemCache) backend() cache.Cache {
+	if c.store != nil {
+		return c.store
+	}
+	return sharedCache
+}
+
+// itemKey is the cache key of a tenant's item.
+func itemKey(tenantID, code string) string {
+	return "xero:item:" + tenantID + ":" + code
+}
+
+// lookup returns code -> item for the codes that exist in Xero, fetching cache misses
+// in batches. Codes already looked up in this request (see WithItemLookups) are not
+// fetched again, found or not.
+func (c *xeroItemCache) lookup(ctx context.Context, xc *xero.Client, accessToken, tenantID string, codes []string) (map[string]xero.ItemSummary, error) {
+	total := len(codes) // for progress reports
+	memo := itemMemoFrom(ctx)
+	out, codes := memo.split(tenantID, codes)
+
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = itemKey(tenantID, code)
+	}
+	cached, err := cache.GetJSON[xero.ItemSummary](ctx, c.backend(), keys...)
+	if err != nil {
+		cacheMiss("get items", err)
+	}
+	var missing []string
+	for i, code := range codes {
+		if it, ok := cached[keys[i]]; ok {
+			out[code] = it
+			continue
+		}
+		missing = append(missing, code)
+	}
+
+	if len(missing) == 0 {
+		reportItemsResolved(ctx, total, total)
+		return out, nil
+	}
+	reportItemsResolved(ctx, total-len(missing), total)
+	items, err := xc.GetItemsByCodes(ctx, accessToken, tenantID, missing)
+	if err != nil {
+		return nil, err
+	}
+	memo.record(tenantID, missing, items)
+
+	fetched := make(map[string]xero.ItemSummary, len(items))
+	for code, it := range items {
+		out[code] = it
+		fetched[itemKey(tenantID, code)] = it
+	}
+	if err := cache.SetJSON(ctx, c.backend(), fetched, c.ttl); err != nil {
+		cacheMiss("set items", err)
+	} else if len(fetched) > 0 {
+		markCacheFilled(ctx, c.backend(), cacheKindItems, tenantID, c.ttl)
+	}
+	reportItemsResolved(ctx, total, total)
+	return out, nil
+}
+
+// forget drops a tenant's cached items, e.g. after changing them in Xero.
+func (c *xeroItemCache) forget(ctx context.Context, tenantID string, codes []string) {
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = itemKey(tenantID, code)
+	}
+	if err := c.backend().Delete(ctx, keys...); err != nil {
+		cacheMiss("delete items", err)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// itemMemo remembers every Xero item lookup made while serving one request, including
+// codes Xero does not have (which itemCache deliberately forgets), so resolving several
+// invoices and then their stock levels asks Xero about each code at most once.
+type itemMemo struct {
+	mu    sync.Mutex
+	items map[string]xero.ItemSummary // tenantID + "\x00" + code
+	known map[string]bool             // looked up this request, found or not
+}
+
+type itemMemoKey struct{}
+
+// WithItemLookups returns a context under which Xero item lookups (BOM resolution and
+// stock on hand) are memoised for the life of the request.
+func WithItemLookups(ctx context.Context) context.Context {
+	if itemMemoFrom(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, itemMemoKey{}, &itemMemo{items: map[string]xero.ItemSummary{}, known: map[string]bool{}})
+}
+
+// itemMemoFrom returns ctx's memo, or nil when the request has none.
+func itemMemoFrom(ctx context.Context) *itemMemo {
+	m, _ := ctx.Value(itemMemoKey{}).(*itemMemo)
+	return m
+}
+
+// split returns the memoised items among codes and the codes not yet looked up. A nil
+// memo knows nothing.
+func (m *itemMemo) split(tenantID string, codes []string) (map[string]xero.ItemSummary, []string) {
+	found := make(map[string]xero.ItemSummary, len(codes))
+	if m == nil {
+		return found, codes
+	}
+	var unknown []string
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range codes {
+		k := tenantID + "\x00" + code
+		if !m.known[k] {
+			unknown = append(unknown, code)
+			continue
+		}
+		if it, ok := m.items[k]; ok {
+			found[code] = it
+		}
+	}
+	return found, unknown
+}
+
+// record stores the result of looking up codes; codes absent from items are remembered
+// as not in Xero.
+func (m *itemMemo) record(tenantID string, codes []string, items map[string]xero.ItemSummary) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range codes {
+		k := tenantID + "\x00" + code
+		m.known[k] = true
+		if it, ok := items[code]; ok {
+			m.items[k] = it
+		}
+	}
+}
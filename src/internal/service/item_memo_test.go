@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestItemLookups_MemoisedPerRequest(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	var asked []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		asked = append(asked, r.URL.Query().Get("where"))
+		var items []string
+		for _, clause := range strings.Split(r.URL.Query().Get("where"), " OR ") {
+			code := strings.TrimSuffix(strings.TrimPrefix(clause, `Code=="`), `"`)
+			if code != "GONE" {
+				items = append(items, `{"Code":"`+code+`","Name":"Item `+code+`","IsTrackedAsInventory":true,"QuantityOnHand":3}`)
+			}
+		}
+		_, _ = w.Write([]byte(`{"Items":[` + strings.Join(items, ",") + `]}`))
+	}))
+	defer ts.Close()
+	xc := xero.NewClient(ts.Client(), ts.URL)
+	items := newXeroItemCache(cache.NewMemory(), time.Minute)
+	ctx := WithItemLookups(context.Background())
+
+	// first invoice: one fetch, including a code Xero does not have
+	got, err := items.lookup(ctx, xc, "at", "memo-tenant", []string{"A", "GONE"})
+	if err != nil || len(got) != 1 || got["A"].Name != "Item A" {
+		t.Fatalf("first lookup: %+v %v", got, err)
+	}
+	// second invoice shares A and GONE; only B is new
+	got, err = items.lookup(ctx, xc, "at", "memo-tenant", []string{"A", "B", "GONE"})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("second lookup: %+v %v", got, err)
+	}
+	// stock for everything already seen in this request needs no call at all
+	stock, err := FetchStockOnHand(ctx, xc, "at", "memo-tenant", []string{"A", "B", "GONE"})
+	if err != nil || stock["A"] != 3 || stock["B"] != 3 || len(stock) != 2 {
+		t.Fatalf("stock: %+v %v", stock, err)
+	}
+	if n := calls.Load(); n != 2 || asked[1] != `Code=="B"` {
+		t.Fatalf("%d calls to Xero (%q), want 2 with B alone second", n, asked)
+	}
+
+	// without a memo, stock always goes to Xero
+	if _, err := FetchStockOnHand(context.Background(), xc, "at", "memo-tenant", []string{"A"}); err != nil || calls.Load() != 3 {
+		t.Fatalf("stock outside a request memo: calls=%d err=%v", calls.Load(), err)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// purge is one kind of row nothing reads again. arg gives the query's $1.
+type purge struct {
+	table string
+	sql   string
+	arg   func(now time.Time) int64
+}
+
+// purges are run in order by PurgeExpiredRows.
+var purges = []purge{
+	// connect flows abandoned before the Xero callback
+	{"oauth_states", `DELETE FROM oauth_states WHERE expires_at <= $1`,
+		func(now time.Time) int64 { return now.Unix() }},
+	// XERO_DEBUG=db logs past retention (RecordAPICall also prunes as it goes)
+	{"api_call_log", `DELETE FROM api_call_log WHERE created_at < $1`,
+		func(now time.Time) int64 { return now.Add(-APICallRetention).Unix() }},
+	// invoice results never shown (the browser did not follow the redirect)
+	{"view_states", `DELETE FROM view_states WHERE expires_at <= $1`,
+		func(now time.Time) int64 { return now.Unix() }},
+	// webhook events old enough to be rejected by age, so no longer needed to spot repeats
+	{"xero_webhook_events", `DELETE FROM xero_webhook_events WHERE expires_at <= $1`,
+		func(now time.Time) int64 { return now.Unix() }},
+	// rows from before lists were per owner that no owner could be given; no page
+	// shows them. $1 keeps rows added in the last day out of it, just in case.
+	{"shopping_list", `DELETE FROM shopping_list WHERE owner_id IS NULL AND COALESCE(created_at, 0) < $1`,
+		func(now time.Time) int64 { return now.Add(-24 * time.Hour).Unix() }},
+}
+
+// Retention is how long the janitor keeps rows that are only kept for a while; 0
+// keeps them for good.
+type Retention struct {
+	Archived     time.Duration // archived shopping list rows and supplier mappings
+	AuditLog     time.Duration // audit_log rows
+	BOMSnapshots time.Duration // bom_snapshots; an invoice resolved after its snapshots went saves a new one
+}
+
+// retentionPurges delete rows older than r allows, skipping those kept for good.
+func retentionPurges(r Retention) []purge {
+	cutoff := func(d time.Duration) func(now time.Time) int64 {
+		return func(now time.Time) int64 { return now.Add(-d).Unix() }
+	}
+	var out []purge
+	if r.Archived > 0 {
+		out = append(out,
+			purge{"shopping_list_archived", `DELETE FROM shopping_list WHERE archived_at < $1`, cutoff(r.Archived)},
+			purge{"items_contacts_archived", `DELETE FROM items_contacts WHERE archived_at < $1`, cutoff(r.Archived)})
+	}
+	if r.AuditLog > 0 {
+		out = append(out, purge{"audit_log", `DELETE FROM audit_log WHERE created_at < $1`, cutoff(r.AuditLog)})
+	}
+	if r.BOMSnapshots > 0 {
+		out = append(out, purge{"bom_snapshots", `DELETE FROM bom_snapshots WHERE created_at < $1`, cutoff(r.BOMSnapshots)})
+	}
+	return out
+}
+
+// PurgeExpiredRows deletes expired and orphaned rows, and rows older than retention
+// allows, and returns how many went, by table. On error the counts so far are
+// returned with it.
+func PurgeExpiredRows(ctx context.Context, dbURL string, retention Retention) (map[string]int64, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	now := time.Now()
+	all := append(append([]purge(nil), purges...), retentionPurges(retention)...)
+	purged := make(map[string]int64, len(all))
+	for _, p := range all {
+		tag, err := pool.Exec(ctx, p.sql, p.arg(now))
+		if err != nil {
+			return purged, fmt.Errorf("purge %s: %w", p.table, err)
+		}
+		purged[p.table] = tag.RowsAffected()
+	}
+	return purged, nil
+}
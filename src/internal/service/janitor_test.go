@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetentionPurges(t *testing.T) {
+	t.Parallel()
+	if got := retentionPurges(Retention{}); got != nil {
+		t.Fatalf("retention 0 should keep rows, got %+v", got)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	got := retentionPurges(Retention{Archived: 24 * time.Hour, AuditLog: 48 * time.Hour, BOMSnapshots: 72 * time.Hour})
+	want := map[string]time.Duration{
+		"shopping_list_archived":  24 * time.Hour,
+		"items_contacts_archived": 24 * time.Hour,
+		"audit_log":               48 * time.Hour,
+		"bom_snapshots":           72 * time.Hour,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected purges: %+v", got)
+	}
+	for _, p := range got {
+		d, ok := want[p.table]
+		if !ok {
+			t.Fatalf("unexpected purge of %s", p.table)
+		}
+		if arg := p.arg(now); arg != now.Add(-d).Unix() {
+			t.Fatalf("%s: cutoff = %d", p.table, arg)
+		}
+	}
+	if got := retentionPurges(Retention{AuditLog: time.Hour}); len(got) != 1 || got[0].table != "audit_log" {
+		t.Fatalf("only the audit log should be purged: %+v", got)
+	}
+}
+
+func TestPurgeExpiredRows_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := PurgeExpiredRows(context.Background(), "", Retention{}); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -9,8 +12,9 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// CreateOAuthState stores a one-time state with TTL (seconds).
-func CreateOAuthState(ctx context.Context, dbURL, state, ownerID string, ttlSeconds int) error {
+// CreateOAuthState stores a one-time state with TTL (seconds), bound to nonce: the
+// value kept in the starting browser's cookie. Only a hash of nonce is stored.
+func CreateOAuthState(ctx context.Context, dbURL, state, ownerID, nonce string, ttlSeconds int) error {
 	if dbURL == "" {
 		return fmt.Errorf("db url missing")
 	}
@@ -24,13 +28,14 @@ func CreateOAuthState(ctx context.Context, dbURL, state, ownerID string, ttlSeco
 	createdAt := time.Now().Unix()
 
 	_, err = pool.Exec(ctx, `
-INSERT INTO oauth_states (state, owner_id, expires_at, created_at)
-VALUES ($1, $2, $3, $4)
+INSERT INTO oauth_states (state, owner_id, nonce_hash, expires_at, created_at)
+VALUES ($1, $2, $3, $4, $5)
 ON CONFLICT (state) DO UPDATE
   SET owner_id = EXCLUDED.owner_id,
+      nonce_hash = EXCLUDED.nonce_hash,
       expires_at = EXCLUDED.expires_at,
       created_at = EXCLUDED.created_at
-`, state, ownerID, expiresAt, createdAt)
+`, state, ownerID, oauthNonceHash(nonce), expiresAt, createdAt)
 	if err != nil {
 		return fmt.Errorf("insert oauth_state: %w", err)
 	}
@@ -38,8 +43,10 @@ ON CONFLICT (state) DO UPDATE
 }
 
 // ConsumeOAuthState atomically returns the ownerID for state and deletes the row.
+// A state presented with a different nonce than it was created with is consumed
+// but not found.
 // returns (ownerID, found, error)
-func ConsumeOAuthState(ctx context.Context, dbURL, state string) (string, bool, error) {
+func ConsumeOAuthState(ctx context.Context, dbURL, state, nonce string) (string, bool, error) {
 	if dbURL == "" {
 		return "", false, fmt.Errorf("db url missing")
 	}
@@ -49,14 +56,14 @@ func ConsumeOAuthState(ctx context.Context, dbURL, state string) (string, bool,
 	}
 	defer pool.Close()
 
-	var ownerID string
+	var ownerID, nonceHash string
 	nowEpoch := time.Now().Unix()
 	// atomic delete + return owner_id if not expired (compare against unix epoch seconds)
 	err = pool.QueryRow(ctx, `
 DELETE FROM oauth_states
 WHERE state = $1 AND expires_at > $2
-RETURNING owner_id
-`, state, nowEpoch).Scan(&ownerID)
+RETURNING owner_id, nonce_hash
+`, state, nowEpoch).Scan(&ownerID, &nonceHash)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			// expired or missing
@@ -64,5 +71,18 @@ RETURNING owner_id
 		}
 		return "", false, fmt.Errorf("consume oauth_state: %w", err)
 	}
+	if nonceHash == "" || subtle.ConstantTimeCompare([]byte(nonceHash), []byte(oauthNonceHash(nonce))) != 1 {
+		return "", false, nil
+	}
 	return ownerID, true, nil
 }
+
+// oauthNonceHash is the stored form of a state nonce; "" for no nonce, which
+// never matches.
+func oauthNonceHash(nonce string) string {
+	if nonce == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/uom"
+)
+
+// SupplierTerms are a supplier's ordering terms for one item (items_contacts).
+// Zero MinimumOrderQty or PackSize means no constraint; zero UnitPrice means no
+// agreed price. UOM is the unit the supplier sells in (items_contacts.uom, else
+// parts.uom); quantities and terms are in that unit.
+type SupplierTerms struct {
+	LeadTimeDays    int
+	HasLeadTime     bool // false when the lead time is not recorded
+	MinimumOrderQty float64
+	PackSize        float64
+	UnitPrice       float64
+	UOM             string
+}
+
+// Price sources reported on preview lines.
+const (
+	PriceSourceSupplier = "supplier" // items_contacts.unit_price
+	PriceSourceXero     = "xero"     // the Xero item's PurchaseDetails.UnitPrice
+)
+
+// UnitPrice is the price to order it at: the supplier's agreed price, else
+// xeroPrices[ItemID] when positive. source is "" when neither is known and Xero
+// should apply its own default.
+func (it ContactItem) UnitPrice(xeroPrices map[string]float64) (price float64, source string) {
+	if it.Terms.UnitPrice > 0 {
+		return it.Terms.UnitPrice, PriceSourceSupplier
+	}
+	if p := xeroPrices[it.ItemID]; p > 0 {
+		return p, PriceSourceXero
+	}
+	return 0, ""
+}
+
+// OrderQuantity is qty rounded up to what can be ordered in the unit (whole sheets,
+// centimetres of cable), then to a whole number of packs.
+func (t SupplierTerms) OrderQuantity(qty float64) float64 {
+	qty = uom.Ceil(qty, t.UOM)
+	if t.PackSize <= 0 || qty <= 0 {
+		return qty
+	}
+	return roundQty(math.Ceil(roundQty(qty/t.PackSize)) * t.PackSize)
+}
+
+// POPreviewLine is one item of a previewed purchase order.
+type POPreviewLine struct {
+	ItemID          string
+	UOM             string
+	Requested       float64 // summed shopping list quantity
+	Quantity        float64 // Requested rounded up to the pack size; what is ordered
+	PackSize        float64
+	MinimumOrderQty float64
+	BelowMOQ        bool      // Quantity is under the supplier's minimum order
+	ExpectedArrival time.Time // zero when the lead time is unknown
+	UnitPrice       float64
+	PriceSource     string  // PriceSourceSupplier, PriceSourceXero or "" when unpriced
+	LineTotal       float64 // Quantity * UnitPrice
+	// NoRate is set when the line only has a Xero purchase price, which cannot be
+	// converted to the supplier's currency yet (see NoRateError)
+	NoRate      bool
+	AccountCode string // the item's default account code; "" = the Xero item's
+	// Suppliers compares what each of the item's suppliers would charge, when it has
+	// several
+	Suppliers []SupplierQuote
+}
+
+// SupplierQuote is what one of an item's suppliers would charge for a preview line:
+// the requested quantity rounded to their packs, at their price in their currency.
+type SupplierQuote struct {
+	ContactID   string
+	Preferred   bool
+	Chosen      bool // the supplier the line is ordered from
+	Cheapest    bool // the lowest LineTotal in the base currency of the priced quotes
+	Quantity    float64
+	UnitPrice   float64
+	PriceSource string // as on POPreviewLine
+	LineTotal   float64
+	Currency    string // as on POPreview
+}
+
+// quoteSuppliers prices the line's quantity with each of its suppliers, chosen being the
+// one it is ordered from. Nil when it has only one supplier. Quotes in a currency
+// without a rate are not compared.
+func quoteSuppliers(it ContactItem, chosen string, xeroPrices map[string]float64, cur SupplierCurrencies) []SupplierQuote {
+	if len(it.Suppliers) < 2 {
+		return nil
+	}
+	out := make([]SupplierQuote, 0, len(it.Suppliers))
+	cheapest, lowest := -1, 0.0
+	for _, s := range it.Suppliers {
+		q := SupplierQuote{ContactID: s.ContactID, Preferred: s.Preferred, Chosen: s.ContactID == chosen, Quantity: s.Terms.OrderQuantity(it.Quantity), Currency: cur.Currency(s.ContactID)}
+		// without a rate the quote shows as unpriced
+		q.UnitPrice, q.PriceSource, _ = cur.UnitPrice(ContactItem{ItemID: it.ItemID, Terms: s.Terms}, s.ContactID, xeroPrices)
+		if q.PriceSource != "" {
+			q.LineTotal = q.Quantity * q.UnitPrice
+			if base, ok := cur.ToBase(s.ContactID, q.LineTotal); ok && (cheapest < 0 || base < lowest) {
+				cheapest, lowest = len(out), base
+			}
+		}
+		out = append(out, q)
+	}
+	if cheapest >= 0 {
+		out[cheapest].Cheapest = true
+	}
+	return out
+}
+
+// POPreview is the purchase order that would be raised for one supplier, priced in
+// the currency they invoice in.
+type POPreview struct {
+	Supplier string // Xero Contacts.AccountNumber
+	// Currency is the ISO 4217 code of the supplier's Xero default currency when it is
+	// not the base currency, "" otherwise
+	Currency string
+	Lines    []POPreviewLine
+	// ExpectedArrival is the latest line arrival, i.e. when the whole order is in;
+	// zero when no line has a known lead time
+	ExpectedArrival time.Time
+	BelowMOQ        int     // number of lines under MOQ
+	Total           float64 // sum of priced lines
+	Unpriced        int     // lines left to Xero's default price, not in Total
+	// NoRate counts the lines with NoRate, not in Total either. The purchase order
+	// cannot be created while there are any.
+	NoRate int
+	// Rate is Xero's rate for Currency (units per unit of the base currency) and
+	// BaseTotal is Total converted at it; both 0 in the base currency or when Xero
+	// has no rate yet
+	Rate      float64
+	BaseTotal float64
+}
+
+// BuildPOPreview turns shopping list items grouped by supplier into order previews,
+// sorted by supplier and item. Arrival dates count lead time days from orderDate;
+// xeroPrices (item code -> purchase price, may be nil) prices lines without an
+// agreed supplier price, converted to the currency of suppliers in cur.
+func BuildPOPreview(grouped map[string][]ContactItem, orderDate time.Time, xeroPrices map[string]float64, cur SupplierCurrencies) []POPreview {
+	day := time.Date(orderDate.Year(), orderDate.Month(), orderDate.Day(), 0, 0, 0, 0, orderDate.Location())
+	out := make([]POPreview, 0, len(grouped))
+	for supplier, items := range grouped {
+		p := POPreview{Supplier: supplier, Currency: cur.Currency(supplier)}
+		for _, it := range items {
+			line := POPreviewLine{
+				ItemID:          it.ItemID,
+				UOM:             it.Terms.UOM,
+				Requested:       it.Quantity,
+				Quantity:        it.Terms.OrderQuantity(it.Quantity),
+				PackSize:        it.Terms.PackSize,
+				MinimumOrderQty: it.Terms.MinimumOrderQty,
+			}
+			line.BelowMOQ = line.MinimumOrderQty > 0 && line.Quantity < line.MinimumOrderQty
+			if it.Terms.HasLeadTime {
+				line.ExpectedArrival = day.AddDate(0, 0, it.Terms.LeadTimeDays)
+				if line.ExpectedArrival.After(p.ExpectedArrival) {
+					p.ExpectedArrival = line.ExpectedArrival
+				}
+			}
+			if line.BelowMOQ {
+				p.BelowMOQ++
+			}
+			line.Suppliers = quoteSuppliers(it, supplier, xeroPrices, cur)
+			var err error
+			line.UnitPrice, line.PriceSource, err = cur.UnitPrice(it, supplier, xeroPrices)
+			switch {
+			case err != nil:
+				line.NoRate = true
+				p.NoRate++
+			case line.PriceSource == "":
+				p.Unpriced++
+			default:
+				line.LineTotal = line.Quantity * line.UnitPrice
+				p.Total += line.LineTotal
+			}
+			p.Lines = append(p.Lines, line)
+		}
+		if base, ok := cur.ToBase(supplier, p.Total); ok && p.Currency != "" {
+			p.Rate, p.BaseTotal = cur.Rate(supplier), base
+		}
+		sort.Slice(p.Lines, func(i, j int) bool { return p.Lines[i].ItemID < p.Lines[j].ItemID })
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Supplier < out[j].Supplier })
+	return out
+}
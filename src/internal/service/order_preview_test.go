@@ -0,0 +1,121 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSupplierTerms_OrderQuantity(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		uom             string
+		pack, qty, want float64
+	}{
+		{"", 0, 7, 7},
+		{"", 1, 7, 7},
+		{"", 10, 7, 10},
+		{"", 10, 10, 10},
+		{"", 10, 11, 20},
+		{"", 6, 0, 0},
+		{"sheet", 0, 2.2, 3},  // whole sheets
+		{"m", 0, 2.345, 2.35}, // to the centimetre
+		{"m", 2.5, 0.1 * 3 * 10, 5},
+	} {
+		if got := (SupplierTerms{PackSize: tc.pack, UOM: tc.uom}).OrderQuantity(tc.qty); got != tc.want {
+			t.Errorf("%s pack %v qty %v: got %v, want %v", tc.uom, tc.pack, tc.qty, got, tc.want)
+		}
+	}
+}
+
+func TestBuildPOPreview(t *testing.T) {
+	t.Parallel()
+	orderDate := time.Date(2025, 6, 2, 15, 30, 0, 0, time.UTC)
+	got := BuildPOPreview(map[string][]ContactItem{
+		"S-002": {{ItemID: "LED", Quantity: 3}},
+		"S-001": {
+			{ItemID: "NUT", Quantity: 30, Terms: SupplierTerms{PackSize: 100, MinimumOrderQty: 500, LeadTimeDays: 3, HasLeadTime: true}},
+			{ItemID: "BOLT", Quantity: 45, Terms: SupplierTerms{PackSize: 50, MinimumOrderQty: 50, LeadTimeDays: 10, HasLeadTime: true, UnitPrice: 0.25}},
+		},
+	}, orderDate, map[string]float64{"BOLT": 0.5, "NUT": 0.05}, SupplierCurrencies{})
+
+	want := []POPreview{
+		{
+			Supplier: "S-001",
+			Lines: []POPreviewLine{
+				{ItemID: "BOLT", Requested: 45, Quantity: 50, PackSize: 50, MinimumOrderQty: 50, ExpectedArrival: time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC),
+					UnitPrice: 0.25, PriceSource: PriceSourceSupplier, LineTotal: 12.5},
+				{ItemID: "NUT", Requested: 30, Quantity: 100, PackSize: 100, MinimumOrderQty: 500, BelowMOQ: true, ExpectedArrival: time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC),
+					UnitPrice: 0.05, PriceSource: PriceSourceXero, LineTotal: 5},
+			},
+			ExpectedArrival: time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC),
+			BelowMOQ:        1,
+			Total:           17.5,
+		},
+		{Supplier: "S-002", Lines: []POPreviewLine{{ItemID: "LED", Requested: 3, Quantity: 3}}, Unpriced: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestQuoteSuppliers(t *testing.T) {
+	t.Parallel()
+	it := ContactItem{ItemID: "BOLT", Quantity: 45, Suppliers: []ItemSupplier{
+		{ContactID: "S-002", Preferred: true, Terms: SupplierTerms{PackSize: 50}},
+		{ContactID: "S-001", Terms: SupplierTerms{UnitPrice: 0.5}},
+		{ContactID: "S-003"},
+	}}
+	got := quoteSuppliers(it, "S-001", map[string]float64{"BOLT": 0.25}, SupplierCurrencies{})
+	want := []SupplierQuote{
+		{ContactID: "S-002", Preferred: true, Quantity: 50, UnitPrice: 0.25, PriceSource: PriceSourceXero, LineTotal: 12.5},
+		{ContactID: "S-001", Chosen: true, Quantity: 45, UnitPrice: 0.5, PriceSource: PriceSourceSupplier, LineTotal: 22.5},
+		{ContactID: "S-003", Cheapest: true, Quantity: 45, UnitPrice: 0.25, PriceSource: PriceSourceXero, LineTotal: 11.25},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+
+	if got := quoteSuppliers(ContactItem{ItemID: "BOLT", Quantity: 45, Suppliers: it.Suppliers[:1]}, "S-002", nil, SupplierCurrencies{}); got != nil {
+		t.Errorf("single supplier: got %+v, want nil", got)
+	}
+
+	// quotes are compared in the base currency; one without a rate is not compared
+	cur := SupplierCurrencies{ByContact: map[string]string{"S-002": "EUR", "S-003": "USD"}, Rates: map[string]float64{"EUR": 2}}
+	got = quoteSuppliers(it, "S-001", map[string]float64{"BOLT": 0.25}, cur)
+	want = []SupplierQuote{
+		{ContactID: "S-002", Preferred: true, Cheapest: true, Quantity: 50, UnitPrice: 0.5, PriceSource: PriceSourceXero, LineTotal: 25, Currency: "EUR"},
+		{ContactID: "S-001", Chosen: true, Quantity: 45, UnitPrice: 0.5, PriceSource: PriceSourceSupplier, LineTotal: 22.5},
+		{ContactID: "S-003", Quantity: 45, Currency: "USD"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("in currencies: got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestBuildPOPreview_Currency(t *testing.T) {
+	t.Parallel()
+	grouped := map[string][]ContactItem{
+		"S-EU": {
+			{ItemID: "BOLT", Quantity: 10, Terms: SupplierTerms{UnitPrice: 0.3}},
+			{ItemID: "NUT", Quantity: 10},
+		},
+		"S-US": {{ItemID: "NUT", Quantity: 10}},
+	}
+	cur := SupplierCurrencies{ByContact: map[string]string{"S-EU": "EUR", "S-US": "USD"}, Rates: map[string]float64{"EUR": 1.25}}
+	got := BuildPOPreview(grouped, time.Now(), map[string]float64{"NUT": 0.08}, cur)
+	if len(got) != 2 {
+		t.Fatalf("got %+v", got)
+	}
+	eu, us := got[0], got[1]
+	if eu.Currency != "EUR" || eu.Total != 4 || eu.Rate != 1.25 || eu.BaseTotal != 3.2 || eu.Unpriced != 0 {
+		t.Errorf("EUR preview: %+v", eu)
+	}
+	if eu.Lines[1].UnitPrice != 0.1 || eu.Lines[1].PriceSource != PriceSourceXero {
+		t.Errorf("Xero price not converted: %+v", eu.Lines[1])
+	}
+	// without a rate the Xero price cannot be converted, so the line is flagged
+	if us.Currency != "USD" || us.Total != 0 || us.Unpriced != 0 || us.NoRate != 1 || !us.Lines[0].NoRate || us.Rate != 0 || us.BaseTotal != 0 {
+		t.Errorf("USD preview: %+v", us)
+	}
+}
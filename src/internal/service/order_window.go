@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReleasedBySchedule is who released held orders, and requested approval for them,
+// when the release job did rather than a user.
+const ReleasedBySchedule = "schedule"
+
+// LastRelease is the owner's most recent scheduled release at or before now: the
+// latest ReleaseWeekday at ReleaseHour UTC.
+func (s OwnerSettings) LastRelease(now time.Time) time.Time {
+	now = now.UTC()
+	t := time.Date(now.Year(), now.Month(), now.Day(), s.ReleaseHour, 0, 0, 0, time.UTC)
+	t = t.AddDate(0, 0, -int((7+now.Weekday()-s.ReleaseWeekday)%7))
+	if t.After(now) {
+		t = t.AddDate(0, 0, -7)
+	}
+	return t
+}
+
+// NextRelease is the owner's first scheduled release after now.
+func (s OwnerSettings) NextRelease(now time.Time) time.Time {
+	return s.LastRelease(now).AddDate(0, 0, 7)
+}
+
+// ReleaseDue reports whether held orders last released at releasedAt (unix seconds)
+// are due: a scheduled release has passed since. It is always true when orders are
+// not held.
+func (s OwnerSettings) ReleaseDue(releasedAt int64, now time.Time) bool {
+	return !s.HoldOrders || releasedAt < s.LastRelease(now).Unix()
+}
+
+// GetOrderRelease returns when the owner's held orders were last released (or the
+// hold turned on), 0 for never.
+func GetOrderRelease(ctx context.Context, dbURL, ownerID string) (int64, error) {
+	if dbURL == "" {
+		return 0, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return 0, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var at int64
+	err = pool.QueryRow(ctx, `SELECT released_at FROM order_releases WHERE owner_id = $1`, ownerID).Scan(&at)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("load order_release: %w", err)
+	}
+	return at, nil
+}
+
+// RecordOrderRelease records that releasedBy (a user id, or ReleasedBySchedule)
+// released the owner's held orders now, starting the next window.
+func RecordOrderRelease(ctx context.Context, dbURL, ownerID, releasedBy string) error {
+	if releasedBy == "" {
+		return fmt.Errorf("user id missing")
+	}
+	return WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		if err := saveOrderRelease(ctx, tx, ownerID, releasedBy); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  AuditOrdersReleased,
+			Detail:  map[string]any{"released_by": releasedBy},
+		})
+	})
+}
+
+func saveOrderRelease(ctx context.Context, tx pgx.Tx, ownerID, releasedBy string) error {
+	_, err := tx.Exec(ctx, `
+INSERT INTO order_releases (owner_id, released_at, released_by) VALUES ($1, (extract(epoch from now()))::bigint, $2)
+ON CONFLICT (owner_id) DO UPDATE SET released_at = EXCLUDED.released_at, released_by = EXCLUDED.released_by
+`, ownerID, releasedBy)
+	if err != nil {
+		return fmt.Errorf("upsert order_release: %w", err)
+	}
+	return nil
+}
+
+// ListHoldingOwners returns the owners whose settings hold orders for release.
+func ListHoldingOwners(ctx context.Context, dbURL string) ([]string, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `SELECT owner_id FROM owner_settings WHERE hold_orders ORDER BY owner_id`)
+	if err != nil {
+		return nil, fmt.Errorf("query owner_settings: %w", err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan owner_settings: %w", err)
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOwnerSettings_Releases(t *testing.T) {
+	t.Parallel()
+	s := OwnerSettings{HoldOrders: true, ReleaseWeekday: time.Friday, ReleaseHour: 12}
+	at := func(day, hour int) time.Time { return time.Date(2026, time.October, day, hour, 0, 0, 0, time.UTC) } // 16th is a Friday
+	cases := []struct {
+		now        time.Time
+		last, next time.Time
+	}{
+		{at(14, 9), at(9, 12), at(16, 12)},  // Wednesday
+		{at(16, 11), at(9, 12), at(16, 12)}, // Friday, before the hour
+		{at(16, 12), at(16, 12), at(23, 12)},
+		{at(18, 23), at(16, 12), at(23, 12)}, // Sunday
+	}
+	for _, c := range cases {
+		if got := s.LastRelease(c.now); !got.Equal(c.last) {
+			t.Errorf("LastRelease(%v) = %v, want %v", c.now, got, c.last)
+		}
+		if got := s.NextRelease(c.now); !got.Equal(c.next) {
+			t.Errorf("NextRelease(%v) = %v, want %v", c.now, got, c.next)
+		}
+	}
+
+	// times in other zones count in UTC
+	if got := s.LastRelease(at(16, 12).In(time.FixedZone("NZDT", 13*3600))); !got.Equal(at(16, 12)) {
+		t.Errorf("LastRelease in another zone = %v", got)
+	}
+}
+
+func TestOwnerSettings_ReleaseDue(t *testing.T) {
+	t.Parallel()
+	s := OwnerSettings{HoldOrders: true, ReleaseWeekday: time.Friday, ReleaseHour: 12}
+	now := time.Date(2026, time.October, 16, 13, 0, 0, 0, time.UTC)
+	friday := time.Date(2026, time.October, 16, 12, 0, 0, 0, time.UTC).Unix()
+	cases := []struct {
+		releasedAt int64
+		want       bool
+	}{
+		{0, true},
+		{friday - 3600, true}, // released before this week's release
+		{friday, false},
+		{friday + 60, false}, // already released since
+	}
+	for _, c := range cases {
+		if got := s.ReleaseDue(c.releasedAt, now); got != c.want {
+			t.Errorf("ReleaseDue(%d) = %v, want %v", c.releasedAt, got, c.want)
+		}
+	}
+	s.HoldOrders = false
+	if !s.ReleaseDue(friday, now) {
+		t.Error("orders not held should always be due")
+	}
+}
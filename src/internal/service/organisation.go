@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// organisationCacheTTL bounds how long a tenant's organisation details are reused.
+// Neither the base currency (fixed once there are transactions) nor the short code
+// used in deep links changes in practice.
+const organisationCacheTTL = 24 * time.Hour
+
+// XeroOrganisation returns the tenant's Xero organisation (base currency, short code
+// for deep links), caching it in c (nil caches nothing).
+func XeroOrganisation(ctx context.Context, c cache.Cache, xc *xero.Client, accessToken, tenantID string) (xero.Organisation, error) {
+	key := "xero:org:" + tenantID
+	if c != nil {
+		cached, err := cache.GetJSON[xero.Organisation](ctx, c, key)
+		if err != nil {
+			cacheMiss("get organisation", err)
+		}
+		if org, ok := cached[key]; ok {
+			return org, nil
+		}
+	}
+	org, err := xc.GetOrganisation(ctx, accessToken, tenantID)
+	if err != nil {
+		return xero.Organisation{}, err
+	}
+	if c != nil {
+		if err := cache.SetJSON(ctx, c, map[string]xero.Organisation{key: org}, organisationCacheTTL); err != nil {
+			cacheMiss("set organisation", err)
+		}
+	}
+	return org, nil
+}
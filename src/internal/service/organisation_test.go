@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestXeroOrganisation_Cached(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"Organisations":[{"Name":"Demo","BaseCurrency":"AUD"}]}`))
+	}))
+	defer ts.Close()
+	xc := xero.NewClient(ts.Client(), ts.URL)
+	ctx := context.Background()
+	c := cache.NewMemory()
+
+	for range 2 {
+		if org, err := XeroOrganisation(ctx, c, xc, "at", "tenant-1"); org.BaseCurrency != "AUD" || err != nil {
+			t.Fatalf("XeroOrganisation = %+v, %v", org, err)
+		}
+	}
+	_, _ = XeroOrganisation(ctx, c, xc, "at", "tenant-2")
+	_, _ = XeroOrganisation(ctx, nil, xc, "at", "tenant-1")
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("%d calls to Xero, want 3", n)
+	}
+}
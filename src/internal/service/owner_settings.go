@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Purchase order statuses an owner can have new POs created in.
+const (
+	POStatusDraft      = "DRAFT"
+	POStatusSubmitted  = "SUBMITTED" // awaiting approval
+	POStatusAuthorised = "AUTHORISED"
+)
+
+// BOM expansion depth limits for invoice resolution.
+const (
+	DefaultBOMMaxDepth = 12
+	MaxBOMMaxDepth     = 50
+)
+
+// DefaultInvoiceStatuses are the invoice statuses parts are ordered for unless an
+// owner chooses otherwise: not drafts, and not voided or deleted invoices.
+var DefaultInvoiceStatuses = []string{"SUBMITTED", "AUTHORISED", "PAID"}
+
+// POSettings are the purchase order header defaults, editable on the PO preview.
+type POSettings struct {
+	DeliveryAddress string `json:"delivery_address"`
+	AttentionTo     string `json:"attention_to"`
+	// Reference is sent on every PO; "" uses the PO's source invoice numbers.
+	Reference string `json:"reference"`
+}
+
+// OwnerSettings are an owner's preferences (owner_settings).
+type OwnerSettings struct {
+	POStatus        string `json:"po_status"`
+	BrandingThemeID string `json:"branding_theme_id"` // "" = the organisation's default theme
+	POSettings
+	// AutoEmailSuppliers creates POs marked as sent to the supplier (SentToContact);
+	// Xero's API can't send the purchase order email itself.
+	AutoEmailSuppliers bool `json:"auto_email_suppliers"`
+	BOMMaxDepth        int  `json:"bom_max_depth"`
+	// InvoiceStatuses are the Xero invoice statuses BOMs are resolved for.
+	InvoiceStatuses []string `json:"invoice_statuses"`
+	// TrackingCategory names a Xero tracking category; PO lines are assigned the
+	// option named after their source invoice. "" leaves lines untracked.
+	TrackingCategory string `json:"tracking_category"`
+	// ApprovalThreshold is the purchase order value above which a run waits for an
+	// approver (see NeedsApproval); 0 sends every run straight to Xero.
+	ApprovalThreshold float64 `json:"approval_threshold"`
+	// ApprovalScope is ApprovalScopeBatch or ApprovalScopePO.
+	ApprovalScope string `json:"approval_scope"`
+	// BOMReview holds parts list imports as proposals (bom_proposals) until a second
+	// user approves them; until then BOMs resolve from the current parent_child.
+	BOMReview bool `json:"bom_review"`
+	// HoldOrders keeps unordered rows on the shopping list until the weekly release
+	// (ReleaseWeekday at ReleaseHour UTC), when one purchase order per supplier is
+	// raised for everything ordered since the last; see ReleaseDue.
+	HoldOrders     bool         `json:"hold_orders"`
+	ReleaseWeekday time.Weekday `json:"release_weekday"`
+	ReleaseHour    int          `json:"release_hour"`
+}
+
+// DefaultOwnerSettings are the settings of an owner who has not saved any.
+func DefaultOwnerSettings() OwnerSettings {
+	return OwnerSettings{POStatus: POStatusAuthorised, BOMMaxDepth: DefaultBOMMaxDepth, InvoiceStatuses: slices.Clone(DefaultInvoiceStatuses), ApprovalScope: ApprovalScopeBatch,
+		ReleaseWeekday: time.Friday, ReleaseHour: 12}
+}
+
+// Validate checks the settings can be saved.
+func (s OwnerSettings) Validate() error {
+	switch s.POStatus {
+	case POStatusDraft, POStatusSubmitted, POStatusAuthorised:
+	default:
+		return invalid("po_status", "invalid purchase order status %q", s.POStatus)
+	}
+	if s.BOMMaxDepth < 1 || s.BOMMaxDepth > MaxBOMMaxDepth {
+		return invalid("bom_max_depth", "BOM max depth must be between 1 and %d", MaxBOMMaxDepth)
+	}
+	if len(strings.TrimSpace(s.Reference)) > maxReferenceLen {
+		return invalid("reference", "reference is longer than %d characters", maxReferenceLen)
+	}
+	if len(strings.TrimSpace(s.TrackingCategory)) > maxTrackingNameLen {
+		return invalid("tracking_category", "tracking category is longer than %d characters", maxTrackingNameLen)
+	}
+	if len(s.InvoiceStatuses) == 0 {
+		return invalid("invoice_statuses", "choose at least one invoice status")
+	}
+	for _, st := range s.InvoiceStatuses {
+		if !slices.Contains(xero.InvoiceStatuses, st) {
+			return invalid("invoice_statuses", "invalid invoice status %q", st)
+		}
+	}
+	if s.ApprovalThreshold < 0 || s.ApprovalThreshold > maxApprovalThreshold {
+		return invalid("approval_threshold", "approval threshold must be between 0 and %.0f", maxApprovalThreshold)
+	}
+	switch s.ApprovalScope {
+	case ApprovalScopeBatch, ApprovalScopePO:
+	default:
+		return invalid("approval_scope", "invalid approval scope %q", s.ApprovalScope)
+	}
+	if s.ReleaseWeekday < time.Sunday || s.ReleaseWeekday > time.Saturday {
+		return invalid("release_weekday", "invalid release day %d", s.ReleaseWeekday)
+	}
+	if s.ReleaseHour < 0 || s.ReleaseHour > 23 {
+		return invalid("release_hour", "release hour must be between 0 and 23")
+	}
+	return nil
+}
+
+// ResolveOptions are the settings ResolveInvoice uses.
+func (s OwnerSettings) ResolveOptions() ResolveOptions {
+	return ResolveOptions{MaxDepth: s.BOMMaxDepth, Statuses: s.InvoiceStatuses}
+}
+
+// ParseOwnerSettings reads the /settings form. Missing fields take their defaults.
+func ParseOwnerSettings(v url.Values) (OwnerSettings, error) {
+	s := DefaultOwnerSettings()
+	if st := strings.ToUpper(strings.TrimSpace(v.Get("po_status"))); st != "" {
+		s.POStatus = st
+	}
+	s.BrandingThemeID = strings.TrimSpace(v.Get("branding_theme_id"))
+	s.DeliveryAddress = strings.TrimSpace(v.Get("delivery_address"))
+	s.AttentionTo = strings.TrimSpace(v.Get("attention_to"))
+	s.Reference = strings.TrimSpace(v.Get("reference"))
+	s.AutoEmailSuppliers = v.Get("auto_email_suppliers") != ""
+	s.BOMReview = v.Get("bom_review") != ""
+	s.TrackingCategory = strings.TrimSpace(v.Get("tracking_category"))
+	s.HoldOrders = v.Get("hold_orders") != ""
+	if t := strings.TrimSpace(v.Get("approval_threshold")); t != "" {
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil || math.IsNaN(f) {
+			return s, invalid("approval_threshold", "invalid approval threshold %q", t)
+		}
+		s.ApprovalThreshold = f
+	}
+	if sc := strings.TrimSpace(v.Get("approval_scope")); sc != "" {
+		s.ApprovalScope = sc
+	}
+	if d := strings.TrimSpace(v.Get("release_weekday")); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return s, invalid("release_weekday", "invalid release day %q", d)
+		}
+		s.ReleaseWeekday = time.Weekday(n)
+	}
+	if hr := strings.TrimSpace(v.Get("release_hour")); hr != "" {
+		n, err := strconv.Atoi(hr)
+		if err != nil {
+			return s, invalid("release_hour", "invalid release hour %q", hr)
+		}
+		s.ReleaseHour = n
+	}
+	if d := strings.TrimSpace(v.Get("bom_max_depth")); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return s, invalid("bom_max_depth", "invalid BOM max depth %q", d)
+		}
+		s.BOMMaxDepth = n
+	}
+	// the form sends invoice_statuses_set with its checkboxes, so none ticked is an
+	// error rather than the defaults
+	if _, ok := v["invoice_statuses_set"]; ok || len(v["invoice_status"]) > 0 {
+		s.InvoiceStatuses = nil
+		for _, st := range v["invoice_status"] {
+			if st = strings.ToUpper(strings.TrimSpace(st)); st != "" && !slices.Contains(s.InvoiceStatuses, st) {
+				s.InvoiceStatuses = append(s.InvoiceStatuses, st)
+			}
+		}
+	}
+	return s, s.Validate()
+}
+
+// maxReferenceLen is the longest Reference Xero accepts on a purchase order.
+const maxReferenceLen = 255
+
+// maxApprovalThreshold is the largest approval threshold owner_settings can hold.
+const maxApprovalThreshold = 999_999_999_999.99
+
+// maxTrackingNameLen is the longest tracking category or option name Xero accepts.
+const maxTrackingNameLen = 100
+
+// Details are the Xero header fields for a PO raised from rows with the given
+// source invoices.
+func (s POSettings) Details(sourceInvoices []string) xero.PODetails {
+	ref := strings.TrimSpace(s.Reference)
+	if ref == "" {
+		ref = strings.Join(sourceInvoices, ", ")
+	}
+	if len(ref) > maxReferenceLen {
+		ref = ref[:maxReferenceLen]
+	}
+	return xero.PODetails{
+		DeliveryAddress: strings.TrimSpace(s.DeliveryAddress),
+		AttentionTo:     strings.TrimSpace(s.AttentionTo),
+		Reference:       ref,
+	}
+}
+
+// PODetails are the Xero fields for a new PO raised from rows with the given
+// source invoices: the POSettings header plus status, theme and sent flag.
+func (s OwnerSettings) PODetails(sourceInvoices []string) xero.PODetails {
+	d := s.POSettings.Details(sourceInvoices)
+	d.Status = s.POStatus
+	d.BrandingThemeID = s.BrandingThemeID
+	d.SentToContact = s.AutoEmailSuppliers
+	return d
+}
+
+// SourceInvoices returns the distinct, sorted invoice numbers of the rows behind
+// items (matched by ListIDs); manually added rows have none.
+func SourceInvoices(rows []ShoppingRow, items []ContactItem) []string {
+	byList := make(map[int]string, len(rows))
+	for _, r := range rows {
+		byList[r.ListID] = r.SourceInvoice
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, it := range items {
+		for _, id := range it.ListIDs {
+			if inv := byList[id]; inv != "" && !seen[inv] {
+				seen[inv] = true
+				out = append(out, inv)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ItemSourceInvoice is the source invoice of the rows behind it when they all come
+// from one invoice, else "" (several invoices, or manually added rows only).
+func ItemSourceInvoice(rows []ShoppingRow, it ContactItem) string {
+	if invs := SourceInvoices(rows, []ContactItem{it}); len(invs) == 1 && len(invs[0]) <= maxTrackingNameLen {
+		return invs[0]
+	}
+	return ""
+}
+
+// GetOwnerSettings returns the owner's settings, or the defaults when none are saved.
+func GetOwnerSettings(ctx context.Context, dbURL, ownerID string) (OwnerSettings, error) {
+	if dbURL == "" {
+		return OwnerSettings{}, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return OwnerSettings{}, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var s OwnerSettings
+	err = pool.QueryRow(ctx, `
+SELECT po_status, branding_theme_id, delivery_address, attention_to, po_reference, auto_email_suppliers, bom_max_depth, invoice_statuses, tracking_category,
+       approval_threshold::float8, approval_scope, bom_review, hold_orders, release_weekday, release_hour
+FROM owner_settings WHERE owner_id = $1
+`, ownerID).Scan(&s.POStatus, &s.BrandingThemeID, &s.DeliveryAddress, &s.AttentionTo, &s.Reference, &s.AutoEmailSuppliers, &s.BOMMaxDepth, &s.InvoiceStatuses, &s.TrackingCategory,
+		&s.ApprovalThreshold, &s.ApprovalScope, &s.BOMReview, &s.HoldOrders, &s.ReleaseWeekday, &s.ReleaseHour)
+	if err == pgx.ErrNoRows {
+		return DefaultOwnerSettings(), nil
+	}
+	if err != nil {
+		return OwnerSettings{}, fmt.Errorf("query owner_settings: %w", err)
+	}
+	return s, nil
+}
+
+// EnsureOwnerSettings creates the owner's settings row with the defaults unless it
+// already exists; called when a user registers.
+func EnsureOwnerSettings(ctx context.Context, dbURL, ownerID string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	if ownerID == "" {
+		return fmt.Errorf("owner id missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	d := DefaultOwnerSettings()
+	_, err = pool.Exec(ctx, `
+INSERT INTO owner_settings (owner_id, po_status, bom_max_depth) VALUES ($1, $2, $3)
+ON CONFLICT (owner_id) DO NOTHING
+`, ownerID, d.POStatus, d.BOMMaxDepth)
+	if err != nil {
+		return fmt.Errorf("insert owner_settings: %w", err)
+	}
+	return nil
+}
+
+// SaveOwnerSettings validates and stores the owner's settings. Turning HoldOrders on
+// starts a new window: orders are first released at the next scheduled release.
+func SaveOwnerSettings(ctx context.Context, dbURL, ownerID string, s OwnerSettings) error {
+	if ownerID == "" {
+		return fmt.Errorf("owner id missing")
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	return WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		var held bool
+		err := tx.QueryRow(ctx, `SELECT hold_orders FROM owner_settings WHERE owner_id = $1 FOR UPDATE`, ownerID).Scan(&held)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("load owner_settings: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+INSERT INTO owner_settings (owner_id, po_status, branding_theme_id, delivery_address, attention_to, po_reference, auto_email_suppliers, bom_max_depth, invoice_statuses, tracking_category,
+                            approval_threshold, approval_scope, bom_review, hold_orders, release_weekday, release_hour)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+ON CONFLICT (owner_id) DO UPDATE
+  SET po_status = EXCLUDED.po_status, branding_theme_id = EXCLUDED.branding_theme_id,
+      delivery_address = EXCLUDED.delivery_address, attention_to = EXCLUDED.attention_to,
+      po_reference = EXCLUDED.po_reference, auto_email_suppliers = EXCLUDED.auto_email_suppliers,
+      bom_max_depth = EXCLUDED.bom_max_depth, invoice_statuses = EXCLUDED.invoice_statuses,
+      tracking_category = EXCLUDED.tracking_category, approval_threshold = EXCLUDED.approval_threshold,
+      approval_scope = EXCLUDED.approval_scope, bom_review = EXCLUDED.bom_review,
+      hold_orders = EXCLUDED.hold_orders, release_weekday = EXCLUDED.release_weekday, release_hour = EXCLUDED.release_hour
+`, ownerID, s.POStatus, strings.TrimSpace(s.BrandingThemeID), strings.TrimSpace(s.DeliveryAddress),
+			strings.TrimSpace(s.AttentionTo), strings.TrimSpace(s.Reference), s.AutoEmailSuppliers, s.BOMMaxDepth, s.InvoiceStatuses,
+			strings.TrimSpace(s.TrackingCategory), s.ApprovalThreshold, s.ApprovalScope, s.BOMReview, s.HoldOrders, int(s.ReleaseWeekday), s.ReleaseHour)
+		if err != nil {
+			return fmt.Errorf("upsert owner_settings: %w", err)
+		}
+		if s.HoldOrders && !held {
+			return saveOrderRelease(ctx, tx, ownerID, "")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestSourceInvoices(t *testing.T) {
+	t.Parallel()
+	rows := []ShoppingRow{
+		{ListID: 1, SourceInvoice: "INV-0002"},
+		{ListID: 2, SourceInvoice: "INV-0001"},
+		{ListID: 3},
+		{ListID: 4, SourceInvoice: "INV-0002"},
+		{ListID: 5, SourceInvoice: "INV-0009"},
+	}
+	items := []ContactItem{{ItemID: "BOLT", ListIDs: []int{1, 3}}, {ItemID: "NUT", ListIDs: []int{4, 2}}}
+	if got := SourceInvoices(rows, items); !reflect.DeepEqual(got, []string{"INV-0001", "INV-0002"}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := SourceInvoices(rows, []ContactItem{{ListIDs: []int{3}}}); got != nil {
+		t.Fatalf("manual rows: got %v", got)
+	}
+	if got := ItemSourceInvoice(rows, items[0]); got != "INV-0002" {
+		t.Fatalf("ItemSourceInvoice = %q", got)
+	}
+	if got := ItemSourceInvoice(rows, items[1]); got != "" {
+		t.Fatalf("ItemSourceInvoice of two invoices = %q", got)
+	}
+}
+
+func TestPOSettings_Details(t *testing.T) {
+	t.Parallel()
+	s := POSettings{DeliveryAddress: " Unit 4\nMill Lane ", AttentionTo: "Stores"}
+	want := xero.PODetails{DeliveryAddress: "Unit 4\nMill Lane", AttentionTo: "Stores", Reference: "INV-0001, INV-0002"}
+	if got := s.Details([]string{"INV-0001", "INV-0002"}); got != want {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+
+	s.Reference = "Job 42"
+	if got := s.Details([]string{"INV-0001"}); got.Reference != "Job 42" {
+		t.Fatalf("fixed reference: %+v", got)
+	}
+
+	s.Reference = strings.Repeat("x", 300)
+	if got := s.Details(nil); len(got.Reference) != maxReferenceLen {
+		t.Fatalf("reference not truncated: %d", len(got.Reference))
+	}
+}
+
+func TestParseOwnerSettings(t *testing.T) {
+	t.Parallel()
+	s, err := ParseOwnerSettings(url.Values{})
+	if err != nil || !reflect.DeepEqual(s, DefaultOwnerSettings()) {
+		t.Fatalf("defaults: %+v, %v", s, err)
+	}
+
+	s, err = ParseOwnerSettings(url.Values{
+		"po_status":            {"draft"},
+		"branding_theme_id":    {" theme-1 "},
+		"delivery_address":     {"Unit 4"},
+		"auto_email_suppliers": {"1"},
+		"bom_max_depth":        {"20"},
+	})
+	want := OwnerSettings{POStatus: POStatusDraft, BrandingThemeID: "theme-1", POSettings: POSettings{DeliveryAddress: "Unit 4"}, AutoEmailSuppliers: true, BOMMaxDepth: 20, InvoiceStatuses: DefaultInvoiceStatuses, ApprovalScope: ApprovalScopeBatch,
+		ReleaseWeekday: time.Friday, ReleaseHour: 12}
+	if err != nil || !reflect.DeepEqual(s, want) {
+		t.Fatalf("got %+v, %v\nwant %+v", s, err, want)
+	}
+
+	s, err = ParseOwnerSettings(url.Values{"invoice_statuses_set": {"1"}, "invoice_status": {"paid", "AUTHORISED", "PAID"}})
+	if err != nil || !reflect.DeepEqual(s.InvoiceStatuses, []string{"PAID", "AUTHORISED"}) {
+		t.Fatalf("invoice statuses: %v, %v", s.InvoiceStatuses, err)
+	}
+
+	s, err = ParseOwnerSettings(url.Values{"approval_threshold": {" 2500.50 "}, "approval_scope": {"po"}})
+	if err != nil || s.ApprovalThreshold != 2500.50 || s.ApprovalScope != ApprovalScopePO {
+		t.Fatalf("approval: %+v, %v", s, err)
+	}
+
+	s, err = ParseOwnerSettings(url.Values{"hold_orders": {"1"}, "release_weekday": {"1"}, "release_hour": {" 9 "}})
+	if err != nil || !s.HoldOrders || s.ReleaseWeekday != time.Monday || s.ReleaseHour != 9 {
+		t.Fatalf("ordering window: %+v, %v", s, err)
+	}
+
+	for _, v := range []url.Values{
+		{"po_status": {"PAID"}},
+		{"bom_max_depth": {"0"}},
+		{"bom_max_depth": {"51"}},
+		{"bom_max_depth": {"deep"}},
+		{"reference": {strings.Repeat("x", 256)}},
+		{"invoice_statuses_set": {"1"}},
+		{"invoice_status": {"OVERDUE"}},
+		{"tracking_category": {strings.Repeat("x", 101)}},
+		{"approval_threshold": {"-1"}},
+		{"approval_threshold": {"lots"}},
+		{"approval_scope": {"supplier"}},
+		{"release_weekday": {"7"}},
+		{"release_weekday": {"friday"}},
+		{"release_hour": {"24"}},
+		{"release_hour": {"-1"}},
+	} {
+		if _, err := ParseOwnerSettings(v); err == nil {
+			t.Fatalf("expected error for %v", v)
+		}
+	}
+}
+
+func TestOwnerSettings_PODetails(t *testing.T) {
+	t.Parallel()
+	s := OwnerSettings{POStatus: POStatusSubmitted, BrandingThemeID: "theme-1", POSettings: POSettings{AttentionTo: "Stores"}, AutoEmailSuppliers: true}
+	want := xero.PODetails{Status: POStatusSubmitted, BrandingThemeID: "theme-1", AttentionTo: "Stores", Reference: "INV-0001", SentToContact: true}
+	if got := s.PODetails([]string{"INV-0001"}); got != want {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+}
+
+func TestOwnerSettings_NeedsApproval(t *testing.T) {
+	t.Parallel()
+	po := func(qty, price float64) PlannedPO {
+		return PlannedPO{Lines: []PlannedLine{{POItem: xero.POItem{Quantity: qty, UnitAmount: price}}}}
+	}
+	batch := POBatch{POs: []PlannedPO{po(10, 40), po(3, 100)}} // 400 + 300
+	cases := []struct {
+		threshold float64
+		scope     string
+		want      bool
+	}{
+		{0, ApprovalScopeBatch, false},
+		{700, ApprovalScopeBatch, false},
+		{699.99, ApprovalScopeBatch, true},
+		{500, ApprovalScopeBatch, true},
+		{500, ApprovalScopePO, false},
+		{399, ApprovalScopePO, true},
+		{500, "", true}, // an unset scope counts the batch
+	}
+	for _, c := range cases {
+		s := OwnerSettings{ApprovalThreshold: c.threshold, ApprovalScope: c.scope}
+		if got := s.NeedsApproval(batch); got != c.want {
+			t.Errorf("threshold %v scope %q: NeedsApproval = %v, want %v", c.threshold, c.scope, got, c.want)
+		}
+	}
+	if got := batch.Total(); got != 700 {
+		t.Errorf("Total = %v, want 700", got)
+	}
+
+	// a purchase order in a foreign currency counts in the base currency
+	eur := po(10, 50) // EUR 500
+	eur.Details.CurrencyCode, eur.CurrencyRate = "EUR", 1.25
+	if got := (POBatch{POs: []PlannedPO{eur, po(3, 100)}}).Total(); got != 700 {
+		t.Errorf("Total with EUR = %v, want 700", got)
+	}
+	if (OwnerSettings{ApprovalThreshold: 450, ApprovalScope: ApprovalScopePO}).NeedsApproval(POBatch{POs: []PlannedPO{eur}}) {
+		t.Error("EUR 500 at 1.25 is 400 in the base currency, under 450")
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ListSpec describes a paged list: its page sizes and the keys it can be sorted by.
+// Every paged list query reads its PageRequest through one, so page, per_page, sort
+// and q mean the same on each list.
+type ListSpec struct {
+	PerPage    int // default page size
+	MaxPerPage int
+	// Sorts maps each sort key to the SQL expression it orders by. Only these
+	// expressions reach ORDER BY.
+	Sorts map[string]string
+	// DefaultSort is the sort used without ?sort=, e.g. "-created_at".
+	DefaultSort string
+	// TieBreak is a unique column ordered by after the sort key, so pages never
+	// overlap or skip rows with equal keys.
+	TieBreak string
+}
+
+// PageRequest is one page of a list, sorted and filtered.
+type PageRequest struct {
+	Page    int
+	PerPage int
+	Sort    string // a key of the list's Sorts
+	Desc    bool
+	// Query is the list's free-text filter (q); each list says what it matches.
+	Query string
+}
+
+// Offset is the number of rows before the page.
+func (p PageRequest) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// SortParam is the sort as written in ?sort=: the key, with "-" in front when
+// descending.
+func (p PageRequest) SortParam() string {
+	if p.Desc {
+		return "-" + p.Sort
+	}
+	return p.Sort
+}
+
+// Page is one page of a list with what is needed to link to the others.
+type Page[T any] struct {
+	Items   []T    `json:"items"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+	Total   int    `json:"total"`
+	Sort    string `json:"sort,omitempty"` // as in ?sort=
+	Query   string `json:"q,omitempty"`
+}
+
+// newPage starts the empty page for req.
+func newPage[T any](req PageRequest) Page[T] {
+	return Page[T]{Items: []T{}, Page: req.Page, PerPage: req.PerPage, Sort: req.SortParam(), Query: req.Query}
+}
+
+// Pages is the number of pages, at least 1.
+func (p Page[T]) Pages() int {
+	if p.PerPage < 1 || p.Total <= p.PerPage {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// Parse reads page, per_page, sort and q from query values. per_page is clamped to
+// MaxPerPage; an unknown sort key is an error.
+func (s ListSpec) Parse(v url.Values) (PageRequest, error) {
+	req := s.Normalize(PageRequest{})
+	if str := strings.TrimSpace(v.Get("page")); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 1 {
+			return req, invalid("page", "invalid page %q", str)
+		}
+		req.Page = n
+	}
+	if str := strings.TrimSpace(v.Get("per_page")); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 1 {
+			return req, invalid("per_page", "invalid per_page %q", str)
+		}
+		req.PerPage = min(n, s.MaxPerPage)
+	}
+	if str := strings.TrimSpace(v.Get("sort")); str != "" {
+		key, desc := strings.CutPrefix(str, "-")
+		if _, ok := s.Sorts[key]; !ok {
+			return req, invalid("sort", "invalid sort %q", str)
+		}
+		req.Sort, req.Desc = key, desc
+	}
+	req.Query = strings.TrimSpace(v.Get("q"))
+	return req, nil
+}
+
+// Normalize fills in the defaults for whatever req leaves unset or out of range.
+func (s ListSpec) Normalize(req PageRequest) PageRequest {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PerPage < 1 || req.PerPage > s.MaxPerPage {
+		req.PerPage = s.PerPage
+	}
+	if _, ok := s.Sorts[req.Sort]; !ok {
+		req.Sort, req.Desc = strings.CutPrefix(s.DefaultSort, "-")
+	}
+	return req
+}
+
+// orderBy is the ORDER BY clause (without the keywords) for req, which must be
+// normalized.
+func (s ListSpec) orderBy(req PageRequest) string {
+	dir := " ASC"
+	if req.Desc {
+		dir = " DESC"
+	}
+	order := s.Sorts[req.Sort] + dir
+	if s.TieBreak != "" && s.TieBreak != s.Sorts[req.Sort] {
+		order += ", " + s.TieBreak + dir
+	}
+	return order
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+)
+
+var testListSpec = ListSpec{
+	PerPage:     10,
+	MaxPerPage:  50,
+	Sorts:       map[string]string{"name": "lower(name)", "id": "id"},
+	DefaultSort: "-id",
+	TieBreak:    "id",
+}
+
+func TestListSpecParse(t *testing.T) {
+	t.Parallel()
+	req, err := testListSpec.Parse(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (PageRequest{Page: 1, PerPage: 10, Sort: "id", Desc: true}); req != want {
+		t.Fatalf("defaults = %+v, want %+v", req, want)
+	}
+
+	req, err = testListSpec.Parse(url.Values{"page": {"3"}, "per_page": {"1000"}, "sort": {"name"}, "q": {" bolt "}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (PageRequest{Page: 3, PerPage: 50, Sort: "name", Query: "bolt"}); req != want {
+		t.Fatalf("got %+v, want %+v", req, want)
+	}
+	if req.Offset() != 100 || req.SortParam() != "name" {
+		t.Fatalf("offset %d, sort %q", req.Offset(), req.SortParam())
+	}
+
+	for _, v := range []url.Values{
+		{"page": {"0"}},
+		{"per_page": {"x"}},
+		{"sort": {"owner_id"}},
+		{"sort": {"name; DROP TABLE parts"}},
+	} {
+		_, err := testListSpec.Parse(v)
+		if _, ok := err.(*ValidationError); !ok {
+			t.Errorf("%v: err = %v, want a ValidationError", v, err)
+		}
+	}
+}
+
+func TestListSpecOrderBy(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		req  PageRequest
+		want string
+	}{
+		{PageRequest{}, "id DESC"},
+		{PageRequest{Sort: "name"}, "lower(name) ASC, id ASC"},
+		{PageRequest{Sort: "name", Desc: true}, "lower(name) DESC, id DESC"},
+		{PageRequest{Sort: "unknown"}, "id DESC"},
+	}
+	for _, c := range cases {
+		if got := testListSpec.orderBy(testListSpec.Normalize(c.req)); got != c.want {
+			t.Errorf("orderBy(%+v) = %q, want %q", c.req, got, c.want)
+		}
+	}
+}
+
+func TestPagePages(t *testing.T) {
+	t.Parallel()
+	for _, c := range []struct{ total, perPage, want int }{{0, 10, 1}, {10, 10, 1}, {11, 10, 2}, {95, 10, 10}} {
+		if got := (Page[int]{Total: c.total, PerPage: c.perPage}).Pages(); got != c.want {
+			t.Errorf("Pages(total %d, per page %d) = %d, want %d", c.total, c.perPage, got, c.want)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"math"
+	"strings"
 
 	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -13,9 +16,32 @@ import (
 type BOMNode struct {
 	PartID     string    `json:"part_id"`
 	Name       string    `json:"name"`
-	Quantity   float64   `json:"quantity"`    // effective qty (multiplied up the tree)
-	IsAssembly bool      `json:"is_assembly"` // true when node expands into children
+	Quantity   float64   `json:"quantity"`      // effective qty (multiplied up the tree)
+	UOM        string    `json:"uom,omitempty"` // unit Quantity is in; see package uom
+	IsAssembly bool      `json:"is_assembly"`   // true when node expands into children
 	Children   []BOMNode `json:"children,omitempty"`
+
+	// UnitCost is the purchase cost of one unit (leaves: the default supplier's agreed
+	// price, else the Xero purchase price or parts.cost_price; assemblies: rolled up
+	// from children). TotalCost is UnitCost x Quantity. CostIncomplete marks a missing
+	// price somewhere below.
+	UnitCost       float64 `json:"unit_cost,omitempty"`
+	TotalCost      float64 `json:"total_cost,omitempty"`
+	CostIncomplete bool    `json:"cost_incomplete,omitempty"`
+
+	// Collapsible marks an item with both a supplier and a parts list: it is ordered
+	// whole (a leaf) unless it was expanded, when it is an assembly of its parts.
+	Collapsible bool `json:"collapsible,omitempty"`
+
+	// SubstituteFor is the item this node replaces when the user picked a substitute
+	// for it. Unavailable marks an item flagged unavailable in the parts table.
+	// Substitutes are the orderable alternatives offered for either.
+	SubstituteFor string   `json:"substitute_for,omitempty"`
+	Unavailable   bool     `json:"unavailable,omitempty"`
+	Substitutes   []string `json:"substitutes,omitempty"`
+
+	// Attachments are filled in for rendering only (not carried in the view cookies).
+	Attachments []PartAttachment `json:"-"`
 }
 
 // RootItem is an invoice line root for resolution.
@@ -26,9 +52,17 @@ type RootItem struct {
 }
 
 // ResolveInvoiceBOM expands invoice roots into a tree of purchasable leaves.
-// Uses Xero for item metadata; Supabase for relationships and item->contact mapping.
-// item IDs must be Xero Item Code; contacts are Xero AccountNumber in items_contacts.
-func ResolveInvoiceBOM(ctx context.Context, dbURL string, roots []RootItem, maxDepth int, httpClient *http.Client, accessToken, tenantID string) ([]BOMNode, string, error) {
+// Uses Xero for item metadata; Supabase for the owner's (workspace's) relationships
+// and item->contact mapping. item IDs must be Xero Item Code; contacts are Xero
+// AccountNumber in items_contacts.
+//
+// The whole subtree is loaded with one recursive query (expansion stops at items with a
+// supplier contact, at maxDepth, and at cycles), then names are fetched in one batched
+// Xero lookup backed by an in-process cache and, under WithItemLookups, a per-request memo.
+// Items in expand are expanded into their parts list even when they have a supplier.
+// An item in substitute is replaced by the substitute mapped to it, as long as
+// item_substitutes offers it; see assembleBOM.
+func ResolveInvoiceBOM(ctx context.Context, dbURL, ownerID string, roots []RootItem, maxDepth int, expand []string, substitute map[string]string, xc *xero.Client, accessToken, tenantID string) ([]BOMNode, string, error) {
 	if dbURL == "" {
 		return nil, "", fmt.Errorf("db url missing")
 	}
@@ -38,132 +72,466 @@ func ResolveInvoiceBOM(ctx context.Context, dbURL string, roots []RootItem, maxD
 	}
 	defer pool.Close()
 
-	// default http client when nil to avoid nil-pointer panics in callers/tests
-	if httpClient == nil {
-		httpClient = http.DefaultClient
+	// default xero client when nil to avoid nil-pointer panics in callers/tests
+	if xc == nil {
+		xc = &xero.Client{}
+	}
+
+	rootIDs := make([]string, 0, len(roots))
+	for _, r := range roots {
+		rootIDs = append(rootIDs, r.PartID)
+	}
+
+	edges, err := loadBOMEdges(ctx, pool, ownerID, rootIDs, maxDepth, expand)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// every node id in the expansion (roots + children)
+	ids := uniqueStrings(rootIDs)
+	for _, e := range edges {
+		ids = append(ids, e.ChildID)
 	}
+	ids = uniqueStrings(ids)
 
-	// helpers
-	getItem := func(ctx context.Context, code string) (name string, exists bool, err error) {
-		name, ok, err := xero.GetItemNameByCode(ctx, httpClient, accessToken, tenantID, code)
-		if err != nil {
-			return "", false, err
+	// substitutes are looked up like any other item, so they can be named and priced
+	subs, err := loadSubstitutes(ctx, pool, ownerID, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	subs.Chosen = substitute
+	for _, options := range subs.Options {
+		ids = append(ids, options...)
+	}
+	ids = uniqueStrings(ids)
+
+	contacts, err := loadContactSet(ctx, pool, ownerID, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	// which of the supplier items could be ordered as their parts instead
+	var supplied []string
+	for id := range contacts {
+		supplied = append(supplied, id)
+	}
+	partsLists, err := loadPartsListSet(ctx, pool, ownerID, supplied)
+	if err != nil {
+		return nil, "", err
+	}
+	items, err := itemCache.lookup(ctx, xc, accessToken, tenantID, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	names := make(map[string]string, len(items))
+	costs := make(map[string]float64, len(items))
+	for code, it := range items {
+		names[code] = it.Name
+		if it.PurchaseDetails.UnitPrice > 0 {
+			costs[code] = it.PurchaseDetails.UnitPrice
 		}
-		return name, ok, nil
 	}
-	hasContact := func(ctx context.Context, id string) (bool, error) {
-		var c int
-		if err := pool.QueryRow(ctx, `SELECT COUNT(1) FROM items_contacts WHERE item_id = $1`, id).Scan(&c); err != nil {
-			return false, err
+
+	expandSet := make(map[string]bool, len(expand))
+	for _, id := range expand {
+		expandSet[id] = true
+	}
+	out, msg := assembleBOM(roots, edges, contacts, partsLists, expandSet, subs, names, maxDepth)
+	if msg != "" {
+		return nil, msg, nil
+	}
+
+	// the agreed price of the supplier an item is ordered from wins, as on purchase orders
+	agreed, err := loadSupplierCosts(ctx, pool, ownerID, supplied)
+	if err != nil {
+		return nil, "", err
+	}
+	for id, c := range agreed {
+		costs[id] = c
+	}
+
+	// fall back to the local parts table for leaves without a price
+	var unpriced []string
+	for id := range contacts {
+		if _, ok := costs[id]; !ok {
+			unpriced = append(unpriced, id)
+		}
+	}
+	local, err := loadLocalPartCosts(ctx, pool, unpriced)
+	if err != nil {
+		return nil, "", err
+	}
+	for id, c := range local {
+		costs[id] = c
+	}
+
+	applyLeafCosts(out, costs)
+	RollupBOMCosts(out)
+
+	uoms, err := loadUOMs(ctx, pool, ownerID, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	applyUOMs(out, uoms)
+	return out, "", nil
+}
+
+// loadLocalPartCosts returns cost_price from the optional parts table for ids
+// (empty if the table does not exist in this database).
+func loadLocalPartCosts(ctx context.Context, pool *pgxpool.Pool, ids []string) (map[string]float64, error) {
+	out := map[string]float64{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var exists bool
+	if err := pool.QueryRow(ctx, `SELECT to_regclass('parts') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("check parts table: %w", err)
+	}
+	if !exists {
+		return out, nil
+	}
+	rows, err := pool.Query(ctx, `SELECT part_id, cost_price::float8 FROM parts WHERE part_id = ANY($1) AND cost_price > 0`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query parts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var c float64
+		if err := rows.Scan(&id, &c); err != nil {
+			return nil, fmt.Errorf("scan part cost: %w", err)
 		}
-		return c > 0, nil
+		out[id] = c
+	}
+	return out, rows.Err()
+}
+
+// loadSupplierCosts returns, for ids with one, the agreed unit_price of the supplier
+// each is ordered from by default: the preferred one, else the cheapest (see
+// GroupShoppingItemsByContact).
+func loadSupplierCosts(ctx context.Context, pool *pgxpool.Pool, ownerID string, ids []string) (map[string]float64, error) {
+	out := map[string]float64{}
+	if len(ids) == 0 {
+		return out, nil
 	}
-	getChildren := func(ctx context.Context, id string) (pairs [][2]interface{}, err error) {
-		rows, err := pool.Query(ctx, `SELECT child_id, quantity FROM parent_child WHERE parent_id = $1`, id)
-		if err != nil {
-			return nil, err
+	rows, err := pool.Query(ctx, `
+SELECT DISTINCT ON (item_id) item_id, COALESCE(unit_price, 0)::float8
+FROM items_contacts
+WHERE workspace_id = $1 AND item_id = ANY($2) AND archived_at IS NULL
+ORDER BY item_id, preferred DESC, unit_price ASC NULLS LAST, contact_id
+`, ownerID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var c float64
+		if err := rows.Scan(&id, &c); err != nil {
+			return nil, fmt.Errorf("scan supplier price: %w", err)
 		}
-		defer rows.Close()
-		var out [][2]interface{}
-		for rows.Next() {
-			var child string
-			var qty int
-			if err := rows.Scan(&child, &qty); err != nil {
-				return nil, err
-			}
-			out = append(out, [2]interface{}{child, qty})
+		if c > 0 {
+			out[id] = c
 		}
-		return out, rows.Err()
 	}
+	return out, rows.Err()
+}
 
-	type stackKey struct{ id string }
-	visiting := map[stackKey]bool{}
+// applyLeafCosts sets UnitCost on purchasable leaves from costs.
+func applyLeafCosts(nodes []BOMNode, costs map[string]float64) {
+	for i := range nodes {
+		if !nodes[i].IsAssembly {
+			nodes[i].UnitCost = costs[nodes[i].PartID]
+		}
+		applyLeafCosts(nodes[i].Children, costs)
+	}
+}
 
-	var dfs func(ctx context.Context, id, displayName string, qty float64, depth int) (BOMNode, string, bool, error)
-	dfs = func(ctx context.Context, id, displayName string, qty float64, depth int) (BOMNode, string, bool, error) {
-		if depth > maxDepth {
-			return BOMNode{}, fmt.Sprintf("max depth exceeded while resolving item %s (possible circular reference)", id), false, nil
+// RollupBOMCosts fills TotalCost for every node of an effective-quantity BOM and
+// UnitCost for assemblies (TotalCost / Quantity). Leaves must already carry UnitCost;
+// a leaf with no price marks itself and its ancestors CostIncomplete.
+func RollupBOMCosts(nodes []BOMNode) {
+	for i := range nodes {
+		n := &nodes[i]
+		if !n.IsAssembly {
+			n.TotalCost = roundCost(n.UnitCost * n.Quantity)
+			n.CostIncomplete = n.UnitCost <= 0
+			continue
 		}
-		k := stackKey{id: id}
-		if visiting[k] {
-			return BOMNode{}, fmt.Sprintf("circular parent/child relationship detected at %s", id), false, nil
+		RollupBOMCosts(n.Children)
+		n.TotalCost, n.CostIncomplete = 0, false
+		for _, ch := range n.Children {
+			n.TotalCost += ch.TotalCost
+			n.CostIncomplete = n.CostIncomplete || ch.CostIncomplete
 		}
-		visiting[k] = true
-		defer func() { delete(visiting, k) }()
+		n.TotalCost = roundCost(n.TotalCost)
+		if n.Quantity > 0 {
+			n.UnitCost = roundCost(n.TotalCost / n.Quantity)
+		}
+	}
+}
+
+// BOMTotalCost is the material cost of all roots and whether any price is missing.
+func BOMTotalCost(roots []BOMNode) (float64, bool) {
+	var total float64
+	incomplete := false
+	for _, r := range roots {
+		total += r.TotalCost
+		incomplete = incomplete || r.CostIncomplete
+	}
+	return roundCost(total), incomplete
+}
+
+// roundCost rounds to 4 decimal places (Xero allows 4dp unit prices).
+func roundCost(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// roundQty rounds a quantity to 4 decimal places, the most Xero keeps on a line, so
+// sums of fractional quantities do not carry floating point noise.
+func roundQty(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// bomEdge is one parent->child row of the recursive expansion. Path runs from the
+// root to ChildID; Depth is the child's depth (roots are depth 1).
+type bomEdge struct {
+	ParentID string
+	ChildID  string
+	Quantity float64
+	Depth    int
+	Path     []string
+	IsCycle  bool
+}
+
+// bomExpansionSQL walks the owner's ($4) parent_child from the roots in one query. Items
+// with a supplier contact are purchasable leaves and are not expanded unless they are
+// in $3; cycles are
+// flagged via the path array and not followed. Rows go one level past maxDepth so the
+// caller can report it.
+const bomExpansionSQL = `
+WITH RECURSIVE tree AS (
+  SELECT pc.parent_id, pc.child_id, pc.quantity, 2 AS depth,
+         ARRAY[pc.parent_id, pc.child_id] AS path,
+         pc.child_id = pc.parent_id AS is_cycle
+  FROM parent_child pc
+  WHERE pc.workspace_id = $4
+    AND pc.parent_id = ANY($1)
+    AND (pc.parent_id = ANY($3)
+         OR NOT EXISTS (SELECT 1 FROM items_contacts ic WHERE ic.workspace_id = $4 AND ic.item_id = pc.parent_id AND ic.archived_at IS NULL))
+  UNION ALL
+  SELECT pc.parent_id, pc.child_id, pc.quantity, t.depth + 1,
+         t.path || pc.child_id,
+         pc.child_id = ANY(t.path)
+  FROM tree t
+  JOIN parent_child pc ON pc.workspace_id = $4 AND pc.parent_id = t.child_id
+  WHERE NOT t.is_cycle
+    AND t.depth <= $2
+    AND (t.child_id = ANY($3)
+         OR NOT EXISTS (SELECT 1 FROM items_contacts ic WHERE ic.workspace_id = $4 AND ic.item_id = t.child_id AND ic.archived_at IS NULL))
+)
+SELECT parent_id, child_id, quantity, depth, path, is_cycle
+FROM tree
+ORDER BY depth, path
+`
 
-		// ensure item exists (via Xero)
-		name, exists, err := getItem(ctx, id)
-		if err != nil {
-			return BOMNode{}, "", false, err
+func loadBOMEdges(ctx context.Context, pool *pgxpool.Pool, ownerID string, rootIDs []string, maxDepth int, expand []string) ([]bomEdge, error) {
+	if expand == nil {
+		expand = []string{}
+	}
+	rows, err := pool.Query(ctx, bomExpansionSQL, uniqueStrings(rootIDs), maxDepth, expand, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query bom expansion: %w", err)
+	}
+	defer rows.Close()
+
+	var out []bomEdge
+	for rows.Next() {
+		var e bomEdge
+		if err := rows.Scan(&e.ParentID, &e.ChildID, &e.Quantity, &e.Depth, &e.Path, &e.IsCycle); err != nil {
+			return nil, fmt.Errorf("scan bom edge: %w", err)
 		}
-		if !exists {
-			return BOMNode{}, fmt.Sprintf("item %s not found in Xero", id), false, nil
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func loadContactSet(ctx context.Context, pool *pgxpool.Pool, ownerID string, ids []string) (map[string]bool, error) {
+	rows, err := pool.Query(ctx, `SELECT DISTINCT item_id FROM items_contacts WHERE workspace_id = $1 AND item_id = ANY($2) AND archived_at IS NULL`, ownerID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan item id: %w", err)
 		}
-		if displayName == "" {
-			displayName = name
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+// loadPartsListSet returns the ids that are the parent of at least one of the owner's
+// parent_child rows.
+func loadPartsListSet(ctx context.Context, pool *pgxpool.Pool, ownerID string, ids []string) (map[string]bool, error) {
+	out := map[string]bool{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := pool.Query(ctx, `SELECT DISTINCT parent_id FROM parent_child WHERE workspace_id = $1 AND parent_id = ANY($2)`, ownerID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query parent_child: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan parent id: %w", err)
 		}
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+// assembleBOM builds the BOM tree from the expanded edges. It applies the same checks,
+// in the same order, as a depth-first walk would and returns the first problem found
+// as a user-facing message. Items with a supplier are leaves unless they are in expand
+// and have a parts list (partsLists); either way they are marked Collapsible when they
+// have one.
+//
+// Substitutes are only used when the user chose one (subs.Chosen): the item becomes a
+// leaf of the substitute, whatever its own supplier or parts list. Otherwise an
+// unavailable supplier item is still a leaf, marked with its substitutes, and an item
+// that can be neither ordered nor expanded names its substitutes in the message.
+func assembleBOM(roots []RootItem, edges []bomEdge, contacts, partsLists, expand map[string]bool, subs bomSubstitutes, names map[string]string, maxDepth int) ([]BOMNode, string) {
+	children := map[string][]bomEdge{} // parent path key -> child edges
+	for _, e := range edges {
+		k := pathKey(e.Path[:len(e.Path)-1])
+		children[k] = append(children[k], e)
+	}
 
-		// purchasable leaf if it has a contact mapping
-		okContact, err := hasContact(ctx, id)
-		if err != nil {
-			return BOMNode{}, "", false, err
+	var build func(id, displayName string, qty float64, depth int, path []string, cycle bool) (BOMNode, string)
+	build = func(id, displayName string, qty float64, depth int, path []string, cycle bool) (BOMNode, string) {
+		if depth > maxDepth {
+			return BOMNode{}, fmt.Sprintf("max depth exceeded while resolving item %s (possible circular reference)", id)
 		}
-		if okContact {
-			return BOMNode{
-				PartID:     id,
-				Name:       displayName,
-				Quantity:   qty,
-				IsAssembly: false,
-				Children:   nil,
-			}, "", true, nil
+		if cycle {
+			return BOMNode{}, fmt.Sprintf("circular parent/child relationship detected at %s", id)
 		}
-
-		// expand children (from Supabase)
-		children, err := getChildren(ctx, id)
-		if err != nil {
-			return BOMNode{}, "", false, err
+		if sub := subs.Chosen[id]; sub != "" && subs.offers(id, sub) {
+			subName, exists := names[sub]
+			if !exists {
+				return BOMNode{}, fmt.Sprintf(missingItemFormat, sub)
+			}
+			return BOMNode{PartID: sub, Name: subName, Quantity: qty, SubstituteFor: id, Substitutes: subs.Options[id]}, ""
 		}
-		if len(children) == 0 {
-			return BOMNode{}, fmt.Sprintf("item %s has no supplier contact and no subcomponents", id), false, nil
+		name, exists := names[id]
+		if !exists {
+			return BOMNode{}, fmt.Sprintf(missingItemFormat, id)
 		}
-
-		node := BOMNode{
-			PartID:     id,
-			Name:       displayName,
-			Quantity:   qty,
-			IsAssembly: true,
+		if displayName == "" {
+			displayName = name
 		}
-		for _, pair := range children {
-			childID := pair[0].(string)
-			childQty := float64(pair[1].(int))
-			childNode, errMsg, ok, err := dfs(ctx, childID, "", qty*childQty, depth+1)
-			if err != nil {
-				return BOMNode{}, "", false, err
+
+		kids := children[pathKey(path)]
+		// purchasable leaf if it has a contact mapping, unless its parts were asked for
+		if contacts[id] && (!expand[id] || len(kids) == 0) {
+			node := BOMNode{PartID: id, Name: displayName, Quantity: qty, Collapsible: partsLists[id]}
+			if subs.Unavailable[id] {
+				node.Unavailable, node.Substitutes = true, subs.Options[id]
 			}
-			if errMsg != "" {
-				return BOMNode{}, errMsg, false, nil
+			return node, ""
+		}
+
+		if len(kids) == 0 {
+			if options := subs.Options[id]; len(options) > 0 {
+				return BOMNode{}, fmt.Sprintf(noSupplierSubstitutesFormat, id, strings.Join(options, ", "))
 			}
-			if !ok {
-				return BOMNode{}, "unexpected resolve failure", false, nil
+			return BOMNode{}, fmt.Sprintf("item %s has no supplier contact and no subcomponents", id)
+		}
+		node := BOMNode{PartID: id, Name: displayName, Quantity: qty, IsAssembly: true, Collapsible: contacts[id]}
+		for _, e := range kids {
+			child, msg := build(e.ChildID, "", qty*e.Quantity, e.Depth, e.Path, e.IsCycle)
+			if msg != "" {
+				return BOMNode{}, msg
 			}
-			node.Children = append(node.Children, childNode)
+			node.Children = append(node.Children, child)
 		}
-		return node, "", true, nil
+		return node, ""
 	}
 
-	var rootsOut []BOMNode
+	var out []BOMNode
 	for _, r := range roots {
-		node, errMsg, ok, err := dfs(ctx, r.PartID, r.Name, r.Quantity, 1)
-		if err != nil {
-			return nil, "", err
+		node, msg := build(r.PartID, r.Name, r.Quantity, 1, []string{r.PartID}, false)
+		if msg != "" {
+			return nil, msg
 		}
-		if errMsg != "" || !ok {
-			// any error aborts and return message
-			return nil, errMsg, nil
+		out = append(out, node)
+	}
+	return out, ""
+}
+
+// missingItemFormat is the resolution message for an item code Xero doesn't have.
+const missingItemFormat = "item %s not found in Xero"
+
+// MissingXeroItem returns the item code when msg is a resolution message (possibly
+// prefixed with its invoice) about an item missing from Xero, so callers can offer
+// to create it.
+func MissingXeroItem(msg string) (code string, ok bool) {
+	prefix, suffix, _ := strings.Cut(missingItemFormat, "%s")
+	rest, ok := strings.CutSuffix(msg, suffix)
+	i := strings.LastIndex(rest, prefix)
+	if !ok || i < 0 || (i > 0 && !strings.HasSuffix(rest[:i], ": ")) {
+		return "", false
+	}
+	code = rest[i+len(prefix):]
+	return code, code != ""
+}
+
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// uniqueStrings returns ss without duplicates (order preserved).
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]struct{}, len(ss))
+	out := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if _, ok := seen[s]; ok {
+			continue
 		}
-		rootsOut = append(rootsOut, node)
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	return out
+}
+
+// GetPart returns partID from the local parts table; found=false when it has no row.
+func GetPart(ctx context.Context, dbURL, partID string) (xero.Part, bool, error) {
+	if dbURL == "" {
+		return xero.Part{}, false, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return xero.Part{}, false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	p := xero.Part{PartID: partID}
+	err = pool.QueryRow(ctx, `
+SELECT COALESCE(name, ''), COALESCE(description, ''),
+       COALESCE(cost_price, 0)::float8, COALESCE(sales_price, 0)::float8
+FROM parts WHERE part_id = $1
+`, partID).Scan(&p.Name, &p.Description, &p.CostPrice, &p.SalesPrice)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return xero.Part{}, false, nil
+	}
+	if err != nil {
+		return xero.Part{}, false, fmt.Errorf("query part: %w", err)
 	}
-	return rootsOut, "", nil
+	return p, true, nil
 }
 
 // LoadParts loads parts from the primary DB and returns them as pkg/xero.Part.
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Approval scopes: whether OwnerSettings.ApprovalThreshold applies to a run's total
+// or to each purchase order in it.
+const (
+	ApprovalScopeBatch = "batch"
+	ApprovalScopePO    = "po"
+)
+
+// po_approvals statuses.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// ErrApprovalPending is returned by RequestPOApproval while the workspace already
+// has a batch waiting: its rows would be ordered twice.
+var ErrApprovalPending = errors.New("a purchase order batch is already awaiting approval")
+
+// ErrApprovalDecided is returned by DecidePOApproval for a batch that was already
+// approved or rejected.
+var ErrApprovalDecided = errors.New("purchase order batch already decided")
+
+// ErrSelfApproval is returned by DecidePOApproval when the user deciding is the one
+// who asked for the batch.
+var ErrSelfApproval = errors.New("purchase order batch must be approved by someone other than who requested it")
+
+// PlannedLine is a purchase order line to send. SourceInvoice names the sales
+// invoice it came from, for the line's tracking option, which is only looked up (and
+// created in Xero) when the line is sent.
+type PlannedLine struct {
+	xero.POItem
+	SourceInvoice string `json:"source_invoice,omitempty"`
+}
+
+// PlannedPO is a purchase order to raise for one supplier, in the currency of
+// Details.CurrencyCode ("" = the base currency).
+type PlannedPO struct {
+	ContactAccount string         `json:"contact_account"`
+	ContactID      string         `json:"contact_id"`
+	Lines          []PlannedLine  `json:"lines"`
+	Details        xero.PODetails `json:"details"`
+	// ListIDs are the shopping_list rows the purchase order covers
+	ListIDs []int `json:"list_ids,omitempty"`
+	// CurrencyRate is Xero's rate for Details.CurrencyCode (units per unit of the base
+	// currency) when it is planned in a foreign currency; 0 when there is none yet
+	CurrencyRate float64 `json:"currency_rate,omitempty"`
+}
+
+// Total is the sum of the lines' quantity times unit price; unpriced lines add 0.
+func (p PlannedPO) Total() float64 {
+	var t float64
+	for _, l := range p.Lines {
+		t += l.Quantity * l.UnitAmount
+	}
+	return t
+}
+
+// BaseTotal is Total in the base currency. A purchase order in a currency without a
+// rate counts unconverted.
+func (p PlannedPO) BaseTotal() float64 {
+	if p.Details.CurrencyCode != "" && p.CurrencyRate > 0 {
+		return p.Total() / p.CurrencyRate
+	}
+	return p.Total()
+}
+
+// POBatch is one "Create Purchase Orders" run: the purchase orders to raise in
+// TenantID and the shopping_list versions read (ShoppingVersions) for the rows they
+// cover, which are marked ordered once the POs exist.
+type POBatch struct {
+	TenantID         string      `json:"tenant_id"`
+	TrackingCategory string      `json:"tracking_category,omitempty"`
+	POs              []PlannedPO `json:"pos"`
+	Versions         map[int]int `json:"versions"`
+}
+
+// Total is the sum of the batch's purchase order totals in the base currency.
+func (b POBatch) Total() float64 {
+	var t float64
+	for _, po := range b.POs {
+		t += po.BaseTotal()
+	}
+	return t
+}
+
+// NeedsApproval reports whether b is worth more than the approval threshold: its
+// total, or any one purchase order with ApprovalScopePO. A threshold of 0 never does.
+func (s OwnerSettings) NeedsApproval(b POBatch) bool {
+	if s.ApprovalThreshold <= 0 {
+		return false
+	}
+	if s.ApprovalScope == ApprovalScopePO {
+		for _, po := range b.POs {
+			if po.BaseTotal() > s.ApprovalThreshold {
+				return true
+			}
+		}
+		return false
+	}
+	return b.Total() > s.ApprovalThreshold
+}
+
+// POApproval is a po_approvals row.
+type POApproval struct {
+	ID          int     `json:"id"`
+	RequestedBy string  `json:"requested_by"`
+	Status      string  `json:"status"`
+	Total       float64 `json:"total"`
+	Batch       POBatch `json:"batch"`
+	DecidedBy   string  `json:"decided_by,omitempty"`
+	DecidedAt   int64   `json:"decided_at,omitempty"`
+	CreatedAt   int64   `json:"created_at"`
+}
+
+// RequestPOApproval stores batch as pending approval and returns its id, or
+// ErrApprovalPending while another batch of the owner's waits.
+func RequestPOApproval(ctx context.Context, dbURL, ownerID, requestedBy string, batch POBatch) (int, error) {
+	if requestedBy == "" {
+		return 0, fmt.Errorf("user id missing")
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return 0, fmt.Errorf("encode purchase order batch: %w", err)
+	}
+	var id int
+	err = WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `
+INSERT INTO po_approvals (owner_id, requested_by, total, batch)
+VALUES ($1, $2, $3, $4::jsonb)
+RETURNING id
+`, ownerID, requestedBy, batch.Total(), string(body)).Scan(&id); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" { // po_approvals_one_pending_idx
+				return ErrApprovalPending
+			}
+			return fmt.Errorf("insert po_approval: %w", err)
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  AuditPurchaseOrdersPending,
+			Detail:  map[string]any{"approval_id": id, "purchase_orders": len(batch.POs), "total": batch.Total()},
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// poApprovalColumns are the po_approvals columns scanPOApproval reads, in order.
+const poApprovalColumns = `id, requested_by, status, total::float8, batch, COALESCE(decided_by, ''), COALESCE(decided_at, 0), created_at`
+
+func scanPOApproval(row pgx.Row) (POApproval, error) {
+	var a POApproval
+	var batch []byte
+	if err := row.Scan(&a.ID, &a.RequestedBy, &a.Status, &a.Total, &batch, &a.DecidedBy, &a.DecidedAt, &a.CreatedAt); err != nil {
+		return a, err
+	}
+	if err := json.Unmarshal(batch, &a.Batch); err != nil {
+		return a, fmt.Errorf("decode po_approval %d batch: %w", a.ID, err)
+	}
+	return a, nil
+}
+
+// GetPOApproval returns the owner's batch id, or an error wrapping ErrNotFound.
+func GetPOApproval(ctx context.Context, dbURL, ownerID string, id int) (POApproval, error) {
+	if dbURL == "" {
+		return POApproval{}, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return POApproval{}, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	a, err := scanPOApproval(pool.QueryRow(ctx, `SELECT `+poApprovalColumns+` FROM po_approvals WHERE owner_id = $1 AND id = $2`, ownerID, id))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return POApproval{}, fmt.Errorf("purchase order batch %d: %w", id, ErrNotFound)
+	case err != nil:
+		return POApproval{}, fmt.Errorf("load po_approval: %w", err)
+	}
+	return a, nil
+}
+
+// ListPOApprovals returns the owner's pending batches and the limit most recently
+// decided ones, newest first.
+func ListPOApprovals(ctx context.Context, dbURL, ownerID string, limit int) ([]POApproval, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+(SELECT `+poApprovalColumns+` FROM po_approvals WHERE owner_id = $1 AND status = 'pending')
+UNION ALL
+(SELECT `+poApprovalColumns+` FROM po_approvals WHERE owner_id = $1 AND status <> 'pending' ORDER BY id DESC LIMIT $2)
+ORDER BY id DESC
+`, ownerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query po_approvals: %w", err)
+	}
+	defer rows.Close()
+	var out []POApproval
+	for rows.Next() {
+		a, err := scanPOApproval(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan po_approval: %w", err)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// DecidePOApproval approves or rejects the owner's pending batch id as decidedBy and
+// returns it. It fails with ErrNotFound, ErrApprovalDecided, or ErrSelfApproval when
+// decidedBy asked for the batch. Approving only claims the batch: the caller sends
+// it to Xero, so two approvers cannot both send it.
+func DecidePOApproval(ctx context.Context, dbURL, ownerID string, id int, decidedBy string, approve bool) (POApproval, error) {
+	if decidedBy == "" {
+		return POApproval{}, fmt.Errorf("user id missing")
+	}
+	status, action := ApprovalRejected, AuditPurchaseOrdersRejected
+	if approve {
+		status, action = ApprovalApproved, AuditPurchaseOrdersApproved
+	}
+	var a POApproval
+	err := WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		var err error
+		a, err = scanPOApproval(tx.QueryRow(ctx, `SELECT `+poApprovalColumns+` FROM po_approvals WHERE owner_id = $1 AND id = $2 FOR UPDATE`, ownerID, id))
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return fmt.Errorf("purchase order batch %d: %w", id, ErrNotFound)
+		case err != nil:
+			return fmt.Errorf("load po_approval: %w", err)
+		case a.Status != ApprovalPending:
+			return fmt.Errorf("%w: %s", ErrApprovalDecided, a.Status)
+		case a.RequestedBy == decidedBy:
+			return ErrSelfApproval
+		}
+		a.Status, a.DecidedBy, a.DecidedAt = status, decidedBy, time.Now().Unix()
+		if _, err := tx.Exec(ctx, `
+UPDATE po_approvals SET status = $3, decided_by = $4, decided_at = $5 WHERE owner_id = $1 AND id = $2
+`, ownerID, id, a.Status, a.DecidedBy, a.DecidedAt); err != nil {
+			return fmt.Errorf("update po_approval: %w", err)
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  action,
+			Detail:  map[string]any{"approval_id": id, "requested_by": a.RequestedBy, "decided_by": decidedBy},
+		})
+	})
+	if err != nil {
+		return POApproval{}, err
+	}
+	return a, nil
+}
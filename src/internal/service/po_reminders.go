@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReminderPolicy decides when an unbilled purchase order is outstanding and how often
+// it is reminded about.
+type ReminderPolicy struct {
+	DefaultDays int // days allowed when no lead time is recorded for the PO's lines
+	GraceDays   int // days allowed on top of the supplier's lead time
+	RepeatDays  int // days between reminders for the same PO; 0 reminds once
+}
+
+// OpenPurchaseOrder is a purchase order raised by the app that is neither billed nor
+// deleted as far as the app knows.
+type OpenPurchaseOrder struct {
+	ID             int
+	XeroPOID       string
+	ContactAccount string
+	SupplierName   string
+	CreatedAt      int64
+	RemindedAt     *int64
+	// LeadTimeDays is the longest lead time recorded for the PO's lines from this
+	// supplier; nil when none is recorded
+	LeadTimeDays *int
+}
+
+// OutstandingPurchaseOrder is an open purchase order past its due date, as reported
+// by a reminder.
+type OutstandingPurchaseOrder struct {
+	ID             int       `json:"id"`
+	XeroPOID       string    `json:"xero_po_id"`
+	Number         string    `json:"number"` // Xero PurchaseOrderNumber
+	Status         string    `json:"status"` // Xero status
+	ContactAccount string    `json:"contact_account"`
+	SupplierName   string    `json:"supplier_name"`
+	CreatedAt      time.Time `json:"created_at"`
+	DueAt          time.Time `json:"due_at"`
+	DaysOverdue    int       `json:"days_overdue"`
+}
+
+// DueAt is when po becomes outstanding: its supplier's lead time plus GraceDays after
+// it was raised, or DefaultDays when no lead time is recorded.
+func (p ReminderPolicy) DueAt(po OpenPurchaseOrder) time.Time {
+	days := p.DefaultDays
+	if po.LeadTimeDays != nil {
+		days = *po.LeadTimeDays + p.GraceDays
+	}
+	return time.Unix(po.CreatedAt, 0).UTC().AddDate(0, 0, days)
+}
+
+// dueReminders returns the open purchase orders to remind about at now: past their
+// due date and not reminded about within RepeatDays (ever, when RepeatDays is 0).
+func dueReminders(open []OpenPurchaseOrder, p ReminderPolicy, now time.Time) []OpenPurchaseOrder {
+	var out []OpenPurchaseOrder
+	for _, po := range open {
+		if now.Before(p.DueAt(po)) {
+			continue
+		}
+		if po.RemindedAt != nil {
+			if p.RepeatDays == 0 || now.Before(time.Unix(*po.RemindedAt, 0).AddDate(0, 0, p.RepeatDays)) {
+				continue
+			}
+		}
+		out = append(out, po)
+	}
+	return out
+}
+
+// ListOpenPurchaseOrders returns the owner's purchase orders not known to be billed or
+// deleted in Xero, oldest first.
+func ListOpenPurchaseOrders(ctx context.Context, dbURL, ownerID string) ([]OpenPurchaseOrder, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT po.id, po.xero_po_id, po.contact_account, COALESCE(s.supplier_name, ''), po.created_at, po.reminded_at,
+       (SELECT MAX(ic.lead_time_days)
+        FROM purchase_order_lines l
+        JOIN items_contacts ic ON ic.workspace_id = po.owner_id AND ic.item_id = l.item_id AND ic.contact_id = po.contact_account
+        WHERE l.purchase_order_id = po.id)
+FROM purchase_orders po
+LEFT JOIN suppliers s ON s.supplier_id = po.contact_account
+WHERE po.owner_id = $1 AND po.xero_deleted_at IS NULL AND po.status NOT IN ('BILLED', 'DELETED')
+ORDER BY po.created_at
+`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query purchase_orders: %w", err)
+	}
+	defer rows.Close()
+
+	var out []OpenPurchaseOrder
+	for rows.Next() {
+		var po OpenPurchaseOrder
+		if err := rows.Scan(&po.ID, &po.XeroPOID, &po.ContactAccount, &po.SupplierName, &po.CreatedAt, &po.RemindedAt, &po.LeadTimeDays); err != nil {
+			return nil, fmt.Errorf("scan purchase_order: %w", err)
+		}
+		out = append(out, po)
+	}
+	return out, rows.Err()
+}
+
+// SetPurchaseOrderStatuses records the Xero status of purchase orders, keyed by Xero
+// PurchaseOrderID.
+func SetPurchaseOrderStatuses(ctx context.Context, dbURL string, statuses map[string]string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	if len(statuses) == 0 {
+		return nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	b := &pgx.Batch{}
+	for id, status := range statuses {
+		b.Queue(`UPDATE purchase_orders SET status = $2 WHERE xero_po_id = $1 AND status <> $2`, id, status)
+	}
+	return execBatch(ctx, pool, b, func(int) string { return "update purchase_orders" })
+}
+
+// MarkPurchaseOrdersReminded records that a reminder mentioned the purchase orders.
+func MarkPurchaseOrdersReminded(ctx context.Context, dbURL string, ids []int, at time.Time) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `UPDATE purchase_orders SET reminded_at = $2 WHERE id = ANY($1)`, ids, at.Unix()); err != nil {
+		return fmt.Errorf("update purchase_orders: %w", err)
+	}
+	return nil
+}
+
+// outstandingInXero checks the due purchase orders against Xero's copies: those
+// Xero has billed are returned in billed (Xero id -> status) rather than as
+// outstanding, and those Xero no longer lists are left to reconciliation.
+func outstandingInXero(due []OpenPurchaseOrder, remote []xero.PurchaseOrder, p ReminderPolicy, now time.Time) (outstanding []OutstandingPurchaseOrder, billed map[string]string) {
+	byID := make(map[string]xero.PurchaseOrder, len(remote))
+	for _, r := range remote {
+		byID[r.PurchaseOrderID] = r
+	}
+	billed = map[string]string{}
+	for _, po := range due {
+		r, ok := byID[po.XeroPOID]
+		if !ok {
+			continue
+		}
+		switch status := strings.ToUpper(r.Status); status {
+		case "BILLED":
+			billed[po.XeroPOID] = status
+			continue
+		case "DELETED":
+			continue
+		}
+		dueAt := p.DueAt(po)
+		name := po.SupplierName
+		if name == "" {
+			name = r.Contact.Name
+		}
+		outstanding = append(outstanding, OutstandingPurchaseOrder{
+			ID:             po.ID,
+			XeroPOID:       po.XeroPOID,
+			Number:         r.PurchaseOrderNumber,
+			Status:         r.Status,
+			ContactAccount: po.ContactAccount,
+			SupplierName:   name,
+			CreatedAt:      time.Unix(po.CreatedAt, 0).UTC(),
+			DueAt:          dueAt,
+			DaysOverdue:    int(now.Sub(dueAt).Hours() / 24),
+		})
+	}
+	sort.SliceStable(outstanding, func(i, j int) bool { return outstanding[i].DaysOverdue > outstanding[j].DaysOverdue })
+	return outstanding, billed
+}
+
+// FindOutstandingPurchaseOrders returns the owner's purchase orders that are overdue
+// under p and due a reminder at now, after checking each against Xero. Purchase
+// orders Xero reports as billed are recorded as such and never reminded about again.
+// The caller marks the returned orders reminded once the reminder is sent.
+func FindOutstandingPurchaseOrders(ctx context.Context, dbURL string, xc *xero.Client, ownerID string, creds XeroCredentials, p ReminderPolicy, now time.Time) ([]OutstandingPurchaseOrder, error) {
+	open, err := ListOpenPurchaseOrders(ctx, dbURL, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	due := dueReminders(open, p, now)
+	if len(due) == 0 {
+		return nil, nil
+	}
+	if xc == nil {
+		xc = &xero.Client{}
+	}
+	// due is oldest first
+	remote, err := xc.ListPurchaseOrders(ctx, creds.AccessToken, creds.TenantID, time.Unix(due[0].CreatedAt, 0).UTC().AddDate(0, 0, -1))
+	if err != nil {
+		return nil, fmt.Errorf("list xero purchase orders: %w", err)
+	}
+	outstanding, billed := outstandingInXero(due, remote, p, now)
+	if err := SetPurchaseOrderStatuses(ctx, dbURL, billed); err != nil {
+		return nil, err
+	}
+	return outstanding, nil
+}
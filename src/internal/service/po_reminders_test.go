@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestDueReminders(t *testing.T) {
+	t.Parallel()
+	day := int64(86400)
+	now := time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC)
+	ago := func(days int64) int64 { return now.Unix() - days*day }
+	lead := func(days int) *int { return &days }
+	p := ReminderPolicy{DefaultDays: 14, GraceDays: 3, RepeatDays: 7}
+
+	open := []OpenPurchaseOrder{
+		{ID: 1, CreatedAt: ago(20)},                                                         // no lead time: due after 14 days
+		{ID: 2, CreatedAt: ago(10)},                                                         // not yet
+		{ID: 3, CreatedAt: ago(10), LeadTimeDays: lead(5)},                                  // due after 5+3 days
+		{ID: 4, CreatedAt: ago(10), LeadTimeDays: lead(21)},                                 // long lead time: not yet
+		{ID: 5, CreatedAt: ago(30), RemindedAt: func() *int64 { v := ago(2); return &v }()}, // reminded recently
+		{ID: 6, CreatedAt: ago(30), RemindedAt: func() *int64 { v := ago(7); return &v }()}, // reminded a week ago
+	}
+	var ids []int
+	for _, po := range dueReminders(open, p, now) {
+		ids = append(ids, po.ID)
+	}
+	if want := []int{1, 3, 6}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("due = %v, want %v", ids, want)
+	}
+
+	p.RepeatDays = 0
+	ids = nil
+	for _, po := range dueReminders(open, p, now) {
+		ids = append(ids, po.ID)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("remind once: due = %v, want %v", ids, want)
+	}
+}
+
+func TestOutstandingInXero(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC)
+	created := now.AddDate(0, 0, -20)
+	p := ReminderPolicy{DefaultDays: 14}
+	due := []OpenPurchaseOrder{
+		{ID: 1, XeroPOID: "po-1", ContactAccount: "ACME", CreatedAt: created.Unix()},
+		{ID: 2, XeroPOID: "po-2", ContactAccount: "ACME", CreatedAt: created.Unix()},
+		{ID: 3, XeroPOID: "po-3", ContactAccount: "FAST", SupplierName: "Fasteners", CreatedAt: created.Unix()},
+		{ID: 4, XeroPOID: "po-4", CreatedAt: created.Unix()}, // not listed by Xero
+	}
+	remote := []xero.PurchaseOrder{
+		{PurchaseOrderID: "po-1", PurchaseOrderNumber: "PO-0001", Status: "AUTHORISED", Contact: xero.PurchaseContact{Name: "Acme"}},
+		{PurchaseOrderID: "po-2", Status: "BILLED"},
+		{PurchaseOrderID: "po-3", PurchaseOrderNumber: "PO-0003", Status: "DELETED"},
+	}
+	out, billed := outstandingInXero(due, remote, p, now)
+	want := []OutstandingPurchaseOrder{{
+		ID: 1, XeroPOID: "po-1", Number: "PO-0001", Status: "AUTHORISED", ContactAccount: "ACME", SupplierName: "Acme",
+		CreatedAt: time.Unix(created.Unix(), 0).UTC(), DueAt: created.AddDate(0, 0, 14), DaysOverdue: 6,
+	}}
+	if !reflect.DeepEqual(out, want) {
+		t.Fatalf("outstanding = %+v, want %+v", out, want)
+	}
+	if !reflect.DeepEqual(billed, map[string]string{"po-2": "BILLED"}) {
+		t.Fatalf("billed = %v", billed)
+	}
+}
+
+func TestListOpenPurchaseOrders_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := ListOpenPurchaseOrders(context.Background(), "", "o"); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
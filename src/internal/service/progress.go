@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// Progress is how far a long request (resolving invoices, raising purchase orders)
+// has got, for the progress bar on the page that started it.
+type Progress struct {
+	Message string `json:"message"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"` // 0 when the step is not counted
+	// Finished is set once the request has answered; nothing follows it.
+	Finished bool `json:"finished"`
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context under which ReportProgress calls report to fn. fn
+// is called on the request's goroutine, so it should be quick.
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress tells ctx's reporter that done of total are finished, described by
+// msg ("Resolved 14/37 items"). It does nothing without WithProgress.
+func ReportProgress(ctx context.Context, done, total int, msg string) {
+	if fn, ok := ctx.Value(progressKey{}).(func(Progress)); ok {
+		fn(Progress{Message: msg, Done: done, Total: total})
+	}
+}
+
+// reportItemsResolved reports item lookups during BOM resolution.
+func reportItemsResolved(ctx context.Context, done, total int) {
+	ReportProgress(ctx, done, total, fmt.Sprintf("Resolved %d/%d items", done, total))
+}
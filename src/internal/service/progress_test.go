@@ -0,0 +1,19 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReportProgress(t *testing.T) {
+	t.Parallel()
+	ReportProgress(context.Background(), 1, 2, "nobody listening") // no reporter: no-op
+
+	var got []Progress
+	ctx := WithProgress(context.Background(), func(p Progress) { got = append(got, p) })
+	reportItemsResolved(ctx, 14, 37)
+	ReportProgress(ctx, 2, 5, "Creating PO 3 of 5")
+	if len(got) != 2 || got[0] != (Progress{Message: "Resolved 14/37 items", Done: 14, Total: 37}) || got[1].Message != "Creating PO 3 of 5" {
+		t.Fatalf("reported %+v", got)
+	}
+}
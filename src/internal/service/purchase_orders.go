@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PurchaseOrderLine is a line of a locally recorded purchase order.
+type PurchaseOrderLine struct {
+	ItemID     string  `json:"item_id"`
+	Quantity   float64 `json:"quantity"`
+	UnitAmount float64 `json:"unit_amount,omitempty"` // 0 when Xero's item price applied
+}
+
+// PurchaseOrderRecord is a purchase order this app created in Xero.
+type PurchaseOrderRecord struct {
+	ID             int                 `json:"id"`
+	OwnerID        string              `json:"owner_id"`
+	TenantID       string              `json:"tenant_id"`
+	XeroPOID       string              `json:"xero_po_id"`
+	ContactAccount string              `json:"contact_account"`
+	ContactID      string              `json:"contact_id"`
+	Status         string              `json:"status"`
+	CurrencyCode   string              `json:"currency_code,omitempty"` // "" = the base currency
+	CurrencyRate   float64             `json:"currency_rate,omitempty"` // units per unit of the base currency; 0 = unknown
+	XeroDeletedAt  *int64              `json:"xero_deleted_at,omitempty"`
+	CreatedAt      int64               `json:"created_at"`
+	Lines          []PurchaseOrderLine `json:"lines,omitempty"`
+}
+
+// RecordPurchaseOrders finishes a "Create Purchase Orders" run in one transaction: it
+// stores the purchase orders created in Xero, marks the shopping list rows they cover
+// ordered (see markShoppingListOrdered) and writes an audit_log row. Nothing is
+// stored if any step fails. Rows changed since they were read are left unmarked and
+// returned in a *ShoppingConflictError after the rest has committed.
+func RecordPurchaseOrders(ctx context.Context, dbURL, ownerID string, pos []PurchaseOrderRecord, versions map[int]int) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	for _, po := range pos {
+		if po.XeroPOID == "" { // recorded to reconcile against Xero, so it must have one
+			return fmt.Errorf("xero purchase order id missing")
+		}
+	}
+	var stale []int
+	err := WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		xeroIDs := make([]string, 0, len(pos))
+		for _, po := range pos {
+			if _, err := insertPurchaseOrder(ctx, tx, po); err != nil {
+				return err
+			}
+			xeroIDs = append(xeroIDs, po.XeroPOID)
+		}
+		var err error
+		if stale, err = markShoppingListOrdered(ctx, tx, ownerID, versions); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  AuditPurchaseOrdersCreated,
+			Detail: map[string]any{
+				"xero_po_ids": xeroIDs,
+				"marked":      len(versions) - len(stale),
+				"not_marked":  stale,
+			},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		return &ShoppingConflictError{ListIDs: stale}
+	}
+	return nil
+}
+
+// insertPurchaseOrder stores po and its lines within tx and returns the local id.
+func insertPurchaseOrder(ctx context.Context, tx pgx.Tx, po PurchaseOrderRecord) (int, error) {
+	status := po.Status
+	if status == "" {
+		status = "AUTHORISED"
+	}
+	var id int
+	err := tx.QueryRow(ctx, `
+INSERT INTO purchase_orders (owner_id, tenant_id, xero_po_id, contact_account, contact_id, status, currency_code, currency_rate)
+VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8::numeric, 0))
+RETURNING id
+`, po.OwnerID, po.TenantID, po.XeroPOID, po.ContactAccount, po.ContactID, status, po.CurrencyCode, po.CurrencyRate).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("insert purchase_order: %w", err)
+	}
+	b := &pgx.Batch{}
+	for _, l := range po.Lines {
+		b.Queue(`
+INSERT INTO purchase_order_lines (purchase_order_id, item_id, quantity, unit_amount)
+VALUES ($1, $2, $3, NULLIF($4::numeric, 0))
+`, id, l.ItemID, l.Quantity, l.UnitAmount)
+	}
+	err = execBatch(ctx, tx, b, func(i int) string { return "insert purchase_order_line " + po.Lines[i].ItemID })
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ListPurchaseOrdersSince returns an owner's recorded purchase orders created on/after since
+// (without lines).
+func ListPurchaseOrdersSince(ctx context.Context, dbURL, ownerID string, since time.Time) ([]PurchaseOrderRecord, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT id, owner_id, tenant_id, xero_po_id, contact_account, contact_id, status, xero_deleted_at, created_at
+FROM purchase_orders
+WHERE owner_id = $1 AND created_at >= $2
+ORDER BY created_at
+`, ownerID, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("query purchase_orders: %w", err)
+	}
+	defer rows.Close()
+
+	var out []PurchaseOrderRecord
+	for rows.Next() {
+		var po PurchaseOrderRecord
+		if err := rows.Scan(&po.ID, &po.OwnerID, &po.TenantID, &po.XeroPOID, &po.ContactAccount, &po.ContactID, &po.Status, &po.XeroDeletedAt, &po.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan purchase_order: %w", err)
+		}
+		out = append(out, po)
+	}
+	return out, rows.Err()
+}
+
+// MarkPurchaseOrdersDeletedInXero flags local records whose Xero purchase order no longer exists.
+func MarkPurchaseOrdersDeletedInXero(ctx context.Context, dbURL string, xeroPOIDs []string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	if len(xeroPOIDs) == 0 {
+		return nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Exec(ctx, `
+UPDATE purchase_orders
+SET status = 'DELETED', xero_deleted_at = (extract(epoch from now()))::bigint
+WHERE xero_po_id = ANY($1) AND xero_deleted_at IS NULL
+`, xeroPOIDs)
+	if err != nil {
+		return fmt.Errorf("update purchase_orders: %w", err)
+	}
+	return nil
+}
+
+// GetTrackedItemCodes returns the set of item codes with a live (not archived) supplier
+// mapping (items_contacts) in the owner's workspace.
+func GetTrackedItemCodes(ctx context.Context, dbURL, ownerID string) (map[string]bool, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `SELECT DISTINCT item_id FROM items_contacts WHERE workspace_id = $1 AND archived_at IS NULL`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	defer rows.Close()
+
+	out := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan item id: %w", err)
+		}
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+// PurchaseOrderListSpec pages the purchase order history, newest first by default.
+// q matches the supplier account number or the Xero purchase order id.
+var PurchaseOrderListSpec = ListSpec{
+	PerPage:    25,
+	MaxPerPage: 200,
+	Sorts: map[string]string{
+		"created_at":      "created_at",
+		"contact_account": "contact_account",
+		"status":          "status",
+	},
+	DefaultSort: "-created_at",
+	TieBreak:    "id",
+}
+
+// ListPurchaseOrders returns one page of the owner's recorded purchase orders with
+// their lines.
+func ListPurchaseOrders(ctx context.Context, dbURL, ownerID string, req PageRequest) (Page[PurchaseOrderRecord], error) {
+	req = PurchaseOrderListSpec.Normalize(req)
+	page := newPage[PurchaseOrderRecord](req)
+	if dbURL == "" {
+		return page, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return page, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	const filter = `
+WHERE owner_id = $1
+  AND ($2 = '' OR contact_account ILIKE '%' || $2 || '%' OR xero_po_id ILIKE '%' || $2 || '%')`
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM purchase_orders `+filter, ownerID, req.Query).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("count purchase_orders: %w", err)
+	}
+	rows, err := pool.Query(ctx, `
+SELECT id, owner_id, tenant_id, xero_po_id, contact_account, contact_id, status, currency_code, COALESCE(currency_rate, 0)::float8,
+       xero_deleted_at, COALESCE(created_at, 0)
+FROM purchase_orders
+`+filter+`
+ORDER BY `+PurchaseOrderListSpec.orderBy(req)+`
+LIMIT $3 OFFSET $4
+`, ownerID, req.Query, req.PerPage, req.Offset())
+	if err != nil {
+		return page, fmt.Errorf("query purchase_orders: %w", err)
+	}
+	defer rows.Close()
+
+	byID := map[int]int{} // purchase order id -> index in page.Items
+	for rows.Next() {
+		var po PurchaseOrderRecord
+		if err := rows.Scan(&po.ID, &po.OwnerID, &po.TenantID, &po.XeroPOID, &po.ContactAccount, &po.ContactID, &po.Status, &po.CurrencyCode, &po.CurrencyRate, &po.XeroDeletedAt, &po.CreatedAt); err != nil {
+			return page, fmt.Errorf("scan purchase_order: %w", err)
+		}
+		byID[po.ID] = len(page.Items)
+		page.Items = append(page.Items, po)
+	}
+	if err := rows.Err(); err != nil {
+		return page, fmt.Errorf("query purchase_orders: %w", err)
+	}
+	if len(byID) == 0 {
+		return page, nil
+	}
+
+	ids := make([]int, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	lines, err := pool.Query(ctx, `
+SELECT purchase_order_id, item_id, quantity::float8, COALESCE(unit_amount, 0)::float8
+FROM purchase_order_lines
+WHERE purchase_order_id = ANY($1)
+ORDER BY id
+`, ids)
+	if err != nil {
+		return page, fmt.Errorf("query purchase_order_lines: %w", err)
+	}
+	defer lines.Close()
+	for lines.Next() {
+		var (
+			poID int
+			l    PurchaseOrderLine
+		)
+		if err := lines.Scan(&poID, &l.ItemID, &l.Quantity, &l.UnitAmount); err != nil {
+			return page, fmt.Errorf("scan purchase_order_line: %w", err)
+		}
+		po := &page.Items[byID[poID]]
+		po.Lines = append(po.Lines, l)
+	}
+	return page, lines.Err()
+}
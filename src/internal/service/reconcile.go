@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Discrepancy kinds reported by purchase order reconciliation.
+const (
+	DiscrepancyExternalPO    = "external_po"     // PO in Xero, not created by the app, for items we track
+	DiscrepancyDeletedInXero = "deleted_in_xero" // local record whose Xero PO was deleted
+)
+
+// PODiscrepancy is one reconciliation finding.
+type PODiscrepancy struct {
+	Kind        string   `json:"kind"`
+	XeroPOID    string   `json:"xero_po_id"`
+	PONumber    string   `json:"po_number,omitempty"`
+	Contact     string   `json:"contact,omitempty"`
+	TrackedItem []string `json:"tracked_items,omitempty"`
+	Detail      string   `json:"detail"`
+}
+
+// POReconciliationReport summarises a reconciliation run for one owner.
+type POReconciliationReport struct {
+	OwnerID       string          `json:"owner_id"`
+	Since         int64           `json:"since"`
+	RunAt         int64           `json:"run_at"`
+	XeroCount     int             `json:"xero_count"`
+	LocalCount    int             `json:"local_count"`
+	Discrepancies []PODiscrepancy `json:"discrepancies"`
+}
+
+// ReconcilePurchaseOrders compares Xero purchase orders with local records.
+// remote should cover the same period as local; missing holds local Xero PO ids that
+// are not in remote and were confirmed absent/deleted by a direct lookup.
+func ReconcilePurchaseOrders(local []PurchaseOrderRecord, remote []xero.PurchaseOrder, tracked map[string]bool, missing map[string]bool) []PODiscrepancy {
+	localByID := make(map[string]PurchaseOrderRecord, len(local))
+	for _, po := range local {
+		localByID[po.XeroPOID] = po
+	}
+
+	var out []PODiscrepancy
+	seen := make(map[string]bool, len(remote))
+	for _, rpo := range remote {
+		seen[rpo.PurchaseOrderID] = true
+		lpo, ours := localByID[rpo.PurchaseOrderID]
+		deleted := strings.EqualFold(rpo.Status, "DELETED")
+
+		if !ours {
+			if deleted {
+				continue
+			}
+			var items []string
+			for _, li := range rpo.LineItems {
+				if li.ItemCode != "" && tracked[li.ItemCode] {
+					items = append(items, li.ItemCode)
+				}
+			}
+			if len(items) == 0 {
+				continue
+			}
+			sort.Strings(items)
+			out = append(out, PODiscrepancy{
+				Kind:        DiscrepancyExternalPO,
+				XeroPOID:    rpo.PurchaseOrderID,
+				PONumber:    rpo.PurchaseOrderNumber,
+				Contact:     rpo.Contact.Name,
+				TrackedItem: items,
+				Detail:      fmt.Sprintf("purchase order created outside the app contains %d tracked item(s)", len(items)),
+			})
+			continue
+		}
+
+		if deleted && lpo.XeroDeletedAt == nil {
+			out = append(out, PODiscrepancy{
+				Kind:     DiscrepancyDeletedInXero,
+				XeroPOID: rpo.PurchaseOrderID,
+				PONumber: rpo.PurchaseOrderNumber,
+				Contact:  rpo.Contact.Name,
+				Detail:   "purchase order was deleted in Xero",
+			})
+		}
+	}
+
+	for _, lpo := range local {
+		if seen[lpo.XeroPOID] || lpo.XeroDeletedAt != nil || !missing[lpo.XeroPOID] {
+			continue
+		}
+		out = append(out, PODiscrepancy{
+			Kind:     DiscrepancyDeletedInXero,
+			XeroPOID: lpo.XeroPOID,
+			Contact:  lpo.ContactAccount,
+			Detail:   "purchase order no longer exists in Xero",
+		})
+	}
+	return out
+}
+
+// RunPurchaseOrderReconciliation reconciles one owner's purchase orders created on/after
+// since, flags local records deleted in Xero, and stores the report.
+// creds come from TokenManager for the owner's connection.
+func RunPurchaseOrderReconciliation(ctx context.Context, dbURL string, xc *xero.Client, ownerID string, creds XeroCredentials, since time.Time) (*POReconciliationReport, error) {
+	if xc == nil {
+		xc = &xero.Client{}
+	}
+	remote, err := xc.ListPurchaseOrders(ctx, creds.AccessToken, creds.TenantID, since)
+	if err != nil {
+		return nil, fmt.Errorf("list xero purchase orders: %w", err)
+	}
+	local, err := ListPurchaseOrdersSince(ctx, dbURL, ownerID, since)
+	if err != nil {
+		return nil, err
+	}
+	tracked, err := GetTrackedItemCodes(ctx, dbURL, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// local records not returned by the list: look them up directly before calling them deleted
+	inRemote := make(map[string]bool, len(remote))
+	for _, r := range remote {
+		inRemote[r.PurchaseOrderID] = true
+	}
+	missing := map[string]bool{}
+	for _, lpo := range local {
+		if inRemote[lpo.XeroPOID] || lpo.XeroDeletedAt != nil {
+			continue
+		}
+		po, found, err := xc.GetPurchaseOrder(ctx, creds.AccessToken, creds.TenantID, lpo.XeroPOID)
+		if err != nil {
+			return nil, fmt.Errorf("lookup purchase order %s: %w", lpo.XeroPOID, err)
+		}
+		if !found || strings.EqualFold(po.Status, "DELETED") {
+			missing[lpo.XeroPOID] = true
+		}
+	}
+
+	report := &POReconciliationReport{
+		OwnerID:       ownerID,
+		Since:         since.Unix(),
+		RunAt:         time.Now().Unix(),
+		XeroCount:     len(remote),
+		LocalCount:    len(local),
+		Discrepancies: ReconcilePurchaseOrders(local, remote, tracked, missing),
+	}
+
+	var deleted []string
+	for _, d := range report.Discrepancies {
+		if d.Kind == DiscrepancyDeletedInXero {
+			deleted = append(deleted, d.XeroPOID)
+		}
+	}
+	if err := MarkPurchaseOrdersDeletedInXero(ctx, dbURL, deleted); err != nil {
+		return nil, err
+	}
+	if err := SaveReconciliationReport(ctx, dbURL, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// SaveReconciliationReport persists a report in po_reconciliation_runs.
+func SaveReconciliationReport(ctx context.Context, dbURL string, report *POReconciliationReport) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `INSERT INTO po_reconciliation_runs (owner_id, report) VALUES ($1, $2)`, report.OwnerID, b); err != nil {
+		return fmt.Errorf("insert po_reconciliation_run: %w", err)
+	}
+	return nil
+}
+
+// GetLatestReconciliationReport returns the owner's most recent report (nil if none).
+func GetLatestReconciliationReport(ctx context.Context, dbURL, ownerID string) (*POReconciliationReport, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var b []byte
+	err = pool.QueryRow(ctx, `SELECT report FROM po_reconciliation_runs WHERE owner_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1`, ownerID).Scan(&b)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query po_reconciliation_runs: %w", err)
+	}
+	var report POReconciliationReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, fmt.Errorf("decode report: %w", err)
+	}
+	return &report, nil
+}
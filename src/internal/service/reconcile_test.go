@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestReconcilePurchaseOrders(t *testing.T) {
+	t.Parallel()
+	deletedAt := int64(1)
+	local := []PurchaseOrderRecord{
+		{XeroPOID: "po-ours", Status: "AUTHORISED"},
+		{XeroPOID: "po-deleted-remote", Status: "AUTHORISED"},
+		{XeroPOID: "po-gone", ContactAccount: "S-001", Status: "AUTHORISED"},
+		{XeroPOID: "po-already-flagged", Status: "DELETED", XeroDeletedAt: &deletedAt},
+	}
+	remote := []xero.PurchaseOrder{
+		{PurchaseOrderID: "po-ours", Status: "AUTHORISED"},
+		{PurchaseOrderID: "po-deleted-remote", Status: "DELETED"},
+		{PurchaseOrderID: "po-external", PurchaseOrderNumber: "PO-0099", Status: "AUTHORISED",
+			Contact:   xero.PurchaseContact{Name: "Acme"},
+			LineItems: []xero.PurchaseLine{{ItemCode: "P-2"}, {ItemCode: "UNTRACKED"}, {ItemCode: "P-1"}}},
+		{PurchaseOrderID: "po-external-untracked", Status: "AUTHORISED",
+			LineItems: []xero.PurchaseLine{{ItemCode: "UNTRACKED"}}},
+		{PurchaseOrderID: "po-external-deleted", Status: "DELETED",
+			LineItems: []xero.PurchaseLine{{ItemCode: "P-1"}}},
+	}
+	tracked := map[string]bool{"P-1": true, "P-2": true}
+	missing := map[string]bool{"po-gone": true, "po-already-flagged": true}
+
+	got := ReconcilePurchaseOrders(local, remote, tracked, missing)
+	byID := map[string]PODiscrepancy{}
+	for _, d := range got {
+		byID[d.XeroPOID] = d
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 discrepancies, got %d: %#v", len(got), got)
+	}
+	ext, ok := byID["po-external"]
+	if !ok || ext.Kind != DiscrepancyExternalPO {
+		t.Fatalf("expected external_po for po-external, got %#v", ext)
+	}
+	if len(ext.TrackedItem) != 2 || ext.TrackedItem[0] != "P-1" || ext.TrackedItem[1] != "P-2" {
+		t.Fatalf("unexpected tracked items: %v", ext.TrackedItem)
+	}
+	if d := byID["po-deleted-remote"]; d.Kind != DiscrepancyDeletedInXero {
+		t.Fatalf("expected deleted_in_xero for po-deleted-remote, got %#v", d)
+	}
+	if d := byID["po-gone"]; d.Kind != DiscrepancyDeletedInXero {
+		t.Fatalf("expected deleted_in_xero for po-gone, got %#v", d)
+	}
+}
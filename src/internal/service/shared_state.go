@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SaveViewState stores v (JSON-encoded) for ownerID until ttl has passed and
+// returns its new random id, for a page on any instance to show once.
+func SaveViewState(ctx context.Context, dbURL, ownerID string, v any, ttl time.Duration) (string, error) {
+	if dbURL == "" {
+		return "", fmt.Errorf("db url missing")
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("encode view state: %w", err)
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("view state id: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return "", fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Exec(ctx, `
+INSERT INTO view_states (id, owner_id, payload, expires_at) VALUES ($1, $2, $3, $4)
+`, id, ownerID, payload, time.Now().Add(ttl).Unix())
+	if err != nil {
+		return "", fmt.Errorf("insert view_state: %w", err)
+	}
+	return id, nil
+}
+
+// TakeViewState decodes the owner's view state id into dst and deletes it. It
+// reports false when there is none (expired, already taken or another owner's).
+func TakeViewState(ctx context.Context, dbURL, ownerID, id string, dst any) (bool, error) {
+	if dbURL == "" {
+		return false, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var payload []byte
+	err = pool.QueryRow(ctx, `
+DELETE FROM view_states WHERE id = $1 AND owner_id = $2 AND expires_at > $3
+RETURNING payload
+`, id, ownerID, time.Now().Unix()).Scan(&payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("take view_state: %w", err)
+	}
+	if err := json.Unmarshal(payload, dst); err != nil {
+		return false, fmt.Errorf("decode view state: %w", err)
+	}
+	return true, nil
+}
+
+// SharedSecret returns the secret stored under name, generating it (32 random bytes,
+// hex) on first use. Every instance gets the same value.
+func SharedSecret(ctx context.Context, dbURL, name string) (string, error) {
+	if dbURL == "" {
+		return "", fmt.Errorf("db url missing")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate secret: %w", err)
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return "", fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	// the first instance to get here wins; the others read its value
+	if _, err := pool.Exec(ctx, `
+INSERT INTO app_secrets (name, value) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING
+`, name, hex.EncodeToString(b)); err != nil {
+		return "", fmt.Errorf("insert app_secret: %w", err)
+	}
+	var secret string
+	if err := pool.QueryRow(ctx, `SELECT value FROM app_secrets WHERE name = $1`, name).Scan(&secret); err != nil {
+		return "", fmt.Errorf("load app_secret: %w", err)
+	}
+	return secret, nil
+}
+
+// ClaimJobRun reports whether instanceID may do the current run of job name: true
+// unless another claim was made less than minGap ago. Of several instances on the
+// same schedule, one gets the claim and the others skip the run.
+func ClaimJobRun(ctx context.Context, dbURL, name, instanceID string, minGap time.Duration) (bool, error) {
+	if dbURL == "" {
+		return false, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	now := time.Now()
+	var by string
+	err = pool.QueryRow(ctx, `
+INSERT INTO job_runs (name, claimed_at, claimed_by) VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE SET claimed_at = EXCLUDED.claimed_at, claimed_by = EXCLUDED.claimed_by
+WHERE job_runs.claimed_at <= $4
+RETURNING claimed_by
+`, name, now.Unix(), instanceID, now.Add(-minGap).Unix()).Scan(&by)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("claim job run: %w", err)
+	}
+	return true, nil
+}
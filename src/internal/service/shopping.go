@@ -3,26 +3,34 @@ package service
 import (
 	"context"
 	"fmt"
+	"slices"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ShoppingRow is a row from shopping_list.
 type ShoppingRow struct {
-	ListID   int
-	ItemID   string
-	Quantity int
+	ListID        int
+	ItemID        string
+	Quantity      float64
+	SourceInvoice string // invoice the row was added from, "" when added manually
+	Version       int    // bumped on every update; see ShoppingConflictError
 }
 
 // ContactItem represents an item assigned to a contact; ListIDs tracks source rows.
 type ContactItem struct {
 	ItemID   string
-	Quantity int
+	Quantity float64
 	ListIDs  []int
+	Terms    SupplierTerms
+	// Suppliers are all the item's suppliers, best first, when it has several
+	Suppliers []ItemSupplier
 }
 
-// GetUnorderedShoppingRows returns all shopping_list rows where ordered = false.
-func GetUnorderedShoppingRows(ctx context.Context, dbURL string) ([]ShoppingRow, error) {
+// GetUnorderedShoppingRows returns the owner's shopping_list rows where ordered = false,
+// leaving out archived rows.
+func GetUnorderedShoppingRows(ctx context.Context, dbURL, ownerID string) ([]ShoppingRow, error) {
 	if dbURL == "" {
 		return nil, fmt.Errorf("db url missing")
 	}
@@ -32,7 +40,11 @@ func GetUnorderedShoppingRows(ctx context.Context, dbURL string) ([]ShoppingRow,
 	}
 	defer pool.Close()
 
-	rows, err := pool.Query(ctx, `SELECT list_id, item_id, quantity FROM shopping_list WHERE ordered = FALSE`)
+	rows, err := pool.Query(ctx, `
+SELECT list_id, item_id, quantity, COALESCE(source_invoice, ''), version
+FROM shopping_list
+WHERE ordered = FALSE AND owner_id = $1 AND archived_at IS NULL
+`, ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("query shopping_list: %w", err)
 	}
@@ -41,7 +53,7 @@ func GetUnorderedShoppingRows(ctx context.Context, dbURL string) ([]ShoppingRow,
 	var out []ShoppingRow
 	for rows.Next() {
 		var r ShoppingRow
-		if err := rows.Scan(&r.ListID, &r.ItemID, &r.Quantity); err != nil {
+		if err := rows.Scan(&r.ListID, &r.ItemID, &r.Quantity, &r.SourceInvoice, &r.Version); err != nil {
 			return nil, fmt.Errorf("scan shopping row: %w", err)
 		}
 		out = append(out, r)
@@ -49,76 +61,115 @@ func GetUnorderedShoppingRows(ctx context.Context, dbURL string) ([]ShoppingRow,
 	return out, nil
 }
 
-// GroupShoppingItemsByContact assigns each shopping row to a contact (AccountNumber) and aggregates duplicates.
-// If an item has no contact mapping -> error.
-func GroupShoppingItemsByContact(ctx context.Context, dbURL string, rows []ShoppingRow) (map[string][]ContactItem, error) {
-	if dbURL == "" {
-		return nil, fmt.Errorf("db url missing")
-	}
-	if len(rows) == 0 {
+// markShoppingListOrdered sets ordered = true, and ordered_at unless already set, for
+// the owner's rows in versions (list id -> version read) within tx. Rows changed
+// since they were read are left alone and their ids returned; the others are still
+// marked, as their purchase orders exist.
+func markShoppingListOrdered(ctx context.Context, tx pgx.Tx, ownerID string, versions map[int]int) ([]int, error) {
+	if len(versions) == 0 {
 		return nil, nil
 	}
-	pool, err := pgxpool.New(ctx, dbURL)
+	ids := make([]int, 0, len(versions))
+	for id := range versions {
+		ids = append(ids, id)
+	}
+	current, err := lockShoppingVersions(ctx, tx, ownerID, ids)
 	if err != nil {
-		return nil, fmt.Errorf("connect db: %w", err)
+		return nil, err
 	}
-	defer pool.Close()
-
-	// map contact_account_number -> (item -> ContactItem)
-	groupMap := map[string]map[string]*ContactItem{}
-
-	for _, r := range rows {
-		var contactID string // Xero Contacts.AccountNumber
-		err := pool.QueryRow(ctx, `SELECT contact_id FROM items_contacts WHERE item_id = $1 LIMIT 1`, r.ItemID).Scan(&contactID)
-		if err != nil {
-			return nil, fmt.Errorf("no contact mapping found for item %s", r.ItemID)
-		}
-		if _, ok := groupMap[contactID]; !ok {
-			groupMap[contactID] = map[string]*ContactItem{}
-		}
-		if existing, ok := groupMap[contactID][r.ItemID]; ok {
-			existing.Quantity += r.Quantity
-			existing.ListIDs = append(existing.ListIDs, r.ListID)
-		} else {
-			groupMap[contactID][r.ItemID] = &ContactItem{
-				ItemID:   r.ItemID,
-				Quantity: r.Quantity,
-				ListIDs:  []int{r.ListID},
-			}
+	stale := staleShoppingRows(versions, current)
+	fresh := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(stale, id) {
+			fresh = append(fresh, id)
 		}
 	}
 
-	// convert to desired output shape
-	out := map[string][]ContactItem{}
-	for contact, m := range groupMap {
-		for _, v := range m {
-			out[contact] = append(out[contact], *v)
-		}
+	_, err = tx.Exec(ctx, `
+UPDATE shopping_list
+SET ordered = TRUE, updated_at = (extract(epoch from now()))::bigint,
+    ordered_at = COALESCE(ordered_at, (extract(epoch from now()))::bigint)
+WHERE list_id = ANY($1) AND owner_id = $2
+`, fresh, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("update shopping_list: %w", err)
 	}
-	return out, nil
+	return stale, nil
+}
+
+// ShoppingListEntry is a shopping_list row as listed by ListShoppingList.
+type ShoppingListEntry struct {
+	ListID        int     `json:"list_id"`
+	ItemID        string  `json:"item_id"`
+	Quantity      float64 `json:"quantity"`
+	Ordered       bool    `json:"ordered"`
+	Received      bool    `json:"received"`
+	NeededBy      *int64  `json:"needed_by,omitempty"` // epoch seconds
+	SourceInvoice string  `json:"source_invoice,omitempty"`
+	CreatedAt     int64   `json:"created_at"`
+	ArchivedAt    *int64  `json:"archived_at,omitempty"` // nil unless archived
+	Version       int     `json:"version"`               // send back with bulk updates
 }
 
-// MarkShoppingListOrdered sets ordered = true for the given list IDs and updates updated_at.
-func MarkShoppingListOrdered(ctx context.Context, dbURL string, ids []int) error {
+// ShoppingListSpec pages the shopping list, newest first by default. q matches the
+// item code or the source invoice.
+var ShoppingListSpec = ListSpec{
+	PerPage:    50,
+	MaxPerPage: 500,
+	Sorts: map[string]string{
+		"list_id":   "list_id",
+		"item_id":   "item_id",
+		"quantity":  "quantity",
+		"needed_by": "needed_by",
+	},
+	DefaultSort: "-list_id",
+	TieBreak:    "list_id",
+}
+
+// shoppingListFilterSQL is shared by the count and page queries. Params: $1 owner,
+// $2 include archived, $3 query.
+const shoppingListFilterSQL = `
+WHERE owner_id = $1 AND ($2 OR archived_at IS NULL)
+  AND ($3 = '' OR item_id ILIKE '%' || $3 || '%' OR source_invoice ILIKE '%' || $3 || '%')`
+
+// ListShoppingList returns one page of the owner's shopping_list rows. Archived rows
+// are left out unless includeArchived is set.
+func ListShoppingList(ctx context.Context, dbURL, ownerID string, includeArchived bool, req PageRequest) (Page[ShoppingListEntry], error) {
+	req = ShoppingListSpec.Normalize(req)
+	page := newPage[ShoppingListEntry](req)
 	if dbURL == "" {
-		return fmt.Errorf("db url missing")
-	}
-	if len(ids) == 0 {
-		return nil
+		return page, fmt.Errorf("db url missing")
 	}
 	pool, err := pgxpool.New(ctx, dbURL)
 	if err != nil {
-		return fmt.Errorf("connect db: %w", err)
+		return page, fmt.Errorf("connect db: %w", err)
 	}
 	defer pool.Close()
 
-	_, err = pool.Exec(ctx, `
-UPDATE shopping_list
-SET ordered = TRUE, updated_at = (extract(epoch from now()))::bigint
-WHERE list_id = ANY($1)
-`, ids)
+	args := []any{ownerID, includeArchived, req.Query}
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM shopping_list `+shoppingListFilterSQL, args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("count shopping_list: %w", err)
+	}
+	rows, err := pool.Query(ctx, `
+SELECT list_id, item_id, quantity, ordered, received, needed_by,
+       COALESCE(source_invoice, ''), COALESCE(created_at, 0), archived_at, version
+FROM shopping_list
+`+shoppingListFilterSQL+`
+ORDER BY `+ShoppingListSpec.orderBy(req)+`
+LIMIT $4 OFFSET $5
+`, append(args, req.PerPage, req.Offset())...)
 	if err != nil {
-		return fmt.Errorf("update shopping_list: %w", err)
+		return page, fmt.Errorf("query shopping_list: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e ShoppingListEntry
+		if err := rows.Scan(&e.ListID, &e.ItemID, &e.Quantity, &e.Ordered, &e.Received, &e.NeededBy,
+			&e.SourceInvoice, &e.CreatedAt, &e.ArchivedAt, &e.Version); err != nil {
+			return page, fmt.Errorf("scan shopping row: %w", err)
+		}
+		page.Items = append(page.Items, e)
 	}
-	return nil
+	return page, rows.Err()
 }
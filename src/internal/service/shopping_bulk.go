@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Bulk shopping list actions.
+const (
+	ShoppingBulkSetQuantity   = "set_quantity"
+	ShoppingBulkSetNeededBy   = "set_needed_by"
+	ShoppingBulkDelete        = "delete"
+	ShoppingBulkMarkUnordered = "mark_unordered"
+	ShoppingBulkMarkReceived  = "mark_received"
+	ShoppingBulkArchive       = "archive" // hide without deleting (see PurgeExpiredRows)
+	ShoppingBulkRestore       = "restore" // undo archive
+)
+
+// ShoppingBulkOp is one action applied to a set of shopping_list rows.
+type ShoppingBulkOp struct {
+	Action   string  `json:"action"`
+	ListIDs  []int   `json:"list_ids"`
+	Quantity float64 `json:"quantity,omitempty"`  // set_quantity only; kept to 4 decimal places
+	NeededBy *int64  `json:"needed_by,omitempty"` // set_needed_by only; epoch seconds, nil clears
+	// Versions are the row versions the caller read (list id -> version); when given
+	// the batch fails with a *ShoppingConflictError if any row has changed since
+	Versions map[int]int `json:"versions,omitempty"`
+}
+
+// ShoppingBulkResult reports rows affected per op (same order as the input).
+type ShoppingBulkResult struct {
+	Action   string `json:"action"`
+	Affected int64  `json:"affected"`
+}
+
+// validateShoppingBulkOps checks ops before any DB work is done.
+func validateShoppingBulkOps(ops []ShoppingBulkOp) error {
+	if len(ops) == 0 {
+		return invalid("operations", "no operations")
+	}
+	for i, op := range ops {
+		if len(op.ListIDs) == 0 {
+			return invalid("operations", "operation %d (%s): no list ids", i, op.Action)
+		}
+		switch op.Action {
+		case ShoppingBulkSetQuantity:
+			if roundQty(op.Quantity) <= 0 {
+				return invalid("operations", "operation %d (%s): quantity must be positive", i, op.Action)
+			}
+		case ShoppingBulkSetNeededBy, ShoppingBulkDelete, ShoppingBulkMarkUnordered, ShoppingBulkMarkReceived,
+			ShoppingBulkArchive, ShoppingBulkRestore:
+		default:
+			return invalid("operations", "operation %d: unknown action %q", i, op.Action)
+		}
+		for id := range op.Versions {
+			if !slices.Contains(op.ListIDs, id) {
+				return invalid("operations", "operation %d (%s): version given for list id %d not in list_ids", i, op.Action, id)
+			}
+		}
+	}
+	_, err := expectedShoppingVersions(ops)
+	return err
+}
+
+// expectedShoppingVersions merges the versions of all ops; they are checked once,
+// before the first op bumps them.
+func expectedShoppingVersions(ops []ShoppingBulkOp) (map[int]int, error) {
+	out := map[int]int{}
+	for _, op := range ops {
+		for id, v := range op.Versions {
+			if prev, ok := out[id]; ok && prev != v {
+				return nil, invalid("operations", "list id %d given versions %d and %d", id, prev, v)
+			}
+			out[id] = v
+		}
+	}
+	return out, nil
+}
+
+// BulkUpdateShoppingList applies all ops to the owner's rows in a single transaction.
+// Every list id must exist and belong to the owner, and apart from delete and restore
+// not be archived: if any op touches fewer rows than requested the whole batch is
+// rolled back. So is it when a row given a version has changed since (a
+// *ShoppingConflictError).
+func BulkUpdateShoppingList(ctx context.Context, dbURL, ownerID string, ops []ShoppingBulkOp) ([]ShoppingBulkResult, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	if err := validateShoppingBulkOps(ops); err != nil {
+		return nil, err
+	}
+	out := make([]ShoppingBulkResult, 0, len(ops))
+	err := WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		if expected, _ := expectedShoppingVersions(ops); len(expected) > 0 {
+			ids := make([]int, 0, len(expected))
+			for id := range expected {
+				ids = append(ids, id)
+			}
+			current, err := lockShoppingVersions(ctx, tx, ownerID, ids)
+			if err != nil {
+				return err
+			}
+			if stale := staleShoppingRows(expected, current); len(stale) > 0 {
+				return &ShoppingConflictError{ListIDs: stale}
+			}
+		}
+
+		for i, op := range ops {
+			var sql string
+			var args []any
+			switch op.Action {
+			case ShoppingBulkSetQuantity:
+				sql = `UPDATE shopping_list SET quantity = $2 WHERE list_id = ANY($1) AND owner_id = $3 AND archived_at IS NULL`
+				args = []any{op.ListIDs, roundQty(op.Quantity), ownerID}
+			case ShoppingBulkSetNeededBy:
+				sql = `UPDATE shopping_list SET needed_by = $2 WHERE list_id = ANY($1) AND owner_id = $3 AND archived_at IS NULL`
+				args = []any{op.ListIDs, op.NeededBy, ownerID}
+			case ShoppingBulkDelete:
+				sql = `DELETE FROM shopping_list WHERE list_id = ANY($1) AND owner_id = $2`
+				args = []any{op.ListIDs, ownerID}
+			case ShoppingBulkMarkUnordered:
+				sql = `UPDATE shopping_list SET ordered = FALSE, received = FALSE, ordered_at = NULL, received_at = NULL
+WHERE list_id = ANY($1) AND owner_id = $2 AND archived_at IS NULL`
+				args = []any{op.ListIDs, ownerID}
+			case ShoppingBulkMarkReceived:
+				// rows received without going through a purchase order have no ordered_at
+				sql = `UPDATE shopping_list SET ordered = TRUE, received = TRUE,
+  received_at = COALESCE(received_at, (extract(epoch from now()))::bigint)
+WHERE list_id = ANY($1) AND owner_id = $2 AND archived_at IS NULL`
+				args = []any{op.ListIDs, ownerID}
+			case ShoppingBulkArchive:
+				sql = `UPDATE shopping_list SET archived_at = (extract(epoch from now()))::bigint
+WHERE list_id = ANY($1) AND owner_id = $2 AND archived_at IS NULL`
+				args = []any{op.ListIDs, ownerID}
+			case ShoppingBulkRestore:
+				sql = `UPDATE shopping_list SET archived_at = NULL WHERE list_id = ANY($1) AND owner_id = $2 AND archived_at IS NOT NULL`
+				args = []any{op.ListIDs, ownerID}
+			}
+			tag, err := tx.Exec(ctx, sql, args...)
+			if err != nil {
+				return fmt.Errorf("operation %d (%s): %w", i, op.Action, err)
+			}
+			if tag.RowsAffected() != int64(len(uniqueInts(op.ListIDs))) {
+				return fmt.Errorf("operation %d (%s): %d of %d rows found", i, op.Action, tag.RowsAffected(), len(uniqueInts(op.ListIDs)))
+			}
+			out = append(out, ShoppingBulkResult{Action: op.Action, Affected: tag.RowsAffected()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// uniqueInts returns ids with duplicates removed (order preserved).
+func uniqueInts(ids []int) []int {
+	seen := make(map[int]struct{}, len(ids))
+	out := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
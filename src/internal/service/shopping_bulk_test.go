@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateShoppingBulkOps(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		ops     []ShoppingBulkOp
+		wantErr string
+	}{
+		{"empty", nil, "no operations"},
+		{"no ids", []ShoppingBulkOp{{Action: ShoppingBulkDelete}}, "no list ids"},
+		{"bad qty", []ShoppingBulkOp{{Action: ShoppingBulkSetQuantity, ListIDs: []int{1}}}, "quantity must be positive"},
+		{"unknown", []ShoppingBulkOp{{Action: "explode", ListIDs: []int{1}}}, "unknown action"},
+		{"version for other row", []ShoppingBulkOp{{Action: ShoppingBulkDelete, ListIDs: []int{1}, Versions: map[int]int{2: 1}}}, "version given for list id 2"},
+		{"versions disagree", []ShoppingBulkOp{
+			{Action: ShoppingBulkSetQuantity, ListIDs: []int{1}, Quantity: 2, Versions: map[int]int{1: 3}},
+			{Action: ShoppingBulkArchive, ListIDs: []int{1}, Versions: map[int]int{1: 4}},
+		}, "list id 1 given versions 3 and 4"},
+		{"ok", []ShoppingBulkOp{
+			{Action: ShoppingBulkSetQuantity, ListIDs: []int{1, 2}, Quantity: 3, Versions: map[int]int{1: 3}},
+			{Action: ShoppingBulkSetNeededBy, ListIDs: []int{1}, Versions: map[int]int{1: 3}},
+			{Action: ShoppingBulkMarkUnordered, ListIDs: []int{2}},
+			{Action: ShoppingBulkMarkReceived, ListIDs: []int{1}},
+			{Action: ShoppingBulkDelete, ListIDs: []int{3}},
+			{Action: ShoppingBulkArchive, ListIDs: []int{4}},
+			{Action: ShoppingBulkRestore, ListIDs: []int{4}},
+		}, ""},
+	}
+	for _, tc := range cases {
+		err := validateShoppingBulkOps(tc.ops)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestBulkUpdateShoppingList_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	_, err := BulkUpdateShoppingList(context.Background(), "", "owner-1", []ShoppingBulkOp{{Action: ShoppingBulkDelete, ListIDs: []int{1}}})
+	if err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
+
+func TestUniqueInts(t *testing.T) {
+	t.Parallel()
+	got := uniqueInts([]int{3, 1, 3, 2, 1})
+	if len(got) != 3 || got[0] != 3 || got[1] != 1 || got[2] != 2 {
+		t.Fatalf("unexpected result: %v", got)
+	}
+}
+
+func TestStaleShoppingRows(t *testing.T) {
+	t.Parallel()
+	got := staleShoppingRows(map[int]int{1: 2, 2: 5, 3: 1, 4: 7}, map[int]int{1: 2, 2: 6, 4: 7})
+	if !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Fatalf("stale = %v, want [2 3]", got)
+	}
+	err := error(&ShoppingConflictError{ListIDs: got})
+	if !errors.Is(err, ErrShoppingConflict) || !strings.Contains(err.Error(), "row(s) 2, 3 changed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := ShoppingVersions([]ShoppingRow{{ListID: 1, Version: 4}, {ListID: 9, Version: 1}}); !reflect.DeepEqual(v, map[int]int{1: 4, 9: 1}) {
+		t.Fatalf("versions = %v", v)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/uom"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrSupplierChoice is returned by GroupShoppingItemsByContact when a chosen supplier
+// is not one the item is bought from (any more).
+var ErrSupplierChoice = errors.New("chosen supplier not found")
+
+// ItemSupplier is a supplier an item can be ordered from and its terms (items_contacts).
+type ItemSupplier struct {
+	ContactID string // Xero Contacts.AccountNumber
+	Preferred bool
+	Terms     SupplierTerms
+}
+
+// GroupShoppingItemsByContact assigns each shopping row to a contact (AccountNumber) and aggregates duplicates.
+// If an item has no contact mapping -> error. Mappings are the owner's. An item with
+// several suppliers goes to choices[item] when set, else its preferred supplier, else
+// the one with the lowest agreed price (then the lowest contact id).
+func GroupShoppingItemsByContact(ctx context.Context, dbURL, ownerID string, rows []ShoppingRow, choices map[string]string) (map[string][]ContactItem, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	itemIDs := make([]string, 0, len(rows))
+	for _, r := range rows {
+		itemIDs = append(itemIDs, r.ItemID)
+	}
+	// every live mapping, best first per item
+	q, err := pool.Query(ctx, `
+SELECT ic.item_id, ic.contact_id, ic.preferred, ic.lead_time_days,
+       COALESCE(ic.minimum_order_qty, 0)::float8, COALESCE(ic.pack_size, 0)::float8,
+       COALESCE(ic.unit_price, 0)::float8, COALESCE(ic.uom, p.uom, '')
+FROM items_contacts ic
+LEFT JOIN parts p ON p.part_id = ic.item_id
+WHERE ic.workspace_id = $1 AND ic.item_id = ANY($2) AND ic.archived_at IS NULL
+ORDER BY ic.item_id, ic.preferred DESC, ic.unit_price ASC NULLS LAST, ic.contact_id
+`, ownerID, uniqueStrings(itemIDs))
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	defer q.Close()
+
+	suppliers := map[string][]ItemSupplier{}
+	for q.Next() {
+		var itemID string
+		var s ItemSupplier
+		var leadTime *int
+		if err := q.Scan(&itemID, &s.ContactID, &s.Preferred, &leadTime, &s.Terms.MinimumOrderQty, &s.Terms.PackSize, &s.Terms.UnitPrice, &s.Terms.UOM); err != nil {
+			return nil, fmt.Errorf("scan items_contacts: %w", err)
+		}
+		s.Terms.UOM = uom.Normalize(s.Terms.UOM)
+		if leadTime != nil {
+			s.Terms.LeadTimeDays, s.Terms.HasLeadTime = *leadTime, true
+		}
+		suppliers[itemID] = append(suppliers[itemID], s)
+	}
+	if err := q.Err(); err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	return groupByContact(rows, suppliers, choices)
+}
+
+// groupByContact groups rows by their item's supplier, adding up rows for the same
+// item. suppliers lists each item's suppliers best first; the first is used unless
+// choices names another. Items keep the order they first appear in rows. An item
+// without a supplier, or whose choice is not one of them, is an error.
+func groupByContact(rows []ShoppingRow, suppliers map[string][]ItemSupplier, choices map[string]string) (map[string][]ContactItem, error) {
+	out := map[string][]ContactItem{}
+	pos := map[string]int{} // item id -> index in its contact's items
+	chosen := map[string]ItemSupplier{}
+	for _, r := range rows {
+		s, ok := chosen[r.ItemID]
+		if !ok {
+			all := suppliers[r.ItemID]
+			if len(all) == 0 {
+				return nil, fmt.Errorf("no contact mapping found for item %s", r.ItemID)
+			}
+			s = all[0]
+			if c, set := choices[r.ItemID]; set && c != s.ContactID {
+				i := slices.IndexFunc(all, func(s ItemSupplier) bool { return s.ContactID == c })
+				if i < 0 {
+					return nil, fmt.Errorf("%w: %s is not bought from %s", ErrSupplierChoice, r.ItemID, c)
+				}
+				s = all[i]
+			}
+			chosen[r.ItemID] = s
+		}
+		i, ok := pos[r.ItemID]
+		if !ok {
+			i = len(out[s.ContactID])
+			pos[r.ItemID] = i
+			it := ContactItem{ItemID: r.ItemID, Terms: s.Terms}
+			if all := suppliers[r.ItemID]; len(all) > 1 {
+				it.Suppliers = all
+			}
+			out[s.ContactID] = append(out[s.ContactID], it)
+		}
+		ci := &out[s.ContactID][i]
+		ci.Quantity = roundQty(ci.Quantity + r.Quantity)
+		ci.ListIDs = append(ci.ListIDs, r.ListID)
+	}
+	return out, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupByContact(t *testing.T) {
+	t.Parallel()
+	nutTerms := SupplierTerms{PackSize: 100, LeadTimeDays: 3, HasLeadTime: true}
+	suppliers := map[string][]ItemSupplier{
+		"BOLT": {{ContactID: "SUP-1"}},
+		"NUT":  {{ContactID: "SUP-1", Terms: nutTerms}},
+		"GLUE": {{ContactID: "SUP-2"}},
+	}
+	rows := []ShoppingRow{
+		{ListID: 1, ItemID: "NUT", Quantity: 10},
+		{ListID: 2, ItemID: "GLUE", Quantity: 1},
+		{ListID: 3, ItemID: "BOLT", Quantity: 4},
+		{ListID: 4, ItemID: "NUT", Quantity: 5},
+	}
+	got, err := groupByContact(rows, suppliers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]ContactItem{
+		"SUP-1": {
+			{ItemID: "NUT", Quantity: 15, ListIDs: []int{1, 4}, Terms: nutTerms},
+			{ItemID: "BOLT", Quantity: 4, ListIDs: []int{3}},
+		},
+		"SUP-2": {{ItemID: "GLUE", Quantity: 1, ListIDs: []int{2}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	rows = append(rows, ShoppingRow{ListID: 5, ItemID: "WIDGET", Quantity: 1})
+	if _, err := groupByContact(rows, suppliers, nil); err == nil || !strings.Contains(err.Error(), "no contact mapping found for item WIDGET") {
+		t.Fatalf("err = %v, want missing mapping for WIDGET", err)
+	}
+}
+
+func TestGroupByContact_Choices(t *testing.T) {
+	t.Parallel()
+	cheap := SupplierTerms{UnitPrice: 0.1}
+	dear := SupplierTerms{UnitPrice: 0.2, PackSize: 10}
+	suppliers := map[string][]ItemSupplier{
+		"BOLT": {{ContactID: "SUP-2", Preferred: true, Terms: dear}, {ContactID: "SUP-1", Terms: cheap}},
+		"NUT":  {{ContactID: "SUP-1"}},
+	}
+	rows := []ShoppingRow{
+		{ListID: 1, ItemID: "BOLT", Quantity: 4},
+		{ListID: 2, ItemID: "NUT", Quantity: 8},
+		{ListID: 3, ItemID: "BOLT", Quantity: 1},
+	}
+
+	// the best supplier by default, with every supplier kept for comparison
+	got, err := groupByContact(rows, suppliers, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]ContactItem{
+		"SUP-1": {{ItemID: "NUT", Quantity: 8, ListIDs: []int{2}}},
+		"SUP-2": {{ItemID: "BOLT", Quantity: 5, ListIDs: []int{1, 3}, Terms: dear, Suppliers: suppliers["BOLT"]}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("default: got %+v, want %+v", got, want)
+	}
+
+	got, err = groupByContact(rows, suppliers, map[string]string{"BOLT": "SUP-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]ContactItem{
+		"SUP-1": {
+			{ItemID: "BOLT", Quantity: 5, ListIDs: []int{1, 3}, Terms: cheap, Suppliers: suppliers["BOLT"]},
+			{ItemID: "NUT", Quantity: 8, ListIDs: []int{2}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("chosen: got %+v, want %+v", got, want)
+	}
+
+	if _, err := groupByContact(rows, suppliers, map[string]string{"NUT": "SUP-9"}); !errors.Is(err, ErrSupplierChoice) {
+		t.Fatalf("err = %v, want ErrSupplierChoice", err)
+	}
+}
@@ -77,11 +77,15 @@ func setupTestPostgresShopping(t *testing.T) (dbURL string, cleanup func()) {
 	defer cancel()
 	_, err = db.Exec(ctx, `
 CREATE TABLE IF NOT EXISTS items_contacts (
+  workspace_id TEXT NOT NULL DEFAULT 'owner-1',
   item_id TEXT NOT NULL,
   contact_id TEXT NOT NULL,
+  lead_time_days INTEGER,
+  minimum_order_qty INTEGER,
+  pack_size INTEGER,
   created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
   updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
-  PRIMARY KEY (item_id, contact_id)
+  PRIMARY KEY (workspace_id, item_id, contact_id)
 );
 `)
 	if err != nil {
@@ -114,10 +118,10 @@ func TestGroupShoppingItemsByContact_BasicAggregation(t *testing.T) {
 	defer pool.Close()
 
 	_, err = pool.Exec(ctx, `
-INSERT INTO items_contacts (item_id, contact_id) VALUES
-  ('P-001', 'C-AAA'),
-  ('P-002', 'C-AAA'),
-  ('P-003', 'C-BBB')
+INSERT INTO items_contacts (item_id, contact_id, lead_time_days, minimum_order_qty, pack_size) VALUES
+  ('P-001', 'C-AAA', 5, 10, 4),
+  ('P-002', 'C-AAA', NULL, NULL, NULL),
+  ('P-003', 'C-BBB', 0, NULL, NULL)
 ON CONFLICT DO NOTHING;
 `)
 	if err != nil {
@@ -132,7 +136,7 @@ ON CONFLICT DO NOTHING;
 		{ListID: 4, ItemID: "P-003", Quantity: 1},
 	}
 
-	grouped, err := GroupShoppingItemsByContact(context.Background(), dbURL, rows)
+	grouped, err := GroupShoppingItemsByContact(context.Background(), dbURL, "owner-1", rows, nil)
 	if err != nil {
 		t.Fatalf("GroupShoppingItemsByContact returned error: %v", err)
 	}
@@ -159,21 +163,27 @@ ON CONFLICT DO NOTHING;
 		t.Fatalf("expected P-001 in C-AAA group")
 	} else {
 		if ci.Quantity != 5 {
-			t.Fatalf("expected P-001 aggregated qty 5, got %d", ci.Quantity)
+			t.Fatalf("expected P-001 aggregated qty 5, got %g", ci.Quantity)
 		}
 		if len(ci.ListIDs) != 2 || ci.ListIDs[0] != 1 || ci.ListIDs[1] != 2 {
 			t.Fatalf("unexpected P-001 ListIDs: %v", ci.ListIDs)
 		}
+		if want := (SupplierTerms{LeadTimeDays: 5, HasLeadTime: true, MinimumOrderQty: 10, PackSize: 4}); ci.Terms != want {
+			t.Fatalf("unexpected P-001 terms: %+v", ci.Terms)
+		}
 	}
 	if ci, ok := mAAA["P-002"]; !ok {
 		t.Fatalf("expected P-002 in C-AAA group")
 	} else {
 		if ci.Quantity != 4 {
-			t.Fatalf("expected P-002 qty 4, got %d", ci.Quantity)
+			t.Fatalf("expected P-002 qty 4, got %g", ci.Quantity)
 		}
 		if len(ci.ListIDs) != 1 || ci.ListIDs[0] != 3 {
 			t.Fatalf("unexpected P-002 ListIDs: %v", ci.ListIDs)
 		}
+		if ci.Terms != (SupplierTerms{}) {
+			t.Fatalf("expected no terms for P-002, got %+v", ci.Terms)
+		}
 	}
 
 	// Validate C-BBB group
@@ -184,7 +194,7 @@ ON CONFLICT DO NOTHING;
 	if len(bbb) != 1 {
 		t.Fatalf("expected 1 item for C-BBB, got %d", len(bbb))
 	}
-	if bbb[0].ItemID != "P-003" || bbb[0].Quantity != 1 || len(bbb[0].ListIDs) != 1 || bbb[0].ListIDs[0] != 4 {
+	if bbb[0].ItemID != "P-003" || bbb[0].Quantity != 1 || len(bbb[0].ListIDs) != 1 || bbb[0].ListIDs[0] != 4 || !bbb[0].Terms.HasLeadTime {
 		t.Fatalf("unexpected C-BBB item: %#v", bbb[0])
 	}
 }
@@ -220,7 +230,7 @@ ON CONFLICT DO NOTHING;
 		{ListID: 11, ItemID: "P-999", Quantity: 2}, // no mapping
 	}
 
-	_, err = GroupShoppingItemsByContact(context.Background(), dbURL, rows)
+	_, err = GroupShoppingItemsByContact(context.Background(), dbURL, "owner-1", rows, nil)
 	if err == nil {
 		t.Fatalf("expected error due to missing mapping for P-999, got nil")
 	}
@@ -230,6 +240,70 @@ ON CONFLICT DO NOTHING;
 	}
 }
 
+func TestListShoppingList_PagesSortsAndFilters(t *testing.T) {
+	t.Parallel()
+
+	dbURL, cleanup := setupTestPostgresShopping(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("connect db: %v", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Exec(ctx, `
+CREATE TABLE shopping_list (
+  list_id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  item_id TEXT NOT NULL,
+  quantity NUMERIC(14, 4) NOT NULL,
+  ordered BOOLEAN NOT NULL DEFAULT false,
+  received BOOLEAN NOT NULL DEFAULT false,
+  needed_by BIGINT,
+  source_invoice TEXT,
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  archived_at BIGINT,
+  version INTEGER NOT NULL DEFAULT 1
+);
+INSERT INTO shopping_list (owner_id, item_id, quantity, source_invoice, archived_at) VALUES
+  ('owner-1', 'P-003', 3, 'INV-1', NULL),
+  ('owner-1', 'P-001', 1, 'INV-1', NULL),
+  ('owner-1', 'P-002', 2, 'INV-2', NULL),
+  ('owner-1', 'P-004', 4, 'INV-2', 1700000000),
+  ('owner-2', 'P-001', 9, 'INV-9', NULL);
+`)
+	if err != nil {
+		t.Fatalf("create shopping_list: %v", err)
+	}
+
+	page, err := ListShoppingList(ctx, dbURL, "owner-1", false, PageRequest{PerPage: 2})
+	if err != nil {
+		t.Fatalf("ListShoppingList: %v", err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0].ItemID != "P-002" || page.Sort != "-list_id" {
+		t.Fatalf("first page = %+v", page)
+	}
+
+	page, err = ListShoppingList(ctx, dbURL, "owner-1", true, PageRequest{Page: 2, PerPage: 2, Sort: "item_id"})
+	if err != nil {
+		t.Fatalf("ListShoppingList(sorted): %v", err)
+	}
+	if page.Total != 4 || len(page.Items) != 2 || page.Items[0].ItemID != "P-003" || page.Items[1].ItemID != "P-004" {
+		t.Fatalf("second sorted page = %+v", page)
+	}
+
+	page, err = ListShoppingList(ctx, dbURL, "owner-1", false, PageRequest{Query: "inv-2"})
+	if err != nil {
+		t.Fatalf("ListShoppingList(q): %v", err)
+	}
+	if page.Total != 1 || page.Items[0].ItemID != "P-002" {
+		t.Fatalf("filtered page = %+v", page)
+	}
+}
+
 // simple helpers
 func contains(s, sub string) bool {
 	return len(s) >= len(sub) && (s == sub || (len(s) > len(sub) && (indexOf(s, sub) >= 0)))
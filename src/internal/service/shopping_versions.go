@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrShoppingConflict is wrapped by ShoppingConflictError: a shopping_list row was
+// changed by someone else since it was read.
+var ErrShoppingConflict = errors.New("shopping list changed by someone else")
+
+// ShoppingConflictError lists the rows whose version no longer matches what the
+// writer read (or that are gone); reload them and try again.
+type ShoppingConflictError struct {
+	ListIDs []int
+}
+
+func (e *ShoppingConflictError) Error() string {
+	ids := make([]string, len(e.ListIDs))
+	for i, id := range e.ListIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	return fmt.Sprintf("shopping list row(s) %s changed since you loaded them; reload and try again", strings.Join(ids, ", "))
+}
+
+func (e *ShoppingConflictError) Unwrap() error { return ErrShoppingConflict }
+
+// ShoppingVersions returns list id -> version of rows, to pass back with a write.
+func ShoppingVersions(rows []ShoppingRow) map[int]int {
+	out := make(map[int]int, len(rows))
+	for _, r := range rows {
+		out[r.ListID] = r.Version
+	}
+	return out
+}
+
+// staleShoppingRows returns the ids in expected whose current version differs or that
+// have no current row, sorted.
+func staleShoppingRows(expected, current map[int]int) []int {
+	var out []int
+	for id, v := range expected {
+		if cur, ok := current[id]; !ok || cur != v {
+			out = append(out, id)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// lockShoppingVersions locks the owner's rows among ids for the rest of tx and
+// returns their versions.
+func lockShoppingVersions(ctx context.Context, tx pgx.Tx, ownerID string, ids []int) (map[int]int, error) {
+	rows, err := tx.Query(ctx, `
+SELECT list_id, version FROM shopping_list
+WHERE list_id = ANY($1) AND owner_id = $2
+FOR UPDATE
+`, ids, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+	defer rows.Close()
+	out := map[int]int{}
+	for rows.Next() {
+		var id, v int
+		if err := rows.Scan(&id, &v); err != nil {
+			return nil, fmt.Errorf("scan shopping row: %w", err)
+		}
+		out[id] = v
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Shortage states, from furthest to closest to being resolved.
+const (
+	ShortageUnlisted = "unlisted" // not (fully) on the shopping list
+	ShortageToOrder  = "to_order" // on the shopping list, no PO yet
+	ShortageOnOrder  = "on_order" // ordered, awaiting receipt
+)
+
+// PartShortage is a part a build still needs to receive.
+type PartShortage struct {
+	PartID      string  `json:"part_id"`
+	Required    float64 `json:"required"`    // build_parts, summed over assemblies
+	Requested   float64 `json:"requested"`   // on the build's shopping list rows
+	Ordered     float64 `json:"ordered"`     // of Requested, on a purchase order
+	Received    float64 `json:"received"`    // of Ordered, received
+	Outstanding float64 `json:"outstanding"` // Required - Received
+	State       string  `json:"state"`
+	// Supplier is the part's items_contacts.contact_id (Xero Contacts.AccountNumber),
+	// "" when the part has no supplier mapping
+	Supplier string `json:"supplier"`
+	// LastPOAt is when the owner last raised a (not deleted) PO containing the part;
+	// 0 when never
+	LastPOAt int64 `json:"last_po_at,omitempty"`
+}
+
+// LastPOTime is LastPOAt as a time; zero when never ordered.
+func (s PartShortage) LastPOTime() time.Time {
+	if s.LastPOAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(s.LastPOAt, 0).UTC()
+}
+
+// BuildShortage is the outstanding parts of one build.
+type BuildShortage struct {
+	Build Build          `json:"build"`
+	Parts []PartShortage `json:"parts"`
+}
+
+// shoppingTotals are a build's shopping_list quantities for one part.
+type shoppingTotals struct {
+	requested, ordered, received float64
+}
+
+// computeShortages returns the parts not yet fully received, sorted by part. Each
+// part's supplier and last PO time come from suppliers and lastPO when known.
+func computeShortages(parts []BuildPart, totals map[string]shoppingTotals, suppliers map[string]string, lastPO map[string]int64) []PartShortage {
+	need := map[string]float64{}
+	for _, p := range parts {
+		need[p.PartID] += p.Quantity
+	}
+	var out []PartShortage
+	for part, required := range need {
+		t := totals[part]
+		if t.received >= required {
+			continue
+		}
+		s := PartShortage{
+			PartID:      part,
+			Required:    required,
+			Requested:   t.requested,
+			Ordered:     t.ordered,
+			Received:    t.received,
+			Outstanding: roundQty(required - t.received),
+			Supplier:    suppliers[part],
+			LastPOAt:    lastPO[part],
+		}
+		switch {
+		case t.ordered >= required:
+			s.State = ShortageOnOrder
+		case t.requested >= required:
+			s.State = ShortageToOrder
+		default:
+			s.State = ShortageUnlisted
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PartID < out[j].PartID })
+	return out
+}
+
+// GetShortageReport returns the owner's builds with parts still outstanding, newest
+// first, each with what is missing and who supplies it. invoiceNumber, when set,
+// limits the report to that build.
+func GetShortageReport(ctx context.Context, dbURL, ownerID, invoiceNumber string) ([]BuildShortage, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `SELECT `+buildColumns+` FROM builds
+WHERE owner_id = $1 AND ($2 = '' OR invoice_number = $2)
+ORDER BY created_at DESC, id DESC`, ownerID, invoiceNumber)
+	if err != nil {
+		return nil, fmt.Errorf("query builds: %w", err)
+	}
+	var builds []Build
+	for rows.Next() {
+		b, err := scanBuild(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		builds = append(builds, *b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query builds: %w", err)
+	}
+	if len(builds) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, len(builds))
+	for i, b := range builds {
+		ids[i] = b.ID
+	}
+
+	parts := map[int][]BuildPart{}
+	partSet := map[string]bool{}
+	rows, err = pool.Query(ctx, `SELECT build_id, assembly_id, part_id, quantity FROM build_parts WHERE build_id = ANY($1)`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query build_parts: %w", err)
+	}
+	for rows.Next() {
+		var id int
+		var p BuildPart
+		if err := rows.Scan(&id, &p.AssemblyID, &p.PartID, &p.Quantity); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan build_part: %w", err)
+		}
+		parts[id] = append(parts[id], p)
+		partSet[p.PartID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query build_parts: %w", err)
+	}
+
+	totals := map[int]map[string]shoppingTotals{}
+	rows, err = pool.Query(ctx, `
+SELECT build_id, item_id, COALESCE(SUM(quantity), 0),
+       COALESCE(SUM(quantity) FILTER (WHERE ordered), 0),
+       COALESCE(SUM(quantity) FILTER (WHERE received), 0)
+FROM shopping_list WHERE build_id = ANY($1) AND (archived_at IS NULL OR ordered)
+GROUP BY build_id, item_id
+`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+	for rows.Next() {
+		var id int
+		var item string
+		var t shoppingTotals
+		if err := rows.Scan(&id, &item, &t.requested, &t.ordered, &t.received); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan shopping totals: %w", err)
+		}
+		if totals[id] == nil {
+			totals[id] = map[string]shoppingTotals{}
+		}
+		totals[id][item] = t
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+
+	partIDs := make([]string, 0, len(partSet))
+	for id := range partSet {
+		partIDs = append(partIDs, id)
+	}
+	// the supplier a part is ordered from when it has several; see GroupShoppingItemsByContact
+	suppliers := map[string]string{}
+	rows, err = pool.Query(ctx, `
+SELECT DISTINCT ON (item_id) item_id, contact_id
+FROM items_contacts WHERE workspace_id = $1 AND item_id = ANY($2) AND archived_at IS NULL
+ORDER BY item_id, preferred DESC, unit_price ASC NULLS LAST, contact_id
+`, ownerID, partIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	for rows.Next() {
+		var item, contact string
+		if err := rows.Scan(&item, &contact); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan items_contacts: %w", err)
+		}
+		suppliers[item] = contact
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+
+	lastPO := map[string]int64{}
+	rows, err = pool.Query(ctx, `
+SELECT l.item_id, COALESCE(MAX(po.created_at), 0)
+FROM purchase_order_lines l
+JOIN purchase_orders po ON po.id = l.purchase_order_id
+WHERE po.owner_id = $1 AND po.xero_deleted_at IS NULL AND l.item_id = ANY($2)
+GROUP BY l.item_id
+`, ownerID, partIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query purchase_orders: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item string
+		var at int64
+		if err := rows.Scan(&item, &at); err != nil {
+			return nil, fmt.Errorf("scan purchase_orders: %w", err)
+		}
+		lastPO[item] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query purchase_orders: %w", err)
+	}
+
+	var out []BuildShortage
+	for _, b := range builds {
+		if short := computeShortages(parts[b.ID], totals[b.ID], suppliers, lastPO); len(short) > 0 {
+			out = append(out, BuildShortage{Build: b, Parts: short})
+		}
+	}
+	return out, nil
+}
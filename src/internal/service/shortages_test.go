@@ -0,0 +1,36 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComputeShortages(t *testing.T) {
+	t.Parallel()
+	parts := []BuildPart{
+		{AssemblyID: "KIT", PartID: "SCREW", Quantity: 10},
+		{AssemblyID: "KIT", PartID: "TAP", Quantity: 2},
+		{AssemblyID: "BED", PartID: "SCREW", Quantity: 10},
+		{AssemblyID: "BED", PartID: "LEG", Quantity: 4},
+		{AssemblyID: "BED", PartID: "PANEL", Quantity: 1},
+	}
+	totals := map[string]shoppingTotals{
+		"SCREW": {requested: 20, ordered: 20, received: 5},
+		"TAP":   {requested: 2, ordered: 2, received: 2},
+		"LEG":   {requested: 4},
+		"PANEL": {},
+	}
+	got := computeShortages(parts, totals, map[string]string{"SCREW": "S-001", "LEG": "S-002"}, map[string]int64{"SCREW": 1700000000})
+	want := []PartShortage{
+		{PartID: "LEG", Required: 4, Requested: 4, Outstanding: 4, State: ShortageToOrder, Supplier: "S-002"},
+		{PartID: "PANEL", Required: 1, Outstanding: 1, State: ShortageUnlisted},
+		{PartID: "SCREW", Required: 20, Requested: 20, Ordered: 20, Received: 5, Outstanding: 15, State: ShortageOnOrder, Supplier: "S-001", LastPOAt: 1700000000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+
+	if got := computeShortages(parts[:2], map[string]shoppingTotals{"SCREW": {received: 10}, "TAP": {received: 3}}, nil, nil); got != nil {
+		t.Fatalf("fully received build: %+v", got)
+	}
+}
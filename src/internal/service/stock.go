@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/uom"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// FetchStockOnHand returns QuantityOnHand for the tracked inventory items among codes.
+// Untracked and unknown codes are absent. Stock moves with every bill and invoice, so
+// this asks Xero rather than going through itemCache; only items already fetched in
+// this request (see WithItemLookups) are reused.
+func FetchStockOnHand(ctx context.Context, xc *xero.Client, accessToken, tenantID string, codes []string) (map[string]float64, error) {
+	memo := itemMemoFrom(ctx)
+	items, unknown := memo.split(tenantID, uniqueStrings(codes))
+	if len(unknown) > 0 {
+		fetched, err := xc.GetItemsByCodes(ctx, accessToken, tenantID, unknown)
+		if err != nil {
+			return nil, err
+		}
+		memo.record(tenantID, unknown, fetched)
+		for code, it := range fetched {
+			items[code] = it
+		}
+	}
+	out := make(map[string]float64, len(items))
+	for code, it := range items {
+		if it.IsTrackedAsInventory {
+			out[code] = it.QuantityOnHand
+		}
+	}
+	return out, nil
+}
+
+// LeafPartIDs returns the part IDs of the leaf totals.
+func LeafPartIDs(totals []LeafTotal) []string {
+	out := make([]string, 0, len(totals))
+	for _, lt := range totals {
+		out = append(out, lt.PartID)
+	}
+	return out
+}
+
+// ApplyStockOnHand deducts available stock from the suggested quantities in place.
+// Quantities never go below zero and negative stock counts as none. Fractional stock
+// (an offcut, a part-used pack) can leave a remainder the part's unit cannot be
+// bought in, so what is left is rounded up to the unit (uom.Ceil). TotalCost is
+// recomputed for what is left to buy.
+func ApplyStockOnHand(totals []LeafTotal, stock map[string]float64) {
+	for i := range totals {
+		onHand, ok := stock[totals[i].PartID]
+		if !ok {
+			continue
+		}
+		lt := &totals[i]
+		lt.StockTracked = true
+		lt.OnHand = onHand
+		lt.Required = lt.Quantity
+		if onHand > 0 {
+			lt.Quantity = uom.Ceil(roundQty(lt.Quantity-onHand), lt.UOM)
+			if lt.Quantity < 0 {
+				lt.Quantity = 0
+			}
+		}
+		lt.TotalCost = roundCost(lt.UnitCost * lt.Quantity)
+	}
+}
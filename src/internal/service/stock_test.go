@@ -0,0 +1,48 @@
+package service
+
+import "testing"
+
+func TestApplyStockOnHand(t *testing.T) {
+	t.Parallel()
+	totals := []LeafTotal{
+		{PartID: "A", Quantity: 10, UnitCost: 2},
+		{PartID: "B", Quantity: 3, UnitCost: 1},
+		{PartID: "C", Quantity: 5, UnitCost: 1},
+		{PartID: "D", Quantity: 4, UnitCost: 1},
+	}
+	ApplyStockOnHand(totals, map[string]float64{"A": 4, "B": 8, "D": -2})
+
+	a := totals[0]
+	if !a.StockTracked || a.Required != 10 || a.OnHand != 4 || a.Quantity != 6 || a.TotalCost != 12 {
+		t.Fatalf("unexpected A: %+v", a)
+	}
+	if b := totals[1]; b.Quantity != 0 || b.TotalCost != 0 || b.Required != 3 {
+		t.Fatalf("stock above requirement should leave zero to buy: %+v", b)
+	}
+	if c := totals[2]; c.StockTracked || c.Quantity != 5 {
+		t.Fatalf("untracked part should be unchanged: %+v", c)
+	}
+	if d := totals[3]; !d.StockTracked || d.Quantity != 4 || d.OnHand != -2 {
+		t.Fatalf("negative stock should not add to the order: %+v", d)
+	}
+}
+
+func TestApplyStockOnHand_FractionalStock(t *testing.T) {
+	t.Parallel()
+	totals := []LeafTotal{
+		{PartID: "BOLT", Quantity: 5, UOM: "each", UnitCost: 2},
+		{PartID: "CABLE", Quantity: 3, UOM: "m", UnitCost: 10},
+		{PartID: "WASHER", Quantity: 4, UnitCost: 1},
+	}
+	ApplyStockOnHand(totals, map[string]float64{"BOLT": 2.5, "CABLE": 1.234, "WASHER": 3.9})
+
+	if b := totals[0]; b.Quantity != 3 || b.Required != 5 || b.TotalCost != 6 {
+		t.Fatalf("half a bolt left should be ordered as a whole one: %+v", b)
+	}
+	if c := totals[1]; c.Quantity != 1.77 || c.TotalCost != 17.7 {
+		t.Fatalf("cable left should round up to the centimetre: %+v", c)
+	}
+	if w := totals[2]; w.Quantity != 1 {
+		t.Fatalf("a part without a unit is counted in whole ones: %+v", w)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrSubstituteNotFound is returned when an item has no such substitute.
+var ErrSubstituteNotFound = fmt.Errorf("substitute %w", ErrNotFound)
+
+// ItemSubstitute is an item_substitutes row: a part that may be ordered in place of
+// ItemID. Lower Priority is offered first.
+type ItemSubstitute struct {
+	ItemID       string `json:"item_id"`
+	SubstituteID string `json:"substitute_id"`
+	Priority     int    `json:"priority"`
+	Note         string `json:"note,omitempty"`
+}
+
+// bomSubstitutes is what BOM resolution knows about substitutes: Options are the
+// orderable substitutes of each item, best first; Unavailable the items marked
+// unavailable; Chosen the substitute the user picked for an item.
+type bomSubstitutes struct {
+	Options     map[string][]string
+	Unavailable map[string]bool
+	Chosen      map[string]string
+}
+
+// offers reports whether sub is one of id's orderable substitutes.
+func (s bomSubstitutes) offers(id, sub string) bool {
+	return slices.Contains(s.Options[id], sub)
+}
+
+// substituteOptionsSQL lists the substitutes of $1's items that have a live supplier
+// mapping in workspace $2, so whatever is offered can be ordered.
+const substituteOptionsSQL = `
+SELECT s.item_id, s.substitute_id
+FROM item_substitutes s
+WHERE s.item_id = ANY($1)
+  AND EXISTS (SELECT 1 FROM items_contacts ic WHERE ic.workspace_id = $2 AND ic.item_id = s.substitute_id AND ic.archived_at IS NULL)
+ORDER BY s.item_id, s.priority, s.substitute_id
+`
+
+// loadSubstitutes returns the orderable substitutes of ids (see substituteOptionsSQL)
+// and which of ids are marked unavailable in the parts table.
+func loadSubstitutes(ctx context.Context, pool *pgxpool.Pool, ownerID string, ids []string) (bomSubstitutes, error) {
+	subs := bomSubstitutes{Options: map[string][]string{}, Unavailable: map[string]bool{}}
+	if len(ids) == 0 {
+		return subs, nil
+	}
+	rows, err := pool.Query(ctx, substituteOptionsSQL, ids, ownerID)
+	if err != nil {
+		return subs, fmt.Errorf("query item_substitutes: %w", err)
+	}
+	for rows.Next() {
+		var id, sub string
+		if err := rows.Scan(&id, &sub); err != nil {
+			rows.Close()
+			return subs, fmt.Errorf("scan item_substitutes: %w", err)
+		}
+		subs.Options[id] = append(subs.Options[id], sub)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return subs, fmt.Errorf("query item_substitutes: %w", err)
+	}
+
+	rows, err = pool.Query(ctx, `SELECT part_id FROM parts WHERE part_id = ANY($1) AND unavailable`, ids)
+	if err != nil {
+		return subs, fmt.Errorf("query parts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return subs, fmt.Errorf("scan part id: %w", err)
+		}
+		subs.Unavailable[id] = true
+	}
+	return subs, rows.Err()
+}
+
+// noSupplierSubstitutesFormat is the resolution message for an item that cannot be
+// ordered or expanded but has substitutes that can.
+const noSupplierSubstitutesFormat = "item %s has no supplier contact; it can be replaced by %s"
+
+// SubstitutesOffered returns the item code and its substitutes when msg is a
+// resolution message (possibly prefixed with its invoice) about an item that needs
+// one, so callers can let the user pick.
+func SubstitutesOffered(msg string) (code string, subs []string, ok bool) {
+	prefix, rest, _ := strings.Cut(noSupplierSubstitutesFormat, "%s")
+	infix, _, _ := strings.Cut(rest, "%s")
+	i := strings.LastIndex(msg, prefix)
+	if i < 0 || (i > 0 && !strings.HasSuffix(msg[:i], ": ")) {
+		return "", nil, false
+	}
+	code, list, ok := strings.Cut(msg[i+len(prefix):], infix)
+	if !ok || code == "" || list == "" {
+		return "", nil, false
+	}
+	return code, strings.Split(list, ", "), true
+}
+
+// ListItemSubstitutes returns the substitutes of itemID, best first.
+func ListItemSubstitutes(ctx context.Context, dbURL, itemID string) ([]ItemSubstitute, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT item_id, substitute_id, priority, COALESCE(note, '')
+FROM item_substitutes
+WHERE item_id = $1
+ORDER BY priority, substitute_id
+`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("query item_substitutes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ItemSubstitute
+	for rows.Next() {
+		var s ItemSubstitute
+		if err := rows.Scan(&s.ItemID, &s.SubstituteID, &s.Priority, &s.Note); err != nil {
+			return nil, fmt.Errorf("scan item_substitutes: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// SaveItemSubstitute adds s, or updates its priority and note when the pair exists.
+func SaveItemSubstitute(ctx context.Context, dbURL string, s ItemSubstitute) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	if s.ItemID == "" || s.SubstituteID == "" {
+		return invalid("", "item and substitute codes are required")
+	}
+	if s.ItemID == s.SubstituteID {
+		return invalid("substitute_id", "an item cannot substitute for itself")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Exec(ctx, `
+INSERT INTO item_substitutes (item_id, substitute_id, priority, note)
+VALUES ($1, $2, $3, NULLIF($4, ''))
+ON CONFLICT (item_id, substitute_id) DO UPDATE SET priority = EXCLUDED.priority, note = EXCLUDED.note
+`, s.ItemID, s.SubstituteID, s.Priority, s.Note)
+	if err != nil {
+		return fmt.Errorf("upsert item_substitutes: %w", err)
+	}
+	return nil
+}
+
+// DeleteItemSubstitute removes substituteID from itemID's substitutes.
+func DeleteItemSubstitute(ctx context.Context, dbURL, itemID, substituteID string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM item_substitutes WHERE item_id = $1 AND substitute_id = $2`, itemID, substituteID)
+	if err != nil {
+		return fmt.Errorf("delete item_substitutes: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSubstituteNotFound
+	}
+	return nil
+}
+
+// SetPartUnavailable marks itemID unavailable, so BOM resolution offers its
+// substitutes, or available again. Items not in the parts table yet get a row.
+func SetPartUnavailable(ctx context.Context, dbURL, itemID string, unavailable bool) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	_, err = pool.Exec(ctx, `
+INSERT INTO parts (part_id, unavailable) VALUES ($1, $2)
+ON CONFLICT (part_id) DO UPDATE SET unavailable = EXCLUDED.unavailable
+`, itemID, unavailable)
+	if err != nil {
+		return fmt.Errorf("update parts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssembleBOM_Substitutes(t *testing.T) {
+	t.Parallel()
+	names := map[string]string{"KIT": "Kitchen", "TAP": "Tap", "TAP-B": "Tap (brass)", "HOSE": "Hose", "HOSE-2": "Braided hose"}
+	contacts := map[string]bool{"TAP": true, "TAP-B": true, "HOSE-2": true}
+	roots := []RootItem{{PartID: "KIT", Quantity: 2}}
+	edges := []bomEdge{
+		{ParentID: "KIT", ChildID: "TAP", Quantity: 1, Depth: 2, Path: []string{"KIT", "TAP"}},
+		{ParentID: "KIT", ChildID: "HOSE", Quantity: 2, Depth: 2, Path: []string{"KIT", "HOSE"}},
+	}
+	subs := bomSubstitutes{
+		Options:     map[string][]string{"TAP": {"TAP-B"}, "HOSE": {"HOSE-2"}},
+		Unavailable: map[string]bool{"TAP": true},
+	}
+
+	// HOSE has no supplier, so resolution stops and offers its substitute
+	_, msg := assembleBOM(roots, edges, contacts, nil, nil, subs, names, 12)
+	if code, offered, ok := SubstitutesOffered(msg); !ok || code != "HOSE" || !reflect.DeepEqual(offered, []string{"HOSE-2"}) {
+		t.Fatalf("message %q offers %q %v %v", msg, code, offered, ok)
+	}
+
+	// once chosen it replaces HOSE; the unavailable TAP is kept, with its alternatives
+	subs.Chosen = map[string]string{"HOSE": "HOSE-2"}
+	got, msg := assembleBOM(roots, edges, contacts, nil, nil, subs, names, 12)
+	if msg != "" {
+		t.Fatalf("unexpected message: %s", msg)
+	}
+	tap, hose := got[0].Children[0], got[0].Children[1]
+	if tap.PartID != "TAP" || !tap.Unavailable || !reflect.DeepEqual(tap.Substitutes, []string{"TAP-B"}) {
+		t.Fatalf("tap = %+v", tap)
+	}
+	if hose.PartID != "HOSE-2" || hose.Name != "Braided hose" || hose.SubstituteFor != "HOSE" || hose.Quantity != 4 {
+		t.Fatalf("hose = %+v", hose)
+	}
+
+	// a choice that is not on offer is ignored
+	subs.Chosen["TAP"] = "TAP-X"
+	if got, _ := assembleBOM(roots, edges, contacts, nil, nil, subs, names, 12); got[0].Children[0].PartID != "TAP" {
+		t.Fatalf("tap replaced by %s", got[0].Children[0].PartID)
+	}
+	subs.Chosen["TAP"] = "TAP-B"
+	got, _ = assembleBOM(roots, edges, contacts, nil, nil, subs, names, 12)
+	lt := AggregateLeafTotals(BuildPerAssemblyBOM(got, roots))
+	byID := map[string]LeafTotal{}
+	for _, l := range lt {
+		byID[l.PartID] = l
+	}
+	if l := byID["TAP-B"]; l.Quantity != 2 || l.SubstituteFor != "TAP" {
+		t.Fatalf("totals = %+v", lt)
+	}
+}
+
+func TestSubstitutesOffered(t *testing.T) {
+	t.Parallel()
+	code, subs, ok := SubstitutesOffered("Invoice INV-1: item HOSE has no supplier contact; it can be replaced by HOSE-2, HOSE-3")
+	if !ok || code != "HOSE" || !reflect.DeepEqual(subs, []string{"HOSE-2", "HOSE-3"}) {
+		t.Fatalf("got %q %v %v", code, subs, ok)
+	}
+	for _, msg := range []string{"item HOSE has no supplier contact and no subcomponents", "item B-1 not found in Xero", ""} {
+		if _, _, ok := SubstitutesOffered(msg); ok {
+			t.Errorf("SubstitutesOffered(%q) ok", msg)
+		}
+	}
+}
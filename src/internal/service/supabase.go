@@ -41,8 +41,27 @@ SET access_token = EXCLUDED.access_token,
 	return nil
 }
 
-// AddShoppingListEntry inserts a row into shopping_list for the given item and quantity.
-func AddShoppingListEntry(ctx context.Context, dbURL, itemID string, quantity int, ordered bool) error {
+// DeleteConnection removes the stored connection (and its tokens) with the given id.
+func DeleteConnection(ctx context.Context, dbURL, id string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `DELETE FROM xero_connections WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("delete connection: %w", err)
+	}
+	return nil
+}
+
+// AddShoppingListEntry inserts a row into the owner's shopping_list for the given item
+// and quantity (kept to 4 decimal places). sourceInvoice may be empty for rows not
+// added from an invoice.
+func AddShoppingListEntry(ctx context.Context, dbURL, ownerID, itemID, sourceInvoice string, quantity float64, ordered bool) error {
 	if dbURL == "" {
 		return fmt.Errorf("db url missing")
 	}
@@ -53,11 +72,38 @@ func AddShoppingListEntry(ctx context.Context, dbURL, itemID string, quantity in
 	defer pool.Close()
 
 	_, err = pool.Exec(ctx, `
-INSERT INTO shopping_list (item_id, quantity, ordered, created_at)
-VALUES ($1, $2, $3, (extract(epoch from now()))::bigint)
-`, itemID, quantity, ordered)
+INSERT INTO shopping_list (item_id, quantity, ordered, owner_id, source_invoice, created_at)
+VALUES ($1, $2, $3, $4, NULLIF($5, ''), (extract(epoch from now()))::bigint)
+`, itemID, roundQty(quantity), ordered, ownerID, sourceInvoice)
 	if err != nil {
 		return fmt.Errorf("insert shopping_list: %w", err)
 	}
 	return nil
 }
+
+// AddBuildShoppingListEntry inserts an unordered shopping_list row attributed to a build;
+// owner and source invoice are taken from the build.
+func AddBuildShoppingListEntry(ctx context.Context, dbURL string, buildID int, itemID string, quantity float64) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tag, err := pool.Exec(ctx, `
+INSERT INTO shopping_list (item_id, quantity, ordered, build_id, owner_id, source_invoice, created_at)
+SELECT $1, $2, FALSE, b.id, b.owner_id, b.invoice_number, (extract(epoch from now()))::bigint
+FROM builds b
+WHERE b.id = $3
+`, itemID, roundQty(quantity), buildID)
+	if err != nil {
+		return fmt.Errorf("insert shopping_list: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("build %d not found", buildID)
+	}
+	return nil
+}
@@ -88,6 +88,8 @@ CREATE TABLE IF NOT EXISTS shopping_list (
   item_id TEXT NOT NULL,
   quantity INTEGER NOT NULL,
   ordered BOOLEAN DEFAULT FALSE,
+  owner_id TEXT,
+  source_invoice TEXT,
   created_at BIGINT,
   updated_at BIGINT
 );
@@ -186,15 +188,15 @@ func TestAddShoppingListEntry(t *testing.T) {
 	defer cancel()
 
 	// empty db url
-	if err := AddShoppingListEntry(ctx, "", "P-1", 2, false); err == nil {
+	if err := AddShoppingListEntry(ctx, "", "owner-1", "P-1", "", 2, false); err == nil {
 		t.Fatal("expected error for empty db url")
 	}
 
 	// add two rows
-	if err := AddShoppingListEntry(ctx, dbURL, "P-1", 2, false); err != nil {
+	if err := AddShoppingListEntry(ctx, dbURL, "owner-1", "P-1", "", 2, false); err != nil {
 		t.Fatalf("AddShoppingListEntry failed: %v", err)
 	}
-	if err := AddShoppingListEntry(ctx, dbURL, "P-2", 1, true); err != nil {
+	if err := AddShoppingListEntry(ctx, dbURL, "owner-1", "P-2", "", 1, true); err != nil {
 		t.Fatalf("AddShoppingListEntry failed: %v", err)
 	}
 
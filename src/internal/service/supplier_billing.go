@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// billedStatuses are the Xero bill statuses that count as billed: approved, paid or not.
+var billedStatuses = []string{"AUTHORISED", "PAID"}
+
+// SupplierBilling compares what the owner committed to a supplier on purchase orders
+// raised by the app with what the supplier has billed in Xero over the same period.
+type SupplierBilling struct {
+	ContactID     string  `json:"contact_id"`
+	AccountNumber string  `json:"account_number"`
+	Name          string  `json:"name"`
+	POs           int     `json:"pos"`
+	Committed     float64 `json:"committed"` // Xero total of those purchase orders
+	Bills         int     `json:"bills"`
+	Billed        float64 `json:"billed"`
+	Difference    float64 `json:"difference"` // Billed - Committed
+	// Missing counts purchase orders recorded locally but not returned by Xero, so
+	// Committed leaves them out
+	Missing int `json:"missing,omitempty"`
+}
+
+// SupplierBillingReport is the per-supplier comparison since Since (unix seconds).
+type SupplierBillingReport struct {
+	Since     int64             `json:"since"`
+	Suppliers []SupplierBilling `json:"suppliers"`
+	Committed float64           `json:"committed"`
+	Billed    float64           `json:"billed"`
+}
+
+// compareSupplierBilling groups the owner's purchase orders (those not deleted in
+// Xero) and the bills of the same contacts by contact, pricing each order at its Xero
+// total. Suppliers are ordered by the size of the difference, largest first.
+func compareSupplierBilling(local []PurchaseOrderRecord, remote []xero.PurchaseOrder, bills []xero.InvoiceSummary) []SupplierBilling {
+	remoteByID := make(map[string]xero.PurchaseOrder, len(remote))
+	for _, po := range remote {
+		remoteByID[po.PurchaseOrderID] = po
+	}
+	bySupplier := map[string]*SupplierBilling{}
+	var order []string
+	for _, lpo := range local {
+		if lpo.XeroDeletedAt != nil || lpo.ContactID == "" {
+			continue
+		}
+		s := bySupplier[lpo.ContactID]
+		if s == nil {
+			s = &SupplierBilling{ContactID: lpo.ContactID, AccountNumber: lpo.ContactAccount}
+			bySupplier[lpo.ContactID] = s
+			order = append(order, lpo.ContactID)
+		}
+		rpo, ok := remoteByID[lpo.XeroPOID]
+		if !ok {
+			s.Missing++
+			continue
+		}
+		if rpo.Status == "DELETED" {
+			continue
+		}
+		s.POs++
+		s.Committed += rpo.Total
+		if s.Name == "" {
+			s.Name = rpo.Contact.Name
+		}
+	}
+	for _, b := range bills {
+		s := bySupplier[b.Contact.ContactID]
+		if s == nil {
+			continue
+		}
+		s.Bills++
+		s.Billed += b.Total
+		if s.Name == "" {
+			s.Name = b.Contact.Name
+		}
+	}
+
+	out := make([]SupplierBilling, 0, len(order))
+	for _, id := range order {
+		s := bySupplier[id]
+		s.Committed = roundMoney(s.Committed)
+		s.Billed = roundMoney(s.Billed)
+		s.Difference = roundMoney(s.Billed - s.Committed)
+		out = append(out, *s)
+	}
+	abs := func(v float64) float64 {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if a, b := abs(out[i].Difference), abs(out[j].Difference); a != b {
+			return a > b
+		}
+		return out[i].AccountNumber < out[j].AccountNumber
+	})
+	return out
+}
+
+// RunSupplierBillingReport compares, per supplier, the Xero value of the purchase
+// orders the owner raised since since with the approved bills (ACCPAY invoices) those
+// suppliers have raised in Xero since then.
+func RunSupplierBillingReport(ctx context.Context, dbURL string, xc *xero.Client, ownerID string, creds XeroCredentials, since time.Time) (*SupplierBillingReport, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	if xc == nil {
+		xc = &xero.Client{}
+	}
+	local, err := ListPurchaseOrdersSince(ctx, dbURL, ownerID, since)
+	if err != nil {
+		return nil, err
+	}
+	report := &SupplierBillingReport{Since: since.Unix(), Suppliers: []SupplierBilling{}}
+	contacts := map[string]bool{}
+	var contactIDs []string
+	for _, lpo := range local {
+		if lpo.ContactID != "" && !contacts[lpo.ContactID] {
+			contacts[lpo.ContactID] = true
+			contactIDs = append(contactIDs, lpo.ContactID)
+		}
+	}
+	if len(contactIDs) == 0 {
+		return report, nil
+	}
+
+	remote, err := xc.ListPurchaseOrders(ctx, creds.AccessToken, creds.TenantID, since)
+	if err != nil {
+		return nil, fmt.Errorf("list xero purchase orders: %w", err)
+	}
+	bills, err := xc.ListBills(ctx, creds.AccessToken, creds.TenantID, xero.BillsQuery{
+		ContactIDs: contactIDs,
+		Statuses:   billedStatuses,
+		From:       since,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list xero bills: %w", err)
+	}
+
+	report.Suppliers = compareSupplierBilling(local, remote, bills)
+	for _, s := range report.Suppliers {
+		report.Committed += s.Committed
+		report.Billed += s.Billed
+	}
+	report.Committed, report.Billed = roundMoney(report.Committed), roundMoney(report.Billed)
+	return report, nil
+}
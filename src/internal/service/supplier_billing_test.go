@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestCompareSupplierBilling(t *testing.T) {
+	t.Parallel()
+	deleted := int64(1)
+	local := []PurchaseOrderRecord{
+		{XeroPOID: "po-1", ContactID: "c-acme", ContactAccount: "ACME"},
+		{XeroPOID: "po-2", ContactID: "c-acme", ContactAccount: "ACME"},
+		{XeroPOID: "po-3", ContactID: "c-fast", ContactAccount: "FAST"},
+		{XeroPOID: "po-4", ContactID: "c-fast", ContactAccount: "FAST", XeroDeletedAt: &deleted},
+		{XeroPOID: "po-5", ContactID: "c-fast", ContactAccount: "FAST"}, // not returned by Xero
+	}
+	remote := []xero.PurchaseOrder{
+		{PurchaseOrderID: "po-1", Status: "AUTHORISED", Contact: xero.PurchaseContact{ContactID: "c-acme", Name: "Acme"}, Total: 100},
+		{PurchaseOrderID: "po-2", Status: "BILLED", Contact: xero.PurchaseContact{ContactID: "c-acme", Name: "Acme"}, Total: 50.5},
+		{PurchaseOrderID: "po-3", Status: "AUTHORISED", Contact: xero.PurchaseContact{ContactID: "c-fast", Name: "Fasteners"}, Total: 20},
+		{PurchaseOrderID: "po-4", Status: "DELETED", Contact: xero.PurchaseContact{ContactID: "c-fast"}, Total: 999},
+		{PurchaseOrderID: "po-9", Status: "AUTHORISED", Contact: xero.PurchaseContact{ContactID: "c-other"}, Total: 70}, // raised outside the app
+	}
+	bills := []xero.InvoiceSummary{
+		{InvoiceID: "b-1", Contact: xero.PurchaseContact{ContactID: "c-acme"}, Total: 100},
+		{InvoiceID: "b-2", Contact: xero.PurchaseContact{ContactID: "c-fast"}, Total: 32},
+		{InvoiceID: "b-3", Contact: xero.PurchaseContact{ContactID: "c-other"}, Total: 70},
+	}
+
+	got := compareSupplierBilling(local, remote, bills)
+	want := []SupplierBilling{
+		{ContactID: "c-acme", AccountNumber: "ACME", Name: "Acme", POs: 2, Committed: 150.5, Bills: 1, Billed: 100, Difference: -50.5},
+		{ContactID: "c-fast", AccountNumber: "FAST", Name: "Fasteners", POs: 1, Committed: 20, Bills: 1, Billed: 32, Difference: 12, Missing: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestRunSupplierBillingReport_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := RunSupplierBillingReport(context.Background(), "", nil, "o", XeroCredentials{}, time.Now()); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
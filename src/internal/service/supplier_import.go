@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// KnownXeroAccountNumbers returns which of accountNumbers belong to a Xero contact.
+func KnownXeroAccountNumbers(ctx context.Context, xc *xero.Client, accessToken, tenantID string, accountNumbers []string) (map[string]bool, error) {
+	ids, err := xc.GetContactIDsByAccountNumbers(ctx, accessToken, tenantID, accountNumbers)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(ids))
+	for acc := range ids {
+		known[acc] = true
+	}
+	return known, nil
+}
+
+// ImportSupplierMappings adds rows to the owner's items_contacts in a single
+// transaction: either every row is applied or none is.
+func ImportSupplierMappings(ctx context.Context, dbURL, ownerID string, rows []csvimport.SupplierRow) (csvimport.Result, error) {
+	if dbURL == "" {
+		return csvimport.Result{}, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return csvimport.Result{}, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return csvimport.Result{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	res, err := csvimport.UpsertSupplierMappings(ctx, tx, ownerID, rows)
+	if err != nil {
+		return csvimport.Result{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return csvimport.Result{}, fmt.Errorf("commit: %w", err)
+	}
+	return res, nil
+}
+
+// NewlyOrderable returns the unordered shopping rows that have no supplier mapping
+// in tracked (see GetTrackedItemCodes) but would have one after importing rows, so
+// would go on a purchase order. Sorted by item code then list ID.
+func NewlyOrderable(shopping []ShoppingRow, tracked map[string]bool, rows []csvimport.SupplierRow) []ShoppingRow {
+	imported := map[string]bool{}
+	for _, r := range rows {
+		imported[r.ItemCode] = true
+	}
+	var out []ShoppingRow
+	for _, s := range shopping {
+		if !tracked[s.ItemID] && imported[s.ItemID] {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].ItemID != out[j].ItemID {
+			return out[i].ItemID < out[j].ItemID
+		}
+		return out[i].ListID < out[j].ListID
+	})
+	return out
+}
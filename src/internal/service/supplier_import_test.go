@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+)
+
+func TestImportSupplierMappings_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	_, err := ImportSupplierMappings(context.Background(), "", "owner-1", []csvimport.SupplierRow{{Line: 2, ItemCode: "A", AccountNumber: "SUP-1"}})
+	if err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
+
+func TestNewlyOrderable(t *testing.T) {
+	t.Parallel()
+	shopping := []ShoppingRow{
+		{ListID: 4, ItemID: "NUT", Quantity: 2},
+		{ListID: 1, ItemID: "BOLT", Quantity: 10}, // already mapped
+		{ListID: 2, ItemID: "WASHER", Quantity: 5},
+		{ListID: 3, ItemID: "NUT", Quantity: 1},
+		{ListID: 5, ItemID: "GEAR", Quantity: 1}, // still unmapped
+	}
+	rows := []csvimport.SupplierRow{
+		{Line: 2, ItemCode: "BOLT", AccountNumber: "SUP-2"},
+		{Line: 3, ItemCode: "NUT", AccountNumber: "SUP-1"},
+		{Line: 4, ItemCode: "WASHER", AccountNumber: "SUP-1"},
+	}
+	got := NewlyOrderable(shopping, map[string]bool{"BOLT": true}, rows)
+	want := []ShoppingRow{
+		{ListID: 3, ItemID: "NUT", Quantity: 1},
+		{ListID: 4, ItemID: "NUT", Quantity: 2},
+		{ListID: 2, ItemID: "WASHER", Quantity: 5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrMappingNotFound is returned when an item has no such supplier mapping.
+var ErrMappingNotFound = fmt.Errorf("supplier mapping %w", ErrNotFound)
+
+// SupplierMapping is an items_contacts row: an item bought from a supplier (Xero
+// Contacts.AccountNumber) and the ordering terms agreed with them.
+type SupplierMapping struct {
+	ItemID          string   `json:"item_id"`
+	ContactID       string   `json:"contact_id"`
+	LeadTimeDays    *int     `json:"lead_time_days,omitempty"`
+	MinimumOrderQty *float64 `json:"minimum_order_qty,omitempty"`
+	PackSize        *float64 `json:"pack_size,omitempty"`
+	UnitPrice       *float64 `json:"unit_price,omitempty"`
+	UOM             *string  `json:"uom,omitempty"`         // nil: the part's unit
+	Preferred       bool     `json:"preferred"`             // ordered from first; see GroupShoppingItemsByContact
+	ArchivedAt      *int64   `json:"archived_at,omitempty"` // nil unless archived
+}
+
+// ListSupplierMappings returns the owner's items_contacts rows by item and supplier.
+// Archived mappings are left out unless includeArchived is set.
+func ListSupplierMappings(ctx context.Context, dbURL, ownerID string, includeArchived bool) ([]SupplierMapping, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT item_id, contact_id, lead_time_days, minimum_order_qty::float8, pack_size::float8, unit_price::float8, uom, preferred, archived_at
+FROM items_contacts
+WHERE workspace_id = $1 AND ($2 OR archived_at IS NULL)
+ORDER BY item_id, contact_id
+`, ownerID, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SupplierMapping
+	for rows.Next() {
+		var m SupplierMapping
+		if err := rows.Scan(&m.ItemID, &m.ContactID, &m.LeadTimeDays, &m.MinimumOrderQty, &m.PackSize, &m.UnitPrice, &m.UOM, &m.Preferred, &m.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("scan items_contacts: %w", err)
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// SetSupplierMappingArchived archives (hides from ordering and BOM resolution, see
+// PurgeExpiredRows) or restores the owner's mapping of itemID to contactID. Archiving
+// an archived mapping, or restoring a live one, changes nothing. An archived mapping
+// is no longer preferred.
+func SetSupplierMappingArchived(ctx context.Context, dbURL, ownerID, itemID, contactID string, archived bool) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tag, err := pool.Exec(ctx, `
+UPDATE items_contacts
+SET archived_at = CASE WHEN $4 THEN COALESCE(archived_at, (extract(epoch from now()))::bigint) END,
+    preferred = preferred AND NOT $4
+WHERE workspace_id = $1 AND item_id = $2 AND contact_id = $3
+`, ownerID, itemID, contactID, archived)
+	if err != nil {
+		return fmt.Errorf("update items_contacts: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMappingNotFound
+	}
+	return nil
+}
+
+// SetPreferredSupplier makes contactID the owner's preferred supplier of itemID, the
+// one it is ordered from when it has several, in place of any other. The mapping must
+// exist and not be archived.
+func SetPreferredSupplier(ctx context.Context, dbURL, ownerID, itemID, contactID string) error {
+	return WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+UPDATE items_contacts SET preferred = false
+WHERE workspace_id = $1 AND item_id = $2 AND contact_id <> $3 AND preferred
+`, ownerID, itemID, contactID); err != nil {
+			return fmt.Errorf("update items_contacts: %w", err)
+		}
+		tag, err := tx.Exec(ctx, `
+UPDATE items_contacts SET preferred = true
+WHERE workspace_id = $1 AND item_id = $2 AND contact_id = $3 AND archived_at IS NULL
+`, ownerID, itemID, contactID)
+		if err != nil {
+			return fmt.Errorf("update items_contacts: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrMappingNotFound
+		}
+		return nil
+	})
+}
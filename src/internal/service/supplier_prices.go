@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/csvimport"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PriceChange is a supplier price list entry taking effect: the mapping's agreed
+// price (items_contacts.unit_price) went From -> To. From is 0 when it had none.
+type PriceChange struct {
+	OwnerID       string  `json:"-"`
+	ItemID        string  `json:"item_id"`
+	ContactID     string  `json:"contact_id"`
+	From          float64 `json:"from"`
+	To            float64 `json:"to"`
+	EffectiveFrom string  `json:"effective_from"` // YYYY-MM-DD
+}
+
+// SupplierPrice is a supplier_prices row: an item's price from a supplier from
+// EffectiveFrom on. AppliedAt is set once it became the agreed price.
+type SupplierPrice struct {
+	ContactID     string  `json:"contact_id"`
+	EffectiveFrom string  `json:"effective_from"` // YYYY-MM-DD
+	UnitPrice     float64 `json:"unit_price"`
+	Source        string  `json:"source"`
+	AppliedAt     *int64  `json:"applied_at,omitempty"`
+}
+
+// MappedSuppliers returns which (item code, AccountNumber) pairs among items'
+// mappings are live, for checking a price list.
+func MappedSuppliers(ctx context.Context, dbURL, ownerID string, items []string) (map[[2]string]bool, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT item_id, contact_id FROM items_contacts
+WHERE workspace_id = $1 AND item_id = ANY($2) AND archived_at IS NULL
+`, ownerID, items)
+	if err != nil {
+		return nil, fmt.Errorf("query items_contacts: %w", err)
+	}
+	defer rows.Close()
+	out := map[[2]string]bool{}
+	for rows.Next() {
+		var item, contact string
+		if err := rows.Scan(&item, &contact); err != nil {
+			return nil, fmt.Errorf("scan items_contacts: %w", err)
+		}
+		out[[2]string{item, contact}] = true
+	}
+	return out, rows.Err()
+}
+
+// ImportSupplierPrices adds a price list to the owner's supplier_prices in a single
+// transaction and applies the prices already in effect, returning what changed.
+// Prices dated later are applied by ApplyDueSupplierPrices on the day.
+func ImportSupplierPrices(ctx context.Context, dbURL, ownerID string, src csvimport.PriceSource, rows []csvimport.PriceRow) (csvimport.Result, []PriceChange, error) {
+	var res csvimport.Result
+	var changes []PriceChange
+	err := WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		var err error
+		if res, err = csvimport.UpsertSupplierPrices(ctx, tx, ownerID, src, rows); err != nil {
+			return err
+		}
+		if changes, err = applySupplierPrices(ctx, tx, ownerID); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, AuditEntry{
+			OwnerID: ownerID,
+			Action:  AuditSupplierPricesImported,
+			Detail: map[string]any{"file": src.Filename, "imported_by": src.ImportedBy, "push_to_xero": src.PushToXero,
+				"rows": len(rows), "result": res, "applied": changes},
+		})
+	})
+	if err != nil {
+		return csvimport.Result{}, nil, err
+	}
+	return res, changes, nil
+}
+
+// ApplyDueSupplierPrices applies the supplier prices of every workspace that have
+// taken effect since they were imported, auditing them per workspace.
+func ApplyDueSupplierPrices(ctx context.Context, dbURL string) ([]PriceChange, error) {
+	var changes []PriceChange
+	err := WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		var err error
+		if changes, err = applySupplierPrices(ctx, tx, ""); err != nil {
+			return err
+		}
+		byOwner := map[string][]PriceChange{}
+		var owners []string
+		for _, c := range changes {
+			if _, ok := byOwner[c.OwnerID]; !ok {
+				owners = append(owners, c.OwnerID)
+			}
+			byOwner[c.OwnerID] = append(byOwner[c.OwnerID], c)
+		}
+		for _, o := range owners {
+			if err := writeAudit(ctx, tx, AuditEntry{OwnerID: o, Action: AuditSupplierPricesApplied, Detail: map[string]any{"applied": byOwner[o]}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// applySupplierPrices copies each mapping's latest price in effect to
+// items_contacts.unit_price when it has not been applied yet, for ownerID's mappings
+// ("" = every workspace's). Older unapplied prices it supersedes are marked applied
+// with it, and a price set by hand after the latest one was applied is left alone.
+func applySupplierPrices(ctx context.Context, tx pgx.Tx, ownerID string) ([]PriceChange, error) {
+	rows, err := tx.Query(ctx, `
+WITH latest AS (
+  SELECT DISTINCT ON (workspace_id, item_id, contact_id) workspace_id, item_id, contact_id, effective_from, unit_price, applied_at
+  FROM supplier_prices
+  WHERE effective_from <= current_date AND ($1 = '' OR workspace_id = $1)
+  ORDER BY workspace_id, item_id, contact_id, effective_from DESC
+), due AS (
+  SELECT l.workspace_id, l.item_id, l.contact_id, l.effective_from, l.unit_price, ic.unit_price AS old_price
+  FROM latest l
+  JOIN items_contacts ic ON ic.workspace_id = l.workspace_id AND ic.item_id = l.item_id AND ic.contact_id = l.contact_id
+  WHERE l.applied_at IS NULL
+)
+UPDATE items_contacts ic SET unit_price = due.unit_price
+FROM due
+WHERE ic.workspace_id = due.workspace_id AND ic.item_id = due.item_id AND ic.contact_id = due.contact_id
+RETURNING ic.workspace_id, ic.item_id, ic.contact_id, COALESCE(due.old_price, 0)::float8, due.unit_price::float8,
+          to_char(due.effective_from, 'YYYY-MM-DD')
+`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("apply supplier_prices: %w", err)
+	}
+	var changes []PriceChange
+	for rows.Next() {
+		var c PriceChange
+		if err := rows.Scan(&c.OwnerID, &c.ItemID, &c.ContactID, &c.From, &c.To, &c.EffectiveFrom); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan price change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("apply supplier_prices: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+UPDATE supplier_prices sp SET applied_at = (extract(epoch from now()))::bigint
+WHERE sp.applied_at IS NULL AND sp.effective_from <= current_date AND ($1 = '' OR sp.workspace_id = $1)
+  AND EXISTS (SELECT 1 FROM items_contacts ic WHERE ic.workspace_id = sp.workspace_id AND ic.item_id = sp.item_id AND ic.contact_id = sp.contact_id)
+`, ownerID); err != nil {
+		return nil, fmt.Errorf("mark supplier_prices applied: %w", err)
+	}
+	sortPriceChanges(changes)
+	return changes, nil
+}
+
+// sortPriceChanges orders changes by workspace, item and supplier.
+func sortPriceChanges(changes []PriceChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.OwnerID != b.OwnerID {
+			return a.OwnerID < b.OwnerID
+		}
+		if a.ItemID != b.ItemID {
+			return a.ItemID < b.ItemID
+		}
+		return a.ContactID < b.ContactID
+	})
+}
+
+// ListPendingPriceOwners returns the workspaces with applied prices from a price
+// list marked for Xero that have not been pushed there yet.
+func ListPendingPriceOwners(ctx context.Context, dbURL string) ([]string, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT DISTINCT workspace_id FROM supplier_prices
+WHERE push_to_xero AND applied_at IS NOT NULL AND pushed_at IS NULL
+ORDER BY workspace_id
+`)
+	if err != nil {
+		return nil, fmt.Errorf("query supplier_prices: %w", err)
+	}
+	return scanStrings(rows)
+}
+
+// scanStrings reads rows of one text column.
+func scanStrings(rows pgx.Rows) ([]string, error) {
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// PushXeroPurchasePrices updates the Xero purchase price of the owner's items with
+// applied prices from a price list marked for Xero, to the agreed price of the
+// supplier each is ordered from by default (see loadSupplierCosts). It returns the
+// number of items updated. Items without an agreed price or missing from Xero are
+// skipped; either way they are not pushed again until a new price takes effect.
+func PushXeroPurchasePrices(ctx context.Context, dbURL string, xc *xero.Client, ownerID string, creds XeroCredentials) (int, error) {
+	if dbURL == "" {
+		return 0, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return 0, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT DISTINCT item_id FROM supplier_prices
+WHERE workspace_id = $1 AND push_to_xero AND applied_at IS NOT NULL AND pushed_at IS NULL
+`, ownerID)
+	if err != nil {
+		return 0, fmt.Errorf("query supplier_prices: %w", err)
+	}
+	items, err := scanStrings(rows)
+	if err != nil {
+		return 0, fmt.Errorf("read supplier_prices: %w", err)
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+	prices, err := loadSupplierCosts(ctx, pool, ownerID, items)
+	if err != nil {
+		return 0, err
+	}
+	pushed := len(prices)
+	if len(prices) > 0 {
+		missing, err := xc.UpdatePurchasePrices(ctx, creds.AccessToken, creds.TenantID, prices)
+		if err != nil {
+			return 0, fmt.Errorf("update xero purchase prices: %w", err)
+		}
+		pushed -= len(missing)
+		itemCache.forget(ctx, creds.TenantID, items)
+	}
+	if _, err := pool.Exec(ctx, `
+UPDATE supplier_prices SET pushed_at = (extract(epoch from now()))::bigint
+WHERE workspace_id = $1 AND item_id = ANY($2) AND push_to_xero AND applied_at IS NOT NULL AND pushed_at IS NULL
+`, ownerID, items); err != nil {
+		return pushed, fmt.Errorf("mark supplier_prices pushed: %w", err)
+	}
+	return pushed, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoadSuppliers loads the suppliers table as pkg/xero.Supplier (for contact sync).
+func LoadSuppliers(ctx context.Context, dbURL string) ([]xero.Supplier, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT
+  supplier_id,
+  COALESCE(supplier_name, ''),
+  COALESCE(contact_email, ''),
+  COALESCE(phone, ''),
+  COALESCE(updated_at, 0)
+FROM suppliers
+ORDER BY supplier_id
+`)
+	if err != nil {
+		return nil, fmt.Errorf("query suppliers: %w", err)
+	}
+	defer rows.Close()
+
+	var out []xero.Supplier
+	for rows.Next() {
+		var s xero.Supplier
+		if err := rows.Scan(&s.SupplierID, &s.SupplierName, &s.ContactEmail, &s.Phone, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan supplier: %w", err)
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query suppliers: %w", err)
+	}
+	return out, nil
+}
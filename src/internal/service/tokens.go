@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrNoConnection is returned when an owner has no stored Xero connection.
+var ErrNoConnection = errors.New("no xero connection found for owner")
+
+// ErrConsentRevoked is returned when Xero no longer accepts a connection's refresh
+// token, typically because the user revoked the app's access. The connection has
+// been removed by then; the user has to connect to Xero again.
+var ErrConsentRevoked = errors.New("xero access was revoked or has expired")
+
+// tokenRefreshLeeway refreshes access tokens that expire within this window.
+const tokenRefreshLeeway = 60 * time.Second
+
+// XeroCredentials is what a caller needs to call the Xero API for one tenant. It is
+// short-lived, never carries the refresh token and is not serialised.
+type XeroCredentials struct {
+	TenantID    string `json:"-"`
+	AccessToken string `json:"-"`
+}
+
+// TokenManager is the only reader of stored Xero tokens. It loads a connection's
+// tokens, refreshes them when they are about to expire and persists the result.
+type TokenManager struct {
+	dbURL        string
+	xc           *xero.Client
+	clientID     string
+	clientSecret string
+}
+
+// NewTokenManager returns a TokenManager using the Xero app credentials.
+func NewTokenManager(dbURL string, xc *xero.Client, clientID, clientSecret string) *TokenManager {
+	if xc == nil {
+		xc = &xero.Client{}
+	}
+	return &TokenManager{dbURL: dbURL, xc: xc, clientID: clientID, clientSecret: clientSecret}
+}
+
+// Credentials returns a valid access token for conn, refreshing it first when needed.
+func (m *TokenManager) Credentials(ctx context.Context, conn ConnectionInfo) (XeroCredentials, error) {
+	if m.dbURL == "" {
+		return XeroCredentials{}, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, m.dbURL)
+	if err != nil {
+		return XeroCredentials{}, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var accessToken, refreshToken string
+	var expiresAt int64
+	err = pool.QueryRow(ctx, `SELECT access_token, refresh_token, COALESCE(expires_at, 0) FROM xero_connections WHERE id = $1`, conn.ID).
+		Scan(&accessToken, &refreshToken, &expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return XeroCredentials{}, ErrNoConnection
+	}
+	if err != nil {
+		return XeroCredentials{}, fmt.Errorf("load tokens: %w", err)
+	}
+
+	if expiresAt > time.Now().Add(tokenRefreshLeeway).Unix() {
+		return XeroCredentials{TenantID: conn.TenantID, AccessToken: accessToken}, nil
+	}
+	accessToken, err = m.refresh(ctx, conn)
+	if err != nil {
+		return XeroCredentials{}, err
+	}
+	return XeroCredentials{TenantID: conn.TenantID, AccessToken: accessToken}, nil
+}
+
+// refresh renews conn's tokens while holding its row lock. Xero refresh tokens are
+// single use, so when several instances find the token expiring at once the first
+// refreshes it and the others, once the lock is theirs, use the stored result
+// instead of spending the old refresh token (which would fail as invalid_grant and
+// remove a working connection).
+func (m *TokenManager) refresh(ctx context.Context, conn ConnectionInfo) (string, error) {
+	var accessToken string
+	revoked := false
+	err := WithTx(ctx, m.dbURL, func(tx pgx.Tx) error {
+		var refreshToken string
+		var expiresAt int64
+		err := tx.QueryRow(ctx, `
+SELECT access_token, refresh_token, COALESCE(expires_at, 0) FROM xero_connections WHERE id = $1 FOR UPDATE
+`, conn.ID).Scan(&accessToken, &refreshToken, &expiresAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoConnection
+		}
+		if err != nil {
+			return fmt.Errorf("lock tokens: %w", err)
+		}
+		if expiresAt > time.Now().Add(tokenRefreshLeeway).Unix() {
+			return nil // refreshed by someone else while we waited
+		}
+
+		tr, err := m.xc.RefreshToken(ctx, m.clientID, m.clientSecret, refreshToken)
+		if errors.Is(err, xero.ErrInvalidGrant) {
+			// the stored tokens are dead; drop them so the app shows "not connected"
+			if _, err := tx.Exec(ctx, `DELETE FROM xero_connections WHERE id = $1`, conn.ID); err != nil {
+				return fmt.Errorf("%w (removing connection: %v)", ErrConsentRevoked, err)
+			}
+			revoked = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("refresh token failed: %w", err)
+		}
+		secs := tr.ExpiresIn
+		if secs == 0 {
+			secs = 3600
+		}
+		now := time.Now().Unix()
+		if _, err := tx.Exec(ctx, `
+UPDATE xero_connections SET access_token = $2, refresh_token = $3, expires_at = $4, updated_at = $5 WHERE id = $1
+`, conn.ID, tr.AccessToken, tr.RefreshToken, now+secs, now); err != nil {
+			return fmt.Errorf("persist refreshed token: %w", err)
+		}
+		accessToken = tr.AccessToken
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if revoked {
+		return "", ErrConsentRevoked
+	}
+	return accessToken, nil
+}
+
+// CredentialsForOwner returns credentials for the owner's first connection (the UI
+// supports one tenant per owner). It returns ErrNoConnection when there is none.
+func (m *TokenManager) CredentialsForOwner(ctx context.Context, ownerID string) (XeroCredentials, error) {
+	conns, err := GetConnectionsForOwner(ctx, m.dbURL, ownerID)
+	if err != nil {
+		return XeroCredentials{}, fmt.Errorf("load connections: %w", err)
+	}
+	if len(conns) == 0 {
+		return XeroCredentials{}, ErrNoConnection
+	}
+	return m.Credentials(ctx, conns[0])
+}
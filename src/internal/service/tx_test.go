@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeTx records how a transaction ended; other pgx.Tx methods are not used.
+type fakeTx struct {
+	pgx.Tx
+	committed, rolledBack bool
+}
+
+func (t *fakeTx) Commit(ctx context.Context) error {
+	t.committed = true
+	return nil
+}
+
+func (t *fakeTx) Rollback(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	t.rolledBack = true
+	return nil
+}
+
+type fakeBeginner struct{ tx *fakeTx }
+
+func (b *fakeBeginner) Begin(ctx context.Context) (pgx.Tx, error) {
+	b.tx = &fakeTx{}
+	return b.tx, nil
+}
+
+func TestRunTx(t *testing.T) {
+	t.Parallel()
+	boom := errors.New("boom")
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, tc := range map[string]struct {
+		ctx       context.Context
+		fn        func(pgx.Tx) error
+		wantErr   error
+		committed bool
+	}{
+		"commits":   {context.Background(), func(pgx.Tx) error { return nil }, nil, true},
+		"error":     {context.Background(), func(pgx.Tx) error { return boom }, boom, false},
+		"cancelled": {cancelled, func(pgx.Tx) error { return nil }, context.Canceled, false},
+	} {
+		b := &fakeBeginner{}
+		err := runTx(tc.ctx, b, tc.fn)
+		if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil) != (err == nil) {
+			t.Errorf("%s: err = %v, want %v", name, err, tc.wantErr)
+		}
+		if b.tx.committed != tc.committed || b.tx.rolledBack == tc.committed {
+			t.Errorf("%s: committed = %v, rolled back = %v", name, b.tx.committed, b.tx.rolledBack)
+		}
+	}
+}
+
+func TestRunTx_PanicRollsBack(t *testing.T) {
+	t.Parallel()
+	b := &fakeBeginner{}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic swallowed")
+			}
+		}()
+		_ = runTx(context.Background(), b, func(pgx.Tx) error { panic("boom") })
+	}()
+	if b.tx.committed || !b.tx.rolledBack {
+		t.Fatalf("committed = %v, rolled back = %v", b.tx.committed, b.tx.rolledBack)
+	}
+}
+
+func TestWithTx_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	called := false
+	err := WithTx(context.Background(), "", func(pgx.Tx) error { called = true; return nil })
+	if err == nil || !strings.Contains(err.Error(), "db url missing") || called {
+		t.Fatalf("err = %v, called = %v", err, called)
+	}
+	err = RecordPurchaseOrders(context.Background(), "", "owner-1", []PurchaseOrderRecord{{XeroPOID: "po-1"}}, map[int]int{1: 1})
+	if err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
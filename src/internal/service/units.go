@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hwalton/xero-invoice-orderer/internal/uom"
+	"github.com/jackc/pgx/v5"
+)
+
+// itemUOMSQL is the unit each of $1's items is ordered in: the unit of its best
+// supplier mapping in workspace $2 (the one GroupShoppingItemsByContact orders from
+// unless the PO preview picks another), else the parts table's. Items with neither are left out and count in uom.Each.
+const itemUOMSQL = `
+SELECT i.id, COALESCE(ic.uom, p.uom)
+FROM unnest($1::text[]) AS i(id)
+LEFT JOIN LATERAL (
+  SELECT uom FROM items_contacts
+  WHERE workspace_id = $2 AND item_id = i.id AND archived_at IS NULL
+  ORDER BY preferred DESC, unit_price ASC NULLS LAST, contact_id LIMIT 1
+) ic ON TRUE
+LEFT JOIN parts p ON p.part_id = i.id
+WHERE COALESCE(ic.uom, p.uom) IS NOT NULL
+`
+
+// loadUOMs returns the unit of measure of each of ids, normalized; see itemUOMSQL.
+func loadUOMs(ctx context.Context, q interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}, ownerID string, ids []string) (map[string]string, error) {
+	out := map[string]string{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := q.Query(ctx, itemUOMSQL, uniqueStrings(ids), ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query units of measure: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, u string
+		if err := rows.Scan(&id, &u); err != nil {
+			return nil, fmt.Errorf("scan unit of measure: %w", err)
+		}
+		out[id] = uom.Normalize(u)
+	}
+	return out, rows.Err()
+}
+
+// applyUOMs sets UOM on every node of the tree from uoms; the rest count in uom.Each.
+func applyUOMs(nodes []BOMNode, uoms map[string]string) {
+	for i := range nodes {
+		nodes[i].UOM = uoms[nodes[i].PartID]
+		if nodes[i].UOM == "" {
+			nodes[i].UOM = uom.Each
+		}
+		applyUOMs(nodes[i].Children, uoms)
+	}
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxTopParts is how many parts the usage report ranks.
+const maxTopParts = 25
+
+// usageMonthLayout is how report months are written ("2026-03").
+const usageMonthLayout = "2006-01"
+
+// PartUsage is how much of a part the owner ordered over the report period.
+type PartUsage struct {
+	PartID   string  `json:"part_id"`
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Orders   int     `json:"orders"` // purchase orders containing the part
+	Spend    float64 `json:"spend"`
+	// Unpriced counts lines whose price is unknown (Xero's item price applied), so
+	// Spend leaves them out
+	Unpriced int `json:"unpriced,omitempty"`
+}
+
+// SupplierSpend is what the owner ordered from one supplier in one month.
+type SupplierSpend struct {
+	Month        string  `json:"month"` // YYYY-MM, UTC
+	SupplierID   string  `json:"supplier_id"`
+	SupplierName string  `json:"supplier_name"`
+	Orders       int     `json:"orders"`
+	Spend        float64 `json:"spend"`
+	Unpriced     int     `json:"unpriced,omitempty"`
+}
+
+// ReceiptTime is the average time from ordering to receiving shopping list rows
+// received in one month ("" for the whole period).
+type ReceiptTime struct {
+	Month       string  `json:"month"`
+	Rows        int     `json:"rows"`
+	AverageDays float64 `json:"average_days"`
+}
+
+// UsageReport aggregates the owner's purchasing since Since: the most ordered parts,
+// spend per supplier per month, and order-to-receipt times per month plus overall.
+type UsageReport struct {
+	Since         int64           `json:"since"`
+	TopParts      []PartUsage     `json:"top_parts"`
+	SupplierSpend []SupplierSpend `json:"supplier_spend"`
+	Receipts      []ReceiptTime   `json:"receipts"`
+	Receipt       ReceiptTime     `json:"receipt"`
+}
+
+// usageLine is one purchase order line as read for the usage report.
+type usageLine struct {
+	POID         int
+	CreatedAt    int64
+	SupplierID   string
+	SupplierName string
+	PartID       string
+	PartName     string
+	Quantity     float64
+	UnitAmount   *float64 // in the base currency; nil when unknown
+}
+
+// receiptSpan is when one shopping list row was ordered and received (unix seconds).
+type receiptSpan struct {
+	OrderedAt, ReceivedAt int64
+}
+
+// usageMonth is the UTC month of a unix time.
+func usageMonth(t int64) string { return time.Unix(t, 0).UTC().Format(usageMonthLayout) }
+
+// roundMoney rounds to cents.
+func roundMoney(v float64) float64 { return math.Round(v*100) / 100 }
+
+// summarizeUsage ranks parts by quantity ordered (at most limit) and totals spend per
+// supplier per month, newest month first and biggest spend first within a month.
+func summarizeUsage(lines []usageLine, limit int) ([]PartUsage, []SupplierSpend) {
+	type partAcc struct {
+		PartUsage
+		orders map[int]bool
+	}
+	type spendKey struct{ month, supplier string }
+	type spendAcc struct {
+		SupplierSpend
+		orders map[int]bool
+	}
+	parts := map[string]*partAcc{}
+	spend := map[spendKey]*spendAcc{}
+	for _, l := range lines {
+		p := parts[l.PartID]
+		if p == nil {
+			p = &partAcc{PartUsage: PartUsage{PartID: l.PartID, Name: l.PartName}, orders: map[int]bool{}}
+			parts[l.PartID] = p
+		}
+		k := spendKey{usageMonth(l.CreatedAt), l.SupplierID}
+		s := spend[k]
+		if s == nil {
+			s = &spendAcc{SupplierSpend: SupplierSpend{Month: k.month, SupplierID: l.SupplierID, SupplierName: l.SupplierName}, orders: map[int]bool{}}
+			spend[k] = s
+		}
+		p.Quantity = roundQty(p.Quantity + l.Quantity)
+		p.orders[l.POID] = true
+		s.orders[l.POID] = true
+		if l.UnitAmount == nil {
+			p.Unpriced++
+			s.Unpriced++
+			continue
+		}
+		p.Spend += *l.UnitAmount * l.Quantity
+		s.Spend += *l.UnitAmount * l.Quantity
+	}
+
+	top := make([]PartUsage, 0, len(parts))
+	for _, p := range parts {
+		p.Orders = len(p.orders)
+		p.Spend = roundMoney(p.Spend)
+		top = append(top, p.PartUsage)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Quantity != top[j].Quantity {
+			return top[i].Quantity > top[j].Quantity
+		}
+		return top[i].PartID < top[j].PartID
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+
+	bySupplier := make([]SupplierSpend, 0, len(spend))
+	for _, s := range spend {
+		s.Orders = len(s.orders)
+		s.Spend = roundMoney(s.Spend)
+		bySupplier = append(bySupplier, s.SupplierSpend)
+	}
+	sort.Slice(bySupplier, func(i, j int) bool {
+		a, b := bySupplier[i], bySupplier[j]
+		if a.Month != b.Month {
+			return a.Month > b.Month
+		}
+		if a.Spend != b.Spend {
+			return a.Spend > b.Spend
+		}
+		return a.SupplierID < b.SupplierID
+	})
+	return top, bySupplier
+}
+
+// summarizeReceipts averages order-to-receipt time per month of receipt, newest first,
+// and over all spans. Spans received before they were ordered are ignored.
+func summarizeReceipts(spans []receiptSpan) ([]ReceiptTime, ReceiptTime) {
+	type acc struct {
+		rows int
+		secs int64
+	}
+	byMonth := map[string]*acc{}
+	var all acc
+	for _, s := range spans {
+		if s.ReceivedAt < s.OrderedAt {
+			continue
+		}
+		m := usageMonth(s.ReceivedAt)
+		a := byMonth[m]
+		if a == nil {
+			a = &acc{}
+			byMonth[m] = a
+		}
+		a.rows++
+		a.secs += s.ReceivedAt - s.OrderedAt
+		all.rows++
+		all.secs += s.ReceivedAt - s.OrderedAt
+	}
+	days := func(a acc) float64 {
+		if a.rows == 0 {
+			return 0
+		}
+		return math.Round(float64(a.secs)/float64(a.rows)/86400*10) / 10
+	}
+	out := make([]ReceiptTime, 0, len(byMonth))
+	for m, a := range byMonth {
+		out = append(out, ReceiptTime{Month: m, Rows: a.rows, AverageDays: days(*a)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Month > out[j].Month })
+	return out, ReceiptTime{Rows: all.rows, AverageDays: days(all)}
+}
+
+// GetUsageReport builds the owner's usage report from purchase orders raised (and not
+// deleted in Xero) and shopping list rows received since since. Lines of purchase
+// orders in a foreign currency are converted at the rate they were raised at.
+func GetUsageReport(ctx context.Context, dbURL, ownerID string, since time.Time) (*UsageReport, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT po.id, po.created_at, po.contact_account, COALESCE(s.supplier_name, ''),
+       l.item_id, COALESCE(p.name, ''), l.quantity::float8, (l.unit_amount / COALESCE(po.currency_rate, 1))::float8
+FROM purchase_order_lines l
+JOIN purchase_orders po ON po.id = l.purchase_order_id
+LEFT JOIN suppliers s ON s.supplier_id = po.contact_account
+LEFT JOIN parts p ON p.part_id = l.item_id
+WHERE po.owner_id = $1 AND po.created_at >= $2 AND po.xero_deleted_at IS NULL
+`, ownerID, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("query purchase order lines: %w", err)
+	}
+	var lines []usageLine
+	for rows.Next() {
+		var l usageLine
+		if err := rows.Scan(&l.POID, &l.CreatedAt, &l.SupplierID, &l.SupplierName, &l.PartID, &l.PartName, &l.Quantity, &l.UnitAmount); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan purchase order line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query purchase order lines: %w", err)
+	}
+
+	rows, err = pool.Query(ctx, `
+SELECT ordered_at, received_at FROM shopping_list
+WHERE owner_id = $1 AND received AND ordered_at IS NOT NULL AND received_at >= $2
+`, ownerID, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+	var spans []receiptSpan
+	for rows.Next() {
+		var s receiptSpan
+		if err := rows.Scan(&s.OrderedAt, &s.ReceivedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan shopping_list: %w", err)
+		}
+		spans = append(spans, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query shopping_list: %w", err)
+	}
+
+	report := &UsageReport{Since: since.Unix()}
+	report.TopParts, report.SupplierSpend = summarizeUsage(lines, maxTopParts)
+	report.Receipts, report.Receipt = summarizeReceipts(spans)
+	return report, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeUsage(t *testing.T) {
+	t.Parallel()
+	mar := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC).Unix()
+	apr := time.Date(2026, 4, 2, 9, 0, 0, 0, time.UTC).Unix()
+	price := func(v float64) *float64 { return &v }
+	lines := []usageLine{
+		{POID: 1, CreatedAt: mar, SupplierID: "ACME", SupplierName: "Acme", PartID: "BOLT", PartName: "Bolt", Quantity: 100, UnitAmount: price(0.1)},
+		{POID: 1, CreatedAt: mar, SupplierID: "ACME", SupplierName: "Acme", PartID: "NUT", PartName: "Nut", Quantity: 50},
+		{POID: 2, CreatedAt: mar, SupplierID: "FAST", SupplierName: "Fasteners", PartID: "BOLT", PartName: "Bolt", Quantity: 20, UnitAmount: price(0.12)},
+		{POID: 3, CreatedAt: apr, SupplierID: "ACME", SupplierName: "Acme", PartID: "CLIP", PartName: "Clip", Quantity: 50, UnitAmount: price(1.5)},
+	}
+
+	top, spend := summarizeUsage(lines, 2)
+	wantTop := []PartUsage{
+		{PartID: "BOLT", Name: "Bolt", Quantity: 120, Orders: 2, Spend: 12.4},
+		// CLIP and NUT tie on quantity; ordered by part
+		{PartID: "CLIP", Name: "Clip", Quantity: 50, Orders: 1, Spend: 75},
+	}
+	if !reflect.DeepEqual(top, wantTop) {
+		t.Fatalf("top parts = %+v, want %+v", top, wantTop)
+	}
+	wantSpend := []SupplierSpend{
+		{Month: "2026-04", SupplierID: "ACME", SupplierName: "Acme", Orders: 1, Spend: 75},
+		{Month: "2026-03", SupplierID: "ACME", SupplierName: "Acme", Orders: 1, Spend: 10, Unpriced: 1},
+		{Month: "2026-03", SupplierID: "FAST", SupplierName: "Fasteners", Orders: 1, Spend: 2.4},
+	}
+	if !reflect.DeepEqual(spend, wantSpend) {
+		t.Fatalf("supplier spend = %+v, want %+v", spend, wantSpend)
+	}
+}
+
+func TestSummarizeReceipts(t *testing.T) {
+	t.Parallel()
+	day := int64(86400)
+	ordered := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Unix()
+	months, all := summarizeReceipts([]receiptSpan{
+		{OrderedAt: ordered, ReceivedAt: ordered + 4*day},
+		{OrderedAt: ordered, ReceivedAt: ordered + 6*day},
+		{OrderedAt: ordered, ReceivedAt: ordered + 35*day}, // April
+		{OrderedAt: ordered, ReceivedAt: ordered - day},    // clock skew: ignored
+	})
+	want := []ReceiptTime{
+		{Month: "2026-04", Rows: 1, AverageDays: 35},
+		{Month: "2026-03", Rows: 2, AverageDays: 5},
+	}
+	if !reflect.DeepEqual(months, want) {
+		t.Fatalf("months = %+v, want %+v", months, want)
+	}
+	if all.Rows != 3 || all.AverageDays != 15 {
+		t.Fatalf("overall = %+v, want 3 rows averaging 15 days", all)
+	}
+}
+
+func TestGetUsageReport_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := GetUsageReport(context.Background(), "", "o", time.Now()); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
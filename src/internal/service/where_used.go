@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// whereUsedMaxDepth bounds the upward walk (the invoice BOM resolver's default).
+const whereUsedMaxDepth = DefaultBOMMaxDepth
+
+// WhereUsedPath is one route from a top-level assembly down to the part.
+type WhereUsedPath struct {
+	Items    []string `json:"items"`    // top-level assembly first, part last
+	Quantity float64  `json:"quantity"` // parts per one top-level assembly along this route
+}
+
+// WhereUsedAssembly is a top-level assembly that contains the part.
+type WhereUsedAssembly struct {
+	AssemblyID string          `json:"assembly_id"`
+	Quantity   float64         `json:"quantity"` // total parts per one assembly (all routes)
+	Paths      []WhereUsedPath `json:"paths"`
+}
+
+// whereUsedRow is one upward path ending at a top-level assembly. Path runs from the
+// part up to the assembly, as built by the recursive query.
+type whereUsedRow struct {
+	AssemblyID string
+	Quantity   float64
+	Path       []string
+}
+
+// whereUsedSQL walks workspace $3's parent_child upward from the part. Cycles are
+// detected with the path array and dropped; only rows ending at an item with no parent
+// are returned.
+const whereUsedSQL = `
+WITH RECURSIVE up AS (
+  SELECT pc.parent_id, pc.quantity::float8 AS qty, 1 AS depth,
+         ARRAY[pc.child_id, pc.parent_id] AS path,
+         pc.parent_id = pc.child_id AS is_cycle
+  FROM parent_child pc
+  WHERE pc.workspace_id = $3 AND pc.child_id = $1
+  UNION ALL
+  SELECT pc.parent_id, up.qty * pc.quantity::float8, up.depth + 1,
+         up.path || pc.parent_id,
+         pc.parent_id = ANY(up.path)
+  FROM up
+  JOIN parent_child pc ON pc.workspace_id = $3 AND pc.child_id = up.parent_id
+  WHERE NOT up.is_cycle AND up.depth < $2
+)
+SELECT parent_id, qty, path
+FROM up
+WHERE NOT is_cycle
+  AND NOT EXISTS (SELECT 1 FROM parent_child p2 WHERE p2.workspace_id = $3 AND p2.child_id = up.parent_id)
+ORDER BY parent_id, path
+`
+
+// WhereUsed lists every top-level assembly that uses itemID, directly or through
+// sub-assemblies, with the quantity needed per assembly, in the owner's parts lists.
+func WhereUsed(ctx context.Context, dbURL, ownerID, itemID string) ([]WhereUsedAssembly, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, whereUsedSQL, itemID, whereUsedMaxDepth, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query where used: %w", err)
+	}
+	defer rows.Close()
+
+	var found []whereUsedRow
+	for rows.Next() {
+		var r whereUsedRow
+		if err := rows.Scan(&r.AssemblyID, &r.Quantity, &r.Path); err != nil {
+			return nil, fmt.Errorf("scan where used: %w", err)
+		}
+		found = append(found, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query where used: %w", err)
+	}
+	return groupWhereUsed(found), nil
+}
+
+// groupWhereUsed merges paths per top-level assembly, summing quantities.
+func groupWhereUsed(rows []whereUsedRow) []WhereUsedAssembly {
+	byID := map[string]*WhereUsedAssembly{}
+	for _, r := range rows {
+		a, ok := byID[r.AssemblyID]
+		if !ok {
+			a = &WhereUsedAssembly{AssemblyID: r.AssemblyID}
+			byID[r.AssemblyID] = a
+		}
+		items := make([]string, len(r.Path))
+		for i, id := range r.Path {
+			items[len(r.Path)-1-i] = id
+		}
+		a.Quantity = roundQty(a.Quantity + r.Quantity)
+		a.Paths = append(a.Paths, WhereUsedPath{Items: items, Quantity: r.Quantity})
+	}
+	out := make([]WhereUsedAssembly, 0, len(byID))
+	for _, a := range byID {
+		out = append(out, *a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AssemblyID < out[j].AssemblyID })
+	return out
+}
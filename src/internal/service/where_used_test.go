@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGroupWhereUsed(t *testing.T) {
+	t.Parallel()
+	// BOLT is in VAN directly (x2) and via KIT (x4 per kit, 1 kit per van); also in BED (x6)
+	got := groupWhereUsed([]whereUsedRow{
+		{AssemblyID: "VAN", Quantity: 2, Path: []string{"BOLT", "VAN"}},
+		{AssemblyID: "VAN", Quantity: 4, Path: []string{"BOLT", "KIT", "VAN"}},
+		{AssemblyID: "BED", Quantity: 6, Path: []string{"BOLT", "BED"}},
+	})
+	if len(got) != 2 || got[0].AssemblyID != "BED" || got[1].AssemblyID != "VAN" {
+		t.Fatalf("unexpected assemblies: %+v", got)
+	}
+	van := got[1]
+	if van.Quantity != 6 || len(van.Paths) != 2 {
+		t.Fatalf("unexpected VAN usage: %+v", van)
+	}
+	if strings.Join(van.Paths[1].Items, ">") != "VAN>KIT>BOLT" {
+		t.Fatalf("expected path top-down, got %v", van.Paths[1].Items)
+	}
+}
+
+func TestWhereUsed_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := WhereUsed(context.Background(), "", "owner-1", "X"); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
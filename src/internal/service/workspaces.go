@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Workspace member roles. Owners manage the members and the join code; every member
+// works with the workspace's data alike.
+const (
+	WorkspaceOwner  = "owner"
+	WorkspaceMember = "member"
+)
+
+// personalWorkspaceName names the workspace every user gets on first sign-in. Its id
+// is the user's id, so the data of users from before workspaces stays theirs.
+const personalWorkspaceName = "Personal"
+
+// maxWorkspaceNameLen bounds a workspace name.
+const maxWorkspaceNameLen = 80
+
+// Workspace is a workspace as one member sees it: Role is theirs.
+type Workspace struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	JoinCode  string `json:"join_code,omitempty"` // "" when joining is closed
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Personal reports whether this is the member's personal workspace.
+func (w Workspace) Personal(userID string) bool { return w.ID == userID }
+
+// WorkspaceMemberInfo is one member of a workspace.
+type WorkspaceMemberInfo struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// NormalizeWorkspaceName trims a workspace name and checks its length.
+func NormalizeWorkspaceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", invalid("name", "workspace name is required")
+	}
+	if len(name) > maxWorkspaceNameLen {
+		return "", invalid("name", "workspace name is longer than %d characters", maxWorkspaceNameLen)
+	}
+	return name, nil
+}
+
+// NewWorkspaceJoinCode returns a random code for joining a workspace.
+func NewWorkspaceJoinCode() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("join code: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+const workspaceColumns = `w.id, w.name, m.role, COALESCE(w.join_code, ''), w.created_at`
+
+func scanWorkspace(row pgx.Row) (Workspace, error) {
+	var w Workspace
+	err := row.Scan(&w.ID, &w.Name, &w.Role, &w.JoinCode, &w.CreatedAt)
+	return w, err
+}
+
+// ResolveWorkspace returns the workspace a request from userID works in: requested
+// when the user is a member of it, else their personal workspace, which is created
+// on first use (with email on the membership, for the members list).
+func ResolveWorkspace(ctx context.Context, dbURL, userID, email, requested string) (Workspace, error) {
+	if dbURL == "" {
+		return Workspace{}, fmt.Errorf("db url missing")
+	}
+	if userID == "" {
+		return Workspace{}, fmt.Errorf("user id missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	member := func(id string) (Workspace, error) {
+		return scanWorkspace(pool.QueryRow(ctx, `
+SELECT `+workspaceColumns+`
+FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+WHERE w.id = $1 AND m.user_id = $2
+`, id, userID))
+	}
+	if requested != "" && requested != userID {
+		w, err := member(requested)
+		if err == nil {
+			return w, nil
+		}
+		if err != pgx.ErrNoRows {
+			return Workspace{}, fmt.Errorf("query workspace: %w", err)
+		}
+		// no longer a member: fall back to the personal workspace
+	}
+	w, err := member(userID)
+	if err == nil {
+		return w, nil
+	}
+	if err != pgx.ErrNoRows {
+		return Workspace{}, fmt.Errorf("query workspace: %w", err)
+	}
+
+	// first sign-in: create the personal workspace (concurrent requests may race)
+	err = runTx(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `INSERT INTO workspaces (id, name) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`, userID, personalWorkspaceName); err != nil {
+			return fmt.Errorf("insert workspace: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+INSERT INTO workspace_members (workspace_id, user_id, email, role) VALUES ($1, $1, $2, $3)
+ON CONFLICT (workspace_id, user_id) DO NOTHING
+`, userID, email, WorkspaceOwner); err != nil {
+			return fmt.Errorf("insert workspace member: %w", err)
+		}
+		w, err = scanWorkspace(tx.QueryRow(ctx, `
+SELECT `+workspaceColumns+`
+FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+WHERE w.id = $1 AND m.user_id = $1
+`, userID))
+		if err != nil {
+			return fmt.Errorf("query workspace: %w", err)
+		}
+		return nil
+	})
+	return w, err
+}
+
+// ListWorkspaces returns the workspaces userID is a member of, their personal one
+// first, then by name.
+func ListWorkspaces(ctx context.Context, dbURL, userID string) ([]Workspace, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT `+workspaceColumns+`
+FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+WHERE m.user_id = $1
+ORDER BY w.id = $1 DESC, lower(w.name), w.id
+`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Workspace
+	for rows.Next() {
+		w, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan workspace: %w", err)
+		}
+		out = append(out, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query workspaces: %w", err)
+	}
+	return out, nil
+}
+
+// CreateWorkspace creates a workspace with userID as its owner and returns its id.
+func CreateWorkspace(ctx context.Context, dbURL, userID, email, name string) (string, error) {
+	if dbURL == "" {
+		return "", fmt.Errorf("db url missing")
+	}
+	name, err := NormalizeWorkspaceName(name)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("workspace id: %w", err)
+	}
+	id := "ws-" + hex.EncodeToString(b)
+
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return "", fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	err = runTx(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `INSERT INTO workspaces (id, name) VALUES ($1, $2)`, id, name); err != nil {
+			return fmt.Errorf("insert workspace: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO workspace_members (workspace_id, user_id, email, role) VALUES ($1, $2, $3, $4)`, id, userID, email, WorkspaceOwner); err != nil {
+			return fmt.Errorf("insert workspace member: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// SetWorkspaceJoinCode replaces the workspace's join code; "" closes joining.
+func SetWorkspaceJoinCode(ctx context.Context, dbURL, workspaceID, code string) error {
+	if dbURL == "" {
+		return fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, `UPDATE workspaces SET join_code = NULLIF($2, '') WHERE id = $1`, workspaceID, code); err != nil {
+		return fmt.Errorf("update workspace: %w", err)
+	}
+	return nil
+}
+
+// JoinWorkspace makes userID a member of the workspace whose join code is code and
+// returns it. ok is false when no workspace has that code.
+func JoinWorkspace(ctx context.Context, dbURL, code, userID, email string) (w Workspace, ok bool, err error) {
+	if dbURL == "" {
+		return Workspace{}, false, fmt.Errorf("db url missing")
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return Workspace{}, false, nil
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return Workspace{}, false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	var id string
+	err = pool.QueryRow(ctx, `SELECT id FROM workspaces WHERE join_code = $1`, code).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return Workspace{}, false, nil
+	}
+	if err != nil {
+		return Workspace{}, false, fmt.Errorf("query workspace: %w", err)
+	}
+	if _, err := pool.Exec(ctx, `
+INSERT INTO workspace_members (workspace_id, user_id, email, role) VALUES ($1, $2, $3, $4)
+ON CONFLICT (workspace_id, user_id) DO NOTHING
+`, id, userID, email, WorkspaceMember); err != nil {
+		return Workspace{}, false, fmt.Errorf("insert workspace member: %w", err)
+	}
+	w, err = scanWorkspace(pool.QueryRow(ctx, `
+SELECT `+workspaceColumns+`
+FROM workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+WHERE w.id = $1 AND m.user_id = $2
+`, id, userID))
+	if err != nil {
+		return Workspace{}, false, fmt.Errorf("query workspace: %w", err)
+	}
+	return w, true, nil
+}
+
+// ListWorkspaceMembers returns a workspace's members, owners first, then by email.
+func ListWorkspaceMembers(ctx context.Context, dbURL, workspaceID string) ([]WorkspaceMemberInfo, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `
+SELECT user_id, email, role, created_at
+FROM workspace_members
+WHERE workspace_id = $1
+ORDER BY role = 'owner' DESC, lower(email), user_id
+`, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query workspace_members: %w", err)
+	}
+	defer rows.Close()
+
+	var out []WorkspaceMemberInfo
+	for rows.Next() {
+		var m WorkspaceMemberInfo
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Role, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan workspace_members: %w", err)
+		}
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query workspace_members: %w", err)
+	}
+	return out, nil
+}
+
+// RemoveWorkspaceMember takes userID out of the workspace. Owners cannot be
+// removed; removed reports whether a member was.
+func RemoveWorkspaceMember(ctx context.Context, dbURL, workspaceID, userID string) (removed bool, err error) {
+	if dbURL == "" {
+		return false, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return false, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	tag, err := pool.Exec(ctx, `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2 AND role <> 'owner'`, workspaceID, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete workspace member: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -4,23 +4,63 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// XeroConnection is the persisted record.
-type XeroConnection struct {
-	ID           string
-	OwnerID      string
-	TenantID     string
-	AccessToken  string
-	RefreshToken string
-	ExpiresAt    int64
-	CreatedAt    int64
-	UpdatedAt    int64
+// ConnectionInfo is the token-free view of a stored Xero connection. It is safe to
+// hand to templates or encode as JSON; use a TokenManager to get credentials for it.
+type ConnectionInfo struct {
+	ID         string `json:"id"`
+	OwnerID    string `json:"owner_id"`
+	TenantID   string `json:"tenant_id"`
+	TenantName string `json:"tenant_name"`
+	ExpiresAt  int64  `json:"expires_at"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+	// HasRefreshToken reports whether a refresh token is stored (not the token itself).
+	HasRefreshToken bool `json:"has_refresh_token"`
+}
+
+// connectionInfoColumns selects every ConnectionInfo field and no secrets.
+const connectionInfoColumns = `id, owner_id, tenant_id, tenant_name, COALESCE(expires_at, 0), COALESCE(created_at, 0), COALESCE(updated_at, 0), refresh_token <> ''`
+
+func scanConnectionInfo(rows pgx.Rows) (ConnectionInfo, error) {
+	var c ConnectionInfo
+	err := rows.Scan(&c.ID, &c.OwnerID, &c.TenantID, &c.TenantName, &c.ExpiresAt, &c.CreatedAt, &c.UpdatedAt, &c.HasRefreshToken)
+	return c, err
 }
 
 // GetConnectionsForOwner returns stored connections for an owner.
-func GetConnectionsForOwner(ctx context.Context, dbURL, ownerID string) ([]XeroConnection, error) {
+func GetConnectionsForOwner(ctx context.Context, dbURL, ownerID string) ([]ConnectionInfo, error) {
+	if dbURL == "" {
+		return nil, fmt.Errorf("db url missing")
+	}
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect db: %w", err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `SELECT `+connectionInfoColumns+` FROM xero_connections WHERE owner_id = $1 ORDER BY created_at, id`, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("query connections: %w", err)
+	}
+	defer rows.Close()
+
+	var out []ConnectionInfo
+	for rows.Next() {
+		c, err := scanConnectionInfo(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan conn: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ListAllConnections returns every stored connection (used by background jobs).
+func ListAllConnections(ctx context.Context, dbURL string) ([]ConnectionInfo, error) {
 	if dbURL == "" {
 		return nil, fmt.Errorf("db url missing")
 	}
@@ -30,19 +70,19 @@ func GetConnectionsForOwner(ctx context.Context, dbURL, ownerID string) ([]XeroC
 	}
 	defer pool.Close()
 
-	rows, err := pool.Query(ctx, `SELECT id, owner_id, tenant_id, access_token, refresh_token, expires_at, created_at, updated_at FROM xero_connections WHERE owner_id = $1`, ownerID)
+	rows, err := pool.Query(ctx, `SELECT `+connectionInfoColumns+` FROM xero_connections ORDER BY owner_id`)
 	if err != nil {
 		return nil, fmt.Errorf("query connections: %w", err)
 	}
 	defer rows.Close()
 
-	var out []XeroConnection
+	var out []ConnectionInfo
 	for rows.Next() {
-		var xc XeroConnection
-		if err := rows.Scan(&xc.ID, &xc.OwnerID, &xc.TenantID, &xc.AccessToken, &xc.RefreshToken, &xc.ExpiresAt, &xc.CreatedAt, &xc.UpdatedAt); err != nil {
+		c, err := scanConnectionInfo(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan conn: %w", err)
 		}
-		out = append(out, xc)
+		out = append(out, c)
 	}
-	return out, nil
+	return out, rows.Err()
 }
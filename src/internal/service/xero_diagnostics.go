@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/cache"
+)
+
+// Kinds of cached Xero lookups XeroCacheFreshness reports on.
+const (
+	cacheKindItems    = "items"
+	cacheKindContacts = "contacts"
+)
+
+// cacheFilledKey is where markCacheFilled records when a tenant's kind lookups were
+// last fetched from Xero.
+func cacheFilledKey(kind, tenantID string) string {
+	return "xero:filled:" + kind + ":" + tenantID
+}
+
+// markCacheFilled records that kind lookups of tenantID were just fetched from Xero
+// into c. The mark expires with the entries (ttl), so no mark means nothing fetched
+// is cached any more.
+func markCacheFilled(ctx context.Context, c cache.Cache, kind, tenantID string, ttl time.Duration) {
+	if err := cache.SetJSON(ctx, c, map[string]int64{cacheFilledKey(kind, tenantID): time.Now().Unix()}, ttl); err != nil {
+		cacheMiss("mark "+kind+" filled", err)
+	}
+}
+
+// XeroCacheStatus is how fresh one kind of cached Xero lookup is for a tenant.
+type XeroCacheStatus struct {
+	Name string
+	TTL  time.Duration // how long each entry is kept
+	// FilledAt is when entries were last fetched from Xero; zero when none of them is
+	// cached any more
+	FilledAt time.Time
+}
+
+// XeroCacheFreshness reports when the tenant's Xero item and contact lookups were
+// last fetched into the shared cache (SharedCache). It fails only when the cache
+// cannot be read.
+func XeroCacheFreshness(ctx context.Context, tenantID string) ([]XeroCacheStatus, error) {
+	out := []XeroCacheStatus{
+		{Name: cacheKindItems, TTL: itemCacheTTL},
+		{Name: cacheKindContacts, TTL: contactIDCacheTTL},
+	}
+	keys := make([]string, len(out))
+	for i, s := range out {
+		keys[i] = cacheFilledKey(s.Name, tenantID)
+	}
+	filled, err := cache.GetJSON[int64](ctx, sharedCache, keys...)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		if at, ok := filled[keys[i]]; ok {
+			out[i].FilledAt = time.Unix(at, 0)
+		}
+	}
+	return out, nil
+}
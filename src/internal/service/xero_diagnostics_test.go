@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXeroCacheFreshness(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	const tenant = "tenant-freshness"
+
+	got, err := XeroCacheFreshness(ctx, tenant)
+	if err != nil || len(got) != 2 || got[0].Name != "items" || got[1].Name != "contacts" {
+		t.Fatalf("freshness = %+v, %v", got, err)
+	}
+	if !got[0].FilledAt.IsZero() || !got[1].FilledAt.IsZero() || got[0].TTL != itemCacheTTL {
+		t.Fatalf("nothing cached yet, got %+v", got)
+	}
+
+	before := time.Now().Truncate(time.Second)
+	markCacheFilled(ctx, SharedCache(), cacheKindContacts, tenant, time.Minute)
+	got, err = XeroCacheFreshness(ctx, tenant)
+	if err != nil || !got[0].FilledAt.IsZero() || got[1].FilledAt.Before(before) {
+		t.Fatalf("contacts filled: %+v, %v", got, err)
+	}
+}
+
+func TestListAPICallErrors_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := ListAPICallErrors(context.Background(), "", "", 20); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
@@ -5,11 +5,18 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/ory/dockertest/v3"
 )
@@ -78,7 +85,8 @@ CREATE TABLE IF NOT EXISTS xero_connections (
   refresh_token TEXT NOT NULL,
   expires_at BIGINT,
   created_at BIGINT,
-  updated_at BIGINT
+  updated_at BIGINT,
+  tenant_name TEXT NOT NULL DEFAULT ''
 );
 
 CREATE TABLE IF NOT EXISTS shopping_list (
@@ -86,6 +94,8 @@ CREATE TABLE IF NOT EXISTS shopping_list (
   item_id TEXT NOT NULL,
   quantity INTEGER NOT NULL,
   ordered BOOLEAN DEFAULT FALSE,
+  owner_id TEXT,
+  source_invoice TEXT,
   created_at BIGINT,
   updated_at BIGINT
 );
@@ -164,6 +174,186 @@ func TestUpsertConnection_InsertAndUpdate(t *testing.T) {
 	}
 }
 
+func TestListConnectionSummaries_PagesFiltersAndHidesTokens(t *testing.T) {
+	// do not run in parallel due to docker container usage
+	dbURL, cleanup := setupTestPostgresXero(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, tid := range []string{"t-a", "t-b", "t-c"} {
+		if err := UpsertConnection(ctx, dbURL, "owner-1", tid, "secret-access", "secret-refresh", 3600); err != nil {
+			t.Fatalf("UpsertConnection: %v", err)
+		}
+	}
+	if err := UpsertConnection(ctx, dbURL, "owner-2", "t-z", "x", "y", 3600); err != nil {
+		t.Fatalf("UpsertConnection: %v", err)
+	}
+	if err := SetConnectionTenantName(ctx, dbURL, "owner-1", "t-b", "Acme Bikes"); err != nil {
+		t.Fatalf("SetConnectionTenantName: %v", err)
+	}
+
+	page, err := ListConnectionSummaries(ctx, dbURL, "owner-1", ConnectionListOptions{Page: 1, PerPage: 2})
+	if err != nil {
+		t.Fatalf("ListConnectionSummaries: %v", err)
+	}
+	if page.Total != 3 || len(page.Connections) != 2 {
+		t.Fatalf("expected 2 of 3, got %d of %d", len(page.Connections), page.Total)
+	}
+	for _, c := range page.Connections {
+		if c.Status != ConnectionActive {
+			t.Fatalf("expected active status, got %q", c.Status)
+		}
+	}
+	b, err := json.Marshal(page)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Fatalf("tokens leaked into JSON: %s", b)
+	}
+
+	page, err = ListConnectionSummaries(ctx, dbURL, "owner-1", ConnectionListOptions{Page: 1, PerPage: 10, Query: "acme"})
+	if err != nil {
+		t.Fatalf("ListConnectionSummaries(q): %v", err)
+	}
+	if page.Total != 1 || page.Connections[0].TenantName != "Acme Bikes" {
+		t.Fatalf("unexpected query result: %+v", page)
+	}
+
+	page, err = ListConnectionSummaries(ctx, dbURL, "owner-1", ConnectionListOptions{Page: 1, PerPage: 10, Status: ConnectionExpired})
+	if err != nil {
+		t.Fatalf("ListConnectionSummaries(status): %v", err)
+	}
+	if page.Total != 0 || len(page.Connections) != 0 {
+		t.Fatalf("expected no expired connections, got %+v", page)
+	}
+}
+
+func TestTokenManager_CredentialsForOwner(t *testing.T) {
+	// do not run in parallel due to docker container usage
+	dbURL, cleanup := setupTestPostgresXero(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tm := NewTokenManager(dbURL, nil, "id", "secret")
+	if _, err := tm.CredentialsForOwner(ctx, "nobody"); !errors.Is(err, ErrNoConnection) {
+		t.Fatalf("expected ErrNoConnection, got %v", err)
+	}
+
+	// far from expiry -> stored access token is returned without calling Xero
+	if err := UpsertConnection(ctx, dbURL, "owner-1", "tenant-1", "access-1", "refresh-1", 3600); err != nil {
+		t.Fatalf("UpsertConnection: %v", err)
+	}
+	creds, err := tm.CredentialsForOwner(ctx, "owner-1")
+	if err != nil {
+		t.Fatalf("CredentialsForOwner: %v", err)
+	}
+	if creds.AccessToken != "access-1" || creds.TenantID != "tenant-1" {
+		t.Fatalf("unexpected creds: tenant=%s", creds.TenantID)
+	}
+
+	conns, err := GetConnectionsForOwner(ctx, dbURL, "owner-1")
+	if err != nil || len(conns) != 1 || !conns[0].HasRefreshToken {
+		t.Fatalf("unexpected connections: %+v err=%v", conns, err)
+	}
+	for _, v := range []any{conns, creds} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		if strings.Contains(string(b), "access-1") || strings.Contains(string(b), "refresh-1") {
+			t.Fatalf("tokens leaked into JSON: %s", b)
+		}
+	}
+}
+
+func TestTokenManager_ConsentRevoked(t *testing.T) {
+	// do not run in parallel due to docker container usage
+	dbURL, cleanup := setupTestPostgresXero(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+	}))
+	defer ts.Close()
+	xc := xero.NewClient(ts.Client(), ts.URL)
+	xc.Retry = &xero.NoRetry
+
+	// already expired -> refresh is attempted and rejected
+	if err := UpsertConnection(ctx, dbURL, "owner-1", "tenant-1", "access-1", "refresh-1", -60); err != nil {
+		t.Fatalf("UpsertConnection: %v", err)
+	}
+	tm := NewTokenManager(dbURL, xc, "id", "secret")
+	if _, err := tm.CredentialsForOwner(ctx, "owner-1"); !errors.Is(err, ErrConsentRevoked) {
+		t.Fatalf("expected ErrConsentRevoked, got %v", err)
+	}
+	if _, err := tm.CredentialsForOwner(ctx, "owner-1"); !errors.Is(err, ErrNoConnection) {
+		t.Fatalf("expected the connection to be removed, got %v", err)
+	}
+}
+
+func TestTokenManager_ConcurrentRefreshSpendsTokenOnce(t *testing.T) {
+	// do not run in parallel due to docker container usage
+	dbURL, cleanup := setupTestPostgresXero(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// like Xero, each refresh token works once
+	var mu sync.Mutex
+	spent := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		rt := r.PostForm.Get("refresh_token")
+		if spent[rt] {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		spent[rt] = true
+		_, _ = w.Write([]byte(`{"access_token":"access-2","refresh_token":"refresh-2","expires_in":1800}`))
+	}))
+	defer ts.Close()
+	xc := xero.NewClient(ts.Client(), ts.URL)
+	xc.Retry = &xero.NoRetry
+
+	if err := UpsertConnection(ctx, dbURL, "owner-1", "tenant-1", "access-1", "refresh-1", -60); err != nil {
+		t.Fatalf("UpsertConnection: %v", err)
+	}
+	// one manager per simulated instance
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	tokens := make([]string, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			creds, err := NewTokenManager(dbURL, xc, "id", "secret").CredentialsForOwner(ctx, "owner-1")
+			errs[i], tokens[i] = err, creds.AccessToken
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil || tokens[i] != "access-2" {
+			t.Fatalf("instance %d: token %q, err %v", i, tokens[i], err)
+		}
+	}
+	if len(spent) != 1 {
+		t.Fatalf("refresh tokens used = %v, want only refresh-1", spent)
+	}
+}
+
 func TestAddShoppingListEntry_InsertRows(t *testing.T) {
 	// do not run in parallel due to docker container usage
 	dbURL, cleanup := setupTestPostgresXero(t)
@@ -173,14 +363,14 @@ func TestAddShoppingListEntry_InsertRows(t *testing.T) {
 	defer cancel()
 
 	// empty db url -> error
-	if err := AddShoppingListEntry(ctx, "", "P-1", 1, false); err == nil {
+	if err := AddShoppingListEntry(ctx, "", "owner-1", "P-1", "", 1, false); err == nil {
 		t.Fatal("expected error for empty db url")
 	}
 
-	if err := AddShoppingListEntry(ctx, dbURL, "P-1", 2, false); err != nil {
+	if err := AddShoppingListEntry(ctx, dbURL, "owner-1", "P-1", "", 2, false); err != nil {
 		t.Fatalf("AddShoppingListEntry failed: %v", err)
 	}
-	if err := AddShoppingListEntry(ctx, dbURL, "P-2", 3, true); err != nil {
+	if err := AddShoppingListEntry(ctx, dbURL, "owner-1", "P-2", "", 3, true); err != nil {
 		t.Fatalf("AddShoppingListEntry failed: %v", err)
 	}
 
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5"
+)
+
+// WebhookReceipt is what RecordWebhookEvents made of one webhook delivery.
+type WebhookReceipt struct {
+	Fresh []xero.WebhookEvent // events not handled before, to act on
+	Stale int                 // events older than the max age (or undated), ignored
+	Seen  int                 // events already handled, ignored
+}
+
+// splitStaleWebhookEvents returns the events dated within maxAge of now and how many
+// were not.
+func splitStaleWebhookEvents(events []xero.WebhookEvent, now time.Time, maxAge time.Duration) ([]xero.WebhookEvent, int) {
+	var recent []xero.WebhookEvent
+	stale := 0
+	for _, e := range events {
+		if e.EventDateUTC.IsZero() || now.Sub(e.EventDateUTC.Time) > maxAge {
+			stale++
+			continue
+		}
+		recent = append(recent, e)
+	}
+	return recent, stale
+}
+
+// RecordWebhookEvents remembers the events of a verified webhook delivery in
+// xero_webhook_events and returns those to act on: events older than maxAge, and
+// events already recorded by an earlier delivery (Xero's retries, or a replay), are
+// left out. Events are remembered until they are older than maxAge. On error none of
+// the delivery's events are remembered.
+func RecordWebhookEvents(ctx context.Context, dbURL string, events []xero.WebhookEvent, maxAge time.Duration) (WebhookReceipt, error) {
+	var rec WebhookReceipt
+	if dbURL == "" {
+		return rec, fmt.Errorf("db url missing")
+	}
+	recent, stale := splitStaleWebhookEvents(events, time.Now(), maxAge)
+	rec.Stale = stale
+	if len(recent) == 0 {
+		return rec, nil
+	}
+	// one transaction, so a failed insert forgets the whole delivery and Xero's retry
+	// of it is acted on in full
+	err := WithTx(ctx, dbURL, func(tx pgx.Tx) error {
+		rec.Fresh, rec.Seen = nil, 0
+		for _, e := range recent {
+			tag, err := tx.Exec(ctx, `
+INSERT INTO xero_webhook_events (event_id, tenant_id, event_category, resource_id, event_date, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (event_id) DO NOTHING
+`, e.ID(), e.TenantID, e.EventCategory, e.ResourceID, e.EventDateUTC.Unix(), e.EventDateUTC.Add(maxAge).Unix())
+			if err != nil {
+				return fmt.Errorf("insert xero_webhook_event: %w", err)
+			}
+			if tag.RowsAffected() == 0 {
+				rec.Seen++
+				continue
+			}
+			rec.Fresh = append(rec.Fresh, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return WebhookReceipt{Stale: stale}, err
+	}
+	return rec, nil
+}
+
+// PollTenantInvoices runs PollInvoices now for every connection to tenantID, e.g.
+// when a webhook reports one of its invoices changed, and adds up the results. A
+// failed connection does not stop the others; the first error is returned.
+func PollTenantInvoices(ctx context.Context, dbURL string, xc *xero.Client, tokens *TokenManager, tenantID string, lookback time.Duration) (InvoicePollResult, error) {
+	var total InvoicePollResult
+	conns, err := ListAllConnections(ctx, dbURL)
+	if err != nil {
+		return total, err
+	}
+	var firstErr error
+	for _, c := range conns {
+		if c.TenantID != tenantID {
+			continue
+		}
+		creds, err := tokens.Credentials(ctx, c)
+		if err == nil {
+			var res InvoicePollResult
+			res, err = PollInvoices(ctx, dbURL, xc, c.OwnerID, creds, lookback)
+			total.Seen += res.Seen
+			total.Ready += res.Ready
+			total.Problems += res.Problems
+			total.Removed += res.Removed
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("owner %s: %w", c.OwnerID, err)
+		}
+	}
+	return total, firstErr
+}
@@ -0,0 +1,67 @@
+//go:build integration
+// +build integration
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestRecordWebhookEvents_FailedInsertRecordsNothing(t *testing.T) {
+	t.Parallel()
+
+	dbURL, cleanup := setupTestPostgresShopping(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		t.Fatalf("connect db: %v", err)
+	}
+	defer pool.Close()
+	// as in migration 000042, with a check that fails the second event's insert
+	if _, err := pool.Exec(ctx, `
+CREATE TABLE xero_webhook_events (
+  event_id TEXT PRIMARY KEY,
+  tenant_id TEXT NOT NULL,
+  event_category TEXT NOT NULL,
+  resource_id TEXT NOT NULL CHECK (resource_id <> 'inv-bad'),
+  event_date BIGINT NOT NULL,
+  received_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint,
+  expires_at BIGINT NOT NULL
+);
+`); err != nil {
+		t.Fatalf("create xero_webhook_events: %v", err)
+	}
+
+	at := xero.Timestamp{Time: time.Now().Add(-time.Minute)}
+	good := xero.WebhookEvent{TenantID: "tenant-1", EventCategory: "INVOICE", EventType: "UPDATE", ResourceID: "inv-1", EventDateUTC: at}
+	bad := good
+	bad.ResourceID = "inv-bad"
+
+	if _, err := RecordWebhookEvents(ctx, dbURL, []xero.WebhookEvent{good, bad}, time.Hour); err == nil {
+		t.Fatal("expected the second insert to fail")
+	}
+	var n int
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM xero_webhook_events`).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("%d event(s) remembered after a failed delivery, want 0", n)
+	}
+
+	// the redelivery acts on the event the failed one did not
+	rec, err := RecordWebhookEvents(ctx, dbURL, []xero.WebhookEvent{good}, time.Hour)
+	if err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if len(rec.Fresh) != 1 || rec.Seen != 0 {
+		t.Fatalf("redelivery receipt = %+v, want the event fresh", rec)
+	}
+}
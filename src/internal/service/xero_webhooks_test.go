@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestSplitStaleWebhookEvents(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) xero.Timestamp { return xero.Timestamp{Time: now.Add(-ago)} }
+	events := []xero.WebhookEvent{
+		{ResourceID: "inv-1", EventDateUTC: at(time.Minute)},
+		{ResourceID: "inv-2", EventDateUTC: at(2 * time.Hour)},
+		{ResourceID: "inv-3"},
+		{ResourceID: "inv-4", EventDateUTC: at(time.Hour)},
+	}
+	recent, stale := splitStaleWebhookEvents(events, now, time.Hour)
+	if len(recent) != 2 || recent[0].ResourceID != "inv-1" || recent[1].ResourceID != "inv-4" || stale != 2 {
+		t.Fatalf("recent = %+v, stale = %d", recent, stale)
+	}
+}
+
+func TestRecordWebhookEvents_EmptyDBURL(t *testing.T) {
+	t.Parallel()
+	if _, err := RecordWebhookEvents(context.Background(), "", nil, time.Hour); err == nil || !strings.Contains(err.Error(), "db url missing") {
+		t.Fatalf("expected db url missing error, got %v", err)
+	}
+}
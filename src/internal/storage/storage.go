@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/supabasetoolbox"
+)
+
+// Store persists binary objects (attachments, exports) outside the app container.
+type Store interface {
+	// Put uploads an object under key, replacing any existing object.
+	Put(ctx context.Context, key, contentType string, body io.Reader) error
+	// SignedURL returns a time-limited download URL for key.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewSupabase returns a Store backed by a Supabase Storage bucket.
+// The client should carry the service-role key so uploads bypass storage RLS.
+func NewSupabase(client *supabasetoolbox.Client, bucket string) Store {
+	return &supabaseStore{client: client, bucket: bucket}
+}
+
+type supabaseStore struct {
+	client *supabasetoolbox.Client
+	bucket string
+}
+
+func (s *supabaseStore) Put(ctx context.Context, key, contentType string, body io.Reader) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return s.client.UploadObject(ctx, s.bucket, key, contentType, body)
+}
+
+func (s *supabaseStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	secs := int(ttl / time.Second)
+	if secs <= 0 {
+		secs = 60
+	}
+	return s.client.SignObjectURL(ctx, s.bucket, key, secs)
+}
+
+func (s *supabaseStore) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return s.client.DeleteObject(ctx, s.bucket, key)
+}
+
+// validKey rejects empty keys and path traversal.
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package storage
+
+import "testing"
+
+func TestValidKey(t *testing.T) {
+	t.Parallel()
+	for _, k := range []string{"parts/P-1/a.png", "x"} {
+		if err := validKey(k); err != nil {
+			t.Fatalf("expected %q valid, got %v", k, err)
+		}
+	}
+	for _, k := range []string{"", "/abs", "a/../b", "a//b", "./a"} {
+		if err := validKey(k); err == nil {
+			t.Fatalf("expected %q invalid", k)
+		}
+	}
+}
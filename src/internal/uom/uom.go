@@ -0,0 +1,127 @@
+// Package uom knows the units of measure parts are counted and ordered in, and how
+// precisely a quantity in each is kept. Parts bought by the metre, area or weight
+// have fractional quantities; countable ones (each, sheet, roll) are whole numbers.
+package uom
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Each is the unit of parts counted one by one, and of parts without a unit.
+const Each = "each"
+
+// MaxLen is the longest unit name accepted.
+const MaxLen = 20
+
+// MaxDecimals is the precision of units this package does not know, and the most
+// decimal places Xero keeps on a line quantity.
+const MaxDecimals = 4
+
+// decimals is how many decimal places a quantity in each known unit keeps.
+var decimals = map[string]int{
+	Each:    0,
+	"pair":  0,
+	"set":   0,
+	"pack":  0,
+	"box":   0,
+	"sheet": 0,
+	"roll":  0,
+	"mm":    0,
+	"cm":    1,
+	"m":     2,
+	"m2":    2,
+	"g":     0,
+	"kg":    3,
+	"ml":    0,
+	"l":     2,
+	"h":     2,
+}
+
+// aliases are the other spellings Normalize accepts for a known unit.
+var aliases = map[string]string{
+	"":       Each,
+	"ea":     Each,
+	"pc":     Each,
+	"pcs":    Each,
+	"unit":   Each,
+	"units":  Each,
+	"pairs":  "pair",
+	"sets":   "set",
+	"packs":  "pack",
+	"boxes":  "box",
+	"sheets": "sheet",
+	"rolls":  "roll",
+	"metre":  "m",
+	"metres": "m",
+	"meter":  "m",
+	"meters": "m",
+	"sqm":    "m2",
+	"m²":     "m2",
+	"kgs":    "kg",
+	"litre":  "l",
+	"litres": "l",
+	"liter":  "l",
+	"liters": "l",
+	"hr":     "h",
+	"hrs":    "h",
+	"hours":  "h",
+}
+
+// Normalize returns u lower-cased and trimmed, with known spellings mapped to one
+// name ("Metres" -> "m", "" -> "each"). Units this package does not know are kept.
+func Normalize(u string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if a, ok := aliases[u]; ok {
+		return a
+	}
+	return u
+}
+
+// Decimals is how many decimal places a quantity in u keeps.
+func Decimals(u string) int {
+	if d, ok := decimals[Normalize(u)]; ok {
+		return d
+	}
+	return MaxDecimals
+}
+
+// Whole reports whether u is counted in whole numbers.
+func Whole(u string) bool {
+	return Decimals(u) == 0
+}
+
+// Round rounds q to the nearest quantity u can express.
+func Round(q float64, u string) float64 {
+	p := math.Pow10(Decimals(u))
+	return math.Round(q*p) / p
+}
+
+// Ceil rounds q up to a quantity u can express, e.g. for what has to be ordered.
+// Noise below u's precision (0.1*3 = 0.30000000000000004) does not round up.
+func Ceil(q float64, u string) float64 {
+	p := math.Pow10(Decimals(u))
+	return math.Ceil(math.Round(q*p*1e6)/1e6) / p
+}
+
+// Step is the HTML number input step for quantities in u: "1", "0.1", "0.01", ...
+func Step(u string) string {
+	d := Decimals(u)
+	if d == 0 {
+		return "1"
+	}
+	return "0." + strings.Repeat("0", d-1) + "1"
+}
+
+// Format writes q to at most MaxDecimals places without trailing zeros, followed by
+// the unit unless it is Each: "3", "2.5 m", "0.125 kg". It does not round to what u
+// can express, so per-assembly shares such as half a bolt still show.
+func Format(q float64, u string) string {
+	p := math.Pow10(MaxDecimals)
+	s := strconv.FormatFloat(math.Round(q*p)/p, 'f', -1, 64)
+	if u = Normalize(u); u != Each {
+		s += " " + u
+	}
+	return s
+}
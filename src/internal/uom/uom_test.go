@@ -0,0 +1,45 @@
+package uom
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{"": "each", " EA ": "each", "Metres": "m", "sheets": "sheet", "m²": "m2", "bag": "bag"} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRoundCeilFormat(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		q      float64
+		u      string
+		round  float64
+		ceil   float64
+		format string
+		step   string
+	}{
+		{2.4, "each", 2, 3, "2.4", "1"},
+		{3, "sheets", 3, 3, "3 sheet", "1"},
+		{0.1 * 3, "m", 0.3, 0.3, "0.3 m", "0.01"},
+		{1.2345, "metre", 1.23, 1.24, "1.2345 m", "0.01"},
+		{0.125, "kg", 0.125, 0.125, "0.125 kg", "0.001"},
+		{1.23456, "bag", 1.2346, 1.2346, "1.2346 bag", "0.0001"},
+	}
+	for _, tt := range tests {
+		if got := Round(tt.q, tt.u); got != tt.round {
+			t.Errorf("Round(%v, %q) = %v, want %v", tt.q, tt.u, got, tt.round)
+		}
+		if got := Ceil(tt.q, tt.u); got != tt.ceil {
+			t.Errorf("Ceil(%v, %q) = %v, want %v", tt.q, tt.u, got, tt.ceil)
+		}
+		if got := Format(tt.q, tt.u); got != tt.format {
+			t.Errorf("Format(%v, %q) = %q, want %q", tt.q, tt.u, got, tt.format)
+		}
+		if got := Step(tt.u); got != tt.step {
+			t.Errorf("Step(%q) = %q, want %q", tt.u, got, tt.step)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package validate checks submitted form values and collects one error per field, so
+// a page can show each next to its input and a JSON API can return them as a list.
+//
+//	f := validate.New(r.PostForm)
+//	code := f.Code("code", "Item code", 30)
+//	qty := f.Float("qty", "Quantity", 1, 0, math.MaxFloat64)
+//	if err := f.Err(); err != nil { ... }
+package validate
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldError is a form field that failed a check.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors are the fields that failed, in the order they were checked.
+type Errors []FieldError
+
+// Error joins the messages, for callers that show one line.
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Get is field's message, "" when it passed. Templates use it to show the error
+// next to the input: {{ .Errors.Get "name" }}.
+func (e Errors) Get(field string) string {
+	for _, fe := range e {
+		if fe.Field == field {
+			return fe.Message
+		}
+	}
+	return ""
+}
+
+// Form checks the values of one submitted form. Each field keeps its first error
+// only, so later checks on a failed field are skipped.
+type Form struct {
+	values url.Values
+	errs   Errors
+}
+
+// New checks values (r.PostForm, r.Form or a query).
+func New(values url.Values) *Form {
+	return &Form{values: values}
+}
+
+// Value is field's value with surrounding spaces trimmed.
+func (f *Form) Value(field string) string {
+	return strings.TrimSpace(f.values.Get(field))
+}
+
+// Fail records msg against field unless it has already failed.
+func (f *Form) Fail(field, msg string) {
+	if f.failed(field) {
+		return
+	}
+	f.errs = append(f.errs, FieldError{Field: field, Message: msg})
+}
+
+func (f *Form) failed(field string) bool {
+	return f.errs.Get(field) != ""
+}
+
+// Required returns field's value, failing it when empty.
+func (f *Form) Required(field, label string) string {
+	v := f.Value(field)
+	if v == "" {
+		f.Fail(field, label+" is required")
+	}
+	return v
+}
+
+// MaxLen returns field's value, failing it when longer than n characters.
+func (f *Form) MaxLen(field, label string, n int) string {
+	v := f.Value(field)
+	if utf8.RuneCountInString(v) > n {
+		f.Fail(field, fmt.Sprintf("%s is longer than %d characters", label, n))
+	}
+	return v
+}
+
+// Code returns field as an item code: required, at most maxLen characters, and on
+// one line (Xero allows spaces in codes, but not tabs or line breaks).
+func (f *Form) Code(field, label string, maxLen int) string {
+	v := f.Required(field, label)
+	if v == "" {
+		return ""
+	}
+	if strings.ContainsFunc(v, unicode.IsControl) {
+		f.Fail(field, label+" cannot contain tabs or line breaks")
+	}
+	return f.MaxLen(field, label, maxLen)
+}
+
+// Int returns field as a whole number from min to max, or def when it is empty.
+func (f *Form) Int(field, label string, def, min, max int) int {
+	v := f.Value(field)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		f.Fail(field, label+" must be a whole number"+rangeText(float64(min), float64(max), math.MinInt, math.MaxInt))
+		return def
+	}
+	return n
+}
+
+// Float returns field as a number from min to max, or def when it is empty.
+func (f *Form) Float(field, label string, def, min, max float64) float64 {
+	v := f.Value(field)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) || n < min || n > max {
+		f.Fail(field, label+" must be a number"+rangeText(min, max, -math.MaxFloat64, math.MaxFloat64))
+		return def
+	}
+	return n
+}
+
+// rangeText describes the allowed range, leaving out the bounds that are the type's
+// own (lowest, highest).
+func rangeText(min, max, lowest, highest float64) string {
+	num := func(n float64) string { return strconv.FormatFloat(n, 'f', -1, 64) }
+	switch {
+	case min > lowest && max < highest:
+		return " from " + num(min) + " to " + num(max)
+	case min > lowest:
+		return " of at least " + num(min)
+	case max < highest:
+		return " of at most " + num(max)
+	}
+	return ""
+}
+
+// Errors returns the failed fields, nil when every check passed.
+func (f *Form) Errors() Errors {
+	return f.errs
+}
+
+// Err returns the failed fields as an error, nil when every check passed.
+func (f *Form) Err() error {
+	if len(f.errs) == 0 {
+		return nil
+	}
+	return f.errs
+}
@@ -0,0 +1,67 @@
+package validate
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestForm(t *testing.T) {
+	t.Parallel()
+	f := New(url.Values{
+		"code":     {" P-0001 "},
+		"bad_code": {"P\t0001"},
+		"long":     {"abcdef"},
+		"priority": {"3"},
+		"negative": {"-1"},
+		"qty":      {"2.5"},
+		"nan":      {"NaN"},
+	})
+
+	if got := f.Code("code", "Item code", 30); got != "P-0001" {
+		t.Fatalf("code = %q", got)
+	}
+	f.Code("bad_code", "Substitute", 30)
+	f.Required("name", "Name")
+	f.MaxLen("name", "Name", 3) // already failed: not reported twice
+	f.MaxLen("long", "Note", 5)
+	if got := f.Int("priority", "Priority", 0, 0, math.MaxInt); got != 3 {
+		t.Fatalf("priority = %d", got)
+	}
+	if got := f.Int("negative", "Priority", 7, 0, math.MaxInt); got != 7 {
+		t.Fatalf("invalid int = %d, want the default", got)
+	}
+	if got := f.Int("missing", "Days", 14, 1, 365); got != 14 {
+		t.Fatalf("empty int = %d, want the default", got)
+	}
+	if got := f.Float("qty", "Quantity", 1, 0, math.MaxFloat64); got != 2.5 {
+		t.Fatalf("qty = %v", got)
+	}
+	f.Float("nan", "Price", 0, 0, 100)
+
+	want := Errors{
+		{Field: "bad_code", Message: "Substitute cannot contain tabs or line breaks"},
+		{Field: "name", Message: "Name is required"},
+		{Field: "long", Message: "Note is longer than 5 characters"},
+		{Field: "negative", Message: "Priority must be a whole number of at least 0"},
+		{Field: "nan", Message: "Price must be a number from 0 to 100"},
+	}
+	if !reflect.DeepEqual(f.Errors(), want) {
+		t.Fatalf("errors = %+v\nwant %+v", f.Errors(), want)
+	}
+	var errs Errors
+	if err := f.Err(); !errors.As(err, &errs) || errs.Get("long") != "Note is longer than 5 characters" || errs.Get("code") != "" {
+		t.Fatalf("Err() = %v", err)
+	}
+}
+
+func TestForm_Valid(t *testing.T) {
+	t.Parallel()
+	f := New(url.Values{"name": {"Workshop"}})
+	f.Required("name", "Name")
+	if f.Errors() != nil || f.Err() != nil {
+		t.Fatalf("errors = %v", f.Errors())
+	}
+}
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS shopping_list;
+DROP TABLE IF EXISTS items_contacts;
+DROP TABLE IF EXISTS parent_child;
+DROP FUNCTION IF EXISTS set_updated_at_epoch();
+
+COMMIT;
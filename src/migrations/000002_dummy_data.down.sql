@@ -0,0 +1,12 @@
+BEGIN;
+
+DELETE FROM parent_child
+WHERE (parent_id, child_id) IN (('KIT-001','P-0001'), ('KIT-001','P-0005'), ('KIT-001','P-0009'));
+
+DELETE FROM items_contacts
+WHERE (item_id, contact_id) IN (
+  ('P-0001','S-001'), ('P-0002','S-002'), ('P-0003','S-003'), ('P-0004','S-004'), ('P-0005','S-004'),
+  ('P-0006','S-001'), ('P-0007','S-003'), ('P-0008','S-003'), ('P-0009','S-001'), ('P-0010','S-001')
+);
+
+COMMIT;
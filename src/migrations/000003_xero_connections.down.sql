@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS xero_connections;
+
+COMMIT;
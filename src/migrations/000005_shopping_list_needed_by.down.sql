@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE shopping_list
+  DROP COLUMN IF EXISTS needed_by;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE shopping_list
+  ADD COLUMN IF NOT EXISTS needed_by BIGINT;
+
+COMMIT;
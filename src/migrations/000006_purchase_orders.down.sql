@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS po_reconciliation_runs;
+DROP TABLE IF EXISTS purchase_order_lines;
+DROP TABLE IF EXISTS purchase_orders;
+
+COMMIT;
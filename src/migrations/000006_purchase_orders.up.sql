@@ -0,0 +1,69 @@
+BEGIN;
+
+-- purchase orders created in Xero by this app
+CREATE TABLE IF NOT EXISTS purchase_orders (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  tenant_id TEXT NOT NULL,
+  xero_po_id TEXT NOT NULL UNIQUE,       -- Xero PurchaseOrderID (GUID)
+  contact_account TEXT NOT NULL,         -- Xero Contacts.AccountNumber (items_contacts.contact_id)
+  contact_id TEXT NOT NULL DEFAULT '',   -- Xero ContactID (GUID)
+  status TEXT NOT NULL DEFAULT 'AUTHORISED',
+  xero_deleted_at BIGINT,                -- set by reconciliation when the PO is gone from Xero
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS purchase_orders_owner_idx ON purchase_orders (owner_id, created_at);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  purchase_order_id INTEGER NOT NULL REFERENCES purchase_orders (id) ON DELETE CASCADE,
+  item_id TEXT NOT NULL,
+  quantity INTEGER NOT NULL,
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+-- discrepancy reports produced by the nightly reconciliation job
+CREATE TABLE IF NOT EXISTS po_reconciliation_runs (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  report JSONB NOT NULL,
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS po_reconciliation_runs_owner_idx ON po_reconciliation_runs (owner_id, created_at);
+
+ALTER TABLE purchase_orders ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_purchase_orders
+  ON purchase_orders
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+ALTER TABLE purchase_order_lines ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_purchase_order_lines
+  ON purchase_order_lines
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+ALTER TABLE po_reconciliation_runs ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_po_reconciliation_runs
+  ON po_reconciliation_runs
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER purchase_orders_set_updated_at
+  BEFORE UPDATE ON purchase_orders
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+CREATE TRIGGER purchase_order_lines_set_updated_at
+  BEFORE UPDATE ON purchase_order_lines
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+CREATE TRIGGER po_reconciliation_runs_set_updated_at
+  BEFORE UPDATE ON po_reconciliation_runs
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
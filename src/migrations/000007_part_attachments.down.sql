@@ -0,0 +1,6 @@
+BEGIN;
+
+-- stored objects are not removed from the bucket
+DROP TABLE IF EXISTS part_attachments;
+
+COMMIT;
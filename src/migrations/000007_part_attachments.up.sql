@@ -0,0 +1,29 @@
+BEGIN;
+
+-- images/datasheets attached to a part; the file itself lives in object storage
+CREATE TABLE IF NOT EXISTS part_attachments (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  item_id TEXT NOT NULL,                 -- Xero Item Code
+  kind TEXT NOT NULL CHECK (kind IN ('image', 'datasheet')),
+  storage_key TEXT NOT NULL UNIQUE,      -- object key in the attachments bucket
+  filename TEXT NOT NULL,
+  content_type TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  uploaded_by TEXT NOT NULL,
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS part_attachments_item_idx ON part_attachments (item_id);
+
+ALTER TABLE part_attachments ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_part_attachments
+  ON part_attachments
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER part_attachments_set_updated_at
+  BEFORE UPDATE ON part_attachments
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP INDEX IF EXISTS shopping_list_build_idx;
+
+ALTER TABLE shopping_list
+  DROP COLUMN IF EXISTS received,
+  DROP COLUMN IF EXISTS build_id;
+
+DROP TABLE IF EXISTS build_parts;
+DROP TABLE IF EXISTS builds;
+
+COMMIT;
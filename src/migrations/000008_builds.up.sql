@@ -0,0 +1,53 @@
+BEGIN;
+
+-- a build is one customer job (invoice) whose parts are bought via the shopping list
+CREATE TABLE IF NOT EXISTS builds (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  invoice_number TEXT NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  share_token_hash TEXT UNIQUE,          -- sha256 of the public embed token; NULL = not shared
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  UNIQUE (owner_id, invoice_number)
+);
+
+-- purchasable parts required per top-level assembly (snapshot of the resolved BOM)
+CREATE TABLE IF NOT EXISTS build_parts (
+  build_id INTEGER NOT NULL REFERENCES builds (id) ON DELETE CASCADE,
+  assembly_id TEXT NOT NULL,
+  assembly_name TEXT NOT NULL DEFAULT '',
+  part_id TEXT NOT NULL,
+  quantity INTEGER NOT NULL,
+  PRIMARY KEY (build_id, assembly_id, part_id),
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+ALTER TABLE shopping_list
+  ADD COLUMN IF NOT EXISTS build_id INTEGER REFERENCES builds (id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS received BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS shopping_list_build_idx ON shopping_list (build_id);
+
+ALTER TABLE builds ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_builds
+  ON builds
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+ALTER TABLE build_parts ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_build_parts
+  ON build_parts
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER builds_set_updated_at
+  BEFORE UPDATE ON builds
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+CREATE TRIGGER build_parts_set_updated_at
+  BEFORE UPDATE ON build_parts
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS shopping_list_owner_ordered_idx;
+
+ALTER TABLE shopping_list
+  DROP COLUMN IF EXISTS source_invoice,
+  DROP COLUMN IF EXISTS owner_id;
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+-- scope the shopping list per owner and record which invoice a row came from
+ALTER TABLE shopping_list
+  ADD COLUMN IF NOT EXISTS owner_id TEXT,
+  ADD COLUMN IF NOT EXISTS source_invoice TEXT;
+
+-- rows attributed to a build take its owner and invoice
+UPDATE shopping_list sl
+SET owner_id = b.owner_id, source_invoice = b.invoice_number
+FROM builds b
+WHERE sl.build_id = b.id AND sl.owner_id IS NULL;
+
+-- older rows were global; hand them to the owner when there is only one
+UPDATE shopping_list
+SET owner_id = (SELECT owner_id FROM xero_connections LIMIT 1)
+WHERE owner_id IS NULL
+  AND (SELECT count(*) FROM xero_connections) = 1;
+
+CREATE INDEX IF NOT EXISTS shopping_list_owner_ordered_idx ON shopping_list (owner_id, ordered);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- the tables themselves are kept: they may predate this migration and hold the
+-- catalogue; only what this migration attached to them is removed
+DROP TRIGGER IF EXISTS parts_set_updated_at ON parts;
+DROP TRIGGER IF EXISTS suppliers_set_updated_at ON suppliers;
+
+DROP POLICY IF EXISTS allow_authenticated_read_on_parts ON parts;
+DROP POLICY IF EXISTS allow_authenticated_read_on_suppliers ON suppliers;
+
+ALTER TABLE parts DISABLE ROW LEVEL SECURITY;
+ALTER TABLE suppliers DISABLE ROW LEVEL SECURITY;
+
+COMMIT;
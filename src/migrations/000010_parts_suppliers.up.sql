@@ -0,0 +1,56 @@
+BEGIN;
+
+-- local catalogue used by cost fallback, Xero sync and dev seeding
+-- (IF NOT EXISTS: some databases already have these tables)
+CREATE TABLE IF NOT EXISTS suppliers (
+  supplier_id TEXT PRIMARY KEY,          -- Xero Contacts.AccountNumber
+  supplier_name TEXT NOT NULL DEFAULT '',
+  contact_email TEXT,
+  phone TEXT,
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE TABLE IF NOT EXISTS parts (
+  part_id TEXT PRIMARY KEY,              -- Xero Item Code
+  name TEXT NOT NULL DEFAULT '',
+  description TEXT,
+  cost_price NUMERIC(12, 4),
+  sales_price NUMERIC(12, 4),
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+-- pre-existing tables may predate the timestamp columns the trigger needs
+ALTER TABLE suppliers
+  ADD COLUMN IF NOT EXISTS created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  ADD COLUMN IF NOT EXISTS updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint;
+ALTER TABLE parts
+  ADD COLUMN IF NOT EXISTS created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  ADD COLUMN IF NOT EXISTS updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint;
+
+ALTER TABLE suppliers ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS allow_authenticated_read_on_suppliers ON suppliers;
+CREATE POLICY allow_authenticated_read_on_suppliers
+  ON suppliers
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+ALTER TABLE parts ENABLE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS allow_authenticated_read_on_parts ON parts;
+CREATE POLICY allow_authenticated_read_on_parts
+  ON parts
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+DROP TRIGGER IF EXISTS suppliers_set_updated_at ON suppliers;
+CREATE TRIGGER suppliers_set_updated_at
+  BEFORE UPDATE ON suppliers
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+DROP TRIGGER IF EXISTS parts_set_updated_at ON parts;
+CREATE TRIGGER parts_set_updated_at
+  BEFORE UPDATE ON parts
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE xero_connections DROP COLUMN IF EXISTS tenant_name;
+
+COMMIT;
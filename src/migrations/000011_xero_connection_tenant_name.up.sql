@@ -0,0 +1,8 @@
+BEGIN;
+
+-- display name of the Xero organisation, captured at connect time so the
+-- connections list can show it without calling Xero
+ALTER TABLE xero_connections
+  ADD COLUMN IF NOT EXISTS tenant_name TEXT NOT NULL DEFAULT '';
+
+COMMIT;
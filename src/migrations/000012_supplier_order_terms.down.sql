@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE items_contacts
+  DROP COLUMN IF EXISTS pack_size,
+  DROP COLUMN IF EXISTS minimum_order_qty,
+  DROP COLUMN IF EXISTS lead_time_days;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- per item/supplier ordering terms used by the purchase order preview:
+-- NULL lead time = unknown; NULL MOQ / pack size = no constraint
+ALTER TABLE items_contacts
+  ADD COLUMN IF NOT EXISTS lead_time_days INTEGER CHECK (lead_time_days >= 0),
+  ADD COLUMN IF NOT EXISTS minimum_order_qty INTEGER CHECK (minimum_order_qty >= 1),
+  ADD COLUMN IF NOT EXISTS pack_size INTEGER CHECK (pack_size >= 1);
+
+COMMIT;
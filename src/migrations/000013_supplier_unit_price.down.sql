@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE items_contacts DROP COLUMN IF EXISTS unit_price;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- agreed purchase price per item/supplier, sent as the PO line UnitAmount;
+-- NULL = use the Xero item's purchase price
+ALTER TABLE items_contacts
+  ADD COLUMN IF NOT EXISTS unit_price NUMERIC(12, 4) CHECK (unit_price >= 0);
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- per owner purchase order defaults: delivery address, attention-to and a fixed
+-- Reference ('' = use the source invoice numbers of the ordered rows)
+CREATE TABLE IF NOT EXISTS po_settings (
+  owner_id TEXT PRIMARY KEY,
+  delivery_address TEXT NOT NULL DEFAULT '',
+  attention_to TEXT NOT NULL DEFAULT '',
+  reference TEXT NOT NULL DEFAULT '',
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+ALTER TABLE po_settings ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_po_settings
+  ON po_settings
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER po_settings_set_updated_at
+  BEFORE UPDATE ON po_settings
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
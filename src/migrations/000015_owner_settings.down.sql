@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS po_settings (
+  owner_id TEXT PRIMARY KEY,
+  delivery_address TEXT NOT NULL DEFAULT '',
+  attention_to TEXT NOT NULL DEFAULT '',
+  reference TEXT NOT NULL DEFAULT '',
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+INSERT INTO po_settings (owner_id, delivery_address, attention_to, reference, created_at)
+SELECT owner_id, delivery_address, attention_to, po_reference, created_at FROM owner_settings
+WHERE delivery_address <> '' OR attention_to <> '' OR po_reference <> ''
+ON CONFLICT (owner_id) DO NOTHING;
+
+ALTER TABLE po_settings ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_po_settings
+  ON po_settings
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER po_settings_set_updated_at
+  BEFORE UPDATE ON po_settings
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+DROP TABLE IF EXISTS owner_settings;
+
+COMMIT;
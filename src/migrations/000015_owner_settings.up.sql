@@ -0,0 +1,34 @@
+BEGIN;
+
+-- per owner preferences edited on /settings; absent row = defaults
+CREATE TABLE IF NOT EXISTS owner_settings (
+  owner_id TEXT PRIMARY KEY,
+  po_status TEXT NOT NULL DEFAULT 'AUTHORISED' CHECK (po_status IN ('DRAFT', 'SUBMITTED', 'AUTHORISED')),
+  branding_theme_id TEXT NOT NULL DEFAULT '',   -- Xero BrandingThemeID; '' = the organisation default
+  delivery_address TEXT NOT NULL DEFAULT '',
+  attention_to TEXT NOT NULL DEFAULT '',
+  po_reference TEXT NOT NULL DEFAULT '',        -- '' = the source invoice numbers of the ordered rows
+  auto_email_suppliers BOOLEAN NOT NULL DEFAULT FALSE,
+  bom_max_depth INTEGER NOT NULL DEFAULT 12 CHECK (bom_max_depth BETWEEN 1 AND 50),
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+-- the purchase order defaults move here
+INSERT INTO owner_settings (owner_id, delivery_address, attention_to, po_reference, created_at)
+SELECT owner_id, delivery_address, attention_to, reference, created_at FROM po_settings
+ON CONFLICT (owner_id) DO NOTHING;
+
+DROP TABLE IF EXISTS po_settings;
+
+ALTER TABLE owner_settings ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_owner_settings
+  ON owner_settings
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER owner_settings_set_updated_at
+  BEFORE UPDATE ON owner_settings
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
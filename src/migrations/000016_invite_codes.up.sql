@@ -0,0 +1,21 @@
+BEGIN;
+
+-- codes required to register on /register; each allows max_uses sign-ups
+CREATE TABLE IF NOT EXISTS invite_codes (
+  code TEXT PRIMARY KEY,
+  max_uses INTEGER NOT NULL DEFAULT 1 CHECK (max_uses >= 1),
+  uses INTEGER NOT NULL DEFAULT 0 CHECK (uses >= 0),
+  expires_at BIGINT,                      -- NULL = never expires
+  note TEXT NOT NULL DEFAULT '',
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+-- server side only: no policies, so API roles can't list codes
+ALTER TABLE invite_codes ENABLE ROW LEVEL SECURITY;
+
+CREATE TRIGGER invite_codes_set_updated_at
+  BEFORE UPDATE ON invite_codes
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- Xero requests recorded when XERO_DEBUG=db; secrets redacted, bodies truncated
+CREATE TABLE IF NOT EXISTS api_call_log (
+  id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL DEFAULT '',     -- '' for calls outside a user request (jobs)
+  request_id TEXT NOT NULL DEFAULT '',
+  tenant_id TEXT NOT NULL DEFAULT '',
+  method TEXT NOT NULL,
+  url TEXT NOT NULL,
+  status INTEGER NOT NULL,               -- 0 when no response was received
+  latency_ms INTEGER NOT NULL,
+  error TEXT NOT NULL DEFAULT '',
+  response_body TEXT NOT NULL DEFAULT '',
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS api_call_log_created_idx ON api_call_log (created_at);
+
+-- server side only: no policies
+ALTER TABLE api_call_log ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
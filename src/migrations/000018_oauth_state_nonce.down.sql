@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS oauth_states_expires_idx;
+ALTER TABLE oauth_states DROP COLUMN IF EXISTS nonce_hash;
+
+COMMIT;
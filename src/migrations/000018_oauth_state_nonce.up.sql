@@ -0,0 +1,9 @@
+BEGIN;
+
+-- states are bound to the browser that started the flow: sha256 (hex) of the nonce
+-- kept in its cookie. Rows from before this column never match and expire.
+ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS nonce_hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS oauth_states_expires_idx ON oauth_states (expires_at);
+
+COMMIT;
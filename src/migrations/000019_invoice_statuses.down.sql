@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE owner_settings DROP COLUMN IF EXISTS invoice_statuses;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- Xero invoice statuses parts are ordered for; drafts, voided and deleted invoices
+-- are refused by default
+ALTER TABLE owner_settings
+  ADD COLUMN IF NOT EXISTS invoice_statuses TEXT[] NOT NULL DEFAULT '{SUBMITTED,AUTHORISED,PAID}';
+
+COMMIT;
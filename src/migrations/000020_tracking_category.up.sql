@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Xero tracking category PO lines are assigned to; '' leaves them untracked
+ALTER TABLE owner_settings
+  ADD COLUMN IF NOT EXISTS tracking_category TEXT NOT NULL DEFAULT '';
+
+COMMIT;
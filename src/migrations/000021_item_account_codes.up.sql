@@ -0,0 +1,22 @@
+BEGIN;
+
+-- per item Xero expense account code used on purchase order lines; no row = the
+-- Xero item's own purchase account
+CREATE TABLE IF NOT EXISTS item_account_codes (
+  item_id TEXT PRIMARY KEY,              -- Xero Item Code
+  account_code TEXT NOT NULL CHECK (account_code <> ''),
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+ALTER TABLE item_account_codes ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_item_account_codes
+  ON item_account_codes
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER item_account_codes_set_updated_at
+  BEFORE UPDATE ON item_account_codes
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+-- parts list of an invoice each time it resolved differently from the last time, so
+-- changes after an engineering edit can be reviewed before re-ordering
+CREATE TABLE IF NOT EXISTS bom_snapshots (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  invoice_number TEXT NOT NULL,
+  parts JSONB NOT NULL,                  -- [{part_id, name, quantity, unit_cost}] sorted by part_id
+  created_at BIGINT DEFAULT (extract(epoch from now()))::bigint,
+  updated_at BIGINT DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS bom_snapshots_invoice_idx ON bom_snapshots (owner_id, invoice_number, id DESC);
+
+ALTER TABLE bom_snapshots ENABLE ROW LEVEL SECURITY;
+CREATE POLICY allow_authenticated_read_on_bom_snapshots
+  ON bom_snapshots
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE TRIGGER bom_snapshots_set_updated_at
+  BEFORE UPDATE ON bom_snapshots
+  FOR EACH ROW EXECUTE FUNCTION set_updated_at_epoch();
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE shopping_list
+  DROP COLUMN IF EXISTS received_at,
+  DROP COLUMN IF EXISTS ordered_at;
+
+ALTER TABLE purchase_order_lines
+  DROP COLUMN IF EXISTS unit_amount;
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- what each purchase order line cost, for spend reporting; NULL = unknown (Xero's
+-- own item price applied, or recorded before this column existed)
+ALTER TABLE purchase_order_lines
+  ADD COLUMN IF NOT EXISTS unit_amount NUMERIC(12, 4);
+
+-- when shopping list rows were ordered and received, for order-to-receipt times;
+-- NULL for rows that changed state before these columns existed
+ALTER TABLE shopping_list
+  ADD COLUMN IF NOT EXISTS ordered_at BIGINT,
+  ADD COLUMN IF NOT EXISTS received_at BIGINT;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE purchase_orders
+  DROP COLUMN IF EXISTS reminded_at;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- when the outstanding purchase order reminder last mentioned the PO; NULL = never
+ALTER TABLE purchase_orders
+  ADD COLUMN IF NOT EXISTS reminded_at BIGINT;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS shopping_list_archived_idx;
+DROP INDEX IF EXISTS items_contacts_archived_idx;
+ALTER TABLE shopping_list
+  DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE items_contacts
+  DROP COLUMN IF EXISTS archived_at;
+
+COMMIT;
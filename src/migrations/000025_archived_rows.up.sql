@@ -0,0 +1,14 @@
+BEGIN;
+
+-- soft delete: archived rows are hidden from the shopping list, ordering and BOM
+-- resolution but kept (reports still count them) until the janitor purges them
+-- after ARCHIVE_RETENTION. NULL = live.
+ALTER TABLE shopping_list
+  ADD COLUMN IF NOT EXISTS archived_at BIGINT;
+ALTER TABLE items_contacts
+  ADD COLUMN IF NOT EXISTS archived_at BIGINT;
+
+CREATE INDEX IF NOT EXISTS shopping_list_archived_idx ON shopping_list (archived_at) WHERE archived_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS items_contacts_archived_idx ON items_contacts (archived_at) WHERE archived_at IS NOT NULL;
+
+COMMIT;
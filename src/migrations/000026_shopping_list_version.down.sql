@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS shopping_list_bump_version ON shopping_list;
+DROP FUNCTION IF EXISTS bump_row_version();
+ALTER TABLE shopping_list
+  DROP COLUMN IF EXISTS version;
+
+COMMIT;
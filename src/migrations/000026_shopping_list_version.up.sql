@@ -0,0 +1,20 @@
+BEGIN;
+
+-- row version for optimistic concurrency: every update bumps it, so a writer that
+-- read version N can tell whether someone else changed the row since
+ALTER TABLE shopping_list
+  ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_row_version() RETURNS trigger AS $$
+BEGIN
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS shopping_list_bump_version ON shopping_list;
+CREATE TRIGGER shopping_list_bump_version
+  BEFORE UPDATE ON shopping_list
+  FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+
+COMMIT;
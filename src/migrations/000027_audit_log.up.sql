@@ -0,0 +1,14 @@
+BEGIN;
+
+-- who did what, written in the same transaction as the change it describes
+CREATE TABLE IF NOT EXISTS audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  action TEXT NOT NULL,
+  detail JSONB NOT NULL DEFAULT '{}'::jsonb,
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_owner_created_idx ON audit_log (owner_id, created_at);
+
+COMMIT;
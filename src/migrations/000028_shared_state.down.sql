@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS job_runs;
+DROP TABLE IF EXISTS app_secrets;
+DROP TABLE IF EXISTS view_states;
+
+COMMIT;
@@ -0,0 +1,31 @@
+BEGIN;
+
+-- state every app instance must see the same way, so it can run behind a load
+-- balancer with several replicas
+
+-- invoice results waiting to be shown after the redirect home; the cookie only
+-- carries the id. The janitor deletes expired rows.
+CREATE TABLE IF NOT EXISTS view_states (
+  id TEXT PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  expires_at BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS view_states_expires_idx ON view_states (expires_at);
+
+-- generated secrets shared by all instances (e.g. the flash cookie key when
+-- FLASH_SECRET is not set)
+CREATE TABLE IF NOT EXISTS app_secrets (
+  name TEXT PRIMARY KEY,
+  value TEXT NOT NULL,
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint
+);
+
+-- the last claimed run of each scheduled job, so only one instance runs it
+CREATE TABLE IF NOT EXISTS job_runs (
+  name TEXT PRIMARY KEY,
+  claimed_at BIGINT NOT NULL,
+  claimed_by TEXT NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- fractional quantities are rounded up so nothing is under-ordered
+ALTER TABLE purchase_order_lines ALTER COLUMN quantity TYPE INTEGER USING ceil(quantity);
+ALTER TABLE build_parts ALTER COLUMN quantity TYPE INTEGER USING ceil(quantity);
+ALTER TABLE shopping_list ALTER COLUMN quantity TYPE INTEGER USING ceil(quantity);
+ALTER TABLE parent_child ALTER COLUMN quantity TYPE INTEGER USING ceil(quantity);
+
+ALTER TABLE items_contacts
+  ALTER COLUMN pack_size TYPE INTEGER USING ceil(pack_size),
+  ALTER COLUMN minimum_order_qty TYPE INTEGER USING ceil(minimum_order_qty),
+  DROP COLUMN IF EXISTS uom;
+
+ALTER TABLE parts DROP COLUMN IF EXISTS uom;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+-- units of measure: parts bought by the metre, area or weight have fractional
+-- quantities, so every quantity on the way from the BOM to the purchase order is
+-- NUMERIC (Xero keeps 4 decimal places on line quantities)
+ALTER TABLE parts
+  ADD COLUMN IF NOT EXISTS uom TEXT NOT NULL DEFAULT 'each';
+
+-- the unit the supplier sells the item in; NULL = the part's unit
+ALTER TABLE items_contacts
+  ADD COLUMN IF NOT EXISTS uom TEXT,
+  ALTER COLUMN minimum_order_qty TYPE NUMERIC(14, 4),
+  ALTER COLUMN pack_size TYPE NUMERIC(14, 4);
+
+ALTER TABLE parent_child ALTER COLUMN quantity TYPE NUMERIC(14, 4);
+ALTER TABLE shopping_list ALTER COLUMN quantity TYPE NUMERIC(14, 4);
+ALTER TABLE build_parts ALTER COLUMN quantity TYPE NUMERIC(14, 4);
+ALTER TABLE purchase_order_lines ALTER COLUMN quantity TYPE NUMERIC(14, 4);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE parts DROP COLUMN IF EXISTS unavailable;
+DROP TABLE IF EXISTS item_substitutes;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+-- parts that can be ordered in place of another, best first; offered during BOM
+-- resolution when the item has no supplier or is marked unavailable, and only used
+-- once the user picks one
+CREATE TABLE IF NOT EXISTS item_substitutes (
+  item_id TEXT NOT NULL,
+  substitute_id TEXT NOT NULL,
+  priority INTEGER NOT NULL DEFAULT 0,
+  note TEXT,
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint,
+  PRIMARY KEY (item_id, substitute_id),
+  CHECK (item_id <> substitute_id)
+);
+
+-- a part that cannot be had for now (discontinued, out of stock at the supplier)
+ALTER TABLE parts
+  ADD COLUMN IF NOT EXISTS unavailable BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+-- generated exports (pick lists, kit lists, BOM and contacts files) kept in object
+-- storage; the downloads page lists them per owner and signs a fresh URL per click
+CREATE TABLE IF NOT EXISTS exports (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  kind TEXT NOT NULL,                    -- picklist, kit, bom, contacts
+  filename TEXT NOT NULL,
+  storage_key TEXT NOT NULL UNIQUE,      -- object key in the storage bucket
+  content_type TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS exports_owner_created_idx ON exports (owner_id, created_at DESC);
+
+COMMIT;
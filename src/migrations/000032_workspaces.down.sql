@@ -0,0 +1,101 @@
+BEGIN;
+
+-- back to one global copy of each parts list line and supplier mapping
+ALTER TABLE items_contacts DROP CONSTRAINT IF EXISTS items_contacts_pkey;
+DELETE FROM items_contacts a USING items_contacts b
+WHERE a.item_id = b.item_id AND a.contact_id = b.contact_id AND a.workspace_id > b.workspace_id;
+ALTER TABLE items_contacts DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE items_contacts ADD PRIMARY KEY (item_id, contact_id);
+
+DROP INDEX IF EXISTS parent_child_child_idx;
+ALTER TABLE parent_child DROP CONSTRAINT IF EXISTS parent_child_pkey;
+DELETE FROM parent_child a USING parent_child b
+WHERE a.parent_id = b.parent_id AND a.child_id = b.child_id AND a.workspace_id > b.workspace_id;
+ALTER TABLE parent_child DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE parent_child ADD PRIMARY KEY (parent_id, child_id);
+
+-- the signed-in read policies the tables had before
+CREATE POLICY allow_authenticated_read_on_parent_child
+  ON parent_child
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_items_contacts
+  ON items_contacts
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_shopping_list
+  ON shopping_list
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_xero_connections
+  ON xero_connections
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_oauth_states
+  ON oauth_states
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_purchase_orders
+  ON purchase_orders
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_purchase_order_lines
+  ON purchase_order_lines
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_po_reconciliation_runs
+  ON po_reconciliation_runs
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_part_attachments
+  ON part_attachments
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_builds
+  ON builds
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_build_parts
+  ON build_parts
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_suppliers
+  ON suppliers
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_parts
+  ON parts
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_owner_settings
+  ON owner_settings
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_item_account_codes
+  ON item_account_codes
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+CREATE POLICY allow_authenticated_read_on_bom_snapshots
+  ON bom_snapshots
+  FOR SELECT
+  USING (auth.uid() IS NOT NULL);
+
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
+
+COMMIT;
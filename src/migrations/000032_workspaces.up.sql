@@ -0,0 +1,93 @@
+BEGIN;
+
+-- workspaces: the Xero connection, settings, shopping list, purchase orders and
+-- every other owner_id row belong to a workspace that several users can share.
+-- owner_id columns hold the workspace id.
+CREATE TABLE IF NOT EXISTS workspaces (
+  id TEXT PRIMARY KEY,
+  name TEXT NOT NULL,
+  join_code TEXT UNIQUE,                 -- lets a signed-in user join; NULL = closed
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+  workspace_id TEXT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+  user_id TEXT NOT NULL,                 -- Supabase auth user id
+  email TEXT NOT NULL DEFAULT '',
+  role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'member')),
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint,
+  PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS workspace_members_user_idx ON workspace_members (user_id);
+
+-- server side only, like invite_codes
+ALTER TABLE workspaces ENABLE ROW LEVEL SECURITY;
+ALTER TABLE workspace_members ENABLE ROW LEVEL SECURITY;
+
+-- the older tables let any signed-in user read every row through the Supabase
+-- REST API with their own token, which would now mean every workspace's Xero
+-- tokens, orders and builds. Workspace scoping lives in the app's queries, so
+-- these tables become server side only too: row level security stays on, with no
+-- policies. (po_settings, which had one as well, went in 000015.)
+DROP POLICY IF EXISTS allow_authenticated_read_on_parent_child ON parent_child;
+DROP POLICY IF EXISTS allow_authenticated_read_on_items_contacts ON items_contacts;
+DROP POLICY IF EXISTS allow_authenticated_read_on_shopping_list ON shopping_list;
+DROP POLICY IF EXISTS allow_authenticated_read_on_xero_connections ON xero_connections;
+DROP POLICY IF EXISTS allow_authenticated_read_on_oauth_states ON oauth_states;
+DROP POLICY IF EXISTS allow_authenticated_read_on_purchase_orders ON purchase_orders;
+DROP POLICY IF EXISTS allow_authenticated_read_on_purchase_order_lines ON purchase_order_lines;
+DROP POLICY IF EXISTS allow_authenticated_read_on_po_reconciliation_runs ON po_reconciliation_runs;
+DROP POLICY IF EXISTS allow_authenticated_read_on_part_attachments ON part_attachments;
+DROP POLICY IF EXISTS allow_authenticated_read_on_builds ON builds;
+DROP POLICY IF EXISTS allow_authenticated_read_on_build_parts ON build_parts;
+DROP POLICY IF EXISTS allow_authenticated_read_on_suppliers ON suppliers;
+DROP POLICY IF EXISTS allow_authenticated_read_on_parts ON parts;
+DROP POLICY IF EXISTS allow_authenticated_read_on_owner_settings ON owner_settings;
+DROP POLICY IF EXISTS allow_authenticated_read_on_item_account_codes ON item_account_codes;
+DROP POLICY IF EXISTS allow_authenticated_read_on_bom_snapshots ON bom_snapshots;
+
+-- every existing owner becomes a personal workspace with the same id, so their
+-- rows need no change
+INSERT INTO workspaces (id, name)
+SELECT owner_id, 'Personal' FROM (
+  SELECT owner_id FROM xero_connections
+  UNION SELECT owner_id FROM owner_settings
+  UNION SELECT owner_id FROM shopping_list WHERE owner_id IS NOT NULL
+  UNION SELECT owner_id FROM purchase_orders
+  UNION SELECT owner_id FROM builds
+) o
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT id, id, 'owner' FROM workspaces
+ON CONFLICT (workspace_id, user_id) DO NOTHING;
+
+-- parts lists and supplier mappings were shared by everyone: each workspace gets
+-- its own copy. Without any workspace they are kept under '' where nobody sees them.
+ALTER TABLE parent_child ADD COLUMN IF NOT EXISTS workspace_id TEXT;
+ALTER TABLE parent_child DROP CONSTRAINT IF EXISTS parent_child_pkey;
+INSERT INTO parent_child
+SELECT (jsonb_populate_record(pc, jsonb_build_object('workspace_id', w.id))).*
+FROM parent_child pc CROSS JOIN workspaces w
+WHERE pc.workspace_id IS NULL;
+DELETE FROM parent_child WHERE workspace_id IS NULL AND EXISTS (SELECT 1 FROM workspaces);
+UPDATE parent_child SET workspace_id = '' WHERE workspace_id IS NULL;
+ALTER TABLE parent_child
+  ALTER COLUMN workspace_id SET NOT NULL,
+  ADD PRIMARY KEY (workspace_id, parent_id, child_id);
+CREATE INDEX IF NOT EXISTS parent_child_child_idx ON parent_child (workspace_id, child_id);
+
+ALTER TABLE items_contacts ADD COLUMN IF NOT EXISTS workspace_id TEXT;
+ALTER TABLE items_contacts DROP CONSTRAINT IF EXISTS items_contacts_pkey;
+INSERT INTO items_contacts
+SELECT (jsonb_populate_record(ic, jsonb_build_object('workspace_id', w.id))).*
+FROM items_contacts ic CROSS JOIN workspaces w
+WHERE ic.workspace_id IS NULL;
+DELETE FROM items_contacts WHERE workspace_id IS NULL AND EXISTS (SELECT 1 FROM workspaces);
+UPDATE items_contacts SET workspace_id = '' WHERE workspace_id IS NULL;
+ALTER TABLE items_contacts
+  ALTER COLUMN workspace_id SET NOT NULL,
+  ADD PRIMARY KEY (workspace_id, item_id, contact_id);
+
+COMMIT;
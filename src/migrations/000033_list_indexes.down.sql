@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS audit_log_owner_id_idx;
+DROP INDEX IF EXISTS shopping_list_owner_list_idx;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- paged lists (service.ListSpec) read one owner's rows newest first
+CREATE INDEX IF NOT EXISTS shopping_list_owner_list_idx ON shopping_list (owner_id, list_id);
+CREATE INDEX IF NOT EXISTS audit_log_owner_id_idx ON audit_log (owner_id, id);
+
+COMMIT;
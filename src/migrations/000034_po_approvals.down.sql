@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS po_approvals;
+ALTER TABLE owner_settings
+  DROP COLUMN IF EXISTS approval_scope,
+  DROP COLUMN IF EXISTS approval_threshold;
+
+COMMIT;
@@ -0,0 +1,28 @@
+BEGIN;
+
+-- purchase order batches worth more than approval_threshold wait in po_approvals for
+-- a second user with the approver role; 0 turns approval off. approval_scope says
+-- whether the threshold applies to each PO ('po') or a run's total ('batch').
+ALTER TABLE owner_settings
+  ADD COLUMN IF NOT EXISTS approval_threshold NUMERIC(14, 2) NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS approval_scope TEXT NOT NULL DEFAULT 'batch';
+
+CREATE TABLE IF NOT EXISTS po_approvals (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,
+  requested_by TEXT NOT NULL,            -- user id of who ran "Create Purchase Orders"
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  total NUMERIC(14, 2) NOT NULL,
+  batch JSONB NOT NULL,                  -- service.POBatch: the POs to send and row versions
+  decided_by TEXT,
+  decided_at BIGINT,
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint
+);
+
+-- one batch waits per workspace, so its rows cannot be ordered twice
+CREATE UNIQUE INDEX IF NOT EXISTS po_approvals_one_pending_idx ON po_approvals (owner_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS po_approvals_owner_idx ON po_approvals (owner_id, id);
+
+ALTER TABLE po_approvals ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
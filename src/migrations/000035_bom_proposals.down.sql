@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS bom_proposals;
+ALTER TABLE owner_settings DROP COLUMN IF EXISTS bom_review;
+
+COMMIT;
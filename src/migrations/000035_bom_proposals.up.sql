@@ -0,0 +1,23 @@
+BEGIN;
+
+-- with bom_review on, parts list imports wait in bom_proposals until a second user
+-- approves them, and only then change parent_child
+ALTER TABLE owner_settings ADD COLUMN IF NOT EXISTS bom_review BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS bom_proposals (
+  id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  owner_id TEXT NOT NULL,                -- workspace id
+  proposed_by TEXT NOT NULL,             -- user id of who uploaded the CSV
+  filename TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  rows JSONB NOT NULL,                   -- []csvimport.BOMRow to upsert into parent_child
+  decided_by TEXT,
+  decided_at BIGINT,
+  created_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint
+);
+
+CREATE INDEX IF NOT EXISTS bom_proposals_owner_idx ON bom_proposals (owner_id, id);
+
+ALTER TABLE bom_proposals ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
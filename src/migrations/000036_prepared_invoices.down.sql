@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS invoice_polls;
+DROP TABLE IF EXISTS prepared_invoices;
+
+COMMIT;
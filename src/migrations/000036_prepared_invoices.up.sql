@@ -0,0 +1,28 @@
+BEGIN;
+
+-- sales invoices the invoice poll found authorised in Xero, with the outcome of
+-- resolving their BOMs ahead of time
+CREATE TABLE IF NOT EXISTS prepared_invoices (
+  owner_id TEXT NOT NULL,                -- workspace id
+  tenant_id TEXT NOT NULL,
+  invoice_number TEXT NOT NULL,
+  ready BOOLEAN NOT NULL,                -- the BOM resolved
+  message TEXT NOT NULL DEFAULT '',      -- why it did not, when not ready
+  parts INTEGER NOT NULL DEFAULT 0,      -- distinct parts to order
+  updated_at BIGINT NOT NULL,            -- the invoice's UpdatedDateUTC, epoch seconds
+  resolved_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint,
+  PRIMARY KEY (owner_id, tenant_id, invoice_number)
+);
+
+-- how far each connection has been polled: the latest UpdatedDateUTC seen
+CREATE TABLE IF NOT EXISTS invoice_polls (
+  owner_id TEXT NOT NULL,
+  tenant_id TEXT NOT NULL,
+  polled_until BIGINT NOT NULL,
+  PRIMARY KEY (owner_id, tenant_id)
+);
+
+ALTER TABLE prepared_invoices ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoice_polls ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
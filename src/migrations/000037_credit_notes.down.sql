@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS applied_credit_notes;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- credit notes whose lines have been taken off the shopping list, so one is only
+-- applied once
+CREATE TABLE IF NOT EXISTS applied_credit_notes (
+  owner_id TEXT NOT NULL,                -- workspace id
+  credit_note_number TEXT NOT NULL,
+  invoices TEXT[] NOT NULL,              -- the invoices whose rows it reduced
+  applied_by TEXT NOT NULL,
+  applied_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint,
+  PRIMARY KEY (owner_id, credit_note_number)
+);
+
+ALTER TABLE applied_credit_notes ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
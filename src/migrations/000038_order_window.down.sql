@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS order_releases;
+ALTER TABLE owner_settings DROP COLUMN IF EXISTS release_hour;
+ALTER TABLE owner_settings DROP COLUMN IF EXISTS release_weekday;
+ALTER TABLE owner_settings DROP COLUMN IF EXISTS hold_orders;
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+-- with hold_orders on, "Create Purchase Orders" waits for the weekly release
+-- (release_weekday 0 = Sunday, release_hour in UTC) so each supplier gets one PO
+ALTER TABLE owner_settings ADD COLUMN IF NOT EXISTS hold_orders BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE owner_settings ADD COLUMN IF NOT EXISTS release_weekday SMALLINT NOT NULL DEFAULT 5 CHECK (release_weekday BETWEEN 0 AND 6);
+ALTER TABLE owner_settings ADD COLUMN IF NOT EXISTS release_hour SMALLINT NOT NULL DEFAULT 12 CHECK (release_hour BETWEEN 0 AND 23);
+
+-- when each owner's held orders were last released (or the hold turned on); the
+-- release job raises them once a scheduled release has passed since
+CREATE TABLE IF NOT EXISTS order_releases (
+  owner_id TEXT PRIMARY KEY,             -- workspace id
+  released_at BIGINT NOT NULL,
+  released_by TEXT NOT NULL DEFAULT ''   -- user id, or "schedule"
+);
+
+ALTER TABLE order_releases ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS items_contacts_preferred_idx;
+ALTER TABLE items_contacts DROP COLUMN IF EXISTS preferred;
+
+COMMIT;
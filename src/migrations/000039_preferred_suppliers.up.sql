@@ -0,0 +1,8 @@
+BEGIN;
+
+-- an item with several suppliers is ordered from its preferred one, else the one
+-- with the lowest agreed price, unless the PO preview picks another
+ALTER TABLE items_contacts ADD COLUMN IF NOT EXISTS preferred BOOLEAN NOT NULL DEFAULT false;
+CREATE UNIQUE INDEX IF NOT EXISTS items_contacts_preferred_idx ON items_contacts (workspace_id, item_id) WHERE preferred;
+
+COMMIT;
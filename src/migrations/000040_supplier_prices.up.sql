@@ -0,0 +1,25 @@
+BEGIN;
+
+-- supplier price lists: an item's price from a supplier from a date on. The latest
+-- price in effect is copied to items_contacts.unit_price once (applied_at), so a price
+-- set by hand afterwards stands until the next one takes effect.
+CREATE TABLE IF NOT EXISTS supplier_prices (
+  workspace_id TEXT NOT NULL,
+  item_id TEXT NOT NULL,
+  contact_id TEXT NOT NULL,              -- Xero Contacts.AccountNumber
+  effective_from DATE NOT NULL,
+  unit_price NUMERIC(12, 4) NOT NULL CHECK (unit_price >= 0),
+  source TEXT NOT NULL DEFAULT '',       -- the uploaded file's name
+  imported_by TEXT NOT NULL DEFAULT '',
+  imported_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint,
+  push_to_xero BOOLEAN NOT NULL DEFAULT false, -- also update the Xero item's purchase price
+  applied_at BIGINT,
+  pushed_at BIGINT,
+  PRIMARY KEY (workspace_id, item_id, contact_id, effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS supplier_prices_unapplied_idx ON supplier_prices (effective_from) WHERE applied_at IS NULL;
+
+ALTER TABLE supplier_prices ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE purchase_orders
+  DROP COLUMN IF EXISTS currency_rate,
+  DROP COLUMN IF EXISTS currency_code;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- purchase orders to suppliers invoicing in a foreign currency are raised in it;
+-- currency_rate is Xero's rate when planned (units per unit of the base currency)
+-- so spend can be reported in the base currency
+ALTER TABLE purchase_orders
+  ADD COLUMN IF NOT EXISTS currency_code TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS currency_rate NUMERIC(18, 6);
+
+COMMIT;
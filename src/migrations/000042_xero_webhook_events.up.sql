@@ -0,0 +1,19 @@
+BEGIN;
+
+-- Xero webhook events already handled, so a redelivered or replayed event is
+-- ignored; kept until the event is too old to be accepted anyway
+CREATE TABLE IF NOT EXISTS xero_webhook_events (
+  event_id TEXT PRIMARY KEY,             -- xero.WebhookEvent.ID
+  tenant_id TEXT NOT NULL,
+  event_category TEXT NOT NULL,
+  resource_id TEXT NOT NULL,
+  event_date BIGINT NOT NULL,            -- eventDateUtc, epoch seconds
+  received_at BIGINT NOT NULL DEFAULT (extract(epoch from now()))::bigint,
+  expires_at BIGINT NOT NULL             -- event_date + XERO_WEBHOOK_MAX_AGE
+);
+
+CREATE INDEX IF NOT EXISTS xero_webhook_events_expires_at_idx ON xero_webhook_events (expires_at);
+
+ALTER TABLE xero_webhook_events ENABLE ROW LEVEL SECURITY;
+
+COMMIT;
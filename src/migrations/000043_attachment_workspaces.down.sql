@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS part_attachments_workspace_item_idx;
+CREATE INDEX IF NOT EXISTS part_attachments_item_idx ON part_attachments (item_id);
+
+UPDATE part_attachments SET uploaded_by = workspace_id;
+ALTER TABLE part_attachments DROP COLUMN IF EXISTS workspace_id;
+
+COMMIT;
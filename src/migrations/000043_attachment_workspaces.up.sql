@@ -0,0 +1,13 @@
+BEGIN;
+
+-- attachments belong to the workspace that uploaded them, like the other owned
+-- rows. uploaded_by held that workspace's id (a personal workspace has its owner's
+-- id); from now on it names the user who uploaded.
+ALTER TABLE part_attachments ADD COLUMN IF NOT EXISTS workspace_id TEXT;
+UPDATE part_attachments SET workspace_id = uploaded_by WHERE workspace_id IS NULL;
+ALTER TABLE part_attachments ALTER COLUMN workspace_id SET NOT NULL;
+
+DROP INDEX IF EXISTS part_attachments_item_idx;
+CREATE INDEX IF NOT EXISTS part_attachments_workspace_item_idx ON part_attachments (workspace_id, item_id);
+
+COMMIT;
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations (golang-migrate file naming) so the
+// web binary can apply them at startup.
+package migrations
+
+import "embed"
+
+// FS holds the *.up.sql and *.down.sql migration files.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// NewGoTrue returns an Authenticator that verifies bearer tokens against the
+// Supabase GoTrue user endpoint (GET {supabaseURL}/auth/v1/user) using a
+// server-side key (typically the service-role key).
+// If local is non-nil the token must pass local verification first, so GoTrue
+// is only consulted for tokens that are already well-formed and unexpired.
+// Successful lookups are cached for cacheTTL (0 disables caching), but never past
+// the token's own exp.
+func NewGoTrue(local Authenticator, supabaseURL, apiKey string, client *http.Client, cacheTTL time.Duration) Authenticator {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &goTrueAuth{
+		local:    local,
+		baseURL:  strings.TrimRight(supabaseURL, "/"),
+		apiKey:   apiKey,
+		client:   client,
+		cacheTTL: cacheTTL,
+		cache:    map[string]goTrueCacheEntry{},
+		now:      time.Now,
+	}
+}
+
+type goTrueAuth struct {
+	local    Authenticator
+	baseURL  string
+	apiKey   string
+	client   *http.Client
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]goTrueCacheEntry // sha256(token) -> claims
+	now   func() time.Time
+}
+
+type goTrueCacheEntry struct {
+	claims  map[string]interface{}
+	expires time.Time
+}
+
+// goTrueUser is the subset of the GoTrue user object mapped into claims.
+type goTrueUser struct {
+	ID           string                 `json:"id"`
+	Aud          string                 `json:"aud"`
+	Role         string                 `json:"role"`
+	Email        string                 `json:"email"`
+	AppMetadata  map[string]interface{} `json:"app_metadata"`
+	UserMetadata map[string]interface{} `json:"user_metadata"`
+}
+
+func (a *goTrueAuth) Authenticate(r *http.Request) (map[string]interface{}, bool) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, false
+	}
+
+	var localClaims map[string]interface{}
+	if a.local != nil {
+		c, ok := a.local.Authenticate(r)
+		if !ok {
+			return nil, false
+		}
+		localClaims = c
+	}
+
+	key := tokenKey(token)
+	if claims, ok := a.cached(key); ok {
+		return copyClaims(claims), true
+	}
+
+	user, err := a.fetchUser(r.Context(), token)
+	if err != nil {
+		log.Printf("gotrue auth: %v", err)
+		return nil, false
+	}
+	if user.ID == "" {
+		log.Printf("gotrue auth: user response missing id")
+		return nil, false
+	}
+
+	claims := make(map[string]interface{}, len(localClaims)+6)
+	for k, v := range localClaims {
+		claims[k] = v
+	}
+	claims["sub"] = user.ID
+	if user.Email != "" {
+		claims["email"] = user.Email
+	}
+	if user.Role != "" {
+		claims["role"] = user.Role
+	}
+	if user.Aud != "" {
+		claims["aud"] = user.Aud
+	}
+	if user.AppMetadata != nil {
+		claims["app_metadata"] = user.AppMetadata
+	}
+	if user.UserMetadata != nil {
+		claims["user_metadata"] = user.UserMetadata
+	}
+
+	a.store(key, claims, tokenExpiry(token))
+	return copyClaims(claims), true
+}
+
+func (a *goTrueAuth) fetchUser(ctx context.Context, token string) (*goTrueUser, error) {
+	if a.baseURL == "" {
+		return nil, fmt.Errorf("supabase url not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/auth/v1/user", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if a.apiKey != "" {
+		req.Header.Set("apikey", a.apiKey)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("user lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("user lookup failed: status=%d", resp.StatusCode)
+	}
+	var u goTrueUser
+	if err := json.Unmarshal(body, &u); err != nil {
+		return nil, fmt.Errorf("decode user: %w", err)
+	}
+	return &u, nil
+}
+
+func (a *goTrueAuth) cached(key string) (map[string]interface{}, bool) {
+	if a.cacheTTL <= 0 {
+		return nil, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	e, ok := a.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !a.now().Before(e.expires) {
+		delete(a.cache, key)
+		return nil, false
+	}
+	return e.claims, true
+}
+
+// store caches claims for cacheTTL, or until exp when the token expires sooner (a
+// zero exp is a token without one).
+func (a *goTrueAuth) store(key string, claims map[string]interface{}, exp time.Time) {
+	if a.cacheTTL <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	// drop expired entries so the map doesn't grow unbounded
+	for k, e := range a.cache {
+		if !now.Before(e.expires) {
+			delete(a.cache, k)
+		}
+	}
+	expires := now.Add(a.cacheTTL)
+	if !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+	if !now.Before(expires) {
+		return
+	}
+	a.cache[key] = goTrueCacheEntry{claims: claims, expires: expires}
+}
+
+// tokenExpiry is the exp claim of a JWT, zero when it has none or is not a JWT.
+// The signature is not checked: GoTrue has just accepted the token, and exp only
+// bounds how long that answer is cached.
+func tokenExpiry(token string) time.Time {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return time.Time{}
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+	return exp.Time
+}
+
+// copyClaims copies claims, and the maps nested in them, so callers cannot change
+// the cached ones.
+func copyClaims(claims map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = copyClaims(m)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// tokenKey hashes a token so raw tokens are never kept as map keys.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
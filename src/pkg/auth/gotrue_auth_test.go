@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// goTrueServer answers user lookups for the bearer token valid, counting them.
+func goTrueServer(t *testing.T, calls *int32, valid string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if r.URL.Path != "/auth/v1/user" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("apikey") != "service-key" {
+			http.Error(w, "missing apikey", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":           "user-1",
+			"email":        "a@example.com",
+			"role":         "authenticated",
+			"app_metadata": map[string]interface{}{"role": "admin"},
+		})
+	}))
+}
+
+func TestGoTrue_ValidToken(t *testing.T) {
+	var calls int32
+	ts := goTrueServer(t, &calls, "good")
+	defer ts.Close()
+
+	a := NewGoTrue(nil, ts.URL, "service-key", ts.Client(), time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer good")
+
+	claims, ok := a.Authenticate(req)
+	if !ok {
+		t.Fatalf("expected authenticated")
+	}
+	if claims["sub"] != "user-1" || claims["email"] != "a@example.com" {
+		t.Fatalf("unexpected claims: %#v", claims)
+	}
+
+	// second call served from cache
+	if _, ok := a.Authenticate(req); !ok {
+		t.Fatalf("expected authenticated from cache")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", got)
+	}
+}
+
+func TestGoTrue_RejectedToken(t *testing.T) {
+	var calls int32
+	ts := goTrueServer(t, &calls, "good")
+	defer ts.Close()
+
+	a := NewGoTrue(nil, ts.URL, "service-key", ts.Client(), 0)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer bad")
+	if _, ok := a.Authenticate(req); ok {
+		t.Fatalf("expected not authenticated for rejected token")
+	}
+}
+
+func TestGoTrue_LocalVerificationFirst(t *testing.T) {
+	var calls int32
+	ts := goTrueServer(t, &calls, "good")
+	defer ts.Close()
+
+	// token signed with the wrong secret never reaches GoTrue
+	token := signedToken(t, jwt.SigningMethodHS256, "wrong", jwt.MapClaims{"sub": "user-1"})
+	a := NewGoTrue(NewJWT("right", "", ""), ts.URL, "service-key", ts.Client(), 0)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if _, ok := a.Authenticate(req); ok {
+		t.Fatalf("expected not authenticated when local verification fails")
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Fatalf("expected no upstream calls, got %d", got)
+	}
+}
+
+func TestGoTrue_CacheEndsAtTokenExpiry(t *testing.T) {
+	base := time.Unix(1_700_000_000, 0)
+	token := signedToken(t, jwt.SigningMethodHS256, "secret", jwt.MapClaims{"sub": "user-1", "exp": base.Add(30 * time.Second).Unix()})
+	var calls int32
+	ts := goTrueServer(t, &calls, token)
+	defer ts.Close()
+
+	a := NewGoTrue(nil, ts.URL, "service-key", ts.Client(), time.Minute).(*goTrueAuth)
+	now := base
+	a.now = func() time.Time { return now }
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	for _, step := range []struct {
+		at    time.Duration
+		calls int32
+	}{
+		{0, 1},
+		{20 * time.Second, 1}, // cached
+		{30 * time.Second, 2}, // the token's exp, before the cache TTL: asked again
+	} {
+		now = base.Add(step.at)
+		if _, ok := a.Authenticate(req); !ok {
+			t.Fatalf("at %v: expected authenticated", step.at)
+		}
+		if got := atomic.LoadInt32(&calls); got != step.calls {
+			t.Fatalf("at %v: %d upstream calls, want %d", step.at, got, step.calls)
+		}
+	}
+}
+
+func TestGoTrue_CachedClaimsAreCopies(t *testing.T) {
+	var calls int32
+	ts := goTrueServer(t, &calls, "good")
+	defer ts.Close()
+
+	a := NewGoTrue(nil, ts.URL, "service-key", ts.Client(), time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer good")
+
+	claims, ok := a.Authenticate(req)
+	if !ok {
+		t.Fatalf("expected authenticated")
+	}
+	claims["sub"] = "someone-else"
+	claims["app_metadata"].(map[string]interface{})["role"] = "user"
+
+	claims, ok = a.Authenticate(req)
+	if !ok {
+		t.Fatalf("expected authenticated from cache")
+	}
+	if claims["sub"] != "user-1" || claims["app_metadata"].(map[string]interface{})["role"] != "admin" {
+		t.Fatalf("cached claims were changed: %#v", claims)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", got)
+	}
+}
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Defaults for JWKS caching. Keys are refreshed after jwksDefaultTTL, and an unknown
+// kid forces a refresh at most once per jwksMinRefresh (handles key rotation without
+// letting bogus tokens hammer the JWKS endpoint).
+const (
+	jwksDefaultTTL = 10 * time.Minute
+	jwksMinRefresh = 30 * time.Second
+)
+
+// NewJWKS returns an Authenticator that validates RS256/ES256-signed JWTs using
+// public keys fetched from a JWKS URL (e.g. https://<ref>.supabase.co/auth/v1/.well-known/jwks.json).
+// issuer/audience are optional, as for NewJWT. ttl <= 0 uses the default cache TTL.
+func NewJWKS(jwksURL, issuer, audience string, client *http.Client, ttl time.Duration) Authenticator {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if ttl <= 0 {
+		ttl = jwksDefaultTTL
+	}
+	return &jwksAuth{
+		url:      jwksURL,
+		issuer:   issuer,
+		audience: audience,
+		client:   client,
+		ttl:      ttl,
+		keys:     map[string]interface{}{},
+	}
+}
+
+type jwksAuth struct {
+	url      string
+	issuer   string
+	audience string
+	client   *http.Client
+	ttl      time.Duration
+
+	mu         sync.Mutex
+	keys       map[string]interface{} // kid -> *rsa.PublicKey | *ecdsa.PublicKey
+	fetchedAt  time.Time
+	lastForced time.Time // last refresh triggered by an unknown kid
+}
+
+// jwk is the subset of RFC 7517 fields needed for RSA and EC public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (a *jwksAuth) Authenticate(r *http.Request) (map[string]interface{}, bool) {
+	tokenString := bearerToken(r)
+	if tokenString == "" {
+		return nil, false
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method {
+		case jwt.SigningMethodRS256, jwt.SigningMethodES256:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, err := a.key(r.Context(), kid)
+		if err != nil {
+			return nil, err
+		}
+		// make sure the key type matches the algorithm
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if t.Method != jwt.SigningMethodRS256 {
+				return nil, fmt.Errorf("key %q is RSA but token alg is %v", kid, t.Header["alg"])
+			}
+		case *ecdsa.PublicKey:
+			if t.Method != jwt.SigningMethodES256 {
+				return nil, fmt.Errorf("key %q is EC but token alg is %v", kid, t.Header["alg"])
+			}
+		}
+		return key, nil
+	})
+	if err != nil {
+		log.Printf("jwks auth: parse error: %v", err)
+		return nil, false
+	}
+	if !token.Valid {
+		log.Printf("jwks auth: token invalid")
+		return nil, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, false
+	}
+	if !checkIssuerAudience(claims, a.issuer, a.audience) {
+		return nil, false
+	}
+
+	out := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		out[k] = v
+	}
+	return out, true
+}
+
+// key returns the public key for kid, refreshing the JWKS when the cache is stale
+// or the kid is unknown (rotation). An empty kid matches when exactly one key exists.
+func (a *jwksAuth) key(ctx context.Context, kid string) (interface{}, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	stale := now.Sub(a.fetchedAt) > a.ttl
+	_, known := a.lookup(kid)
+	forced := !stale && !known && now.Sub(a.lastForced) > jwksMinRefresh
+	if stale || forced {
+		if forced {
+			a.lastForced = now
+		}
+		keys, err := a.fetch(ctx)
+		if err != nil {
+			// keep serving previously fetched keys if the endpoint is briefly unavailable
+			log.Printf("jwks auth: refresh failed: %v", err)
+		} else {
+			a.keys = keys
+			a.fetchedAt = now
+		}
+	}
+
+	if k, ok := a.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("no jwks key for kid %q", kid)
+}
+
+// lookup must be called with a.mu held.
+func (a *jwksAuth) lookup(kid string) (interface{}, bool) {
+	if kid == "" {
+		if len(a.keys) == 1 {
+			for _, k := range a.keys {
+				return k, true
+			}
+		}
+		return nil, false
+	}
+	k, ok := a.keys[kid]
+	return k, ok
+}
+
+func (a *jwksAuth) fetch(ctx context.Context) (map[string]interface{}, error) {
+	if a.url == "" {
+		return nil, fmt.Errorf("jwks url not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks fetch failed: status=%d", resp.StatusCode)
+	}
+	return parseJWKS(body)
+}
+
+// parseJWKS decodes a JWKS document into kid -> public key, skipping keys that
+// are not usable for signature verification.
+func parseJWKS(b []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	out := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.Printf("jwks auth: skipping key %q: %v", k.Kid, err)
+			continue
+		}
+		out[k.Kid] = pub
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("jwks contains no usable keys")
+	}
+	return out, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeB64(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeB64(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeB64(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeB64(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("point not on curve")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+}
+
+func decodeB64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// jwksServer serves whatever keys are currently set and counts fetches.
+type jwksServer struct {
+	mu    sync.Mutex
+	keys  []map[string]string
+	calls int32
+}
+
+func (s *jwksServer) set(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.calls, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+}
+
+func signWithKid(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return s
+}
+
+func authReq(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWKS_RS256AndES256(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	srv := &jwksServer{}
+	srv.set(rsaJWK("r1", &rsaKey.PublicKey), ecJWK("e1", &ecKey.PublicKey))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	a := NewJWKS(ts.URL, "iss-1", "", ts.Client(), 0)
+
+	rs := signWithKid(t, jwt.SigningMethodRS256, "r1", rsaKey, jwt.MapClaims{"sub": "u1", "iss": "iss-1"})
+	if claims, ok := a.Authenticate(authReq(rs)); !ok || claims["sub"] != "u1" {
+		t.Fatalf("expected RS256 token accepted, got ok=%v claims=%v", ok, claims)
+	}
+
+	es := signWithKid(t, jwt.SigningMethodES256, "e1", ecKey, jwt.MapClaims{"sub": "u2", "iss": "iss-1"})
+	if claims, ok := a.Authenticate(authReq(es)); !ok || claims["sub"] != "u2" {
+		t.Fatalf("expected ES256 token accepted, got ok=%v claims=%v", ok, claims)
+	}
+
+	// keys are cached between requests
+	if got := atomic.LoadInt32(&srv.calls); got != 1 {
+		t.Fatalf("expected 1 jwks fetch, got %d", got)
+	}
+
+	// wrong issuer is rejected
+	bad := signWithKid(t, jwt.SigningMethodRS256, "r1", rsaKey, jwt.MapClaims{"sub": "u1", "iss": "other"})
+	if _, ok := a.Authenticate(authReq(bad)); ok {
+		t.Fatalf("expected issuer mismatch to be rejected")
+	}
+}
+
+func TestJWKS_RejectsHS256(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	srv := &jwksServer{}
+	srv.set(rsaJWK("r1", &rsaKey.PublicKey))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	a := NewJWKS(ts.URL, "", "", ts.Client(), 0)
+	token := signedToken(t, jwt.SigningMethodHS256, "secret", jwt.MapClaims{"sub": "1"})
+	if _, ok := a.Authenticate(authReq(token)); ok {
+		t.Fatalf("expected HS256 token rejected by JWKS verifier")
+	}
+}
+
+func TestJWKS_KeyRotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	srv := &jwksServer{}
+	srv.set(rsaJWK("old", &oldKey.PublicKey))
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	a := NewJWKS(ts.URL, "", "", ts.Client(), 0)
+	if _, ok := a.Authenticate(authReq(signWithKid(t, jwt.SigningMethodRS256, "old", oldKey, jwt.MapClaims{"sub": "1"}))); !ok {
+		t.Fatalf("expected old key accepted")
+	}
+
+	// rotate: publish the new key; an unknown kid triggers a refetch
+	srv.set(rsaJWK("old", &oldKey.PublicKey), rsaJWK("new", &newKey.PublicKey))
+	if _, ok := a.Authenticate(authReq(signWithKid(t, jwt.SigningMethodRS256, "new", newKey, jwt.MapClaims{"sub": "1"}))); !ok {
+		t.Fatalf("expected rotated key accepted after refetch")
+	}
+	if got := atomic.LoadInt32(&srv.calls); got != 2 {
+		t.Fatalf("expected 2 jwks fetches, got %d", got)
+	}
+
+	// a further unknown kid within the min refresh window does not refetch
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, ok := a.Authenticate(authReq(signWithKid(t, jwt.SigningMethodRS256, "bogus", other, jwt.MapClaims{"sub": "1"}))); ok {
+		t.Fatalf("expected unknown kid rejected")
+	}
+	if got := atomic.LoadInt32(&srv.calls); got != 2 {
+		t.Fatalf("expected refetch to be throttled, got %d fetches", got)
+	}
+}
+
+func TestParseJWKS_NoUsableKeys(t *testing.T) {
+	if _, err := parseJWKS([]byte(`{"keys":[{"kty":"oct","kid":"x"}]}`)); err == nil {
+		t.Fatalf("expected error for jwks without usable keys")
+	}
+}
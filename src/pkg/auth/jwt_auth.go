@@ -70,48 +70,57 @@ func (a *jwtAuth) Authenticate(r *http.Request) (map[string]interface{}, bool) {
 		return nil, false
 	}
 
+	if !checkIssuerAudience(claims, a.issuer, a.audience) {
+		return nil, false
+	}
+
+	out := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		out[k] = v
+	}
+	return out, true
+}
+
+// checkIssuerAudience validates the optional expected iss/aud claims.
+// aud may be a string or an array of strings.
+func checkIssuerAudience(claims jwt.MapClaims, issuer, audience string) bool {
 	// Optional: validate issuer
-	if a.issuer != "" {
-		if iss, ok := claims["iss"].(string); !ok || iss != a.issuer {
-			log.Printf("jwt auth: issuer mismatch; expected=%s got=%v", a.issuer, claims["iss"])
-			return nil, false
+	if issuer != "" {
+		if iss, ok := claims["iss"].(string); !ok || iss != issuer {
+			log.Printf("jwt auth: issuer mismatch; expected=%s got=%v", issuer, claims["iss"])
+			return false
 		}
 	}
 
 	// Optional: validate audience (aud can be string or array)
-	if a.audience != "" {
+	if audience != "" {
 		if audVal, ok := claims["aud"]; ok {
 			switch v := audVal.(type) {
 			case string:
-				if v != a.audience {
-					log.Printf("jwt auth: audience mismatch; expected=%s got=%s", a.audience, v)
-					return nil, false
+				if v != audience {
+					log.Printf("jwt auth: audience mismatch; expected=%s got=%s", audience, v)
+					return false
 				}
 			case []interface{}:
 				found := false
 				for _, it := range v {
-					if s, ok := it.(string); ok && s == a.audience {
+					if s, ok := it.(string); ok && s == audience {
 						found = true
 						break
 					}
 				}
 				if !found {
-					log.Printf("jwt auth: audience not found; expected=%s", a.audience)
-					return nil, false
+					log.Printf("jwt auth: audience not found; expected=%s", audience)
+					return false
 				}
 			default:
 				log.Printf("jwt auth: unexpected aud claim type: %T", audVal)
-				return nil, false
+				return false
 			}
 		} else {
-			log.Printf("jwt auth: aud claim missing but expected=%s", a.audience)
-			return nil, false
+			log.Printf("jwt auth: aud claim missing but expected=%s", audience)
+			return false
 		}
 	}
-
-	out := make(map[string]interface{}, len(claims))
-	for k, v := range claims {
-		out[k] = v
-	}
-	return out, true
+	return true
 }
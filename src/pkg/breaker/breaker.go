@@ -0,0 +1,143 @@
+// Package breaker is a per-host circuit breaker for outbound HTTP calls.
+//
+// After Threshold consecutive failures (transport errors, timeouts or 5xx responses)
+// from a host, requests to it fail immediately with an *OpenError for Cooldown instead
+// of waiting for the client timeout. Once the cooldown has passed a single trial
+// request is let through: success closes the circuit, failure opens it again.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrOpen matches (errors.Is) every error returned for a request refused by an open circuit.
+var ErrOpen = errors.New("circuit open")
+
+// OpenError is returned by Transport for a request to a host whose circuit is open.
+type OpenError struct {
+	Host  string
+	Until time.Time // when a trial request will next be allowed
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%s temporarily unavailable (circuit open until %s)", e.Host, e.Until.UTC().Format(time.TimeOnly))
+}
+
+func (e *OpenError) Unwrap() error { return ErrOpen }
+
+// CircuitOpen marks the error for clients that skip retries of refused requests
+// (xero.CircuitOpenError).
+func (e *OpenError) CircuitOpen() bool { return true }
+
+// Breaker tracks the health of each host it has seen. It is safe for concurrent use.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	failures  int       // consecutive failures while closed
+	openUntil time.Time // zero when closed
+	probing   bool      // a trial request is in flight
+}
+
+// New returns a Breaker that opens after threshold consecutive failures and stays
+// open for cooldown. A threshold below 1 never opens.
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, now: time.Now, hosts: map[string]*hostState{}}
+}
+
+// Allow reports whether a request to host may be sent. When it returns nil the caller
+// must report the outcome with Record.
+func (b *Breaker) Allow(host string) error {
+	if b.threshold < 1 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.hosts[host]
+	if s == nil || s.openUntil.IsZero() {
+		return nil
+	}
+	if b.now().Before(s.openUntil) || s.probing {
+		return &OpenError{Host: host, Until: s.openUntil}
+	}
+	// half-open: this request is the trial
+	s.probing = true
+	return nil
+}
+
+// Record reports the outcome of a request allowed by Allow.
+func (b *Breaker) Record(host string, ok bool) {
+	if b.threshold < 1 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.hosts[host]
+	if s == nil {
+		if ok {
+			return
+		}
+		s = &hostState{}
+		b.hosts[host] = s
+	}
+	wasProbe := s.probing
+	s.probing = false
+	if ok {
+		*s = hostState{}
+		return
+	}
+	s.failures++
+	if wasProbe || s.failures >= b.threshold {
+		s.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Open reports whether host's circuit is currently refusing requests.
+func (b *Breaker) Open(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.hosts[host]
+	return s != nil && !s.openUntil.IsZero() && b.now().Before(s.openUntil)
+}
+
+// Transport wraps base (http.DefaultTransport when nil) so every request goes through b.
+func (b *Breaker) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{b: b, base: base}
+}
+
+type transport struct {
+	b    *Breaker
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := t.b.Allow(host); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		// the caller gave up; says nothing about the host
+		t.b.Record(host, true)
+	case err != nil:
+		t.b.Record(host, false)
+	default:
+		t.b.Record(host, resp.StatusCode < 500)
+	}
+	return resp, err
+}
@@ -0,0 +1,142 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+func TestBreaker_OpensAfterThresholdAndProbes(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := New(3, 30*time.Second)
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := b.Allow("api.xero.com"); err != nil {
+			t.Fatalf("allow %d: %v", i, err)
+		}
+		b.Record("api.xero.com", false)
+	}
+	// a success resets the count
+	b.Record("api.xero.com", true)
+	for i := 0; i < 3; i++ {
+		_ = b.Allow("api.xero.com")
+		b.Record("api.xero.com", false)
+	}
+	err := b.Allow("api.xero.com")
+	var open *OpenError
+	if !errors.As(err, &open) || !errors.Is(err, ErrOpen) || !open.Until.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	if err := b.Allow("supabase.co"); err != nil {
+		t.Fatalf("other hosts are unaffected: %v", err)
+	}
+
+	// after the cooldown exactly one trial is allowed
+	now = now.Add(31 * time.Second)
+	if err := b.Allow("api.xero.com"); err != nil {
+		t.Fatalf("trial request refused: %v", err)
+	}
+	if err := b.Allow("api.xero.com"); !errors.Is(err, ErrOpen) {
+		t.Fatalf("second request during trial should be refused, got %v", err)
+	}
+	// failed trial re-opens immediately
+	b.Record("api.xero.com", false)
+	if !b.Open("api.xero.com") {
+		t.Fatal("failed trial should re-open the circuit")
+	}
+	now = now.Add(31 * time.Second)
+	_ = b.Allow("api.xero.com")
+	b.Record("api.xero.com", true)
+	if b.Open("api.xero.com") || b.Allow("api.xero.com") != nil {
+		t.Fatal("successful trial should close the circuit")
+	}
+}
+
+func TestBreaker_ZeroThresholdNeverOpens(t *testing.T) {
+	b := New(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		b.Record("h", false)
+	}
+	if err := b.Allow("h"); err != nil {
+		t.Fatalf("disabled breaker refused request: %v", err)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	b := New(2, time.Minute)
+	client := &http.Client{Transport: b.Transport(ts.Client().Transport)}
+	get := func(path string) (*http.Response, error) {
+		resp, err := client.Get(ts.URL + path)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// 4xx is the caller's problem, not the host's
+	for i := 0; i < 3; i++ {
+		if _, err := get("/missing"); err != nil {
+			t.Fatalf("404 request: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if resp, err := get("/down"); err != nil || resp.StatusCode != http.StatusBadGateway {
+			t.Fatalf("5xx is passed through before opening: %v", err)
+		}
+	}
+	before := calls.Load()
+	if _, err := get("/ok"); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected ErrOpen through the client, got %v", err)
+	}
+	if calls.Load() != before {
+		t.Fatal("request reached the server while the circuit was open")
+	}
+}
+
+func TestTransport_CallerCancelIsNotAFailure(t *testing.T) {
+	b := New(1, time.Minute)
+	blocked := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer ts.Close()
+	defer close(blocked)
+
+	client := &http.Client{Transport: b.Transport(ts.Client().Transport)}
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	go func() { time.Sleep(10 * time.Millisecond); cancel() }()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("expected cancellation error")
+	}
+	if b.Open(req.URL.Host) {
+		t.Fatal("caller cancellation opened the circuit")
+	}
+}
+
+func TestOpenError_IsXeroCircuitOpenError(t *testing.T) {
+	var err error = &OpenError{Host: "api.xero.com"}
+	var open xero.CircuitOpenError
+	if !errors.As(err, &open) || !open.CircuitOpen() {
+		t.Fatal("the Xero client would retry requests refused by the breaker")
+	}
+}
@@ -0,0 +1,113 @@
+// Package cache keeps short-lived values by key so repeated lookups skip the slow
+// source (the Xero API, the database).
+//
+// Memory keeps values in the process; Redis shares them between app instances.
+// Callers treat a cache error like a miss: the cache only ever saves work.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Cache stores byte values under string keys for a limited time.
+type Cache interface {
+	// Get returns the values stored under keys, leaving out missing and expired ones.
+	Get(ctx context.Context, keys ...string) (map[string][]byte, error)
+	// Set stores each value under its key for ttl.
+	Set(ctx context.Context, values map[string][]byte, ttl time.Duration) error
+	// Delete forgets keys.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// GetJSON is Get for values stored with SetJSON. Values that no longer decode into T
+// are left out, like misses.
+func GetJSON[T any](ctx context.Context, c Cache, keys ...string) (map[string]T, error) {
+	raw, err := c.Get(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]T, len(raw))
+	for k, b := range raw {
+		var v T
+		if json.Unmarshal(b, &v) == nil {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// SetJSON stores values JSON-encoded.
+func SetJSON[T any](ctx context.Context, c Cache, values map[string]T, ttl time.Duration) error {
+	raw := make(map[string][]byte, len(values))
+	for k, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		raw[k] = b
+	}
+	return c.Set(ctx, raw, ttl)
+}
+
+// sweepEvery is how many Sets Memory takes between sweeps for expired entries.
+const sweepEvery = 1000
+
+// Memory is a Cache in process memory.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	sets    int
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory returns an empty in-memory cache.
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]memoryEntry{}, now: time.Now}
+}
+
+func (m *Memory) Get(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	out := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		if e, ok := m.entries[k]; ok && now.Before(e.expires) {
+			out[k] = e.value
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) Set(ctx context.Context, values map[string][]byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if m.sets++; m.sets%sweepEvery == 0 {
+		for k, e := range m.entries {
+			if !now.Before(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	for k, v := range values {
+		m.entries[k] = memoryEntry{value: v, expires: now.Add(ttl)}
+	}
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		delete(m.entries, k)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/redis"
+)
+
+type part struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+func TestMemory(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if err := SetJSON(ctx, m, map[string]part{"BOLT": {"M6 bolt", 0.2}, "NUT": {"M6 nut", 0.05}}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_ = m.Set(ctx, map[string][]byte{"JUNK": []byte("{")}, time.Hour)
+	got, err := GetJSON[part](ctx, m, "BOLT", "NUT", "WASHER", "JUNK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]part{"BOLT": {"M6 bolt", 0.2}, "NUT": {"M6 nut", 0.05}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	_ = m.Delete(ctx, "NUT")
+	now = now.Add(time.Minute)
+	if got, _ := m.Get(ctx, "BOLT", "NUT", "JUNK"); len(got) != 1 || got["JUNK"] == nil {
+		t.Fatalf("after expiry and delete: %v", got)
+	}
+}
+
+// fakeRedis keeps strings in memory and answers MGET, SET and DEL; expiry is not
+// simulated but the PX given is recorded.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu     sync.Mutex
+	values map[string]string
+	px     map[string]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, values: map[string]string{}, px: map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	for {
+		v, err := redis.ReadReply(br)
+		if err != nil {
+			return
+		}
+		items, _ := v.([]any)
+		args := make([]string, len(items))
+		for i, it := range items {
+			args[i], _ = it.(string)
+		}
+		var out strings.Builder
+		f.mu.Lock()
+		switch args[0] {
+		case "MGET":
+			out.WriteString("*" + strconv.Itoa(len(args)-1) + "\r\n")
+			for _, k := range args[1:] {
+				if s, ok := f.values[k]; ok {
+					out.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+				} else {
+					out.WriteString("$-1\r\n")
+				}
+			}
+		case "SET":
+			f.values[args[1]], f.px[args[1]] = args[2], args[4]
+			out.WriteString("+OK\r\n")
+		case "DEL":
+			for _, k := range args[1:] {
+				delete(f.values, k)
+			}
+			out.WriteString(":1\r\n")
+		default:
+			out.WriteString("-ERR unknown command\r\n")
+		}
+		f.mu.Unlock()
+		if _, err := c.Write([]byte(out.String())); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedis(t *testing.T) {
+	f := newFakeRedis(t)
+	r, err := NewRedis("redis://"+f.ln.Addr().String(), "app:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := SetJSON(ctx, r, map[string]part{"BOLT": {"M6 bolt", 0.2}}, 90*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	stored, px := f.values["app:BOLT"], f.px["app:BOLT"]
+	f.mu.Unlock()
+	if stored != `{"name":"M6 bolt","price":0.2}` || px != "90000" {
+		t.Fatalf("stored %q with PX %s", stored, px)
+	}
+	got, err := GetJSON[part](ctx, r, "NUT", "BOLT")
+	if err != nil || !reflect.DeepEqual(got, map[string]part{"BOLT": {"M6 bolt", 0.2}}) {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	if err := r.Delete(ctx, "BOLT"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Get(ctx, "BOLT"); err != nil || len(got) != 0 {
+		t.Fatalf("after delete: %v, %v", got, err)
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/redis"
+)
+
+// Redis is a Cache on a Redis server, shared by every app instance using it. Keys
+// are stored under Prefix.
+type Redis struct {
+	c      *redis.Client
+	Prefix string
+}
+
+// NewRedis connects lazily to the server at a redis:// or rediss:// URL (see
+// redis.New); keys are stored under prefix.
+func NewRedis(rawURL, prefix string) (*Redis, error) {
+	c, err := redis.New(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{c: c, Prefix: prefix}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	if len(keys) == 0 {
+		return map[string][]byte{}, nil
+	}
+	args := append([]string{"MGET"}, r.keys(keys)...)
+	reply, err := r.c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	vals, _ := reply.([]any)
+	out := make(map[string][]byte, len(keys))
+	for i, v := range vals {
+		if s, ok := v.(string); ok && i < len(keys) {
+			out[keys[i]] = []byte(s)
+		}
+	}
+	return out, nil
+}
+
+func (r *Redis) Set(ctx context.Context, values map[string][]byte, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+	px := strconv.FormatInt(max(ttl.Milliseconds(), 1), 10)
+	cmds := make([][]string, 0, len(values))
+	for k, v := range values {
+		cmds = append(cmds, []string{"SET", r.Prefix + k, string(v), "PX", px})
+	}
+	_, err := r.c.Pipeline(ctx, cmds...)
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.c.Do(ctx, append([]string{"DEL"}, r.keys(keys)...)...)
+	return err
+}
+
+func (r *Redis) keys(keys []string) []string {
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = r.Prefix + k
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+// Package ratelimit counts events per key in fixed windows, e.g. login attempts per
+// client IP or failed logins per email address.
+//
+// Counts live in a Store: Memory keeps them in the process, Redis shares them
+// between instances. A key's window starts at its first event and its count is
+// dropped when the window ends.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store holds the per-key counters.
+type Store interface {
+	// Incr adds one to key's count, starting a window of length window when the key
+	// has none, and returns the new count and the time left in the window.
+	Incr(ctx context.Context, key string, window time.Duration) (int, time.Duration, error)
+	// Get returns key's count and the time left in its window (0, 0 when none).
+	Get(ctx context.Context, key string) (int, time.Duration, error)
+	// Reset forgets key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Limiter allows Limit events per key per Window.
+type Limiter struct {
+	Store  Store
+	Prefix string // namespaces the keys in Store
+	Limit  int    // below 1 never limits
+	Window time.Duration
+}
+
+// Hit records an event for key. It returns how long until key may be used again, or
+// 0 while the count is within the limit.
+func (l Limiter) Hit(ctx context.Context, key string) (time.Duration, error) {
+	if l.Limit < 1 {
+		return 0, nil
+	}
+	n, ttl, err := l.Store.Incr(ctx, l.Prefix+key, l.Window)
+	if err != nil || n <= l.Limit {
+		return 0, err
+	}
+	return retryAfter(ttl), nil
+}
+
+// Check reports, without recording an event, how long until key may be used again:
+// 0 when another event would still be within the limit.
+func (l Limiter) Check(ctx context.Context, key string) (time.Duration, error) {
+	if l.Limit < 1 {
+		return 0, nil
+	}
+	n, ttl, err := l.Store.Get(ctx, l.Prefix+key)
+	if err != nil || n < l.Limit {
+		return 0, err
+	}
+	return retryAfter(ttl), nil
+}
+
+// Remaining is how many more events key may have in its current window.
+func (l Limiter) Remaining(ctx context.Context, key string) (int, error) {
+	n, _, err := l.Store.Get(ctx, l.Prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return max(l.Limit-n, 0), nil
+}
+
+// Reset clears key's count, e.g. after a successful login.
+func (l Limiter) Reset(ctx context.Context, key string) error {
+	return l.Store.Reset(ctx, l.Prefix+key)
+}
+
+// retryAfter rounds ttl up to whole seconds so a limited caller never retries early.
+func retryAfter(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return time.Second
+	}
+	return ttl.Truncate(time.Second) + time.Second
+}
+
+// Memory is an in-process Store. It is safe for concurrent use.
+type Memory struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*window
+	sweeps  int
+}
+
+type window struct {
+	count int
+	ends  time.Time
+}
+
+// NewMemory returns an empty in-process store.
+func NewMemory() *Memory {
+	return &Memory{now: time.Now, windows: map[string]*window{}}
+}
+
+func (m *Memory) Incr(ctx context.Context, key string, d time.Duration) (int, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+	w := m.windows[key]
+	if w == nil || !now.Before(w.ends) {
+		w = &window{ends: now.Add(d)}
+		m.windows[key] = w
+	}
+	w.count++
+	return w.count, w.ends.Sub(now), nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (int, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	w := m.windows[key]
+	if w == nil || !now.Before(w.ends) {
+		return 0, 0, nil
+	}
+	return w.count, w.ends.Sub(now), nil
+}
+
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.windows, key)
+	return nil
+}
+
+// sweep drops ended windows every so often so unused keys don't accumulate.
+func (m *Memory) sweep(now time.Time) {
+	if m.sweeps++; m.sweeps < 1000 {
+		return
+	}
+	m.sweeps = 0
+	for k, w := range m.windows {
+		if !now.Before(w.ends) {
+			delete(m.windows, k)
+		}
+	}
+}
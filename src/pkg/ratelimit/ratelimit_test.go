@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/redis"
+)
+
+func TestLimiter_Memory(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	l := Limiter{Store: m, Prefix: "ip:", Limit: 3, Window: time.Minute}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if d, err := l.Hit(ctx, "1.2.3.4"); d != 0 || err != nil {
+			t.Fatalf("hit %d limited: %v %v", i, d, err)
+		}
+	}
+	if d, _ := l.Check(ctx, "1.2.3.4"); d != time.Minute+time.Second {
+		t.Fatalf("Check at limit = %v", d)
+	}
+	now = now.Add(20 * time.Second)
+	if d, _ := l.Hit(ctx, "1.2.3.4"); d != 41*time.Second {
+		t.Fatalf("Hit over limit = %v, want the rest of the window", d)
+	}
+	if d, _ := l.Hit(ctx, "5.6.7.8"); d != 0 {
+		t.Fatal("other keys are unaffected")
+	}
+
+	// the window ends 60s after the first hit
+	now = now.Add(40 * time.Second)
+	if d, _ := l.Check(ctx, "1.2.3.4"); d != 0 {
+		t.Fatalf("still limited after the window: %v", d)
+	}
+	_, _ = l.Hit(ctx, "1.2.3.4")
+	if n, _ := l.Remaining(ctx, "1.2.3.4"); n != 2 {
+		t.Fatalf("Remaining = %d, want 2", n)
+	}
+	_ = l.Reset(ctx, "1.2.3.4")
+	if n, _ := l.Remaining(ctx, "1.2.3.4"); n != 3 {
+		t.Fatalf("Remaining after reset = %d", n)
+	}
+
+	if d, _ := (Limiter{Store: m, Limit: 0}).Hit(ctx, "x"); d != 0 {
+		t.Fatal("zero limit never limits")
+	}
+}
+
+// fakeRedis speaks enough RESP to run the two scripts and DEL, keeping counts in
+// memory; expiry is not simulated, every key reports 60s left.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu     sync.Mutex
+	counts map[string]int
+	auths  int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, password: password, counts: map[string]int{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	br := bufio.NewReader(c)
+	authed := f.password == ""
+	for {
+		v, err := redis.ReadReply(br)
+		if err != nil {
+			return
+		}
+		items, _ := v.([]any)
+		args := make([]string, len(items))
+		for i, it := range items {
+			args[i], _ = it.(string)
+		}
+		f.mu.Lock()
+		var out string
+		switch {
+		case args[0] == "AUTH":
+			f.auths++
+			authed = args[len(args)-1] == f.password
+			out = "+OK\r\n"
+			if !authed {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "DEL":
+			delete(f.counts, args[1])
+			out = ":1\r\n"
+		case args[0] == "EVAL" && strings.Contains(args[1], "INCR"):
+			f.counts[args[3]]++
+			out = fmt.Sprintf("*2\r\n:%d\r\n:60000\r\n", f.counts[args[3]])
+		case args[0] == "EVAL":
+			ttl := 60000
+			if f.counts[args[3]] == 0 {
+				ttl = -2
+			}
+			out = "*2\r\n:" + strconv.Itoa(f.counts[args[3]]) + "\r\n:" + strconv.Itoa(ttl) + "\r\n"
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := c.Write([]byte(out)); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedis(t *testing.T) {
+	f := newFakeRedis(t, "s3cret")
+	r, err := NewRedis("redis://:s3cret@" + f.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := Limiter{Store: r, Prefix: "email:", Limit: 2, Window: time.Minute}
+	ctx := context.Background()
+
+	if n, err := l.Remaining(ctx, "a@example.com"); n != 2 || err != nil {
+		t.Fatalf("Remaining = %d, %v", n, err)
+	}
+	_, _ = l.Hit(ctx, "a@example.com")
+	_, _ = l.Hit(ctx, "a@example.com")
+	if d, err := l.Check(ctx, "a@example.com"); d != time.Minute+time.Second || err != nil {
+		t.Fatalf("Check = %v, %v", d, err)
+	}
+	if err := l.Reset(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := l.Check(ctx, "a@example.com"); d != 0 || err != nil {
+		t.Fatalf("Check after reset = %v, %v", d, err)
+	}
+	f.mu.Lock()
+	auths := f.auths
+	f.mu.Unlock()
+	if auths == 0 {
+		t.Fatal("password not sent")
+	}
+
+	bad, _ := NewRedis("redis://:wrong@" + f.ln.Addr().String())
+	if _, _, err := bad.Get(ctx, "k"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("expected auth error, got %v", err)
+	}
+}
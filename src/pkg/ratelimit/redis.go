@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/redis"
+)
+
+// incrScript increments a counter, starts its window on the first increment and
+// returns {count, milliseconds left}; atomic on the Redis server.
+const incrScript = `local n = redis.call('INCR', KEYS[1])
+if n == 1 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+return {n, redis.call('PTTL', KEYS[1])}`
+
+// getScript returns {count, milliseconds left} without changing the counter.
+const getScript = `return {tonumber(redis.call('GET', KEYS[1]) or '0'), redis.call('PTTL', KEYS[1])}`
+
+// Redis is a Store backed by a Redis server, so limits hold across app instances.
+// It opens a connection per call, which is plenty for login traffic.
+type Redis struct {
+	c *redis.Client
+}
+
+// NewRedis parses a redis:// or rediss:// (TLS) URL of the form
+// redis://[user:password@]host[:port][/db].
+func NewRedis(rawURL string) (*Redis, error) {
+	c, err := redis.New(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{c: c}, nil
+}
+
+func (r *Redis) Incr(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	return r.eval(ctx, incrScript, key, strconv.FormatInt(window.Milliseconds(), 10))
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (int, time.Duration, error) {
+	return r.eval(ctx, getScript, key)
+}
+
+func (r *Redis) Reset(ctx context.Context, key string) error {
+	_, err := r.c.Do(ctx, "DEL", key)
+	return err
+}
+
+// eval runs a script returning {count, pttl}.
+func (r *Redis) eval(ctx context.Context, script, key string, args ...string) (int, time.Duration, error) {
+	reply, err := r.c.Do(ctx, append([]string{"EVAL", script, "1", key}, args...)...)
+	if err != nil {
+		return 0, 0, err
+	}
+	vals, ok := reply.([]any)
+	if !ok || len(vals) != 2 {
+		return 0, 0, fmt.Errorf("redis: unexpected reply %v", reply)
+	}
+	n, _ := vals[0].(int64)
+	pttl, _ := vals[1].(int64)
+	if pttl < 0 { // no key (-2) or no expiry (-1)
+		pttl = 0
+	}
+	return int(n), time.Duration(pttl) * time.Millisecond, nil
+}
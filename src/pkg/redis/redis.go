@@ -0,0 +1,204 @@
+// Package redis is a small Redis client: enough of RESP to send commands and read
+// their replies, on a new connection per call. It needs nothing outside the standard
+// library; the rate limiter and the shared cache are built on it.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends commands to one Redis server.
+type Client struct {
+	addr     string
+	host     string // for TLS verification
+	username string
+	password string
+	db       int
+	tls      bool
+	timeout  time.Duration
+}
+
+// New parses a redis:// or rediss:// (TLS) URL of the form
+// redis://[user:password@]host[:port][/db].
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("redis url: want redis:// or rediss://, got %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("redis url: host missing")
+	}
+	c := &Client{addr: u.Host, host: u.Hostname(), tls: u.Scheme == "rediss", timeout: 2 * time.Second}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis url: invalid db %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Do sends one command and returns its reply: string, int64, nil or []any.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends cmds together on one connection (after AUTH/SELECT as configured)
+// and returns their replies in order. An error reply to any of them is returned as
+// an ErrorReply.
+func (c *Client) Pipeline(ctx context.Context, cmds ...[]string) ([]any, error) {
+	d := net.Dialer{Timeout: c.timeout}
+	var conn net.Conn
+	var err error
+	if c.tls {
+		td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: c.host}}
+		conn, err = td.DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(c.timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	_ = conn.SetDeadline(deadline)
+
+	var setup [][]string
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []string{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+
+	w := bufio.NewWriter(conn)
+	for _, cmd := range append(setup, cmds...) {
+		WriteCommand(w, cmd)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	br := bufio.NewReader(conn)
+	for range setup {
+		if _, err := ReadReply(br); err != nil {
+			return nil, err
+		}
+	}
+	replies := make([]any, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := ReadReply(br)
+		var er ErrorReply
+		switch {
+		case errors.As(err, &er):
+			// keep reading so every command's reply is consumed
+			if firstErr == nil {
+				firstErr = err
+			}
+		case err != nil:
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return replies, nil
+}
+
+// WriteCommand encodes args as a RESP array of bulk strings.
+func WriteCommand(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+}
+
+// ErrorReply is an error returned by the server ("-ERR ...").
+type ErrorReply string
+
+func (e ErrorReply) Error() string { return "redis: " + string(e) }
+
+// ReadReply decodes one RESP reply: string, int64, nil, []any or an ErrorReply.
+func ReadReply(br *bufio.Reader) (any, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	body := line[1:]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, ErrorReply(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = ReadReply(br); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	c, err := New("rediss://user:pw@cache.example.com/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.addr != "cache.example.com:6379" || !c.tls || c.username != "user" || c.password != "pw" || c.db != 2 {
+		t.Fatalf("unexpected %+v", c)
+	}
+	for _, u := range []string{"http://cache", "redis://", "redis://cache/x"} {
+		if _, err := New(u); err == nil {
+			t.Errorf("%s: expected error", u)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	// answers SELECT, then each command with its name, or an error for BAD
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					v, err := ReadReply(br)
+					if err != nil {
+						return
+					}
+					name, _ := v.([]any)[0].(string)
+					out := "+" + name + "\r\n"
+					if name == "BAD" {
+						out = "-ERR bad command\r\n"
+					}
+					if _, err := conn.Write([]byte(out)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	c, err := New("redis://" + ln.Addr().String() + "/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	replies, err := c.Pipeline(ctx, []string{"GET", "a"}, []string{"SET", "b", "1"})
+	if err != nil || len(replies) != 2 || replies[0] != "GET" || replies[1] != "SET" {
+		t.Fatalf("replies = %v, %v", replies, err)
+	}
+	if _, err := c.Pipeline(ctx, []string{"BAD"}, []string{"GET", "a"}); err == nil || !strings.Contains(err.Error(), "bad command") {
+		t.Fatalf("expected error reply, got %v", err)
+	}
+	if reply, err := c.Do(ctx, "PING"); reply != "PING" || err != nil {
+		t.Fatalf("Do = %v, %v", reply, err)
+	}
+}
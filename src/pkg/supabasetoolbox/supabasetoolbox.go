@@ -1,3 +1,5 @@
+// Package supabasetoolbox calls the Supabase auth (GoTrue) and storage REST APIs of
+// one project through a Client.
 package supabasetoolbox
 
 import (
@@ -8,8 +10,25 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
+// Client calls one Supabase project. In the default (public) auth mode it is built
+// from NEXT_PUBLIC_SUPABASE_URL and the anon key; in server mode, and for storage,
+// from the server-only URL and service-role key. The zero HTTP uses
+// http.DefaultClient.
+type Client struct {
+	URL    string // project base URL, e.g. https://xyz.supabase.co
+	APIKey string // sent as apikey, and as the bearer token for storage calls
+	HTTP   *http.Client
+}
+
+// New returns a Client for the project at baseURL.
+func New(baseURL, apiKey string, httpClient *http.Client) *Client {
+	return &Client{URL: strings.TrimRight(baseURL, "/"), APIKey: apiKey, HTTP: httpClient}
+}
+
 type loginResponse struct {
 	AccessToken  string `json:"access_token"`
 	TokenType    string `json:"token_type"`
@@ -20,117 +39,207 @@ type loginResponse struct {
 	} `json:"user"`
 }
 
-// GenerateSignedURL calls Supabase storage sign endpoint to produce a signed URL.
-// Provide supabaseBaseURL (e.g. https://xyz.supabase.co) and anonKey explicitly.
-func GenerateSignedURL(supabaseBaseURL, anonKey, accessToken, path string) (string, error) {
-	apiURL := fmt.Sprintf("%s/storage/v1/object/sign/flashcard-assets/%s", supabaseBaseURL, path)
-
-	expiry := 3600 // URL valid for 1 hour
-
-	body := map[string]interface{}{
-		"expiresIn": expiry,
+// do sends req with the project's apikey header.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("apikey", c.APIKey)
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
 	}
-	jsonBody, _ := json.Marshal(body)
+	return hc.Do(req)
+}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(jsonBody))
+// postJSON posts v as JSON to path (relative to the project URL).
+func (c *Client) postJSON(ctx context.Context, path string, v any) (*http.Response, error) {
+	b, _ := json.Marshal(v)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+path, bytes.NewReader(b))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("apikey", anonKey)
 	req.Header.Set("Content-Type", "application/json")
+	return c.do(req)
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// SignIn exchanges email+password for access and refresh tokens and the user id.
+func (c *Client) SignIn(ctx context.Context, email, password string) (string, string, string, error) {
+	resp, err := c.postJSON(ctx, "/auth/v1/token?grant_type=password", map[string]string{"email": email, "password": password})
 	if err != nil {
-		return "", err
+		return "", "", "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to generate signed URL: status %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("supabase sign in: status=%d body=%s", resp.StatusCode, string(body))
+		return "", "", "", &AuthError{Status: resp.StatusCode, Body: string(body)}
 	}
 
-	var result struct {
-		SignedURL string `json:"signedURL"`
-	}
+	var result loginResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return "", "", "", err
 	}
-
-	return supabaseBaseURL + "/storage/v1" + result.SignedURL, nil
+	return result.AccessToken, result.RefreshToken, result.User.ID, nil
 }
 
-// AuthenticateWithSupabase calls Supabase auth REST to exchange email+password for tokens.
-func AuthenticateWithSupabase(ctx context.Context, client *http.Client, email string, password string, supabaseURL string, apiKey string) (string, string, string, error) {
-
-	payload := map[string]string{
-		"email":    email,
-		"password": password,
+// SendConfirmation emails a user whose email is not confirmed yet a confirmation
+// link (the project's "Confirm signup" template), e.g. one made with CreateUser.
+// redirectTo, when set, is where the link sends them.
+func (c *Client) SendConfirmation(ctx context.Context, email, redirectTo string) error {
+	path := "/auth/v1/resend"
+	if redirectTo != "" {
+		path += "?" + url.Values{"redirect_to": {redirectTo}}.Encode()
 	}
-	b, _ := json.Marshal(payload)
+	resp, err := c.postJSON(ctx, path, map[string]string{"type": "signup", "email": email})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return &AuthError{Status: resp.StatusCode, Body: string(b)}
+	}
+	return nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, supabaseURL+"/auth/v1/token?grant_type=password", bytes.NewReader(b))
+// VerifyEmailToken exchanges the token_hash from a confirmation email link for a
+// session. typ is the link's type parameter (e.g. "email" or "signup").
+func (c *Client) VerifyEmailToken(ctx context.Context, tokenHash, typ string) (string, string, string, error) {
+	resp, err := c.postJSON(ctx, "/auth/v1/verify", map[string]string{"token_hash": tokenHash, "type": typ})
 	if err != nil {
 		return "", "", "", err
 	}
-	req.Header.Set("apikey", apiKey)
-	req.Header.Set("Content-Type", "application/json")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", "", "", &AuthError{Status: resp.StatusCode, Body: string(body)}
+	}
+	var result loginResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", "", "", err
+	}
+	return result.AccessToken, result.RefreshToken, result.User.ID, nil
+}
 
-	if client == nil {
-		client = http.DefaultClient
+// Refresh exchanges a refresh token for a new access and refresh token.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (string, string, error) {
+	if refreshToken == "" {
+		return "", "", fmt.Errorf("refresh token missing")
 	}
-	resp, err := client.Do(req)
+	resp, err := c.postJSON(ctx, "/auth/v1/token?grant_type=refresh_token", map[string]string{"refresh_token": refreshToken})
 	if err != nil {
-		return "", "", "", err
+		return "", "", fmt.Errorf("failed to refresh access token: %w", err)
 	}
 	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("AuthenticateWithSupabase: status=%d body=%s", resp.StatusCode, string(body))
-		return "", "", "", fmt.Errorf("http error: status %d: %s", resp.StatusCode, string(body))
+		return "", "", &AuthError{Status: resp.StatusCode, Body: string(body)}
 	}
 
-	var result struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
-		User         struct {
-			ID string `json:"id"`
-		} `json:"user"`
-	}
+	var result loginResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", "", "", err
+		return "", "", fmt.Errorf("failed to decode refreshed token: %w", err)
 	}
+	return result.AccessToken, result.RefreshToken, nil
+}
 
-	return result.AccessToken, result.RefreshToken, result.User.ID, nil
+// AuthError is a non-200 response from the Supabase auth API.
+type AuthError struct {
+	Status int
+	Body   string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("http error: status %d: %s", e.Status, e.Body)
+}
+
+// Message is the API's human readable error message, if any.
+func (e *AuthError) Message() string {
+	var b struct {
+		Msg              string `json:"msg"`
+		Message          string `json:"message"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal([]byte(e.Body), &b)
+	for _, m := range []string{b.Msg, b.Message, b.ErrorDescription} {
+		if m != "" {
+			return m
+		}
+	}
+	return ""
 }
 
-func RefreshAccessToken(r *http.Request, supabaseUrl string, apiKey string) (string, string, error) {
-	refreshCookie, err := r.Cookie("refresh_token")
+// storageRequest builds a storage API request authorised with the client's key (use
+// the service-role key for server-side storage).
+func (c *Client) storageRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+"/storage/v1/object/"+path, body)
 	if err != nil {
-		return "", "", fmt.Errorf("refresh token missing: %w", err)
+		return nil, err
 	}
-	refreshToken := refreshCookie.Value
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	return req, nil
+}
 
-	payload := map[string]string{
-		"refresh_token": refreshToken,
+// UploadObject uploads body to bucket/path, overwriting any existing object.
+func (c *Client) UploadObject(ctx context.Context, bucket, path, contentType string, body io.Reader) error {
+	req, err := c.storageRequest(ctx, http.MethodPost, bucket+"/"+path, body)
+	if err != nil {
+		return err
 	}
-	payloadBytes, _ := json.Marshal(payload)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("x-upsert", "true")
 
-	req, _ := http.NewRequest("POST", supabaseUrl+"/auth/v1/token?grant_type=refresh_token", bytes.NewReader(payloadBytes))
-	req.Header.Set("apikey", apiKey)
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("upload failed: status %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
+
+// SignObjectURL returns a signed download URL for bucket/path valid for expiresIn seconds.
+func (c *Client) SignObjectURL(ctx context.Context, bucket, path string, expiresIn int) (string, error) {
+	jsonBody, _ := json.Marshal(map[string]interface{}{"expiresIn": expiresIn})
+	req, err := c.storageRequest(ctx, http.MethodPost, "sign/"+bucket+"/"+path, bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", err
+	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return "", "", fmt.Errorf("failed to refresh access token: %w", err)
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
 	}
 	defer resp.Body.Close()
-
-	var result loginResponse
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to generate signed URL: status %d", resp.StatusCode)
+	}
+	var result struct {
+		SignedURL string `json:"signedURL"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", "", fmt.Errorf("failed to decode refreshed token: %w", err)
+		return "", err
 	}
+	return c.URL + "/storage/v1" + result.SignedURL, nil
+}
 
-	return result.AccessToken, result.RefreshToken, nil
+// DeleteObject removes bucket/path from storage. Missing objects are not an error.
+func (c *Client) DeleteObject(ctx context.Context, bucket, path string) error {
+	req, err := c.storageRequest(ctx, http.MethodDelete, bucket+"/"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		b, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("delete failed: status %d: %s", resp.StatusCode, string(b))
+	}
+	return nil
 }
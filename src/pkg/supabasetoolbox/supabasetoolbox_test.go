@@ -4,57 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
-// TestGenerateSignedURL_Success verifies we build the final URL from the signedURL response.
-func TestGenerateSignedURL_Success(t *testing.T) {
-	// mock Supabase storage sign endpoint
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			t.Fatalf("expected POST, got %s", r.Method)
-		}
-		if r.URL.Path != "/storage/v1/object/sign/flashcard-assets/myfile.jpg" {
-			t.Fatalf("unexpected path: %s", r.URL.Path)
-		}
-		var body struct {
-			ExpiresIn int `json:"expiresIn"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			t.Fatalf("decode body: %v", err)
-		}
-		resp := map[string]string{"signedURL": "/o/abc?token=xyz"}
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(resp)
-	}))
-	defer ts.Close()
-
-	out, err := GenerateSignedURL(ts.URL, "anon-key", "access-token", "myfile.jpg")
-	if err != nil {
-		t.Fatalf("GenerateSignedURL error: %v", err)
-	}
-	expected := ts.URL + "/storage/v1" + "/o/abc?token=xyz"
-	if out != expected {
-		t.Fatalf("unexpected url: got %q want %q", out, expected)
-	}
-}
-
-// TestGenerateSignedURL_Non200 ensures non-200 responses return an error.
-func TestGenerateSignedURL_Non200(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, "boom", http.StatusInternalServerError)
-	}))
-	defer ts.Close()
-
-	if _, err := GenerateSignedURL(ts.URL, "anon", "access", "x"); err == nil {
-		t.Fatal("expected error for non-200 response")
-	}
-}
-
-// TestAuthenticateWithSupabase covers success and non-200 failure.
-func TestAuthenticateWithSupabase(t *testing.T) {
+// TestSignIn covers success and non-200 failure.
+func TestSignIn(t *testing.T) {
 	tsOK := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// ensure query grant_type=password present
 		if r.URL.RawQuery != "grant_type=password" {
@@ -78,10 +36,9 @@ func TestAuthenticateWithSupabase(t *testing.T) {
 	}))
 	defer tsOK.Close()
 
-	cli := tsOK.Client()
-	at, rt, uid, err := AuthenticateWithSupabase(context.Background(), cli, "a", "b", tsOK.URL, "key")
+	at, rt, uid, err := New(tsOK.URL, "key", tsOK.Client()).SignIn(context.Background(), "a", "b")
 	if err != nil {
-		t.Fatalf("AuthenticateWithSupabase failed: %v", err)
+		t.Fatalf("SignIn failed: %v", err)
 	}
 	if at != "at" || rt != "rt" || uid != "uid-1" {
 		t.Fatalf("unexpected tokens: %s %s %s", at, rt, uid)
@@ -93,58 +50,168 @@ func TestAuthenticateWithSupabase(t *testing.T) {
 	}))
 	defer tsBad.Close()
 
-	_, _, _, err = AuthenticateWithSupabase(context.Background(), tsBad.Client(), "a", "b", tsBad.URL, "key")
+	_, _, _, err = New(tsBad.URL, "key", tsBad.Client()).SignIn(context.Background(), "a", "b")
 	if err == nil {
 		t.Fatal("expected error for non-200 response")
 	}
 }
 
-// TestRefreshAccessToken exercises missing cookie, success and non-200 cases.
-func TestRefreshAccessToken(t *testing.T) {
-	// success server
+// TestRefresh exercises a missing token, success and non-200 cases.
+func TestRefresh(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var p map[string]string
 		_ = json.NewDecoder(r.Body).Decode(&p)
-		_ = p
-		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("grant_type") != "refresh_token" || r.Header.Get("apikey") != "k" {
+			t.Fatalf("unexpected request %s apikey=%q", r.URL, r.Header.Get("apikey"))
+		}
+		if p["refresh_token"] != "old" {
+			http.Error(w, `{"msg":"Invalid Refresh Token"}`, http.StatusBadRequest)
+			return
+		}
 		_ = json.NewEncoder(w).Encode(loginResponse{
 			AccessToken:  "new-at",
 			RefreshToken: "new-rt",
 		})
 	}))
 	defer ts.Close()
+	c := New(ts.URL+"/", "k", ts.Client())
+	ctx := context.Background()
 
-	// preserve and restore default client
-	origClient := http.DefaultClient
-	http.DefaultClient = ts.Client()
-	defer func() { http.DefaultClient = origClient }()
-
-	// missing cookie
-	reqNoCookie := &http.Request{Header: http.Header{}}
-	if _, _, err := RefreshAccessToken(reqNoCookie, ts.URL, "k"); err == nil {
-		t.Fatal("expected error when refresh cookie missing")
+	if _, _, err := c.Refresh(ctx, ""); err == nil {
+		t.Fatal("expected error when refresh token missing")
 	}
-
-	// with cookie
-	req, _ := http.NewRequest("POST", "/", bytes.NewReader(nil))
-	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: "old"})
-	at, rt, err := RefreshAccessToken(req, ts.URL, "k")
+	at, rt, err := c.Refresh(ctx, "old")
 	if err != nil {
-		t.Fatalf("RefreshAccessToken failed: %v", err)
+		t.Fatalf("Refresh failed: %v", err)
 	}
 	if at != "new-at" || rt != "new-rt" {
 		t.Fatalf("unexpected tokens: %s %s", at, rt)
 	}
+	var ae *AuthError
+	if _, _, err := c.Refresh(ctx, "spent"); !errors.As(err, &ae) || ae.Message() != "Invalid Refresh Token" {
+		t.Fatalf("expected AuthError for non-200 refresh response, got %v", err)
+	}
+}
 
-	// server returns non-200
-	tsErr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, "bad", http.StatusBadRequest)
+// TestUploadAndSignObject covers the storage upload and sign helpers.
+func TestUploadAndSignObject(t *testing.T) {
+	var uploaded []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("apikey") != "svc" || r.Header.Get("Authorization") != "Bearer svc" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/storage/v1/object/bucket/a/b.png":
+			if r.Header.Get("Content-Type") != "image/png" {
+				t.Fatalf("unexpected content type: %s", r.Header.Get("Content-Type"))
+			}
+			buf := new(bytes.Buffer)
+			_, _ = buf.ReadFrom(r.Body)
+			uploaded = buf.Bytes()
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && r.URL.Path == "/storage/v1/object/sign/bucket/a/b.png":
+			_ = json.NewEncoder(w).Encode(map[string]string{"signedURL": "/object/sign/bucket/a/b.png?token=t"})
+		default:
+			http.Error(w, "unexpected", http.StatusBadRequest)
+		}
 	}))
-	defer tsErr.Close()
-	http.DefaultClient = tsErr.Client()
-	req2, _ := http.NewRequest("POST", "/", nil)
-	req2.AddCookie(&http.Cookie{Name: "refresh_token", Value: "old"})
-	if _, _, err := RefreshAccessToken(req2, tsErr.URL, "k"); err == nil {
-		t.Fatal("expected error for non-200 refresh response")
+	defer ts.Close()
+
+	ctx := context.Background()
+	c := New(ts.URL, "svc", ts.Client())
+	if err := c.UploadObject(ctx, "bucket", "a/b.png", "image/png", bytes.NewReader([]byte("img"))); err != nil {
+		t.Fatalf("UploadObject error: %v", err)
+	}
+	if string(uploaded) != "img" {
+		t.Fatalf("unexpected uploaded body: %q", uploaded)
+	}
+	u, err := c.SignObjectURL(ctx, "bucket", "a/b.png", 60)
+	if err != nil {
+		t.Fatalf("SignObjectURL error: %v", err)
+	}
+	if u != ts.URL+"/storage/v1/object/sign/bucket/a/b.png?token=t" {
+		t.Fatalf("unexpected signed url: %s", u)
+	}
+}
+
+// TestCreateUser makes an unconfirmed user with the service-role key, and reports
+// an API error message.
+func TestCreateUser(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/auth/v1/admin/users" || r.Header.Get("Authorization") != "Bearer service" {
+			t.Fatalf("unexpected request %s %s auth=%q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["email_confirm"] != false || body["password"] != "pw" {
+			t.Fatalf("unexpected body %v", body)
+		}
+		if body["email"] == "taken@example.com" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"code":422,"msg":"A user with this email address has already been registered"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"u1","email":"new@example.com"}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "service", ts.Client())
+	u, err := c.CreateUser(context.Background(), "new@example.com", "pw")
+	if err != nil || u.ID != "u1" {
+		t.Fatalf("create: %+v %v", u, err)
+	}
+	_, err = c.CreateUser(context.Background(), "taken@example.com", "pw")
+	var ae *AuthError
+	if !errors.As(err, &ae) || ae.Status != http.StatusUnprocessableEntity || !strings.Contains(ae.Message(), "already been registered") {
+		t.Fatalf("expected AuthError, got %v", err)
+	}
+}
+
+// TestSendConfirmation asks for a signup confirmation email with a redirect.
+func TestSendConfirmation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/auth/v1/resend" || body["type"] != "signup" || body["email"] != "new@example.com" {
+			t.Fatalf("unexpected request %s %v", r.URL.Path, body)
+		}
+		if got := r.URL.Query().Get("redirect_to"); got != "http://app/confirm" {
+			t.Fatalf("redirect_to = %q", got)
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "anon", ts.Client())
+	if err := c.SendConfirmation(context.Background(), "new@example.com", "http://app/confirm"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestVerifyEmailToken exchanges a token hash for a session.
+func TestVerifyEmailToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/auth/v1/verify" || body["type"] != "email" {
+			t.Fatalf("unexpected request %s %v", r.URL.Path, body)
+		}
+		if body["token_hash"] != "good" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"msg":"Token has expired or is invalid"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"at","refresh_token":"rt","user":{"id":"u1"}}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "anon", ts.Client())
+	access, refresh, userID, err := c.VerifyEmailToken(context.Background(), "good", "email")
+	if err != nil || access != "at" || refresh != "rt" || userID != "u1" {
+		t.Fatalf("got %q %q %q %v", access, refresh, userID, err)
+	}
+	if _, _, _, err := c.VerifyEmailToken(context.Background(), "bad", "email"); err == nil {
+		t.Fatal("expected error for invalid token")
 	}
 }
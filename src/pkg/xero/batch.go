@@ -0,0 +1,63 @@
+package xero
+
+import (
+	"context"
+	"sync"
+)
+
+// MaxConcurrency is Xero's limit on concurrent calls per tenant; larger
+// Client.Concurrency values are capped to it.
+const MaxConcurrency = 5
+
+// DefaultConcurrency is how many batched lookups run at once when Client.Concurrency
+// is 0, leaving one of the tenant's concurrent calls for the rest of the app.
+const DefaultConcurrency = 4
+
+func (c *Client) concurrency() int {
+	switch n := c.Concurrency; {
+	case n <= 0:
+		return DefaultConcurrency
+	case n > MaxConcurrency:
+		return MaxConcurrency
+	default:
+		return n
+	}
+}
+
+// eachBatch calls fn for each [start, end) slice of n entries, size at a time,
+// running up to c.concurrency() calls at once; fn must be safe to call concurrently.
+// Each request still goes through the RetryPolicy, so a 429 backs off per its
+// Retry-After. The first error cancels the batches still running and is returned.
+func (c *Client) eachBatch(ctx context.Context, n, size int, fn func(ctx context.Context, start, end int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	sem := make(chan struct{}, c.concurrency())
+	for start := 0; start < n; start += size {
+		end := min(start+size, n)
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if err := fn(ctx, start, end); err != nil {
+				once.Do(func() { first = err; cancel() })
+			}
+		}()
+	}
+	wg.Wait()
+	if first != nil {
+		return first
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,59 @@
+package xero
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The real Xero hosts: the accounting API and /connections, the OAuth token and
+// discovery endpoints, and the browser-facing authorize page.
+const (
+	DefaultAPIURL      = "https://api.xero.com"
+	DefaultIdentityURL = "https://identity.xero.com"
+	DefaultLoginURL    = "https://login.xero.com"
+)
+
+// Client calls Xero. Empty fields fall back to the real Xero hosts and
+// http.DefaultClient, so the zero value talks to production Xero.
+type Client struct {
+	BaseURL     string       // accounting API and /connections
+	IdentityURL string       // OAuth token and openid discovery
+	LoginURL    string       // browser authorize page
+	HTTPClient  *http.Client // used for every request
+	Retry       *RetryPolicy // nil uses DefaultRetryPolicy
+	// Concurrency caps the requests one batched lookup (GetItemsByCodes, supplier
+	// sync) runs at once: 0 uses DefaultConcurrency, anything over MaxConcurrency is
+	// capped to it.
+	Concurrency int
+}
+
+// NewClient returns a Client that sends requests with httpClient. A non-empty base
+// points all three hosts at it, e.g. a proxy or a pkg/xerotest server; an empty base
+// uses the real Xero hosts.
+func NewClient(httpClient *http.Client, base string) *Client {
+	base = strings.TrimRight(base, "/")
+	return &Client{BaseURL: base, IdentityURL: base, LoginURL: base, HTTPClient: httpClient}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// apiURL returns the accounting API base URL (no trailing slash).
+func (c *Client) apiURL() string { return orDefault(c.BaseURL, DefaultAPIURL) }
+
+// identityURL returns the OAuth identity base URL.
+func (c *Client) identityURL() string { return orDefault(c.IdentityURL, DefaultIdentityURL) }
+
+// loginURL returns the base URL of the browser authorize page.
+func (c *Client) loginURL() string { return orDefault(c.LoginURL, DefaultLoginURL) }
+
+func orDefault(u, def string) string {
+	if u == "" {
+		return def
+	}
+	return strings.TrimRight(u, "/")
+}
@@ -0,0 +1,112 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Contact is a Xero Contact as listed by the Contacts endpoint. Raw keeps the full
+// object (addresses, balances, groups, ...) for callers that pass it through.
+type Contact struct {
+	ContactID      string         `json:"ContactID"`
+	Name           string         `json:"Name"`
+	AccountNumber  string         `json:"AccountNumber"`
+	EmailAddress   string         `json:"EmailAddress"`
+	ContactStatus  string         `json:"ContactStatus"`
+	IsSupplier     bool           `json:"IsSupplier"`
+	IsCustomer     bool           `json:"IsCustomer"`
+	Phones         []ContactPhone `json:"Phones"`
+	UpdatedDateUTC Timestamp      `json:"UpdatedDateUTC"`
+
+	// DefaultCurrency is the ISO 4217 code the contact trades in; "" when not set
+	DefaultCurrency string `json:"DefaultCurrency"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// ContactPhone is one of a contact's phone numbers.
+type ContactPhone struct {
+	PhoneType   string `json:"PhoneType"`
+	PhoneNumber string `json:"PhoneNumber"`
+}
+
+// DefaultPhone returns the DEFAULT phone number of the contact.
+func (c Contact) DefaultPhone() string {
+	for _, p := range c.Phones {
+		if p.PhoneType == "DEFAULT" {
+			return p.PhoneNumber
+		}
+	}
+	return ""
+}
+
+// ContactsQuery narrows EachContactsPage.
+type ContactsQuery struct {
+	// ModifiedSince, when set, only returns contacts changed after it.
+	ModifiedSince time.Time
+}
+
+// contactsPageSize is Xero's fixed page size for the Contacts endpoint.
+const contactsPageSize = 100
+
+func parseContacts(b []byte) ([]Contact, error) {
+	var res struct {
+		Contacts []json.RawMessage `json:"Contacts"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	out := make([]Contact, 0, len(res.Contacts))
+	for _, raw := range res.Contacts {
+		var c Contact
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, err
+		}
+		c.Raw = raw
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// EachContactsPage lists every contact of the tenant matching q a page at a time,
+// calling fn with each non-empty page as it arrives so callers can stream large
+// address books. An error from fn stops paging and is returned as is.
+func (c *Client) EachContactsPage(ctx context.Context, accessToken, tenantID string, q ContactsQuery, fn func([]Contact) error) error {
+	for page := 1; page <= 50; page++ { // safety cap at 50 pages
+		v := url.Values{}
+		v.Set("page", fmt.Sprint(page))
+		u := c.apiURL() + "/api.xro/2.0/Contacts?" + v.Encode()
+		req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+		if err != nil {
+			return err
+		}
+		setModifiedSince(req, q.ModifiedSince)
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusNotModified {
+			break
+		}
+		if status >= 300 {
+			return statusError("list contacts", status, body)
+		}
+		contacts, err := parseContacts(body)
+		if err != nil {
+			return err
+		}
+		if len(contacts) > 0 {
+			if err := fn(contacts); err != nil {
+				return err
+			}
+		}
+		if len(contacts) < contactsPageSize {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func contactsPage(page string, n int) string {
+	var b strings.Builder
+	b.WriteString(`{"Contacts":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"ContactID":"c%s-%d","Name":"Contact %d","IsSupplier":true,"Phones":[{"PhoneType":"DEFAULT","PhoneNumber":"0%d"}],"Balances":{}}`, page, i, i, i)
+	}
+	b.WriteString(`]}`)
+	return b.String()
+}
+
+func TestEachContactsPage_Paginates(t *testing.T) {
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api.xro/2.0/Contacts" {
+			http.Error(w, "unexpected", http.StatusBadRequest)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		n := 0
+		if page == "1" {
+			n = contactsPageSize // full page -> fetch next
+		} else if page == "2" {
+			n = 2
+		}
+		_, _ = w.Write([]byte(contactsPage(page, n)))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	var got []Contact
+	err := client.EachContactsPage(context.Background(), "at", "tid", ContactsQuery{}, func(cs []Contact) error {
+		got = append(got, cs...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(got) != contactsPageSize+2 || len(pages) != 2 {
+		t.Fatalf("got %d contacts over pages %v", len(got), pages)
+	}
+	last := got[len(got)-1]
+	if last.ContactID != "c2-1" || !last.IsSupplier || last.DefaultPhone() != "01" {
+		t.Fatalf("unexpected contact: %+v", last)
+	}
+	if !strings.Contains(string(last.Raw), `"Balances":{}`) {
+		t.Fatalf("raw contact not kept: %s", last.Raw)
+	}
+}
+
+func TestEachContactsPage_StopsOnCallbackError(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(contactsPage(r.URL.Query().Get("page"), contactsPageSize)))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	stop := errors.New("stop")
+	err := client.EachContactsPage(context.Background(), "at", "tid", ContactsQuery{}, func([]Contact) error { return stop })
+	if !errors.Is(err, stop) || requests != 1 {
+		t.Fatalf("err=%v after %d requests", err, requests)
+	}
+}
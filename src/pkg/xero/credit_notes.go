@@ -0,0 +1,93 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// CreditNoteTypeSales is the type of a credit note against sales invoices (a
+// customer refund or reduction).
+const CreditNoteTypeSales = "ACCRECCREDIT"
+
+// CreditNote is a Xero credit note. Invoices are the numbers of the invoices it is
+// allocated to, in allocation order.
+type CreditNote struct {
+	CreditNoteID     string
+	CreditNoteNumber string
+	Type             string
+	Status           string
+	Lines            []InvoiceLine
+	Invoices         []string
+}
+
+// parseCreditNote reads the first credit note of a CreditNotes response.
+func parseCreditNote(b []byte) (CreditNote, error) {
+	var res struct {
+		CreditNotes []struct {
+			CreditNoteID     string `json:"CreditNoteID"`
+			CreditNoteNumber string `json:"CreditNoteNumber"`
+			Type             string `json:"Type"`
+			Status           string `json:"Status"`
+			LineItems        []struct {
+				ItemCode    string  `json:"ItemCode"`
+				Description string  `json:"Description"`
+				Quantity    float64 `json:"Quantity"`
+				Item        struct {
+					Name string `json:"Name"`
+				} `json:"Item"`
+			} `json:"LineItems"`
+			Allocations []struct {
+				Invoice struct {
+					InvoiceNumber string `json:"InvoiceNumber"`
+				} `json:"Invoice"`
+			} `json:"Allocations"`
+		} `json:"CreditNotes"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return CreditNote{}, err
+	}
+	if len(res.CreditNotes) == 0 {
+		return CreditNote{}, fmt.Errorf("get credit note: none returned")
+	}
+	cn := res.CreditNotes[0]
+	out := CreditNote{CreditNoteID: cn.CreditNoteID, CreditNoteNumber: cn.CreditNoteNumber, Type: cn.Type, Status: cn.Status}
+	for _, li := range cn.LineItems {
+		name := li.Item.Name
+		if name == "" {
+			name = li.Description
+		}
+		out.Lines = append(out.Lines, InvoiceLine{ItemCode: li.ItemCode, Name: name, Quantity: li.Quantity})
+	}
+	for _, a := range cn.Allocations {
+		if n := a.Invoice.InvoiceNumber; n != "" && !slices.Contains(out.Invoices, n) {
+			out.Invoices = append(out.Invoices, n)
+		}
+	}
+	return out, nil
+}
+
+// GetCreditNote returns the credit note with the given CreditNoteNumber (or
+// CreditNoteID), with its lines and allocations. An unknown one fails with an
+// error matching ErrNotFound.
+func (c *Client) GetCreditNote(ctx context.Context, accessToken, tenantID, number string) (CreditNote, error) {
+	if number == "" {
+		return CreditNote{}, fmt.Errorf("credit note number empty")
+	}
+	u := c.apiURL() + "/api.xro/2.0/CreditNotes/" + url.PathEscape(number)
+	req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+	if err != nil {
+		return CreditNote{}, err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return CreditNote{}, err
+	}
+	if status >= 300 {
+		return CreditNote{}, statusError("get credit note", status, body)
+	}
+	return parseCreditNote(body)
+}
@@ -0,0 +1,39 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetCreditNote(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api.xro/2.0/CreditNotes/CN-0007" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"CreditNotes":[{"CreditNoteID":"cn-7","CreditNoteNumber":"CN-0007","Type":"ACCRECCREDIT","Status":"AUTHORISED",
+"LineItems":[{"ItemCode":"KIT-001","Description":"Cabinet door kit","Quantity":1,"Item":{"Name":"Door kit"}},{"Description":"Goodwill","Quantity":1}],
+"Allocations":[{"Amount":45,"Invoice":{"InvoiceNumber":"INV-0001"}},{"Amount":5,"Invoice":{"InvoiceNumber":"INV-0001"}},{"Amount":9.99,"Invoice":{"InvoiceNumber":"INV-0002"}}]}]}`))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	cn, err := client.GetCreditNote(context.Background(), "at", "tid", "CN-0007")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn.Type != CreditNoteTypeSales || cn.Status != "AUTHORISED" || fmt.Sprint(cn.Invoices) != "[INV-0001 INV-0002]" {
+		t.Fatalf("unexpected credit note: %+v", cn)
+	}
+	if len(cn.Lines) != 2 || cn.Lines[0] != (InvoiceLine{ItemCode: "KIT-001", Name: "Door kit", Quantity: 1}) || cn.Lines[1].Name != "Goodwill" {
+		t.Fatalf("unexpected lines: %+v", cn.Lines)
+	}
+
+	if _, err := client.GetCreditNote(context.Background(), "at", "tid", "CN-404"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown credit note: err = %v, want ErrNotFound", err)
+	}
+}
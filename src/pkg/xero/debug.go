@@ -0,0 +1,136 @@
+package xero
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// DefaultDebugBodyLimit is how much of a response body DebugTransport keeps.
+const DefaultDebugBodyLimit = 2048
+
+// APICall is one HTTP exchange observed by DebugTransport. URL and ResponseBody have
+// secrets redacted; ResponseBody is truncated.
+type APICall struct {
+	At           time.Time
+	Method       string
+	URL          string
+	TenantID     string // Xero-tenant-id header, when sent
+	Status       int    // 0 when the request failed before a response
+	Latency      time.Duration
+	Err          string
+	ResponseBody string
+}
+
+// DebugTransport logs every request sent through it for troubleshooting. Bodies
+// are read in full so the caller still sees them, but only the first BodyLimit
+// bytes are logged.
+type DebugTransport struct {
+	Base   http.RoundTripper // http.DefaultTransport when nil
+	Logger *slog.Logger      // slog.Default() when nil
+	// Attrs adds request-scoped attributes (e.g. a request id) to each log record.
+	Attrs func(ctx context.Context) []slog.Attr
+	// Sink, when set, also receives every call, e.g. to store it.
+	Sink      func(ctx context.Context, call APICall)
+	BodyLimit int // DefaultDebugBodyLimit when 0
+}
+
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	call := APICall{
+		At:       time.Now(),
+		Method:   req.Method,
+		URL:      RedactURL(req.URL),
+		TenantID: req.Header.Get("Xero-tenant-id"),
+	}
+	resp, err := base.RoundTrip(req)
+	call.Latency = time.Since(call.At)
+	if err != nil {
+		call.Err = err.Error()
+	} else {
+		call.Status = resp.StatusCode
+		b, rerr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		if rerr != nil {
+			call.Err = "read body: " + rerr.Error()
+		}
+		call.ResponseBody = t.truncate(RedactBody(b), len(b))
+	}
+	t.log(req.Context(), call)
+	if t.Sink != nil {
+		t.Sink(req.Context(), call)
+	}
+	return resp, err
+}
+
+// truncate cuts the (redacted) body b of an n byte response to the body limit.
+func (t *DebugTransport) truncate(b []byte, n int) string {
+	limit := t.BodyLimit
+	if limit <= 0 {
+		limit = DefaultDebugBodyLimit
+	}
+	if len(b) <= limit {
+		return string(b)
+	}
+	return fmt.Sprintf("%s…(truncated, %d bytes)", b[:limit], n)
+}
+
+func (t *DebugTransport) log(ctx context.Context, call APICall) {
+	logger := t.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []slog.Attr{
+		slog.String("method", call.Method),
+		slog.String("url", call.URL),
+		slog.Int("status", call.Status),
+		slog.Duration("latency", call.Latency),
+	}
+	if call.TenantID != "" {
+		attrs = append(attrs, slog.String("tenant_id", call.TenantID))
+	}
+	if call.Err != "" {
+		attrs = append(attrs, slog.String("error", call.Err))
+	}
+	attrs = append(attrs, slog.String("body", call.ResponseBody))
+	if t.Attrs != nil {
+		attrs = append(attrs, t.Attrs(ctx)...)
+	}
+	level := slog.LevelInfo
+	if call.Err != "" || call.Status >= 400 {
+		level = slog.LevelWarn
+	}
+	logger.LogAttrs(ctx, level, "xero api call", attrs...)
+}
+
+// secretFields are JSON fields and query parameters whose values are never logged.
+var secretFields = `access_token|refresh_token|id_token|client_secret|code|code_verifier|password`
+
+var secretJSON = regexp.MustCompile(`("(?:` + secretFields + `)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+var secretForm = regexp.MustCompile(`((?:^|&)(?:` + secretFields + `)=)[^&]*`)
+
+// RedactBody replaces token, secret and password values in a JSON or form-encoded body.
+func RedactBody(b []byte) []byte {
+	b = secretJSON.ReplaceAll(b, []byte(`$1"[REDACTED]"`))
+	return secretForm.ReplaceAll(b, []byte(`$1[REDACTED]`))
+}
+
+// RedactURL is u as a string with secret query parameters replaced.
+func RedactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	c := *u
+	c.RawQuery = string(RedactBody([]byte(u.RawQuery)))
+	return c.String()
+}
@@ -0,0 +1,75 @@
+package xero
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	body := `{"access_token":"eyJ.secret","token_type":"Bearer","refresh_token":"r-\"1","Items":[{"Code":"BOLT"}]}`
+	got := string(RedactBody([]byte(body)))
+	want := `{"access_token":"[REDACTED]","token_type":"Bearer","refresh_token":"[REDACTED]","Items":[{"Code":"BOLT"}]}`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	if got := string(RedactBody([]byte("grant_type=authorization_code&code=abc&client_secret=s"))); got != "grant_type=authorization_code&code=[REDACTED]&client_secret=[REDACTED]" {
+		t.Fatalf("form: %s", got)
+	}
+	r := httptest.NewRequest(http.MethodGet, "https://login.xero.com/authorize?code=abc&state=x", nil)
+	if got := RedactURL(r.URL); got != "https://login.xero.com/authorize?code=[REDACTED]&state=x" {
+		t.Fatalf("url: %s", got)
+	}
+}
+
+func TestDebugTransport(t *testing.T) {
+	body := `{"access_token":"tok","Items":[{"Code":"A","Name":"` + strings.Repeat("x", 100) + `"}]}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	var logs bytes.Buffer
+	var calls []APICall
+	type reqIDKey struct{}
+	client := NewClient(&http.Client{Transport: &DebugTransport{
+		Base:   ts.Client().Transport,
+		Logger: slog.New(slog.NewTextHandler(&logs, nil)),
+		Attrs: func(ctx context.Context) []slog.Attr {
+			id, _ := ctx.Value(reqIDKey{}).(string)
+			return []slog.Attr{slog.String("request_id", id)}
+		},
+		Sink:      func(ctx context.Context, c APICall) { calls = append(calls, c) },
+		BodyLimit: 40,
+	}}, ts.URL)
+
+	ctx := context.WithValue(context.Background(), reqIDKey{}, "req-7")
+	name, ok, err := client.GetItemNameByCode(ctx, "at", "tenant-1", "A")
+	if err != nil || !ok || !strings.HasPrefix(name, "xxx") {
+		t.Fatalf("the caller must still get the full body: %q %v %v", name, ok, err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("%d calls recorded", len(calls))
+	}
+	c := calls[0]
+	if c.Method != http.MethodGet || c.Status != http.StatusOK || c.TenantID != "tenant-1" || !strings.Contains(c.URL, "/api.xro/2.0/Items") {
+		t.Fatalf("unexpected call %+v", c)
+	}
+	if !strings.HasPrefix(c.ResponseBody, `{"access_token":"[REDACTED]","Items":[{`) || !strings.HasSuffix(c.ResponseBody, fmt.Sprintf("(truncated, %d bytes)", len(body))) {
+		t.Fatalf("body not redacted and truncated: %s", c.ResponseBody)
+	}
+	out := logs.String()
+	for _, want := range []string{"msg=\"xero api call\"", "method=GET", "status=200", "tenant_id=tenant-1", "request_id=req-7", "latency="} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log missing %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `\"tok\"`) {
+		t.Fatalf("token logged:\n%s", out)
+	}
+}
@@ -0,0 +1,33 @@
+// Package xero is a small client for the Xero accounting API, used by the web app
+// and the control panel. It is its own Go module
+// (github.com/hwalton/xero-invoice-orderer/pkg/xero) with no dependencies outside
+// the standard library, so other tools can use it without the web app.
+//
+// The API is grouped as follows:
+//
+//   - Client and OAuth: NewClient, BuildAuthURL, ExchangeCodeForToken, RefreshToken,
+//     GetConnections, Ping.
+//   - Organisation: GetOrganisation, GetOrganisationLimits (with the RateLimits Xero
+//     reports).
+//   - Items: GetAllItems, GetItemsByCodes, GetItemIDByCode, GetItemNameByCode,
+//     GetItemNameByID, UpsertItemsBatch, SyncPartsToXero, UpdatePurchasePrices.
+//   - Invoices: ListInvoices, SearchInvoices, GetInvoiceItemCodes, ListBills,
+//     LatestBill.
+//   - Credit notes: GetCreditNote.
+//   - Contacts: EachContactsPage, GetContactIDByAccountNumber,
+//     GetContactIDsByAccountNumbers, GetContactCurrencies, UpsertContactsBatch,
+//     SyncSuppliersToXero.
+//   - Purchase orders: CreatePurchaseOrder, ListPurchaseOrders, GetPurchaseOrder.
+//   - Tracking: GetTrackingCategories, CreateTrackingOption.
+//   - Webhooks: VerifyWebhook, ParseWebhook, SignWebhook.
+//
+// Every call takes the access token and tenant id explicitly; token storage and
+// refresh scheduling are left to the caller. GET requests are retried on 429 and 5xx
+// per the Client's RetryPolicy, except when the transport reports the host down
+// (CircuitOpenError). A call Xero refuses returns an *APIError: match it with
+// errors.Is against ErrNotFound, ErrRateLimited or ErrAuthExpired, or errors.As a
+// *ValidationError for the messages of a 400. Batched lookups send up to Client.Concurrency requests at once,
+// within Xero's limit of 5 concurrent calls per tenant. Exported names only change in
+// backwards compatible ways; for a fake Xero in tests, see pkg/xerotest in the web
+// app module.
+package xero
@@ -0,0 +1,107 @@
+package xero
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors matched by errors.Is on the *APIError of a failed call.
+var (
+	// ErrNotFound is a 404: the record does not exist in the tenant.
+	ErrNotFound = errors.New("xero: not found")
+	// ErrRateLimited is a 429 that was still refused after the retries.
+	ErrRateLimited = errors.New("xero: rate limited")
+	// ErrAuthExpired is a 401 or 403: the access token was rejected or the
+	// connection no longer has access to the tenant.
+	ErrAuthExpired = errors.New("xero: access token rejected")
+)
+
+// APIError is a call Xero answered with a non-success status. errors.Is matches it
+// against ErrNotFound, ErrRateLimited and ErrAuthExpired by status, and errors.As
+// finds a *ValidationError when Xero explained a 400.
+type APIError struct {
+	Op     string // what was attempted, e.g. "list items"
+	Status int
+	Body   string
+	// Validation holds Xero's messages for a 400, nil when there were none.
+	Validation *ValidationError
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s failed: status=%d", e.Op, e.Status)
+	}
+	return fmt.Sprintf("%s failed: status=%d body=%s", e.Op, e.Status, e.Body)
+}
+
+// Is reports whether target is the sentinel for e's status.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrRateLimited:
+		return e.Status == http.StatusTooManyRequests
+	case ErrAuthExpired:
+		return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+	}
+	return false
+}
+
+func (e *APIError) Unwrap() error {
+	if e.Validation == nil {
+		return nil
+	}
+	return e.Validation
+}
+
+// ValidationError is a request Xero refused as invalid (400), with the messages it
+// gave, e.g. "Item code 'X' is not valid".
+type ValidationError struct {
+	Messages []string
+}
+
+func (e *ValidationError) Error() string {
+	return "xero: validation failed: " + strings.Join(e.Messages, "; ")
+}
+
+// statusError is the error for a call that got status with body.
+func statusError(op string, status int, body []byte) *APIError {
+	e := &APIError{Op: op, Status: status, Body: string(body)}
+	if status == http.StatusBadRequest {
+		if msgs := validationMessages(body); len(msgs) > 0 {
+			e.Validation = &ValidationError{Messages: msgs}
+		}
+	}
+	return e
+}
+
+// validationMessages collects the messages of a Xero 400 body: the ValidationErrors
+// of each element, else the top-level Message.
+func validationMessages(body []byte) []string {
+	var resp struct {
+		Message  string `json:"Message"`
+		Elements []struct {
+			ValidationErrors []struct {
+				Message string `json:"Message"`
+			} `json:"ValidationErrors"`
+		} `json:"Elements"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	var msgs []string
+	for _, el := range resp.Elements {
+		for _, ve := range el.ValidationErrors {
+			if ve.Message != "" {
+				msgs = append(msgs, ve.Message)
+			}
+		}
+	}
+	if len(msgs) == 0 && resp.Message != "" {
+		msgs = append(msgs, resp.Message)
+	}
+	return msgs
+}
@@ -0,0 +1,55 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIErrorSentinels(t *testing.T) {
+	cases := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusUnauthorized, ErrAuthExpired},
+		{http.StatusForbidden, ErrAuthExpired},
+	}
+	for _, c := range cases {
+		err := error(statusError("get organisation", c.status, nil))
+		if !errors.Is(err, c.want) {
+			t.Errorf("status %d: errors.Is(%v) = false", c.status, c.want)
+		}
+		for _, other := range []error{ErrNotFound, ErrRateLimited, ErrAuthExpired} {
+			if other != c.want && errors.Is(err, other) {
+				t.Errorf("status %d also matches %v", c.status, other)
+			}
+		}
+	}
+	if err := statusError("list items", http.StatusInternalServerError, []byte("oops")); err.Error() != "list items failed: status=500 body=oops" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestCreatePurchaseOrder_ValidationError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"ErrorNumber":10,"Type":"ValidationException","Message":"A validation exception occurred",
+"Elements":[{"ValidationErrors":[{"Message":"Item code 'X' is not valid"},{"Message":"Account code '999' is not a valid code"}]}]}`))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	_, err := client.CreatePurchaseOrder(context.Background(), "at", "tid", "contact-1", []POItem{{ItemCode: "X", Quantity: 1}}, PODetails{})
+	var ve *ValidationError
+	if !errors.As(err, &ve) || len(ve.Messages) != 2 || ve.Messages[0] != "Item code 'X' is not valid" {
+		t.Fatalf("err = %v, want a ValidationError with Xero's messages", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Fatalf("err = %v, want an APIError with status 400", err)
+	}
+}
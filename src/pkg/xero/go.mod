@@ -0,0 +1,3 @@
+module github.com/hwalton/xero-invoice-orderer/pkg/xero
+
+go 1.24.3
@@ -0,0 +1,263 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Invoice is a Xero Invoice as listed by ListInvoices. Raw keeps the full object
+// (line items, contact, totals, ...) for callers that need more than the summary.
+type Invoice struct {
+	InvoiceID      string    `json:"InvoiceID"`
+	InvoiceNumber  string    `json:"InvoiceNumber"`
+	Type           string    `json:"Type"`
+	Status         string    `json:"Status"`
+	UpdatedDateUTC Timestamp `json:"UpdatedDateUTC"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// InvoicesQuery narrows ListInvoices.
+type InvoicesQuery struct {
+	// ModifiedSince, when set, only returns invoices changed after it.
+	ModifiedSince time.Time
+}
+
+// invoicesPageSize is Xero's fixed page size for the Invoices endpoint.
+const invoicesPageSize = 100
+
+func parseInvoices(b []byte) ([]Invoice, error) {
+	var res struct {
+		Invoices []json.RawMessage `json:"Invoices"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	out := make([]Invoice, 0, len(res.Invoices))
+	for _, raw := range res.Invoices {
+		var inv Invoice
+		if err := json.Unmarshal(raw, &inv); err != nil {
+			return nil, err
+		}
+		inv.Raw = raw
+		out = append(out, inv)
+	}
+	return out, nil
+}
+
+// ListInvoices returns every invoice of the tenant matching q (all pages, at most 50).
+func (c *Client) ListInvoices(ctx context.Context, accessToken, tenantID string, q InvoicesQuery) ([]Invoice, error) {
+	var out []Invoice
+	for page := 1; page <= 50; page++ { // safety cap at 50 pages
+		v := url.Values{}
+		v.Set("page", fmt.Sprint(page))
+		u := c.apiURL() + "/api.xro/2.0/Invoices?" + v.Encode()
+		req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		setModifiedSince(req, q.ModifiedSince)
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return nil, err
+		}
+		if status == http.StatusNotModified {
+			break
+		}
+		if status >= 300 {
+			return nil, statusError("list invoices", status, body)
+		}
+		invoices, err := parseInvoices(body)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, invoices...)
+		if len(invoices) < invoicesPageSize {
+			break
+		}
+	}
+	return out, nil
+}
+
+// InvoiceSummary is one row of SearchInvoices.
+type InvoiceSummary struct {
+	InvoiceID     string          `json:"InvoiceID"`
+	InvoiceNumber string          `json:"InvoiceNumber"`
+	Type          string          `json:"Type"`
+	Status        string          `json:"Status"`
+	Contact       PurchaseContact `json:"Contact"`
+	Date          Timestamp       `json:"DateString"` // the invoice date
+	Total         float64         `json:"Total"`
+	CurrencyCode  string          `json:"CurrencyCode"`
+	// CurrencyRate is units of CurrencyCode per unit of the organisation's base
+	// currency, as Xero converted the invoice (1 in the base currency)
+	CurrencyRate float64 `json:"CurrencyRate"`
+}
+
+// InvoiceSearch filters SearchInvoices. Zero values do not filter.
+type InvoiceSearch struct {
+	// Contact matches invoices whose contact name contains it.
+	Contact string
+	// From and To bound the invoice date (inclusive; only the day is used).
+	From, To time.Time
+	// Page is 1-based; PageSize defaults to 25.
+	Page     int
+	PageSize int
+}
+
+// DefaultInvoiceSearchPageSize is SearchInvoices' page size when none is given.
+const DefaultInvoiceSearchPageSize = 25
+
+// SearchInvoices returns one page of the tenant's sales invoices (ACCREC) matching q,
+// newest first. Line items are not included. A full page means there may be more.
+func (c *Client) SearchInvoices(ctx context.Context, accessToken, tenantID string, q InvoiceSearch) ([]InvoiceSummary, error) {
+	page, size := q.Page, q.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = DefaultInvoiceSearchPageSize
+	}
+	v := url.Values{}
+	v.Set("where", invoiceSearchWhere(q))
+	v.Set("order", "Date DESC")
+	v.Set("summaryOnly", "true")
+	v.Set("page", fmt.Sprint(page))
+	v.Set("pageSize", fmt.Sprint(size))
+	req, err := newJSONRequest(ctx, http.MethodGet, c.apiURL()+"/api.xro/2.0/Invoices?"+v.Encode(), nil, accessToken, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return nil, err
+	}
+	if status >= 300 {
+		return nil, statusError("search invoices", status, body)
+	}
+	var res struct {
+		Invoices []InvoiceSummary `json:"Invoices"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	return res.Invoices, nil
+}
+
+// BillsQuery filters ListBills. Zero values do not filter.
+type BillsQuery struct {
+	ContactIDs []string // Xero ContactIDs of the suppliers
+	Statuses   []string // e.g. AUTHORISED, PAID
+	// From and To bound the bill date (inclusive; only the day is used).
+	From, To time.Time
+}
+
+// billsPageSize is the pageSize ListBills asks for.
+const billsPageSize = 100
+
+// ListBills returns the tenant's bills (ACCPAY invoices) matching q, all pages (at
+// most 50), without line items.
+func (c *Client) ListBills(ctx context.Context, accessToken, tenantID string, q BillsQuery) ([]InvoiceSummary, error) {
+	clauses := []string{`Type=="` + InvoiceTypeBill + `"`}
+	if !q.From.IsZero() {
+		clauses = append(clauses, "Date>="+xeroDateTime(q.From))
+	}
+	if !q.To.IsZero() {
+		clauses = append(clauses, "Date<="+xeroDateTime(q.To))
+	}
+	var out []InvoiceSummary
+	for page := 1; page <= 50; page++ { // safety cap at 50 pages
+		v := url.Values{}
+		v.Set("where", strings.Join(clauses, " AND "))
+		if len(q.ContactIDs) > 0 {
+			v.Set("ContactIDs", strings.Join(q.ContactIDs, ","))
+		}
+		if len(q.Statuses) > 0 {
+			v.Set("Statuses", strings.Join(q.Statuses, ","))
+		}
+		v.Set("summaryOnly", "true")
+		v.Set("page", fmt.Sprint(page))
+		v.Set("pageSize", fmt.Sprint(billsPageSize))
+		req, err := newJSONRequest(ctx, http.MethodGet, c.apiURL()+"/api.xro/2.0/Invoices?"+v.Encode(), nil, accessToken, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return nil, err
+		}
+		if status >= 300 {
+			return nil, statusError("list bills", status, body)
+		}
+		var res struct {
+			Invoices []InvoiceSummary `json:"Invoices"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return nil, err
+		}
+		out = append(out, res.Invoices...)
+		if len(res.Invoices) < billsPageSize {
+			break
+		}
+	}
+	return out, nil
+}
+
+// LatestBill returns the tenant's newest authorised or paid bill in currency (an ISO
+// 4217 code), for the rate Xero converted it at; found is false when there is none.
+func (c *Client) LatestBill(ctx context.Context, accessToken, tenantID, currency string) (bill InvoiceSummary, found bool, err error) {
+	v := url.Values{}
+	v.Set("where", `Type=="`+InvoiceTypeBill+`" AND CurrencyCode=="`+currency+`"`)
+	v.Set("Statuses", "AUTHORISED,PAID")
+	v.Set("order", "Date DESC")
+	v.Set("summaryOnly", "true")
+	v.Set("page", "1")
+	v.Set("pageSize", "1")
+	req, err := newJSONRequest(ctx, http.MethodGet, c.apiURL()+"/api.xro/2.0/Invoices?"+v.Encode(), nil, accessToken, tenantID)
+	if err != nil {
+		return InvoiceSummary{}, false, err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return InvoiceSummary{}, false, err
+	}
+	if status >= 300 {
+		return InvoiceSummary{}, false, statusError("latest bill", status, body)
+	}
+	var res struct {
+		Invoices []InvoiceSummary `json:"Invoices"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return InvoiceSummary{}, false, err
+	}
+	if len(res.Invoices) == 0 {
+		return InvoiceSummary{}, false, nil
+	}
+	return res.Invoices[0], true, nil
+}
+
+// invoiceSearchWhere builds the Invoices where filter for q.
+func invoiceSearchWhere(q InvoiceSearch) string {
+	clauses := []string{`Type=="ACCREC"`}
+	// quotes and backslashes would end the string literal; names rarely need them
+	if name := strings.NewReplacer(`"`, "", `\`, "").Replace(strings.TrimSpace(q.Contact)); name != "" {
+		clauses = append(clauses, `Contact.Name.Contains("`+name+`")`)
+	}
+	if !q.From.IsZero() {
+		clauses = append(clauses, "Date>="+xeroDateTime(q.From))
+	}
+	if !q.To.IsZero() {
+		clauses = append(clauses, "Date<="+xeroDateTime(q.To))
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// xeroDateTime is t's day in where-filter form: DateTime(2024,03,01).
+func xeroDateTime(t time.Time) string {
+	return t.Format("DateTime(2006,01,02)")
+}
@@ -0,0 +1,109 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Item is a Xero Item as listed by GetAllItems. Raw keeps the full object (sales
+// details, accounts, ...) for callers that pass it through.
+type Item struct {
+	ItemSummary
+	Description    string    `json:"Description"`
+	UpdatedDateUTC Timestamp `json:"UpdatedDateUTC"`
+
+	Raw json.RawMessage `json:"-"`
+}
+
+// ItemsQuery narrows GetAllItems.
+type ItemsQuery struct {
+	// ModifiedSince, when set, only returns items changed after it.
+	ModifiedSince time.Time
+	// PageSize is the number of items per request; 0 means Xero's default of 100.
+	PageSize int
+}
+
+// defaultItemsPageSize is the page size Xero uses when none is given.
+const defaultItemsPageSize = 100
+
+// maxItemsPageSize is the largest pageSize Xero accepts.
+const maxItemsPageSize = 1000
+
+// GetAllItems returns every item of the tenant matching q, fetching pages until Xero
+// returns a short one (at most 50 pages). Items repeated across pages, which happens
+// when the list changes mid-fetch, are returned once.
+func (c *Client) GetAllItems(ctx context.Context, accessToken, tenantID string, q ItemsQuery) ([]Item, error) {
+	size := q.PageSize
+	if size == 0 {
+		size = defaultItemsPageSize
+	}
+	if size < 0 || size > maxItemsPageSize {
+		return nil, fmt.Errorf("items page size must be between 1 and %d", maxItemsPageSize)
+	}
+
+	var out []Item
+	seen := make(map[string]bool)
+	for page := 1; page <= 50; page++ { // safety cap at 50 pages
+		v := url.Values{}
+		v.Set("page", fmt.Sprint(page))
+		v.Set("pageSize", fmt.Sprint(size))
+		u := c.apiURL() + "/api.xro/2.0/Items?" + v.Encode()
+		req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		setModifiedSince(req, q.ModifiedSince)
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return nil, err
+		}
+		if status == http.StatusNotModified {
+			break
+		}
+		if status >= 300 {
+			return nil, statusError("list items", status, body)
+		}
+		items, err := parseItems(body)
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			key := it.ItemID
+			if key == "" {
+				key = "code:" + it.Code
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, it)
+		}
+		if len(items) < size {
+			break
+		}
+	}
+	return out, nil
+}
+
+func parseItems(b []byte) ([]Item, error) {
+	var res struct {
+		Items []json.RawMessage `json:"Items"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	out := make([]Item, 0, len(res.Items))
+	for _, raw := range res.Items {
+		var it Item
+		if err := json.Unmarshal(raw, &it); err != nil {
+			return nil, err
+		}
+		it.Raw = raw
+		out = append(out, it)
+	}
+	return out, nil
+}
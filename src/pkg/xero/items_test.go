@@ -0,0 +1,71 @@
+package xero
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetAllItems_Paginates(t *testing.T) {
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api.xro/2.0/Items" || r.URL.Query().Get("pageSize") != "2" {
+			http.Error(w, "unexpected "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		if got := r.Header.Get("If-Modified-Since"); got != "Thu, 02 Jan 2025 00:00:00 GMT" {
+			t.Errorf("If-Modified-Since = %q", got)
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		switch page {
+		case "1":
+			fmt.Fprint(w, `{"Items":[{"ItemID":"i1","Code":"A","SalesDetails":{"UnitPrice":3}},{"ItemID":"i2","Code":"B"}]}`)
+		case "2":
+			// i2 again: the list shifted between requests
+			fmt.Fprint(w, `{"Items":[{"ItemID":"i2","Code":"B"},{"ItemID":"i3","Code":"C"}]}`)
+		default:
+			fmt.Fprint(w, `{"Items":[{"ItemID":"i4","Code":"D"}]}`)
+		}
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	items, err := client.GetAllItems(context.Background(), "at", "tid", ItemsQuery{
+		ModifiedSince: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		PageSize:      2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	var codes []string
+	for _, it := range items {
+		codes = append(codes, it.Code)
+	}
+	if strings.Join(codes, ",") != "A,B,C,D" || strings.Join(pages, ",") != "1,2,3" {
+		t.Fatalf("codes %v from pages %v", codes, pages)
+	}
+	if !strings.Contains(string(items[0].Raw), `"SalesDetails"`) {
+		t.Fatalf("raw item not kept: %s", items[0].Raw)
+	}
+}
+
+func TestGetAllItems_NotModifiedAndBadPageSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	items, err := client.GetAllItems(context.Background(), "at", "tid", ItemsQuery{ModifiedSince: time.Now()})
+	if err != nil || len(items) != 0 {
+		t.Fatalf("not modified: %+v, %v", items, err)
+	}
+	if _, err := client.GetAllItems(context.Background(), "at", "tid", ItemsQuery{PageSize: 5000}); err == nil {
+		t.Fatal("expected error for oversized page")
+	}
+}
@@ -0,0 +1,89 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimestamp_JSON(t *testing.T) {
+	want := time.Date(2019, 11, 14, 18, 10, 38, 314e6, time.UTC)
+	for _, in := range []string{`"/Date(1573755038314+0000)/"`, `"/Date(1573755038314)/"`, `"2019-11-14T18:10:38.314Z"`} {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(in), &ts); err != nil || !ts.Equal(want) {
+			t.Fatalf("%s: got %v, %v", in, ts.Time, err)
+		}
+	}
+	var ds Timestamp
+	if err := json.Unmarshal([]byte(`"2019-11-14T18:10:38"`), &ds); err != nil || !ds.Equal(want.Truncate(time.Second)) {
+		t.Fatalf("DateString form: %v, %v", ds.Time, err)
+	}
+	for _, in := range []string{`null`, `""`} {
+		ts := Timestamp{Time: want}
+		if err := json.Unmarshal([]byte(in), &ts); err != nil || !ts.IsZero() {
+			t.Fatalf("%s: got %v, %v", in, ts.Time, err)
+		}
+	}
+	var bad Timestamp
+	if err := json.Unmarshal([]byte(`"last tuesday"`), &bad); err == nil {
+		t.Fatal("expected error for unrecognised date")
+	}
+
+	b, err := json.Marshal(struct{ A, B Timestamp }{A: Timestamp{Time: want}})
+	if err != nil || string(b) != `{"A":"/Date(1573755038314+0000)/","B":null}` {
+		t.Fatalf("marshal: %s, %v", b, err)
+	}
+}
+
+func TestLatestUpdate(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := LatestUpdate(since); !got.Equal(since) {
+		t.Fatalf("no updates: %v", got)
+	}
+	newer := since.Add(time.Hour)
+	got := LatestUpdate(since, Timestamp{Time: since.Add(-time.Hour)}, Timestamp{Time: newer}, Timestamp{})
+	if !got.Equal(newer) {
+		t.Fatalf("got %v, want %v", got, newer)
+	}
+}
+
+func TestListInvoices_ModifiedSince(t *testing.T) {
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("If-Modified-Since"); got != "Wed, 05 Mar 2025 10:30:00 GMT" {
+			t.Errorf("If-Modified-Since = %q", got)
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		n := 1
+		if page == "1" {
+			n = invoicesPageSize
+		}
+		fmt.Fprint(w, `{"Invoices":[`)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"InvoiceID":"p%s-%d","InvoiceNumber":"INV-%d","Type":"ACCREC","UpdatedDateUTC":"/Date(1741170600000+0000)/"}`, page, i, i)
+		}
+		fmt.Fprint(w, `]}`)
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	since := time.Date(2025, 3, 5, 10, 30, 0, 900e6, time.UTC) // rounded down on the wire
+	invs, err := client.ListInvoices(context.Background(), "at", "tid", InvoicesQuery{ModifiedSince: since})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(invs) != invoicesPageSize+1 || len(pages) != 2 {
+		t.Fatalf("got %d invoices over pages %v", len(invs), pages)
+	}
+	if invs[0].Type != "ACCREC" || !invs[0].UpdatedDateUTC.Equal(time.Date(2025, 3, 5, 10, 30, 0, 0, time.UTC)) || len(invs[0].Raw) == 0 {
+		t.Fatalf("unexpected invoice: %+v", invs[0])
+	}
+}
@@ -0,0 +1,80 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DeepLinkURL is the Xero web app host that deep links point at.
+const DeepLinkURL = "https://go.xero.com"
+
+// Organisation is the Xero organisation behind a tenant.
+type Organisation struct {
+	OrganisationID string `json:"OrganisationID"`
+	Name           string `json:"Name"`
+	BaseCurrency   string `json:"BaseCurrency"` // ISO 4217 code, e.g. "GBP"
+	CountryCode    string `json:"CountryCode"`
+	ShortCode      string `json:"ShortCode"` // picks the organisation in deep links
+}
+
+// InvoiceURL links to invoice invoiceID in the Xero web app: a sales invoice, or a
+// bill when invoiceType is "ACCPAY".
+func (o Organisation) InvoiceURL(invoiceID, invoiceType string) string {
+	if invoiceType == "ACCPAY" {
+		return o.deepLink("/AccountsPayable/View.aspx?InvoiceID=" + url.QueryEscape(invoiceID))
+	}
+	return o.deepLink("/AccountsReceivable/View.aspx?InvoiceID=" + url.QueryEscape(invoiceID))
+}
+
+// PurchaseOrderURL links to purchase order purchaseOrderID in the Xero web app.
+func (o Organisation) PurchaseOrderURL(purchaseOrderID string) string {
+	return o.deepLink("/Accounts/Payable/PurchaseOrders/View/" + url.PathEscape(purchaseOrderID))
+}
+
+// deepLink opens path in the organisation, switching to it first when the user is
+// signed in to another one. Without a ShortCode it opens in whichever organisation
+// the user last used.
+func (o Organisation) deepLink(path string) string {
+	if o.ShortCode == "" {
+		return DeepLinkURL + path
+	}
+	return DeepLinkURL + "/organisationlogin/default.aspx?shortcode=" + url.QueryEscape(o.ShortCode) +
+		"&redirecturl=" + url.QueryEscape(path)
+}
+
+// GetOrganisation returns the tenant's organisation.
+func (c *Client) GetOrganisation(ctx context.Context, accessToken, tenantID string) (Organisation, error) {
+	org, _, err := c.GetOrganisationLimits(ctx, accessToken, tenantID)
+	return org, err
+}
+
+// GetOrganisationLimits is GetOrganisation that also returns the rate limits Xero
+// reported with the answer, e.g. to check how close a tenant is to them. The limits
+// are returned whenever Xero answered, even with an error.
+func (c *Client) GetOrganisationLimits(ctx context.Context, accessToken, tenantID string) (Organisation, RateLimits, error) {
+	req, err := newJSONRequest(ctx, http.MethodGet, c.apiURL()+"/api.xro/2.0/Organisation", nil, accessToken, tenantID)
+	if err != nil {
+		return Organisation{}, RateLimits{}, err
+	}
+	status, body, header, err := c.doJSONHeader(req)
+	limits := parseRateLimits(header)
+	if err != nil {
+		return Organisation{}, limits, err
+	}
+	if status >= 300 {
+		return Organisation{}, limits, statusError("get organisation", status, body)
+	}
+	var res struct {
+		Organisations []Organisation `json:"Organisations"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return Organisation{}, limits, err
+	}
+	if len(res.Organisations) == 0 {
+		return Organisation{}, limits, fmt.Errorf("get organisation: none returned")
+	}
+	return res.Organisations[0], limits, nil
+}
@@ -0,0 +1,46 @@
+package xero
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetOrganisation(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api.xro/2.0/Organisation" || r.Header.Get("Xero-tenant-id") != "tid" {
+			http.Error(w, "unexpected", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Organisations":[{"OrganisationID":"org-1","Name":"Demo","BaseCurrency":"NZD","CountryCode":"NZ","ShortCode":"!abc12"}]}`))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	org, err := client.GetOrganisation(context.Background(), "at", "tid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org.Name != "Demo" || org.BaseCurrency != "NZD" {
+		t.Fatalf("unexpected organisation: %+v", org)
+	}
+	if _, err := client.GetOrganisation(context.Background(), "at", "other"); err == nil {
+		t.Fatal("expected an error for a failed request")
+	}
+}
+
+func TestOrganisationDeepLinks(t *testing.T) {
+	org := Organisation{ShortCode: "!abc12"}
+	if got, want := org.InvoiceURL("inv-1", "ACCREC"),
+		"https://go.xero.com/organisationlogin/default.aspx?shortcode=%21abc12&redirecturl=%2FAccountsReceivable%2FView.aspx%3FInvoiceID%3Dinv-1"; got != want {
+		t.Errorf("InvoiceURL = %s, want %s", got, want)
+	}
+	if got, want := org.InvoiceURL("bill-1", "ACCPAY"),
+		"https://go.xero.com/organisationlogin/default.aspx?shortcode=%21abc12&redirecturl=%2FAccountsPayable%2FView.aspx%3FInvoiceID%3Dbill-1"; got != want {
+		t.Errorf("InvoiceURL(ACCPAY) = %s, want %s", got, want)
+	}
+	if got, want := (Organisation{}).PurchaseOrderURL("po-1"), "https://go.xero.com/Accounts/Payable/PurchaseOrders/View/po-1"; got != want {
+		t.Errorf("PurchaseOrderURL without short code = %s, want %s", got, want)
+	}
+}
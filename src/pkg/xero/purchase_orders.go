@@ -0,0 +1,114 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PurchaseOrder is the subset of a Xero PurchaseOrder used for reconciliation and
+// spend reporting.
+type PurchaseOrder struct {
+	PurchaseOrderID     string          `json:"PurchaseOrderID"`
+	PurchaseOrderNumber string          `json:"PurchaseOrderNumber"`
+	Status              string          `json:"Status"`
+	DateString          string          `json:"DateString"`
+	Contact             PurchaseContact `json:"Contact"`
+	LineItems           []PurchaseLine  `json:"LineItems"`
+	Total               float64         `json:"Total"`
+	CurrencyCode        string          `json:"CurrencyCode"`
+	// CurrencyRate is units of CurrencyCode per unit of the base currency
+	CurrencyRate float64 `json:"CurrencyRate"`
+}
+
+// PurchaseContact is the contact summary embedded in a PurchaseOrder.
+type PurchaseContact struct {
+	ContactID     string `json:"ContactID"`
+	Name          string `json:"Name"`
+	AccountNumber string `json:"AccountNumber"`
+}
+
+// PurchaseLine is a PurchaseOrder line item.
+type PurchaseLine struct {
+	ItemCode    string  `json:"ItemCode"`
+	Description string  `json:"Description"`
+	Quantity    float64 `json:"Quantity"`
+}
+
+// purchaseOrdersPageSize is Xero's fixed page size for the PurchaseOrders endpoint.
+const purchaseOrdersPageSize = 100
+
+func parsePurchaseOrders(b []byte) ([]PurchaseOrder, error) {
+	var res struct {
+		PurchaseOrders []PurchaseOrder `json:"PurchaseOrders"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, err
+	}
+	return res.PurchaseOrders, nil
+}
+
+// ListPurchaseOrders returns all purchase orders dated on/after since (all pages).
+func (c *Client) ListPurchaseOrders(ctx context.Context, accessToken, tenantID string, since time.Time) ([]PurchaseOrder, error) {
+	var out []PurchaseOrder
+	for page := 1; page <= 50; page++ { // safety cap at 50 pages
+		q := url.Values{}
+		q.Set("DateFrom", since.UTC().Format("2006-01-02"))
+		q.Set("page", fmt.Sprint(page))
+		u := c.apiURL() + "/api.xro/2.0/PurchaseOrders?" + q.Encode()
+		req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return nil, err
+		}
+		if status >= 300 {
+			return nil, statusError("list purchase orders", status, body)
+		}
+		pos, err := parsePurchaseOrders(body)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pos...)
+		if len(pos) < purchaseOrdersPageSize {
+			break
+		}
+	}
+	return out, nil
+}
+
+// GetPurchaseOrder fetches a single purchase order by PurchaseOrderID.
+// found=false when Xero returns 404.
+func (c *Client) GetPurchaseOrder(ctx context.Context, accessToken, tenantID, purchaseOrderID string) (PurchaseOrder, bool, error) {
+	if purchaseOrderID == "" {
+		return PurchaseOrder{}, false, nil
+	}
+	u := fmt.Sprintf("%s/api.xro/2.0/PurchaseOrders/%s", c.apiURL(), url.PathEscape(purchaseOrderID))
+	req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+	if err != nil {
+		return PurchaseOrder{}, false, err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return PurchaseOrder{}, false, err
+	}
+	if status == http.StatusNotFound {
+		return PurchaseOrder{}, false, nil
+	}
+	if status >= 300 {
+		return PurchaseOrder{}, false, statusError("get purchase order", status, body)
+	}
+	pos, err := parsePurchaseOrders(body)
+	if err != nil {
+		return PurchaseOrder{}, false, err
+	}
+	if len(pos) == 0 {
+		return PurchaseOrder{}, false, nil
+	}
+	return pos[0], true, nil
+}
@@ -0,0 +1,73 @@
+package xero
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListPurchaseOrders_Paginates(t *testing.T) {
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api.xro/2.0/PurchaseOrders" {
+			http.Error(w, "unexpected", http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("DateFrom") != "2025-01-02" {
+			t.Fatalf("unexpected DateFrom: %s", r.URL.Query().Get("DateFrom"))
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		n := 0
+		if page == "1" {
+			n = purchaseOrdersPageSize // full page -> fetch next
+		} else if page == "2" {
+			n = 3
+		}
+		var b strings.Builder
+		b.WriteString(`{"PurchaseOrders":[`)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, `{"PurchaseOrderID":"p%s-%d","Status":"AUTHORISED"}`, page, i)
+		}
+		b.WriteString(`]}`)
+		_, _ = w.Write([]byte(b.String()))
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.Client(), ts.URL)
+
+	pos, err := client.ListPurchaseOrders(context.Background(), "at", "tid", time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(pos) != purchaseOrdersPageSize+3 {
+		t.Fatalf("expected %d purchase orders, got %d", purchaseOrdersPageSize+3, len(pos))
+	}
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 page requests, got %v", pages)
+	}
+}
+
+func TestGetPurchaseOrder_NotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.Client(), ts.URL)
+
+	_, found, err := client.GetPurchaseOrder(context.Background(), "at", "tid", "missing")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if found {
+		t.Fatalf("expected not found")
+	}
+}
@@ -0,0 +1,47 @@
+package xero
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Rate limit headers Xero sends with API responses: how many calls are left.
+const (
+	dayLimitHeader         = "X-DayLimit-Remaining"    // per tenant per day
+	minLimitHeader         = "X-MinLimit-Remaining"    // per tenant per minute
+	appMinLimitHeader      = "X-AppMinLimit-Remaining" // per app per minute, across tenants
+	rateLimitProblemHeader = "X-Rate-Limit-Problem"    // on a 429: which limit was hit
+)
+
+// RateLimits are the calls Xero says are left before it answers 429. A count is -1
+// when Xero did not send it.
+type RateLimits struct {
+	DayRemaining       int
+	MinuteRemaining    int
+	AppMinuteRemaining int
+	// Problem names the limit that was hit ("day", "minute" or "appminute") when
+	// Xero refused the call with 429
+	Problem string
+}
+
+// Known reports whether Xero sent any of the counts.
+func (l RateLimits) Known() bool {
+	return l.DayRemaining >= 0 || l.MinuteRemaining >= 0 || l.AppMinuteRemaining >= 0
+}
+
+// parseRateLimits reads the rate limit headers of h (nil for no response).
+func parseRateLimits(h http.Header) RateLimits {
+	count := func(name string) int {
+		n, err := strconv.Atoi(h.Get(name))
+		if err != nil || n < 0 {
+			return -1
+		}
+		return n
+	}
+	return RateLimits{
+		DayRemaining:       count(dayLimitHeader),
+		MinuteRemaining:    count(minLimitHeader),
+		AppMinuteRemaining: count(appMinLimitHeader),
+		Problem:            h.Get(rateLimitProblemHeader),
+	}
+}
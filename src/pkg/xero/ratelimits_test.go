@@ -0,0 +1,58 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetOrganisationLimits(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-AppMinLimit-Remaining", "9990")
+		if r.Header.Get("Xero-tenant-id") == "busy" {
+			w.Header().Set("X-DayLimit-Remaining", "4000")
+			w.Header().Set("X-MinLimit-Remaining", "0")
+			w.Header().Set("X-Rate-Limit-Problem", "minute")
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("X-DayLimit-Remaining", "4987")
+		w.Header().Set("X-MinLimit-Remaining", "59")
+		_, _ = w.Write([]byte(`{"Organisations":[{"OrganisationID":"org-1","Name":"Demo"}]}`))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+	ctx := WithRetryPolicy(context.Background(), NoRetry)
+
+	org, limits, err := client.GetOrganisationLimits(ctx, "at", "tid")
+	if err != nil || org.Name != "Demo" {
+		t.Fatalf("org = %+v, err = %v", org, err)
+	}
+	if limits != (RateLimits{DayRemaining: 4987, MinuteRemaining: 59, AppMinuteRemaining: 9990}) || !limits.Known() {
+		t.Fatalf("limits = %+v", limits)
+	}
+
+	// the limits come back with the refusal too
+	_, limits, err = client.GetOrganisationLimits(ctx, "at", "busy")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if limits.MinuteRemaining != 0 || limits.DayRemaining != 4000 || limits.Problem != "minute" {
+		t.Fatalf("limits = %+v", limits)
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	if l := parseRateLimits(nil); l.Known() || l.DayRemaining != -1 || l.Problem != "" {
+		t.Fatalf("no response: %+v", l)
+	}
+	h := http.Header{}
+	h.Set("X-MinLimit-Remaining", "lots")
+	h.Set("X-DayLimit-Remaining", "12")
+	if l := parseRateLimits(h); l.MinuteRemaining != -1 || l.DayRemaining != 12 || !l.Known() {
+		t.Fatalf("partial headers: %+v", l)
+	}
+}
@@ -0,0 +1,94 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how idempotent requests (GET, HEAD) are retried after a 429,
+// a 5xx or a network error. Writes are never retried: Xero may have applied them.
+type RetryPolicy struct {
+	MaxAttempts int           // total tries including the first; 1 or less disables retries
+	BaseDelay   time.Duration // wait before the first retry, doubled for each one after
+	MaxDelay    time.Duration // cap on any single wait, including a Retry-After from Xero
+}
+
+// DefaultRetryPolicy is used when neither the Client nor the call sets one.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+// NoRetry sends each request once.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy overrides the retry policy for calls made with the returned context,
+// e.g. NoRetry for a health check that must answer quickly.
+func WithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// retryPolicy picks the call's policy, then the Client's, then DefaultRetryPolicy.
+func (c *Client) retryPolicy(ctx context.Context) RetryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return p
+	}
+	if c.Retry != nil {
+		return *c.Retry
+	}
+	return DefaultRetryPolicy
+}
+
+// delay returns how long to wait before retry number attempt (1-based): Retry-After
+// when Xero sent one, else exponential backoff with jitter, capped at MaxDelay.
+func (p RetryPolicy) delay(attempt int, h http.Header) time.Duration {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil && secs > 0 {
+		return p.capped(time.Duration(secs) * time.Second)
+	}
+	d := p.capped(p.BaseDelay << (attempt - 1))
+	if d <= 0 {
+		return 0
+	}
+	// "equal jitter": half fixed, half random, so concurrent callers spread out
+	return d/2 + rand.N(d/2+1)
+}
+
+func (p RetryPolicy) capped(d time.Duration) time.Duration {
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d < 0) {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// CircuitOpenError is implemented by errors from an HTTP transport that refused a
+// request because the host is known to be down (e.g. a circuit breaker). Such
+// requests are not retried.
+type CircuitOpenError interface {
+	error
+	CircuitOpen() bool
+}
+
+// retryable reports whether a response (or transport error) is worth another try. A
+// request refused by an open circuit breaker is not: the host is known to be down.
+func retryable(status int, err error) bool {
+	if err != nil {
+		var open CircuitOpenError
+		return !errors.As(err, &open) || !open.CircuitOpen()
+	}
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// sleepCtx waits for d or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
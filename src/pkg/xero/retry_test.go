@@ -0,0 +1,139 @@
+package xero
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// flakyServer fails the first `failures` requests with status, then serves an item.
+func flakyServer(t *testing.T, failures int32, status int) (*Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "try later", status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Items":[{"Code":"A","Name":"Widget"}]}`))
+	}))
+	t.Cleanup(ts.Close)
+	client := NewClient(ts.Client(), ts.URL)
+	client.Retry = &fastRetry
+	return client, &calls
+}
+
+func TestDoJSON_RetriesGetOn429And5xx(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		client, calls := flakyServer(t, 2, status)
+		name, ok, err := client.GetItemNameByCode(context.Background(), "at", "tid", "A")
+		if err != nil || !ok || name != "Widget" {
+			t.Fatalf("status %d: got %q %v %v", status, name, ok, err)
+		}
+		if n := calls.Load(); n != 3 {
+			t.Fatalf("status %d: %d calls, want 3", status, n)
+		}
+	}
+}
+
+func TestDoJSON_GivesUpAfterMaxAttempts(t *testing.T) {
+	client, calls := flakyServer(t, 10, http.StatusTooManyRequests)
+	if _, _, err := client.GetItemNameByCode(context.Background(), "at", "tid", "A"); err == nil {
+		t.Fatal("expected error after retries")
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("%d calls, want 3", n)
+	}
+}
+
+func TestDoJSON_DoesNotRetryWritesOrClientErrors(t *testing.T) {
+	client, calls := flakyServer(t, 10, http.StatusServiceUnavailable)
+	if _, err := client.CreatePurchaseOrder(context.Background(), "at", "tid", "contact", []POItem{{ItemCode: "A", Quantity: 1}}, PODetails{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("POST sent %d times, want 1", n)
+	}
+
+	client, calls = flakyServer(t, 10, http.StatusBadRequest)
+	_, _, _ = client.GetItemNameByCode(context.Background(), "at", "tid", "A")
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("400 retried: %d calls", n)
+	}
+}
+
+func TestDoJSON_PerCallPolicyAndCancellation(t *testing.T) {
+	client, calls := flakyServer(t, 10, http.StatusTooManyRequests)
+	ctx := WithRetryPolicy(context.Background(), NoRetry)
+	_, _, _ = client.GetItemNameByCode(ctx, "at", "tid", "A")
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("NoRetry call sent %d requests", n)
+	}
+
+	// a long wait is cut short by the context
+	slow := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(WithRetryPolicy(context.Background(), slow), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := client.GetItemNameByCode(ctx, "at", "tid", "A")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Fatalf("expected prompt deadline error, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 1; attempt <= 6; attempt++ {
+		want := p.capped(p.BaseDelay << (attempt - 1))
+		if d := p.delay(attempt, nil); d < want/2 || d > want {
+			t.Fatalf("attempt %d: delay %s outside [%s, %s]", attempt, d, want/2, want)
+		}
+	}
+	h := http.Header{}
+	h.Set("Retry-After", "2")
+	if d := (RetryPolicy{MaxDelay: time.Minute}).delay(1, h); d != 2*time.Second {
+		t.Fatalf("Retry-After delay = %s, want 2s", d)
+	}
+	if d := p.delay(1, h); d != time.Second {
+		t.Fatalf("Retry-After should be capped at MaxDelay, got %s", d)
+	}
+}
+
+// openCircuit is a transport error of the kind pkg/breaker returns.
+type openCircuit struct{}
+
+func (openCircuit) Error() string     { return "circuit open" }
+func (openCircuit) CircuitOpen() bool { return true }
+
+// refuseAfterFirst sends the first request and refuses the rest locally.
+type refuseAfterFirst struct {
+	base http.RoundTripper
+	sent atomic.Bool
+}
+
+func (t *refuseAfterFirst) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.sent.Swap(true) {
+		return nil, openCircuit{}
+	}
+	return t.base.RoundTrip(r)
+}
+
+func TestDoJSON_DoesNotRetryOpenCircuit(t *testing.T) {
+	client, calls := flakyServer(t, 10, http.StatusServiceUnavailable)
+	tr := &refuseAfterFirst{base: client.HTTPClient.Transport}
+	client.HTTPClient = &http.Client{Transport: tr}
+
+	// the retry after the first 503 is refused locally and not repeated
+	_, _, err := client.GetItemNameByCode(context.Background(), "at", "tid", "A")
+	var open CircuitOpenError
+	if !errors.As(err, &open) || calls.Load() != 1 {
+		t.Fatalf("got %v after %d calls, want a circuit open error after 1", err, calls.Load())
+	}
+}
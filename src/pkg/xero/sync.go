@@ -0,0 +1,333 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxSyncBatch is the most Items or Contacts sent in one upsert request.
+const MaxSyncBatch = 50
+
+// UpsertItemsBatch creates or updates one batch of items in a single request. Existing
+// items are matched by Code with one batched lookup (rather than one per item as in
+// SyncPartsToXero).
+func (c *Client) UpsertItemsBatch(ctx context.Context, accessToken, tenantID string, items []Part) error {
+	if len(items) == 0 {
+		return nil
+	}
+	if len(items) > MaxSyncBatch {
+		return fmt.Errorf("batch too large: %d items (max %d)", len(items), MaxSyncBatch)
+	}
+	codes := make([]string, 0, len(items))
+	for _, p := range items {
+		codes = append(codes, p.PartID)
+	}
+	existing, err := c.GetItemsByCodes(ctx, accessToken, tenantID, codes)
+	if err != nil {
+		return err
+	}
+	b, err := buildItemsUpsertPayload(items, func(code string) (string, error) {
+		return existing[code].ItemID, nil
+	})
+	if err != nil {
+		return err
+	}
+	req, err := newJSONRequest(ctx, http.MethodPost, c.apiURL()+"/api.xro/2.0/Items", b, accessToken, tenantID)
+	if err != nil {
+		return err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return statusError("xero items post", status, body)
+	}
+	return nil
+}
+
+// getContactsByAccountNumbers returns AccountNumber -> contact for the contacts that
+// exist (batched like GetItemsByCodes).
+func (c *Client) getContactsByAccountNumbers(ctx context.Context, accessToken, tenantID string, accountNumbers []string) (map[string]Contact, error) {
+	var mu sync.Mutex
+	out := make(map[string]Contact, len(accountNumbers))
+	err := c.eachBatch(ctx, len(accountNumbers), itemCodesPerRequest, func(ctx context.Context, start, end int) error {
+		var clauses []string
+		for _, a := range accountNumbers[start:end] {
+			if a != "" {
+				clauses = append(clauses, fmt.Sprintf(`AccountNumber=="%s"`, a))
+			}
+		}
+		if len(clauses) == 0 {
+			return nil
+		}
+		u := c.apiURL() + "/api.xro/2.0/Contacts?where=" + url.QueryEscape(strings.Join(clauses, " OR "))
+		req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+		if err != nil {
+			return err
+		}
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return err
+		}
+		if status >= 300 {
+			return statusError("contacts lookup", status, body)
+		}
+		var res struct {
+			Contacts []Contact `json:"Contacts"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, contact := range res.Contacts {
+			out[contact.AccountNumber] = contact
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetContactIDsByAccountNumbers returns AccountNumber -> ContactID for the contacts
+// that exist.
+func (c *Client) GetContactIDsByAccountNumbers(ctx context.Context, accessToken, tenantID string, accountNumbers []string) (map[string]string, error) {
+	contacts, err := c.getContactsByAccountNumbers(ctx, accessToken, tenantID, accountNumbers)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(contacts))
+	for acc, contact := range contacts {
+		out[acc] = contact.ContactID
+	}
+	return out, nil
+}
+
+// GetContactCurrencies returns AccountNumber -> DefaultCurrency for the contacts that
+// exist and have a default currency set.
+func (c *Client) GetContactCurrencies(ctx context.Context, accessToken, tenantID string, accountNumbers []string) (map[string]string, error) {
+	contacts, err := c.getContactsByAccountNumbers(ctx, accessToken, tenantID, accountNumbers)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(contacts))
+	for acc, contact := range contacts {
+		if contact.DefaultCurrency != "" {
+			out[acc] = contact.DefaultCurrency
+		}
+	}
+	return out, nil
+}
+
+// buildContactsUpsertPayload builds the Contacts upsert payload. Suppliers map to
+// contacts by AccountNumber = SupplierID; contactIDs holds existing ContactIDs.
+func buildContactsUpsertPayload(suppliers []Supplier, contactIDs map[string]string) ([]byte, error) {
+	type phone struct {
+		PhoneType   string `json:"PhoneType"`
+		PhoneNumber string `json:"PhoneNumber"`
+	}
+	type contactPayload struct {
+		ContactID     string  `json:"ContactID,omitempty"`
+		Name          string  `json:"Name"`
+		AccountNumber string  `json:"AccountNumber"`
+		EmailAddress  string  `json:"EmailAddress,omitempty"`
+		Phones        []phone `json:"Phones,omitempty"`
+	}
+	out := make([]contactPayload, 0, len(suppliers))
+	for _, s := range suppliers {
+		if s.SupplierID == "" {
+			return nil, fmt.Errorf("supplier id missing")
+		}
+		cp := contactPayload{
+			ContactID:     contactIDs[s.SupplierID],
+			Name:          s.SupplierName,
+			AccountNumber: s.SupplierID,
+			EmailAddress:  s.ContactEmail,
+		}
+		if cp.Name == "" {
+			cp.Name = s.SupplierID
+		}
+		if s.Phone != "" {
+			cp.Phones = []phone{{PhoneType: "DEFAULT", PhoneNumber: s.Phone}}
+		}
+		out = append(out, cp)
+	}
+	return json.Marshal(map[string]any{"Contacts": out})
+}
+
+// UpsertContactsBatch creates or updates one batch of supplier contacts in a single
+// request, matching existing contacts by AccountNumber.
+func (c *Client) UpsertContactsBatch(ctx context.Context, accessToken, tenantID string, suppliers []Supplier) error {
+	if len(suppliers) == 0 {
+		return nil
+	}
+	if len(suppliers) > MaxSyncBatch {
+		return fmt.Errorf("batch too large: %d contacts (max %d)", len(suppliers), MaxSyncBatch)
+	}
+	accounts := make([]string, 0, len(suppliers))
+	for _, s := range suppliers {
+		accounts = append(accounts, s.SupplierID)
+	}
+	existing, err := c.GetContactIDsByAccountNumbers(ctx, accessToken, tenantID, accounts)
+	if err != nil {
+		return err
+	}
+	return c.postContacts(ctx, accessToken, tenantID, suppliers, existing)
+}
+
+// postContacts sends one Contacts upsert request.
+func (c *Client) postContacts(ctx context.Context, accessToken, tenantID string, suppliers []Supplier, contactIDs map[string]string) error {
+	b, err := buildContactsUpsertPayload(suppliers, contactIDs)
+	if err != nil {
+		return err
+	}
+	req, err := newJSONRequest(ctx, http.MethodPost, c.apiURL()+"/api.xro/2.0/Contacts", b, accessToken, tenantID)
+	if err != nil {
+		return err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return statusError("xero contacts post", status, body)
+	}
+	return nil
+}
+
+// SupplierSyncResult counts what SyncSuppliersToXero did.
+type SupplierSyncResult struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"` // already matching in Xero
+	Skipped   int `json:"skipped"`   // not modified since the cut-off
+}
+
+// SyncSuppliersToXero upserts suppliers as Xero Contacts matched by AccountNumber
+// (= SupplierID), sending name, email and phone.
+//
+// Suppliers whose UpdatedAt is before modifiedSince are skipped without asking Xero
+// (zero modifiedSince syncs all). The rest are compared with the existing contacts
+// and only new or changed ones are posted, in batches of MaxSyncBatch.
+func (c *Client) SyncSuppliersToXero(ctx context.Context, accessToken, tenantID string, suppliers []Supplier, modifiedSince time.Time) (SupplierSyncResult, error) {
+	var res SupplierSyncResult
+	var candidates []Supplier
+	for _, s := range suppliers {
+		if !modifiedSince.IsZero() && s.UpdatedAt != 0 && s.UpdatedAt < modifiedSince.Unix() {
+			res.Skipped++
+			continue
+		}
+		candidates = append(candidates, s)
+	}
+	if len(candidates) == 0 {
+		return res, nil
+	}
+
+	accounts := make([]string, 0, len(candidates))
+	for _, s := range candidates {
+		accounts = append(accounts, s.SupplierID)
+	}
+	existing, err := c.getContactsByAccountNumbers(ctx, accessToken, tenantID, accounts)
+	if err != nil {
+		return res, err
+	}
+
+	ids := make(map[string]string, len(existing))
+	var changed []Supplier
+	for _, s := range candidates {
+		contact, ok := existing[s.SupplierID]
+		switch {
+		case !ok:
+			res.Created++
+		case contactMatches(contact, s):
+			res.Unchanged++
+			continue
+		default:
+			res.Updated++
+			ids[s.SupplierID] = contact.ContactID
+		}
+		changed = append(changed, s)
+	}
+
+	for start := 0; start < len(changed); start += MaxSyncBatch {
+		end := start + MaxSyncBatch
+		if end > len(changed) {
+			end = len(changed)
+		}
+		if err := c.postContacts(ctx, accessToken, tenantID, changed[start:end], ids); err != nil {
+			return res, fmt.Errorf("contacts %d-%d: %w", start+1, end, err)
+		}
+	}
+	return res, nil
+}
+
+// contactMatches reports whether the Xero contact already holds the supplier's
+// details (as they would be sent by buildContactsUpsertPayload).
+func contactMatches(c Contact, s Supplier) bool {
+	name := s.SupplierName
+	if name == "" {
+		name = s.SupplierID
+	}
+	return c.Name == name && c.EmailAddress == s.ContactEmail && c.DefaultPhone() == s.Phone
+}
+
+// UpdatePurchasePrices sets the purchase price (PurchaseDetails.UnitPrice) of the
+// items with the given codes, leaving their other details alone. Codes not in Xero
+// are skipped and returned, sorted.
+func (c *Client) UpdatePurchasePrices(ctx context.Context, accessToken, tenantID string, prices map[string]float64) ([]string, error) {
+	codes := make([]string, 0, len(prices))
+	for code := range prices {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	existing, err := c.GetItemsByCodes(ctx, accessToken, tenantID, codes)
+	if err != nil {
+		return nil, err
+	}
+	type price struct {
+		UnitPrice float64 `json:"UnitPrice"`
+	}
+	type itemPrice struct {
+		ItemID   string `json:"ItemID"`
+		Code     string `json:"Code"`
+		Purchase price  `json:"PurchaseDetails"`
+	}
+	var missing []string
+	var items []itemPrice
+	for _, code := range codes {
+		it, ok := existing[code]
+		if !ok {
+			missing = append(missing, code)
+			continue
+		}
+		items = append(items, itemPrice{ItemID: it.ItemID, Code: code, Purchase: price{UnitPrice: prices[code]}})
+	}
+	for start := 0; start < len(items); start += MaxSyncBatch {
+		b, err := json.Marshal(map[string]any{"Items": items[start:min(start+MaxSyncBatch, len(items))]})
+		if err != nil {
+			return nil, err
+		}
+		req, err := newJSONRequest(ctx, http.MethodPost, c.apiURL()+"/api.xro/2.0/Items", b, accessToken, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return nil, err
+		}
+		if status >= 300 {
+			return nil, statusError("xero items post", status, body)
+		}
+	}
+	return missing, nil
+}
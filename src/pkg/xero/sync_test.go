@@ -0,0 +1,140 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildContactsUpsertPayload(t *testing.T) {
+	b, err := buildContactsUpsertPayload([]Supplier{
+		{SupplierID: "S-001", SupplierName: "Acme", ContactEmail: "a@acme.test", Phone: "0123"},
+		{SupplierID: "S-002"},
+	}, map[string]string{"S-001": "cid-1"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	s := string(b)
+	for _, want := range []string{`"ContactID":"cid-1"`, `"AccountNumber":"S-001"`, `"PhoneNumber":"0123"`, `"Name":"S-002"`} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("payload missing %s: %s", want, s)
+		}
+	}
+	if strings.Count(s, "ContactID") != 1 {
+		t.Fatalf("new contact should not carry a ContactID: %s", s)
+	}
+	if _, err := buildContactsUpsertPayload([]Supplier{{SupplierName: "no id"}}, nil); err == nil {
+		t.Fatal("expected error for missing supplier id")
+	}
+}
+
+func TestUpsertContactsBatch_UsesExistingIDs(t *testing.T) {
+	var posted string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`{"Contacts":[{"ContactID":"cid-1","AccountNumber":"S-001"}]}`))
+		case http.MethodPost:
+			b, _ := io.ReadAll(r.Body)
+			posted = string(b)
+			_, _ = w.Write([]byte(`{"Contacts":[]}`))
+		}
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	err := client.UpsertContactsBatch(context.Background(), "at", "tid", []Supplier{{SupplierID: "S-001", SupplierName: "Acme"}, {SupplierID: "S-009", SupplierName: "New"}})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	var body struct {
+		Contacts []struct {
+			ContactID     string
+			AccountNumber string
+		}
+	}
+	if err := json.Unmarshal([]byte(posted), &body); err != nil || len(body.Contacts) != 2 {
+		t.Fatalf("unexpected post body: %s (%v)", posted, err)
+	}
+	if body.Contacts[0].ContactID != "cid-1" || body.Contacts[1].ContactID != "" {
+		t.Fatalf("unexpected contact ids: %+v", body.Contacts)
+	}
+}
+
+func TestUpsertItemsBatch_TooLarge(t *testing.T) {
+	items := make([]Part, MaxSyncBatch+1)
+	if err := (&Client{}).UpsertItemsBatch(context.Background(), "at", "tid", items); err == nil {
+		t.Fatal("expected error for oversized batch")
+	}
+}
+
+func TestSyncSuppliersToXero_SkipsUnchangedAndOld(t *testing.T) {
+	var posts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`{"Contacts":[
+				{"ContactID":"cid-1","AccountNumber":"S-001","Name":"Acme","EmailAddress":"a@acme.test","Phones":[{"PhoneType":"DEFAULT","PhoneNumber":"01"}]},
+				{"ContactID":"cid-2","AccountNumber":"S-002","Name":"Old Name"}
+			]}`))
+		case http.MethodPost:
+			b, _ := io.ReadAll(r.Body)
+			posts = append(posts, string(b))
+			_, _ = w.Write([]byte(`{"Contacts":[]}`))
+		}
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	since := time.Unix(1000, 0)
+	res, err := client.SyncSuppliersToXero(context.Background(), "at", "tid", []Supplier{
+		{SupplierID: "S-001", SupplierName: "Acme", ContactEmail: "a@acme.test", Phone: "01", UpdatedAt: 2000},
+		{SupplierID: "S-002", SupplierName: "New Name", UpdatedAt: 2000},
+		{SupplierID: "S-003", SupplierName: "Brand New"},
+		{SupplierID: "S-004", SupplierName: "Untouched", UpdatedAt: 500},
+	}, since)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	want := SupplierSyncResult{Created: 1, Updated: 1, Unchanged: 1, Skipped: 1}
+	if res != want {
+		t.Fatalf("got %+v want %+v", res, want)
+	}
+	if len(posts) != 1 || !strings.Contains(posts[0], `"ContactID":"cid-2"`) || !strings.Contains(posts[0], `"AccountNumber":"S-003"`) || strings.Contains(posts[0], "S-001") {
+		t.Fatalf("unexpected posts: %v", posts)
+	}
+}
+
+func TestUpdatePurchasePrices(t *testing.T) {
+	var posted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`{"Items":[{"ItemID":"i-1","Code":"BOLT","Name":"Bolt"}]}`))
+		case http.MethodPost:
+			b, _ := io.ReadAll(r.Body)
+			posted = append(posted, string(b))
+			_, _ = w.Write([]byte(`{"Items":[]}`))
+		}
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	missing, err := client.UpdatePurchasePrices(context.Background(), "at", "tid", map[string]float64{"BOLT": 0.3, "GONE": 1})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(missing) != 1 || missing[0] != "GONE" {
+		t.Fatalf("missing = %v", missing)
+	}
+	// only the price is sent, so Xero keeps the item's name and sales details
+	want := `{"Items":[{"ItemID":"i-1","Code":"BOLT","PurchaseDetails":{"UnitPrice":0.3}}]}`
+	if len(posted) != 1 || posted[0] != want {
+		t.Fatalf("posted %v, want %s", posted, want)
+	}
+}
@@ -0,0 +1,110 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TrackingCategory is a Xero tracking category (e.g. "Build") and its options.
+type TrackingCategory struct {
+	TrackingCategoryID string           `json:"TrackingCategoryID"`
+	Name               string           `json:"Name"`
+	Status             string           `json:"Status"`
+	Options            []TrackingOption `json:"Options"`
+}
+
+// TrackingOption is one value of a tracking category (e.g. a van or job).
+type TrackingOption struct {
+	TrackingOptionID string `json:"TrackingOptionID"`
+	Name             string `json:"Name"`
+	Status           string `json:"Status"`
+}
+
+// LineTracking assigns a line item to an option of a tracking category, both by
+// name as Xero expects on line items.
+type LineTracking struct {
+	Name   string `json:"Name"`
+	Option string `json:"Option"`
+}
+
+// Option returns the category's option with the given name (case-insensitive).
+func (tc TrackingCategory) Option(name string) (TrackingOption, bool) {
+	for _, o := range tc.Options {
+		if strings.EqualFold(o.Name, name) {
+			return o, true
+		}
+	}
+	return TrackingOption{}, false
+}
+
+// FindTrackingCategory returns the category in cats with the given name
+// (case-insensitive).
+func FindTrackingCategory(cats []TrackingCategory, name string) (TrackingCategory, bool) {
+	for _, tc := range cats {
+		if strings.EqualFold(tc.Name, name) {
+			return tc, true
+		}
+	}
+	return TrackingCategory{}, false
+}
+
+// GetTrackingCategories returns the tenant's active tracking categories with their
+// active options.
+func (c *Client) GetTrackingCategories(ctx context.Context, accessToken, tenantID string) ([]TrackingCategory, error) {
+	req, err := newJSONRequest(ctx, http.MethodGet, c.apiURL()+"/api.xro/2.0/TrackingCategories", nil, accessToken, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return nil, err
+	}
+	if status >= 300 {
+		return nil, statusError("get tracking categories", status, body)
+	}
+	var res struct {
+		TrackingCategories []TrackingCategory `json:"TrackingCategories"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	return res.TrackingCategories, nil
+}
+
+// CreateTrackingOption adds an option named name to the tracking category
+// categoryID and returns it.
+func (c *Client) CreateTrackingOption(ctx context.Context, accessToken, tenantID, categoryID, name string) (TrackingOption, error) {
+	if categoryID == "" || strings.TrimSpace(name) == "" {
+		return TrackingOption{}, fmt.Errorf("tracking category id and option name required")
+	}
+	b, err := json.Marshal(map[string]string{"Name": strings.TrimSpace(name)})
+	if err != nil {
+		return TrackingOption{}, err
+	}
+	u := fmt.Sprintf("%s/api.xro/2.0/TrackingCategories/%s/Options", c.apiURL(), url.PathEscape(categoryID))
+	req, err := newJSONRequest(ctx, http.MethodPut, u, b, accessToken, tenantID)
+	if err != nil {
+		return TrackingOption{}, err
+	}
+	status, body, err := c.doJSON(req)
+	if err != nil {
+		return TrackingOption{}, err
+	}
+	if status >= 300 {
+		return TrackingOption{}, statusError("create tracking option", status, body)
+	}
+	var res struct {
+		Options []TrackingOption `json:"Options"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return TrackingOption{}, err
+	}
+	if len(res.Options) == 0 {
+		return TrackingOption{}, fmt.Errorf("create tracking option: no option returned")
+	}
+	return res.Options[0], nil
+}
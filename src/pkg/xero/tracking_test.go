@@ -0,0 +1,81 @@
+package xero
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrackingCategories(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api.xro/2.0/TrackingCategories":
+			_, _ = w.Write([]byte(`{"TrackingCategories":[{"TrackingCategoryID":"tc-1","Name":"Build","Status":"ACTIVE",
+				"Options":[{"TrackingOptionID":"to-1","Name":"INV-0001","Status":"ACTIVE"}]}]}`))
+		case "PUT /api.xro/2.0/TrackingCategories/tc-1/Options":
+			b, _ := io.ReadAll(r.Body)
+			if string(b) != `{"Name":"INV-0002"}` {
+				t.Errorf("unexpected body %s", b)
+			}
+			_, _ = w.Write([]byte(`{"Options":[{"TrackingOptionID":"to-2","Name":"INV-0002","Status":"ACTIVE"}]}`))
+		default:
+			http.Error(w, "unexpected", http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+	ctx := context.Background()
+
+	cats, err := client.GetTrackingCategories(ctx, "at", "tid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc, ok := FindTrackingCategory(cats, "build")
+	if !ok || tc.TrackingCategoryID != "tc-1" {
+		t.Fatalf("category not found: %+v", cats)
+	}
+	if o, ok := tc.Option("inv-0001"); !ok || o.TrackingOptionID != "to-1" {
+		t.Fatalf("option not found: %+v", tc)
+	}
+	if _, ok := tc.Option("INV-0002"); ok {
+		t.Fatal("unexpected option")
+	}
+
+	o, err := client.CreateTrackingOption(ctx, "at", "tid", "tc-1", " INV-0002 ")
+	if err != nil || o.TrackingOptionID != "to-2" {
+		t.Fatalf("CreateTrackingOption = %+v, %v", o, err)
+	}
+	if _, err := client.CreateTrackingOption(ctx, "at", "tid", "tc-1", ""); err == nil {
+		t.Fatal("expected error for an empty name")
+	}
+}
+
+func TestBuildPOPayload_Tracking(t *testing.T) {
+	items := []POItem{
+		{ItemCode: "BOLT", Quantity: 2, Tracking: []LineTracking{{Name: "Build", Option: "INV-0001"}}},
+		{ItemCode: "NUT", Quantity: 1},
+	}
+	b, err := buildPOPayload("c-1", items, PODetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		PurchaseOrders []struct {
+			LineItems []map[string]json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	lines := got.PurchaseOrders[0].LineItems
+	if string(lines[0]["Tracking"]) != `[{"Name":"Build","Option":"INV-0001"}]` {
+		t.Fatalf("tracking not sent: %s", b)
+	}
+	if _, ok := lines[1]["Tracking"]; ok || strings.Count(string(b), "Tracking") != 1 {
+		t.Fatalf("untracked line has tracking: %s", b)
+	}
+}
@@ -0,0 +1,67 @@
+package xero
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"events":[],"firstEventSequence":0,"lastEventSequence":0,"entropy":"S0m3r4Nd0mt3xt"}`)
+	sig := SignWebhook(body, "old-key")
+
+	if !VerifyWebhook(body, sig, "old-key") {
+		t.Fatal("signature under its own key did not verify")
+	}
+	// during a rotation either key verifies, in either position
+	if !VerifyWebhook(body, sig, "new-key", "old-key") || !VerifyWebhook(body, SignWebhook(body, "new-key"), "new-key", "old-key") {
+		t.Fatal("rotation: a delivery signed with either key should verify")
+	}
+	for name, tc := range map[string]struct {
+		body []byte
+		sig  string
+		keys []string
+	}{
+		"other key":    {body, sig, []string{"new-key"}},
+		"no keys":      {body, sig, []string{"", ""}},
+		"changed body": {append([]byte(" "), body...), sig, []string{"old-key"}},
+		"not base64":   {body, "not base64!", []string{"old-key"}},
+		"empty":        {body, "", []string{"old-key"}},
+	} {
+		if VerifyWebhook(tc.body, tc.sig, tc.keys...) {
+			t.Errorf("%s: verified", name)
+		}
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	p, err := ParseWebhook([]byte(`{"events":[{"resourceUrl":"https://api.xero.com/api.xro/2.0/Invoices/inv-1",
+		"resourceId":"inv-1","eventDateUtc":"2017-06-21T01:15:39.902","eventType":"UPDATE","eventCategory":"INVOICE",
+		"tenantId":"tenant-1","tenantType":"ORGANISATION"}],"firstEventSequence":7,"lastEventSequence":7,"entropy":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Events) != 1 || p.LastEventSequence != 7 {
+		t.Fatalf("payload: %+v", p)
+	}
+	e := p.Events[0]
+	if e.EventCategory != "INVOICE" || e.TenantID != "tenant-1" || !e.EventDateUTC.Equal(time.Date(2017, 6, 21, 1, 15, 39, 902e6, time.UTC)) {
+		t.Fatalf("event: %+v", e)
+	}
+
+	// the same change redelivered has the same id; another change does not
+	again := e
+	if e.ID() != again.ID() || len(e.ID()) != 64 {
+		t.Fatalf("id %q not stable", e.ID())
+	}
+	later := e
+	later.EventDateUTC = Timestamp{Time: e.EventDateUTC.Add(time.Second)}
+	other := e
+	other.ResourceID = "inv-2"
+	if later.ID() == e.ID() || other.ID() == e.ID() {
+		t.Fatal("different events share an id")
+	}
+
+	if _, err := ParseWebhook([]byte(`{"events":`)); err == nil {
+		t.Fatal("expected error for truncated body")
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // Supplier represents a supplier row used when syncing contacts to Xero.
@@ -17,6 +20,7 @@ type Supplier struct {
 	SupplierName string `json:"supplier_name"`
 	ContactEmail string `json:"contact_email"`
 	Phone        string `json:"phone"`
+	UpdatedAt    int64  `json:"updated_at,omitempty"` // epoch seconds; 0 when unknown
 }
 
 // newJSONRequest builds an HTTP request with standard Xero headers.
@@ -42,30 +46,78 @@ func newJSONRequest(ctx context.Context, method, u string, body []byte, accessTo
 	return req, nil
 }
 
-// doJSON executes a request and returns status + raw body for assertions.
-func doJSON(client *http.Client, req *http.Request) (int, []byte, error) {
-	resp, err := client.Do(req)
+// doJSON executes a request with c's HTTP client and returns status + raw body.
+// GET and HEAD requests are retried according to the call's RetryPolicy; the last
+// attempt's result is returned.
+func (c *Client) doJSON(req *http.Request) (int, []byte, error) {
+	status, b, _, err := c.doJSONHeader(req)
+	return status, b, err
+}
+
+// doJSONHeader is doJSON that also returns the headers of the last response (nil
+// when there was none).
+func (c *Client) doJSONHeader(req *http.Request) (int, []byte, http.Header, error) {
+	ctx := req.Context()
+	policy := c.retryPolicy(ctx)
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		policy = NoRetry
+	}
+	for attempt := 1; ; attempt++ {
+		status, b, header, err := c.send(req)
+		if attempt >= policy.MaxAttempts || !retryable(status, err) || ctx.Err() != nil {
+			return status, b, header, err
+		}
+		if err := sleepCtx(ctx, policy.delay(attempt, header)); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+}
+
+// send makes one attempt at req.
+func (c *Client) send(req *http.Request) (int, []byte, http.Header, error) {
+	resp, err := c.httpClient().Do(req.Clone(req.Context()))
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, err
 	}
 	defer resp.Body.Close()
 	b, _ := io.ReadAll(resp.Body)
-	return resp.StatusCode, b, nil
+	return resp.StatusCode, b, resp.Header, nil
 }
 
-// buildPOPayload constructs a minimal PO payload.
-func buildPOPayload(contactID string, items []POItem) ([]byte, error) {
+// buildPOPayload constructs a minimal PO payload; empty details are left out.
+func buildPOPayload(contactID string, items []POItem, details PODetails) ([]byte, error) {
 	if contactID == "" {
 		return nil, fmt.Errorf("contact id missing")
 	}
+	status := details.Status
+	if status == "" {
+		status = "AUTHORISED"
+	}
+	po := map[string]interface{}{
+		"Contact":   map[string]string{"ContactID": contactID},
+		"LineItems": items,
+		"Status":    status,
+	}
+	if details.BrandingThemeID != "" {
+		po["BrandingThemeID"] = details.BrandingThemeID
+	}
+	if details.SentToContact {
+		po["SentToContact"] = true
+	}
+	if details.DeliveryAddress != "" {
+		po["DeliveryAddress"] = details.DeliveryAddress
+	}
+	if details.AttentionTo != "" {
+		po["AttentionTo"] = details.AttentionTo
+	}
+	if details.Reference != "" {
+		po["Reference"] = details.Reference
+	}
+	if details.CurrencyCode != "" {
+		po["CurrencyCode"] = details.CurrencyCode
+	}
 	payload := map[string]interface{}{
-		"PurchaseOrders": []map[string]interface{}{
-			{
-				"Contact":   map[string]string{"ContactID": contactID},
-				"LineItems": items,
-				"Status":    "AUTHORISED",
-			},
-		},
+		"PurchaseOrders": []map[string]interface{}{po},
 	}
 	return json.Marshal(payload)
 }
@@ -147,19 +199,25 @@ func parseFirstItemID(b []byte) (string, error) {
 	return res.Items[0].ItemID, nil
 }
 
-func parseInvoiceID(b []byte) (string, error) {
+// invoiceHead is the part of a listed invoice GetInvoiceItemCodes checks.
+type invoiceHead struct {
+	InvoiceID string `json:"InvoiceID"`
+	Type      string `json:"Type"`
+	Status    string `json:"Status"`
+}
+
+// parseInvoiceHead returns the first invoice of a list response (zero when none).
+func parseInvoiceHead(b []byte) (invoiceHead, error) {
 	var listShape struct {
-		Invoices []struct {
-			InvoiceID string `json:"InvoiceID"`
-		} `json:"Invoices"`
+		Invoices []invoiceHead `json:"Invoices"`
 	}
 	if err := json.Unmarshal(b, &listShape); err != nil {
-		return "", err
+		return invoiceHead{}, err
 	}
 	if len(listShape.Invoices) == 0 {
-		return "", nil
+		return invoiceHead{}, nil
 	}
-	return listShape.Invoices[0].InvoiceID, nil
+	return listShape.Invoices[0], nil
 }
 
 func parseInvoiceLines(b []byte) ([]InvoiceLine, error) {
@@ -234,10 +292,11 @@ type Connection struct {
 }
 
 // BuildAuthURL builds the Xero authorize URL.
-func BuildAuthURL(clientID, redirectURI, state string) string {
+func (c *Client) BuildAuthURL(clientID, redirectURI, state string) string {
 	scope := "offline_access accounting.contacts accounting.transactions accounting.settings"
 	// url encode redirectURI and state via QueryEscape
-	return fmt.Sprintf("https://login.xero.com/identity/connect/authorize?response_type=code&client_id=%s&redirect_uri=%s&scope=%s&state=%s",
+	return fmt.Sprintf("%s/identity/connect/authorize?response_type=code&client_id=%s&redirect_uri=%s&scope=%s&state=%s",
+		c.loginURL(),
 		url.QueryEscape(clientID),
 		url.QueryEscape(redirectURI),
 		url.QueryEscape(scope),
@@ -246,25 +305,25 @@ func BuildAuthURL(clientID, redirectURI, state string) string {
 }
 
 // ExchangeCodeForToken exchanges an authorization code for tokens.
-func ExchangeCodeForToken(ctx context.Context, httpClient *http.Client, clientID, clientSecret, code, redirectURI string) (*TokenResponse, error) {
+func (c *Client) ExchangeCodeForToken(ctx context.Context, clientID, clientSecret, code, redirectURI string) (*TokenResponse, error) {
 	form := url.Values{}
 	form.Set("grant_type", "authorization_code")
 	form.Set("code", code)
 	form.Set("redirect_uri", redirectURI)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://identity.xero.com/connect/token", strings.NewReader(form.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.identityURL()+"/connect/token", strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.SetBasicAuth(clientID, clientSecret)
 
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return nil, err
 	}
 	if status >= 300 {
-		return nil, fmt.Errorf("token exchange failed: status=%d body=%s", status, string(body))
+		return nil, statusError("token exchange", status, body)
 	}
 	var tr TokenResponse
 	if err := json.Unmarshal(body, &tr); err != nil {
@@ -273,25 +332,34 @@ func ExchangeCodeForToken(ctx context.Context, httpClient *http.Client, clientID
 	return &tr, nil
 }
 
-// RefreshToken exchanges a refresh token for a new access token.
-func RefreshToken(ctx context.Context, httpClient *http.Client, clientID, clientSecret, refreshToken string) (*TokenResponse, error) {
+// ErrInvalidGrant is returned by RefreshToken when Xero rejects the refresh token
+// itself (invalid_grant): the user revoked the app's access, the token expired
+// unused or it was already rotated. Only a new consent fixes it.
+var ErrInvalidGrant = errors.New("xero: refresh token rejected (invalid_grant)")
+
+// RefreshToken exchanges a refresh token for a new access token. A rejected refresh
+// token gives an error wrapping ErrInvalidGrant.
+func (c *Client) RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*TokenResponse, error) {
 	form := url.Values{}
 	form.Set("grant_type", "refresh_token")
 	form.Set("refresh_token", refreshToken)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://identity.xero.com/connect/token", strings.NewReader(form.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.identityURL()+"/connect/token", strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.SetBasicAuth(clientID, clientSecret)
 
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return nil, err
 	}
+	if isInvalidGrant(status, body) {
+		return nil, fmt.Errorf("refresh failed: status=%d: %w", status, ErrInvalidGrant)
+	}
 	if status >= 300 {
-		return nil, fmt.Errorf("refresh failed: status=%d body=%s", status, string(body))
+		return nil, statusError("refresh", status, body)
 	}
 	var tr TokenResponse
 	if err := json.Unmarshal(body, &tr); err != nil {
@@ -300,34 +368,62 @@ func RefreshToken(ctx context.Context, httpClient *http.Client, clientID, client
 	return &tr, nil
 }
 
-// GetConnections calls GET https://api.xero.com/connections and returns parsed connections.
-func GetConnections(ctx context.Context, httpClient *http.Client, accessToken string) ([]Connection, error) {
-	req, err := newJSONRequest(ctx, http.MethodGet, "https://api.xero.com/connections", nil, accessToken, "")
+// isInvalidGrant reports whether a token endpoint response is an OAuth invalid_grant
+// error (Xero answers 400; 401 is accepted too).
+func isInvalidGrant(status int, body []byte) bool {
+	if status != http.StatusBadRequest && status != http.StatusUnauthorized {
+		return false
+	}
+	var e struct {
+		Error string `json:"error"`
+	}
+	return json.Unmarshal(body, &e) == nil && e.Error == "invalid_grant"
+}
+
+// Ping checks that Xero's identity service is reachable (no credentials needed).
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := newJSONRequest(ctx, http.MethodGet, c.identityURL()+"/.well-known/openid-configuration", nil, "", "")
+	if err != nil {
+		return err
+	}
+	status, _, err := c.doJSON(req)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return statusError("xero identity ping", status, nil)
+	}
+	return nil
+}
+
+// GetConnections calls GET /connections on the API host and returns parsed connections.
+func (c *Client) GetConnections(ctx context.Context, accessToken string) ([]Connection, error) {
+	req, err := newJSONRequest(ctx, http.MethodGet, c.apiURL()+"/connections", nil, accessToken, "")
 	if err != nil {
 		return nil, err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return nil, err
 	}
 	if status >= 300 {
-		return nil, fmt.Errorf("/connections failed: status=%d body=%s", status, string(body))
+		return nil, statusError("/connections", status, body)
 	}
 	return parseConnectionsJSON(body)
 }
 
 // GetItemNameByID returns item Name for a given Xero ItemID.
 // found=false if not found.
-func GetItemNameByID(ctx context.Context, httpClient *http.Client, accessToken, tenantID, itemID string) (name string, found bool, err error) {
+func (c *Client) GetItemNameByID(ctx context.Context, accessToken, tenantID, itemID string) (name string, found bool, err error) {
 	if itemID == "" {
 		return "", false, nil
 	}
-	u := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Items/%s", url.PathEscape(itemID))
+	u := fmt.Sprintf("%s/api.xro/2.0/Items/%s", c.apiURL(), url.PathEscape(itemID))
 	req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
 	if err != nil {
 		return "", false, err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return "", false, err
 	}
@@ -335,29 +431,96 @@ func GetItemNameByID(ctx context.Context, httpClient *http.Client, accessToken,
 		return "", false, nil
 	}
 	if status >= 300 {
-		return "", false, fmt.Errorf("get item by id failed: status=%d body=%s", status, string(body))
+		return "", false, statusError("get item by id", status, body)
 	}
 	return parseFirstItemName(body)
 }
 
+// ItemSummary is the subset of a Xero Item used when resolving BOMs.
+type ItemSummary struct {
+	ItemID          string           `json:"ItemID"`
+	Code            string           `json:"Code"`
+	Name            string           `json:"Name"`
+	PurchaseDetails ItemPriceDetails `json:"PurchaseDetails"`
+
+	// stock levels; QuantityOnHand is only meaningful for tracked inventory items
+	IsTrackedAsInventory bool    `json:"IsTrackedAsInventory"`
+	QuantityOnHand       float64 `json:"QuantityOnHand"`
+}
+
+// ItemPriceDetails is the purchase (or sales) price block of a Xero Item.
+type ItemPriceDetails struct {
+	UnitPrice float64 `json:"UnitPrice"`
+}
+
+// itemCodesPerRequest keeps the where= filter (and URL) a sensible size.
+const itemCodesPerRequest = 40
+
+// GetItemsByCodes fetches items for many codes using OR'd where filters, batched and
+// fetched up to Client.Concurrency batches at a time. Codes not present in Xero are
+// simply absent from the result.
+func (c *Client) GetItemsByCodes(ctx context.Context, accessToken, tenantID string, codes []string) (map[string]ItemSummary, error) {
+	var mu sync.Mutex
+	out := make(map[string]ItemSummary, len(codes))
+	err := c.eachBatch(ctx, len(codes), itemCodesPerRequest, func(ctx context.Context, start, end int) error {
+		var clauses []string
+		for _, code := range codes[start:end] {
+			if code != "" {
+				clauses = append(clauses, fmt.Sprintf(`Code=="%s"`, code))
+			}
+		}
+		if len(clauses) == 0 {
+			return nil
+		}
+		u := c.apiURL() + "/api.xro/2.0/Items?where=" + url.QueryEscape(strings.Join(clauses, " OR "))
+		req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
+		if err != nil {
+			return err
+		}
+		status, body, err := c.doJSON(req)
+		if err != nil {
+			return err
+		}
+		if status >= 300 {
+			return statusError("get items by code", status, body)
+		}
+		var res struct {
+			Items []ItemSummary `json:"Items"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, it := range res.Items {
+			out[it.Code] = it
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GetItemNameByCode returns item Name for a given Xero Item Code.
 // found=false if not found.
-func GetItemNameByCode(ctx context.Context, httpClient *http.Client, accessToken, tenantID, code string) (string, bool, error) {
+func (c *Client) GetItemNameByCode(ctx context.Context, accessToken, tenantID, code string) (string, bool, error) {
 	if code == "" {
 		return "", false, nil
 	}
 	where := url.QueryEscape(fmt.Sprintf(`Code=="%s"`, code))
-	u := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Items?where=%s", where)
+	u := fmt.Sprintf("%s/api.xro/2.0/Items?where=%s", c.apiURL(), where)
 	req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
 	if err != nil {
 		return "", false, err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return "", false, err
 	}
 	if status >= 300 {
-		return "", false, fmt.Errorf("get item by code failed: status=%d body=%s", status, string(body))
+		return "", false, statusError("get item by code", status, body)
 	}
 	return parseFirstItemName(body)
 }
@@ -366,27 +529,27 @@ func GetItemNameByCode(ctx context.Context, httpClient *http.Client, accessToken
 // Keeps payload minimal (Code, Name, Description, SalesDetails.UnitPrice) to avoid account/tax validation.
 // Uses upsert behavior: if an item with the same Code exists it will be updated, otherwise created.
 // Does not delete or touch items not present in the provided slice.
-func SyncPartsToXero(ctx context.Context, httpClient *http.Client, accessToken, tenantID string, items []Part) error {
+func (c *Client) SyncPartsToXero(ctx context.Context, accessToken, tenantID string, items []Part) error {
 	if len(items) == 0 {
 		return nil
 	}
 	codeToID := func(code string) (string, error) {
-		return GetItemIDByCode(ctx, httpClient, accessToken, tenantID, code)
+		return c.GetItemIDByCode(ctx, accessToken, tenantID, code)
 	}
 	b, err := buildItemsUpsertPayload(items, codeToID)
 	if err != nil {
 		return err
 	}
-	req, err := newJSONRequest(ctx, http.MethodPost, "https://api.xero.com/api.xro/2.0/Items", b, accessToken, tenantID)
+	req, err := newJSONRequest(ctx, http.MethodPost, c.apiURL()+"/api.xro/2.0/Items", b, accessToken, tenantID)
 	if err != nil {
 		return err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return err
 	}
 	if status >= 300 {
-		return fmt.Errorf("xero items post failed: status=%d body=%s", status, string(body))
+		return statusError("xero items post", status, body)
 	}
 	return nil
 }
@@ -398,96 +561,163 @@ type InvoiceLine struct {
 	Quantity float64 `json:"quantity"`
 }
 
+// Invoice types.
+const (
+	InvoiceTypeSales = "ACCREC" // a sales invoice
+	InvoiceTypeBill  = "ACCPAY" // a bill from a supplier
+)
+
+// InvoiceStatuses are the statuses a Xero invoice can have, in lifecycle order.
+var InvoiceStatuses = []string{"DRAFT", "SUBMITTED", "AUTHORISED", "PAID", "VOIDED", "DELETED"}
+
+// InvoiceFilter restricts the invoices GetInvoiceItemCodes accepts. Empty lists
+// accept anything.
+type InvoiceFilter struct {
+	Types    []string
+	Statuses []string
+}
+
+// InvoiceRejectedError is returned by GetInvoiceItemCodes when the invoice exists
+// but its type or status is not allowed by the filter.
+type InvoiceRejectedError struct {
+	InvoiceNumber string
+	Type          string
+	Status        string
+	// WrongType is set when the type was refused, else the status was.
+	WrongType bool
+}
+
+func (e *InvoiceRejectedError) Error() string {
+	if e.WrongType {
+		return fmt.Sprintf("invoice %s has type %s", e.InvoiceNumber, e.Type)
+	}
+	return fmt.Sprintf("invoice %s is %s", e.InvoiceNumber, e.Status)
+}
+
+// check returns an *InvoiceRejectedError when f refuses the invoice.
+func (f InvoiceFilter) check(number, typ, status string) error {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, typ) {
+		return &InvoiceRejectedError{InvoiceNumber: number, Type: typ, Status: status, WrongType: true}
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, status) {
+		return &InvoiceRejectedError{InvoiceNumber: number, Type: typ, Status: status}
+	}
+	return nil
+}
+
 // GetInvoiceItemCodes looks up an invoice by InvoiceNumber and returns the ItemCode(s)
-// and Names/Quantities for the invoice's line items.
-func GetInvoiceItemCodes(ctx context.Context, httpClient *http.Client, accessToken, tenantID, invoiceNumber string) ([]InvoiceLine, error) {
+// and Names/Quantities for the invoice's line items. An invoice that f refuses
+// gives an *InvoiceRejectedError; an unknown one gives no lines.
+func (c *Client) GetInvoiceItemCodes(ctx context.Context, accessToken, tenantID, invoiceNumber string, f InvoiceFilter) ([]InvoiceLine, error) {
 	if invoiceNumber == "" {
 		return nil, fmt.Errorf("invoice number empty")
 	}
 	// find InvoiceID by InvoiceNumber
 	where := url.QueryEscape(fmt.Sprintf(`InvoiceNumber=="%s"`, invoiceNumber))
-	listURL := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Invoices?where=%s", where)
+	listURL := fmt.Sprintf("%s/api.xro/2.0/Invoices?where=%s", c.apiURL(), where)
 	req, err := newJSONRequest(ctx, http.MethodGet, listURL, nil, accessToken, tenantID)
 	if err != nil {
 		return nil, err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return nil, err
 	}
 	if status >= 300 {
-		return nil, fmt.Errorf("invoices lookup failed: status=%d body=%s", status, string(body))
+		return nil, statusError("invoices lookup", status, body)
 	}
-	invoiceID, err := parseInvoiceID(body)
-	if err != nil || invoiceID == "" {
+	head, err := parseInvoiceHead(body)
+	if err != nil || head.InvoiceID == "" {
 		return nil, nil
 	}
+	if err := f.check(invoiceNumber, head.Type, head.Status); err != nil {
+		return nil, err
+	}
+	invoiceID := head.InvoiceID
 
 	// fetch invoice detail
-	detailURL := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Invoices/%s", invoiceID)
+	detailURL := fmt.Sprintf("%s/api.xro/2.0/Invoices/%s", c.apiURL(), invoiceID)
 	req2, err := newJSONRequest(ctx, http.MethodGet, detailURL, nil, accessToken, tenantID)
 	if err != nil {
 		return nil, err
 	}
-	status, body, err = doJSON(httpClient, req2)
+	status, body, err = c.doJSON(req2)
 	if err != nil {
 		return nil, err
 	}
 	if status >= 300 {
-		return nil, fmt.Errorf("invoice detail fetch failed: status=%d body=%s", status, string(body))
+		return nil, statusError("invoice detail fetch", status, body)
 	}
 	return parseInvoiceLines(body)
 }
 
-// POItem is a minimal purchase order line (ItemCode + Quantity).
+// POItem is a purchase order line.
 type POItem struct {
-	ItemCode    string `json:"ItemCode"`
-	Quantity    int    `json:"Quantity"`
-	Description string `json:"Description,omitempty"`
+	ItemCode string `json:"ItemCode"`
+	// Quantity may be fractional (metres, kilograms); Xero keeps 4 decimal places
+	Quantity    float64 `json:"Quantity"`
+	Description string  `json:"Description,omitempty"`
+	// UnitAmount is the price per unit; 0 leaves it to Xero's default for the item
+	UnitAmount float64 `json:"UnitAmount,omitempty"`
+	// AccountCode is the expense account; "" uses the item's purchase account
+	AccountCode string `json:"AccountCode,omitempty"`
+	// Tracking assigns the line to tracking category options (at most two in Xero)
+	Tracking []LineTracking `json:"Tracking,omitempty"`
+}
+
+// PODetails are the optional header fields of a purchase order.
+type PODetails struct {
+	Status          string // DRAFT, SUBMITTED or AUTHORISED; "" = AUTHORISED
+	BrandingThemeID string
+	DeliveryAddress string // free text, one address line per line
+	AttentionTo     string
+	Reference       string // e.g. the source invoice number
+	SentToContact   bool   // mark the PO as sent to the supplier
+	CurrencyCode    string // ISO 4217, e.g. "EUR"; "" = the organisation's base currency
 }
 
 // GetContactIDByAccountNumber looks up a Xero ContactID by AccountNumber.
 // Returns empty string if not found.
-func GetContactIDByAccountNumber(ctx context.Context, httpClient *http.Client, accessToken, tenantID, accountNumber string) (string, error) {
+func (c *Client) GetContactIDByAccountNumber(ctx context.Context, accessToken, tenantID, accountNumber string) (string, error) {
 	if accountNumber == "" {
 		return "", nil
 	}
 	where := url.QueryEscape(fmt.Sprintf(`AccountNumber=="%s"`, accountNumber))
-	u := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Contacts?where=%s", where)
+	u := fmt.Sprintf("%s/api.xro/2.0/Contacts?where=%s", c.apiURL(), where)
 	req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
 	if err != nil {
 		return "", err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return "", err
 	}
 	if status >= 300 {
-		return "", fmt.Errorf("contacts lookup failed: status=%d body=%s", status, string(body))
+		return "", statusError("contacts lookup", status, body)
 	}
 	return parseFirstContactID(body)
 }
 
 // CreatePurchaseOrder posts a minimal PurchaseOrder payload to Xero using ContactID.
 // contactID must be the Xero Contacts.ContactID GUID.
-func CreatePurchaseOrder(ctx context.Context, httpClient *http.Client, accessToken, tenantID, contactID string, items []POItem) (string, error) {
+func (c *Client) CreatePurchaseOrder(ctx context.Context, accessToken, tenantID, contactID string, items []POItem, details PODetails) (string, error) {
 	if len(items) == 0 {
 		return "", fmt.Errorf("no items")
 	}
-	b, err := buildPOPayload(contactID, items)
+	b, err := buildPOPayload(contactID, items, details)
 	if err != nil {
 		return "", err
 	}
-	req, err := newJSONRequest(ctx, http.MethodPost, "https://api.xero.com/api.xro/2.0/PurchaseOrders", b, accessToken, tenantID)
+	req, err := newJSONRequest(ctx, http.MethodPost, c.apiURL()+"/api.xro/2.0/PurchaseOrders", b, accessToken, tenantID)
 	if err != nil {
 		return "", err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return "", err
 	}
 	if status >= 300 {
-		return "", fmt.Errorf("create purchase order failed: status=%d body=%s", status, string(body))
+		return "", statusError("create purchase order", status, body)
 	}
 	var res struct {
 		PurchaseOrders []struct {
@@ -502,19 +732,19 @@ func CreatePurchaseOrder(ctx context.Context, httpClient *http.Client, accessTok
 
 // helper to find an existing item by Code. returns ItemID if found, empty string if not.
 // GetItemIDByCode returns Xero ItemID for a given item Code (empty if not found).
-func GetItemIDByCode(ctx context.Context, httpClient *http.Client, accessToken, tenantID, code string) (string, error) {
+func (c *Client) GetItemIDByCode(ctx context.Context, accessToken, tenantID, code string) (string, error) {
 	where := url.QueryEscape(fmt.Sprintf(`Code=="%s"`, code))
-	u := fmt.Sprintf("https://api.xero.com/api.xro/2.0/Items?where=%s", where)
+	u := fmt.Sprintf("%s/api.xro/2.0/Items?where=%s", c.apiURL(), where)
 	req, err := newJSONRequest(ctx, http.MethodGet, u, nil, accessToken, tenantID)
 	if err != nil {
 		return "", err
 	}
-	status, body, err := doJSON(httpClient, req)
+	status, body, err := c.doJSON(req)
 	if err != nil {
 		return "", err
 	}
 	if status >= 300 {
-		return "", fmt.Errorf("get item by code failed: status=%d body=%s", status, string(body))
+		return "", statusError("get item by code", status, body)
 	}
 	return parseFirstItemID(body)
 }
@@ -3,11 +3,15 @@ package xero
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // helper to decode JSON and return map for simple assertions
@@ -19,7 +23,7 @@ func mustUnmarshal(t *testing.T, b []byte, out any) {
 }
 
 func TestBuildPOPayload_EmptyContactID(t *testing.T) {
-	_, err := buildPOPayload("", []POItem{{ItemCode: "A", Quantity: 1}})
+	_, err := buildPOPayload("", []POItem{{ItemCode: "A", Quantity: 1}}, PODetails{})
 	if err == nil {
 		t.Fatalf("expected error for empty contact id")
 	}
@@ -29,7 +33,7 @@ func TestBuildPOPayload_Success(t *testing.T) {
 	items := []POItem{
 		{ItemCode: "C1", Quantity: 2, Description: "desc"},
 	}
-	b, err := buildPOPayload("contact-123", items)
+	b, err := buildPOPayload("contact-123", items, PODetails{Status: "DRAFT", AttentionTo: "Stores", Reference: "INV-0042"})
 	if err != nil {
 		t.Fatalf("buildPOPayload failed: %v", err)
 	}
@@ -43,6 +47,23 @@ func TestBuildPOPayload_Success(t *testing.T) {
 	if contact["ContactID"] != "contact-123" {
 		t.Fatalf("unexpected contact id: %v", contact["ContactID"])
 	}
+	if po["Status"] != "DRAFT" || po["AttentionTo"] != "Stores" || po["Reference"] != "INV-0042" {
+		t.Fatalf("unexpected details: %v", po)
+	}
+	for _, k := range []string{"DeliveryAddress", "BrandingThemeID", "SentToContact", "CurrencyCode"} {
+		if _, ok := po[k]; ok {
+			t.Fatalf("empty %s should be omitted: %v", k, po)
+		}
+	}
+
+	b, err = buildPOPayload("contact-123", items, PODetails{CurrencyCode: "EUR"})
+	if err != nil {
+		t.Fatalf("buildPOPayload failed: %v", err)
+	}
+	mustUnmarshal(t, b, &got)
+	if po := got["PurchaseOrders"][0]; po["CurrencyCode"] != "EUR" {
+		t.Fatalf("currency code not sent: %v", po)
+	}
 }
 
 func TestBuildItemsUpsertPayload_CodeToItemID(t *testing.T) {
@@ -115,7 +136,7 @@ func TestBuildAuthURL_Escaping(t *testing.T) {
 	clientID := "cid"
 	redirect := "https://example.com/cb?a=1"
 	state := "s t&x"
-	u := BuildAuthURL(clientID, redirect, state)
+	u := (&Client{}).BuildAuthURL(clientID, redirect, state)
 	// ensure redirect and state are query-escaped
 	if !strings.Contains(u, url.QueryEscape(redirect)) {
 		t.Fatalf("redirect not escaped in URL: %s", u)
@@ -143,12 +164,10 @@ func TestGetItemNameByID_NotFoundAndSuccess(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	target, _ := url.Parse(ts.URL)
-	// wrap the test server client's transport so absolute requests get rewritten
-	client := &http.Client{Transport: hostRewriter{base: ts.Client().Transport, target: target}}
+	client := NewClient(ts.Client(), ts.URL)
 
 	// not found case should return found=false, nil error
-	name, found, err := GetItemNameByID(context.Background(), client, "at", "tid", "notfound")
+	name, found, err := client.GetItemNameByID(context.Background(), "at", "tid", "notfound")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -156,7 +175,7 @@ func TestGetItemNameByID_NotFoundAndSuccess(t *testing.T) {
 		t.Fatalf("expected not found")
 	}
 	// success
-	name, found, err = GetItemNameByID(context.Background(), client, "at", "tid", "someid")
+	name, found, err = client.GetItemNameByID(context.Background(), "at", "tid", "someid")
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -176,24 +195,258 @@ func TestGetItemNameByCode_ErrorStatus(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	client := ts.Client()
-	_, _, err := GetItemNameByCode(context.Background(), client, "at", "tid", "code123")
+	client := NewClient(ts.Client(), ts.URL)
+	client.Retry = &NoRetry
+	_, _, err := client.GetItemNameByCode(context.Background(), "at", "tid", "code123")
 	if err == nil {
 		t.Fatalf("expected error on non-200")
 	}
 }
 
-// helper transport that rewrites absolute host/scheme to target (httptest) host.
-type hostRewriter struct {
-	base   http.RoundTripper
-	target *url.URL
+func TestPing(t *testing.T) {
+	ok := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" || !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"issuer":"https://identity.xero.com"}`))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+	client.Retry = &NoRetry
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	ok = false
+	if err := client.Ping(context.Background()); err == nil {
+		t.Fatalf("expected error when identity endpoint is down")
+	}
+}
+
+func TestRefreshToken_InvalidGrant(t *testing.T) {
+	status, body := http.StatusBadRequest, `{"error":"invalid_grant"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+	client.Retry = &NoRetry
+
+	_, err := client.RefreshToken(context.Background(), "id", "secret", "rt")
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("expected ErrInvalidGrant, got %v", err)
+	}
+	status, body = http.StatusBadRequest, `{"error":"invalid_client"}`
+	_, err = client.RefreshToken(context.Background(), "id", "secret", "rt")
+	if err == nil || errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("invalid_client is not a revoked grant: %v", err)
+	}
+	status, body = http.StatusOK, `{"access_token":"at","refresh_token":"rt2","expires_in":1800}`
+	tr, err := client.RefreshToken(context.Background(), "id", "secret", "rt")
+	if err != nil || tr.RefreshToken != "rt2" {
+		t.Fatalf("unexpected %+v %v", tr, err)
+	}
+}
+
+func TestGetItemsByCodes_Batches(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		where := r.URL.Query().Get("where")
+		var items []string
+		for _, clause := range strings.Split(where, " OR ") {
+			code := strings.TrimSuffix(strings.TrimPrefix(clause, `Code=="`), `"`)
+			if code == "MISSING" {
+				continue
+			}
+			items = append(items, fmt.Sprintf(`{"ItemID":"id-%s","Code":"%s","Name":"Name %s","PurchaseDetails":{"UnitPrice":2.5},"IsTrackedAsInventory":true,"QuantityOnHand":7}`, code, code, code))
+		}
+		_, _ = w.Write([]byte(`{"Items":[` + strings.Join(items, ",") + `]}`))
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+
+	codes := []string{"MISSING"}
+	for i := 0; i < 45; i++ {
+		codes = append(codes, fmt.Sprintf("P%d", i))
+	}
+	got, err := client.GetItemsByCodes(context.Background(), "at", "tid", codes)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 batched calls, got %d", n)
+	}
+	if len(got) != 45 || got["P44"].Name != "Name P44" || got["P44"].PurchaseDetails.UnitPrice != 2.5 ||
+		!got["P44"].IsTrackedAsInventory || got["P44"].QuantityOnHand != 7 {
+		t.Fatalf("unexpected items: %d %+v", len(got), got["P44"])
+	}
+	if _, ok := got["MISSING"]; ok {
+		t.Fatalf("missing code should be absent")
+	}
 }
 
-func (h hostRewriter) RoundTrip(req *http.Request) (*http.Response, error) {
-	// clone request and rewrite scheme/host so requests to api.xero.com go to ts
-	n := req.Clone(req.Context())
-	n.URL.Scheme = h.target.Scheme
-	n.URL.Host = h.target.Host
-	n.Host = h.target.Host
-	return h.base.RoundTrip(n)
+func TestGetItemsByCodes_Concurrency(t *testing.T) {
+	var inFlight, peak, calls atomic.Int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		<-release
+		code := strings.TrimSuffix(strings.TrimPrefix(strings.Split(r.URL.Query().Get("where"), " OR ")[0], `Code=="`), `"`)
+		fmt.Fprintf(w, `{"Items":[{"ItemID":"id-%s","Code":"%s"}]}`, code, code)
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+	client.Concurrency = 3
+
+	// 10 batches: the first 3 start together and no more until one finishes
+	var codes []string
+	for i := 0; i < 10*itemCodesPerRequest; i++ {
+		codes = append(codes, fmt.Sprintf("P%d", i))
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.GetItemsByCodes(context.Background(), "at", "tid", codes)
+		done <- err
+	}()
+	for inFlight.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("%d requests started, want 3 at once", n)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 10 || peak.Load() != 3 {
+		t.Fatalf("calls=%d peak=%d, want 10 calls at most 3 at once", calls.Load(), peak.Load())
+	}
+}
+
+func TestGetItemsByCodes_BatchError(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"Message":"bad filter"}`, http.StatusBadRequest)
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+	client.Concurrency = 1
+
+	codes := make([]string, 5*itemCodesPerRequest)
+	for i := range codes {
+		codes[i] = fmt.Sprintf("P%d", i)
+	}
+	_, err := client.GetItemsByCodes(context.Background(), "at", "tid", codes)
+	if err == nil || !strings.Contains(err.Error(), "status=400") {
+		t.Fatalf("err = %v, want the batch's error", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("%d requests after the first failed, want the rest skipped", n)
+	}
+}
+
+func TestClient_Concurrency(t *testing.T) {
+	for in, want := range map[int]int{0: DefaultConcurrency, -1: DefaultConcurrency, 2: 2, 50: MaxConcurrency} {
+		if got := (&Client{Concurrency: in}).concurrency(); got != want {
+			t.Errorf("Concurrency %d: got %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestClient_BaseURLs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_, _ = w.Write([]byte(`{}`))
+		case "/api.xro/2.0/Items":
+			_, _ = w.Write([]byte(`{"Items":[{"Code":"A","Name":"Widget"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(ts.Client(), ts.URL+"/")
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("ping via base url: %v", err)
+	}
+	if name, ok, err := client.GetItemNameByCode(context.Background(), "at", "tid", "A"); err != nil || !ok || name != "Widget" {
+		t.Fatalf("item via base url: %q %v %v", name, ok, err)
+	}
+	if u := client.BuildAuthURL("id", "http://localhost/cb", "s"); !strings.HasPrefix(u, ts.URL+"/identity/connect/authorize?") {
+		t.Fatalf("auth url not rebased: %s", u)
+	}
+
+	// hosts can be split, and empty fields fall back to the real Xero hosts
+	split := &Client{IdentityURL: ts.URL, HTTPClient: ts.Client()}
+	if err := split.Ping(context.Background()); err != nil {
+		t.Fatalf("ping via identity url: %v", err)
+	}
+	if u := split.BuildAuthURL("id", "http://localhost/cb", "s"); !strings.HasPrefix(u, DefaultLoginURL+"/") {
+		t.Fatalf("auth url should use the default login host: %s", u)
+	}
+	if got := split.apiURL(); got != DefaultAPIURL {
+		t.Fatalf("api url = %q, want default", got)
+	}
+}
+
+func TestInvoiceSearchWhere(t *testing.T) {
+	got := invoiceSearchWhere(InvoiceSearch{
+		Contact: ` Acme "Bikes" `,
+		From:    time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC),
+		To:      time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+	})
+	want := `Type=="ACCREC" AND Contact.Name.Contains("Acme Bikes") AND Date>=DateTime(2024,03,01) AND Date<=DateTime(2024,03,31)`
+	if got != want {
+		t.Fatalf("where = %s\nwant    %s", got, want)
+	}
+	if got := invoiceSearchWhere(InvoiceSearch{}); got != `Type=="ACCREC"` {
+		t.Fatalf("empty search where = %s", got)
+	}
+}
+
+func TestGetInvoiceItemCodes_Filter(t *testing.T) {
+	head := `{"InvoiceID":"i1","Type":"ACCREC","Status":"VOIDED"}`
+	details := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api.xro/2.0/Invoices" {
+			fmt.Fprintf(w, `{"Invoices":[%s]}`, head)
+			return
+		}
+		details++
+		fmt.Fprint(w, `{"Invoices":[{"LineItems":[{"ItemCode":"KIT","Quantity":1}]}]}`)
+	}))
+	defer ts.Close()
+	client := NewClient(ts.Client(), ts.URL)
+	client.Retry = &NoRetry
+	ctx := context.Background()
+	f := InvoiceFilter{Types: []string{InvoiceTypeSales}, Statuses: []string{"AUTHORISED", "PAID"}}
+
+	_, err := client.GetInvoiceItemCodes(ctx, "at", "t", "INV-1", f)
+	var rej *InvoiceRejectedError
+	if !errors.As(err, &rej) || rej.WrongType || rej.Status != "VOIDED" {
+		t.Fatalf("expected status rejection, got %v", err)
+	}
+	head = `{"InvoiceID":"i1","Type":"ACCPAY","Status":"PAID"}`
+	if _, err := client.GetInvoiceItemCodes(ctx, "at", "t", "INV-1", f); !errors.As(err, &rej) || !rej.WrongType || rej.Type != "ACCPAY" {
+		t.Fatalf("expected type rejection, got %v", err)
+	}
+	if details != 0 {
+		t.Fatal("rejected invoices must not be fetched")
+	}
+	// the zero filter accepts anything
+	lines, err := client.GetInvoiceItemCodes(ctx, "at", "t", "INV-1", InvoiceFilter{})
+	if err != nil || len(lines) != 1 || details != 1 {
+		t.Fatalf("unexpected %+v %v", lines, err)
+	}
 }
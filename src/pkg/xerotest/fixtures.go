@@ -0,0 +1,213 @@
+package xerotest
+
+import "github.com/hwalton/xero-invoice-orderer/pkg/xero"
+
+// Fixtures is the initial state of a fake Xero server. Field names and JSON tags follow
+// the Xero accounting API so fixtures can be written as Xero would return them.
+// Records without an UpdatedDateUTC count as older than any If-Modified-Since.
+type Fixtures struct {
+	Tenants        []Tenant
+	Items          []Item
+	Contacts       []Contact
+	Invoices       []Invoice
+	CreditNotes    []CreditNote
+	PurchaseOrders []PurchaseOrder
+	// TrackingCategories are returned by GET /TrackingCategories; purchase order
+	// lines may only use their options.
+	TrackingCategories []TrackingCategory
+}
+
+// Tenant is an organisation returned by GET /connections.
+type Tenant struct {
+	TenantID   string `json:"tenantId"`
+	TenantName string `json:"tenantName"`
+	// BaseCurrency is returned by GET /Organisation; "" reports GBP
+	BaseCurrency string `json:"-"`
+}
+
+// Item is a Xero inventory item.
+type Item struct {
+	ItemID               string         `json:"ItemID"`
+	Code                 string         `json:"Code"`
+	Name                 string         `json:"Name"`
+	Description          string         `json:"Description,omitempty"`
+	PurchaseDetails      PriceDetails   `json:"PurchaseDetails"`
+	SalesDetails         PriceDetails   `json:"SalesDetails"`
+	IsTrackedAsInventory bool           `json:"IsTrackedAsInventory"`
+	QuantityOnHand       float64        `json:"QuantityOnHand"`
+	UpdatedDateUTC       xero.Timestamp `json:"UpdatedDateUTC"`
+}
+
+// PriceDetails is an item's purchase or sales price.
+type PriceDetails struct {
+	UnitPrice float64 `json:"UnitPrice"`
+}
+
+// Contact is a Xero contact; suppliers are matched by AccountNumber.
+type Contact struct {
+	ContactID      string         `json:"ContactID"`
+	Name           string         `json:"Name"`
+	AccountNumber  string         `json:"AccountNumber"`
+	EmailAddress   string         `json:"EmailAddress,omitempty"`
+	Phones         []Phone        `json:"Phones,omitempty"`
+	UpdatedDateUTC xero.Timestamp `json:"UpdatedDateUTC"`
+	// DefaultCurrency is the ISO 4217 code the contact trades in, if set
+	DefaultCurrency string `json:"DefaultCurrency,omitempty"`
+}
+
+// Phone is a contact phone number.
+type Phone struct {
+	PhoneType   string `json:"PhoneType"`
+	PhoneNumber string `json:"PhoneNumber"`
+}
+
+// Invoice is an invoice; Type defaults to ACCREC (sales) and Status to AUTHORISED.
+// DateString is "2006-01-02T00:00:00".
+type Invoice struct {
+	InvoiceID      string          `json:"InvoiceID"`
+	InvoiceNumber  string          `json:"InvoiceNumber"`
+	Type           string          `json:"Type,omitempty"`
+	Status         string          `json:"Status,omitempty"`
+	Contact        PurchaseContact `json:"Contact"`
+	DateString     string          `json:"DateString,omitempty"`
+	Total          float64         `json:"Total,omitempty"`
+	LineItems      []LineItem      `json:"LineItems"`
+	UpdatedDateUTC xero.Timestamp  `json:"UpdatedDateUTC"`
+	// CurrencyCode and CurrencyRate (units per unit of the base currency) are set
+	// on invoices in a foreign currency
+	CurrencyCode string  `json:"CurrencyCode,omitempty"`
+	CurrencyRate float64 `json:"CurrencyRate,omitempty"`
+}
+
+// CreditNote is a credit note; Type defaults to ACCRECCREDIT (against sales invoices)
+// and Status to AUTHORISED.
+type CreditNote struct {
+	CreditNoteID     string          `json:"CreditNoteID"`
+	CreditNoteNumber string          `json:"CreditNoteNumber"`
+	Type             string          `json:"Type,omitempty"`
+	Status           string          `json:"Status,omitempty"`
+	Contact          PurchaseContact `json:"Contact"`
+	LineItems        []LineItem      `json:"LineItems"`
+	Allocations      []Allocation    `json:"Allocations,omitempty"`
+}
+
+// Allocation applies part of a credit note to an invoice.
+type Allocation struct {
+	Amount  float64    `json:"Amount"`
+	Invoice InvoiceRef `json:"Invoice"`
+}
+
+// InvoiceRef identifies the invoice of an Allocation.
+type InvoiceRef struct {
+	InvoiceID     string `json:"InvoiceID,omitempty"`
+	InvoiceNumber string `json:"InvoiceNumber"`
+}
+
+// LineItem is an invoice or purchase order line.
+type LineItem struct {
+	ItemCode    string  `json:"ItemCode"`
+	Description string  `json:"Description,omitempty"`
+	Quantity    float64 `json:"Quantity"`
+	UnitAmount  float64 `json:"UnitAmount,omitempty"`
+	AccountCode string  `json:"AccountCode,omitempty"`
+	// Tracking is only kept on purchase order lines.
+	Tracking []LineTracking `json:"Tracking,omitempty"`
+}
+
+// LineTracking assigns a line to an option of a tracking category, by name.
+type LineTracking struct {
+	Name   string `json:"Name"`
+	Option string `json:"Option"`
+}
+
+// TrackingCategory is a tracking category and its options; Status defaults to ACTIVE.
+type TrackingCategory struct {
+	TrackingCategoryID string           `json:"TrackingCategoryID"`
+	Name               string           `json:"Name"`
+	Status             string           `json:"Status,omitempty"`
+	Options            []TrackingOption `json:"Options"`
+}
+
+// TrackingOption is one option of a tracking category.
+type TrackingOption struct {
+	TrackingOptionID string `json:"TrackingOptionID"`
+	Name             string `json:"Name"`
+	Status           string `json:"Status,omitempty"`
+}
+
+// PurchaseOrder is a Xero purchase order. DateString is "2006-01-02T15:04:05".
+type PurchaseOrder struct {
+	PurchaseOrderID     string          `json:"PurchaseOrderID"`
+	PurchaseOrderNumber string          `json:"PurchaseOrderNumber"`
+	Status              string          `json:"Status"`
+	DateString          string          `json:"DateString"`
+	Contact             PurchaseContact `json:"Contact"`
+	LineItems           []LineItem      `json:"LineItems"`
+	Reference           string          `json:"Reference,omitempty"`
+	DeliveryAddress     string          `json:"DeliveryAddress,omitempty"`
+	AttentionTo         string          `json:"AttentionTo,omitempty"`
+	BrandingThemeID     string          `json:"BrandingThemeID,omitempty"`
+	SentToContact       bool            `json:"SentToContact,omitempty"`
+	CurrencyCode        string          `json:"CurrencyCode,omitempty"`
+	// Total is set from the line items on creation (no tax)
+	Total float64 `json:"Total,omitempty"`
+}
+
+// PurchaseContact is the contact summary embedded in a PurchaseOrder.
+type PurchaseContact struct {
+	ContactID     string `json:"ContactID"`
+	Name          string `json:"Name,omitempty"`
+	AccountNumber string `json:"AccountNumber,omitempty"`
+}
+
+// DevFixtures is a demo organisation matching the dev seed data (control-panel seed):
+// the seeded parts and kits as items, the seeded suppliers as contacts, two
+// invoices for the kits and a "Build" tracking category.
+func DevFixtures() Fixtures {
+	item := func(code, name string, cost, sale float64, onHand float64) Item {
+		return Item{
+			ItemID:               "item-" + code,
+			Code:                 code,
+			Name:                 name,
+			PurchaseDetails:      PriceDetails{UnitPrice: cost},
+			SalesDetails:         PriceDetails{UnitPrice: sale},
+			IsTrackedAsInventory: onHand > 0,
+			QuantityOnHand:       onHand,
+		}
+	}
+	return Fixtures{
+		Tenants: []Tenant{{TenantID: "dev-tenant", TenantName: "Demo Company (fake Xero)"}},
+		Items: []Item{
+			item("P-0001", "M6 x 20 hex bolt (pack of 50)", 6.40, 11.50, 2),
+			item("P-0002", "12V LED strip 1m", 4.95, 9.99, 0),
+			item("P-0003", "12V rocker switch", 2.10, 4.50, 0),
+			item("P-0004", "40x40 aluminium extrusion 1m", 9.80, 17.00, 0),
+			item("P-0005", "Hinge, stainless 75mm", 3.25, 6.00, 0),
+			item("P-0006", "Wago 221 connector", 0.38, 0.90, 25),
+			item("P-0007", "18mm birch ply sheet", 62.00, 95.00, 0),
+			item("P-0008", "Self-tapping screw 4x30 (pack of 200)", 5.60, 9.50, 0),
+			item("P-0009", "M6 nyloc nut (pack of 100)", 3.90, 7.20, 0),
+			item("P-0010", "Corner bracket 40 series", 1.15, 2.40, 0),
+			item("KIT-001", "Cabinet door kit", 0, 45.00, 0),
+			item("KIT-002", "Lighting kit", 0, 39.00, 0),
+		},
+		Contacts: []Contact{
+			{ContactID: "contact-S-001", Name: "Northern Fasteners Ltd", AccountNumber: "S-001", EmailAddress: "orders@northernfasteners.example"},
+			{ContactID: "contact-S-002", Name: "Brightline Electrical", AccountNumber: "S-002", EmailAddress: "sales@brightline.example"},
+			{ContactID: "contact-S-003", Name: "Coastal Timber Supplies", AccountNumber: "S-003", EmailAddress: "trade@coastaltimber.example"},
+			{ContactID: "contact-S-004", Name: "Midlands Metalwork", AccountNumber: "S-004", EmailAddress: "hello@midlandsmetal.example"},
+		},
+		Invoices: []Invoice{
+			{InvoiceID: "invoice-INV-0001", InvoiceNumber: "INV-0001", Status: "AUTHORISED", Contact: PurchaseContact{ContactID: "contact-C-001", Name: "Harbour Kitchens"}, DateString: "2025-03-03T00:00:00", Total: 540, LineItems: []LineItem{
+				{ItemCode: "KIT-001", Description: "Cabinet door kit", Quantity: 2},
+				{ItemCode: "P-0002", Description: "12V LED strip 1m", Quantity: 3},
+			}},
+			{InvoiceID: "invoice-INV-0002", InvoiceNumber: "INV-0002", Status: "AUTHORISED", Contact: PurchaseContact{ContactID: "contact-C-002", Name: "Lakeside Joinery"}, DateString: "2025-03-10T00:00:00", Total: 260, LineItems: []LineItem{
+				{ItemCode: "KIT-002", Description: "Lighting kit", Quantity: 4},
+			}},
+		},
+		TrackingCategories: []TrackingCategory{
+			{TrackingCategoryID: "tracking-build", Name: "Build", Options: []TrackingOption{{TrackingOptionID: "tracking-INV-0001", Name: "INV-0001"}}},
+		},
+	}
+}
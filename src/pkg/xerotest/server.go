@@ -0,0 +1,818 @@
+// Package xerotest is an in-memory fake of the parts of the Xero API this app uses:
+// the OAuth authorize and token endpoints, /connections, and the Items, Invoices,
+// CreditNotes, Contacts and PurchaseOrders accounting endpoints. It serves handler
+// tests and local development without Xero credentials, and can simulate Xero's rate
+// limiting.
+//
+// All tenants share one data set; requests only need a bearer token and a known
+// Xero-tenant-id header.
+package xerotest
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+)
+
+// pageSize matches Xero's fixed page size for the paged list endpoints.
+const pageSize = 100
+
+// dateStringLayout is the layout of Xero's DateString fields.
+const dateStringLayout = "2006-01-02T15:04:05"
+
+// RateLimit configures simulated rate limiting of API requests (not identity calls).
+type RateLimit struct {
+	// PerMinute is the number of API requests allowed in any rolling minute; 0 disables it.
+	PerMinute int
+	// RetryAfter is sent with 429 responses; defaults to one second.
+	RetryAfter time.Duration
+}
+
+// Server is a running fake Xero API. Create it with New and Close it when done.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	data      Fixtures
+	nextID    int
+	rateLimit RateLimit
+	recent    []time.Time // API request times within the last minute
+	throttle  int         // upcoming API requests to reject with 429
+	requests  []string
+}
+
+// New starts a fake Xero server seeded with a copy of f.
+func New(f Fixtures) *Server {
+	s := &Server{data: cloneFixtures(f)}
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// Client returns a Xero client with every host pointed at the fake.
+func (s *Server) Client() *xero.Client {
+	return xero.NewClient(s.Server.Client(), s.URL)
+}
+
+// SetRateLimit changes the simulated rate limit.
+func (s *Server) SetRateLimit(rl RateLimit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = rl
+	s.recent = nil
+}
+
+// Throttle makes the next n API requests fail with 429 Too Many Requests.
+func (s *Server) Throttle(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttle = n
+}
+
+// Requests returns "METHOD /path" for every request received, in order.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// Items returns the current items.
+func (s *Server) Items() []Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.data.Items)
+}
+
+// Contacts returns the current contacts.
+func (s *Server) Contacts() []Contact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.data.Contacts)
+}
+
+// PurchaseOrders returns the current purchase orders, including those created through the API.
+func (s *Server) PurchaseOrders() []PurchaseOrder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.data.PurchaseOrders)
+}
+
+// TrackingCategories returns the current tracking categories, including options
+// created through the API.
+func (s *Server) TrackingCategories() []TrackingCategory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneFixtures(Fixtures{TrackingCategories: s.data.TrackingCategories}).TrackingCategories
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /identity/connect/authorize", s.authorize)
+	mux.HandleFunc("POST /connect/token", s.token)
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"issuer": "https://identity.xero.com"})
+	})
+	mux.Handle("GET /connections", s.api(false, s.connections))
+
+	mux.Handle("GET /api.xro/2.0/Organisation", s.api(true, s.organisation))
+
+	mux.Handle("GET /api.xro/2.0/Items", s.api(true, s.listItems))
+	mux.Handle("GET /api.xro/2.0/Items/{id}", s.api(true, s.getItem))
+	mux.Handle("POST /api.xro/2.0/Items", s.api(true, s.upsertItems))
+
+	mux.Handle("GET /api.xro/2.0/Contacts", s.api(true, s.listContacts))
+	mux.Handle("POST /api.xro/2.0/Contacts", s.api(true, s.upsertContacts))
+
+	mux.Handle("GET /api.xro/2.0/Invoices", s.api(true, s.listInvoices))
+	mux.Handle("GET /api.xro/2.0/Invoices/{id}", s.api(true, s.getInvoice))
+
+	mux.Handle("GET /api.xro/2.0/CreditNotes/{id}", s.api(true, s.getCreditNote))
+
+	mux.Handle("GET /api.xro/2.0/PurchaseOrders", s.api(true, s.listPurchaseOrders))
+	mux.Handle("GET /api.xro/2.0/PurchaseOrders/{id}", s.api(true, s.getPurchaseOrder))
+	mux.Handle("POST /api.xro/2.0/PurchaseOrders", s.api(true, s.createPurchaseOrders))
+
+	mux.Handle("GET /api.xro/2.0/TrackingCategories", s.api(true, s.listTrackingCategories))
+	mux.Handle("PUT /api.xro/2.0/TrackingCategories/{id}/Options", s.api(true, s.createTrackingOption))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// api wraps an API endpoint with rate limiting and the bearer/tenant checks. The
+// handler runs with s.mu held.
+func (s *Server) api(needTenant bool, h func(w http.ResponseWriter, r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.limited(time.Now()) {
+			retry := s.rateLimit.RetryAfter
+			if retry <= 0 {
+				retry = time.Second
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+			w.Header().Set("X-Rate-Limit-Problem", "minute")
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"Title": "Too Many Requests"})
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"Title": "Unauthorized", "Detail": "missing bearer token"})
+			return
+		}
+		if needTenant && !s.knownTenant(r.Header.Get("Xero-tenant-id")) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"Title": "Forbidden", "Detail": "AuthorizationUnsuccessful"})
+			return
+		}
+		h(w, r)
+	})
+}
+
+// limited records an API request at now and reports whether it must be rejected.
+func (s *Server) limited(now time.Time) bool {
+	if s.throttle > 0 {
+		s.throttle--
+		return true
+	}
+	if s.rateLimit.PerMinute <= 0 {
+		return false
+	}
+	cutoff := now.Add(-time.Minute)
+	kept := s.recent[:0]
+	for _, t := range s.recent {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	s.recent = kept
+	if len(s.recent) >= s.rateLimit.PerMinute {
+		return true
+	}
+	s.recent = append(s.recent, now)
+	return false
+}
+
+func (s *Server) knownTenant(id string) bool {
+	for _, t := range s.data.Tenants {
+		if t.TenantID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// newID returns a unique id with the given prefix.
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+// authorize stands in for the Xero login and consent pages: it approves at once and
+// redirects back to redirect_uri with a code and the caller's state.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" || q.Get("client_id") == "" {
+		http.Error(w, "invalid client_id or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	code := s.newID("code")
+	s.mu.Unlock()
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token implements the authorization_code and refresh_token grants. Any client
+// credentials are accepted; each call issues fresh tokens.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := r.BasicAuth(); !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		if r.PostFormValue("code") == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	case "refresh_token":
+		if r.PostFormValue("refresh_token") == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	s.mu.Lock()
+	access, refresh := s.newID("access"), s.newID("refresh")
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"expires_in":    1800,
+		"token_type":    "Bearer",
+	})
+}
+
+func (s *Server) connections(w http.ResponseWriter, r *http.Request) {
+	type connection struct {
+		ID         string `json:"id"`
+		TenantID   string `json:"tenantId"`
+		TenantType string `json:"tenantType"`
+		TenantName string `json:"tenantName"`
+	}
+	out := make([]connection, 0, len(s.data.Tenants))
+	for _, t := range s.data.Tenants {
+		out = append(out, connection{ID: "conn-" + t.TenantID, TenantID: t.TenantID, TenantType: "ORGANISATION", TenantName: t.TenantName})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// organisation returns the requesting tenant as its organisation.
+func (s *Server) organisation(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get("Xero-tenant-id")
+	i := slices.IndexFunc(s.data.Tenants, func(t Tenant) bool { return t.TenantID == id })
+	t := s.data.Tenants[i]
+	currency := t.BaseCurrency
+	if currency == "" {
+		currency = "GBP"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"Organisations": []map[string]string{{
+		"OrganisationID": "org-" + t.TenantID,
+		"Name":           t.TenantName,
+		"BaseCurrency":   currency,
+		"ShortCode":      "!" + t.TenantID,
+	}}})
+}
+
+// listItems supports the Code where filter and If-Modified-Since; like Xero it returns
+// every match unless a page parameter is given.
+func (s *Server) listItems(w http.ResponseWriter, r *http.Request) {
+	match, err := whereFilter(r, "Code")
+	if err != nil {
+		validationError(w, err.Error())
+		return
+	}
+	page, size, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	since, ok := modifiedSince(w, r)
+	if !ok {
+		return
+	}
+	out := []Item{}
+	for _, it := range s.data.Items {
+		if match(it.Code) && changedSince(it.UpdatedDateUTC, since) {
+			out = append(out, it)
+		}
+	}
+	if r.URL.Query().Has("page") {
+		out = pageOf(out, page, size)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"Items": out})
+}
+
+// getItem accepts an ItemID or an item Code, as Xero does.
+func (s *Server) getItem(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, it := range s.data.Items {
+		if it.ItemID == id || it.Code == id {
+			writeJSON(w, http.StatusOK, map[string]any{"Items": []Item{it}})
+			return
+		}
+	}
+	notFound(w)
+}
+
+// upsertItems updates items matched by ItemID or Code and creates the rest. Stock
+// fields, and the name, description and price details when left out, are kept on
+// update.
+func (s *Server) upsertItems(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Items []struct {
+			Item
+			Purchase *PriceDetails `json:"PurchaseDetails"`
+			Sales    *PriceDetails `json:"SalesDetails"`
+		} `json:"Items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		validationError(w, "invalid JSON: "+err.Error())
+		return
+	}
+	out := make([]Item, 0, len(body.Items))
+	for _, posted := range body.Items {
+		in := posted.Item
+		if posted.Purchase != nil {
+			in.PurchaseDetails = *posted.Purchase
+		}
+		if posted.Sales != nil {
+			in.SalesDetails = *posted.Sales
+		}
+		if in.Code == "" {
+			validationError(w, "Code is required")
+			return
+		}
+		i := slices.IndexFunc(s.data.Items, func(it Item) bool {
+			return (in.ItemID != "" && it.ItemID == in.ItemID) || it.Code == in.Code
+		})
+		if i < 0 {
+			if in.ItemID == "" {
+				in.ItemID = s.newID("item")
+			}
+			in.UpdatedDateUTC = xero.Timestamp{Time: time.Now().UTC()}
+			s.data.Items = append(s.data.Items, in)
+			out = append(out, in)
+			continue
+		}
+		cur := &s.data.Items[i]
+		cur.Code = in.Code
+		if in.Name != "" {
+			cur.Name = in.Name
+		}
+		if in.Description != "" {
+			cur.Description = in.Description
+		}
+		if posted.Purchase != nil {
+			cur.PurchaseDetails = in.PurchaseDetails
+		}
+		if posted.Sales != nil {
+			cur.SalesDetails = in.SalesDetails
+		}
+		cur.UpdatedDateUTC = xero.Timestamp{Time: time.Now().UTC()}
+		out = append(out, *cur)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"Items": out})
+}
+
+// listContacts supports the AccountNumber where filter, If-Modified-Since and the page
+// parameter.
+func (s *Server) listContacts(w http.ResponseWriter, r *http.Request) {
+	match, err := whereFilter(r, "AccountNumber")
+	if err != nil {
+		validationError(w, err.Error())
+		return
+	}
+	page, size, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	since, ok := modifiedSince(w, r)
+	if !ok {
+		return
+	}
+	var out []Contact
+	for _, c := range s.data.Contacts {
+		if match(c.AccountNumber) && changedSince(c.UpdatedDateUTC, since) {
+			out = append(out, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"Contacts": pageOf(out, page, size)})
+}
+
+// upsertContacts updates contacts matched by ContactID and creates the rest.
+func (s *Server) upsertContacts(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Contacts []Contact `json:"Contacts"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		validationError(w, "invalid JSON: "+err.Error())
+		return
+	}
+	out := make([]Contact, 0, len(body.Contacts))
+	for _, in := range body.Contacts {
+		if in.Name == "" {
+			validationError(w, "Name is required")
+			return
+		}
+		in.UpdatedDateUTC = xero.Timestamp{Time: time.Now().UTC()}
+		i := slices.IndexFunc(s.data.Contacts, func(c Contact) bool { return in.ContactID != "" && c.ContactID == in.ContactID })
+		if i < 0 {
+			if in.ContactID != "" {
+				validationError(w, "contact "+in.ContactID+" not found")
+				return
+			}
+			in.ContactID = s.newID("contact")
+			s.data.Contacts = append(s.data.Contacts, in)
+		} else {
+			s.data.Contacts[i] = in
+		}
+		out = append(out, in)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"Contacts": out})
+}
+
+// listInvoices supports the where filters pkg/xero sends (InvoiceNumber lists, or
+// Type, CurrencyCode, Contact.Name.Contains and Date bounds joined by AND), the ContactIDs and
+// Statuses lists, order=Date DESC, If-Modified-Since and the page and pageSize
+// parameters.
+func (s *Server) listInvoices(w http.ResponseWriter, r *http.Request) {
+	match, err := invoiceFilter(r)
+	if err != nil {
+		validationError(w, err.Error())
+		return
+	}
+	page, size, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+	since, ok := modifiedSince(w, r)
+	if !ok {
+		return
+	}
+	inList := func(param, v string) bool {
+		list := r.URL.Query().Get(param)
+		return list == "" || slices.Contains(strings.Split(list, ","), v)
+	}
+	var out []Invoice
+	for _, inv := range s.data.Invoices {
+		if match(inv) && changedSince(inv.UpdatedDateUTC, since) && inList("ContactIDs", inv.Contact.ContactID) && inList("Statuses", inv.Status) {
+			out = append(out, inv)
+		}
+	}
+	if order := r.URL.Query().Get("order"); order == "Date DESC" {
+		slices.SortStableFunc(out, func(a, b Invoice) int { return strings.Compare(b.DateString, a.DateString) })
+	} else if order != "" {
+		validationError(w, "unsupported order "+order)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"Invoices": pageOf(out, page, size)})
+}
+
+// getInvoice accepts an InvoiceID or an InvoiceNumber, as Xero does.
+func (s *Server) getInvoice(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, inv := range s.data.Invoices {
+		if inv.InvoiceID == id || inv.InvoiceNumber == id {
+			writeJSON(w, http.StatusOK, map[string]any{"Invoices": []Invoice{inv}})
+			return
+		}
+	}
+	notFound(w)
+}
+
+// getCreditNote accepts a CreditNoteID or a CreditNoteNumber, as Xero does.
+func (s *Server) getCreditNote(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, cn := range s.data.CreditNotes {
+		if cn.CreditNoteID == id || cn.CreditNoteNumber == id {
+			writeJSON(w, http.StatusOK, map[string]any{"CreditNotes": []CreditNote{cn}})
+			return
+		}
+	}
+	notFound(w)
+}
+
+// listPurchaseOrders supports the DateFrom filter and page parameter.
+func (s *Server) listPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var from time.Time
+	if v := q.Get("DateFrom"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			validationError(w, "invalid DateFrom")
+			return
+		}
+		from = t
+	}
+	page, size, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	var matched []PurchaseOrder
+	for _, po := range s.data.PurchaseOrders {
+		d, err := time.Parse(dateStringLayout, po.DateString)
+		if err != nil || !d.Before(from) {
+			matched = append(matched, po)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"PurchaseOrders": pageOf(matched, page, size)})
+}
+
+// pageParams reads the 1-based page and pageSize (default 100, at most 1000) query
+// parameters, answering 400 when either is invalid.
+func pageParams(w http.ResponseWriter, r *http.Request) (page, size int, ok bool) {
+	page, size = 1, pageSize
+	q := r.URL.Query()
+	if v := q.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			validationError(w, "invalid page")
+			return 0, 0, false
+		}
+		page = n
+	}
+	if v := q.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			validationError(w, "invalid pageSize")
+			return 0, 0, false
+		}
+		size = n
+	}
+	return page, size, true
+}
+
+// modifiedSince reads If-Modified-Since, answering 400 when it is not an HTTP date.
+// Without the header it returns the zero time.
+func modifiedSince(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	v := r.Header.Get("If-Modified-Since")
+	if v == "" {
+		return time.Time{}, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		validationError(w, "invalid If-Modified-Since")
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// changedSince reports whether a record updated at u matches If-Modified-Since since.
+func changedSince(u xero.Timestamp, since time.Time) bool {
+	return since.IsZero() || u.After(since)
+}
+
+// pageOf returns the given page of all; past the end it is empty, not nil.
+func pageOf[T any](all []T, page, size int) []T {
+	start := (page - 1) * size
+	if start >= len(all) {
+		return []T{}
+	}
+	return all[start:min(start+size, len(all))]
+}
+
+func (s *Server) getPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	for _, po := range s.data.PurchaseOrders {
+		if po.PurchaseOrderID == id || po.PurchaseOrderNumber == id {
+			writeJSON(w, http.StatusOK, map[string]any{"PurchaseOrders": []PurchaseOrder{po}})
+			return
+		}
+	}
+	notFound(w)
+}
+
+// createPurchaseOrders creates orders dated today. The contact must exist.
+func (s *Server) createPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		PurchaseOrders []PurchaseOrder `json:"PurchaseOrders"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		validationError(w, "invalid JSON: "+err.Error())
+		return
+	}
+	out := make([]PurchaseOrder, 0, len(body.PurchaseOrders))
+	for _, po := range body.PurchaseOrders {
+		i := slices.IndexFunc(s.data.Contacts, func(c Contact) bool { return c.ContactID == po.Contact.ContactID })
+		if i < 0 {
+			validationError(w, "contact "+po.Contact.ContactID+" not found")
+			return
+		}
+		if len(po.LineItems) == 0 {
+			validationError(w, "a purchase order needs at least one line item")
+			return
+		}
+		for _, li := range po.LineItems {
+			for _, tr := range li.Tracking {
+				if !s.trackingOptionExists(tr) {
+					validationError(w, fmt.Sprintf("tracking option %q of category %q not found", tr.Option, tr.Name))
+					return
+				}
+			}
+		}
+		c := s.data.Contacts[i]
+		po.Contact = PurchaseContact{ContactID: c.ContactID, Name: c.Name, AccountNumber: c.AccountNumber}
+		po.PurchaseOrderID = s.newID("po")
+		po.PurchaseOrderNumber = fmt.Sprintf("PO-%04d", len(s.data.PurchaseOrders)+1)
+		if po.Status == "" {
+			po.Status = "DRAFT"
+		}
+		po.DateString = time.Now().UTC().Truncate(24 * time.Hour).Format(dateStringLayout)
+		po.Total = 0
+		for _, li := range po.LineItems {
+			po.Total += li.Quantity * li.UnitAmount
+		}
+		s.data.PurchaseOrders = append(s.data.PurchaseOrders, po)
+		out = append(out, po)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"PurchaseOrders": out})
+}
+
+func (s *Server) listTrackingCategories(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"TrackingCategories": s.data.TrackingCategories})
+}
+
+// createTrackingOption adds an option to a category; names must be unique within it.
+func (s *Server) createTrackingOption(w http.ResponseWriter, r *http.Request) {
+	i := slices.IndexFunc(s.data.TrackingCategories, func(tc TrackingCategory) bool {
+		return tc.TrackingCategoryID == r.PathValue("id")
+	})
+	if i < 0 {
+		notFound(w)
+		return
+	}
+	var body struct {
+		Name string `json:"Name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+		validationError(w, "an option needs a Name")
+		return
+	}
+	tc := &s.data.TrackingCategories[i]
+	if slices.ContainsFunc(tc.Options, func(o TrackingOption) bool { return strings.EqualFold(o.Name, body.Name) }) {
+		validationError(w, "For each specified tracking option the name must be unique.")
+		return
+	}
+	o := TrackingOption{TrackingOptionID: s.newID("option"), Name: body.Name, Status: "ACTIVE"}
+	tc.Options = append(tc.Options, o)
+	writeJSON(w, http.StatusOK, map[string]any{"Options": []TrackingOption{o}})
+}
+
+// trackingOptionExists reports whether tr names an option of a category, as Xero
+// requires on line items.
+func (s *Server) trackingOptionExists(tr LineTracking) bool {
+	for _, tc := range s.data.TrackingCategories {
+		if strings.EqualFold(tc.Name, tr.Name) {
+			return slices.ContainsFunc(tc.Options, func(o TrackingOption) bool { return strings.EqualFold(o.Name, tr.Option) })
+		}
+	}
+	return false
+}
+
+// whereFilter parses the `Field=="value" OR Field=="value"` filters pkg/xero sends.
+// With no where parameter every value matches.
+func whereFilter(r *http.Request, field string) (func(string) bool, error) {
+	where := r.URL.Query().Get("where")
+	if where == "" {
+		return func(string) bool { return true }, nil
+	}
+	want := map[string]bool{}
+	for _, clause := range strings.Split(where, " OR ") {
+		v, ok := strings.CutPrefix(strings.TrimSpace(clause), field+`=="`)
+		if !ok || !strings.HasSuffix(v, `"`) {
+			return nil, fmt.Errorf("unsupported where clause %q", clause)
+		}
+		want[strings.TrimSuffix(v, `"`)] = true
+	}
+	return func(v string) bool { return want[v] }, nil
+}
+
+// invoiceFilter parses an Invoices where parameter: an InvoiceNumber list as for
+// whereFilter, or clauses joined by AND among Type=="...", CurrencyCode=="...",
+// Contact.Name.Contains("...") (case-insensitive) and Date>= / Date<= DateTime(y,m,d).
+func invoiceFilter(r *http.Request) (func(Invoice) bool, error) {
+	where := r.URL.Query().Get("where")
+	if where == "" || strings.HasPrefix(where, "InvoiceNumber==") {
+		match, err := whereFilter(r, "InvoiceNumber")
+		if err != nil {
+			return nil, err
+		}
+		return func(inv Invoice) bool { return match(inv.InvoiceNumber) }, nil
+	}
+	var tests []func(Invoice) bool
+	for _, clause := range strings.Split(where, " AND ") {
+		clause = strings.TrimSpace(clause)
+		if v, ok := strings.CutPrefix(clause, `Type=="`); ok && strings.HasSuffix(v, `"`) {
+			typ := strings.TrimSuffix(v, `"`)
+			tests = append(tests, func(inv Invoice) bool { return inv.Type == typ })
+			continue
+		}
+		if v, ok := strings.CutPrefix(clause, `CurrencyCode=="`); ok && strings.HasSuffix(v, `"`) {
+			code := strings.TrimSuffix(v, `"`)
+			tests = append(tests, func(inv Invoice) bool { return inv.CurrencyCode == code })
+			continue
+		}
+		if v, ok := strings.CutPrefix(clause, `Contact.Name.Contains("`); ok && strings.HasSuffix(v, `")`) {
+			name := strings.ToLower(strings.TrimSuffix(v, `")`))
+			tests = append(tests, func(inv Invoice) bool { return strings.Contains(strings.ToLower(inv.Contact.Name), name) })
+			continue
+		}
+		var y, m, d int
+		if _, err := fmt.Sscanf(clause, "Date>=DateTime(%d,%d,%d)", &y, &m, &d); err == nil {
+			from := fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+			tests = append(tests, func(inv Invoice) bool { return inv.DateString[:min(10, len(inv.DateString))] >= from })
+			continue
+		}
+		if _, err := fmt.Sscanf(clause, "Date<=DateTime(%d,%d,%d)", &y, &m, &d); err == nil {
+			to := fmt.Sprintf("%04d-%02d-%02d", y, m, d)
+			tests = append(tests, func(inv Invoice) bool { return inv.DateString[:min(10, len(inv.DateString))] <= to })
+			continue
+		}
+		return nil, fmt.Errorf("unsupported where clause %q", clause)
+	}
+	return func(inv Invoice) bool {
+		for _, t := range tests {
+			if !t(inv) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func validationError(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusBadRequest, map[string]any{
+		"Type":     "ValidationException",
+		"Message":  "A validation exception occurred",
+		"Elements": []map[string]any{{"ValidationErrors": []map[string]string{{"Message": msg}}}},
+	})
+}
+
+func notFound(w http.ResponseWriter) {
+	writeJSON(w, http.StatusNotFound, map[string]string{"Title": "Not Found"})
+}
+
+func cloneFixtures(f Fixtures) Fixtures {
+	c := Fixtures{
+		Tenants:        slices.Clone(f.Tenants),
+		Items:          slices.Clone(f.Items),
+		Contacts:       slices.Clone(f.Contacts),
+		Invoices:       slices.Clone(f.Invoices),
+		CreditNotes:    slices.Clone(f.CreditNotes),
+		PurchaseOrders: slices.Clone(f.PurchaseOrders),
+	}
+	for _, tc := range f.TrackingCategories {
+		tc.Status = cmp.Or(tc.Status, "ACTIVE")
+		tc.Options = slices.Clone(tc.Options)
+		for i := range tc.Options {
+			tc.Options[i].Status = cmp.Or(tc.Options[i].Status, "ACTIVE")
+		}
+		c.TrackingCategories = append(c.TrackingCategories, tc)
+	}
+	// Xero always sends Type and Status
+	for i := range c.Invoices {
+		c.Invoices[i].Type = cmp.Or(c.Invoices[i].Type, xero.InvoiceTypeSales)
+		c.Invoices[i].Status = cmp.Or(c.Invoices[i].Status, "AUTHORISED")
+	}
+	for i := range c.CreditNotes {
+		c.CreditNotes[i].Type = cmp.Or(c.CreditNotes[i].Type, xero.CreditNoteTypeSales)
+		c.CreditNotes[i].Status = cmp.Or(c.CreditNotes[i].Status, "AUTHORISED")
+	}
+	return c
+}
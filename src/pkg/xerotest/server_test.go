@@ -0,0 +1,414 @@
+package xerotest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwalton/xero-invoice-orderer/pkg/xero"
+	"github.com/hwalton/xero-invoice-orderer/pkg/xerotest"
+)
+
+func newServer(t *testing.T) *xerotest.Server {
+	t.Helper()
+	s := xerotest.New(xerotest.Fixtures{
+		Tenants: []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		Items: []xerotest.Item{
+			{ItemID: "item-bolt", Code: "BOLT", Name: "M6 bolt", PurchaseDetails: xerotest.PriceDetails{UnitPrice: 0.2}, IsTrackedAsInventory: true, QuantityOnHand: 40},
+			{ItemID: "item-nut", Code: "NUT", Name: "M6 nut"},
+		},
+		Contacts: []xerotest.Contact{{ContactID: "contact-1", Name: "Fasteners Inc", AccountNumber: "SUP-1"}},
+		Invoices: []xerotest.Invoice{{
+			InvoiceID:     "inv-1",
+			InvoiceNumber: "INV-0001",
+			LineItems:     []xerotest.LineItem{{ItemCode: "FRAME", Description: "Frame", Quantity: 2}},
+		}},
+		TrackingCategories: []xerotest.TrackingCategory{{
+			TrackingCategoryID: "tc-build",
+			Name:               "Build",
+			Options:            []xerotest.TrackingOption{{TrackingOptionID: "to-1", Name: "INV-0001"}},
+		}},
+	})
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestServer_TokenAndConnections(t *testing.T) {
+	t.Parallel()
+	s := newServer(t)
+	ctx := context.Background()
+	xc := s.Client()
+
+	tr, err := xc.ExchangeCodeForToken(ctx, "id", "secret", "code", "http://localhost/cb")
+	if err != nil || tr.AccessToken == "" || tr.RefreshToken == "" || tr.ExpiresIn != 1800 {
+		t.Fatalf("exchange: %+v, %v", tr, err)
+	}
+	refreshed, err := xc.RefreshToken(ctx, "id", "secret", tr.RefreshToken)
+	if err != nil || refreshed.AccessToken == tr.AccessToken {
+		t.Fatalf("refresh: %+v, %v", refreshed, err)
+	}
+	conns, err := xc.GetConnections(ctx, tr.AccessToken)
+	if err != nil || len(conns) != 1 || conns[0].TenantName != "Acme Ltd" {
+		t.Fatalf("connections: %+v, %v", conns, err)
+	}
+	if err := xc.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	org, err := xc.GetOrganisation(ctx, tr.AccessToken, "tenant-1")
+	if err != nil || org.Name != "Acme Ltd" || org.BaseCurrency != "GBP" {
+		t.Fatalf("organisation: %+v, %v", org, err)
+	}
+}
+
+func TestServer_ItemsAndInvoices(t *testing.T) {
+	t.Parallel()
+	s := newServer(t)
+	ctx := context.Background()
+	xc := s.Client()
+
+	items, err := xc.GetItemsByCodes(ctx, "at", "tenant-1", []string{"BOLT", "MISSING"})
+	if err != nil || len(items) != 1 || items["BOLT"].QuantityOnHand != 40 || items["BOLT"].PurchaseDetails.UnitPrice != 0.2 {
+		t.Fatalf("items: %+v, %v", items, err)
+	}
+	if name, ok, err := xc.GetItemNameByID(ctx, "at", "tenant-1", "item-nut"); err != nil || !ok || name != "M6 nut" {
+		t.Fatalf("item by id: %q %v %v", name, ok, err)
+	}
+
+	lines, err := xc.GetInvoiceItemCodes(ctx, "at", "tenant-1", "INV-0001", xero.InvoiceFilter{Types: []string{xero.InvoiceTypeSales}})
+	if err != nil || len(lines) != 1 || lines[0].ItemCode != "FRAME" || lines[0].Quantity != 2 {
+		t.Fatalf("invoice lines: %+v, %v", lines, err)
+	}
+	if lines, err := xc.GetInvoiceItemCodes(ctx, "at", "tenant-1", "INV-9999", xero.InvoiceFilter{}); err != nil || lines != nil {
+		t.Fatalf("unknown invoice: %+v, %v", lines, err)
+	}
+
+	// upsert keeps stock on the existing item and creates the new one
+	if err := xc.UpsertItemsBatch(ctx, "at", "tenant-1", []xero.Part{
+		{PartID: "BOLT", Name: "M6x20 bolt"},
+		{PartID: "WASHER", Name: "M6 washer", CostPrice: 0.05},
+	}); err != nil {
+		t.Fatalf("upsert items: %v", err)
+	}
+	got := map[string]xerotest.Item{}
+	for _, it := range s.Items() {
+		got[it.Code] = it
+	}
+	if len(got) != 3 || got["BOLT"].Name != "M6x20 bolt" || got["BOLT"].QuantityOnHand != 40 || got["WASHER"].ItemID == "" {
+		t.Fatalf("items after upsert: %+v", got)
+	}
+
+	// a price update leaves the rest of the item alone
+	if missing, err := xc.UpdatePurchasePrices(ctx, "at", "tenant-1", map[string]float64{"WASHER": 0.06}); err != nil || missing != nil {
+		t.Fatalf("update prices: %v, %v", missing, err)
+	}
+	for _, it := range s.Items() {
+		if it.Code == "WASHER" && (it.Name != "M6 washer" || it.PurchaseDetails.UnitPrice != 0.06) {
+			t.Fatalf("item after price update: %+v", it)
+		}
+	}
+}
+
+func TestServer_ContactsAndPurchaseOrders(t *testing.T) {
+	t.Parallel()
+	s := newServer(t)
+	ctx := context.Background()
+	xc := s.Client()
+
+	res, err := xc.SyncSuppliersToXero(ctx, "at", "tenant-1", []xero.Supplier{
+		{SupplierID: "SUP-1", SupplierName: "Fasteners International"},
+		{SupplierID: "SUP-2", SupplierName: "Sheet Metal Co"},
+	}, time.Time{})
+	if err != nil {
+		t.Fatalf("sync suppliers: %v", err)
+	}
+	if res.Created != 1 || res.Updated != 1 {
+		t.Fatalf("sync result: %+v", res)
+	}
+	if cs := s.Contacts(); len(cs) != 2 || cs[0].Name != "Fasteners International" {
+		t.Fatalf("contacts after sync: %+v", cs)
+	}
+
+	contactID, err := xc.GetContactIDByAccountNumber(ctx, "at", "tenant-1", "SUP-1")
+	if err != nil || contactID != "contact-1" {
+		t.Fatalf("contact lookup: %q, %v", contactID, err)
+	}
+	poID, err := xc.CreatePurchaseOrder(ctx, "at", "tenant-1", contactID, []xero.POItem{{ItemCode: "BOLT", Quantity: 10}}, xero.PODetails{Reference: "INV-0001"})
+	if err != nil || poID == "" {
+		t.Fatalf("create po: %q, %v", poID, err)
+	}
+	if pos := s.PurchaseOrders(); len(pos) != 1 || pos[0].Reference != "INV-0001" {
+		t.Fatalf("stored pos: %+v", pos)
+	}
+	if _, err := xc.CreatePurchaseOrder(ctx, "at", "tenant-1", "nope", []xero.POItem{{ItemCode: "BOLT", Quantity: 1}}, xero.PODetails{}); err == nil {
+		t.Fatal("expected validation error for unknown contact")
+	}
+
+	po, found, err := xc.GetPurchaseOrder(ctx, "at", "tenant-1", poID)
+	if err != nil || !found || po.Status != "AUTHORISED" || po.Contact.AccountNumber != "SUP-1" || po.LineItems[0].Quantity != 10 {
+		t.Fatalf("get po: %+v %v %v", po, found, err)
+	}
+	if _, found, err := xc.GetPurchaseOrder(ctx, "at", "tenant-1", "po-missing"); err != nil || found {
+		t.Fatalf("missing po: found=%v err=%v", found, err)
+	}
+	pos, err := xc.ListPurchaseOrders(ctx, "at", "tenant-1", time.Now().AddDate(0, 0, -1))
+	if err != nil || len(pos) != 1 {
+		t.Fatalf("list pos: %+v, %v", pos, err)
+	}
+	if pos, err := xc.ListPurchaseOrders(ctx, "at", "tenant-1", time.Now().AddDate(0, 0, 2)); err != nil || len(pos) != 0 {
+		t.Fatalf("list future pos: %+v, %v", pos, err)
+	}
+}
+
+func TestServer_RejectsUnknownTenantAndMissingToken(t *testing.T) {
+	t.Parallel()
+	s := newServer(t)
+	ctx := context.Background()
+	xc := s.Client()
+
+	if _, err := xc.GetItemsByCodes(ctx, "at", "other-tenant", []string{"BOLT"}); err == nil || !strings.Contains(err.Error(), "status=403") {
+		t.Fatalf("expected 403 for unknown tenant, got %v", err)
+	}
+	if _, err := xc.GetConnections(ctx, ""); err == nil || !strings.Contains(err.Error(), "status=401") {
+		t.Fatalf("expected 401 without token, got %v", err)
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	t.Parallel()
+	s := newServer(t)
+	ctx := context.Background()
+	get := func() (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+"/api.xro/2.0/Items", nil)
+		req.Header.Set("Authorization", "Bearer at")
+		req.Header.Set("Xero-tenant-id", "tenant-1")
+		resp, err := s.Server.Client().Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	s.SetRateLimit(xerotest.RateLimit{PerMinute: 2, RetryAfter: 30 * time.Second})
+	for i := 0; i < 2; i++ {
+		if resp, err := get(); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: %v %v", i, resp.StatusCode, err)
+		}
+	}
+	resp, err := get()
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "30" {
+		t.Fatalf("expected 429 with Retry-After 30, got %v %v", resp.StatusCode, err)
+	}
+
+	s.SetRateLimit(xerotest.RateLimit{})
+	s.Throttle(1)
+	if resp, _ := get(); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("throttled request: %d", resp.StatusCode)
+	}
+	if resp, _ := get(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("request after throttle: %d", resp.StatusCode)
+	}
+	if n := len(s.Requests()); n != 5 {
+		t.Fatalf("recorded %d requests, want 5", n)
+	}
+}
+
+func TestServer_ModifiedSince(t *testing.T) {
+	t.Parallel()
+	s := newServer(t)
+	ctx := context.Background()
+	xc := s.Client()
+
+	before := time.Now().Add(-time.Second)
+	if err := xc.UpsertItemsBatch(ctx, "at", "tenant-1", []xero.Part{{PartID: "NUT", Name: "M6 nyloc nut"}}); err != nil {
+		t.Fatalf("upsert items: %v", err)
+	}
+	items, err := xc.GetAllItems(ctx, "at", "tenant-1", xero.ItemsQuery{ModifiedSince: before})
+	if err != nil || len(items) != 1 || items[0].Code != "NUT" || items[0].UpdatedDateUTC.IsZero() {
+		t.Fatalf("changed items: %+v, %v", items, err)
+	}
+	if all, err := xc.GetAllItems(ctx, "at", "tenant-1", xero.ItemsQuery{}); err != nil || len(all) != 2 {
+		t.Fatalf("all items: %+v, %v", all, err)
+	}
+
+	var contacts []xero.Contact
+	err = xc.EachContactsPage(ctx, "at", "tenant-1", xero.ContactsQuery{ModifiedSince: before}, func(cs []xero.Contact) error {
+		contacts = append(contacts, cs...)
+		return nil
+	})
+	if err != nil || len(contacts) != 0 {
+		t.Fatalf("unchanged contacts: %+v, %v", contacts, err)
+	}
+	if invs, err := xc.ListInvoices(ctx, "at", "tenant-1", xero.InvoicesQuery{}); err != nil || len(invs) != 1 || invs[0].InvoiceNumber != "INV-0001" {
+		t.Fatalf("invoices: %+v, %v", invs, err)
+	}
+}
+
+func TestServer_SearchInvoices(t *testing.T) {
+	t.Parallel()
+	s := xerotest.New(xerotest.Fixtures{
+		Tenants: []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		Invoices: []xerotest.Invoice{
+			{InvoiceID: "inv-1", InvoiceNumber: "INV-0001", Contact: xerotest.PurchaseContact{Name: "Harbour Kitchens"}, DateString: "2025-03-03T00:00:00", Total: 540},
+			{InvoiceID: "inv-2", InvoiceNumber: "INV-0002", Contact: xerotest.PurchaseContact{Name: "Lakeside Joinery"}, DateString: "2025-03-10T00:00:00", Total: 260},
+			{InvoiceID: "bill-1", InvoiceNumber: "BILL-1", Type: "ACCPAY", Contact: xerotest.PurchaseContact{Name: "Harbour Timber"}, DateString: "2025-03-11T00:00:00"},
+		},
+	})
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+	xc := s.Client()
+
+	invs, err := xc.SearchInvoices(ctx, "at", "tenant-1", xero.InvoiceSearch{})
+	if err != nil || len(invs) != 2 || invs[0].InvoiceNumber != "INV-0002" || invs[1].Total != 540 {
+		t.Fatalf("all sales invoices, newest first: %+v, %v", invs, err)
+	}
+	if invs[0].Contact.Name != "Lakeside Joinery" || !invs[0].Date.Equal(time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected summary %+v", invs[0])
+	}
+	invs, err = xc.SearchInvoices(ctx, "at", "tenant-1", xero.InvoiceSearch{Contact: "harbour"})
+	if err != nil || len(invs) != 1 || invs[0].InvoiceNumber != "INV-0001" {
+		t.Fatalf("by contact: %+v, %v", invs, err)
+	}
+	invs, err = xc.SearchInvoices(ctx, "at", "tenant-1", xero.InvoiceSearch{
+		From: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil || len(invs) != 1 || invs[0].InvoiceNumber != "INV-0002" {
+		t.Fatalf("by date: %+v, %v", invs, err)
+	}
+	invs, err = xc.SearchInvoices(ctx, "at", "tenant-1", xero.InvoiceSearch{Page: 2, PageSize: 1})
+	if err != nil || len(invs) != 1 || invs[0].InvoiceNumber != "INV-0001" {
+		t.Fatalf("page 2: %+v, %v", invs, err)
+	}
+}
+
+func TestServer_CreditNotes(t *testing.T) {
+	t.Parallel()
+	s := xerotest.New(xerotest.Fixtures{
+		Tenants: []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		CreditNotes: []xerotest.CreditNote{{
+			CreditNoteID: "cn-1", CreditNoteNumber: "CN-0001",
+			LineItems:   []xerotest.LineItem{{ItemCode: "KIT-001", Description: "Cabinet door kit", Quantity: 1}},
+			Allocations: []xerotest.Allocation{{Amount: 45, Invoice: xerotest.InvoiceRef{InvoiceNumber: "INV-0001"}}},
+		}},
+	})
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+	xc := s.Client()
+
+	cn, err := xc.GetCreditNote(ctx, "at", "tenant-1", "CN-0001")
+	if err != nil || cn.CreditNoteID != "cn-1" || cn.Type != xero.CreditNoteTypeSales || cn.Status != "AUTHORISED" {
+		t.Fatalf("credit note: %+v, %v", cn, err)
+	}
+	if len(cn.Lines) != 1 || cn.Lines[0].Quantity != 1 || len(cn.Invoices) != 1 || cn.Invoices[0] != "INV-0001" {
+		t.Fatalf("lines %+v, invoices %v", cn.Lines, cn.Invoices)
+	}
+	if _, err := xc.GetCreditNote(ctx, "at", "tenant-1", "CN-0404"); !errors.Is(err, xero.ErrNotFound) {
+		t.Fatalf("unknown credit note: %v", err)
+	}
+}
+
+func TestServer_ListBills(t *testing.T) {
+	t.Parallel()
+	s := xerotest.New(xerotest.Fixtures{
+		Tenants: []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		Invoices: []xerotest.Invoice{
+			{InvoiceID: "inv-1", InvoiceNumber: "INV-0001", Type: "ACCREC", Status: "AUTHORISED", Contact: xerotest.PurchaseContact{ContactID: "c-1"}, DateString: "2025-03-03T00:00:00", Total: 540},
+			{InvoiceID: "bill-1", InvoiceNumber: "BILL-1", Type: "ACCPAY", Status: "PAID", Contact: xerotest.PurchaseContact{ContactID: "c-1"}, DateString: "2025-03-11T00:00:00", Total: 120},
+			{InvoiceID: "bill-2", InvoiceNumber: "BILL-2", Type: "ACCPAY", Status: "VOIDED", Contact: xerotest.PurchaseContact{ContactID: "c-1"}, DateString: "2025-03-12T00:00:00", Total: 80},
+			{InvoiceID: "bill-3", InvoiceNumber: "BILL-3", Type: "ACCPAY", Status: "AUTHORISED", Contact: xerotest.PurchaseContact{ContactID: "c-2"}, DateString: "2025-02-01T00:00:00", Total: 45},
+		},
+	})
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+	xc := s.Client()
+
+	bills, err := xc.ListBills(ctx, "at", "tenant-1", xero.BillsQuery{})
+	if err != nil || len(bills) != 3 {
+		t.Fatalf("all bills: %+v, %v", bills, err)
+	}
+	bills, err = xc.ListBills(ctx, "at", "tenant-1", xero.BillsQuery{ContactIDs: []string{"c-1"}, Statuses: []string{"AUTHORISED", "PAID"}})
+	if err != nil || len(bills) != 1 || bills[0].InvoiceNumber != "BILL-1" || bills[0].Total != 120 {
+		t.Fatalf("by contact and status: %+v, %v", bills, err)
+	}
+	bills, err = xc.ListBills(ctx, "at", "tenant-1", xero.BillsQuery{From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil || len(bills) != 2 {
+		t.Fatalf("by date: %+v, %v", bills, err)
+	}
+}
+
+func TestServer_Currencies(t *testing.T) {
+	t.Parallel()
+	s := xerotest.New(xerotest.Fixtures{
+		Tenants: []xerotest.Tenant{{TenantID: "tenant-1", TenantName: "Acme Ltd"}},
+		Contacts: []xerotest.Contact{
+			{ContactID: "c-1", Name: "Fasteners Inc", AccountNumber: "SUP-1"},
+			{ContactID: "c-2", Name: "Schrauben GmbH", AccountNumber: "SUP-2", DefaultCurrency: "EUR"},
+		},
+		Invoices: []xerotest.Invoice{
+			{InvoiceID: "bill-1", InvoiceNumber: "BILL-1", Type: "ACCPAY", Status: "PAID", Contact: xerotest.PurchaseContact{ContactID: "c-2"}, DateString: "2025-03-03T00:00:00", CurrencyCode: "EUR", CurrencyRate: 1.15},
+			{InvoiceID: "bill-2", InvoiceNumber: "BILL-2", Type: "ACCPAY", Status: "AUTHORISED", Contact: xerotest.PurchaseContact{ContactID: "c-2"}, DateString: "2025-03-10T00:00:00", CurrencyCode: "EUR", CurrencyRate: 1.17},
+			{InvoiceID: "bill-3", InvoiceNumber: "BILL-3", Type: "ACCPAY", Status: "VOIDED", Contact: xerotest.PurchaseContact{ContactID: "c-2"}, DateString: "2025-03-12T00:00:00", CurrencyCode: "EUR", CurrencyRate: 2},
+			{InvoiceID: "bill-4", InvoiceNumber: "BILL-4", Type: "ACCPAY", Status: "PAID", Contact: xerotest.PurchaseContact{ContactID: "c-1"}, DateString: "2025-03-13T00:00:00"},
+		},
+	})
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+	xc := s.Client()
+
+	currencies, err := xc.GetContactCurrencies(ctx, "at", "tenant-1", []string{"SUP-1", "SUP-2", "SUP-9"})
+	if err != nil || len(currencies) != 1 || currencies["SUP-2"] != "EUR" {
+		t.Fatalf("contact currencies: %+v, %v", currencies, err)
+	}
+	bill, found, err := xc.LatestBill(ctx, "at", "tenant-1", "EUR")
+	if err != nil || !found || bill.InvoiceNumber != "BILL-2" || bill.CurrencyRate != 1.17 {
+		t.Fatalf("latest EUR bill: %+v %v %v", bill, found, err)
+	}
+	if _, found, err := xc.LatestBill(ctx, "at", "tenant-1", "USD"); err != nil || found {
+		t.Fatalf("latest USD bill: found=%v err=%v", found, err)
+	}
+
+	if _, err := xc.CreatePurchaseOrder(ctx, "at", "tenant-1", "c-2", []xero.POItem{{ItemCode: "BOLT", Quantity: 1}}, xero.PODetails{CurrencyCode: "EUR"}); err != nil {
+		t.Fatalf("create po: %v", err)
+	}
+	if pos := s.PurchaseOrders(); len(pos) != 1 || pos[0].CurrencyCode != "EUR" {
+		t.Fatalf("stored pos: %+v", pos)
+	}
+}
+
+func TestServer_TrackingCategories(t *testing.T) {
+	t.Parallel()
+	s := newServer(t)
+	ctx := context.Background()
+	xc := s.Client()
+
+	cats, err := xc.GetTrackingCategories(ctx, "at", "tenant-1")
+	if err != nil || len(cats) != 1 || cats[0].Status != "ACTIVE" || len(cats[0].Options) != 1 {
+		t.Fatalf("categories: %+v, %v", cats, err)
+	}
+	line := func(option string) []xero.POItem {
+		return []xero.POItem{{ItemCode: "BOLT", Quantity: 1, Tracking: []xero.LineTracking{{Name: "Build", Option: option}}}}
+	}
+	if _, err := xc.CreatePurchaseOrder(ctx, "at", "tenant-1", "contact-1", line("INV-0002"), xero.PODetails{}); err == nil {
+		t.Fatal("expected a validation error for an unknown option")
+	}
+	o, err := xc.CreateTrackingOption(ctx, "at", "tenant-1", "tc-build", "INV-0002")
+	if err != nil || o.TrackingOptionID == "" {
+		t.Fatalf("create option: %+v, %v", o, err)
+	}
+	if _, err := xc.CreateTrackingOption(ctx, "at", "tenant-1", "tc-build", "inv-0002"); err == nil {
+		t.Fatal("expected an error for a duplicate option")
+	}
+	if _, err := xc.CreatePurchaseOrder(ctx, "at", "tenant-1", "contact-1", line("INV-0002"), xero.PODetails{}); err != nil {
+		t.Fatalf("create PO: %v", err)
+	}
+	pos := s.PurchaseOrders()
+	if tr := pos[len(pos)-1].LineItems[0].Tracking; len(tr) != 1 || tr[0].Option != "INV-0002" {
+		t.Fatalf("tracking not stored: %+v", pos)
+	}
+	if got := s.TrackingCategories(); len(got[0].Options) != 2 {
+		t.Fatalf("options: %+v", got)
+	}
+}
@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Load sample parts, BOMs, suppliers and shopping list rows into the dev database.
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+APP_DIR="${SCRIPT_DIR}/../control-panel/cmd/main"
+
+pushd "$APP_DIR" >/dev/null
+go run main.go seed-dev "$@"
+popd >/dev/null
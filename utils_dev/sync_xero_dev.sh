@@ -0,0 +1,10 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Push parts and suppliers to Xero. Pass --dry-run to preview.
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+APP_DIR="${SCRIPT_DIR}/../control-panel/cmd/main"
+
+pushd "$APP_DIR" >/dev/null
+go run main.go sync-xero --dev "$@"
+popd >/dev/null